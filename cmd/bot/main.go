@@ -14,6 +14,8 @@ import (
 
 	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/handlers"
+	"github.com/ad/go-telegram-quest/internal/httpapi"
+	"github.com/ad/go-telegram-quest/internal/metrics"
 	"github.com/ad/go-telegram-quest/internal/services"
 	"github.com/go-telegram/bot"
 	tgmodels "github.com/go-telegram/bot/models"
@@ -41,6 +43,103 @@ func main() {
 		dbPath = "quest.db"
 	}
 
+	stickerPackRetryAttempts := 3
+	if value := os.Getenv("STICKER_PACK_RETRY_ATTEMPTS"); value != "" {
+		stickerPackRetryAttempts, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("Invalid STICKER_PACK_RETRY_ATTEMPTS: %v", err)
+		}
+	}
+
+	stickerOperationsPerMinute := services.DefaultStickerOperationsPerMinute
+	if value := os.Getenv("STICKER_OPERATIONS_PER_MINUTE"); value != "" {
+		stickerOperationsPerMinute, err = strconv.Atoi(value)
+		if err != nil || stickerOperationsPerMinute < 0 {
+			log.Fatalf("Invalid STICKER_OPERATIONS_PER_MINUTE: %q", value)
+		}
+	}
+
+	achievementEvaluationMode, err := services.ParseEvaluationMode(os.Getenv("ACHIEVEMENT_EVALUATION"))
+	if err != nil {
+		log.Fatalf("Invalid ACHIEVEMENT_EVALUATION: %v", err)
+	}
+
+	var achievementEvaluationDebounce time.Duration
+	if value := os.Getenv("ACHIEVEMENT_EVALUATION_DEBOUNCE"); value != "" {
+		achievementEvaluationDebounce, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid ACHIEVEMENT_EVALUATION_DEBOUNCE: %v", err)
+		}
+	}
+
+	flawlessTimeLimit := services.DefaultFlawlessTimeLimitMinutes
+	if value := os.Getenv("FLAWLESS_TIME_MINUTES"); value != "" {
+		flawlessTimeLimit, err = strconv.Atoi(value)
+		if err != nil || flawlessTimeLimit <= 0 {
+			log.Fatalf("Invalid FLAWLESS_TIME_MINUTES: %q", value)
+		}
+	}
+
+	achievementThresholdMode, err := services.ParseThresholdMode(os.Getenv("ACHIEVEMENT_THRESHOLDS"))
+	if err != nil {
+		log.Fatalf("Invalid ACHIEVEMENT_THRESHOLDS: %v", err)
+	}
+
+	duplicatePolicy, err := services.ParseDuplicatePolicy(os.Getenv("DUPLICATE_MESSAGES"))
+	if err != nil {
+		log.Fatalf("Invalid DUPLICATE_MESSAGES: %v", err)
+	}
+
+	maxMessageLength := services.MaxMessageLength
+	if value := os.Getenv("MAX_MESSAGE_LENGTH"); value != "" {
+		maxMessageLength, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("Invalid MAX_MESSAGE_LENGTH: %v", err)
+		}
+	}
+
+	statsCacheTTL := 30 * time.Second
+	if value := os.Getenv("STATS_CACHE_TTL"); value != "" {
+		statsCacheTTL, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid STATS_CACHE_TTL: %v", err)
+		}
+	}
+
+	hallOfFameInterval := 10 * time.Minute
+	if value := os.Getenv("HALL_OF_FAME_INTERVAL"); value != "" {
+		hallOfFameInterval, err = time.ParseDuration(value)
+		if err != nil || hallOfFameInterval <= 0 {
+			log.Fatalf("Invalid HALL_OF_FAME_INTERVAL: %q", value)
+		}
+	}
+
+	answerRetentionDays := 0
+	if value := os.Getenv("ANSWER_RETENTION_DAYS"); value != "" {
+		answerRetentionDays, err = strconv.Atoi(value)
+		if err != nil || answerRetentionDays < 0 {
+			log.Fatalf("Invalid ANSWER_RETENTION_DAYS: %q", value)
+		}
+	}
+
+	notificationRetryAttempts := services.DefaultNotificationRetryAttempts
+	if value := os.Getenv("NOTIFICATION_RETRY_ATTEMPTS"); value != "" {
+		notificationRetryAttempts, err = strconv.Atoi(value)
+		if err != nil || notificationRetryAttempts < 0 {
+			log.Fatalf("Invalid NOTIFICATION_RETRY_ATTEMPTS: %q", value)
+		}
+	}
+
+	notificationRetryInterval := time.Minute
+	if value := os.Getenv("NOTIFICATION_RETRY_INTERVAL"); value != "" {
+		notificationRetryInterval, err = time.ParseDuration(value)
+		if err != nil || notificationRetryInterval <= 0 {
+			log.Fatalf("Invalid NOTIFICATION_RETRY_INTERVAL: %q", value)
+		}
+	}
+
+	metricsAddr := os.Getenv("METRICS_ADDR")
+
 	sqlDB, err := sql.Open("sqlite", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
@@ -63,6 +162,7 @@ func main() {
 	adminMessagesRepo := db.NewAdminMessagesRepository(dbQueue)
 	adminStateRepo := db.NewAdminStateRepository(dbQueue)
 	achievementRepo := db.NewAchievementRepository(dbQueue)
+	stepReportRepo := db.NewStepReportRepository(dbQueue)
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
@@ -100,19 +200,36 @@ func main() {
 
 	stickerPackRepo := db.NewStickerPackRepository(dbQueue)
 	stickerService := services.NewStickerService(b, stickerPackRepo, botUsername, botToken)
+	stickerService.SetRateLimiter(services.NewStickerRateLimiter(stickerOperationsPerMinute, services.DefaultStickerBurst))
 
 	errorManager := services.NewErrorManager(b, adminID)
 	stateResolver := services.NewStateResolver(stepRepo, progressRepo, userRepo)
-	answerChecker := services.NewAnswerChecker(answerRepo, progressRepo, userRepo)
+	stateResolver.SetSettingsRepository(settingsRepo)
+	answerChecker := services.NewAnswerChecker(answerRepo, progressRepo, userRepo, settingsRepo)
 	msgManager := services.NewMessageManager(b, chatStateRepo, errorManager)
+	msgManager.SetMaxMessageLength(maxMessageLength)
+	msgManager.SetDuplicatePolicy(duplicatePolicy)
 	statsService := services.NewStatisticsServiceWithAchievements(dbQueue, stepRepo, progressRepo, userRepo, achievementRepo)
+	statsService.SetCacheTTL(statsCacheTTL)
+	statsService.SetSettingsRepository(settingsRepo)
 	achievementEngine := services.NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, dbQueue)
+	achievementEngine.SetEvaluationMode(achievementEvaluationMode)
+	achievementEngine.SetEvaluationDebounce(achievementEvaluationDebounce)
+	achievementEngine.SetFlawlessTimeLimit(flawlessTimeLimit)
+	achievementEngine.SetThresholdMode(achievementThresholdMode)
+	achievementEngine.SetSettingsRepository(settingsRepo)
 	userManager := services.NewUserManager(userRepo, stepRepo, progressRepo, answerRepo, chatStateRepo, achievementRepo, statsService, achievementEngine)
 	questStateManager := services.NewQuestStateManager(settingsRepo)
 	achievementNotifier := services.NewAchievementNotifier(b, achievementRepo, msgManager, stickerService)
 	achievementService := services.NewAchievementService(achievementRepo, userRepo)
 	retroactiveProcessor := services.NewRetroactiveProcessor(achievementEngine, achievementRepo, userRepo)
 	groupChatVerifier := services.NewGroupChatVerifier(b, settingsRepo)
+	referralService := services.NewReferralService(db.NewReferralRepository(dbQueue), userRepo, botUsername)
+	channelAnnouncer := services.NewChannelAnnouncer(b, settingsRepo, userRepo)
+	hallOfFame := services.NewHallOfFamePublisher(b, settingsRepo, userRepo, achievementRepo, achievementEngine)
+	diagnostics := services.NewDiagnosticsService(dbQueue, settingsRepo, b, dbPath)
+	questConfig := services.NewQuestConfigService(dbQueue, stepRepo, settingsRepo, achievementRepo, answerRepo)
+	unlockService := services.NewUnlockService(db.NewUnlockCodeRepository(dbQueue))
 
 	handler := handlers.NewBotHandler(
 		b,
@@ -130,15 +247,26 @@ func main() {
 		chatStateRepo,
 		adminMessagesRepo,
 		adminStateRepo,
+		stepReportRepo,
 		userManager,
 		questStateManager,
 		achievementEngine,
 		achievementNotifier,
 		achievementService,
 		groupChatVerifier,
+		referralService,
+		channelAnnouncer,
+		diagnostics,
+		questConfig,
+		unlockService,
 		dbPath,
 	)
 
+	notificationQueue := services.NewNotificationQueue(db.NewPendingNotificationRepository(dbQueue))
+	notificationQueue.SetMaxAttempts(notificationRetryAttempts)
+	achievementNotifier.SetNotificationQueue(notificationQueue)
+	handler.SetNotificationQueue(notificationQueue)
+
 	b.RegisterHandlerMatchFunc(func(update *tgmodels.Update) bool {
 		return true
 	}, handler.HandleUpdate, logMiddleware)
@@ -147,6 +275,7 @@ func main() {
 
 	// Process retroactive winner achievements
 	go func() {
+		defer errorManager.Recover(ctx, "retroactive achievements")
 		// log.Printf("Starting retroactive processing for winner achievements...")
 		for _, achievementKey := range []string{"winner_1", "winner_2", "winner_3", "hint_30", "writer"} {
 			if _, err := retroactiveProcessor.ProcessAchievementSync(achievementKey, 50); err != nil {
@@ -157,6 +286,172 @@ func main() {
 		}
 	}()
 
+	// Prometheus metrics endpoint
+	if metricsAddr != "" {
+		server := &http.Server{
+			Addr:    metricsAddr,
+			Handler: httpapi.NewMux(httpapi.NewMetricsHandler(metrics.Default, userRepo, progressRepo)),
+		}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Metrics server failed: %v", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+	}
+
+	// Retry sticker packs whose creation failed earlier
+	go func() {
+		ticker := time.NewTicker(6 * time.Hour)
+		defer ticker.Stop()
+		for {
+			errorManager.Guard(ctx, "sticker pack repair", func() {
+				repaired, err := stickerService.RepairStickerPacks(ctx, stickerPackRetryAttempts, 3*time.Second)
+				if err != nil {
+					log.Printf("Failed to repair sticker packs: %v", err)
+					errorManager.Report(ctx, services.ErrorCategoryDB, fmt.Errorf("repair sticker packs: %w", err))
+				} else if repaired > 0 {
+					log.Printf("Repaired %d sticker packs", repaired)
+				}
+			})
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	// Periodic achievement consistency check
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				errorManager.Guard(ctx, "achievement audit", func() {
+					inconsistencies, err := achievementEngine.AuditConsistency()
+					if err != nil {
+						log.Printf("Failed to audit achievements: %v", err)
+						errorManager.Report(ctx, services.ErrorCategoryDB, fmt.Errorf("audit achievements: %w", err))
+						return
+					}
+					if len(inconsistencies) == 0 {
+						return
+					}
+					msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+						ChatID: adminID,
+						Text:   handlers.FormatAchievementAudit(inconsistencies),
+					})
+				})
+			}
+		}
+	}()
+
+	// Daily removal of rows that reference deleted answers, steps or users
+	// and of answers past the retention period
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			errorManager.Guard(ctx, "orphan cleanup", func() {
+				cleanup, err := db.CleanupOrphans(dbQueue)
+				if err != nil {
+					log.Printf("Failed to clean up orphaned rows: %v", err)
+					errorManager.Report(ctx, services.ErrorCategoryDB, fmt.Errorf("cleanup orphans: %w", err))
+				} else if cleanup.Total() > 0 {
+					log.Printf("Removed orphaned rows: %s", cleanup)
+					msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+						ChatID: adminID,
+						Text:   handlers.FormatOrphanCleanup(cleanup),
+					})
+				}
+
+				if answerRetentionDays > 0 {
+					purge, err := db.PurgeOldAnswers(dbQueue, time.Now().AddDate(0, 0, -answerRetentionDays))
+					if err != nil {
+						log.Printf("Failed to purge old answers: %v", err)
+						errorManager.Report(ctx, services.ErrorCategoryDB, fmt.Errorf("purge old answers: %w", err))
+					} else if purge.UserAnswers > 0 {
+						log.Printf("Purged answers older than %d days: %s", answerRetentionDays, purge)
+					}
+				}
+			})
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	// Pinned hall of fame in the required group chat; the message is edited
+	// only when its content changes
+	go func() {
+		ticker := time.NewTicker(hallOfFameInterval)
+		defer ticker.Stop()
+		for {
+			errorManager.Guard(ctx, "hall of fame", func() {
+				if err := hallOfFame.Update(ctx); err != nil {
+					log.Printf("Failed to update hall of fame: %v", err)
+				}
+			})
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	// Redelivery of achievement notifications and steps that failed to send
+	// because of transient Telegram or network errors
+	go func() {
+		ticker := time.NewTicker(notificationRetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				errorManager.Guard(ctx, "notification retry", func() {
+					result, err := notificationQueue.Retry(ctx)
+					if err != nil {
+						log.Printf("Failed to retry notifications: %v", err)
+						errorManager.Report(ctx, services.ErrorCategoryDB, fmt.Errorf("retry notifications: %w", err))
+					} else if result.Delivered > 0 || result.Dropped > 0 {
+						log.Printf("Retried notifications: delivered %d, dropped %d, pending %d", result.Delivered, result.Dropped, result.Pending)
+					}
+				})
+			}
+		}
+	}()
+
+	// Minute-level jobs: auto approval of stale reviews, the daily admin digest
+	// and summaries of repeated errors collapsed by the error manager
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				errorManager.Guard(ctx, "review auto approval", func() { handler.AutoApproveStaleReviews(ctx) })
+				errorManager.Guard(ctx, "daily digest", func() { handler.SendDailyDigestIfDue(ctx, time.Now()) })
+				errorManager.FlushSuppressed(ctx)
+			}
+		}
+	}()
+
 	b.Start(ctx)
 }
 
@@ -73,12 +73,13 @@ func TestBotHandlerIntegration(t *testing.T) {
 	adminMessagesRepo := db.NewAdminMessagesRepository(dbQueue)
 	adminStateRepo := db.NewAdminStateRepository(dbQueue)
 	achievementRepo := db.NewAchievementRepository(dbQueue)
+	stepReportRepo := db.NewStepReportRepository(dbQueue)
 
 	adminID := int64(123456)
 
 	errorManager := services.NewErrorManager(nil, adminID)
 	stateResolver := services.NewStateResolver(stepRepo, progressRepo, userRepo)
-	answerChecker := services.NewAnswerChecker(answerRepo, progressRepo, userRepo)
+	answerChecker := services.NewAnswerChecker(answerRepo, progressRepo, userRepo, settingsRepo)
 	msgManager := services.NewMessageManager(nil, chatStateRepo, errorManager)
 	statsService := services.NewStatisticsService(dbQueue, stepRepo, progressRepo, userRepo)
 	achievementEngine := services.NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, dbQueue)
@@ -103,12 +104,18 @@ func TestBotHandlerIntegration(t *testing.T) {
 		chatStateRepo,
 		adminMessagesRepo,
 		adminStateRepo,
+		stepReportRepo,
 		userManager,
 		questStateManager,
 		achievementEngine,
 		achievementNotifier,
 		achievementService,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 		"",
 	)
 
@@ -186,6 +193,8 @@ func TestAdminHandlerIntegration(t *testing.T) {
 	progressRepo := db.NewProgressRepository(dbQueue)
 	chatStateRepo := db.NewChatStateRepository(dbQueue)
 	achievementRepo := db.NewAchievementRepository(dbQueue)
+	stepReportRepo := db.NewStepReportRepository(dbQueue)
+	adminMessagesRepo := db.NewAdminMessagesRepository(dbQueue)
 
 	adminID := int64(123456)
 
@@ -200,8 +209,11 @@ func TestAdminHandlerIntegration(t *testing.T) {
 		adminID,
 		stepRepo,
 		answerRepo,
+		progressRepo,
 		settingsRepo,
 		adminStateRepo,
+		stepReportRepo,
+		adminMessagesRepo,
 		userManager,
 		userRepo,
 		questStateManager,
@@ -210,6 +222,10 @@ func TestAdminHandlerIntegration(t *testing.T) {
 		nil, // achievementNotifier
 		statsService,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 		"",
 	)
 
@@ -384,6 +384,19 @@ func getDefaultAchievements() []*models.Achievement {
 		IsActive: true,
 	})
 
+	achievements = append(achievements, &models.Achievement{
+		Key:         "completionist",
+		Name:        "Перфекционист",
+		Description: "Завершить квест, ответив на все вопросы со звёздочкой",
+		Category:    models.CategoryCompletion,
+		Type:        models.TypeActionBased,
+		IsUnique:    false,
+		Conditions: models.AchievementConditions{
+			AllAsteriskAnswered: boolPtr(true),
+		},
+		IsActive: true,
+	})
+
 	// Hint-based achievements
 	achievements = append(achievements, &models.Achievement{
 		Key:         "hint_5",
@@ -620,6 +633,72 @@ func getDefaultAchievements() []*models.Achievement {
 		IsActive: true,
 	})
 
+	achievements = append(achievements, &models.Achievement{
+		Key:         "comeback",
+		Name:        "Возвращение",
+		Description: "Вернуться в квест после долгого перерыва и пройти шаг",
+		Category:    models.CategorySpecial,
+		Type:        models.TypeTimeBased,
+		IsUnique:    false,
+		Conditions: models.AchievementConditions{
+			ComebackDays: intPtr(7),
+		},
+		IsActive: true,
+	})
+
+	achievements = append(achievements, &models.Achievement{
+		Key:         "night_owl",
+		Name:        "Сова",
+		Description: "Дать правильный ответ глубокой ночью (с 0:00 до 5:00 по вашему времени)",
+		Category:    models.CategorySpecial,
+		Type:        models.TypeTimeBased,
+		IsUnique:    false,
+		Conditions: models.AchievementConditions{
+			LocalHourFrom: intPtr(0),
+			LocalHourTo:   intPtr(5),
+		},
+		IsActive: true,
+	})
+
+	achievements = append(achievements, &models.Achievement{
+		Key:         "recruiter",
+		Name:        "Вербовщик",
+		Description: "Пригласить по своей ссылке новых участников, которые прошли хотя бы один шаг",
+		Category:    models.CategorySpecial,
+		Type:        models.TypeActionBased,
+		IsUnique:    false,
+		Conditions: models.AchievementConditions{
+			ReferralCount: intPtr(3),
+		},
+		IsActive: true,
+	})
+
+	achievements = append(achievements, &models.Achievement{
+		Key:         "helper",
+		Name:        "Помощник",
+		Description: "Сообщить через /report о проблемах, которые организаторы отметили полезными",
+		Category:    models.CategorySpecial,
+		Type:        models.TypeActionBased,
+		IsUnique:    false,
+		Conditions: models.AchievementConditions{
+			UsefulReportCount: intPtr(3),
+		},
+		IsActive: true,
+	})
+
+	achievements = append(achievements, &models.Achievement{
+		Key:         "streak",
+		Name:        "Постоянство",
+		Description: "Проходить шаги 3 дня подряд (дни считаются по вашему времени)",
+		Category:    models.CategorySpecial,
+		Type:        models.TypeTimeBased,
+		IsUnique:    false,
+		Conditions: models.AchievementConditions{
+			StreakDays: intPtr(3),
+		},
+		IsActive: true,
+	})
+
 	// Manual achievements (awarded by admin)
 	achievements = append(achievements, &models.Achievement{
 		Key:         "veteran",
@@ -689,6 +768,21 @@ func getDefaultAchievements() []*models.Achievement {
 		IsActive: true,
 	})
 
+	achievements = append(achievements, &models.Achievement{
+		Key:         "flawless",
+		Name:        "Безупречный",
+		Description: "Завершить квест без единой ошибки и подсказки быстрее заданного лимита времени",
+		Category:    models.CategoryComposite,
+		Type:        models.TypeComposite,
+		IsUnique:    false,
+		Conditions: models.AchievementConditions{
+			NoErrors:              boolPtr(true),
+			NoHints:               boolPtr(true),
+			CompletionTimeMinutes: intPtr(15),
+		},
+		IsActive: true,
+	})
+
 	achievements = append(achievements, &models.Achievement{
 		Key:         "legend",
 		Name:        "Легенда",
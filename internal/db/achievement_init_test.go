@@ -52,6 +52,7 @@ func TestInitializeDefaultAchievements(t *testing.T) {
 		"lightning":       {"Молния", models.CategoryCompletion, false, false},
 		"rocket":          {"Ракета", models.CategoryCompletion, false, false},
 		"cheater":         {"Жулик", models.CategoryCompletion, false, false},
+		"completionist":   {"Перфекционист", models.CategoryCompletion, false, false},
 		"hint_5":          {"Подсказочный 5", models.CategoryHints, false, false},
 		"hint_10":         {"Подсказочный 10", models.CategoryHints, false, false},
 		"hint_15":         {"Подсказочный 15", models.CategoryHints, false, false},
@@ -72,10 +73,16 @@ func TestInitializeDefaultAchievements(t *testing.T) {
 		"wow":             {"Вау! За отличный ответ", models.CategorySpecial, false, false},
 		"super_collector": {"Суперколлекционер", models.CategoryComposite, false, false},
 		"super_brain":     {"Супермозг", models.CategoryComposite, false, false},
+		"flawless":        {"Безупречный", models.CategoryComposite, false, false},
 		"legend":          {"Легенда", models.CategoryComposite, false, false},
 		"asterisk":        {"Вопрос со звёздочкой", models.CategorySpecial, false, false},
 		"unseen":          {"Невидимый собеседник", models.CategorySpecial, false, false},
 		"voice":           {"Голос свыше", models.CategorySpecial, false, false},
+		"comeback":        {"Возвращение", models.CategorySpecial, false, false},
+		"night_owl":       {"Сова", models.CategorySpecial, false, false},
+		"streak":          {"Постоянство", models.CategorySpecial, false, false},
+		"recruiter":       {"Вербовщик", models.CategorySpecial, false, false},
+		"helper":          {"Помощник", models.CategorySpecial, false, false},
 	}
 
 	for key, expected := range expectedAchievements {
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/ad/go-telegram-quest/internal/metrics"
 	"github.com/ad/go-telegram-quest/internal/models"
 )
 
@@ -44,7 +45,7 @@ func (r *AchievementRepository) Create(achievement *models.Achievement) error {
 func (r *AchievementRepository) GetByID(id int64) (*models.Achievement, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		row := db.QueryRow(`
-			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active
+			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active, notify_on_award
 			FROM achievements WHERE id = ?
 		`, id)
 		return scanAchievement(row)
@@ -58,7 +59,7 @@ func (r *AchievementRepository) GetByID(id int64) (*models.Achievement, error) {
 func (r *AchievementRepository) GetByKey(key string) (*models.Achievement, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		row := db.QueryRow(`
-			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active
+			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active, notify_on_award
 			FROM achievements WHERE key = ?
 		`, key)
 		return scanAchievement(row)
@@ -72,7 +73,7 @@ func (r *AchievementRepository) GetByKey(key string) (*models.Achievement, error
 func (r *AchievementRepository) GetAll() ([]*models.Achievement, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
-			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active
+			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active, notify_on_award
 			FROM achievements ORDER BY created_at
 		`)
 		if err != nil {
@@ -90,7 +91,7 @@ func (r *AchievementRepository) GetAll() ([]*models.Achievement, error) {
 func (r *AchievementRepository) GetActive() ([]*models.Achievement, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
-			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active
+			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active, notify_on_award
 			FROM achievements WHERE is_active = 1 ORDER BY created_at
 		`)
 		if err != nil {
@@ -108,7 +109,7 @@ func (r *AchievementRepository) GetActive() ([]*models.Achievement, error) {
 func (r *AchievementRepository) GetByCategory(category models.AchievementCategory) ([]*models.Achievement, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
-			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active
+			SELECT id, key, name, description, category, type, is_unique, conditions, created_at, is_active, notify_on_award
 			FROM achievements WHERE category = ? AND is_active = 1 ORDER BY created_at
 		`, category)
 		if err != nil {
@@ -142,13 +143,29 @@ func (r *AchievementRepository) Update(achievement *models.Achievement) error {
 	return err
 }
 
+// SetNotifyOnAward включает или отключает уведомление и стикер при получении достижения.
+// Само достижение выдаётся в любом случае
+func (r *AchievementRepository) SetNotifyOnAward(id int64, notify bool) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE achievements SET notify_on_award = ? WHERE id = ?`, notify, id)
+		return nil, err
+	})
+	return err
+}
+
 func (r *AchievementRepository) AssignToUser(userID, achievementID int64, earnedAt time.Time, isRetroactive bool) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
-		_, err := db.Exec(`
+		result, err := db.Exec(`
 			INSERT OR IGNORE INTO user_achievements (user_id, achievement_id, earned_at, is_retroactive)
 			VALUES (?, ?, ?, ?)
 		`, userID, achievementID, earnedAt, isRetroactive)
-		return nil, err
+		if err != nil {
+			return nil, err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+			metrics.Default.AchievementsAwarded.Inc()
+		}
+		return nil, nil
 	})
 	return err
 }
@@ -344,13 +361,25 @@ func (r *AchievementRepository) DeleteUserAchievements(userID int64) error {
 	return err
 }
 
+// DeleteUserAchievementsByCategory снимает с пользователя все достижения одной категории
+func (r *AchievementRepository) DeleteUserAchievementsByCategory(userID int64, category models.AchievementCategory) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			DELETE FROM user_achievements
+			WHERE user_id = ? AND achievement_id IN (SELECT id FROM achievements WHERE category = ?)
+		`, userID, category)
+		return nil, err
+	})
+	return err
+}
+
 func scanAchievement(row *sql.Row) (*models.Achievement, error) {
 	var achievement models.Achievement
 	var conditionsJSON string
 	err := row.Scan(
 		&achievement.ID, &achievement.Key, &achievement.Name, &achievement.Description,
 		&achievement.Category, &achievement.Type, &achievement.IsUnique,
-		&conditionsJSON, &achievement.CreatedAt, &achievement.IsActive,
+		&conditionsJSON, &achievement.CreatedAt, &achievement.IsActive, &achievement.NotifyOnAward,
 	)
 	if err != nil {
 		return nil, err
@@ -372,7 +401,7 @@ func scanAchievements(rows *sql.Rows) ([]*models.Achievement, error) {
 		if err := rows.Scan(
 			&achievement.ID, &achievement.Key, &achievement.Name, &achievement.Description,
 			&achievement.Category, &achievement.Type, &achievement.IsUnique,
-			&conditionsJSON, &achievement.CreatedAt, &achievement.IsActive,
+			&conditionsJSON, &achievement.CreatedAt, &achievement.IsActive, &achievement.NotifyOnAward,
 		); err != nil {
 			return nil, err
 		}
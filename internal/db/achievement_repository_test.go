@@ -282,3 +282,29 @@ func TestAchievementRepository_GetAchievementStats(t *testing.T) {
 		t.Errorf("Expected 1 user for stats_test_2, got %d", stats["stats_test_2"])
 	}
 }
+
+func TestAchievementRepository_SetNotifyOnAward(t *testing.T) {
+	db, achievementRepo, _ := setupAchievementTestDB(t)
+	defer db.Close()
+
+	achievement := createTestAchievement(t, achievementRepo, "notify_toggle")
+
+	stored, err := achievementRepo.GetByKey(achievement.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.NotifyOnAward {
+		t.Error("Expected new achievements to notify on award by default")
+	}
+
+	if err := achievementRepo.SetNotifyOnAward(achievement.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = achievementRepo.GetByKey(achievement.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.NotifyOnAward {
+		t.Error("Expected notifications to be disabled")
+	}
+}
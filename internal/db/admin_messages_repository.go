@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
 )
 
 type AdminMessage struct {
@@ -52,3 +54,116 @@ func (r *AdminMessagesRepository) Delete(key string) error {
 	})
 	return err
 }
+
+// SupportMessage — сообщение из переписки администратора с участником
+type SupportMessage struct {
+	ID        int64
+	UserID    int64
+	FromAdmin bool
+	Text      string
+	MessageID int
+	CreatedAt time.Time
+}
+
+// supportThreadKey — ключ в admin_messages, под которым хранится последнее сообщение
+// администратора участнику. Пока ключ есть, переписка считается открытой
+func supportThreadKey(userID int64) string {
+	return fmt.Sprintf("support_thread:%d", userID)
+}
+
+// AddSupportMessage сохраняет сообщение переписки. Сообщение администратора открывает
+// переписку: ответы участника на него будут пересылаться администратору
+func (r *AdminMessagesRepository) AddSupportMessage(userID int64, fromAdmin bool, text string, messageID int) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+			INSERT INTO support_messages (user_id, from_admin, text, message_id, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, userID, fromAdmin, text, messageID, time.Now()); err != nil {
+			return nil, err
+		}
+
+		if fromAdmin {
+			if _, err := tx.Exec(`
+				INSERT INTO admin_messages (key, chat_id, message_id) VALUES (?, ?, ?)
+				ON CONFLICT(key) DO UPDATE SET chat_id = excluded.chat_id, message_id = excluded.message_id
+			`, supportThreadKey(userID), userID, messageID); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, tx.Commit()
+	})
+	return err
+}
+
+// GetSupportThread возвращает последние limit сообщений переписки с участником в хронологическом порядке
+func (r *AdminMessagesRepository) GetSupportThread(userID int64, limit int) ([]SupportMessage, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT id, user_id, from_admin, text, message_id, created_at FROM (
+				SELECT * FROM support_messages WHERE user_id = ? ORDER BY id DESC LIMIT ?
+			) ORDER BY id
+		`, userID, limit)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var messages []SupportMessage
+		for rows.Next() {
+			var msg SupportMessage
+			if err := rows.Scan(&msg.ID, &msg.UserID, &msg.FromAdmin, &msg.Text, &msg.MessageID, &msg.CreatedAt); err != nil {
+				return nil, err
+			}
+			messages = append(messages, msg)
+		}
+		return messages, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]SupportMessage), nil
+}
+
+func (r *AdminMessagesRepository) IsSupportThreadOpen(userID int64) (bool, error) {
+	_, err := r.Get(supportThreadKey(userID))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IsSupportReply сообщает, что сообщение участника является ответом на сообщение
+// администратора из открытой переписки
+func (r *AdminMessagesRepository) IsSupportReply(userID int64, replyToMessageID int) (bool, error) {
+	open, err := r.IsSupportThreadOpen(userID)
+	if err != nil || !open {
+		return false, err
+	}
+
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var count int
+		err := db.QueryRow(`
+			SELECT COUNT(*) FROM support_messages
+			WHERE user_id = ? AND from_admin = TRUE AND message_id = ?
+		`, userID, replyToMessageID).Scan(&count)
+		return count > 0, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+func (r *AdminMessagesRepository) CloseSupportThread(userID int64) error {
+	return r.Delete(supportThreadKey(userID))
+}
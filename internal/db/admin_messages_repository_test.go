@@ -0,0 +1,87 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func setupAdminMessagesTestDB(t *testing.T) (*sql.DB, *AdminMessagesRepository, *UserRepository) {
+	db, err := sql.Open("sqlite", "file:admin_messages_test?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitSchema(db); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := NewDBQueue(db)
+	return db, NewAdminMessagesRepository(queue), NewUserRepository(queue)
+}
+
+func TestSupportThread(t *testing.T) {
+	db, repo, userRepo := setupAdminMessagesTestDB(t)
+	defer db.Close()
+
+	createTestUser(t, userRepo, 1)
+
+	open, err := repo.IsSupportThreadOpen(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open {
+		t.Fatal("Thread should be closed before the admin writes")
+	}
+
+	if err := repo.AddSupportMessage(1, true, "Как дела с шагом?", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddSupportMessage(1, false, "Не могу найти табличку", 101); err != nil {
+		t.Fatal(err)
+	}
+
+	isReply, err := repo.IsSupportReply(1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isReply {
+		t.Error("Reply to the admin message should be routed to the thread")
+	}
+
+	isReply, err = repo.IsSupportReply(1, 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isReply {
+		t.Error("Reply to the user's own message should not be routed to the thread")
+	}
+
+	thread, err := repo.GetSupportThread(1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread) != 2 || !thread[0].FromAdmin || thread[1].FromAdmin || thread[1].Text != "Не могу найти табличку" {
+		t.Errorf("Unexpected thread: %+v", thread)
+	}
+
+	latest, err := repo.GetSupportThread(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].MessageID != 101 {
+		t.Errorf("Expected only the latest message, got %+v", latest)
+	}
+
+	if err := repo.CloseSupportThread(1); err != nil {
+		t.Fatal(err)
+	}
+	isReply, err = repo.IsSupportReply(1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isReply {
+		t.Error("Replies should not be routed after the thread is closed")
+	}
+}
@@ -19,10 +19,11 @@ func (r *AdminStateRepository) Save(state *models.AdminState) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		imagesJSON, _ := json.Marshal(state.NewStepImages)
 		answersJSON, _ := json.Marshal(state.NewStepAnswers)
+		mediaTypesJSON, _ := json.Marshal(state.NewStepMediaTypes)
 
 		_, err := db.Exec(`
-			INSERT INTO admin_state (user_id, current_state, editing_step_id, new_step_text, new_step_type, new_step_images, new_step_answers, editing_setting, new_hint_text, target_user_id, new_group_chat_id, send_message_type)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			INSERT INTO admin_state (user_id, current_state, editing_step_id, new_step_text, new_step_type, new_step_images, new_step_answers, editing_setting, new_hint_text, target_user_id, new_group_chat_id, send_message_type, review_message_id, new_step_media_types)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 			ON CONFLICT(user_id) DO UPDATE SET
 				current_state = excluded.current_state,
 				editing_step_id = excluded.editing_step_id,
@@ -34,8 +35,10 @@ func (r *AdminStateRepository) Save(state *models.AdminState) error {
 				new_hint_text = excluded.new_hint_text,
 				target_user_id = excluded.target_user_id,
 				new_group_chat_id = excluded.new_group_chat_id,
-				send_message_type = excluded.send_message_type
-		`, state.UserID, state.CurrentState, state.EditingStepID, state.NewStepText, state.NewStepType, string(imagesJSON), string(answersJSON), state.EditingSetting, state.NewHintText, state.TargetUserID, state.NewGroupChatID, state.SendMessageType)
+				send_message_type = excluded.send_message_type,
+				review_message_id = excluded.review_message_id,
+				new_step_media_types = excluded.new_step_media_types
+		`, state.UserID, state.CurrentState, state.EditingStepID, state.NewStepText, state.NewStepType, string(imagesJSON), string(answersJSON), state.EditingSetting, state.NewHintText, state.TargetUserID, state.NewGroupChatID, state.SendMessageType, state.ReviewMessageID, string(mediaTypesJSON))
 		return nil, err
 	})
 	return err
@@ -44,19 +47,20 @@ func (r *AdminStateRepository) Save(state *models.AdminState) error {
 func (r *AdminStateRepository) Get(userID int64) (*models.AdminState, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		row := db.QueryRow(`
-			SELECT user_id, current_state, editing_step_id, new_step_text, new_step_type, new_step_images, new_step_answers, editing_setting, COALESCE(new_hint_text, ''), COALESCE(target_user_id, 0), COALESCE(new_group_chat_id, 0), COALESCE(send_message_type, '')
+			SELECT user_id, current_state, editing_step_id, new_step_text, new_step_type, new_step_images, new_step_answers, editing_setting, COALESCE(new_hint_text, ''), COALESCE(target_user_id, 0), COALESCE(new_group_chat_id, 0), COALESCE(send_message_type, ''), COALESCE(review_message_id, 0), COALESCE(new_step_media_types, '[]')
 			FROM admin_state WHERE user_id = ?
 		`, userID)
 
 		var state models.AdminState
-		var imagesJSON, answersJSON string
-		err := row.Scan(&state.UserID, &state.CurrentState, &state.EditingStepID, &state.NewStepText, &state.NewStepType, &imagesJSON, &answersJSON, &state.EditingSetting, &state.NewHintText, &state.TargetUserID, &state.NewGroupChatID, &state.SendMessageType)
+		var imagesJSON, answersJSON, mediaTypesJSON string
+		err := row.Scan(&state.UserID, &state.CurrentState, &state.EditingStepID, &state.NewStepText, &state.NewStepType, &imagesJSON, &answersJSON, &state.EditingSetting, &state.NewHintText, &state.TargetUserID, &state.NewGroupChatID, &state.SendMessageType, &state.ReviewMessageID, &mediaTypesJSON)
 		if err != nil {
 			return nil, err
 		}
 
 		json.Unmarshal([]byte(imagesJSON), &state.NewStepImages)
 		json.Unmarshal([]byte(answersJSON), &state.NewStepAnswers)
+		json.Unmarshal([]byte(mediaTypesJSON), &state.NewStepMediaTypes)
 
 		return &state, nil
 	})
@@ -2,8 +2,11 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 	"strings"
 	"time"
+
+	"github.com/ad/go-telegram-quest/internal/models"
 )
 
 type AnswerRepository struct {
@@ -33,7 +36,15 @@ func (r *AnswerRepository) CreateTextAnswer(userID, stepID int64, textAnswer str
 
 func (r *AnswerRepository) CreateImageAnswer(userID, stepID int64, fileIDs []string, hintUsed bool) (int64, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
-		res, err := db.Exec(`
+		// Ответ и его фото пишутся в одной транзакции: при повторе после занятости базы
+		// не остаётся ответа без фото и не появляется второй ответ
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`
 			INSERT INTO user_answers (user_id, step_id, hint_used)
 			VALUES (?, ?, ?)
 		`, userID, stepID, hintUsed)
@@ -47,7 +58,7 @@ func (r *AnswerRepository) CreateImageAnswer(userID, stepID int64, fileIDs []str
 		}
 
 		for i, fileID := range fileIDs {
-			_, err = db.Exec(`
+			_, err = tx.Exec(`
 				INSERT INTO answer_images (answer_id, file_id, position)
 				VALUES (?, ?, ?)
 			`, answerID, fileID, i)
@@ -56,7 +67,7 @@ func (r *AnswerRepository) CreateImageAnswer(userID, stepID int64, fileIDs []str
 			}
 		}
 
-		return answerID, nil
+		return answerID, tx.Commit()
 	})
 	if err != nil {
 		return 0, err
@@ -64,9 +75,18 @@ func (r *AnswerRepository) CreateImageAnswer(userID, stepID int64, fileIDs []str
 	return result.(int64), nil
 }
 
+// SetMatchRule сохраняет в ответе участника правило, по которому он был засчитан
+func (r *AnswerRepository) SetMatchRule(answerID int64, rule string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE user_answers SET match_rule = ? WHERE id = ?`, rule, answerID)
+		return nil, err
+	})
+	return err
+}
+
 func (r *AnswerRepository) GetStepAnswers(stepID int64) ([]string, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
-		rows, err := db.Query(`SELECT answer FROM step_answers WHERE step_id = ?`, stepID)
+		rows, err := db.Query(`SELECT answer FROM step_answers WHERE step_id = ? ORDER BY position, id`, stepID)
 		if err != nil {
 			return nil, err
 		}
@@ -89,16 +109,77 @@ func (r *AnswerRepository) GetStepAnswers(stepID int64) ([]string, error) {
 }
 
 func (r *AnswerRepository) AddStepAnswer(stepID int64, answer string) error {
+	return r.AddStepAnswerWithLanguage(stepID, answer, "")
+}
+
+// AddStepAnswerWithLanguage добавляет вариант ответа с кодом языка для двуязычных квестов
+func (r *AnswerRepository) AddStepAnswerWithLanguage(stepID int64, answer, language string) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		_, err := db.Exec(`
-			INSERT INTO step_answers (step_id, answer)
-			VALUES (?, ?)
-		`, stepID, strings.ToLower(strings.TrimSpace(answer)))
+			INSERT INTO step_answers (step_id, answer, position, language)
+			VALUES (?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM step_answers WHERE step_id = ?), ?)
+		`, stepID, strings.ToLower(strings.TrimSpace(answer)), stepID, language)
 		return nil, err
 	})
 	return err
 }
 
+// GetStepAnswerLanguages возвращает языки вариантов ответа шага: вариант → код языка
+func (r *AnswerRepository) GetStepAnswerLanguages(stepID int64) (map[string]string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`SELECT answer, language FROM step_answers WHERE step_id = ? AND language != ''`, stepID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		languages := make(map[string]string)
+		for rows.Next() {
+			var answer, language string
+			if err := rows.Scan(&answer, &language); err != nil {
+				return nil, err
+			}
+			languages[answer] = language
+		}
+		return languages, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]string), nil
+}
+
+// GetAnswerLanguages возвращает коды языков, которыми помечены варианты ответов неудалённых шагов
+func (r *AnswerRepository) GetAnswerLanguages() ([]string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT DISTINCT sa.language
+			FROM step_answers sa
+			JOIN steps s ON s.id = sa.step_id AND s.is_deleted = FALSE
+			WHERE sa.language != ''
+			ORDER BY sa.language
+		`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var languages []string
+		for rows.Next() {
+			var language string
+			if err := rows.Scan(&language); err != nil {
+				return nil, err
+			}
+			languages = append(languages, language)
+		}
+		return languages, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
 func (r *AnswerRepository) DeleteStepAnswer(stepID int64, answer string) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		_, err := db.Exec(`
@@ -109,6 +190,153 @@ func (r *AnswerRepository) DeleteStepAnswer(stepID int64, answer string) error {
 	return err
 }
 
+// ReorderStepAnswer перемещает вариант ответа с позиции from на позицию to (индексы с нуля)
+func (r *AnswerRepository) ReorderStepAnswer(stepID int64, from, to int) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`SELECT id FROM step_answers WHERE step_id = ? ORDER BY position, id`, stepID)
+		if err != nil {
+			return nil, err
+		}
+
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		if from < 0 || from >= len(ids) || to < 0 || to >= len(ids) {
+			return nil, fmt.Errorf("answer position out of range: from=%d to=%d count=%d", from, to, len(ids))
+		}
+		if from == to {
+			return nil, nil
+		}
+
+		moved := ids[from]
+		ids = append(ids[:from], ids[from+1:]...)
+		ids = append(ids[:to], append([]int64{moved}, ids[to:]...)...)
+
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		for position, id := range ids {
+			if _, err := tx.Exec(`UPDATE step_answers SET position = ? WHERE id = ?`, position, id); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		return nil, tx.Commit()
+	})
+	return err
+}
+
+// AddSynonyms добавляет синонимы канонической формы ответа в общий словарь. Синоним, уже
+// относящийся к другой группе, переносится в эту
+func (r *AnswerRepository) AddSynonyms(canonical string, synonyms []string) error {
+	canonical = strings.ToLower(strings.TrimSpace(canonical))
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		for _, synonym := range synonyms {
+			synonym = strings.ToLower(strings.TrimSpace(synonym))
+			if synonym == "" || synonym == canonical {
+				continue
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO answer_synonyms (synonym, canonical) VALUES (?, ?)
+				ON CONFLICT(synonym) DO UPDATE SET canonical = excluded.canonical
+			`, synonym, canonical); err != nil {
+				return nil, err
+			}
+		}
+		return nil, tx.Commit()
+	})
+	return err
+}
+
+// DeleteSynonymGroup удаляет группу синонимов, в которую входит запись id
+func (r *AnswerRepository) DeleteSynonymGroup(id int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			DELETE FROM answer_synonyms
+			WHERE canonical = (SELECT canonical FROM answer_synonyms WHERE id = ?)
+		`, id)
+		return nil, err
+	})
+	return err
+}
+
+// GetSynonymGroups возвращает словарь синонимов, сгруппированный по канонической форме
+func (r *AnswerRepository) GetSynonymGroups() ([]models.SynonymGroup, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`SELECT id, synonym, canonical FROM answer_synonyms ORDER BY canonical, id`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var groups []models.SynonymGroup
+		for rows.Next() {
+			var id int64
+			var synonym, canonical string
+			if err := rows.Scan(&id, &synonym, &canonical); err != nil {
+				return nil, err
+			}
+			if len(groups) == 0 || groups[len(groups)-1].Canonical != canonical {
+				groups = append(groups, models.SynonymGroup{ID: id, Canonical: canonical})
+			}
+			last := &groups[len(groups)-1]
+			last.Synonyms = append(last.Synonyms, synonym)
+		}
+		return groups, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]models.SynonymGroup), nil
+}
+
+// GetStepSynonyms возвращает словарь синонимов (синоним → каноническая форма) для проверки
+// ответа на шаге. Если синонимы для шага выключены, словарь пуст
+func (r *AnswerRepository) GetStepSynonyms(stepID int64) (map[string]string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT synonym, canonical FROM answer_synonyms
+			WHERE EXISTS (SELECT 1 FROM steps WHERE id = ? AND use_synonyms = TRUE)
+		`, stepID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		synonyms := make(map[string]string)
+		for rows.Next() {
+			var synonym, canonical string
+			if err := rows.Scan(&synonym, &canonical); err != nil {
+				return nil, err
+			}
+			synonyms[synonym] = canonical
+		}
+		return synonyms, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]string), nil
+}
+
 func (r *AnswerRepository) GetUserAnswerTimes(userID int64) ([]time.Time, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
@@ -198,9 +426,72 @@ func (r *AnswerRepository) GetUserAnswer(userID, stepID int64) (string, error) {
 	return result.(string), nil
 }
 
+func (r *AnswerRepository) GetUserAnswerHistory(userID int64) ([]*models.UserAnswer, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT id, user_id, step_id, COALESCE(text_answer, ''), COALESCE(hint_used, FALSE), COALESCE(match_rule, ''), created_at
+			FROM user_answers
+			WHERE user_id = ?
+			ORDER BY created_at ASC, id ASC
+		`, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		var answers []*models.UserAnswer
+		byID := make(map[int64]*models.UserAnswer)
+		for rows.Next() {
+			var answer models.UserAnswer
+			if err := rows.Scan(&answer.ID, &answer.UserID, &answer.StepID, &answer.TextAnswer, &answer.HintUsed, &answer.MatchRule, &answer.CreatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			answers = append(answers, &answer)
+			byID[answer.ID] = &answer
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		imgRows, err := db.Query(`
+			SELECT ai.id, ai.answer_id, ai.file_id, ai.position
+			FROM answer_images ai
+			JOIN user_answers ua ON ua.id = ai.answer_id
+			WHERE ua.user_id = ?
+			ORDER BY ai.answer_id, ai.position
+		`, userID)
+		if err != nil {
+			return nil, err
+		}
+		defer imgRows.Close()
+
+		for imgRows.Next() {
+			var img models.AnswerImage
+			if err := imgRows.Scan(&img.ID, &img.AnswerID, &img.FileID, &img.Position); err != nil {
+				return nil, err
+			}
+			if answer, ok := byID[img.AnswerID]; ok {
+				answer.Images = append(answer.Images, img)
+			}
+		}
+		return answers, imgRows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*models.UserAnswer), nil
+}
+
 func (r *AnswerRepository) DeleteUserAnswers(userID int64) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
-		_, err := db.Exec(`
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(`
 			DELETE FROM answer_images WHERE answer_id IN (
 				SELECT id FROM user_answers WHERE user_id = ?
 			)
@@ -209,8 +500,15 @@ func (r *AnswerRepository) DeleteUserAnswers(userID int64) error {
 			return nil, err
 		}
 
-		_, err = db.Exec(`DELETE FROM user_answers WHERE user_id = ?`, userID)
-		return nil, err
+		_, err = tx.Exec(`DELETE FROM user_answers WHERE user_id = ?`, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = tx.Exec(`DELETE FROM purged_answer_counts WHERE user_id = ?`, userID); err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit()
 	})
 	return err
 }
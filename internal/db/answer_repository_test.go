@@ -0,0 +1,174 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"modernc.org/sqlite"
+)
+
+func TestReorderStepAnswer(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite", "file:answer_reorder?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	if err := InitSchema(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := NewDBQueue(sqlDB)
+	defer queue.Close()
+	stepRepo := NewStepRepository(queue)
+	answerRepo := NewAnswerRepository(queue)
+
+	stepID := createTestStep(t, stepRepo, "Reorder answers")
+	for _, answer := range []string{"alpha", "beta", "gamma"} {
+		if err := answerRepo.AddStepAnswer(stepID, answer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := answerRepo.ReorderStepAnswer(stepID, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	answers, err := answerRepo.GetStepAnswers(stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gamma", "alpha", "beta"}; !reflect.DeepEqual(answers, want) {
+		t.Errorf("Expected %v after moving last to first, got %v", want, answers)
+	}
+
+	step, err := stepRepo.GetByID(stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gamma", "alpha", "beta"}; !reflect.DeepEqual(step.Answers, want) {
+		t.Errorf("Expected step answers %v, got %v", want, step.Answers)
+	}
+
+	if err := answerRepo.ReorderStepAnswer(stepID, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	answers, _ = answerRepo.GetStepAnswers(stepID)
+	if want := []string{"alpha", "beta", "gamma"}; !reflect.DeepEqual(answers, want) {
+		t.Errorf("Expected round-trip to restore %v, got %v", want, answers)
+	}
+
+	if err := answerRepo.AddStepAnswer(stepID, "delta"); err != nil {
+		t.Fatal(err)
+	}
+	answers, _ = answerRepo.GetStepAnswers(stepID)
+	if answers[len(answers)-1] != "delta" {
+		t.Errorf("Expected new answer to be appended last, got %v", answers)
+	}
+
+	if err := answerRepo.ReorderStepAnswer(stepID, 0, 10); err == nil {
+		t.Error("Expected error for out-of-range position")
+	}
+}
+
+// busyCodeError имитирует ошибку драйвера с кодом SQLITE_BUSY
+type busyCodeError struct{}
+
+func (busyCodeError) Error() string { return "database is locked (5) (SQLITE_BUSY)" }
+func (busyCodeError) Code() int     { return 5 }
+
+// busyOnceDriver возвращает SQLITE_BUSY при первом выполнении запроса, содержащего failOn
+type busyOnceDriver struct {
+	failOn string
+	fired  atomic.Bool
+}
+
+func (d *busyOnceDriver) Open(name string) (driver.Conn, error) {
+	conn, err := (&sqlite.Driver{}).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &busyOnceConn{Conn: conn, driver: d}, nil
+}
+
+type busyOnceConn struct {
+	driver.Conn
+	driver *busyOnceDriver
+}
+
+func (c *busyOnceConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(query)
+	if err != nil || !strings.Contains(query, c.driver.failOn) {
+		return stmt, err
+	}
+	return &busyOnceStmt{Stmt: stmt, driver: c.driver}, nil
+}
+
+type busyOnceStmt struct {
+	driver.Stmt
+	driver *busyOnceDriver
+}
+
+func (s *busyOnceStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.driver.fired.CompareAndSwap(false, true) {
+		return nil, busyCodeError{}
+	}
+	return s.Stmt.Exec(args)
+}
+
+var busyImageInsertDriver = &busyOnceDriver{failOn: "INSERT INTO answer_images"}
+
+func init() {
+	sql.Register("sqlite_busy_image_insert", busyImageInsertDriver)
+}
+
+func TestCreateImageAnswer_BusyMidTaskWritesOnce(t *testing.T) {
+	const dsn = "file:answer_busy_mid_task?mode=memory&cache=shared"
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	if err := InitSchema(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+
+	stepRepo := NewStepRepository(NewDBQueue(sqlDB))
+	stepID := createTestStep(t, stepRepo, "Busy mid task")
+	if _, err := sqlDB.Exec(`INSERT INTO users (id, first_name) VALUES (1, 'U')`); err != nil {
+		t.Fatal(err)
+	}
+
+	busyDB, err := sql.Open("sqlite_busy_image_insert", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busyDB.Close()
+	busyDB.SetMaxOpenConns(1)
+
+	queue := NewDBQueueForTest(busyDB)
+	defer queue.Close()
+	answerRepo := NewAnswerRepository(queue)
+
+	if _, err := answerRepo.CreateImageAnswer(1, stepID, []string{"f1", "f2"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if !busyImageInsertDriver.fired.Load() {
+		t.Fatal("expected the image insert to hit SQLITE_BUSY once")
+	}
+
+	var answers, images int
+	if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM user_answers WHERE user_id = 1`).Scan(&answers); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM answer_images`).Scan(&images); err != nil {
+		t.Fatal(err)
+	}
+	if answers != 1 || images != 2 {
+		t.Errorf("expected a single answer with 2 images after retry, got %d answers and %d images", answers, images)
+	}
+}
@@ -35,14 +35,16 @@ func (r *ChatStateRepository) Save(state *models.ChatState) error {
 func (r *ChatStateRepository) Get(userID int64) (*models.ChatState, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		row := db.QueryRow(`
-			SELECT user_id, last_task_message_id, last_user_answer_message_id, last_reaction_message_id, hint_message_id, current_step_hint_used, awaiting_next_step
+			SELECT user_id, last_task_message_id, last_user_answer_message_id, last_reaction_message_id, hint_message_id, current_step_hint_used, awaiting_next_step,
+				last_bot_message_id, last_bot_message_text
 			FROM user_chat_state WHERE user_id = ?
 		`, userID)
 
 		var state models.ChatState
-		var taskMsgID, answerMsgID, reactionMsgID, hintMsgID sql.NullInt64
+		var taskMsgID, answerMsgID, reactionMsgID, hintMsgID, botMsgID sql.NullInt64
 		var hintUsed, awaitingNext sql.NullBool
-		err := row.Scan(&state.UserID, &taskMsgID, &answerMsgID, &reactionMsgID, &hintMsgID, &hintUsed, &awaitingNext)
+		var botMsgText sql.NullString
+		err := row.Scan(&state.UserID, &taskMsgID, &answerMsgID, &reactionMsgID, &hintMsgID, &hintUsed, &awaitingNext, &botMsgID, &botMsgText)
 		if err != nil {
 			return nil, err
 		}
@@ -52,6 +54,8 @@ func (r *ChatStateRepository) Get(userID int64) (*models.ChatState, error) {
 		state.HintMessageID = int(hintMsgID.Int64)
 		state.CurrentStepHintUsed = hintUsed.Bool
 		state.AwaitingNextStep = awaitingNext.Bool
+		state.LastBotMessageID = int(botMsgID.Int64)
+		state.LastBotMessageText = botMsgText.String
 		return &state, nil
 	})
 	if err != nil {
@@ -167,3 +171,32 @@ func (r *ChatStateRepository) ClearAwaitingNextStep(userID int64) error {
 	})
 	return err
 }
+
+// UpdateLastBotMessage запоминает последнее текстовое сообщение бота в чате; id 0
+// сбрасывает его, например когда после него бот отправил что-то другое
+func (r *ChatStateRepository) UpdateLastBotMessage(userID int64, messageID int, text string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			INSERT INTO user_chat_state (user_id, last_bot_message_id, last_bot_message_text)
+			VALUES (?, ?, ?)
+			ON CONFLICT(user_id) DO UPDATE SET
+				last_bot_message_id = excluded.last_bot_message_id,
+				last_bot_message_text = excluded.last_bot_message_text
+		`, userID, messageID, text)
+		return nil, err
+	})
+	return err
+}
+
+// ForgetBotMessage сбрасывает последнее сообщение бота, если это messageID — например,
+// после его удаления
+func (r *ChatStateRepository) ForgetBotMessage(userID int64, messageID int) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			UPDATE user_chat_state SET last_bot_message_id = 0, last_bot_message_text = ''
+			WHERE user_id = ? AND last_bot_message_id = ?
+		`, userID, messageID)
+		return nil, err
+	})
+	return err
+}
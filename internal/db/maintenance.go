@@ -0,0 +1,195 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// OrphanCleanupResult — сколько строк каждого вида удалила CleanupOrphans
+type OrphanCleanupResult struct {
+	AnswerImages  int64
+	UserAnswers   int64
+	UserProgress  int64
+	StepRelations int64
+}
+
+func (r *OrphanCleanupResult) Total() int64 {
+	return r.AnswerImages + r.UserAnswers + r.UserProgress + r.StepRelations
+}
+
+func (r *OrphanCleanupResult) String() string {
+	return fmt.Sprintf("answer_images=%d user_answers=%d user_progress=%d step_relations=%d",
+		r.AnswerImages, r.UserAnswers, r.UserProgress, r.StepRelations)
+}
+
+// orphanStepRelationTables — таблицы с данными шага, строки которых теряют смысл без шага
+var orphanStepRelationTables = []string{"step_images", "step_answers", "step_choices", "step_documents"}
+
+// CleanupOrphans в одной транзакции удаляет строки, ссылающиеся на несуществующие записи:
+// ответы и прогресс удалённых пользователей и шагов, данные удалённых шагов и изображения
+// ответов, которых больше нет. Мягко удалённые шаги (is_deleted) сохраняются вместе с историей
+func CleanupOrphans(queue *DBQueue) (*OrphanCleanupResult, error) {
+	result, err := queue.Execute(func(db *sql.DB) (interface{}, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		exec := func(query string) (int64, error) {
+			res, err := tx.Exec(query)
+			if err != nil {
+				return 0, err
+			}
+			return res.RowsAffected()
+		}
+
+		cleanup := &OrphanCleanupResult{}
+
+		// Ответы удаляются первыми, чтобы их изображения попали в следующий запрос
+		if cleanup.UserAnswers, err = exec(`
+			DELETE FROM user_answers
+			WHERE step_id NOT IN (SELECT id FROM steps) OR user_id NOT IN (SELECT id FROM users)
+		`); err != nil {
+			return nil, err
+		}
+
+		purgedCounts, err := exec(`
+			DELETE FROM purged_answer_counts
+			WHERE step_id NOT IN (SELECT id FROM steps) OR user_id NOT IN (SELECT id FROM users)
+		`)
+		if err != nil {
+			return nil, err
+		}
+		cleanup.UserAnswers += purgedCounts
+
+		if cleanup.AnswerImages, err = exec(`
+			DELETE FROM answer_images WHERE answer_id NOT IN (SELECT id FROM user_answers)
+		`); err != nil {
+			return nil, err
+		}
+
+		if cleanup.UserProgress, err = exec(`
+			DELETE FROM user_progress
+			WHERE step_id NOT IN (SELECT id FROM steps) OR user_id NOT IN (SELECT id FROM users)
+		`); err != nil {
+			return nil, err
+		}
+
+		for _, table := range orphanStepRelationTables {
+			removed, err := exec(`DELETE FROM ` + table + ` WHERE step_id NOT IN (SELECT id FROM steps)`)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", table, err)
+			}
+			cleanup.StepRelations += removed
+		}
+
+		return cleanup, tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*OrphanCleanupResult), nil
+}
+
+// AnswerPurgeResult — сколько строк удалила PurgeOldAnswers
+type AnswerPurgeResult struct {
+	UserAnswers  int64
+	AnswerImages int64
+}
+
+func (r *AnswerPurgeResult) String() string {
+	return fmt.Sprintf("user_answers=%d answer_images=%d", r.UserAnswers, r.AnswerImages)
+}
+
+// purgeableAnswerCondition отбирает ответы старше срока хранения у участников, которые прошли
+// квест или не отвечали с тех пор. Ответы, ожидающие ручной проверки, не трогаются
+const purgeableAnswerCondition = `
+	datetime(ua.created_at) < datetime(?1)
+	AND NOT EXISTS (
+		SELECT 1 FROM user_progress review
+		WHERE review.user_id = ua.user_id AND review.step_id = ua.step_id AND review.status = 'waiting_review'
+	)
+	AND (
+		NOT EXISTS (
+			SELECT 1 FROM user_answers recent
+			WHERE recent.user_id = ua.user_id AND datetime(recent.created_at) >= datetime(?1)
+		)
+		OR NOT EXISTS (
+			SELECT 1 FROM steps s
+			WHERE s.is_active = 1 AND s.is_deleted = 0
+			AND NOT EXISTS (
+				SELECT 1 FROM user_progress done
+				WHERE done.user_id = ua.user_id AND done.step_id = s.id AND done.status IN ('approved', 'skipped')
+			)
+		)
+	)
+`
+
+// PurgeOldAnswers в одной транзакции удаляет ответы старше olderThan вместе с их изображениями
+// у участников, которые прошли квест или неактивны с olderThan. Перед удалением число ответов,
+// подсказок и время первого и последнего ответа по каждому шагу переносятся в purged_answer_counts,
+// поэтому статистика участника и условия достижений, основанные на этих счётчиках, не меняются.
+// Прогресс и выданные достижения не затрагиваются
+func PurgeOldAnswers(queue *DBQueue, olderThan time.Time) (*AnswerPurgeResult, error) {
+	cutoff := olderThan.UTC().Format("2006-01-02 15:04:05")
+
+	result, err := queue.Execute(func(db *sql.DB) (interface{}, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+			INSERT INTO purged_answer_counts (user_id, step_id, answers, hints, first_answer_at, last_answer_at)
+			SELECT ua.user_id, ua.step_id, COUNT(*),
+				COALESCE(SUM(CASE WHEN ua.hint_used = 1 THEN 1 ELSE 0 END), 0),
+				MIN(ua.created_at), MAX(ua.created_at)
+			FROM user_answers ua
+			WHERE `+purgeableAnswerCondition+`
+			GROUP BY ua.user_id, ua.step_id
+			ON CONFLICT(user_id, step_id) DO UPDATE SET
+				answers = answers + excluded.answers,
+				hints = hints + excluded.hints,
+				first_answer_at = MIN(COALESCE(first_answer_at, excluded.first_answer_at), excluded.first_answer_at),
+				last_answer_at = MAX(COALESCE(last_answer_at, excluded.last_answer_at), excluded.last_answer_at)
+		`, cutoff); err != nil {
+			return nil, fmt.Errorf("save answer counts: %w", err)
+		}
+
+		purge := &AnswerPurgeResult{}
+
+		// Изображения удаляются первыми, пока их ответы ещё на месте
+		res, err := tx.Exec(`
+			DELETE FROM answer_images WHERE answer_id IN (
+				SELECT ua.id FROM user_answers ua WHERE `+purgeableAnswerCondition+`
+			)
+		`, cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("delete answer images: %w", err)
+		}
+		if purge.AnswerImages, err = res.RowsAffected(); err != nil {
+			return nil, err
+		}
+
+		res, err = tx.Exec(`
+			DELETE FROM user_answers WHERE id IN (
+				SELECT ua.id FROM user_answers ua WHERE `+purgeableAnswerCondition+`
+			)
+		`, cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("delete answers: %w", err)
+		}
+		if purge.UserAnswers, err = res.RowsAffected(); err != nil {
+			return nil, err
+		}
+
+		return purge, tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*AnswerPurgeResult), nil
+}
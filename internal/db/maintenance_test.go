@@ -0,0 +1,179 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestCleanupOrphans(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite", "file:cleanup_orphans_test?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	if err := InitSchema(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+	queue := NewDBQueue(sqlDB)
+
+	statements := []string{
+		`INSERT INTO users (id, first_name) VALUES (1, 'Test')`,
+		`INSERT INTO steps (id, step_order, text) VALUES (10, 1, 'Valid step')`,
+		`INSERT INTO steps (id, step_order, text, is_deleted) VALUES (11, 2, 'Soft deleted step', TRUE)`,
+
+		// Валидные строки
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer) VALUES (100, 1, 10, 'valid')`,
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer) VALUES (101, 1, 11, 'history')`,
+		`INSERT INTO answer_images (answer_id, file_id) VALUES (100, 'valid_image')`,
+		`INSERT INTO user_progress (user_id, step_id, status) VALUES (1, 10, 'approved')`,
+		`INSERT INTO step_answers (step_id, answer) VALUES (10, 'ответ')`,
+
+		// Осиротевшие строки
+		`INSERT INTO answer_images (answer_id, file_id) VALUES (999, 'orphan_image')`,
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer) VALUES (102, 1, 99, 'missing step')`,
+		`INSERT INTO answer_images (answer_id, file_id) VALUES (102, 'image_of_missing_step')`,
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer) VALUES (103, 2, 10, 'missing user')`,
+		`INSERT INTO user_progress (user_id, step_id, status) VALUES (1, 99, 'pending')`,
+		`INSERT INTO step_answers (step_id, answer) VALUES (99, 'потерянный')`,
+		`INSERT INTO step_images (step_id, file_id) VALUES (99, 'orphan_step_image')`,
+	}
+	for _, stmt := range statements {
+		if _, err := sqlDB.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	result, err := CleanupOrphans(queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.UserAnswers != 2 || result.AnswerImages != 2 || result.UserProgress != 1 || result.StepRelations != 2 {
+		t.Errorf("Unexpected cleanup result: %s", result)
+	}
+	if result.Total() != 7 {
+		t.Errorf("Expected 7 removed rows, got %d", result.Total())
+	}
+
+	counts := map[string]int{
+		`SELECT COUNT(*) FROM user_answers`:                                2,
+		`SELECT COUNT(*) FROM answer_images WHERE file_id = 'valid_image'`: 1,
+		`SELECT COUNT(*) FROM answer_images`:                               1,
+		`SELECT COUNT(*) FROM user_progress`:                               1,
+		`SELECT COUNT(*) FROM step_answers`:                                1,
+		`SELECT COUNT(*) FROM step_images`:                                 0,
+		`SELECT COUNT(*) FROM user_answers WHERE step_id = 11`:             1,
+	}
+	for query, want := range counts {
+		var got int
+		if err := sqlDB.QueryRow(query).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s = %d, want %d", query, got, want)
+		}
+	}
+
+	again, err := CleanupOrphans(queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Total() != 0 {
+		t.Errorf("Expected second cleanup to remove nothing, got %s", again)
+	}
+}
+
+func TestPurgeOldAnswers(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite", "file:purge_old_answers_test?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	if err := InitSchema(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+	queue := NewDBQueue(sqlDB)
+
+	statements := []string{
+		`INSERT INTO users (id, first_name) VALUES (1, 'Finished'), (2, 'Inactive'), (3, 'Active')`,
+		`INSERT INTO steps (id, step_order, text) VALUES (10, 1, 'Первый'), (11, 2, 'Второй')`,
+
+		// Прошёл квест: старые ответы удаляются, свежий остаётся
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer, hint_used, created_at) VALUES (100, 1, 10, 'a', TRUE, datetime('now', '-60 days'))`,
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer, created_at) VALUES (101, 1, 10, 'b', datetime('now', '-50 days'))`,
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer, created_at) VALUES (102, 1, 11, 'c', datetime('now', '-1 days'))`,
+		`INSERT INTO answer_images (answer_id, file_id) VALUES (100, 'finished_image')`,
+		`INSERT INTO user_progress (user_id, step_id, status) VALUES (1, 10, 'approved'), (1, 11, 'approved')`,
+
+		// Неактивен: старый ответ удаляется, ответ на проверке остаётся
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer, created_at) VALUES (200, 2, 10, 'd', datetime('now', '-40 days'))`,
+		`INSERT INTO user_answers (id, user_id, step_id, created_at) VALUES (201, 2, 11, datetime('now', '-40 days'))`,
+		`INSERT INTO answer_images (answer_id, file_id) VALUES (201, 'review_image')`,
+		`INSERT INTO user_progress (user_id, step_id, status) VALUES (2, 10, 'approved'), (2, 11, 'waiting_review')`,
+
+		// Активен и не прошёл квест: ничего не удаляется
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer, created_at) VALUES (300, 3, 10, 'e', datetime('now', '-40 days'))`,
+		`INSERT INTO user_answers (id, user_id, step_id, text_answer, created_at) VALUES (301, 3, 11, 'f', datetime('now', '-1 days'))`,
+		`INSERT INTO user_progress (user_id, step_id, status) VALUES (3, 10, 'approved'), (3, 11, 'pending')`,
+	}
+	for _, stmt := range statements {
+		if _, err := sqlDB.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	result, err := PurgeOldAnswers(queue, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UserAnswers != 3 || result.AnswerImages != 1 {
+		t.Errorf("Unexpected purge result: %s", result)
+	}
+
+	var remaining []int64
+	rows, err := sqlDB.Query(`SELECT id FROM user_answers ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int64
+		rows.Scan(&id)
+		remaining = append(remaining, id)
+	}
+	rows.Close()
+	if fmt.Sprint(remaining) != "[102 201 300 301]" {
+		t.Errorf("Expected answers [102 201 300 301] to remain, got %v", remaining)
+	}
+
+	counts := map[string]int{
+		`SELECT COUNT(*) FROM answer_images WHERE file_id = 'review_image'`:           1,
+		`SELECT COUNT(*) FROM user_progress`:                                          6,
+		`SELECT answers FROM purged_answer_counts WHERE user_id = 1 AND step_id = 10`: 2,
+		`SELECT hints FROM purged_answer_counts WHERE user_id = 1 AND step_id = 10`:   1,
+		`SELECT answers FROM purged_answer_counts WHERE user_id = 2 AND step_id = 10`: 1,
+		`SELECT COUNT(*) FROM purged_answer_counts WHERE user_id = 3 OR step_id = 11`: 0,
+	}
+	for query, want := range counts {
+		var got int
+		if err := sqlDB.QueryRow(query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got != want {
+			t.Errorf("%s = %d, want %d", query, got, want)
+		}
+	}
+
+	again, err := PurgeOldAnswers(queue, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.UserAnswers != 0 || again.AnswerImages != 0 {
+		t.Errorf("Expected second purge to remove nothing, got %s", again)
+	}
+}
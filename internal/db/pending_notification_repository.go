@@ -0,0 +1,74 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+type PendingNotificationRepository struct {
+	queue *DBQueue
+}
+
+func NewPendingNotificationRepository(queue *DBQueue) *PendingNotificationRepository {
+	return &PendingNotificationRepository{queue: queue}
+}
+
+// Add ставит сообщение в очередь повторной отправки. Если такое сообщение уже ждёт
+// отправки, обновляется только текст последней ошибки
+func (r *PendingNotificationRepository) Add(notification *models.PendingNotification) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		return db.Exec(`
+			INSERT INTO pending_notifications (user_id, kind, payload, attempts, last_error)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(user_id, kind, payload) DO UPDATE SET last_error = excluded.last_error
+		`, notification.UserID, notification.Kind, notification.Payload, notification.Attempts, notification.LastError)
+	})
+	return err
+}
+
+// GetAll возвращает сообщения, ожидающие отправки, в порядке постановки в очередь
+func (r *PendingNotificationRepository) GetAll() ([]*models.PendingNotification, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT id, user_id, kind, payload, attempts, COALESCE(last_error, ''), created_at
+			FROM pending_notifications
+			ORDER BY id
+		`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var notifications []*models.PendingNotification
+		for rows.Next() {
+			var n models.PendingNotification
+			if err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Payload, &n.Attempts, &n.LastError, &n.CreatedAt); err != nil {
+				return nil, err
+			}
+			notifications = append(notifications, &n)
+		}
+		return notifications, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*models.PendingNotification), nil
+}
+
+// RecordFailure увеличивает число попыток отправки и запоминает ошибку
+func (r *PendingNotificationRepository) RecordFailure(id int64, lastError string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		return db.Exec(`
+			UPDATE pending_notifications SET attempts = attempts + 1, last_error = ? WHERE id = ?
+		`, lastError, id)
+	})
+	return err
+}
+
+func (r *PendingNotificationRepository) Delete(id int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		return db.Exec(`DELETE FROM pending_notifications WHERE id = ?`, id)
+	})
+	return err
+}
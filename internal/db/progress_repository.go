@@ -47,10 +47,116 @@ func (r *ProgressRepository) Update(progress *models.UserProgress) error {
 			now := time.Now()
 			progress.CompletedAt = &now
 		}
+		// Причина отклонения относится только к отклонённому ответу и сбрасывается при смене статуса
 		_, err := db.Exec(`
-			UPDATE user_progress SET status = ?, completed_at = ?
+			UPDATE user_progress SET status = ?, completed_at = ?,
+				review_reason = CASE WHEN ? = 'rejected' THEN review_reason ELSE '' END
 			WHERE user_id = ? AND step_id = ?
-		`, progress.Status, progress.CompletedAt, progress.UserID, progress.StepID)
+		`, progress.Status, progress.CompletedAt, progress.Status, progress.UserID, progress.StepID)
+		return nil, err
+	})
+	return err
+}
+
+// ApproveWaitingReview одобряет ответ, только если он всё ещё ждёт проверки. Результат false
+// означает, что ответ уже проверил кто-то другой и одобрение не выполнено
+func (r *ProgressRepository) ApproveWaitingReview(userID, stepID int64) (bool, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		res, err := db.Exec(`
+			UPDATE user_progress SET status = ?, completed_at = ?, review_reason = ''
+			WHERE user_id = ? AND step_id = ? AND status = ?
+		`, models.StatusApproved, time.Now(), userID, stepID, models.StatusWaitingReview)
+		if err != nil {
+			return false, err
+		}
+		affected, err := res.RowsAffected()
+		return affected == 1, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+// RejectWithReason отклоняет ответ участника на шаге и сохраняет причину отклонения.
+// Отклоняется только ответ, который ещё ждёт проверки: результат false означает,
+// что ответ уже проверен и ничего не изменилось
+func (r *ProgressRepository) RejectWithReason(userID, stepID int64, reason string) (bool, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		res, err := db.Exec(`
+			UPDATE user_progress SET status = ?, review_reason = ?
+			WHERE user_id = ? AND step_id = ? AND status = ?
+		`, models.StatusRejected, reason, userID, stepID, models.StatusWaitingReview)
+		if err != nil {
+			return false, err
+		}
+		affected, err := res.RowsAffected()
+		return affected == 1, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+// GetSequencePosition возвращает, сколько элементов последовательности участник уже отправил
+// верно на шаге с ответами по порядку
+func (r *ProgressRepository) GetSequencePosition(userID, stepID int64) (int, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var position int
+		err := db.QueryRow(`
+			SELECT COALESCE(sequence_position, 0) FROM user_progress WHERE user_id = ? AND step_id = ?
+		`, userID, stepID).Scan(&position)
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return position, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
+
+// SetSequencePosition сохраняет позицию участника в последовательности ответов шага
+func (r *ProgressRepository) SetSequencePosition(userID, stepID int64, position int) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			INSERT INTO user_progress (user_id, step_id, sequence_position)
+			VALUES (?, ?, ?)
+			ON CONFLICT(user_id, step_id) DO UPDATE SET sequence_position = excluded.sequence_position
+		`, userID, stepID, position)
+		return nil, err
+	})
+	return err
+}
+
+// GetLastHintAt возвращает время последнего запроса подсказки участником на шаге.
+// Нулевое время — подсказку на шаге ещё не запрашивали
+func (r *ProgressRepository) GetLastHintAt(userID, stepID int64) (time.Time, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var lastHintAt sql.NullTime
+		err := db.QueryRow(`
+			SELECT last_hint_at FROM user_progress WHERE user_id = ? AND step_id = ?
+		`, userID, stepID).Scan(&lastHintAt)
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return lastHintAt.Time, err
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return result.(time.Time), nil
+}
+
+// SetLastHintAt запоминает время запроса подсказки участником на шаге. Меняется только
+// существующая запись прогресса: подсказка не создаёт прогресс на шаге, который участник не начал
+func (r *ProgressRepository) SetLastHintAt(userID, stepID int64, at time.Time) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			UPDATE user_progress SET last_hint_at = ? WHERE user_id = ? AND step_id = ?
+		`, at.UTC(), userID, stepID)
 		return nil, err
 	})
 	return err
@@ -59,13 +165,13 @@ func (r *ProgressRepository) Update(progress *models.UserProgress) error {
 func (r *ProgressRepository) GetByUserAndStep(userID, stepID int64) (*models.UserProgress, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		row := db.QueryRow(`
-			SELECT user_id, step_id, status, completed_at
+			SELECT user_id, step_id, status, completed_at, COALESCE(review_reason, '')
 			FROM user_progress WHERE user_id = ? AND step_id = ?
 		`, userID, stepID)
 
 		var progress models.UserProgress
 		var completedAt sql.NullTime
-		err := row.Scan(&progress.UserID, &progress.StepID, &progress.Status, &completedAt)
+		err := row.Scan(&progress.UserID, &progress.StepID, &progress.Status, &completedAt, &progress.ReviewReason)
 		if err != nil {
 			return nil, err
 		}
@@ -125,6 +231,49 @@ func (r *ProgressRepository) CountByStep(stepID int64, status models.ProgressSta
 	return result.(int), nil
 }
 
+// CountInProgressUsers возвращает число участников, которые начали квест, но ещё не прошли
+// все активные шаги
+func (r *ProgressRepository) CountInProgressUsers() (int, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var count int
+		err := db.QueryRow(`
+			SELECT COUNT(DISTINCT up.user_id)
+			FROM user_progress up
+			WHERE EXISTS (
+				SELECT 1 FROM steps s
+				WHERE s.is_active = TRUE AND s.is_deleted = FALSE
+				AND NOT EXISTS (
+					SELECT 1 FROM user_progress done
+					WHERE done.user_id = up.user_id AND done.step_id = s.id
+					AND done.status IN (?, ?)
+				)
+			)
+		`, models.StatusApproved, models.StatusSkipped).Scan(&count)
+		return count, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
+
+// CountUsersOnStep возвращает число пользователей, которые сейчас решают шаг:
+// ожидают ответа, ждут проверки или получили отказ и пробуют снова
+func (r *ProgressRepository) CountUsersOnStep(stepID int64) (int, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var count int
+		err := db.QueryRow(`
+			SELECT COUNT(DISTINCT user_id) FROM user_progress
+			WHERE step_id = ? AND status IN (?, ?, ?)
+		`, stepID, models.StatusPending, models.StatusWaitingReview, models.StatusRejected).Scan(&count)
+		return count, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
+
 func (r *ProgressRepository) DeleteUserProgress(userID int64) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		_, err := db.Exec(`DELETE FROM user_progress WHERE user_id = ?`, userID)
@@ -132,3 +281,54 @@ func (r *ProgressRepository) DeleteUserProgress(userID int64) error {
 	})
 	return err
 }
+
+// DeleteStepProgress удаляет прогресс участника по одному шагу
+func (r *ProgressRepository) DeleteStepProgress(userID, stepID int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`DELETE FROM user_progress WHERE user_id = ? AND step_id = ?`, userID, stepID)
+		return nil, err
+	})
+	return err
+}
+
+// GetPendingReviews возвращает шаги в статусе ожидания проверки вместе со временем последнего ответа
+func (r *ProgressRepository) GetPendingReviews() ([]*models.PendingReview, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT up.user_id, up.step_id, ua.created_at
+			FROM user_progress up
+			JOIN user_answers ua ON ua.user_id = up.user_id AND ua.step_id = up.step_id
+			WHERE up.status = ?
+			ORDER BY up.user_id, up.step_id
+		`, models.StatusWaitingReview)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var reviews []*models.PendingReview
+		latest := make(map[[2]int64]*models.PendingReview)
+		for rows.Next() {
+			var userID, stepID int64
+			var createdAt time.Time
+			if err := rows.Scan(&userID, &stepID, &createdAt); err != nil {
+				return nil, err
+			}
+			key := [2]int64{userID, stepID}
+			if review, ok := latest[key]; ok {
+				if createdAt.After(review.SubmittedAt) {
+					review.SubmittedAt = createdAt
+				}
+				continue
+			}
+			review := &models.PendingReview{UserID: userID, StepID: stepID, SubmittedAt: createdAt}
+			latest[key] = review
+			reviews = append(reviews, review)
+		}
+		return reviews, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*models.PendingReview), nil
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ad/go-telegram-quest/internal/models"
 )
@@ -96,3 +97,202 @@ func TestCreateSkipped_WithoutExistingProgress(t *testing.T) {
 		t.Error("Expected completed_at to be set")
 	}
 }
+
+func TestCountUsersOnStep(t *testing.T) {
+	db, stepRepo := setupTestDB(t)
+	defer db.Close()
+
+	queue := NewDBQueue(db)
+	progressRepo := NewProgressRepository(queue)
+
+	step := &models.Step{
+		StepOrder:    1,
+		Text:         "Test step",
+		AnswerType:   models.AnswerTypeText,
+		HasAutoCheck: true,
+		IsActive:     true,
+	}
+	stepID, err := stepRepo.Create(step)
+	if err != nil {
+		t.Fatalf("Failed to create step: %v", err)
+	}
+
+	statuses := []models.ProgressStatus{
+		models.StatusPending,
+		models.StatusWaitingReview,
+		models.StatusRejected,
+		models.StatusApproved,
+		models.StatusSkipped,
+	}
+	for i, status := range statuses {
+		err := progressRepo.Create(&models.UserProgress{
+			UserID: int64(100 + i),
+			StepID: stepID,
+			Status: status,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create progress: %v", err)
+		}
+	}
+
+	count, err := progressRepo.CountUsersOnStep(stepID)
+	if err != nil {
+		t.Fatalf("CountUsersOnStep failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 users on step, got %d", count)
+	}
+}
+
+func TestRejectWithReason(t *testing.T) {
+	db, stepRepo := setupTestDB(t)
+	defer db.Close()
+
+	queue := NewDBQueue(db)
+	progressRepo := NewProgressRepository(queue)
+
+	stepID := createTestStep(t, stepRepo, "Review step")
+	userID := int64(777)
+
+	if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusWaitingReview}); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected, err := progressRepo.RejectWithReason(userID, stepID, "На фото не видно таблички")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rejected {
+		t.Error("Expected the waiting answer to be rejected")
+	}
+
+	progress, err := progressRepo.GetByUserAndStep(userID, stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != models.StatusRejected {
+		t.Errorf("Expected status %s, got %s", models.StatusRejected, progress.Status)
+	}
+	if progress.ReviewReason != "На фото не видно таблички" {
+		t.Errorf("Expected reason to be recorded, got %q", progress.ReviewReason)
+	}
+
+	// Уже проверенный ответ повторно не отклоняется и причина не перезаписывается
+	if rejected, _ := progressRepo.RejectWithReason(userID, stepID, "Другая причина"); rejected {
+		t.Error("Expected an already rejected answer not to be rejected again")
+	}
+	progress, _ = progressRepo.GetByUserAndStep(userID, stepID)
+	if progress.ReviewReason != "На фото не видно таблички" {
+		t.Errorf("Expected the first reason to stay, got %q", progress.ReviewReason)
+	}
+
+	// Новая попытка участника сбрасывает причину прошлого отклонения
+	if err := progressRepo.Update(&models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusWaitingReview}); err != nil {
+		t.Fatal(err)
+	}
+	progress, err = progressRepo.GetByUserAndStep(userID, stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.ReviewReason != "" {
+		t.Errorf("Expected reason to be cleared after resubmission, got %q", progress.ReviewReason)
+	}
+}
+
+func TestApproveWaitingReview(t *testing.T) {
+	db, stepRepo := setupTestDB(t)
+	defer db.Close()
+
+	queue := NewDBQueue(db)
+	progressRepo := NewProgressRepository(queue)
+
+	stepID := createTestStep(t, stepRepo, "Auto approve step")
+	if err := progressRepo.Create(&models.UserProgress{UserID: 1, StepID: stepID, Status: models.StatusWaitingReview}); err != nil {
+		t.Fatal(err)
+	}
+	// Ответ второго участника админ уже отклонил
+	if err := progressRepo.Create(&models.UserProgress{UserID: 2, StepID: stepID, Status: models.StatusRejected}); err != nil {
+		t.Fatal(err)
+	}
+
+	approved, err := progressRepo.ApproveWaitingReview(1, stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approved {
+		t.Error("Expected the waiting answer to be approved")
+	}
+	progress, err := progressRepo.GetByUserAndStep(1, stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != models.StatusApproved || progress.CompletedAt == nil {
+		t.Errorf("Expected approved status with completion time, got %s, %v", progress.Status, progress.CompletedAt)
+	}
+
+	if approved, _ := progressRepo.ApproveWaitingReview(1, stepID); approved {
+		t.Error("Expected an already approved answer not to be approved again")
+	}
+
+	approved, err = progressRepo.ApproveWaitingReview(2, stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved {
+		t.Error("Expected a rejected answer not to be approved")
+	}
+	progress, _ = progressRepo.GetByUserAndStep(2, stepID)
+	if progress.Status != models.StatusRejected {
+		t.Errorf("Expected status to stay %s, got %s", models.StatusRejected, progress.Status)
+	}
+}
+
+func TestLastHintAt(t *testing.T) {
+	db, stepRepo := setupTestDB(t)
+	defer db.Close()
+
+	queue := NewDBQueue(db)
+	progressRepo := NewProgressRepository(queue)
+
+	stepID := createTestStep(t, stepRepo, "Hint step")
+	userID := int64(888)
+
+	lastHintAt, err := progressRepo.GetLastHintAt(userID, stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lastHintAt.IsZero() {
+		t.Errorf("Expected zero time before any hint, got %v", lastHintAt)
+	}
+
+	// Без прогресса на шаге время подсказки не сохраняется и запись не создаётся
+	at := time.Date(2026, 5, 1, 12, 30, 15, 0, time.UTC)
+	if err := progressRepo.SetLastHintAt(userID, stepID, at); err != nil {
+		t.Fatal(err)
+	}
+	if progress, _ := progressRepo.GetByUserAndStep(userID, stepID); progress != nil {
+		t.Errorf("Expected no progress to be created by a hint, got %+v", progress)
+	}
+
+	if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusPending}); err != nil {
+		t.Fatal(err)
+	}
+	if err := progressRepo.SetLastHintAt(userID, stepID, at); err != nil {
+		t.Fatal(err)
+	}
+	lastHintAt, err = progressRepo.GetLastHintAt(userID, stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lastHintAt.Equal(at) {
+		t.Errorf("Expected %v, got %v", at, lastHintAt)
+	}
+
+	progress, err := progressRepo.GetByUserAndStep(userID, stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != models.StatusPending {
+		t.Errorf("Expected hint tracking to keep the step pending, got %s", progress.Status)
+	}
+}
@@ -2,9 +2,18 @@ package db
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Коды SQLite для занятой или заблокированной базы (младший байт расширенного кода)
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
 type DBTask struct {
 	Exec func(*sql.DB) (interface{}, error)
 	Resp chan DBResult
@@ -21,6 +30,7 @@ type DBQueue struct {
 	maxRetry   int
 	retryDelay time.Duration
 	testMode   bool
+	executed   atomic.Int64
 }
 
 func NewDBQueue(db *sql.DB) *DBQueue {
@@ -48,12 +58,18 @@ func NewDBQueueForTest(db *sql.DB) *DBQueue {
 }
 
 func (q *DBQueue) Execute(task func(*sql.DB) (interface{}, error)) (interface{}, error) {
+	q.executed.Add(1)
 	resp := make(chan DBResult, 1)
 	q.tasks <- DBTask{Exec: task, Resp: resp}
 	result := <-resp
 	return result.Data, result.Err
 }
 
+// ExecutedCount возвращает число задач, переданных в очередь с момента её создания
+func (q *DBQueue) ExecutedCount() int64 {
+	return q.executed.Load()
+}
+
 func (q *DBQueue) worker() {
 	for task := range q.tasks {
 		result := q.executeWithRetry(task)
@@ -61,6 +77,10 @@ func (q *DBQueue) worker() {
 	}
 }
 
+// executeWithRetry повторяет задачу только при ошибках занятости базы (SQLITE_BUSY/SQLITE_LOCKED).
+// SQLite возвращает их до применения изменений оператора, а незавершённая транзакция
+// откатывается, поэтому повтор не выполняет запись дважды. Остальные ошибки возвращаются сразу.
+// Задачи из нескольких пишущих операторов должны выполнять их в одной транзакции
 func (q *DBQueue) executeWithRetry(task DBTask) DBResult {
 	var lastErr error
 	for attempt := 0; attempt < q.maxRetry; attempt++ {
@@ -69,6 +89,9 @@ func (q *DBQueue) executeWithRetry(task DBTask) DBResult {
 			return DBResult{Data: data, Err: nil}
 		}
 		lastErr = err
+		if !IsBusyError(err) {
+			break
+		}
 		if attempt < q.maxRetry-1 { // Don't sleep after the last attempt
 			if q.testMode {
 				time.Sleep(q.retryDelay)
@@ -80,6 +103,25 @@ func (q *DBQueue) executeWithRetry(task DBTask) DBResult {
 	return DBResult{Err: lastErr}
 }
 
+// IsBusyError сообщает, что ошибка вызвана занятостью базы другим соединением,
+// и операцию можно повторить
+func IsBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		code := coded.Code() & 0xff
+		return code == sqliteBusy || code == sqliteLocked
+	}
+
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked") ||
+		strings.Contains(msg, "sqlite_busy")
+}
+
 func (q *DBQueue) Close() {
 	close(q.tasks)
 }
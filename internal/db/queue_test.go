@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"sync/atomic"
 	"testing"
 
@@ -10,6 +11,8 @@ import (
 	"pgregory.net/rapid"
 )
 
+var errSimulatedBusy = errors.New("database is locked (5) (SQLITE_BUSY)")
+
 func TestDBQueueRetry_Property(t *testing.T) {
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
@@ -29,7 +32,7 @@ func TestDBQueueRetry_Property(t *testing.T) {
 		task := func(_ *sql.DB) (interface{}, error) {
 			attempt := int(atomic.AddInt32(&attempts, 1))
 			if attempt <= failUntil {
-				return nil, errors.New("simulated failure")
+				return nil, errSimulatedBusy
 			}
 			return expectedData, nil
 		}
@@ -59,3 +62,90 @@ func TestDBQueueRetry_Property(t *testing.T) {
 		}
 	})
 }
+
+func TestDBQueue_NoRetryOnRegularError(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	queue := NewDBQueueForTest(db)
+	defer queue.Close()
+
+	var attempts int32
+	_, err = queue.Execute(func(_ *sql.DB) (interface{}, error) {
+		atomic.AddInt32(&attempts, 1)
+		return nil, errors.New("UNIQUE constraint failed")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if attempts != 1 {
+		t.Errorf("expected a regular error not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestDBQueue_BusyThenSuccessWritesOnce(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := NewDBQueueForTest(db)
+	defer queue.Close()
+
+	var attempts int32
+	_, err = queue.Execute(func(db *sql.DB) (interface{}, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`INSERT INTO items (name) VALUES ('a')`); err != nil {
+			return nil, err
+		}
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return nil, errSimulatedBusy
+		}
+		return nil, tx.Commit()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || count != 1 {
+		t.Errorf("expected 2 attempts and a single row, got %d attempts and %d rows", attempts, count)
+	}
+}
+
+func TestIsBusyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errSimulatedBusy, true},
+		{fmt.Errorf("save progress: %w", errSimulatedBusy), true},
+		{errors.New("database table is locked: users"), true},
+		{sql.ErrNoRows, false},
+		{errors.New("no such table: users"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsBusyError(tt.err); got != tt.want {
+			t.Errorf("IsBusyError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+)
+
+type ReferralRepository struct {
+	queue *DBQueue
+}
+
+func NewReferralRepository(queue *DBQueue) *ReferralRepository {
+	return &ReferralRepository{queue: queue}
+}
+
+// Create записывает, что участника referredID пригласил referrerID. У участника может быть
+// только один пригласивший: повторная запись игнорируется, а результат равен false
+func (r *ReferralRepository) Create(referrerID, referredID int64) (bool, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		res, err := db.Exec(`
+			INSERT OR IGNORE INTO referrals (referrer_id, referred_id)
+			VALUES (?, ?)
+		`, referrerID, referredID)
+		if err != nil {
+			return false, err
+		}
+		affected, err := res.RowsAffected()
+		return affected > 0, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+// GetReferrer возвращает пригласившего участника или 0, если участник пришёл без приглашения
+func (r *ReferralRepository) GetReferrer(referredID int64) (int64, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var referrerID int64
+		err := db.QueryRow(`SELECT referrer_id FROM referrals WHERE referred_id = ?`, referredID).Scan(&referrerID)
+		if err == sql.ErrNoRows {
+			return int64(0), nil
+		}
+		return referrerID, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int64), nil
+}
+
+// MarkQualified отмечает приглашение засчитанным, когда приглашённый прошёл первый шаг.
+// Возвращает пригласившего, если приглашение засчитано именно этим вызовом, иначе 0
+func (r *ReferralRepository) MarkQualified(referredID int64) (int64, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var referrerID int64
+		err := db.QueryRow(`
+			UPDATE referrals SET qualified_at = CURRENT_TIMESTAMP
+			WHERE referred_id = ? AND qualified_at IS NULL
+			RETURNING referrer_id
+		`, referredID).Scan(&referrerID)
+		if err == sql.ErrNoRows {
+			return int64(0), nil
+		}
+		return referrerID, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int64), nil
+}
+
+// CountQualified возвращает число засчитанных приглашений участника
+func (r *ReferralRepository) CountQualified(referrerID int64) (int, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var count int
+		err := db.QueryRow(`
+			SELECT COUNT(*) FROM referrals
+			WHERE referrer_id = ? AND qualified_at IS NOT NULL
+		`, referrerID).Scan(&count)
+		return count, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
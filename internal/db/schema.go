@@ -14,7 +14,12 @@ CREATE TABLE IF NOT EXISTS users (
     username TEXT,
     is_blocked BOOLEAN DEFAULT FALSE,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    new_group_chat_id INTEGER DEFAULT 0
+    new_group_chat_id INTEGER DEFAULT 0,
+    timezone TEXT DEFAULT '',
+    do_not_disturb BOOLEAN DEFAULT FALSE,
+    accepted_at DATETIME,
+    accepted_rules_version INTEGER DEFAULT 0,
+    pending_completion BOOLEAN DEFAULT FALSE
 );
 
 CREATE TABLE IF NOT EXISTS steps (
@@ -29,6 +34,25 @@ CREATE TABLE IF NOT EXISTS steps (
     hint_text TEXT DEFAULT '',
     hint_image TEXT DEFAULT '',
     is_asterisk BOOLEAN DEFAULT FALSE,
+    location_lat REAL DEFAULT 0,
+    location_lng REAL DEFAULT 0,
+    location_radius INTEGER DEFAULT 0,
+    required_answers INTEGER DEFAULT 0,
+    chapter_id INTEGER DEFAULT 0,
+    numeric_feedback BOOLEAN DEFAULT FALSE,
+    ordered_answers BOOLEAN DEFAULT FALSE,
+    use_synonyms BOOLEAN DEFAULT FALSE,
+    link_domain TEXT DEFAULT '',
+    require_reply BOOLEAN DEFAULT FALSE,
+    image_constraints TEXT DEFAULT '',
+    author_note TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS chapters (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -36,13 +60,41 @@ CREATE TABLE IF NOT EXISTS step_images (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     step_id INTEGER NOT NULL REFERENCES steps(id),
     file_id TEXT NOT NULL,
-    position INTEGER NOT NULL DEFAULT 0
+    media_type TEXT NOT NULL DEFAULT 'photo',
+    position INTEGER NOT NULL DEFAULT 0,
+    width INTEGER DEFAULT 0,
+    height INTEGER DEFAULT 0,
+    file_size INTEGER DEFAULT 0
 );
 
 CREATE TABLE IF NOT EXISTS step_answers (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     step_id INTEGER NOT NULL REFERENCES steps(id),
-    answer TEXT NOT NULL
+    answer TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    language TEXT DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS answer_synonyms (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    synonym TEXT NOT NULL UNIQUE,
+    canonical TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS step_choices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    step_id INTEGER NOT NULL REFERENCES steps(id),
+    text TEXT NOT NULL,
+    is_correct BOOLEAN DEFAULT FALSE,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS step_documents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    step_id INTEGER NOT NULL REFERENCES steps(id),
+    file_id TEXT NOT NULL,
+    file_name TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0
 );
 
 CREATE TABLE IF NOT EXISTS user_progress (
@@ -50,6 +102,9 @@ CREATE TABLE IF NOT EXISTS user_progress (
     step_id INTEGER NOT NULL REFERENCES steps(id),
     status TEXT NOT NULL DEFAULT 'pending',
     completed_at DATETIME,
+    review_reason TEXT DEFAULT '',
+    sequence_position INTEGER DEFAULT 0,
+    last_hint_at DATETIME,
     PRIMARY KEY (user_id, step_id)
 );
 
@@ -59,6 +114,7 @@ CREATE TABLE IF NOT EXISTS user_answers (
     step_id INTEGER NOT NULL REFERENCES steps(id),
     text_answer TEXT,
     hint_used BOOLEAN DEFAULT FALSE,
+    match_rule TEXT DEFAULT '',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -69,6 +125,16 @@ CREATE TABLE IF NOT EXISTS answer_images (
     position INTEGER NOT NULL DEFAULT 0
 );
 
+CREATE TABLE IF NOT EXISTS purged_answer_counts (
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    step_id INTEGER NOT NULL REFERENCES steps(id),
+    answers INTEGER NOT NULL DEFAULT 0,
+    hints INTEGER NOT NULL DEFAULT 0,
+    first_answer_at DATETIME,
+    last_answer_at DATETIME,
+    PRIMARY KEY (user_id, step_id)
+);
+
 CREATE TABLE IF NOT EXISTS user_chat_state (
     user_id INTEGER PRIMARY KEY REFERENCES users(id),
     last_task_message_id INTEGER,
@@ -76,7 +142,9 @@ CREATE TABLE IF NOT EXISTS user_chat_state (
     last_reaction_message_id INTEGER,
     hint_message_id INTEGER DEFAULT 0,
     current_step_hint_used BOOLEAN DEFAULT FALSE,
-    awaiting_next_step BOOLEAN DEFAULT FALSE
+    awaiting_next_step BOOLEAN DEFAULT FALSE,
+    last_bot_message_id INTEGER DEFAULT 0,
+    last_bot_message_text TEXT DEFAULT ''
 );
 
 CREATE TABLE IF NOT EXISTS admin_messages (
@@ -85,6 +153,15 @@ CREATE TABLE IF NOT EXISTS admin_messages (
     message_id INTEGER NOT NULL
 );
 
+CREATE TABLE IF NOT EXISTS support_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    from_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    text TEXT NOT NULL DEFAULT '',
+    message_id INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE IF NOT EXISTS settings (
     key TEXT PRIMARY KEY,
     value TEXT NOT NULL
@@ -102,7 +179,9 @@ CREATE TABLE IF NOT EXISTS admin_state (
     new_hint_text TEXT DEFAULT '',
     target_user_id INTEGER DEFAULT 0,
     new_group_chat_id INTEGER DEFAULT 0,
-    send_message_type TEXT DEFAULT ''
+    send_message_type TEXT DEFAULT '',
+    review_message_id INTEGER DEFAULT 0,
+    new_step_media_types TEXT DEFAULT '[]'
 );
 
 CREATE TABLE IF NOT EXISTS achievements (
@@ -115,7 +194,8 @@ CREATE TABLE IF NOT EXISTS achievements (
     is_unique BOOLEAN DEFAULT FALSE,
     conditions TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    is_active BOOLEAN DEFAULT TRUE
+    is_active BOOLEAN DEFAULT TRUE,
+    notify_on_award BOOLEAN DEFAULT TRUE
 );
 
 CREATE TABLE IF NOT EXISTS user_achievements (
@@ -131,9 +211,67 @@ CREATE TABLE IF NOT EXISTS user_sticker_packs (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     user_id INTEGER UNIQUE NOT NULL REFERENCES users(id),
     pack_name TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'ok',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS start_waitlist (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id),
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE IF NOT EXISTS step_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    step_id INTEGER NOT NULL DEFAULT 0,
+    text TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    is_useful BOOLEAN DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS referrals (
+    referred_id INTEGER PRIMARY KEY REFERENCES users(id),
+    referrer_id INTEGER NOT NULL REFERENCES users(id),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    qualified_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS unlock_codes (
+    code TEXT PRIMARY KEY,
+    step_id INTEGER NOT NULL DEFAULT 0,
+    chapter_id INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS user_unlocks (
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    step_id INTEGER NOT NULL DEFAULT 0,
+    chapter_id INTEGER NOT NULL DEFAULT 0,
+    unlocked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, step_id, chapter_id)
+);
+
+CREATE TABLE IF NOT EXISTS step_tags (
+    step_id INTEGER NOT NULL REFERENCES steps(id),
+    tag TEXT NOT NULL,
+    PRIMARY KEY (step_id, tag)
+);
+
+CREATE TABLE IF NOT EXISTS pending_notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, kind, payload)
+);
+
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer_id ON referrals(referrer_id);
+CREATE INDEX IF NOT EXISTS idx_step_tags_tag ON step_tags(tag);
+CREATE INDEX IF NOT EXISTS idx_support_messages_user_id ON support_messages(user_id);
 CREATE INDEX IF NOT EXISTS idx_user_sticker_packs_user_id ON user_sticker_packs(user_id);
 CREATE INDEX IF NOT EXISTS idx_user_achievements_user_id ON user_achievements(user_id);
 CREATE INDEX IF NOT EXISTS idx_user_achievements_achievement_id ON user_achievements(achievement_id);
@@ -145,6 +283,7 @@ CREATE INDEX IF NOT EXISTS idx_achievements_category ON achievements(category);
 const defaultSettings = `
 INSERT OR IGNORE INTO settings (key, value) VALUES 
     ('welcome_message', 'Добро пожаловать в квест!'),
+    ('resume_message', '👋 С возвращением! Продолжаем с того места, где вы остановились.'),
     ('final_message', 'Поздравляем! Вы прошли квест!'),
     ('correct_answer_message', '✅ Правильно!'),
     ('wrong_answer_message', '❌ Неверно, попробуйте ещё раз'),
@@ -153,7 +292,57 @@ INSERT OR IGNORE INTO settings (key, value) VALUES
     ('quest_paused_message', 'Квест временно приостановлен. Скоро мы продолжим!'),
     ('quest_completed_message', 'Квест завершён! Спасибо за участие!'),
     ('required_group_chat_id', '0'),
-    ('group_chat_invite_link', '');
+    ('group_chat_invite_link', ''),
+    ('export_mode', 'auto'),
+    ('export_file_threshold', '50'),
+    ('welcome_message_parse_mode', 'html'),
+    ('resume_message_parse_mode', 'html'),
+    ('final_message_parse_mode', 'html'),
+    ('correct_answer_message_parse_mode', 'html'),
+    ('wrong_answer_message_parse_mode', 'html'),
+    ('default_timezone', ''),
+    ('scoring_enabled', 'false'),
+    ('score_points_per_step', '10'),
+    ('score_hint_penalty', '5'),
+    ('auto_approve_minutes', '0'),
+    ('daily_digest_time', ''),
+    ('daily_digest_last_sent', ''),
+    ('last_backup_at', ''),
+    ('min_answer_length', '0'),
+    ('max_active_users', '0'),
+    ('answer_filler_words', ''),
+    ('answer_summary_enabled', 'false'),
+    ('strip_answer_quotes', 'false'),
+    ('verbose_matching', 'false'),
+    ('practice_trace', 'false'),
+    ('share_enabled', 'false'),
+    ('auto_advance', 'false'),
+    ('correct_image_delay', '0'),
+    ('step_typing_delay', '0'),
+    ('hint_cooldown', '0'),
+    ('quest_map_enabled', 'true'),
+    ('completion_confirmation', 'false'),
+    ('notify_unique_achievements', 'false'),
+    ('skip_deactivated_steps', 'true'),
+    ('progress_after_answer', 'false'),
+    ('match_rule_logging', 'false'),
+    ('group_completion_achievements', 'false'),
+    ('user_attempt_stats', 'true'),
+    ('reveal_completion_position', 'false'),
+    ('correct_answer_reaction', ''),
+    ('export_author_notes', 'false'),
+    ('group_check_fail_open', 'false'),
+    ('auto_invite_link', 'true'),
+    ('min_completion_steps', '0'),
+    ('hall_of_fame_enabled', 'false'),
+    ('hall_of_fame_message', ''),
+    ('announce_channel_id', '0'),
+    ('announce_step_interval', '0'),
+    ('share_message', '🏆 Я прошёл квест! Попробуй и ты: {link}'),
+    ('share_message_parse_mode', 'html'),
+    ('rules_message', ''),
+    ('rules_message_parse_mode', 'html'),
+    ('rules_version', '0');
 `
 
 const migrations = `
@@ -170,6 +359,42 @@ ALTER TABLE user_chat_state ADD COLUMN awaiting_next_step BOOLEAN DEFAULT FALSE;
 ALTER TABLE steps ADD COLUMN is_asterisk BOOLEAN DEFAULT FALSE;
 ALTER TABLE admin_state ADD COLUMN new_group_chat_id INTEGER DEFAULT 0;
 ALTER TABLE admin_state ADD COLUMN send_message_type TEXT DEFAULT '';
+ALTER TABLE users ADD COLUMN timezone TEXT DEFAULT '';
+ALTER TABLE step_answers ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE steps ADD COLUMN location_lat REAL DEFAULT 0;
+ALTER TABLE steps ADD COLUMN location_lng REAL DEFAULT 0;
+ALTER TABLE steps ADD COLUMN location_radius INTEGER DEFAULT 0;
+ALTER TABLE user_sticker_packs ADD COLUMN status TEXT NOT NULL DEFAULT 'ok';
+ALTER TABLE user_sticker_packs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE user_sticker_packs ADD COLUMN last_error TEXT DEFAULT '';
+ALTER TABLE steps ADD COLUMN required_answers INTEGER DEFAULT 0;
+ALTER TABLE steps ADD COLUMN chapter_id INTEGER DEFAULT 0;
+ALTER TABLE steps ADD COLUMN numeric_feedback BOOLEAN DEFAULT FALSE;
+ALTER TABLE step_images ADD COLUMN media_type TEXT NOT NULL DEFAULT 'photo';
+ALTER TABLE achievements ADD COLUMN notify_on_award BOOLEAN DEFAULT TRUE;
+ALTER TABLE user_progress ADD COLUMN review_reason TEXT DEFAULT '';
+ALTER TABLE users ADD COLUMN do_not_disturb BOOLEAN DEFAULT FALSE;
+ALTER TABLE steps ADD COLUMN ordered_answers BOOLEAN DEFAULT FALSE;
+ALTER TABLE user_progress ADD COLUMN sequence_position INTEGER DEFAULT 0;
+ALTER TABLE users ADD COLUMN accepted_at DATETIME;
+ALTER TABLE users ADD COLUMN accepted_rules_version INTEGER DEFAULT 0;
+ALTER TABLE user_progress ADD COLUMN last_hint_at DATETIME;
+ALTER TABLE steps ADD COLUMN use_synonyms BOOLEAN DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN pending_completion BOOLEAN DEFAULT FALSE;
+ALTER TABLE step_images ADD COLUMN width INTEGER DEFAULT 0;
+ALTER TABLE step_images ADD COLUMN height INTEGER DEFAULT 0;
+ALTER TABLE step_images ADD COLUMN file_size INTEGER DEFAULT 0;
+ALTER TABLE steps ADD COLUMN link_domain TEXT DEFAULT '';
+ALTER TABLE step_answers ADD COLUMN language TEXT DEFAULT '';
+ALTER TABLE step_reports ADD COLUMN is_useful BOOLEAN DEFAULT FALSE;
+ALTER TABLE steps ADD COLUMN require_reply BOOLEAN DEFAULT FALSE;
+ALTER TABLE user_chat_state ADD COLUMN last_bot_message_id INTEGER DEFAULT 0;
+ALTER TABLE user_chat_state ADD COLUMN last_bot_message_text TEXT DEFAULT '';
+ALTER TABLE steps ADD COLUMN image_constraints TEXT DEFAULT '';
+ALTER TABLE user_answers ADD COLUMN match_rule TEXT DEFAULT '';
+ALTER TABLE steps ADD COLUMN author_note TEXT DEFAULT '';
+ALTER TABLE admin_state ADD COLUMN review_message_id INTEGER DEFAULT 0;
+ALTER TABLE admin_state ADD COLUMN new_step_media_types TEXT DEFAULT '[]';
 `
 
 func InitSchema(db *sql.DB) error {
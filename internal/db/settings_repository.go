@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/ad/go-telegram-quest/internal/models"
 )
@@ -27,6 +29,31 @@ func (r *SettingsRepository) Get(key string) (string, error) {
 	return result.(string), nil
 }
 
+// GetValues возвращает все сохранённые настройки как есть, без значений по умолчанию
+func (r *SettingsRepository) GetValues() (map[string]string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`SELECT key, value FROM settings`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		values := make(map[string]string)
+		for rows.Next() {
+			var key, value string
+			if err := rows.Scan(&key, &value); err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]string), nil
+}
+
 func (r *SettingsRepository) Set(key, value string) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		_, err := db.Exec(`
@@ -46,7 +73,9 @@ func (r *SettingsRepository) GetAll() (*models.Settings, error) {
 		}
 		defer rows.Close()
 
-		settings := &models.Settings{}
+		settings := &models.Settings{
+			ParseModes: make(map[string]models.MessageParseMode),
+		}
 		for rows.Next() {
 			var key, value string
 			if err := rows.Scan(&key, &value); err != nil {
@@ -55,6 +84,8 @@ func (r *SettingsRepository) GetAll() (*models.Settings, error) {
 			switch key {
 			case "welcome_message":
 				settings.WelcomeMessage = value
+			case "resume_message":
+				settings.ResumeMessage = value
 			case "final_message":
 				settings.FinalMessage = value
 			case "correct_answer_message":
@@ -68,6 +99,31 @@ func (r *SettingsRepository) GetAll() (*models.Settings, error) {
 				}
 			case "group_chat_invite_link":
 				settings.GroupChatInviteLink = value
+			case "scoring_enabled":
+				settings.ScoringEnabled = value == "true"
+			case "score_points_per_step":
+				fmt.Sscanf(value, "%d", &settings.ScorePointsPerStep)
+			case "score_hint_penalty":
+				fmt.Sscanf(value, "%d", &settings.ScoreHintPenalty)
+			case "answer_summary_enabled":
+				settings.AnswerSummaryEnabled = value == "true"
+			case "share_enabled":
+				settings.ShareEnabled = value == "true"
+			case "share_message":
+				settings.ShareMessage = value
+			case "rules_message":
+				settings.RulesMessage = value
+			case "rules_version":
+				fmt.Sscanf(value, "%d", &settings.RulesVersion)
+			default:
+				if strings.HasSuffix(key, "_parse_mode") {
+					settings.ParseModes[strings.TrimSuffix(key, "_parse_mode")] = models.MessageParseMode(value)
+				} else if language, ok := strings.CutPrefix(key, models.CorrectAnswerMessagePrefix); ok {
+					if settings.CorrectAnswerMessages == nil {
+						settings.CorrectAnswerMessages = make(map[string]string)
+					}
+					settings.CorrectAnswerMessages[language] = value
+				}
 			}
 		}
 		return settings, rows.Err()
@@ -110,6 +166,47 @@ func (r *SettingsRepository) SetRequiredGroupChatID(chatID int64) error {
 	return r.Set("required_group_chat_id", fmt.Sprintf("%d", chatID))
 }
 
+// GetAnnounceChannelID возвращает канал для объявлений о прогрессе участников. 0 — объявления выключены
+func (r *SettingsRepository) GetAnnounceChannelID() (int64, error) {
+	value, err := r.Get("announce_channel_id")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var chatID int64
+	if _, err := fmt.Sscanf(value, "%d", &chatID); err != nil {
+		return 0, nil
+	}
+	return chatID, nil
+}
+
+func (r *SettingsRepository) SetAnnounceChannelID(chatID int64) error {
+	return r.Set("announce_channel_id", fmt.Sprintf("%d", chatID))
+}
+
+// GetAnnounceStepInterval возвращает, через сколько пройденных шагов объявлять о прогрессе
+// участника. 0 — объявлять только о прохождении квеста
+func (r *SettingsRepository) GetAnnounceStepInterval() (int, error) {
+	value, err := r.Get("announce_step_interval")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var interval int
+	if _, err := fmt.Sscanf(value, "%d", &interval); err != nil || interval < 0 {
+		return 0, nil
+	}
+	return interval, nil
+}
+
+func (r *SettingsRepository) SetAnnounceStepInterval(interval int) error {
+	return r.Set("announce_step_interval", fmt.Sprintf("%d", interval))
+}
+
 func (r *SettingsRepository) GetGroupChatInviteLink() (string, error) {
 	return r.Get("group_chat_invite_link")
 }
@@ -117,3 +214,737 @@ func (r *SettingsRepository) GetGroupChatInviteLink() (string, error) {
 func (r *SettingsRepository) SetGroupChatInviteLink(link string) error {
 	return r.Set("group_chat_invite_link", link)
 }
+
+func (r *SettingsRepository) GetExportMode() (string, error) {
+	value, err := r.Get("export_mode")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "auto", nil
+		}
+		return "", err
+	}
+	if value == "" {
+		return "auto", nil
+	}
+	return value, nil
+}
+
+func (r *SettingsRepository) SetExportMode(mode string) error {
+	return r.Set("export_mode", mode)
+}
+
+func (r *SettingsRepository) GetExportFileThreshold() (int, error) {
+	value, err := r.Get("export_file_threshold")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 50, nil
+		}
+		return 0, err
+	}
+	var threshold int
+	if _, err := fmt.Sscanf(value, "%d", &threshold); err != nil || threshold <= 0 {
+		return 50, nil
+	}
+	return threshold, nil
+}
+
+// SetExportFileThreshold задаёт, при скольких шагах автоматический экспорт ещё идёт сообщениями:
+// если шагов больше, он отправляется файлом
+func (r *SettingsRepository) SetExportFileThreshold(threshold int) error {
+	return r.Set("export_file_threshold", fmt.Sprintf("%d", threshold))
+}
+
+func (r *SettingsRepository) GetDefaultTimezone() (string, error) {
+	value, err := r.Get("default_timezone")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return value, nil
+}
+
+func (r *SettingsRepository) SetDefaultTimezone(timezone string) error {
+	return r.Set("default_timezone", timezone)
+}
+
+func (r *SettingsRepository) SetScoringEnabled(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("scoring_enabled", value)
+}
+
+// SetAnswerSummaryEnabled включает отправку разбора ответов участнику после прохождения квеста
+func (r *SettingsRepository) SetAnswerSummaryEnabled(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("answer_summary_enabled", value)
+}
+
+// SetShareEnabled включает отправку сообщения «поделиться» со ссылкой-приглашением после прохождения квеста
+func (r *SettingsRepository) SetShareEnabled(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("share_enabled", value)
+}
+
+func (r *SettingsRepository) SetScoringValues(pointsPerStep, hintPenalty int) error {
+	if err := r.Set("score_points_per_step", fmt.Sprintf("%d", pointsPerStep)); err != nil {
+		return err
+	}
+	return r.Set("score_hint_penalty", fmt.Sprintf("%d", hintPenalty))
+}
+
+// GetAutoApproveMinutes возвращает время в минутах, после которого ответ на ручной проверке
+// одобряется автоматически. 0 означает, что автоодобрение выключено
+func (r *SettingsRepository) GetAutoApproveMinutes() (int, error) {
+	value, err := r.Get("auto_approve_minutes")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var minutes int
+	if _, err := fmt.Sscanf(value, "%d", &minutes); err != nil || minutes < 0 {
+		return 0, nil
+	}
+	return minutes, nil
+}
+
+func (r *SettingsRepository) SetAutoApproveMinutes(minutes int) error {
+	return r.Set("auto_approve_minutes", fmt.Sprintf("%d", minutes))
+}
+
+// GetMinAnswerLength возвращает минимальную длину текстового ответа в символах.
+// Более короткие ответы отклоняются без попытки. 0 означает, что ограничение выключено
+func (r *SettingsRepository) GetMinAnswerLength() (int, error) {
+	value, err := r.Get("min_answer_length")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var length int
+	if _, err := fmt.Sscanf(value, "%d", &length); err != nil || length < 0 {
+		return 0, nil
+	}
+	return length, nil
+}
+
+func (r *SettingsRepository) SetMinAnswerLength(length int) error {
+	return r.Set("min_answer_length", fmt.Sprintf("%d", length))
+}
+
+// GetMinCompletionSteps возвращает, сколько одобренных шагов достаточно, чтобы квест
+// считался пройденным. 0 означает, что нужно пройти все активные шаги
+func (r *SettingsRepository) GetMinCompletionSteps() (int, error) {
+	value, err := r.Get("min_completion_steps")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var steps int
+	if _, err := fmt.Sscanf(value, "%d", &steps); err != nil || steps < 0 {
+		return 0, nil
+	}
+	return steps, nil
+}
+
+func (r *SettingsRepository) SetMinCompletionSteps(steps int) error {
+	return r.Set("min_completion_steps", fmt.Sprintf("%d", steps))
+}
+
+// GetMaxActiveUsers возвращает, сколько участников могут одновременно проходить квест.
+// Остальные попадают в лист ожидания. 0 означает, что ограничения нет
+func (r *SettingsRepository) GetMaxActiveUsers() (int, error) {
+	value, err := r.Get("max_active_users")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var limit int
+	if _, err := fmt.Sscanf(value, "%d", &limit); err != nil || limit < 0 {
+		return 0, nil
+	}
+	return limit, nil
+}
+
+func (r *SettingsRepository) SetMaxActiveUsers(limit int) error {
+	return r.Set("max_active_users", fmt.Sprintf("%d", limit))
+}
+
+// GetAnswerFillerWords возвращает слова-паразиты, которые отбрасываются в начале текстового
+// ответа перед сравнением с вариантами. Пустой список означает, что нормализация выключена
+func (r *SettingsRepository) GetAnswerFillerWords() ([]string, error) {
+	value, err := r.Get("answer_filler_words")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var words []string
+	for _, word := range strings.Split(value, ",") {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words, nil
+}
+
+func (r *SettingsRepository) SetAnswerFillerWords(words []string) error {
+	return r.Set("answer_filler_words", strings.Join(words, ","))
+}
+
+// GetStripAnswerQuotes сообщает, нужно ли снимать с текстового ответа парные кавычки и скобки
+// вокруг всего ответа перед сравнением с вариантами
+func (r *SettingsRepository) GetStripAnswerQuotes() (bool, error) {
+	value, err := r.Get("strip_answer_quotes")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetStripAnswerQuotes(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("strip_answer_quotes", value)
+}
+
+// GetVerboseMatching сообщает, показывать ли администратору разбор проверки его собственных
+// текстовых ответов и ответов в инструменте проверки
+func (r *SettingsRepository) GetVerboseMatching() (bool, error) {
+	value, err := r.Get("verbose_matching")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetVerboseMatching(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("verbose_matching", value)
+}
+
+// GetPracticeTrace сообщает, показывать ли в инструменте проверки ответа введённый ответ
+// с разбором сравнения независимо от подробной проверки. По умолчанию выключено
+func (r *SettingsRepository) GetPracticeTrace() (bool, error) {
+	value, err := r.Get("practice_trace")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetPracticeTrace(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("practice_trace", value)
+}
+
+// GetQuestMapEnabled сообщает, доступна ли участникам команда /map. По умолчанию карта включена
+func (r *SettingsRepository) GetQuestMapEnabled() (bool, error) {
+	value, err := r.Get("quest_map_enabled")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		return false, err
+	}
+	return value != "false", nil
+}
+
+func (r *SettingsRepository) SetQuestMapEnabled(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("quest_map_enabled", value)
+}
+
+// GetUserAttemptStats сообщает, показывать ли сводку попыток в карточке участника.
+// По умолчанию включено
+func (r *SettingsRepository) GetUserAttemptStats() (bool, error) {
+	value, err := r.Get("user_attempt_stats")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		return false, err
+	}
+	return value != "false", nil
+}
+
+func (r *SettingsRepository) SetUserAttemptStats(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("user_attempt_stats", value)
+}
+
+// GetSkipDeactivatedSteps сообщает, нужно ли переводить участника дальше, если админ отключил
+// шаг, на котором он остановился. По умолчанию включено
+func (r *SettingsRepository) GetSkipDeactivatedSteps() (bool, error) {
+	value, err := r.Get("skip_deactivated_steps")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		return false, err
+	}
+	return value != "false", nil
+}
+
+func (r *SettingsRepository) SetSkipDeactivatedSteps(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("skip_deactivated_steps", value)
+}
+
+// GetProgressAfterAnswer сообщает, нужно ли показывать прогресс-бар в сообщении
+// о правильном ответе. По умолчанию выключено
+func (r *SettingsRepository) GetProgressAfterAnswer() (bool, error) {
+	value, err := r.Get("progress_after_answer")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetProgressAfterAnswer(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("progress_after_answer", value)
+}
+
+// GetMatchRuleLogging сообщает, нужно ли сохранять в ответах участников правило,
+// по которому ответ был засчитан. По умолчанию выключено
+func (r *SettingsRepository) GetMatchRuleLogging() (bool, error) {
+	value, err := r.Get("match_rule_logging")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetMatchRuleLogging(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("match_rule_logging", value)
+}
+
+// GetCorrectAnswerReaction возвращает эмодзи, которым бот отмечает верный ответ участника.
+// Пустая строка — реакция выключена
+func (r *SettingsRepository) GetCorrectAnswerReaction() (string, error) {
+	value, err := r.Get("correct_answer_reaction")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return value, nil
+}
+
+func (r *SettingsRepository) SetCorrectAnswerReaction(emoji string) error {
+	return r.Set("correct_answer_reaction", emoji)
+}
+
+// GetGroupCheckFailOpen сообщает, пускать ли участников без проверки членства в группе, когда
+// бот лишился прав в группе ограничения участия. По умолчанию выключено
+func (r *SettingsRepository) GetGroupCheckFailOpen() (bool, error) {
+	value, err := r.Get("group_check_fail_open")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetGroupCheckFailOpen(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("group_check_fail_open", value)
+}
+
+// GetExportAuthorNotes сообщает, включать ли заметки авторов шагов в экспорт заданий
+// и конфигурации квеста. По умолчанию выключено
+func (r *SettingsRepository) GetExportAuthorNotes() (bool, error) {
+	value, err := r.Get("export_author_notes")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetExportAuthorNotes(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("export_author_notes", value)
+}
+
+// GetRevealCompletionPosition сообщает, нужно ли сообщать участнику его место в порядке
+// прохождения квеста. По умолчанию выключено
+func (r *SettingsRepository) GetRevealCompletionPosition() (bool, error) {
+	value, err := r.Get("reveal_completion_position")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetRevealCompletionPosition(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("reveal_completion_position", value)
+}
+
+// GetGroupCompletionAchievements сообщает, нужно ли собирать достижения за прохождение
+// квеста в финальное сообщение вместо отдельных уведомлений. По умолчанию выключено
+func (r *SettingsRepository) GetGroupCompletionAchievements() (bool, error) {
+	value, err := r.Get("group_completion_achievements")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetGroupCompletionAchievements(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("group_completion_achievements", value)
+}
+
+// GetNotifyUniqueAchievements сообщает, нужно ли уведомлять администратора о получении
+// уникальных достижений. По умолчанию уведомления выключены
+func (r *SettingsRepository) GetNotifyUniqueAchievements() (bool, error) {
+	value, err := r.Get("notify_unique_achievements")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetNotifyUniqueAchievements(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("notify_unique_achievements", value)
+}
+
+// GetCompletionConfirmation сообщает, нужно ли после последнего шага подтвердить
+// завершение квеста кнопкой. По умолчанию квест завершается сразу
+func (r *SettingsRepository) GetCompletionConfirmation() (bool, error) {
+	value, err := r.Get("completion_confirmation")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetCompletionConfirmation(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("completion_confirmation", value)
+}
+
+// GetAutoAdvance сообщает, выдавать ли следующий шаг сразу после правильного ответа.
+// По умолчанию участник переходит к следующему шагу кнопкой «Следующий вопрос»
+func (r *SettingsRepository) GetAutoAdvance() (bool, error) {
+	value, err := r.Get("auto_advance")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetAutoAdvance(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("auto_advance", value)
+}
+
+// GetCorrectImageDelay возвращает задержку в секундах, через которую картинка правильного ответа
+// отправляется отдельным сообщением после текста. 0 — картинка идёт вместе с текстом
+func (r *SettingsRepository) GetCorrectImageDelay() (int, error) {
+	value, err := r.Get("correct_image_delay")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var seconds int
+	if _, err := fmt.Sscanf(value, "%d", &seconds); err != nil || seconds < 0 {
+		return 0, nil
+	}
+	return seconds, nil
+}
+
+func (r *SettingsRepository) SetCorrectImageDelay(seconds int) error {
+	return r.Set("correct_image_delay", fmt.Sprintf("%d", seconds))
+}
+
+// GetStepTypingDelay возвращает, сколько секунд участник видит «печатает…» перед очередным шагом.
+// 0 — шаг отправляется сразу
+func (r *SettingsRepository) GetStepTypingDelay() (int, error) {
+	value, err := r.Get("step_typing_delay")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var seconds int
+	if _, err := fmt.Sscanf(value, "%d", &seconds); err != nil || seconds < 0 {
+		return 0, nil
+	}
+	return seconds, nil
+}
+
+func (r *SettingsRepository) SetStepTypingDelay(seconds int) error {
+	return r.Set("step_typing_delay", fmt.Sprintf("%d", seconds))
+}
+
+// GetHintCooldown возвращает паузу в секундах между повторными запросами подсказки на одном шаге.
+// 0 — без паузы
+func (r *SettingsRepository) GetHintCooldown() (int, error) {
+	value, err := r.Get("hint_cooldown")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var seconds int
+	if _, err := fmt.Sscanf(value, "%d", &seconds); err != nil || seconds < 0 {
+		return 0, nil
+	}
+	return seconds, nil
+}
+
+func (r *SettingsRepository) SetHintCooldown(seconds int) error {
+	return r.Set("hint_cooldown", fmt.Sprintf("%d", seconds))
+}
+
+// GetDailyDigestTime возвращает время отправки ежедневной сводки (ЧЧ:ММ). Пустая строка — сводка выключена
+func (r *SettingsRepository) GetDailyDigestTime() (string, error) {
+	value, err := r.Get("daily_digest_time")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return value, nil
+}
+
+func (r *SettingsRepository) SetDailyDigestTime(value string) error {
+	return r.Set("daily_digest_time", value)
+}
+
+func (r *SettingsRepository) GetDailyDigestLastSent() (string, error) {
+	value, err := r.Get("daily_digest_last_sent")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return value, nil
+}
+
+func (r *SettingsRepository) SetDailyDigestLastSent(day string) error {
+	return r.Set("daily_digest_last_sent", day)
+}
+
+// GetLastBackupAt возвращает время последнего бэкапа базы или нулевое время, если бэкапов не было
+func (r *SettingsRepository) GetLastBackupAt() (time.Time, error) {
+	value, err := r.Get("last_backup_at")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func (r *SettingsRepository) SetLastBackupAt(at time.Time) error {
+	return r.Set("last_backup_at", at.Format(time.RFC3339))
+}
+
+// SetRulesMessage сохраняет текст правил квеста и увеличивает их версию, чтобы участники,
+// принявшие прежнюю редакцию, приняли правила заново. Пустой текст отключает правила
+func (r *SettingsRepository) SetRulesMessage(value string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+			INSERT INTO settings (key, value) VALUES ('rules_message', ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value
+		`, value); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO settings (key, value) VALUES ('rules_version', '1')
+			ON CONFLICT(key) DO UPDATE SET value = CAST(CAST(value AS INTEGER) + 1 AS TEXT)
+		`); err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit()
+	})
+	return err
+}
+
+func (r *SettingsRepository) SetMessageParseMode(key string, mode models.MessageParseMode) error {
+	return r.Set(key+"_parse_mode", string(mode))
+}
+
+// GetAutoInviteLink сообщает, нужно ли при включении ограничения участия создавать
+// ссылку-приглашение в группу через Telegram API. По умолчанию включено
+func (r *SettingsRepository) GetAutoInviteLink() (bool, error) {
+	value, err := r.Get("auto_invite_link")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		return false, err
+	}
+	return value != "false", nil
+}
+
+func (r *SettingsRepository) SetAutoInviteLink(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("auto_invite_link", value)
+}
+
+// GetHallOfFameEnabled сообщает, нужно ли вести закреплённый «Зал славы» в группе участников.
+// По умолчанию выключен
+func (r *SettingsRepository) GetHallOfFameEnabled() (bool, error) {
+	value, err := r.Get("hall_of_fame_enabled")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+func (r *SettingsRepository) SetHallOfFameEnabled(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	return r.Set("hall_of_fame_enabled", value)
+}
+
+// GetHallOfFameMessage возвращает чат и ID закреплённого сообщения «Зала славы». 0 — сообщение
+// ещё не отправлено
+func (r *SettingsRepository) GetHallOfFameMessage() (int64, int, error) {
+	value, err := r.Get("hall_of_fame_message")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	var chatID int64
+	var messageID int
+	if _, err := fmt.Sscanf(value, "%d:%d", &chatID, &messageID); err != nil {
+		return 0, 0, nil
+	}
+	return chatID, messageID, nil
+}
+
+func (r *SettingsRepository) SetHallOfFameMessage(chatID int64, messageID int) error {
+	return r.Set("hall_of_fame_message", fmt.Sprintf("%d:%d", chatID, messageID))
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"testing"
 
+	"github.com/ad/go-telegram-quest/internal/models"
 	_ "modernc.org/sqlite"
 )
 
@@ -35,3 +36,174 @@ func TestNewSettingsInitialization(t *testing.T) {
 		t.Errorf("Expected group_chat_invite_link to be empty, got '%s'", value)
 	}
 }
+
+func TestExportSettingsDefaults(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite", "file::memory:?cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	if err := InitSchema(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := NewDBQueue(sqlDB)
+	defer queue.Close()
+	repo := NewSettingsRepository(queue)
+
+	mode, err := repo.GetExportMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != "auto" {
+		t.Errorf("Expected default export mode 'auto', got '%s'", mode)
+	}
+
+	threshold, err := repo.GetExportFileThreshold()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold != 50 {
+		t.Errorf("Expected default export threshold 50, got %d", threshold)
+	}
+
+	if err := repo.SetExportMode("file"); err != nil {
+		t.Fatal(err)
+	}
+	mode, _ = repo.GetExportMode()
+	if mode != "file" {
+		t.Errorf("Expected export mode 'file', got '%s'", mode)
+	}
+
+	if err := repo.SetExportFileThreshold(20); err != nil {
+		t.Fatal(err)
+	}
+	threshold, _ = repo.GetExportFileThreshold()
+	if threshold != 20 {
+		t.Errorf("Expected export threshold 20, got %d", threshold)
+	}
+}
+
+func TestMessageParseModes(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite", "file::memory:?cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	if err := InitSchema(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := NewDBQueue(sqlDB)
+	defer queue.Close()
+	repo := NewSettingsRepository(queue)
+
+	settings, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := settings.ParseModeFor("welcome_message"); mode != models.MessageParseModeHTML {
+		t.Errorf("Expected default parse mode html, got %s", mode)
+	}
+
+	if err := repo.SetMessageParseMode("welcome_message", models.MessageParseModePlain); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err = repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := settings.ParseModeFor("welcome_message"); mode != models.MessageParseModePlain {
+		t.Errorf("Expected parse mode plain, got %s", mode)
+	}
+	if mode := settings.ParseModeFor("final_message"); mode != models.MessageParseModeHTML {
+		t.Errorf("Expected final_message to keep html parse mode, got %s", mode)
+	}
+}
+
+func TestScoringSettings(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite", "file::memory:?cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	if err := InitSchema(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := NewDBQueue(sqlDB)
+	defer queue.Close()
+	repo := NewSettingsRepository(queue)
+
+	settings, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.ScoringEnabled {
+		t.Error("Expected scoring to be disabled by default")
+	}
+	if settings.ScorePointsPerStep != 10 || settings.ScoreHintPenalty != 5 {
+		t.Errorf("Expected default scoring 10/5, got %d/%d", settings.ScorePointsPerStep, settings.ScoreHintPenalty)
+	}
+
+	if err := repo.SetScoringEnabled(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetScoringValues(20, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err = repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !settings.ScoringEnabled || settings.ScorePointsPerStep != 20 || settings.ScoreHintPenalty != 8 {
+		t.Errorf("Expected enabled scoring 20/8, got %v %d/%d", settings.ScoringEnabled, settings.ScorePointsPerStep, settings.ScoreHintPenalty)
+	}
+
+	repo.SetScoringEnabled(false)
+	repo.SetScoringValues(10, 5)
+}
+
+func TestRulesMessageVersioning(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite", "file::memory:?cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	if err := InitSchema(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := NewDBQueue(sqlDB)
+	defer queue.Close()
+	repo := NewSettingsRepository(queue)
+	defer repo.Set("rules_version", "0")
+	defer repo.Set("rules_message", "")
+
+	settings, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.RulesMessage != "" || settings.RulesVersion != 0 {
+		t.Errorf("Expected rules to be disabled by default, got %q v%d", settings.RulesMessage, settings.RulesVersion)
+	}
+
+	for want, text := range []string{"Не шуметь", "Не шуметь и не спорить"} {
+		if err := repo.SetRulesMessage(text); err != nil {
+			t.Fatal(err)
+		}
+		settings, err = repo.GetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if settings.RulesMessage != text || settings.RulesVersion != want+1 {
+			t.Errorf("Expected %q v%d, got %q v%d", text, want+1, settings.RulesMessage, settings.RulesVersion)
+		}
+	}
+}
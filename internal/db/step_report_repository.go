@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+type StepReportRepository struct {
+	queue *DBQueue
+}
+
+func NewStepReportRepository(queue *DBQueue) *StepReportRepository {
+	return &StepReportRepository{queue: queue}
+}
+
+// Create сохраняет жалобу пользователя. stepID равен 0, если у пользователя нет текущего шага
+func (r *StepReportRepository) Create(userID, stepID int64, text string) (int64, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		res, err := db.Exec(`
+			INSERT INTO step_reports (user_id, step_id, text)
+			VALUES (?, ?, ?)
+		`, userID, stepID, text)
+		if err != nil {
+			return nil, err
+		}
+		return res.LastInsertId()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int64), nil
+}
+
+// GetRecent возвращает последние жалобы, новые первыми
+func (r *StepReportRepository) GetRecent(limit int) ([]*models.StepReport, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT sr.id, sr.user_id, sr.step_id, COALESCE(s.step_order, 0), sr.text, sr.created_at, COALESCE(sr.is_useful, FALSE)
+			FROM step_reports sr
+			LEFT JOIN steps s ON sr.step_id = s.id
+			ORDER BY sr.created_at DESC, sr.id DESC
+			LIMIT ?
+		`, limit)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var reports []*models.StepReport
+		for rows.Next() {
+			var report models.StepReport
+			if err := rows.Scan(&report.ID, &report.UserID, &report.StepID, &report.StepOrder, &report.Text, &report.CreatedAt, &report.IsUseful); err != nil {
+				return nil, err
+			}
+			reports = append(reports, &report)
+		}
+		return reports, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*models.StepReport), nil
+}
+
+// MarkUseful отмечает жалобу полезной и возвращает её автора. changed равен false,
+// если жалоба уже была отмечена раньше
+func (r *StepReportRepository) MarkUseful(id int64) (userID int64, changed bool, err error) {
+	type marked struct {
+		userID  int64
+		changed bool
+	}
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var m marked
+		if err := db.QueryRow(`SELECT user_id FROM step_reports WHERE id = ?`, id).Scan(&m.userID); err != nil {
+			return nil, err
+		}
+		res, err := db.Exec(`UPDATE step_reports SET is_useful = TRUE WHERE id = ? AND COALESCE(is_useful, FALSE) = FALSE`, id)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		m.changed = affected > 0
+		return m, nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	m := result.(marked)
+	return m.userID, m.changed, nil
+}
+
+// CountUseful возвращает число жалоб участника, отмеченных полезными
+func (r *StepReportRepository) CountUseful(userID int64) (int, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var count int
+		err := db.QueryRow(`
+			SELECT COUNT(*) FROM step_reports
+			WHERE user_id = ? AND is_useful = TRUE
+		`, userID).Scan(&count)
+		return count, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
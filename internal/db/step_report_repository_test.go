@@ -0,0 +1,76 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+func TestStepReportRepository_CreateAndGetRecent(t *testing.T) {
+	db, stepRepo := setupTestDB(t)
+	defer db.Close()
+
+	queue := NewDBQueue(db)
+	reportRepo := NewStepReportRepository(queue)
+
+	step := &models.Step{
+		StepOrder:  1,
+		Text:       "Test step",
+		AnswerType: models.AnswerTypeText,
+		IsActive:   true,
+	}
+	stepID, err := stepRepo.Create(step)
+	if err != nil {
+		t.Fatalf("Failed to create step: %v", err)
+	}
+
+	if _, err := reportRepo.Create(100, stepID, "картинка не открывается"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := reportRepo.Create(101, 0, "бот молчит"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	reports, err := reportRepo.GetRecent(10)
+	if err != nil {
+		t.Fatalf("GetRecent failed: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %d", len(reports))
+	}
+
+	if reports[0].UserID != 101 || reports[0].StepID != 0 || reports[0].StepOrder != 0 {
+		t.Errorf("Expected newest report without step first, got %+v", reports[0])
+	}
+	if reports[1].UserID != 100 || reports[1].StepID != stepID || reports[1].StepOrder != 1 || reports[1].Text != "картинка не открывается" {
+		t.Errorf("Unexpected step report: %+v", reports[1])
+	}
+
+	if reports[0].IsUseful || reports[1].IsUseful {
+		t.Errorf("Expected new reports not to be marked useful")
+	}
+	if userID, changed, err := reportRepo.MarkUseful(reports[1].ID); err != nil || userID != 100 || !changed {
+		t.Fatalf("MarkUseful = %d, %v, %v; want author 100 and a change", userID, changed, err)
+	}
+	reports, err = reportRepo.GetRecent(10)
+	if err != nil {
+		t.Fatalf("GetRecent failed: %v", err)
+	}
+	if reports[0].IsUseful || !reports[1].IsUseful {
+		t.Errorf("Expected only the marked report to be useful, got %+v", reports)
+	}
+	if count, err := reportRepo.CountUseful(100); err != nil || count != 1 {
+		t.Errorf("CountUseful(100) = %d, %v; want 1", count, err)
+	}
+	if count, err := reportRepo.CountUseful(101); err != nil || count != 0 {
+		t.Errorf("CountUseful(101) = %d, %v; want 0", count, err)
+	}
+
+	limited, err := reportRepo.GetRecent(1)
+	if err != nil {
+		t.Fatalf("GetRecent failed: %v", err)
+	}
+	if len(limited) != 1 {
+		t.Errorf("Expected limit to be applied, got %d reports", len(limited))
+	}
+}
@@ -62,7 +62,7 @@ func (r *StepRepository) SoftDelete(id int64) error {
 func (r *StepRepository) GetActive() ([]*models.Step, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
-			SELECT id, step_order, text, answer_type, has_auto_check, is_active, is_deleted, is_asterisk, correct_answer_image, hint_text, hint_image, created_at
+			SELECT id, step_order, text, answer_type, has_auto_check, is_active, is_deleted, is_asterisk, correct_answer_image, hint_text, hint_image, location_lat, location_lng, location_radius, required_answers, chapter_id, numeric_feedback, ordered_answers, use_synonyms, link_domain, require_reply, image_constraints, created_at
 			FROM steps
 			WHERE is_active = TRUE AND is_deleted = FALSE
 			ORDER BY step_order
@@ -82,7 +82,7 @@ func (r *StepRepository) GetActive() ([]*models.Step, error) {
 func (r *StepRepository) GetAll() ([]*models.Step, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
-			SELECT id, step_order, text, answer_type, has_auto_check, is_active, is_deleted, is_asterisk, correct_answer_image, hint_text, hint_image, created_at
+			SELECT id, step_order, text, answer_type, has_auto_check, is_active, is_deleted, is_asterisk, correct_answer_image, hint_text, hint_image, location_lat, location_lng, location_radius, required_answers, chapter_id, numeric_feedback, ordered_answers, use_synonyms, link_domain, require_reply, image_constraints, created_at
 			FROM steps
 			WHERE is_deleted = FALSE
 			ORDER BY step_order
@@ -163,6 +163,135 @@ func (r *StepRepository) UpdateHint(id int64, hintText, hintImage string) error
 	return err
 }
 
+func (r *StepRepository) UpdateLocationTarget(id int64, lat, lng float64, radius int) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET location_lat = ?, location_lng = ?, location_radius = ? WHERE id = ?`, lat, lng, radius, id)
+		return nil, err
+	})
+	return err
+}
+
+func (r *StepRepository) SetRequiredAnswers(id int64, required int) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET required_answers = ? WHERE id = ?`, required, id)
+		return nil, err
+	})
+	return err
+}
+
+// SetNumericFeedback включает подсказку «больше/меньше» при неверном числовом ответе
+func (r *StepRepository) SetNumericFeedback(id int64, enabled bool) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET numeric_feedback = ? WHERE id = ?`, enabled, id)
+		return nil, err
+	})
+	return err
+}
+
+// UpdateAnswerType меняет тип ответа шага. Прогресс и ответы участников не затрагиваются
+func (r *StepRepository) UpdateAnswerType(id int64, answerType models.AnswerType) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET answer_type = ? WHERE id = ?`, answerType, id)
+		return nil, err
+	})
+	return err
+}
+
+// SetOrderedAnswers включает режим последовательности: варианты ответа нужно отправить
+// по одному в заданном порядке
+func (r *StepRepository) SetOrderedAnswers(id int64, enabled bool) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET ordered_answers = ? WHERE id = ?`, enabled, id)
+		return nil, err
+	})
+	return err
+}
+
+// SetUseSynonyms включает для шага проверку ответа с учётом общего словаря синонимов
+func (r *StepRepository) SetUseSynonyms(id int64, enabled bool) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET use_synonyms = ? WHERE id = ?`, enabled, id)
+		return nil, err
+	})
+	return err
+}
+
+// UpdateLinkDomain задаёт домен, на который должна вести ссылка в ответе шага
+func (r *StepRepository) UpdateLinkDomain(id int64, domain string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET link_domain = ? WHERE id = ?`, domain, id)
+		return nil, err
+	})
+	return err
+}
+
+// UpdateImageConstraints задаёт ограничения на разрешение фото в ответе на шаг
+func (r *StepRepository) UpdateImageConstraints(id int64, constraints string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET image_constraints = ? WHERE id = ?`, constraints, id)
+		return nil, err
+	})
+	return err
+}
+
+// SetRequireReply включает для шага требование отвечать реплаем на сообщение с заданием
+func (r *StepRepository) SetRequireReply(id int64, enabled bool) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET require_reply = ? WHERE id = ?`, enabled, id)
+		return nil, err
+	})
+	return err
+}
+
+// SetAuthorNote сохраняет внутреннюю заметку автора к шагу. Заметка не входит в models.Step
+// и видна только в админке; пустая строка удаляет заметку
+func (r *StepRepository) SetAuthorNote(id int64, note string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET author_note = ? WHERE id = ?`, note, id)
+		return nil, err
+	})
+	return err
+}
+
+// GetAuthorNote возвращает заметку автора к шагу или пустую строку, если её нет
+func (r *StepRepository) GetAuthorNote(id int64) (string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var note sql.NullString
+		err := db.QueryRow(`SELECT author_note FROM steps WHERE id = ?`, id).Scan(&note)
+		return note.String, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.(string), nil
+}
+
+// GetAuthorNotes возвращает заметки авторов всех шагов, у которых они есть: ID шага → заметка
+func (r *StepRepository) GetAuthorNotes() (map[int64]string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`SELECT id, author_note FROM steps WHERE author_note != ''`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		notes := make(map[int64]string)
+		for rows.Next() {
+			var id int64
+			var note string
+			if err := rows.Scan(&id, &note); err != nil {
+				return nil, err
+			}
+			notes[id] = note
+		}
+		return notes, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[int64]string), nil
+}
+
 func (r *StepRepository) ClearHint(id int64) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		_, err := db.Exec(`UPDATE steps SET hint_text = '', hint_image = '' WHERE id = ?`, id)
@@ -190,7 +319,7 @@ func (r *StepRepository) DeleteAnswers(stepID int64) error {
 func (r *StepRepository) GetByID(id int64) (*models.Step, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		row := db.QueryRow(`
-			SELECT id, step_order, text, answer_type, has_auto_check, is_active, is_deleted, is_asterisk, correct_answer_image, hint_text, hint_image, created_at
+			SELECT id, step_order, text, answer_type, has_auto_check, is_active, is_deleted, is_asterisk, correct_answer_image, hint_text, hint_image, location_lat, location_lng, location_radius, required_answers, chapter_id, numeric_feedback, ordered_answers, use_synonyms, link_domain, require_reply, image_constraints, created_at
 			FROM steps WHERE id = ?
 		`, id)
 
@@ -209,7 +338,7 @@ func (r *StepRepository) GetByID(id int64) (*models.Step, error) {
 func (r *StepRepository) GetNextActive(afterOrder int, userID int64) (*models.Step, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		row := db.QueryRow(`
-			SELECT s.id, s.step_order, s.text, s.answer_type, s.has_auto_check, s.is_active, s.is_deleted, s.is_asterisk, s.correct_answer_image, s.hint_text, s.hint_image, s.created_at
+			SELECT s.id, s.step_order, s.text, s.answer_type, s.has_auto_check, s.is_active, s.is_deleted, s.is_asterisk, s.correct_answer_image, s.hint_text, s.hint_image, s.location_lat, s.location_lng, s.location_radius, s.required_answers, s.chapter_id, s.numeric_feedback, s.ordered_answers, s.use_synonyms, s.link_domain, s.require_reply, s.image_constraints, s.created_at
 			FROM steps s
 			LEFT JOIN user_progress p ON s.id = p.step_id AND p.user_id = ?
 			WHERE s.is_active = TRUE AND s.is_deleted = FALSE AND s.step_order > ?
@@ -233,7 +362,7 @@ func (r *StepRepository) GetNextActive(afterOrder int, userID int64) (*models.St
 func (r *StepRepository) GetPreviousActive(beforeOrder int) (*models.Step, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		row := db.QueryRow(`
-			SELECT id, step_order, text, answer_type, has_auto_check, is_active, is_deleted, is_asterisk, correct_answer_image, hint_text, hint_image, created_at
+			SELECT id, step_order, text, answer_type, has_auto_check, is_active, is_deleted, is_asterisk, correct_answer_image, hint_text, hint_image, location_lat, location_lng, location_radius, required_answers, chapter_id, numeric_feedback, ordered_answers, use_synonyms, link_domain, require_reply, image_constraints, created_at
 			FROM steps
 			WHERE is_active = TRUE AND is_deleted = FALSE AND step_order < ?
 			ORDER BY step_order DESC
@@ -253,22 +382,44 @@ func (r *StepRepository) GetPreviousActive(beforeOrder int) (*models.Step, error
 }
 
 func (r *StepRepository) AddImage(stepID int64, fileID string, position int) error {
+	return r.AddMedia(stepID, fileID, models.MediaTypePhoto, position)
+}
+
+// AddMedia добавляет к шагу медиа указанного типа: фотографию или анимацию
+func (r *StepRepository) AddMedia(stepID int64, fileID, mediaType string, position int) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		_, err := db.Exec(`
-			INSERT INTO step_images (step_id, file_id, position)
-			VALUES (?, ?, ?)
-		`, stepID, fileID, position)
+			INSERT INTO step_images (step_id, file_id, media_type, position)
+			VALUES (?, ?, ?, ?)
+		`, stepID, fileID, mediaType, position)
 		return nil, err
 	})
 	return err
 }
 
 func (r *StepRepository) ReplaceImage(stepID int64, oldPosition int, fileID string) error {
+	return r.ReplaceMedia(stepID, oldPosition, fileID, models.MediaTypePhoto)
+}
+
+// ReplaceMedia заменяет медиа шага на указанной позиции вместе с его типом
+func (r *StepRepository) ReplaceMedia(stepID int64, oldPosition int, fileID, mediaType string) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		_, err := db.Exec(`
-			UPDATE step_images SET file_id = ? 
+			UPDATE step_images SET file_id = ?, media_type = ?, width = 0, height = 0, file_size = 0
 			WHERE step_id = ? AND position = ?
-		`, fileID, stepID, oldPosition)
+		`, fileID, mediaType, stepID, oldPosition)
+		return nil, err
+	})
+	return err
+}
+
+// SetMediaInfo сохраняет разрешение и размер файла медиа шага на указанной позиции
+func (r *StepRepository) SetMediaInfo(stepID int64, position, width, height int, fileSize int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			UPDATE step_images SET width = ?, height = ?, file_size = ?
+			WHERE step_id = ? AND position = ?
+		`, width, height, fileSize, stepID, position)
 		return nil, err
 	})
 	return err
@@ -316,20 +467,344 @@ func (r *StepRepository) GetImageCount(stepID int64) (int, error) {
 func (r *StepRepository) AddAnswer(stepID int64, answer string) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		_, err := db.Exec(`
-			INSERT INTO step_answers (step_id, answer)
-			VALUES (?, ?)
-		`, stepID, strings.ToLower(strings.TrimSpace(answer)))
+			INSERT INTO step_answers (step_id, answer, position)
+			VALUES (?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM step_answers WHERE step_id = ?))
+		`, stepID, strings.ToLower(strings.TrimSpace(answer)), stepID)
 		return nil, err
 	})
 	return err
 }
 
+// FindDuplicateAnswersAcrossSteps находит варианты ответов, которые встречаются
+// у нескольких неудалённых шагов, чтобы автор мог устранить неоднозначность
+func (r *StepRepository) FindDuplicateAnswersAcrossSteps() ([]models.DuplicateAnswer, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT sa.answer, s.id, s.step_order
+			FROM step_answers sa
+			JOIN steps s ON sa.step_id = s.id AND s.is_deleted = FALSE
+			WHERE sa.answer IN (
+				SELECT sa2.answer
+				FROM step_answers sa2
+				JOIN steps s2 ON sa2.step_id = s2.id AND s2.is_deleted = FALSE
+				GROUP BY sa2.answer
+				HAVING COUNT(DISTINCT sa2.step_id) > 1
+			)
+			GROUP BY sa.answer, s.id
+			ORDER BY sa.answer, s.step_order
+		`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var duplicates []models.DuplicateAnswer
+		for rows.Next() {
+			var answer string
+			var stepID int64
+			var stepOrder int
+			if err := rows.Scan(&answer, &stepID, &stepOrder); err != nil {
+				return nil, err
+			}
+			if len(duplicates) == 0 || duplicates[len(duplicates)-1].Answer != answer {
+				duplicates = append(duplicates, models.DuplicateAnswer{Answer: answer})
+			}
+			last := &duplicates[len(duplicates)-1]
+			last.StepIDs = append(last.StepIDs, stepID)
+			last.StepOrders = append(last.StepOrders, stepOrder)
+		}
+		return duplicates, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]models.DuplicateAnswer), nil
+}
+
+func (r *StepRepository) AddChoice(stepID int64, text string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			INSERT INTO step_choices (step_id, text, position)
+			VALUES (?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM step_choices WHERE step_id = ?))
+		`, stepID, strings.TrimSpace(text), stepID)
+		return nil, err
+	})
+	return err
+}
+
+func (r *StepRepository) GetChoiceByID(choiceID int64) (*models.StepChoice, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var choice models.StepChoice
+		err := db.QueryRow(`
+			SELECT id, step_id, text, is_correct, position FROM step_choices WHERE id = ?
+		`, choiceID).Scan(&choice.ID, &choice.StepID, &choice.Text, &choice.IsCorrect, &choice.Position)
+		if err != nil {
+			return nil, err
+		}
+		return &choice, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.StepChoice), nil
+}
+
+func (r *StepRepository) DeleteChoice(choiceID int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`DELETE FROM step_choices WHERE id = ?`, choiceID)
+		return nil, err
+	})
+	return err
+}
+
+// SetCorrectChoice отмечает вариант правильным (остальные варианты шага становятся неправильными)
+// и включает автопроверку шага
+func (r *StepRepository) SetCorrectChoice(stepID, choiceID int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`UPDATE step_choices SET is_correct = (id = ?) WHERE step_id = ?`, choiceID, stepID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`UPDATE steps SET has_auto_check = TRUE WHERE id = ?`, stepID); err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit()
+	})
+	return err
+}
+
+func (r *StepRepository) AddDocument(stepID int64, fileID, fileName string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			INSERT INTO step_documents (step_id, file_id, file_name, position)
+			VALUES (?, ?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM step_documents WHERE step_id = ?))
+		`, stepID, fileID, fileName, stepID)
+		return nil, err
+	})
+	return err
+}
+
+func (r *StepRepository) GetDocumentByID(documentID int64) (*models.StepDocument, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var document models.StepDocument
+		err := db.QueryRow(`
+			SELECT id, step_id, file_id, file_name, position FROM step_documents WHERE id = ?
+		`, documentID).Scan(&document.ID, &document.StepID, &document.FileID, &document.FileName, &document.Position)
+		if err != nil {
+			return nil, err
+		}
+		return &document, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.StepDocument), nil
+}
+
+func (r *StepRepository) DeleteDocument(documentID int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`DELETE FROM step_documents WHERE id = ?`, documentID)
+		return nil, err
+	})
+	return err
+}
+
+// CreateChapter добавляет главу в конец списка глав
+func (r *StepRepository) CreateChapter(title string) (int64, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		res, err := db.Exec(`
+			INSERT INTO chapters (title, position)
+			VALUES (?, (SELECT COALESCE(MAX(position), -1) + 1 FROM chapters))
+		`, title)
+		if err != nil {
+			return nil, err
+		}
+		return res.LastInsertId()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int64), nil
+}
+
+func (r *StepRepository) GetChapters() ([]models.Chapter, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`SELECT id, title, position FROM chapters ORDER BY position, id`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var chapters []models.Chapter
+		for rows.Next() {
+			var chapter models.Chapter
+			if err := rows.Scan(&chapter.ID, &chapter.Title, &chapter.Position); err != nil {
+				return nil, err
+			}
+			chapters = append(chapters, chapter)
+		}
+		return chapters, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]models.Chapter), nil
+}
+
+// DeleteChapter удаляет главу; её шаги остаются в квесте без главы
+func (r *StepRepository) DeleteChapter(chapterID int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`UPDATE steps SET chapter_id = 0 WHERE chapter_id = ?`, chapterID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM chapters WHERE id = ?`, chapterID); err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit()
+	})
+	return err
+}
+
+// SetChapter привязывает шаг к главе. chapterID = 0 убирает шаг из глав
+func (r *StepRepository) SetChapter(stepID, chapterID int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE steps SET chapter_id = ? WHERE id = ?`, chapterID, stepID)
+		return nil, err
+	})
+	return err
+}
+
+// AddTag помечает шаг тегом; повторное добавление ничего не меняет
+func (r *StepRepository) AddTag(stepID int64, tag string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`INSERT OR IGNORE INTO step_tags (step_id, tag) VALUES (?, ?)`, stepID, tag)
+		return nil, err
+	})
+	return err
+}
+
+func (r *StepRepository) RemoveTag(stepID int64, tag string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`DELETE FROM step_tags WHERE step_id = ? AND tag = ?`, stepID, tag)
+		return nil, err
+	})
+	return err
+}
+
+// GetTags возвращает теги шага по алфавиту
+func (r *StepRepository) GetTags(stepID int64) ([]string, error) {
+	return r.queryTags(`SELECT tag FROM step_tags WHERE step_id = ? ORDER BY tag`, stepID)
+}
+
+// GetAllTags возвращает теги всех неудалённых шагов по алфавиту без повторов
+func (r *StepRepository) GetAllTags() ([]string, error) {
+	return r.queryTags(`
+		SELECT DISTINCT t.tag
+		FROM step_tags t
+		JOIN steps s ON s.id = t.step_id AND s.is_deleted = FALSE
+		ORDER BY t.tag
+	`)
+}
+
+func (r *StepRepository) queryTags(query string, args ...any) ([]string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var tags []string
+		for rows.Next() {
+			var tag string
+			if err := rows.Scan(&tag); err != nil {
+				return nil, err
+			}
+			tags = append(tags, tag)
+		}
+		return tags, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
+// GetByTag возвращает неудалённые шаги с тегом в порядке прохождения
+func (r *StepRepository) GetByTag(tag string) ([]*models.Step, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT s.id, s.step_order, s.text, s.answer_type, s.has_auto_check, s.is_active, s.is_deleted, s.is_asterisk, s.correct_answer_image, s.hint_text, s.hint_image, s.location_lat, s.location_lng, s.location_radius, s.required_answers, s.chapter_id, s.numeric_feedback, s.ordered_answers, s.use_synonyms, s.link_domain, s.require_reply, s.image_constraints, s.created_at
+			FROM steps s
+			JOIN step_tags t ON t.step_id = s.id
+			WHERE t.tag = ? AND s.is_deleted = FALSE
+			ORDER BY s.step_order
+		`, tag)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		return r.scanSteps(db, rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*models.Step), nil
+}
+
+// GetChapterProgress возвращает прохождение глав участником в порядке глав.
+// Number — номер главы среди всех глав; главы без активных шагов не попадают в результат
+func (r *StepRepository) GetChapterProgress(userID int64) ([]models.ChapterProgress, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT c.id, c.title, c.position,
+				(SELECT COUNT(*) FROM chapters c2 WHERE c2.position < c.position OR (c2.position = c.position AND c2.id <= c.id)),
+				COUNT(s.id),
+				COUNT(CASE WHEN up.status IN ('approved', 'skipped') THEN 1 END)
+			FROM chapters c
+			JOIN steps s ON s.chapter_id = c.id AND s.is_active = TRUE AND s.is_deleted = FALSE
+			LEFT JOIN user_progress up ON up.step_id = s.id AND up.user_id = ?
+			GROUP BY c.id
+			ORDER BY c.position, c.id
+		`, userID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var progress []models.ChapterProgress
+		for rows.Next() {
+			var p models.ChapterProgress
+			if err := rows.Scan(&p.Chapter.ID, &p.Chapter.Title, &p.Chapter.Position, &p.Number, &p.Total, &p.Completed); err != nil {
+				return nil, err
+			}
+			progress = append(progress, p)
+		}
+		return progress, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]models.ChapterProgress), nil
+}
+
 func (r *StepRepository) scanStep(row *sql.Row) (*models.Step, error) {
 	var step models.Step
 	var correctImg, hintText, hintImage sql.NullString
 	err := row.Scan(
 		&step.ID, &step.StepOrder, &step.Text, &step.AnswerType,
-		&step.HasAutoCheck, &step.IsActive, &step.IsDeleted, &step.IsAsterisk, &correctImg, &hintText, &hintImage, &step.CreatedAt,
+		&step.HasAutoCheck, &step.IsActive, &step.IsDeleted, &step.IsAsterisk, &correctImg, &hintText, &hintImage, &step.LocationLat, &step.LocationLng, &step.LocationRadius, &step.RequiredAnswers, &step.ChapterID, &step.NumericFeedback, &step.OrderedAnswers, &step.UseSynonyms, &step.LinkDomain, &step.RequireReply, &step.ImageConstraints, &step.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -353,7 +828,7 @@ func (r *StepRepository) scanSteps(db *sql.DB, rows *sql.Rows) ([]*models.Step,
 		var correctImg, hintText, hintImage sql.NullString
 		if err := rows.Scan(
 			&step.ID, &step.StepOrder, &step.Text, &step.AnswerType,
-			&step.HasAutoCheck, &step.IsActive, &step.IsDeleted, &step.IsAsterisk, &correctImg, &hintText, &hintImage, &step.CreatedAt,
+			&step.HasAutoCheck, &step.IsActive, &step.IsDeleted, &step.IsAsterisk, &correctImg, &hintText, &hintImage, &step.LocationLat, &step.LocationLng, &step.LocationRadius, &step.RequiredAnswers, &step.ChapterID, &step.NumericFeedback, &step.OrderedAnswers, &step.UseSynonyms, &step.LinkDomain, &step.RequireReply, &step.ImageConstraints, &step.CreatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -642,7 +1117,7 @@ func (r *StepRepository) GetAnsweredAsteriskStepsCount(userID int64) (int, error
 
 func (r *StepRepository) loadStepRelations(db *sql.DB, step *models.Step) (*models.Step, error) {
 	imgRows, err := db.Query(`
-		SELECT id, step_id, file_id, position
+		SELECT id, step_id, file_id, media_type, position, COALESCE(width, 0), COALESCE(height, 0), COALESCE(file_size, 0)
 		FROM step_images WHERE step_id = ? ORDER BY position
 	`, step.ID)
 	if err != nil {
@@ -652,24 +1127,66 @@ func (r *StepRepository) loadStepRelations(db *sql.DB, step *models.Step) (*mode
 
 	for imgRows.Next() {
 		var img models.StepImage
-		if err := imgRows.Scan(&img.ID, &img.StepID, &img.FileID, &img.Position); err != nil {
+		if err := imgRows.Scan(&img.ID, &img.StepID, &img.FileID, &img.MediaType, &img.Position, &img.Width, &img.Height, &img.FileSize); err != nil {
 			return nil, err
 		}
 		step.Images = append(step.Images, img)
 	}
 
-	ansRows, err := db.Query(`SELECT answer FROM step_answers WHERE step_id = ?`, step.ID)
+	ansRows, err := db.Query(`SELECT answer, COALESCE(language, '') FROM step_answers WHERE step_id = ? ORDER BY position, id`, step.ID)
 	if err != nil {
 		return nil, err
 	}
 	defer ansRows.Close()
 
 	for ansRows.Next() {
-		var answer string
-		if err := ansRows.Scan(&answer); err != nil {
+		var answer, language string
+		if err := ansRows.Scan(&answer, &language); err != nil {
 			return nil, err
 		}
 		step.Answers = append(step.Answers, answer)
+		if language != "" {
+			if step.AnswerLanguages == nil {
+				step.AnswerLanguages = make(map[string]string)
+			}
+			step.AnswerLanguages[answer] = language
+		}
+	}
+
+	docRows, err := db.Query(`
+		SELECT id, step_id, file_id, file_name, position
+		FROM step_documents WHERE step_id = ? ORDER BY position, id
+	`, step.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer docRows.Close()
+
+	for docRows.Next() {
+		var document models.StepDocument
+		if err := docRows.Scan(&document.ID, &document.StepID, &document.FileID, &document.FileName, &document.Position); err != nil {
+			return nil, err
+		}
+		step.Documents = append(step.Documents, document)
+	}
+
+	if step.AnswerType == models.AnswerTypeChoice {
+		choiceRows, err := db.Query(`
+			SELECT id, step_id, text, is_correct, position
+			FROM step_choices WHERE step_id = ? ORDER BY position, id
+		`, step.ID)
+		if err != nil {
+			return nil, err
+		}
+		defer choiceRows.Close()
+
+		for choiceRows.Next() {
+			var choice models.StepChoice
+			if err := choiceRows.Scan(&choice.ID, &choice.StepID, &choice.Text, &choice.IsCorrect, &choice.Position); err != nil {
+				return nil, err
+			}
+			step.Choices = append(step.Choices, choice)
+		}
 	}
 
 	return step, nil
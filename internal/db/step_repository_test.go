@@ -319,6 +319,51 @@ func TestProperty9_SkippedStepsAllowProgression(t *testing.T) {
 	})
 }
 
+func TestFindDuplicateAnswersAcrossSteps(t *testing.T) {
+	db, repo := setupTestDB(t)
+	defer db.Close()
+
+	step1ID := createTestStep(t, repo, "Step 1")
+	step2ID := createTestStep(t, repo, "Step 2")
+	step3ID := createTestStep(t, repo, "Step 3")
+
+	for _, a := range []struct {
+		stepID int64
+		answer string
+	}{
+		{step1ID, "общий ответ"},
+		{step1ID, "только первый"},
+		{step2ID, "Общий ответ "},
+		{step3ID, "только третий"},
+	} {
+		if err := repo.AddAnswer(a.stepID, a.answer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	duplicates, err := repo.FindDuplicateAnswersAcrossSteps()
+	if err != nil {
+		t.Fatalf("FindDuplicateAnswersAcrossSteps failed: %v", err)
+	}
+
+	var found *models.DuplicateAnswer
+	for i := range duplicates {
+		if duplicates[i].Answer == "только первый" || duplicates[i].Answer == "только третий" {
+			t.Errorf("Answer %q should not be reported as duplicate", duplicates[i].Answer)
+		}
+		if duplicates[i].Answer == "общий ответ" {
+			found = &duplicates[i]
+		}
+	}
+
+	if found == nil {
+		t.Fatalf("Expected shared answer to be reported, got %+v", duplicates)
+	}
+	if len(found.StepIDs) != 2 || !contains(found.StepIDs, step1ID) || !contains(found.StepIDs, step2ID) {
+		t.Errorf("Expected shared answer on steps %d and %d, got %v", step1ID, step2ID, found.StepIDs)
+	}
+}
+
 func contains(slice []int64, item int64) bool {
 	for _, v := range slice {
 		if v == item {
@@ -327,3 +372,319 @@ func contains(slice []int64, item int64) bool {
 	}
 	return false
 }
+
+func TestStepDocuments(t *testing.T) {
+	db, repo := setupTestDB(t)
+	defer db.Close()
+
+	stepID := createTestStep(t, repo, "Step with documents")
+
+	if err := repo.AddDocument(stepID, "doc_file_1", "map.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddDocument(stepID, "doc_file_2", "rules.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	step, err := repo.GetByID(stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(step.Documents) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(step.Documents))
+	}
+	if step.Documents[0].FileID != "doc_file_1" || step.Documents[0].FileName != "map.pdf" || step.Documents[1].Position != 1 {
+		t.Errorf("Unexpected documents: %+v", step.Documents)
+	}
+
+	document, err := repo.GetDocumentByID(step.Documents[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.StepID != stepID {
+		t.Errorf("Expected document of step %d, got %d", stepID, document.StepID)
+	}
+
+	if err := repo.DeleteDocument(document.ID); err != nil {
+		t.Fatal(err)
+	}
+	step, err = repo.GetByID(stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(step.Documents) != 1 || step.Documents[0].FileName != "rules.txt" {
+		t.Errorf("Expected only rules.txt to remain, got %+v", step.Documents)
+	}
+}
+
+func TestStepChapters(t *testing.T) {
+	db, repo := setupTestDB(t)
+	defer db.Close()
+
+	forestID, err := repo.CreateChapter("Лес")
+	if err != nil {
+		t.Fatal(err)
+	}
+	riverID, err := repo.CreateChapter("Река")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step1 := createTestStep(t, repo, "Forest step 1")
+	step2 := createTestStep(t, repo, "Forest step 2")
+	step3 := createTestStep(t, repo, "River step")
+	step4 := createTestStep(t, repo, "Step without chapter")
+
+	for stepID, chapterID := range map[int64]int64{step1: forestID, step2: forestID, step3: riverID} {
+		if err := repo.SetChapter(stepID, chapterID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step, err := repo.GetByID(step1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step.ChapterID != forestID {
+		t.Errorf("Expected step to be in chapter %d, got %d", forestID, step.ChapterID)
+	}
+	step, err = repo.GetByID(step4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step.ChapterID != 0 {
+		t.Errorf("Expected step without chapter, got %d", step.ChapterID)
+	}
+
+	if _, err := db.Exec(`INSERT INTO users (id, first_name) VALUES (1, 'Test')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO user_progress (user_id, step_id, status) VALUES
+			(1, ?, 'approved'), (1, ?, 'pending'), (1, ?, 'skipped')
+	`, step1, step2, step3); err != nil {
+		t.Fatal(err)
+	}
+
+	progress, err := repo.GetChapterProgress(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 2 {
+		t.Fatalf("Expected 2 chapters, got %+v", progress)
+	}
+	forest, river := progress[0], progress[1]
+	if forest.Chapter.Title != "Лес" || forest.Number != 1 || forest.Completed != 1 || forest.Total != 2 || forest.IsCompleted() {
+		t.Errorf("Unexpected forest progress: %+v", forest)
+	}
+	if river.Chapter.Title != "Река" || river.Number != 2 || !river.IsCompleted() {
+		t.Errorf("Unexpected river progress: %+v", river)
+	}
+
+	if err := repo.DeleteChapter(forestID); err != nil {
+		t.Fatal(err)
+	}
+	step, err = repo.GetByID(step1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step.ChapterID != 0 {
+		t.Errorf("Expected step to leave deleted chapter, got %d", step.ChapterID)
+	}
+	chapters, err := repo.GetChapters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chapters) != 1 || chapters[0].ID != riverID {
+		t.Errorf("Expected only river chapter to remain, got %+v", chapters)
+	}
+}
+
+func TestStepMediaTypes(t *testing.T) {
+	db, repo := setupTestDB(t)
+	defer db.Close()
+
+	stepID := createTestStep(t, repo, "Step with GIF")
+	if err := repo.AddImage(stepID, "photo_file", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddMedia(stepID, "gif_file", models.MediaTypeAnimation, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	step, err := repo.GetByID(stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(step.Images) != 2 {
+		t.Fatalf("Expected 2 media items, got %d", len(step.Images))
+	}
+	if step.Images[0].IsAnimation() || step.Images[0].MediaType != models.MediaTypePhoto {
+		t.Errorf("Expected first item to be a photo, got %+v", step.Images[0])
+	}
+	if !step.Images[1].IsAnimation() || step.Images[1].FileID != "gif_file" {
+		t.Errorf("Expected second item to be the stored animation, got %+v", step.Images[1])
+	}
+
+	if err := repo.ReplaceMedia(stepID, 0, "gif_file_2", models.MediaTypeAnimation); err != nil {
+		t.Fatal(err)
+	}
+	step, err = repo.GetByID(stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !step.Images[0].IsAnimation() || step.Images[0].FileID != "gif_file_2" {
+		t.Errorf("Expected replaced item to become an animation, got %+v", step.Images[0])
+	}
+}
+
+func TestUpdateAnswerType(t *testing.T) {
+	db, repo := setupTestDB(t)
+	defer db.Close()
+
+	stepID := createTestStep(t, repo, "Type change step")
+	if _, err := db.Exec(`INSERT OR IGNORE INTO users (id, first_name) VALUES (1, 'Test')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO user_progress (user_id, step_id, status) VALUES (1, ?, 'approved')`, stepID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.UpdateAnswerType(stepID, models.AnswerTypeImage); err != nil {
+		t.Fatal(err)
+	}
+
+	step, err := repo.GetByID(stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step.AnswerType != models.AnswerTypeImage {
+		t.Errorf("Expected answer type %q, got %q", models.AnswerTypeImage, step.AnswerType)
+	}
+	if step.Text != "Type change step" {
+		t.Errorf("Expected other fields to stay intact, got text %q", step.Text)
+	}
+
+	hasProgress, err := repo.HasCompletedProgress(stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasProgress {
+		t.Error("Expected completed progress to be kept after the type change")
+	}
+}
+
+func TestStepTags(t *testing.T) {
+	db, repo := setupTestDB(t)
+	defer db.Close()
+
+	forest1 := createTestStep(t, repo, "Forest step 1")
+	river := createTestStep(t, repo, "River step")
+	forest2 := createTestStep(t, repo, "Forest step 2")
+
+	for stepID, tags := range map[int64][]string{
+		forest1: {"лес", "легкий"},
+		river:   {"река"},
+		forest2: {"лес", "лес"},
+	} {
+		for _, tag := range tags {
+			if err := repo.AddTag(stepID, tag); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tags, err := repo.GetTags(forest1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0] != "легкий" || tags[1] != "лес" {
+		t.Errorf("Expected sorted tags [легкий лес], got %v", tags)
+	}
+
+	steps, err := repo.GetByTag("лес")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0].ID != forest1 || steps[1].ID != forest2 {
+		t.Fatalf("Expected forest steps in step order, got %v", steps)
+	}
+	if steps[0].Text != "Forest step 1" {
+		t.Errorf("Expected step to be fully loaded, got text %q", steps[0].Text)
+	}
+
+	if err := repo.RemoveTag(forest1, "лес"); err != nil {
+		t.Fatal(err)
+	}
+	steps, err = repo.GetByTag("лес")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 || steps[0].ID != forest2 {
+		t.Errorf("Expected only the second forest step after removing the tag, got %v", steps)
+	}
+
+	if err := repo.SoftDelete(river); err != nil {
+		t.Fatal(err)
+	}
+	steps, err = repo.GetByTag("река")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 0 {
+		t.Errorf("Expected deleted steps to be hidden, got %v", steps)
+	}
+
+	allTags, err := repo.GetAllTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allTags) != 2 || allTags[0] != "легкий" || allTags[1] != "лес" {
+		t.Errorf("Expected tags of remaining steps [легкий лес], got %v", allTags)
+	}
+}
+
+func TestStepAuthorNotes(t *testing.T) {
+	db, repo := setupTestDB(t)
+	defer db.Close()
+
+	noted := createTestStep(t, repo, "Noted step")
+	plain := createTestStep(t, repo, "Plain step")
+
+	note, err := repo.GetAuthorNote(noted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note != "" {
+		t.Errorf("Expected no note for a new step, got %q", note)
+	}
+
+	if err := repo.SetAuthorNote(noted, "Источник: журнал «Наука и жизнь», сложность 3/5"); err != nil {
+		t.Fatal(err)
+	}
+	note, err = repo.GetAuthorNote(noted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note != "Источник: журнал «Наука и жизнь», сложность 3/5" {
+		t.Errorf("Expected saved note, got %q", note)
+	}
+
+	notes, err := repo.GetAuthorNotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[noted] == "" {
+		t.Errorf("Expected only the noted step in notes, got %v", notes)
+	}
+	if _, ok := notes[plain]; ok {
+		t.Errorf("Expected step without a note to be absent, got %v", notes)
+	}
+
+	if err := repo.SetAuthorNote(noted, ""); err != nil {
+		t.Fatal(err)
+	}
+	if notes, err = repo.GetAuthorNotes(); err != nil || len(notes) != 0 {
+		t.Errorf("Expected note to be cleared, got %v (err %v)", notes, err)
+	}
+}
@@ -17,9 +17,13 @@ func NewStickerPackRepository(queue *DBQueue) *StickerPackRepository {
 func (r *StickerPackRepository) Create(userID int64, packName string) error {
 	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		_, err := db.Exec(`
-			INSERT INTO user_sticker_packs (user_id, pack_name)
-			VALUES (?, ?)
-		`, userID, packName)
+			INSERT INTO user_sticker_packs (user_id, pack_name, status)
+			VALUES (?, ?, ?)
+			ON CONFLICT(user_id) DO UPDATE SET
+				pack_name = excluded.pack_name,
+				status = excluded.status,
+				last_error = ''
+		`, userID, packName, models.StickerPackStatusOK)
 		return nil, err
 	})
 	return err
@@ -28,12 +32,12 @@ func (r *StickerPackRepository) Create(userID int64, packName string) error {
 func (r *StickerPackRepository) GetByUserID(userID int64) (*models.UserStickerPack, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		row := db.QueryRow(`
-			SELECT id, user_id, pack_name, created_at
+			SELECT id, user_id, pack_name, status, attempts, COALESCE(last_error, ''), created_at
 			FROM user_sticker_packs WHERE user_id = ?
 		`, userID)
 
 		var pack models.UserStickerPack
-		err := row.Scan(&pack.ID, &pack.UserID, &pack.PackName, &pack.CreatedAt)
+		err := row.Scan(&pack.ID, &pack.UserID, &pack.PackName, &pack.Status, &pack.Attempts, &pack.LastError, &pack.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -49,8 +53,8 @@ func (r *StickerPackRepository) Exists(userID int64) (bool, error) {
 	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		var count int
 		err := db.QueryRow(`
-			SELECT COUNT(*) FROM user_sticker_packs WHERE user_id = ?
-		`, userID).Scan(&count)
+			SELECT COUNT(*) FROM user_sticker_packs WHERE user_id = ? AND status = ?
+		`, userID, models.StickerPackStatusOK).Scan(&count)
 		return count > 0, err
 	})
 	if err != nil {
@@ -58,3 +62,82 @@ func (r *StickerPackRepository) Exists(userID int64) (bool, error) {
 	}
 	return result.(bool), nil
 }
+
+// MarkFailed запоминает неудачную попытку создания набора, чтобы её можно было повторить позже
+func (r *StickerPackRepository) MarkFailed(userID int64, packName, lastError string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			INSERT INTO user_sticker_packs (user_id, pack_name, status, attempts, last_error)
+			VALUES (?, ?, ?, 1, ?)
+			ON CONFLICT(user_id) DO UPDATE SET
+				status = excluded.status,
+				attempts = user_sticker_packs.attempts + 1,
+				last_error = excluded.last_error
+		`, userID, packName, models.StickerPackStatusFailed, lastError)
+		return nil, err
+	})
+	return err
+}
+
+// GetUsersNeedingRepair возвращает пользователей с достижениями, у которых набор стикеров
+// не создан или создание завершилось ошибкой менее maxAttempts раз
+func (r *StickerPackRepository) GetUsersNeedingRepair(maxAttempts int) ([]int64, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT DISTINCT ua.user_id
+			FROM user_achievements ua
+			LEFT JOIN user_sticker_packs sp ON sp.user_id = ua.user_id
+			WHERE sp.id IS NULL OR (sp.status = ? AND sp.attempts < ?)
+			ORDER BY ua.user_id
+		`, models.StickerPackStatusFailed, maxAttempts)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var userIDs []int64
+		for rows.Next() {
+			var userID int64
+			if err := rows.Scan(&userID); err != nil {
+				return nil, err
+			}
+			userIDs = append(userIDs, userID)
+		}
+		return userIDs, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]int64), nil
+}
+
+// GetUserAchievementKeys возвращает ключи достижений пользователя в порядке получения
+func (r *StickerPackRepository) GetUserAchievementKeys(userID int64) ([]string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT a.key
+			FROM user_achievements ua
+			JOIN achievements a ON ua.achievement_id = a.id
+			WHERE ua.user_id = ?
+			ORDER BY ua.earned_at, ua.id
+		`, userID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var keys []string
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		return keys, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
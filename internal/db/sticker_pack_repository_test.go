@@ -6,6 +6,7 @@ import (
 	"sync/atomic"
 	"testing"
 
+	"github.com/ad/go-telegram-quest/internal/models"
 	_ "modernc.org/sqlite"
 	"pgregory.net/rapid"
 )
@@ -102,3 +103,43 @@ func TestStickerPackRepository_GetByUserID_NotFound(t *testing.T) {
 		t.Error("Expected error for non-existent user")
 	}
 }
+
+func TestStickerPackRepository_MarkFailed(t *testing.T) {
+	db, stickerPackRepo, userRepo := setupStickerPackTestDB(t)
+	defer db.Close()
+
+	createTestUser(t, userRepo, 1)
+
+	for i := 0; i < 2; i++ {
+		if err := stickerPackRepo.MarkFailed(1, "pack_1", "telegram API error"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pack, err := stickerPackRepo.GetByUserID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Status != models.StickerPackStatusFailed || pack.Attempts != 2 || pack.LastError != "telegram API error" {
+		t.Errorf("Unexpected failed pack state: %+v", pack)
+	}
+
+	exists, err := stickerPackRepo.Exists(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Failed pack should not be reported as existing")
+	}
+
+	if err := stickerPackRepo.Create(1, "pack_1"); err != nil {
+		t.Fatal(err)
+	}
+	pack, err = stickerPackRepo.GetByUserID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Status != models.StickerPackStatusOK || pack.LastError != "" {
+		t.Errorf("Expected pack to be ok after Create, got %+v", pack)
+	}
+}
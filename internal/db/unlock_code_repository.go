@@ -0,0 +1,125 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+type UnlockCodeRepository struct {
+	queue *DBQueue
+}
+
+func NewUnlockCodeRepository(queue *DBQueue) *UnlockCodeRepository {
+	return &UnlockCodeRepository{queue: queue}
+}
+
+// SetStepCode задаёт промокод шага. Пустой код снимает ограничение
+func (r *UnlockCodeRepository) SetStepCode(stepID int64, code string) error {
+	return r.setCode(&models.UnlockCode{Code: code, StepID: stepID})
+}
+
+// SetChapterCode задаёт промокод главы. Пустой код снимает ограничение
+func (r *UnlockCodeRepository) SetChapterCode(chapterID int64, code string) error {
+	return r.setCode(&models.UnlockCode{Code: code, ChapterID: chapterID})
+}
+
+func (r *UnlockCodeRepository) setCode(target *models.UnlockCode) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`DELETE FROM unlock_codes WHERE step_id = ? AND chapter_id = ?`, target.StepID, target.ChapterID); err != nil {
+			return nil, err
+		}
+		if target.Code != "" {
+			if _, err := tx.Exec(`
+				INSERT INTO unlock_codes (code, step_id, chapter_id)
+				VALUES (?, ?, ?)
+			`, target.Code, target.StepID, target.ChapterID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, tx.Commit()
+	})
+	return err
+}
+
+// GetStepCode возвращает промокод шага или пустую строку, если шаг открыт всем
+func (r *UnlockCodeRepository) GetStepCode(stepID int64) (string, error) {
+	return r.getCode(stepID, 0)
+}
+
+// GetChapterCode возвращает промокод главы или пустую строку, если глава открыта всем
+func (r *UnlockCodeRepository) GetChapterCode(chapterID int64) (string, error) {
+	return r.getCode(0, chapterID)
+}
+
+func (r *UnlockCodeRepository) getCode(stepID, chapterID int64) (string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var code string
+		err := db.QueryRow(`SELECT code FROM unlock_codes WHERE step_id = ? AND chapter_id = ?`, stepID, chapterID).Scan(&code)
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return code, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.(string), nil
+}
+
+// Find возвращает шаг или главу, которые открывает промокод, или nil, если такого кода нет
+func (r *UnlockCodeRepository) Find(code string) (*models.UnlockCode, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		target := &models.UnlockCode{Code: code}
+		err := db.QueryRow(`SELECT step_id, chapter_id FROM unlock_codes WHERE code = ?`, code).Scan(&target.StepID, &target.ChapterID)
+		if err == sql.ErrNoRows {
+			return (*models.UnlockCode)(nil), nil
+		}
+		return target, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.UnlockCode), nil
+}
+
+// Unlock открывает участнику шаг или главу промокода. Повторное открытие ничего не меняет
+func (r *UnlockCodeRepository) Unlock(userID int64, target *models.UnlockCode) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`
+			INSERT OR IGNORE INTO user_unlocks (user_id, step_id, chapter_id)
+			VALUES (?, ?, ?)
+		`, userID, target.StepID, target.ChapterID)
+		return nil, err
+	})
+	return err
+}
+
+// IsLocked сообщает, что шаг закрыт для участника: у шага или его главы есть промокод,
+// который участник ещё не ввёл. Открытие по старому коду сохраняется и после смены кода
+func (r *UnlockCodeRepository) IsLocked(userID int64, step *models.Step) (bool, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var locked bool
+		err := db.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM unlock_codes uc
+				WHERE ((uc.step_id = ? AND uc.chapter_id = 0) OR (uc.chapter_id = ? AND uc.chapter_id != 0 AND uc.step_id = 0))
+				AND NOT EXISTS (
+					SELECT 1 FROM user_unlocks uu
+					WHERE uu.user_id = ? AND uu.step_id = uc.step_id AND uu.chapter_id = uc.chapter_id
+				)
+			)
+		`, step.ID, step.ChapterID, userID).Scan(&locked)
+		return locked, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
@@ -114,3 +114,162 @@ func (r *UserRepository) IsBlocked(userID int64) (bool, error) {
 	}
 	return result.(bool), nil
 }
+
+// SetDoNotDisturb включает или выключает режим «не беспокоить»: участник не упоминается
+// в объявлениях публичного канала
+func (r *UserRepository) SetDoNotDisturb(userID int64, enabled bool) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE users SET do_not_disturb = ? WHERE id = ?`, enabled, userID)
+		return nil, err
+	})
+	return err
+}
+
+func (r *UserRepository) IsDoNotDisturb(userID int64) (bool, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var enabled bool
+		err := db.QueryRow(`SELECT COALESCE(do_not_disturb, 0) FROM users WHERE id = ?`, userID).Scan(&enabled)
+		return enabled, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+// AcceptRules отмечает, что пользователь принял правила квеста указанной версии
+func (r *UserRepository) AcceptRules(userID int64, version int) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE users SET accepted_at = CURRENT_TIMESTAMP, accepted_rules_version = ? WHERE id = ?`, version, userID)
+		return nil, err
+	})
+	return err
+}
+
+// GetAcceptedRulesVersion возвращает версию правил, которую принял пользователь, или 0
+func (r *UserRepository) GetAcceptedRulesVersion(userID int64) (int, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var version int
+		err := db.QueryRow(`SELECT COALESCE(accepted_rules_version, 0) FROM users WHERE id = ?`, userID).Scan(&version)
+		return version, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
+
+// SetPendingCompletion отмечает, что пользователь ответил на последний шаг и квест
+// будет завершён только после подтверждения
+func (r *UserRepository) SetPendingCompletion(userID int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE users SET pending_completion = TRUE WHERE id = ?`, userID)
+		return nil, err
+	})
+	return err
+}
+
+// ClearPendingCompletion снимает ожидание подтверждения. Возвращает false, если его уже
+// сняли, чтобы повторное нажатие кнопки не завершало квест второй раз
+func (r *UserRepository) ClearPendingCompletion(userID int64) (bool, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		res, err := db.Exec(`UPDATE users SET pending_completion = FALSE WHERE id = ? AND pending_completion = TRUE`, userID)
+		if err != nil {
+			return false, err
+		}
+		affected, err := res.RowsAffected()
+		return affected > 0, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+// IsPendingCompletion сообщает, ждёт ли завершение квеста подтверждения пользователя
+func (r *UserRepository) IsPendingCompletion(userID int64) (bool, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var pending bool
+		err := db.QueryRow(`SELECT COALESCE(pending_completion, 0) FROM users WHERE id = ?`, userID).Scan(&pending)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return pending, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+func (r *UserRepository) SetTimezone(userID int64, timezone string) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`UPDATE users SET timezone = ? WHERE id = ?`, timezone, userID)
+		return nil, err
+	})
+	return err
+}
+
+// GetEffectiveTimezone возвращает часовой пояс пользователя, а если он не задан — default_timezone из настроек
+func (r *UserRepository) GetEffectiveTimezone(userID int64) (string, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var timezone string
+		err := db.QueryRow(`
+			SELECT COALESCE(
+				NULLIF((SELECT timezone FROM users WHERE id = ?), ''),
+				(SELECT value FROM settings WHERE key = 'default_timezone'),
+				''
+			)
+		`, userID).Scan(&timezone)
+		if err != nil {
+			return "", err
+		}
+		return timezone, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.(string), nil
+}
+
+// AddToWaitlist ставит пользователя в лист ожидания старта (повторный вызов не меняет очередь)
+func (r *UserRepository) AddToWaitlist(userID int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`INSERT OR IGNORE INTO start_waitlist (user_id) VALUES (?)`, userID)
+		return nil, err
+	})
+	return err
+}
+
+func (r *UserRepository) RemoveFromWaitlist(userID int64) error {
+	_, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		_, err := db.Exec(`DELETE FROM start_waitlist WHERE user_id = ?`, userID)
+		return nil, err
+	})
+	return err
+}
+
+// GetWaitlist возвращает первых limit пользователей из листа ожидания в порядке очереди
+func (r *UserRepository) GetWaitlist(limit int) ([]int64, error) {
+	result, err := r.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`SELECT user_id FROM start_waitlist ORDER BY created_at, rowid LIMIT ?`, limit)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var userIDs []int64
+		for rows.Next() {
+			var userID int64
+			if err := rows.Scan(&userID); err != nil {
+				return nil, err
+			}
+			userIDs = append(userIDs, userID)
+		}
+		return userIDs, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]int64), nil
+}
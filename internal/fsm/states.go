@@ -43,4 +43,31 @@ const (
 	StateAdminEnableGroupRestrictionLink = "admin_enable_group_restriction_link"
 	StateAdminEditGroupID                = "admin_edit_group_id"
 	StateAdminEditGroupLink              = "admin_edit_group_link"
+	StateAdminEditDefaultTimezone        = "admin_edit_default_timezone"
+	StateAdminEditScoring                = "admin_edit_scoring"
+	StateAdminEditAutoApprove            = "admin_edit_auto_approve"
+	StateAdminEditDailyDigest            = "admin_edit_daily_digest"
+	StateAdminEditLocationTarget         = "admin_edit_location_target"
+	StateAdminEditRequiredAnswers        = "admin_edit_required_answers"
+	StateAdminEditMinAnswerLength        = "admin_edit_min_answer_length"
+	StateAdminAddChoice                  = "admin_add_choice"
+	StateAdminEditMaxActiveUsers         = "admin_edit_max_active_users"
+	StateAdminEditFillerWords            = "admin_edit_filler_words"
+	StateAdminAddDocument                = "admin_add_document"
+	StateAdminAddChapter                 = "admin_add_chapter"
+	StateAdminRejectReason               = "admin_reject_reason"
+	StateAdminEditAnnounceChannel        = "admin_edit_announce_channel"
+	StateAdminEditCorrectImageDelay      = "admin_edit_correct_image_delay"
+	StateAdminTestAnswer                 = "admin_test_answer"
+	StateAdminEditHintCooldown           = "admin_edit_hint_cooldown"
+	StateAdminAddSynonymGroup            = "admin_add_synonym_group"
+	StateAdminImportQuestConfig          = "admin_import_quest_config"
+	StateAdminEditLinkDomain             = "admin_edit_link_domain"
+	StateAdminEditStepTypingDelay        = "admin_edit_step_typing_delay"
+	StateAdminEditUnlockCode             = "admin_edit_unlock_code"
+	StateAdminAddStepTag                 = "admin_add_step_tag"
+	StateAdminEditMinCompletionSteps     = "admin_edit_min_completion_steps"
+	StateAdminEditImageConstraints       = "admin_edit_image_constraints"
+	StateAdminEditAuthorNote             = "admin_edit_author_note"
+	StateAdminEditExportFileThreshold    = "admin_edit_export_file_threshold"
 )
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"html"
 	"log"
 	"math"
 	"os/exec"
+	"slices"
 	"strings"
 	"time"
 
@@ -24,8 +27,11 @@ type AdminHandler struct {
 	adminID             int64
 	stepRepo            *db.StepRepository
 	answerRepo          *db.AnswerRepository
+	progressRepo        *db.ProgressRepository
 	settingsRepo        *db.SettingsRepository
 	adminStateRepo      *db.AdminStateRepository
+	stepReportRepo      *db.StepReportRepository
+	adminMessagesRepo   *db.AdminMessagesRepository
 	userManager         *services.UserManager
 	userRepo            *db.UserRepository
 	questStateManager   *services.QuestStateManager
@@ -34,7 +40,13 @@ type AdminHandler struct {
 	achievementNotifier *services.AchievementNotifier
 	statsService        *services.StatisticsService
 	errorManager        *services.ErrorManager
+	msgManager          *services.MessageManager
+	diagnostics         *services.DiagnosticsService
+	questConfig         *services.QuestConfigService
+	unlockService       *services.UnlockService
+	media               *services.MediaService
 	dbPath              string
+	mediaGroups         mediaGroupBuffer
 }
 
 func NewAdminHandler(
@@ -42,8 +54,11 @@ func NewAdminHandler(
 	adminID int64,
 	stepRepo *db.StepRepository,
 	answerRepo *db.AnswerRepository,
+	progressRepo *db.ProgressRepository,
 	settingsRepo *db.SettingsRepository,
 	adminStateRepo *db.AdminStateRepository,
+	stepReportRepo *db.StepReportRepository,
+	adminMessagesRepo *db.AdminMessagesRepository,
 	userManager *services.UserManager,
 	userRepo *db.UserRepository,
 	questStateManager *services.QuestStateManager,
@@ -52,6 +67,10 @@ func NewAdminHandler(
 	achievementNotifier *services.AchievementNotifier,
 	statsService *services.StatisticsService,
 	errorManager *services.ErrorManager,
+	msgManager *services.MessageManager,
+	diagnostics *services.DiagnosticsService,
+	questConfig *services.QuestConfigService,
+	unlockService *services.UnlockService,
 	dbPath string,
 ) *AdminHandler {
 	return &AdminHandler{
@@ -59,8 +78,11 @@ func NewAdminHandler(
 		adminID:             adminID,
 		stepRepo:            stepRepo,
 		answerRepo:          answerRepo,
+		progressRepo:        progressRepo,
 		settingsRepo:        settingsRepo,
 		adminStateRepo:      adminStateRepo,
+		stepReportRepo:      stepReportRepo,
+		adminMessagesRepo:   adminMessagesRepo,
 		userManager:         userManager,
 		userRepo:            userRepo,
 		questStateManager:   questStateManager,
@@ -69,6 +91,11 @@ func NewAdminHandler(
 		achievementNotifier: achievementNotifier,
 		statsService:        statsService,
 		errorManager:        errorManager,
+		msgManager:          msgManager,
+		diagnostics:         diagnostics,
+		questConfig:         questConfig,
+		unlockService:       unlockService,
+		media:               services.NewMediaService(b),
 		dbPath:              dbPath,
 	}
 }
@@ -85,6 +112,9 @@ func (h *AdminHandler) HandleCommand(ctx context.Context, msg *tgmodels.Message)
 	case "/cancel":
 		h.cancelOperation(ctx, msg.Chat.ID)
 		return true
+	case "/diag":
+		h.sendDiagnostics(ctx, msg.Chat.ID)
+		return true
 	}
 
 	state, err := h.adminStateRepo.Get(h.adminID)
@@ -113,6 +143,11 @@ func (h *AdminHandler) HandleCallback(ctx context.Context, callback *tgmodels.Ca
 	messageID := msg.ID
 	data := callback.Data
 
+	if target, ok := strings.CutPrefix(data, statsRefreshPrefix); ok {
+		h.statsService.InvalidateCache()
+		data = target
+	}
+
 	switch {
 	case data == "admin:menu":
 		h.showAdminMenu(ctx, chatID, messageID)
@@ -120,6 +155,10 @@ func (h *AdminHandler) HandleCallback(ctx context.Context, callback *tgmodels.Ca
 		h.startAddStep(ctx, chatID, messageID)
 	case data == "admin:list_steps":
 		h.showStepsList(ctx, chatID, messageID)
+	case data == "admin:tag_filter":
+		h.showTagFilter(ctx, chatID, messageID)
+	case strings.HasPrefix(data, "admin:steps_tag:"):
+		h.showStepsByTag(ctx, chatID, messageID, data)
 	case data == "admin:users":
 		h.showUserList(ctx, chatID, messageID, 1)
 	case data == "admin:settings":
@@ -134,10 +173,88 @@ func (h *AdminHandler) HandleCallback(ctx context.Context, callback *tgmodels.Ca
 		h.startEditGroupID(ctx, chatID, messageID)
 	case data == "admin:edit_group_link":
 		h.startEditGroupLink(ctx, chatID, messageID)
+	case data == "admin:auto_invite_link_toggle":
+		h.toggleAutoInviteLink(ctx, chatID, messageID)
+	case data == "admin:group_fail_open_toggle":
+		h.toggleGroupCheckFailOpen(ctx, chatID, messageID)
+	case data == "admin:hall_of_fame_toggle":
+		h.toggleHallOfFame(ctx, chatID, messageID)
 	case data == "admin:quest_state":
 		h.showQuestStateMenu(ctx, chatID, messageID)
 	case data == "admin:export_steps":
 		h.exportSteps(ctx, chatID, messageID)
+	case data == "admin:quest_config":
+		h.showQuestConfigMenu(ctx, chatID, messageID)
+	case data == "admin:quest_config_export":
+		h.exportQuestConfig(ctx, chatID, messageID)
+	case data == "admin:quest_config_import":
+		h.startImportQuestConfig(ctx, chatID, messageID)
+	case strings.HasPrefix(data, "admin:quest_config_apply:"):
+		h.applyQuestConfig(ctx, chatID, messageID, data)
+	case data == "admin:export_mode":
+		h.cycleExportMode(ctx, chatID, messageID)
+	case data == "admin:export_file_threshold":
+		h.startEditExportFileThreshold(ctx, chatID, messageID)
+	case data == "admin:default_timezone":
+		h.startEditDefaultTimezone(ctx, chatID, messageID)
+	case data == "admin:scoring_toggle":
+		h.toggleScoring(ctx, chatID, messageID)
+	case data == "admin:answer_summary_toggle":
+		h.toggleAnswerSummary(ctx, chatID, messageID)
+	case data == "admin:attempt_stats_toggle":
+		h.toggleUserAttemptStats(ctx, chatID, messageID)
+	case data == "admin:quest_map_toggle":
+		h.toggleQuestMap(ctx, chatID, messageID)
+	case data == "admin:progress_after_answer_toggle":
+		h.toggleProgressAfterAnswer(ctx, chatID, messageID)
+	case data == "admin:match_rule_logging_toggle":
+		h.toggleMatchRuleLogging(ctx, chatID, messageID)
+	case data == "admin:answer_reaction_cycle":
+		h.cycleCorrectAnswerReaction(ctx, chatID, messageID)
+	case data == "admin:export_notes_toggle":
+		h.toggleExportAuthorNotes(ctx, chatID, messageID)
+	case data == "admin:completion_position_toggle":
+		h.toggleRevealCompletionPosition(ctx, chatID, messageID)
+	case data == "admin:group_completion_toggle":
+		h.toggleGroupCompletionAchievements(ctx, chatID, messageID)
+	case data == "admin:skip_deactivated_toggle":
+		h.toggleSkipDeactivatedSteps(ctx, chatID, messageID)
+	case data == "admin:unique_claims_toggle":
+		h.toggleNotifyUniqueAchievements(ctx, chatID, messageID)
+	case data == "admin:completion_confirmation_toggle":
+		h.toggleCompletionConfirmation(ctx, chatID, messageID)
+	case data == "admin:share_toggle":
+		h.toggleShare(ctx, chatID, messageID)
+	case data == "admin:auto_advance_toggle":
+		h.toggleAutoAdvance(ctx, chatID, messageID)
+	case data == "admin:strip_quotes_toggle":
+		h.toggleStripAnswerQuotes(ctx, chatID, messageID)
+	case data == "admin:practice_trace_toggle":
+		h.togglePracticeTrace(ctx, chatID, messageID)
+	case data == "admin:verbose_matching_toggle":
+		h.toggleVerboseMatching(ctx, chatID, messageID)
+	case data == "admin:scoring_values":
+		h.startEditScoring(ctx, chatID, messageID)
+	case data == "admin:auto_approve":
+		h.startEditAutoApprove(ctx, chatID, messageID)
+	case data == "admin:min_completion_steps":
+		h.startEditMinCompletionSteps(ctx, chatID, messageID)
+	case data == "admin:min_answer_length":
+		h.startEditMinAnswerLength(ctx, chatID, messageID)
+	case data == "admin:step_typing_delay":
+		h.startEditStepTypingDelay(ctx, chatID, messageID)
+	case data == "admin:correct_image_delay":
+		h.startEditCorrectImageDelay(ctx, chatID, messageID)
+	case data == "admin:hint_cooldown":
+		h.startEditHintCooldown(ctx, chatID, messageID)
+	case data == "admin:max_active_users":
+		h.startEditMaxActiveUsers(ctx, chatID, messageID)
+	case data == "admin:announce_channel":
+		h.startEditAnnounceChannel(ctx, chatID, messageID)
+	case data == "admin:filler_words":
+		h.startEditFillerWords(ctx, chatID, messageID)
+	case data == "admin:daily_digest":
+		h.startEditDailyDigest(ctx, chatID, messageID)
 	case data == "admin:backup":
 		h.createBackup(ctx, chatID, messageID)
 	case strings.HasPrefix(data, "admin:quest_state:"):
@@ -150,16 +267,84 @@ func (h *AdminHandler) HandleCallback(ctx context.Context, callback *tgmodels.Ca
 		h.startEditStep(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:edit_text:"):
 		h.startEditStepText(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:change_type:"):
+		h.showChangeAnswerType(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:set_type:"):
+		h.changeAnswerType(ctx, chatID, messageID, strings.TrimPrefix(data, "admin:set_type:"), false)
+	case strings.HasPrefix(data, "admin:set_type_ok:"):
+		h.changeAnswerType(ctx, chatID, messageID, strings.TrimPrefix(data, "admin:set_type_ok:"), true)
 	case strings.HasPrefix(data, "admin:delete_step:"):
 		h.deleteStep(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:toggle_step:"):
 		h.toggleStep(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:require_reply:"):
+		h.toggleRequireReply(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:toggle_asterisk:"):
 		h.toggleAsterisk(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:location_target:"):
+		h.startEditLocationTarget(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:link_domain:"):
+		h.startEditLinkDomain(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:image_constraints:"):
+		h.startEditImageConstraints(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:author_note:"):
+		h.startEditAuthorNote(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:unlock_code:"):
+		h.startEditUnlockCode(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:step_tags:"):
+		h.showStepTagsMenu(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:step_tag_add:"):
+		h.startAddStepTag(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:step_tag_del:"):
+		h.removeStepTag(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:ordered_answers:"):
+		h.toggleOrderedAnswers(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:use_synonyms:"):
+		h.toggleUseSynonyms(ctx, chatID, messageID, data)
+	case data == "admin:answer_languages":
+		h.showAnswerLanguagesMenu(ctx, chatID, messageID)
+	case data == "admin:synonyms":
+		h.showSynonymsMenu(ctx, chatID, messageID)
+	case data == "admin:synonym_add":
+		h.startAddSynonymGroup(ctx, chatID, messageID)
+	case strings.HasPrefix(data, "admin:synonym_del:"):
+		h.deleteSynonymGroup(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:numeric_feedback:"):
+		h.toggleNumericFeedback(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:required_answers:"):
+		h.startEditRequiredAnswers(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:answers:"):
 		h.showAnswersMenu(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:test_answer:"):
+		h.startTestAnswer(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:choices:"):
+		h.showChoicesMenu(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:add_choice:"):
+		h.startAddChoice(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:choice_correct:"):
+		h.markCorrectChoice(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:choice_del:"):
+		h.deleteChoice(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:documents:"):
+		h.showDocumentsMenu(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:add_document:"):
+		h.startAddDocument(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:document_del:"):
+		h.deleteDocument(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:step_chapter:"):
+		h.showStepChapterMenu(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:set_chapter:"):
+		h.setStepChapter(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:add_chapter:"):
+		h.startAddChapter(ctx, chatID, messageID, data)
+	case data == "admin:chapters":
+		h.showChaptersMenu(ctx, chatID, messageID)
+	case strings.HasPrefix(data, "admin:chapter_del:"):
+		h.deleteChapter(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:add_answer:"):
 		h.startAddAnswer(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:move_answer:"):
+		h.moveAnswer(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:del_answer:"):
 		h.startDeleteAnswer(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:images:"):
@@ -186,6 +371,8 @@ func (h *AdminHandler) HandleCallback(ctx context.Context, callback *tgmodels.Ca
 		h.startReplaceCorrectImage(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:delete_correct_img:"):
 		h.startDeleteCorrectImage(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:setting_mode:"):
+		h.cycleSettingParseMode(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:edit_setting:"):
 		h.startEditSetting(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "userlist:"):
@@ -202,6 +389,12 @@ func (h *AdminHandler) HandleCallback(ctx context.Context, callback *tgmodels.Ca
 		h.handleResetFromDetails(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "reset_achievements:"):
 		h.handleResetAchievementsFromDetails(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "reset_category:"):
+		h.handleResetCategoryFromDetails(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "export_profile:"):
+		h.exportUserProfile(ctx, chatID, data)
+	case strings.HasPrefix(data, "answer_photos:"):
+		h.handleAnswerPhotos(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "user_achievements:"):
 		h.showUserAchievements(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "award:"):
@@ -212,20 +405,40 @@ func (h *AdminHandler) HandleCallback(ctx context.Context, callback *tgmodels.Ca
 		h.handleSendMessageTypeSelect(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:send_msg_cancel:"):
 		h.handleSendMessageCancel(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:reject_reason:"):
+		h.startRejectWithReason(ctx, chatID, messageID, data)
+	case strings.HasPrefix(data, "admin:close_thread:"):
+		h.handleCloseSupportThread(ctx, chatID, messageID, data)
 	case data == "admin:achievement_stats":
 		h.showAchievementStatistics(ctx, chatID, messageID)
+	case data == "admin:achievement_notify":
+		h.showAchievementNotifySettings(ctx, chatID, messageID)
+	case strings.HasPrefix(data, "admin:achievement_notify_toggle:"):
+		h.toggleAchievementNotify(ctx, chatID, messageID, data)
+	case data == "admin:achievement_audit":
+		h.showAchievementAudit(ctx, chatID, messageID)
+	case data == "admin:achievement_recompute":
+		h.showAchievementRecompute(ctx, chatID, messageID)
+	case strings.HasPrefix(data, "admin:achievement_recompute:"):
+		h.handleAchievementRecompute(ctx, chatID, messageID, data)
 	case strings.HasPrefix(data, "admin:achievement_leaders"):
 		h.showAchievementLeaders(ctx, chatID, messageID)
 	case data == "admin:statistics":
 		h.showStatistics(ctx, chatID, messageID)
 	case data == "admin:analytics":
 		h.showAnalyticsMenu(ctx, chatID, messageID)
+	case data == "admin:reports":
+		h.showStepReports(ctx, chatID, messageID)
+	case strings.HasPrefix(data, "admin:report_useful:"):
+		h.markReportUseful(ctx, chatID, messageID, data)
 	case data == "admin:analytics:funnel":
 		h.showFunnelAnalytics(ctx, chatID, messageID)
 	case data == "admin:analytics:hardest":
 		h.showHardestSteps(ctx, chatID, messageID)
 	case data == "admin:analytics:hints":
 		h.showHintAnalytics(ctx, chatID, messageID)
+	case data == "admin:analytics:duplicates":
+		h.showDuplicateAnswers(ctx, chatID, messageID)
 	case strings.HasPrefix(data, "admin:analytics:answers:"):
 		h.showStepAnswers(ctx, chatID, messageID, data)
 	case data == "admin:analytics:speedrun":
@@ -246,6 +459,12 @@ func (h *AdminHandler) HandleCallback(ctx context.Context, callback *tgmodels.Ca
 		h.setStepType(ctx, chatID, messageID, models.AnswerTypeText)
 	case data == "admin:step_type:image":
 		h.setStepType(ctx, chatID, messageID, models.AnswerTypeImage)
+	case data == "admin:step_type:location":
+		h.setStepType(ctx, chatID, messageID, models.AnswerTypeLocation)
+	case data == "admin:step_type:choice":
+		h.setStepType(ctx, chatID, messageID, models.AnswerTypeChoice)
+	case data == "admin:step_type:link":
+		h.setStepType(ctx, chatID, messageID, models.AnswerTypeLink)
 	case data == "admin:skip_images":
 		h.skipImages(ctx, chatID, messageID)
 	case data == "admin:done_images":
@@ -284,6 +503,19 @@ func (h *AdminHandler) editOrSend(ctx context.Context, chatID int64, messageID i
 	}
 }
 
+// editOrSendLong показывает экран, текст которого может не поместиться в одно сообщение.
+// Короткий текст показывается как обычно, длинный отправляется частями через SendLong
+func (h *AdminHandler) editOrSendLong(ctx context.Context, chatID int64, messageID int, text string, keyboard *tgmodels.InlineKeyboardMarkup) {
+	if h.msgManager == nil || services.MessageLength(text) <= h.msgManager.MaxMessageLength() {
+		h.editOrSend(ctx, chatID, messageID, text, keyboard)
+		return
+	}
+
+	if err := h.msgManager.SendLong(ctx, chatID, text, keyboard); err != nil {
+		log.Printf("[ADMIN] SendLong error: %v", err)
+	}
+}
+
 func (h *AdminHandler) sendMessage(ctx context.Context, chatID int64, text string, keyboard *tgmodels.InlineKeyboardMarkup) {
 	params := &bot.SendMessageParams{
 		ChatID:    chatID,
@@ -315,11 +547,13 @@ func (h *AdminHandler) showAdminMenu(ctx context.Context, chatID int64, messageI
 			{{Text: "➕ Добавить шаг", CallbackData: "admin:add_step"}},
 			{{Text: "📋 Список шагов", CallbackData: "admin:list_steps"}},
 			{{Text: "📤 Экспорт шагов", CallbackData: "admin:export_steps"}},
+			{{Text: "📦 Конфигурация квеста", CallbackData: "admin:quest_config"}},
 			{{Text: "👥 Участники", CallbackData: "admin:users"}},
 			{{Text: "🏆 Достижения", CallbackData: "admin:achievement_stats"}},
 			{{Text: "💾 Бэкап", CallbackData: "admin:backup"}},
 			{{Text: "📊 Статистика", CallbackData: "admin:statistics"}},
 			{{Text: "🔍 Аналитика ответов", CallbackData: "admin:analytics"}},
+			{{Text: "🚩 Жалобы на шаги", CallbackData: "admin:reports"}},
 			{{Text: "⚙️ Настройки", CallbackData: "admin:settings"}},
 		},
 	}
@@ -363,8 +597,42 @@ func (h *AdminHandler) showStepsList(ctx context.Context, chatID int64, messageI
 		return
 	}
 
+	buttons := h.stepListButtons(steps)
+	if tags, err := h.stepRepo.GetAllTags(); err == nil && len(tags) > 0 {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🏷 Фильтр по тегу", CallbackData: "admin:tag_filter"},
+		})
+	}
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад", CallbackData: "admin:menu"},
+	})
+
+	h.editOrSend(ctx, chatID, messageID, "📋 Выберите шаг для редактирования:", &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+// stepListButtons строит кнопки шагов, сгруппированные по главам
+func (h *AdminHandler) stepListButtons(steps []*models.Step) [][]tgmodels.InlineKeyboardButton {
+	chapterTitles := make(map[int64]string)
+	if chapters, err := h.stepRepo.GetChapters(); err == nil {
+		for i, chapter := range chapters {
+			chapterTitles[chapter.ID] = chapterLabel(i+1, chapter)
+		}
+	}
+
 	var buttons [][]tgmodels.InlineKeyboardButton
+	var currentChapterID int64
 	for _, step := range steps {
+		if step.ChapterID != currentChapterID {
+			currentChapterID = step.ChapterID
+			header := "📖 Без главы"
+			if title, ok := chapterTitles[step.ChapterID]; ok {
+				header = "📖 " + title
+			}
+			buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+				{Text: truncateText(header, 40), CallbackData: "admin:chapters"},
+			})
+		}
+
 		status := ""
 		if !step.IsActive {
 			status = "⏸️"
@@ -384,11 +652,59 @@ func (h *AdminHandler) showStepsList(ctx context.Context, chatID int64, messageI
 			{Text: text, CallbackData: fmt.Sprintf("admin:edit_step:%d", step.ID)},
 		})
 	}
+	return buttons
+}
+
+// showTagFilter показывает все теги шагов. В callback передаётся номер тега в списке,
+// а не сам тег, чтобы не выйти за лимит длины callback data
+func (h *AdminHandler) showTagFilter(ctx context.Context, chatID int64, messageID int) {
+	tags, err := h.stepRepo.GetAllTags()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении тегов", nil)
+		return
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for i, tag := range tags {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "#" + tag, CallbackData: fmt.Sprintf("admin:steps_tag:%d", i)},
+		})
+	}
 	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
-		{Text: "⬅️ Назад", CallbackData: "admin:menu"},
+		{Text: "⬅️ Назад", CallbackData: "admin:list_steps"},
 	})
 
-	h.editOrSend(ctx, chatID, messageID, "📋 Выберите шаг для редактирования:", &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+	text := "🏷 Выберите тег:"
+	if len(tags) == 0 {
+		text = "🏷 Тегов пока нет"
+	}
+	h.editOrSend(ctx, chatID, messageID, text, &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+func (h *AdminHandler) showStepsByTag(ctx context.Context, chatID int64, messageID int, data string) {
+	index, err := parseInt64(strings.TrimPrefix(data, "admin:steps_tag:"))
+	if err != nil {
+		return
+	}
+	tags, err := h.stepRepo.GetAllTags()
+	if err != nil || index < 0 || index >= int64(len(tags)) {
+		h.showTagFilter(ctx, chatID, messageID)
+		return
+	}
+	tag := tags[index]
+
+	steps, err := h.stepRepo.GetByTag(tag)
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении шагов", nil)
+		return
+	}
+
+	buttons := h.stepListButtons(steps)
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "✖️ Сбросить фильтр", CallbackData: "admin:list_steps"},
+	})
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("🏷 Шаги с тегом #%s (%d):", html.EscapeString(tag), len(steps)), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
 }
 
 func (h *AdminHandler) startEditStep(ctx context.Context, chatID int64, messageID int, data string) {
@@ -409,20 +725,66 @@ func (h *AdminHandler) startEditStep(ctx context.Context, chatID int64, messageI
 	sb.WriteString(fmt.Sprintf("📋 Шаг %d\n\n", step.StepOrder))
 	sb.WriteString(fmt.Sprintf("📝 Текст: %s\n\n", truncateText(step.Text, 100)))
 	sb.WriteString(fmt.Sprintf("📷 Изображений: %d\n", len(step.Images)))
-	sb.WriteString(fmt.Sprintf("💬 Тип ответа: %s\n", step.AnswerType))
+	if len(step.Documents) > 0 {
+		sb.WriteString(fmt.Sprintf("📎 Файлов: %d\n", len(step.Documents)))
+	}
+	sb.WriteString(fmt.Sprintf("💬 Тип ответа: %s\n", answerTypeLabel(step.AnswerType)))
 	sb.WriteString(fmt.Sprintf("✅ Вариантов ответа: %d\n", len(step.Answers)))
+	if step.AnswerType == models.AnswerTypeLocation {
+		sb.WriteString(fmt.Sprintf("📍 Цель: %s\n", locationTargetLabel(step)))
+	}
+	if step.AnswerType == models.AnswerTypeChoice {
+		sb.WriteString(fmt.Sprintf("🔘 Вариантов выбора: %d\n", len(step.Choices)))
+	}
+	if step.AnswerType == models.AnswerTypeLink {
+		sb.WriteString(fmt.Sprintf("🔗 Домен ссылки: %s\n", linkDomainLabel(step)))
+	}
+	if step.AnswerType == models.AnswerTypeImage && step.ImageConstraints != "" {
+		sb.WriteString(fmt.Sprintf("📐 Фото: %s\n", imageConstraintsLabel(step)))
+	}
+	if step.RequireReply {
+		sb.WriteString("↩️ Ответ принимается только реплаем на задание\n")
+	}
 
 	hasHint := step.HasHint()
 	if hasHint {
 		sb.WriteString("💡 Подсказка: есть\n")
 	}
 
+	if h.unlockService != nil {
+		if code, err := h.unlockService.GetStepCode(stepID); err == nil && code != "" {
+			sb.WriteString(fmt.Sprintf("🔒 Промокод: %s\n", html.EscapeString(code)))
+		}
+	}
+
+	if tags, err := h.stepRepo.GetTags(stepID); err == nil && len(tags) > 0 {
+		sb.WriteString(fmt.Sprintf("🏷 Теги: %s\n", html.EscapeString(services.FormatStepTags(tags))))
+	}
+
+	if note, err := h.stepRepo.GetAuthorNote(stepID); err == nil && note != "" {
+		sb.WriteString(fmt.Sprintf("🗒 Заметка автора: %s\n", html.EscapeString(truncateText(note, 200))))
+	}
+
+	if step.ChapterID != 0 {
+		if chapters, err := h.stepRepo.GetChapters(); err == nil {
+			for i, chapter := range chapters {
+				if chapter.ID == step.ChapterID {
+					sb.WriteString(fmt.Sprintf("📖 %s\n", html.EscapeString(chapterLabel(i+1, chapter))))
+				}
+			}
+		}
+	}
+
 	status := "Активен"
 	if !step.IsActive {
 		status = "Отключён"
 	}
 	sb.WriteString(fmt.Sprintf("📊 Статус: %s\n", status))
 
+	if usersOnStep, err := h.progressRepo.CountUsersOnStep(stepID); err == nil && usersOnStep > 0 {
+		sb.WriteString(fmt.Sprintf("👥 Сейчас на шаге: %d\n", usersOnStep))
+	}
+
 	if hasProgress {
 		sb.WriteString("\n⚠️ Шаг уже пройден некоторыми пользователями")
 	}
@@ -433,14 +795,68 @@ func (h *AdminHandler) startEditStep(ctx context.Context, chatID int64, messageI
 		{Text: "✏️ Изменить текст", CallbackData: fmt.Sprintf("admin:edit_text:%d", stepID)},
 	})
 
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "🔁 Тип ответа", CallbackData: fmt.Sprintf("admin:change_type:%d", stepID)},
+	})
+
 	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
 		{Text: "📝 Варианты ответов", CallbackData: fmt.Sprintf("admin:answers:%d", stepID)},
 	})
 
+	if step.AnswerType == models.AnswerTypeLocation {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "📍 Цель", CallbackData: fmt.Sprintf("admin:location_target:%d", stepID)},
+		})
+	}
+
+	if step.AnswerType == models.AnswerTypeChoice {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🔘 Варианты выбора", CallbackData: fmt.Sprintf("admin:choices:%d", stepID)},
+		})
+	}
+
+	if step.AnswerType == models.AnswerTypeLink {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🔗 Домен ссылки", CallbackData: fmt.Sprintf("admin:link_domain:%d", stepID)},
+		})
+	}
+
+	if step.AnswerType == models.AnswerTypeImage {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "📐 Размер фото", CallbackData: fmt.Sprintf("admin:image_constraints:%d", stepID)},
+		})
+	}
+
+	if step.AnswerType != models.AnswerTypeChoice {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "↩️ Ответ реплаем: " + requireReplyLabel(step), CallbackData: fmt.Sprintf("admin:require_reply:%d", stepID)},
+		})
+	}
+
 	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
 		{Text: "📷 Изображения", CallbackData: fmt.Sprintf("admin:images:%d", stepID)},
 	})
 
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "📎 Файлы", CallbackData: fmt.Sprintf("admin:documents:%d", stepID)},
+	})
+
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "📖 Глава", CallbackData: fmt.Sprintf("admin:step_chapter:%d", stepID)},
+	})
+
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "🔒 Промокод", CallbackData: fmt.Sprintf("admin:unlock_code:step:%d", stepID)},
+	})
+
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "🏷 Теги", CallbackData: fmt.Sprintf("admin:step_tags:%d", stepID)},
+	})
+
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "🗒 Заметка автора", CallbackData: fmt.Sprintf("admin:author_note:%d", stepID)},
+	})
+
 	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
 		{Text: "💡 Подсказка", CallbackData: fmt.Sprintf("admin:hint:%d", stepID)},
 	})
@@ -505,6 +921,153 @@ func (h *AdminHandler) startEditStep(ctx context.Context, chatID int64, messageI
 	h.editOrSend(ctx, chatID, messageID, html.EscapeString(sb.String()), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
 }
 
+// answerTypes перечисляет типы ответа в порядке кнопок выбора
+var answerTypes = []models.AnswerType{
+	models.AnswerTypeText,
+	models.AnswerTypeImage,
+	models.AnswerTypeLocation,
+	models.AnswerTypeChoice,
+	models.AnswerTypeLink,
+}
+
+func answerTypeLabel(answerType models.AnswerType) string {
+	switch answerType {
+	case models.AnswerTypeText:
+		return "📝 Текст"
+	case models.AnswerTypeImage:
+		return "📷 Изображение"
+	case models.AnswerTypeLocation:
+		return "📍 Геопозиция"
+	case models.AnswerTypeChoice:
+		return "🔘 Выбор"
+	case models.AnswerTypeLink:
+		return "🔗 Ссылка"
+	}
+	return string(answerType)
+}
+
+// answerTypeChangeWarning описывает, как смена типа ответа затронет участников шага.
+// Пустая строка — шаг ещё никто не начинал, и тип можно менять без подтверждения
+func answerTypeChangeWarning(from, to models.AnswerType, completedProgress bool, usersOnStep int) string {
+	var warnings []string
+	if completedProgress {
+		warnings = append(warnings, fmt.Sprintf("Шаг уже пройден участниками с ответом типа «%s». Их прогресс сохранится, но ответы не будут перепроверены.", answerTypeLabel(from)))
+	}
+	if usersOnStep > 0 {
+		warnings = append(warnings, fmt.Sprintf("Сейчас на шаге: %d. Ответ типа «%s» больше не будет приниматься, им придётся ответить заново — «%s».", usersOnStep, answerTypeLabel(from), answerTypeLabel(to)))
+	}
+	if len(warnings) == 0 {
+		return ""
+	}
+	return "⚠️ " + strings.Join(warnings, "\n\n⚠️ ")
+}
+
+// answerTypeSetupNote напоминает, что ещё нужно настроить для нового типа ответа
+func answerTypeSetupNote(step *models.Step, to models.AnswerType) string {
+	switch to {
+	case models.AnswerTypeLocation:
+		if step.LocationRadius <= 0 {
+			return "📍 Задайте цель геопозиции, иначе ответы будут уходить на ручную проверку"
+		}
+	case models.AnswerTypeChoice:
+		if len(step.Choices) == 0 {
+			return "🔘 Добавьте варианты выбора, иначе участнику нечего будет выбрать"
+		}
+	case models.AnswerTypeText:
+		if len(step.Answers) == 0 {
+			return "📝 Добавьте варианты ответов, иначе ответы будут уходить на ручную проверку"
+		}
+	}
+	return ""
+}
+
+func (h *AdminHandler) showChangeAnswerType(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:change_type:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Шаг не найден", nil)
+		return
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for _, answerType := range answerTypes {
+		if answerType == step.AnswerType {
+			continue
+		}
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: answerTypeLabel(answerType), CallbackData: fmt.Sprintf("admin:set_type:%d:%s", stepID, answerType)},
+		})
+	}
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад", CallbackData: fmt.Sprintf("admin:edit_step:%d", stepID)},
+	})
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("🔁 Шаг %d\n\nТекущий тип ответа: %s\n\nВыберите новый тип:", step.StepOrder, answerTypeLabel(step.AnswerType)), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+// changeAnswerType меняет тип ответа шага. Если смена затронет участников, сначала
+// показывается предупреждение, и тип меняется только после подтверждения
+func (h *AdminHandler) changeAnswerType(ctx context.Context, chatID int64, messageID int, payload string, confirmed bool) {
+	idPart, typePart, ok := strings.Cut(payload, ":")
+	stepID, _ := parseInt64(idPart)
+	if !ok || stepID == 0 {
+		return
+	}
+
+	to := models.AnswerType(typePart)
+	if !slices.Contains(answerTypes, to) {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Неизвестный тип ответа", nil)
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Шаг не найден", nil)
+		return
+	}
+
+	backKeyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "⬅️ К шагу", CallbackData: fmt.Sprintf("admin:edit_step:%d", stepID)}},
+		},
+	}
+
+	if step.AnswerType == to {
+		h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("ℹ️ У шага уже тип ответа «%s»", answerTypeLabel(to)), backKeyboard)
+		return
+	}
+
+	if !confirmed {
+		completedProgress, _ := h.stepRepo.HasCompletedProgress(stepID)
+		usersOnStep, _ := h.progressRepo.CountUsersOnStep(stepID)
+		if warning := answerTypeChangeWarning(step.AnswerType, to, completedProgress, usersOnStep); warning != "" {
+			keyboard := &tgmodels.InlineKeyboardMarkup{
+				InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+					{{Text: "✅ Сменить тип", CallbackData: fmt.Sprintf("admin:set_type_ok:%d:%s", stepID, to)}},
+					{{Text: "⬅️ Отмена", CallbackData: fmt.Sprintf("admin:edit_step:%d", stepID)}},
+				},
+			}
+			h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("🔁 Шаг %d: %s → %s\n\n%s", step.StepOrder, answerTypeLabel(step.AnswerType), answerTypeLabel(to), html.EscapeString(warning)), keyboard)
+			return
+		}
+	}
+
+	if err := h.stepRepo.UpdateAnswerType(stepID, to); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при смене типа ответа", nil)
+		return
+	}
+
+	text := fmt.Sprintf("✅ Тип ответа шага %d изменён: %s", step.StepOrder, answerTypeLabel(to))
+	if note := answerTypeSetupNote(step, to); note != "" {
+		text += "\n\n" + note
+	}
+	h.editOrSend(ctx, chatID, messageID, text, backKeyboard)
+}
+
 func (h *AdminHandler) deleteStep(ctx context.Context, chatID int64, messageID int, data string) {
 	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:delete_step:"))
 	if stepID == 0 {
@@ -560,8 +1123,10 @@ func (h *AdminHandler) toggleAsterisk(ctx context.Context, chatID int64, message
 	h.startEditStep(ctx, chatID, messageID, fmt.Sprintf("admin:edit_step:%d", stepID))
 }
 
-func (h *AdminHandler) showAnswersMenu(ctx context.Context, chatID int64, messageID int, data string) {
-	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:answers:"))
+// toggleRequireReply включает режим, в котором ответ на шаг засчитывается только
+// если участник ответил реплаем на сообщение с заданием
+func (h *AdminHandler) toggleRequireReply(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:require_reply:"))
 	if stepID == 0 {
 		return
 	}
@@ -571,103 +1136,2782 @@ func (h *AdminHandler) showAnswersMenu(ctx context.Context, chatID int64, messag
 		return
 	}
 
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("📝 Варианты ответов для шага %d:\n\n", step.StepOrder))
+	if err := h.stepRepo.SetRequireReply(stepID, !step.RequireReply); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при изменении режима ответа реплаем", nil)
+		return
+	}
 
-	if len(step.Answers) == 0 {
-		sb.WriteString("Вариантов пока нет")
-	} else {
-		for i, ans := range step.Answers {
-			sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, html.EscapeString(ans)))
-		}
+	h.startEditStep(ctx, chatID, messageID, fmt.Sprintf("admin:edit_step:%d", stepID))
+}
+
+func requireReplyLabel(step *models.Step) string {
+	if step.RequireReply {
+		return "вкл"
 	}
+	return "выкл"
+}
 
-	buttons := [][]tgmodels.InlineKeyboardButton{
-		{{Text: "➕ Добавить вариант", CallbackData: fmt.Sprintf("admin:add_answer:%d", stepID)}},
+func (h *AdminHandler) toggleNumericFeedback(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:numeric_feedback:"))
+	if stepID == 0 {
+		return
 	}
 
-	if len(step.Answers) > 0 {
-		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
-			{Text: "🗑️ Удалить вариант", CallbackData: fmt.Sprintf("admin:del_answer:%d", stepID)},
-		})
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
 	}
 
-	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
-		{Text: "⬅️ Назад", CallbackData: fmt.Sprintf("admin:edit_step:%d", stepID)},
-	})
+	if err := h.stepRepo.SetNumericFeedback(stepID, !step.NumericFeedback); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при изменении подсказки «больше/меньше»", nil)
+		return
+	}
 
-	h.editOrSend(ctx, chatID, messageID, sb.String(), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+	h.showAnswersMenu(ctx, chatID, messageID, fmt.Sprintf("admin:answers:%d", stepID))
 }
 
-func (h *AdminHandler) startAddAnswer(ctx context.Context, chatID int64, messageID int, data string) {
-	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:add_answer:"))
+func (h *AdminHandler) toggleOrderedAnswers(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:ordered_answers:"))
 	if stepID == 0 {
 		return
 	}
 
-	state := &models.AdminState{
-		UserID:        h.adminID,
-		CurrentState:  fsm.StateAdminAddAnswer,
-		EditingStepID: stepID,
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	if err := h.stepRepo.SetOrderedAnswers(stepID, !step.OrderedAnswers); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при изменении режима последовательности", nil)
+		return
 	}
-	h.adminStateRepo.Save(state)
 
-	h.editOrSend(ctx, chatID, messageID, "📝 Введите новый вариант ответа:\n\n/cancel - отмена", nil)
+	h.showAnswersMenu(ctx, chatID, messageID, fmt.Sprintf("admin:answers:%d", stepID))
 }
 
-func (h *AdminHandler) startDeleteAnswer(ctx context.Context, chatID int64, messageID int, data string) {
-	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:del_answer:"))
+func (h *AdminHandler) toggleUseSynonyms(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:use_synonyms:"))
 	if stepID == 0 {
 		return
 	}
 
 	step, err := h.stepRepo.GetByID(stepID)
-	if err != nil || step == nil || len(step.Answers) == 0 {
+	if err != nil || step == nil {
 		return
 	}
 
-	state := &models.AdminState{
-		UserID:        h.adminID,
-		CurrentState:  fsm.StateAdminDeleteAnswer,
-		EditingStepID: stepID,
+	if err := h.stepRepo.SetUseSynonyms(stepID, !step.UseSynonyms); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при изменении проверки синонимов", nil)
+		return
+	}
+
+	h.showAnswersMenu(ctx, chatID, messageID, fmt.Sprintf("admin:answers:%d", stepID))
+}
+
+func useSynonymsLabel(step *models.Step) string {
+	if step.UseSynonyms {
+		return "вкл"
+	}
+	return "выкл"
+}
+
+// showSynonymsMenu показывает общий словарь синонимов ответов. Синонимы применяются
+// только на шагах, где они включены в меню вариантов ответа
+func (h *AdminHandler) showSynonymsMenu(ctx context.Context, chatID int64, messageID int) {
+	groups, err := h.answerRepo.GetSynonymGroups()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении синонимов", nil)
+		return
 	}
-	h.adminStateRepo.Save(state)
 
 	var sb strings.Builder
-	sb.WriteString("🗑️ Введите номер варианта для удаления:\n\n")
-	for i, ans := range step.Answers {
-		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, ans))
+	sb.WriteString("🔤 <b>Синонимы ответов</b>\n\nОтвет-синоним засчитывается как каноническая форма на шагах, где включены синонимы.\n\n")
+	if len(groups) == 0 {
+		sb.WriteString("Словарь пуст")
 	}
-	sb.WriteString("\n/cancel - отмена")
 
-	h.editOrSend(ctx, chatID, messageID, sb.String(), nil)
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for i, group := range groups {
+		sb.WriteString(fmt.Sprintf("%d. <b>%s</b> ← %s\n", i+1, html.EscapeString(group.Canonical), html.EscapeString(strings.Join(group.Synonyms, ", "))))
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: fmt.Sprintf("🗑️ %d. %s", i+1, group.Canonical), CallbackData: fmt.Sprintf("admin:synonym_del:%d", group.ID)},
+		})
+	}
+
+	buttons = append(buttons,
+		[]tgmodels.InlineKeyboardButton{{Text: "➕ Добавить группу", CallbackData: "admin:synonym_add"}},
+		[]tgmodels.InlineKeyboardButton{{Text: "⬅️ Назад", CallbackData: "admin:settings"}},
+	)
+
+	h.editOrSend(ctx, chatID, messageID, sb.String(), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+// showAnswerLanguagesMenu показывает языки, которыми помечены варианты ответов, и сообщения
+// о правильном ответе на каждом из них. Без своего сообщения используется общее
+func (h *AdminHandler) showAnswerLanguagesMenu(ctx context.Context, chatID int64, messageID int) {
+	languages, err := h.answerRepo.GetAnswerLanguages()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении языков", nil)
+		return
+	}
+	settings, _ := h.settingsRepo.GetAll()
+
+	var sb strings.Builder
+	sb.WriteString("🌐 <b>Языки ответов</b>\n\nВариант ответа помечается языком при добавлении: <code>[en] apple</code>. Участник, ответивший на этом языке, получит сообщение о правильном ответе на нём.\n\n")
+	if len(languages) == 0 {
+		sb.WriteString("Вариантов с языком пока нет")
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for _, language := range languages {
+		message := "общее сообщение"
+		if settings != nil && settings.CorrectAnswerMessages[language] != "" {
+			message = html.EscapeString(truncateText(settings.CorrectAnswerMessages[language], 50))
+		}
+		sb.WriteString(fmt.Sprintf("• <b>%s</b>: %s\n", language, message))
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: fmt.Sprintf("✅ Правильный ответ [%s]", language), CallbackData: "admin:edit_setting:" + models.CorrectAnswerMessagePrefix + language},
+		})
+	}
+
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{{Text: "⬅️ Назад", CallbackData: "admin:settings"}})
+
+	h.editOrSend(ctx, chatID, messageID, sb.String(), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+func (h *AdminHandler) startAddSynonymGroup(ctx context.Context, chatID int64, messageID int) {
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminAddSynonymGroup,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, "📝 Введите каноническую форму и синонимы через «=», например:\nсанкт-петербург = питер, спб\n\nЕсли группа уже есть, синонимы добавятся к ней.\n\n/cancel - отмена", nil)
+}
+
+// parseSynonymGroup разбирает строку «каноническая форма = синоним, синоним»
+func parseSynonymGroup(text string) (string, []string, bool) {
+	canonical, list, ok := strings.Cut(text, "=")
+	canonical = strings.ToLower(strings.TrimSpace(canonical))
+	if !ok || canonical == "" {
+		return "", nil, false
+	}
+
+	var synonyms []string
+	for _, synonym := range strings.Split(list, ",") {
+		if synonym = strings.ToLower(strings.TrimSpace(synonym)); synonym != "" && synonym != canonical {
+			synonyms = append(synonyms, synonym)
+		}
+	}
+	return canonical, synonyms, len(synonyms) > 0
+}
+
+func (h *AdminHandler) handleAddSynonymGroup(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	canonical, synonyms, ok := parseSynonymGroup(msg.Text)
+	if !ok {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите группу в формате: санкт-петербург = питер, спб",
+		})
+		return true
+	}
+
+	if err := h.answerRepo.AddSynonyms(canonical, synonyms); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении синонимов",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   fmt.Sprintf("✅ Синонимы для «%s»: %s", canonical, strings.Join(synonyms, ", ")),
+	})
+	h.showSynonymsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func (h *AdminHandler) deleteSynonymGroup(ctx context.Context, chatID int64, messageID int, data string) {
+	id, _ := parseInt64(strings.TrimPrefix(data, "admin:synonym_del:"))
+	if id == 0 {
+		return
+	}
+
+	if err := h.answerRepo.DeleteSynonymGroup(id); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при удалении синонимов", nil)
+		return
+	}
+
+	h.showSynonymsMenu(ctx, chatID, messageID)
+}
+
+func orderedAnswersLabel(step *models.Step) string {
+	if step.OrderedAnswers {
+		return "вкл"
+	}
+	return "выкл"
+}
+
+func numericFeedbackLabel(step *models.Step) string {
+	if step.NumericFeedback {
+		return "вкл"
+	}
+	return "выкл"
+}
+
+func locationTargetLabel(step *models.Step) string {
+	if step.LocationRadius <= 0 {
+		return "не задана (ручная проверка)"
+	}
+	return fmt.Sprintf("%.6f, %.6f, радиус %d м", step.LocationLat, step.LocationLng, step.LocationRadius)
+}
+
+func (h *AdminHandler) startEditLocationTarget(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:location_target:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminEditLocationTarget,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📍 Отправьте геопозицию цели для шага %d или введите координаты и радиус в метрах (например: 55.7539, 37.6208 100):\n\nТекущая цель: %s\n\n/cancel - отмена", step.StepOrder, locationTargetLabel(step)), nil)
+}
+
+func (h *AdminHandler) handleEditLocationTarget(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	step, err := h.stepRepo.GetByID(state.EditingStepID)
+	if err != nil || step == nil {
+		return false
+	}
+
+	var lat, lng float64
+	var radius int
+	switch {
+	case msg.Location != nil:
+		lat, lng = msg.Location.Latitude, msg.Location.Longitude
+		radius = step.LocationRadius
+		if radius <= 0 {
+			radius = services.DefaultLocationRadius
+		}
+	case msg.Text != "":
+		lat, lng, radius, err = services.ParseLocationTarget(msg.Text)
+		if err != nil {
+			h.bot.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: msg.Chat.ID,
+				Text:   "⚠️ Введите широту, долготу и радиус в метрах, например: 55.7539, 37.6208 100",
+			})
+			return true
+		}
+	default:
+		return false
+	}
+
+	if err := h.stepRepo.UpdateLocationTarget(step.ID, lat, lng, radius); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении цели",
+		})
+		return true
+	}
+
+	if !step.HasAutoCheck {
+		step.HasAutoCheck = true
+		h.stepRepo.Update(step)
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   fmt.Sprintf("✅ Цель шага обновлена: %.6f, %.6f, радиус %d м", lat, lng, radius),
+	})
+	h.startEditStep(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:edit_step:%d", step.ID))
+	return true
+}
+
+func linkDomainLabel(step *models.Step) string {
+	if step.LinkDomain == "" {
+		return "любой"
+	}
+	return step.LinkDomain
+}
+
+func (h *AdminHandler) startEditLinkDomain(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:link_domain:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminEditLinkDomain,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("🔗 Введите домен, на который должна вести ссылка в ответе шага %d, например: github.com (0 — любой сайт):\n\nПоддомены тоже подходят. Текущий домен: %s\n\n/cancel - отмена", step.StepOrder, linkDomainLabel(step)), nil)
+}
+
+func (h *AdminHandler) handleEditLinkDomain(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	var domain string
+	if text := strings.TrimSpace(msg.Text); text != "0" {
+		var err error
+		if domain, err = services.NormalizeLinkDomain(text); err != nil {
+			h.bot.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: msg.Chat.ID,
+				Text:   "⚠️ Введите домен, например: github.com",
+			})
+			return true
+		}
+	}
+
+	if err := h.stepRepo.UpdateLinkDomain(state.EditingStepID, domain); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении домена",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Домен ссылки: " + linkDomainLabel(&models.Step{LinkDomain: domain}),
+	})
+	h.startEditStep(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:edit_step:%d", state.EditingStepID))
+	return true
+}
+
+func imageConstraintsLabel(step *models.Step) string {
+	constraints, err := services.ParseImageConstraints(step.ImageConstraints)
+	if err != nil || constraints.IsEmpty() {
+		return "любой"
+	}
+	return services.FormatImageConstraints(constraints)
+}
+
+func (h *AdminHandler) startEditImageConstraints(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:image_constraints:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminEditImageConstraints,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📐 Введите ограничения на фото в ответе шага %d через запятую, например: мин 800x600, макс 4000x4000, 4:3 (0 — без ограничений):\n\nЛюбую часть можно опустить, 0 вместо стороны снимает ограничение по ней. Текущие: %s\n\n/cancel - отмена", step.StepOrder, imageConstraintsLabel(step)), nil)
+}
+
+func (h *AdminHandler) handleEditImageConstraints(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	var constraints services.ImageConstraints
+	if text := strings.TrimSpace(msg.Text); text != "0" {
+		var err error
+		if constraints, err = services.ParseImageConstraints(text); err != nil || constraints.IsEmpty() {
+			h.bot.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: msg.Chat.ID,
+				Text:   "⚠️ Введите ограничения, например: мин 800x600, макс 4000x4000, 4:3",
+			})
+			return true
+		}
+	}
+
+	if err := h.stepRepo.UpdateImageConstraints(state.EditingStepID, constraints.String()); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении ограничений",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Размер фото: " + imageConstraintsLabel(&models.Step{ImageConstraints: constraints.String()}),
+	})
+	h.startEditStep(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:edit_step:%d", state.EditingStepID))
+	return true
+}
+
+// startEditAuthorNote запрашивает внутреннюю заметку автора к шагу: источник загадки,
+// ожидаемую сложность и т.п. Участники заметку не видят
+func (h *AdminHandler) startEditAuthorNote(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:author_note:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminEditAuthorNote,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	current := "нет"
+	if note, err := h.stepRepo.GetAuthorNote(stepID); err == nil && note != "" {
+		current = html.EscapeString(note)
+	}
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("🗒 Введите заметку автора к шагу %d, например источник загадки или ожидаемую сложность (- — удалить заметку):\n\nЗаметку видят только администраторы, участникам она не показывается. Текущая: %s\n\n/cancel - отмена", step.StepOrder, current), nil)
+}
+
+func (h *AdminHandler) handleEditAuthorNote(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	note := strings.TrimSpace(msg.Text)
+	if note == "-" {
+		note = ""
+	}
+
+	if err := h.stepRepo.SetAuthorNote(state.EditingStepID, note); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении заметки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	text := "✅ Заметка сохранена"
+	if note == "" {
+		text = "✅ Заметка удалена"
+	}
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   text,
+	})
+	h.startEditStep(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:edit_step:%d", state.EditingStepID))
+	return true
+}
+
+// startEditUnlockCode запрашивает промокод для шага или главы. Цель хранится
+// в EditingSetting в виде "step:<id>" или "chapter:<id>"
+func (h *AdminHandler) startEditUnlockCode(ctx context.Context, chatID int64, messageID int, data string) {
+	if h.unlockService == nil {
+		return
+	}
+	target := strings.TrimPrefix(data, "admin:unlock_code:")
+	kind, rawID, _ := strings.Cut(target, ":")
+	id, _ := parseInt64(rawID)
+	if id == 0 {
+		return
+	}
+
+	var prompt, current string
+	switch kind {
+	case "step":
+		step, err := h.stepRepo.GetByID(id)
+		if err != nil || step == nil {
+			return
+		}
+		current, _ = h.unlockService.GetStepCode(id)
+		prompt = fmt.Sprintf("🔒 Введите промокод, без которого шаг %d не откроется (0 — убрать промокод):", step.StepOrder)
+	case "chapter":
+		current, _ = h.unlockService.GetChapterCode(id)
+		prompt = "🔒 Введите промокод, без которого не откроются шаги главы (0 — убрать промокод):"
+	default:
+		return
+	}
+	if current == "" {
+		current = "нет"
+	}
+
+	state := &models.AdminState{
+		UserID:         h.adminID,
+		CurrentState:   fsm.StateAdminEditUnlockCode,
+		EditingSetting: target,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("%s\n\nУчастник вводит его командой /code. Регистр не важен. Текущий промокод: %s\n\n/cancel - отмена", prompt, html.EscapeString(current)), nil)
+}
+
+func (h *AdminHandler) handleEditUnlockCode(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	code := strings.TrimSpace(msg.Text)
+	if code == "0" {
+		code = ""
+	}
+
+	kind, rawID, _ := strings.Cut(state.EditingSetting, ":")
+	id, _ := parseInt64(rawID)
+
+	var err error
+	if kind == "chapter" {
+		err = h.unlockService.SetChapterCode(id, code)
+	} else {
+		err = h.unlockService.SetStepCode(id, code)
+	}
+	if errors.Is(err, services.ErrUnlockCodeTaken) {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Этот промокод уже открывает другой шаг или главу. Введите другой",
+		})
+		return true
+	}
+	if err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении промокода",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	text := "✅ Промокод убран"
+	if code != "" {
+		text = "✅ Промокод сохранён: " + services.NormalizeUnlockCode(code)
+	}
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   text,
+	})
+
+	if kind == "chapter" {
+		h.showChaptersMenu(ctx, msg.Chat.ID, 0)
+	} else {
+		h.startEditStep(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:edit_step:%d", id))
+	}
+	return true
+}
+
+func (h *AdminHandler) showStepTagsMenu(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:step_tags:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	tags, err := h.stepRepo.GetTags(stepID)
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении тегов", nil)
+		return
+	}
+
+	text := fmt.Sprintf("🏷 Теги шага %d:\n\n", step.StepOrder)
+	if len(tags) == 0 {
+		text += "Тегов пока нет"
+	} else {
+		text += html.EscapeString(services.FormatStepTags(tags)) + "\n\nНажмите на тег, чтобы удалить его"
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for i, tag := range tags {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "❌ #" + tag, CallbackData: fmt.Sprintf("admin:step_tag_del:%d:%d", stepID, i)},
+		})
+	}
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "➕ Добавить", CallbackData: fmt.Sprintf("admin:step_tag_add:%d", stepID)},
+	})
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад", CallbackData: fmt.Sprintf("admin:edit_step:%d", stepID)},
+	})
+
+	h.editOrSend(ctx, chatID, messageID, text, &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+func (h *AdminHandler) startAddStepTag(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:step_tag_add:"))
+	if stepID == 0 {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminAddStepTag,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, "🏷 Введите теги через пробел или запятую, например: лес финал\n\n/cancel - отмена", nil)
+}
+
+func (h *AdminHandler) handleAddStepTag(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	tags := services.ParseStepTags(msg.Text)
+	if len(tags) == 0 {
+		return false
+	}
+
+	for _, tag := range tags {
+		if err := h.stepRepo.AddTag(state.EditingStepID, tag); err != nil {
+			log.Printf("[ADMIN] Error adding tag %q to step %d: %v", tag, state.EditingStepID, err)
+			h.bot.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: msg.Chat.ID,
+				Text:   "⚠️ Ошибка при сохранении тегов",
+			})
+			return true
+		}
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Теги добавлены: " + services.FormatStepTags(tags),
+	})
+	h.showStepTagsMenu(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:step_tags:%d", state.EditingStepID))
+	return true
+}
+
+// removeStepTag удаляет тег по его номеру в отсортированном списке тегов шага
+func (h *AdminHandler) removeStepTag(ctx context.Context, chatID int64, messageID int, data string) {
+	rawStepID, rawIndex, _ := strings.Cut(strings.TrimPrefix(data, "admin:step_tag_del:"), ":")
+	stepID, _ := parseInt64(rawStepID)
+	index, err := parseInt64(rawIndex)
+	if stepID == 0 || err != nil {
+		return
+	}
+
+	tags, err := h.stepRepo.GetTags(stepID)
+	if err == nil && index >= 0 && index < int64(len(tags)) {
+		if err := h.stepRepo.RemoveTag(stepID, tags[index]); err != nil {
+			log.Printf("[ADMIN] Error removing tag %q from step %d: %v", tags[index], stepID, err)
+		}
+	}
+
+	h.showStepTagsMenu(ctx, chatID, messageID, fmt.Sprintf("admin:step_tags:%d", stepID))
+}
+
+func (h *AdminHandler) showAnswersMenu(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:answers:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 Варианты ответов для шага %d:\n\n", step.StepOrder))
+
+	if len(step.Answers) == 0 {
+		sb.WriteString("Вариантов пока нет")
+	} else {
+		for i, ans := range step.Answers {
+			sb.WriteString(fmt.Sprintf("%d. %s", i+1, html.EscapeString(ans)))
+			if language := step.AnswerLanguages[ans]; language != "" {
+				sb.WriteString(fmt.Sprintf(" [%s]", language))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	if step.RequiredAnswers > 0 {
+		sb.WriteString(fmt.Sprintf("\n🧩 Ответ-перечисление: %s", requiredAnswersLabel(step)))
+	}
+	if step.NumericFeedback {
+		sb.WriteString("\n🔢 Подсказка «больше/меньше» для числового ответа включена")
+	}
+	if step.OrderedAnswers {
+		sb.WriteString("\n🔗 Последовательность: участник отправляет варианты по одному в этом порядке")
+	}
+	if step.UseSynonyms {
+		sb.WriteString("\n🔤 Ответ проверяется с учётом общего словаря синонимов")
+	}
+
+	buttons := [][]tgmodels.InlineKeyboardButton{
+		{{Text: "➕ Добавить вариант", CallbackData: fmt.Sprintf("admin:add_answer:%d", stepID)}},
+	}
+
+	if len(step.Answers) > 0 {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🗑️ Удалить вариант", CallbackData: fmt.Sprintf("admin:del_answer:%d", stepID)},
+		})
+	}
+
+	if len(step.Answers) > 1 && !step.OrderedAnswers {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🧩 Перечисление: " + requiredAnswersLabel(step), CallbackData: fmt.Sprintf("admin:required_answers:%d", stepID)},
+		})
+	}
+
+	if len(step.Answers) > 1 && step.RequiredAnswers == 0 {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🔗 По порядку: " + orderedAnswersLabel(step), CallbackData: fmt.Sprintf("admin:ordered_answers:%d", stepID)},
+		})
+	}
+
+	if len(step.Answers) > 0 && step.RequiredAnswers == 0 && !step.OrderedAnswers {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🔢 Больше/меньше: " + numericFeedbackLabel(step), CallbackData: fmt.Sprintf("admin:numeric_feedback:%d", stepID)},
+		})
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🔤 Синонимы: " + useSynonymsLabel(step), CallbackData: fmt.Sprintf("admin:use_synonyms:%d", stepID)},
+		})
+	}
+
+	buttons = append(buttons, answerMoveButtons(stepID, len(step.Answers))...)
+
+	if len(step.Answers) > 0 {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🧪 Проверить ответ", CallbackData: fmt.Sprintf("admin:test_answer:%d", stepID)},
+		})
+	}
+
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад", CallbackData: fmt.Sprintf("admin:edit_step:%d", stepID)},
+	})
+
+	h.editOrSend(ctx, chatID, messageID, sb.String(), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+// startTestAnswer включает проверку ответов на шаге: администратор присылает варианты ответа
+// и видит, засчитал бы их бот, а при включённой подробной проверке — разбор сравнения
+func (h *AdminHandler) startTestAnswer(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:test_answer:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminTestAnswer,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("🧪 Отправляйте ответы для шага %d — бот покажет, засчитал бы он их. Прогресс участников не меняется.\n\n/cancel - закончить проверку", step.StepOrder), nil)
+}
+
+func (h *AdminHandler) handleTestAnswer(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	step, err := h.stepRepo.GetByID(state.EditingStepID)
+	if err != nil || step == nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Шаг не найден",
+		})
+		return true
+	}
+
+	// Элемент последовательности проверяется относительно позиции участника, которой
+	// у проверки нет, поэтому такие шаги разбираются как обычный текстовый ответ
+	checker := services.NewAnswerChecker(h.answerRepo, h.progressRepo, h.userRepo, h.settingsRepo)
+	var result *services.CheckResult
+	switch {
+	case step.OrderedAnswers:
+		result, err = checker.CheckTextAnswerWithTrace(step.ID, msg.Text)
+	case step.RequiredAnswers > 0:
+		result, err = checker.CheckSetAnswerWithTrace(step, msg.Text)
+	case step.NumericFeedback:
+		result, err = checker.CheckNumericAnswerWithTrace(step, msg.Text)
+	default:
+		result, err = checker.CheckTextAnswerWithTrace(step.ID, msg.Text)
+	}
+	if err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при проверке ответа",
+		})
+		return true
+	}
+
+	text := "❌ Ответ не был бы засчитан"
+	if result.IsCorrect {
+		text = "✅ Ответ был бы засчитан"
+	}
+	if practiceTrace, _ := h.settingsRepo.GetPracticeTrace(); practiceTrace {
+		text = formatPracticeTrace(result)
+	} else if verbose, _ := h.settingsRepo.GetVerboseMatching(); verbose {
+		text = services.FormatMatchTrace(result.Trace, result.IsCorrect)
+	}
+	if step.OrderedAnswers {
+		text += "\n\nℹ️ Шаг с ответами по порядку: проверка сравнивает ответ со всеми элементами сразу, а участнику каждый элемент засчитывается только в свою очередь"
+	}
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    msg.Chat.ID,
+		Text:      text,
+		ParseMode: tgmodels.ParseModeHTML,
+	})
+	return true
+}
+
+// formatPracticeTrace показывает администратору, что он ввёл в проверке ответа и как ответ
+// сопоставился с вариантами шага: разбор сравнения, правило совпадения и язык варианта
+func formatPracticeTrace(result *services.CheckResult) string {
+	var sb strings.Builder
+	sb.WriteString(services.FormatMatchTrace(result.Trace, result.IsCorrect))
+	if result.IsCorrect {
+		sb.WriteString(fmt.Sprintf("\n🧪 Правило совпадения: %s", services.MatchKindLabel(result.MatchKind)))
+		if result.Language != "" {
+			sb.WriteString(fmt.Sprintf("\n🌐 Язык варианта: %s", html.EscapeString(result.Language)))
+		}
+	}
+	return sb.String()
+}
+
+func (h *AdminHandler) showChoicesMenu(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:choices:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔘 Варианты выбора для шага %d:\n\n", step.StepOrder))
+
+	if len(step.Choices) == 0 {
+		sb.WriteString("Вариантов пока нет")
+	} else {
+		for i, choice := range step.Choices {
+			mark := "⬜"
+			if choice.IsCorrect {
+				mark = "✅"
+			}
+			sb.WriteString(fmt.Sprintf("%d. %s %s\n", i+1, mark, html.EscapeString(choice.Text)))
+		}
+		sb.WriteString("\nНажмите на вариант, чтобы отметить его правильным")
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for _, choice := range step.Choices {
+		mark := "⬜"
+		if choice.IsCorrect {
+			mark = "✅"
+		}
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: mark + " " + truncateText(choice.Text, 30), CallbackData: fmt.Sprintf("admin:choice_correct:%d", choice.ID)},
+			{Text: "🗑️", CallbackData: fmt.Sprintf("admin:choice_del:%d", choice.ID)},
+		})
+	}
+
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "➕ Добавить вариант", CallbackData: fmt.Sprintf("admin:add_choice:%d", stepID)},
+	})
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад", CallbackData: fmt.Sprintf("admin:edit_step:%d", stepID)},
+	})
+
+	h.editOrSend(ctx, chatID, messageID, sb.String(), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+func (h *AdminHandler) startAddChoice(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:add_choice:"))
+	if stepID == 0 {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminAddChoice,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, "📝 Введите текст нового варианта выбора:\n\n/cancel - отмена", nil)
+}
+
+func (h *AdminHandler) handleAddChoice(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if strings.TrimSpace(msg.Text) == "" {
+		return false
+	}
+
+	if err := h.stepRepo.AddChoice(state.EditingStepID, msg.Text); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при добавлении варианта",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Вариант добавлен",
+	})
+	h.showChoicesMenu(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:choices:%d", state.EditingStepID))
+	return true
+}
+
+func (h *AdminHandler) markCorrectChoice(ctx context.Context, chatID int64, messageID int, data string) {
+	choiceID, _ := parseInt64(strings.TrimPrefix(data, "admin:choice_correct:"))
+	if choiceID == 0 {
+		return
+	}
+
+	choice, err := h.stepRepo.GetChoiceByID(choiceID)
+	if err != nil {
+		return
+	}
+
+	if err := h.stepRepo.SetCorrectChoice(choice.StepID, choiceID); err != nil {
+		log.Printf("[ADMIN] Error marking choice %d as correct: %v", choiceID, err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении варианта", nil)
+		return
+	}
+
+	h.showChoicesMenu(ctx, chatID, messageID, fmt.Sprintf("admin:choices:%d", choice.StepID))
+}
+
+func (h *AdminHandler) deleteChoice(ctx context.Context, chatID int64, messageID int, data string) {
+	choiceID, _ := parseInt64(strings.TrimPrefix(data, "admin:choice_del:"))
+	if choiceID == 0 {
+		return
+	}
+
+	choice, err := h.stepRepo.GetChoiceByID(choiceID)
+	if err != nil {
+		return
+	}
+
+	if err := h.stepRepo.DeleteChoice(choiceID); err != nil {
+		log.Printf("[ADMIN] Error deleting choice %d: %v", choiceID, err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при удалении варианта", nil)
+		return
+	}
+
+	h.showChoicesMenu(ctx, chatID, messageID, fmt.Sprintf("admin:choices:%d", choice.StepID))
+}
+
+func (h *AdminHandler) showDocumentsMenu(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:documents:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📎 Файлы шага %d:\n\n", step.StepOrder))
+
+	if len(step.Documents) == 0 {
+		sb.WriteString("Файлов пока нет")
+	} else {
+		for i, document := range step.Documents {
+			sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, html.EscapeString(documentLabel(document))))
+		}
+		sb.WriteString("\nФайлы отправляются участнику вместе с заданием")
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for i, document := range step.Documents {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: fmt.Sprintf("🗑️ %d. %s", i+1, truncateText(documentLabel(document), 30)), CallbackData: fmt.Sprintf("admin:document_del:%d", document.ID)},
+		})
+	}
+
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "➕ Добавить файл", CallbackData: fmt.Sprintf("admin:add_document:%d", stepID)},
+	})
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад", CallbackData: fmt.Sprintf("admin:edit_step:%d", stepID)},
+	})
+
+	h.editOrSend(ctx, chatID, messageID, sb.String(), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+func documentLabel(document models.StepDocument) string {
+	if document.FileName == "" {
+		return "файл без имени"
+	}
+	return document.FileName
+}
+
+func (h *AdminHandler) startAddDocument(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:add_document:"))
+	if stepID == 0 {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminAddDocument,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, "📎 Отправьте файл, который нужно прикрепить к шагу:\n\n/cancel - отмена", nil)
+}
+
+func (h *AdminHandler) handleAddDocument(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Document == nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Отправьте файл документом",
+		})
+		return true
+	}
+
+	if err := h.stepRepo.AddDocument(state.EditingStepID, msg.Document.FileID, msg.Document.FileName); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при добавлении файла",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Файл добавлен",
+	})
+	h.showDocumentsMenu(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:documents:%d", state.EditingStepID))
+	return true
+}
+
+func (h *AdminHandler) deleteDocument(ctx context.Context, chatID int64, messageID int, data string) {
+	documentID, _ := parseInt64(strings.TrimPrefix(data, "admin:document_del:"))
+	if documentID == 0 {
+		return
+	}
+
+	document, err := h.stepRepo.GetDocumentByID(documentID)
+	if err != nil {
+		return
+	}
+
+	if err := h.stepRepo.DeleteDocument(documentID); err != nil {
+		log.Printf("[ADMIN] Error deleting document %d: %v", documentID, err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при удалении файла", nil)
+		return
+	}
+
+	h.showDocumentsMenu(ctx, chatID, messageID, fmt.Sprintf("admin:documents:%d", document.StepID))
+}
+
+func chapterLabel(number int, chapter models.Chapter) string {
+	return fmt.Sprintf("Глава %d: %s", number, chapter.Title)
+}
+
+func (h *AdminHandler) showStepChapterMenu(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:step_chapter:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	chapters, err := h.stepRepo.GetChapters()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении глав", nil)
+		return
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for i, chapter := range chapters {
+		mark := ""
+		if chapter.ID == step.ChapterID {
+			mark = "✅ "
+		}
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: mark + truncateText(chapterLabel(i+1, chapter), 40), CallbackData: fmt.Sprintf("admin:set_chapter:%d:%d", stepID, chapter.ID)},
+		})
+	}
+
+	noChapter := "🚫 Без главы"
+	if step.ChapterID == 0 {
+		noChapter = "✅ Без главы"
+	}
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: noChapter, CallbackData: fmt.Sprintf("admin:set_chapter:%d:0", stepID)},
+	})
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "➕ Новая глава", CallbackData: fmt.Sprintf("admin:add_chapter:%d", stepID)},
+	})
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад", CallbackData: fmt.Sprintf("admin:edit_step:%d", stepID)},
+	})
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📖 Глава шага %d\n\nВыберите главу, к которой относится шаг:", step.StepOrder), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+func (h *AdminHandler) setStepChapter(ctx context.Context, chatID int64, messageID int, data string) {
+	// data format: "admin:set_chapter:{stepID}:{chapterID}"
+	parts := strings.Split(strings.TrimPrefix(data, "admin:set_chapter:"), ":")
+	if len(parts) != 2 {
+		return
+	}
+	stepID, _ := parseInt64(parts[0])
+	chapterID, err := parseInt64(parts[1])
+	if stepID == 0 || err != nil {
+		return
+	}
+
+	if err := h.stepRepo.SetChapter(stepID, chapterID); err != nil {
+		log.Printf("[ADMIN] Error setting chapter %d for step %d: %v", chapterID, stepID, err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении главы", nil)
+		return
+	}
+
+	h.startEditStep(ctx, chatID, messageID, fmt.Sprintf("admin:edit_step:%d", stepID))
+}
+
+func (h *AdminHandler) startAddChapter(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:add_chapter:"))
+	if stepID == 0 {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminAddChapter,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, "📖 Введите название новой главы, например: Лес\n\nШаг будет добавлен в эту главу\n\n/cancel - отмена", nil)
+}
+
+func (h *AdminHandler) handleAddChapter(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	title := strings.TrimSpace(msg.Text)
+	if title == "" {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Название главы не может быть пустым",
+		})
+		return true
+	}
+
+	chapterID, err := h.stepRepo.CreateChapter(title)
+	if err == nil {
+		err = h.stepRepo.SetChapter(state.EditingStepID, chapterID)
+	}
+	if err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при создании главы",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Глава создана",
+	})
+	h.startEditStep(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:edit_step:%d", state.EditingStepID))
+	return true
+}
+
+// MaxReviewReasonLength ограничивает длину причины отклонения, которую получает участник
+const MaxReviewReasonLength = 500
+
+// startRejectWithReason запрашивает у администратора причину перед отклонением ответа.
+// Сообщение с ответом участника не меняется, чтобы после /cancel его можно было проверить обычным способом.
+// Его идентификатор запоминается, чтобы после отклонения убрать кнопки проверки
+func (h *AdminHandler) startRejectWithReason(ctx context.Context, chatID int64, messageID int, data string) {
+	parts := strings.Split(strings.TrimPrefix(data, "admin:reject_reason:"), ":")
+	if len(parts) != 2 {
+		return
+	}
+	userID, _ := parseInt64(parts[0])
+	stepID, _ := parseInt64(parts[1])
+	if userID == 0 || stepID == 0 {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:          h.adminID,
+		CurrentState:    fsm.StateAdminRejectReason,
+		EditingStepID:   stepID,
+		TargetUserID:    userID,
+		ReviewMessageID: messageID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   "✏️ Введите причину отклонения ответа — участник получит её вместе с сообщением об ошибке\n\n/cancel - отмена",
+	})
+}
+
+func (h *AdminHandler) handleRejectReason(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	reason := strings.TrimSpace(msg.Text)
+	if reason == "" {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Причина не может быть пустой",
+		})
+		return true
+	}
+	reason = truncateText(reason, MaxReviewReasonLength)
+
+	step, err := h.stepRepo.GetByID(state.EditingStepID)
+	if err != nil || step == nil {
+		h.adminStateRepo.Clear(h.adminID)
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Шаг не найден",
+		})
+		return true
+	}
+
+	rejected, err := h.progressRepo.RejectWithReason(state.TargetUserID, step.ID, reason)
+	if err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при отклонении ответа",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	// Пока администратор писал причину, ответ могли одобрить автоматически или проверить кнопками
+	if !rejected {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   fmt.Sprintf("ℹ️ Ответ на шаг %d уже проверен, причина не отправлена", step.StepOrder),
+		})
+		return true
+	}
+
+	// Как при обычном отклонении: кнопки проверки убираются, ответ участника и реакция удаляются
+	if state.ReviewMessageID != 0 {
+		h.bot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+			ChatID:    msg.Chat.ID,
+			MessageID: state.ReviewMessageID,
+		})
+	}
+	if h.msgManager != nil {
+		h.msgManager.DeleteUserAnswerAndReaction(ctx, state.TargetUserID)
+	}
+
+	if _, err := h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    state.TargetUserID,
+		Text:      FormatRejectionWithReason(step.StepOrder, reason),
+		ParseMode: tgmodels.ParseModeHTML,
+	}); err != nil {
+		log.Printf("[ADMIN] Failed to send rejection reason to user %d: %v", state.TargetUserID, err)
+	}
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   fmt.Sprintf("❌ Ответ на шаг %d отклонён, причина отправлена участнику", step.StepOrder),
+	})
+	return true
+}
+
+// FormatRejectionWithReason форматирует сообщение участнику об отклонённом ответе с причиной
+func FormatRejectionWithReason(stepOrder int, reason string) string {
+	return fmt.Sprintf("❌ <b>Ответ на шаг %d не принят</b>\n\n💬 Причина: %s\n\nПопробуйте ещё раз", stepOrder, html.EscapeString(reason))
+}
+
+func (h *AdminHandler) showChaptersMenu(ctx context.Context, chatID int64, messageID int) {
+	chapters, err := h.stepRepo.GetChapters()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении глав", nil)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📖 Главы квеста\n\n")
+	if len(chapters) == 0 {
+		sb.WriteString("Глав пока нет. Главу можно создать в карточке шага")
+	} else {
+		for i, chapter := range chapters {
+			sb.WriteString(html.EscapeString(chapterLabel(i+1, chapter)))
+			if h.unlockService != nil {
+				if code, err := h.unlockService.GetChapterCode(chapter.ID); err == nil && code != "" {
+					sb.WriteString(" 🔒 " + html.EscapeString(code))
+				}
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\nПри удалении главы её шаги остаются в квесте без главы")
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for i, chapter := range chapters {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🗑️ " + truncateText(chapterLabel(i+1, chapter), 40), CallbackData: fmt.Sprintf("admin:chapter_del:%d", chapter.ID)},
+			{Text: "🔒 Промокод", CallbackData: fmt.Sprintf("admin:unlock_code:chapter:%d", chapter.ID)},
+		})
+	}
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад", CallbackData: "admin:list_steps"},
+	})
+
+	h.editOrSend(ctx, chatID, messageID, sb.String(), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+func (h *AdminHandler) deleteChapter(ctx context.Context, chatID int64, messageID int, data string) {
+	chapterID, _ := parseInt64(strings.TrimPrefix(data, "admin:chapter_del:"))
+	if chapterID == 0 {
+		return
+	}
+
+	if err := h.stepRepo.DeleteChapter(chapterID); err != nil {
+		log.Printf("[ADMIN] Error deleting chapter %d: %v", chapterID, err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при удалении главы", nil)
+		return
+	}
+
+	h.showChaptersMenu(ctx, chatID, messageID)
+}
+
+func requiredAnswersLabel(step *models.Step) string {
+	if step.RequiredAnswers <= 0 {
+		return "выкл"
+	}
+	required := step.RequiredAnswers
+	if required > len(step.Answers) {
+		required = len(step.Answers)
+	}
+	if required == len(step.Answers) {
+		return "все"
+	}
+	return fmt.Sprintf("%d из %d", required, len(step.Answers))
+}
+
+func (h *AdminHandler) startEditRequiredAnswers(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:required_answers:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminEditRequiredAnswers,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("🧩 Сколько вариантов из %d участник должен перечислить через запятую?\n\n0 — выключить перечисление (достаточно одного варианта)\n\nТекущее значение: %s\n\n/cancel - отмена", len(step.Answers), requiredAnswersLabel(step)), nil)
+}
+
+func (h *AdminHandler) handleEditRequiredAnswers(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	required, err := parseInt64(strings.TrimSpace(msg.Text))
+	if err != nil || required < 0 {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите неотрицательное число",
+		})
+		return true
+	}
+
+	if err := h.stepRepo.SetRequiredAnswers(state.EditingStepID, int(required)); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Настройка перечисления обновлена",
+	})
+	h.showAnswersMenu(ctx, msg.Chat.ID, 0, fmt.Sprintf("admin:answers:%d", state.EditingStepID))
+	return true
+}
+
+// answerMoveButtons строит кнопки перемещения вариантов ответа вверх и вниз
+func answerMoveButtons(stepID int64, count int) [][]tgmodels.InlineKeyboardButton {
+	if count < 2 {
+		return nil
+	}
+
+	var rows [][]tgmodels.InlineKeyboardButton
+	for i := 0; i < count; i++ {
+		var row []tgmodels.InlineKeyboardButton
+		if i > 0 {
+			row = append(row, tgmodels.InlineKeyboardButton{
+				Text:         fmt.Sprintf("⬆️ %d", i+1),
+				CallbackData: fmt.Sprintf("admin:move_answer:%d:%d:%d", stepID, i, i-1),
+			})
+		}
+		if i < count-1 {
+			row = append(row, tgmodels.InlineKeyboardButton{
+				Text:         fmt.Sprintf("⬇️ %d", i+1),
+				CallbackData: fmt.Sprintf("admin:move_answer:%d:%d:%d", stepID, i, i+1),
+			})
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (h *AdminHandler) moveAnswer(ctx context.Context, chatID int64, messageID int, data string) {
+	parts := strings.Split(strings.TrimPrefix(data, "admin:move_answer:"), ":")
+	if len(parts) != 3 {
+		return
+	}
+
+	stepID, _ := parseInt64(parts[0])
+	from, errFrom := parseInt64(parts[1])
+	to, errTo := parseInt64(parts[2])
+	if stepID == 0 || errFrom != nil || errTo != nil {
+		return
+	}
+
+	if err := h.answerRepo.ReorderStepAnswer(stepID, int(from), int(to)); err != nil {
+		log.Printf("[ADMIN] Error reordering answers for step %d: %v", stepID, err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при изменении порядка вариантов", nil)
+		return
+	}
+
+	h.showAnswersMenu(ctx, chatID, messageID, fmt.Sprintf("admin:answers:%d", stepID))
+}
+
+func (h *AdminHandler) startAddAnswer(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:add_answer:"))
+	if stepID == 0 {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminAddAnswer,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, "📝 Введите новый вариант ответа:\n\nДля двуязычного квеста укажите язык варианта: <code>[en] apple</code>\n\n/cancel - отмена", nil)
+}
+
+func (h *AdminHandler) startDeleteAnswer(ctx context.Context, chatID int64, messageID int, data string) {
+	stepID, _ := parseInt64(strings.TrimPrefix(data, "admin:del_answer:"))
+	if stepID == 0 {
+		return
+	}
+
+	step, err := h.stepRepo.GetByID(stepID)
+	if err != nil || step == nil || len(step.Answers) == 0 {
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:        h.adminID,
+		CurrentState:  fsm.StateAdminDeleteAnswer,
+		EditingStepID: stepID,
+	}
+	h.adminStateRepo.Save(state)
+
+	var sb strings.Builder
+	sb.WriteString("🗑️ Введите номер варианта для удаления:\n\n")
+	for i, ans := range step.Answers {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, ans))
+	}
+	sb.WriteString("\n/cancel - отмена")
+
+	h.editOrSend(ctx, chatID, messageID, sb.String(), nil)
+}
+
+func (h *AdminHandler) showSettingsMenu(ctx context.Context, chatID int64, messageID int) {
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("⚙️ Настройки бота\n\n")
+	sb.WriteString(fmt.Sprintf("👋 Приветствие: %s\n\n", truncateText(settings.WelcomeMessage, 50)))
+	sb.WriteString(fmt.Sprintf("🏁 Финальное сообщение: %s\n\n", truncateText(settings.FinalMessage, 50)))
+	sb.WriteString(fmt.Sprintf("✅ Правильный ответ: %s\n\n", truncateText(settings.CorrectAnswerMessage, 50)))
+	sb.WriteString(fmt.Sprintf("❌ Неправильный ответ: %s", truncateText(settings.WrongAnswerMessage, 50)))
+	if settings.ScoringEnabled {
+		sb.WriteString(fmt.Sprintf("\n\n⭐ Очки: %d за шаг, штраф за подсказку %d", settings.ScorePointsPerStep, settings.ScoreHintPenalty))
+	}
+
+	exportMode, err := h.settingsRepo.GetExportMode()
+	if err != nil {
+		exportMode = exportModeAuto
+	}
+	exportFileThreshold, err := h.settingsRepo.GetExportFileThreshold()
+	if err != nil {
+		exportFileThreshold = defaultExportFileThreshold
+	}
+
+	defaultTimezone, _ := h.settingsRepo.GetDefaultTimezone()
+	autoApproveMinutes, _ := h.settingsRepo.GetAutoApproveMinutes()
+	dailyDigestTime, _ := h.settingsRepo.GetDailyDigestTime()
+	minAnswerLength, _ := h.settingsRepo.GetMinAnswerLength()
+	minCompletionSteps, _ := h.settingsRepo.GetMinCompletionSteps()
+	maxActiveUsers, _ := h.settingsRepo.GetMaxActiveUsers()
+	announceChannelID, _ := h.settingsRepo.GetAnnounceChannelID()
+	announceInterval, _ := h.settingsRepo.GetAnnounceStepInterval()
+	fillerWords, _ := h.settingsRepo.GetAnswerFillerWords()
+	stripAnswerQuotes, _ := h.settingsRepo.GetStripAnswerQuotes()
+	verboseMatching, _ := h.settingsRepo.GetVerboseMatching()
+	practiceTrace, _ := h.settingsRepo.GetPracticeTrace()
+	autoAdvance, _ := h.settingsRepo.GetAutoAdvance()
+	correctImageDelay, _ := h.settingsRepo.GetCorrectImageDelay()
+	stepTypingDelay, _ := h.settingsRepo.GetStepTypingDelay()
+	hintCooldown, _ := h.settingsRepo.GetHintCooldown()
+	questMapEnabled, _ := h.settingsRepo.GetQuestMapEnabled()
+	userAttemptStats, _ := h.settingsRepo.GetUserAttemptStats()
+	completionConfirmation, _ := h.settingsRepo.GetCompletionConfirmation()
+	notifyUniqueAchievements, _ := h.settingsRepo.GetNotifyUniqueAchievements()
+	skipDeactivatedSteps, _ := h.settingsRepo.GetSkipDeactivatedSteps()
+	progressAfterAnswer, _ := h.settingsRepo.GetProgressAfterAnswer()
+	matchRuleLogging, _ := h.settingsRepo.GetMatchRuleLogging()
+	groupCompletionAchievements, _ := h.settingsRepo.GetGroupCompletionAchievements()
+	revealCompletionPosition, _ := h.settingsRepo.GetRevealCompletionPosition()
+	correctAnswerReaction, _ := h.settingsRepo.GetCorrectAnswerReaction()
+	exportAuthorNotes, _ := h.settingsRepo.GetExportAuthorNotes()
+
+	buttons := [][]tgmodels.InlineKeyboardButton{
+		{{Text: "🎮 Состояние квеста", CallbackData: "admin:quest_state"}},
+		{{Text: "🔐 Ограничение участия", CallbackData: "admin:group_restriction"}},
+		{{Text: "👋 Приветствие", CallbackData: "admin:edit_setting:welcome_message"}},
+		{{Text: "🔄 С возвращением", CallbackData: "admin:edit_setting:resume_message"}},
+		{{Text: "📜 Правила: " + rulesLabel(settings.RulesMessage, settings.RulesVersion), CallbackData: "admin:edit_setting:rules_message"}},
+		{{Text: "🏁 Финальное", CallbackData: "admin:edit_setting:final_message"}},
+		{{Text: "✅ Правильный ответ", CallbackData: "admin:edit_setting:correct_answer_message"}},
+		{{Text: "❌ Неправильный ответ", CallbackData: "admin:edit_setting:wrong_answer_message"}},
+		{
+			{Text: "📤 Экспорт: " + exportModeLabel(exportMode), CallbackData: "admin:export_mode"},
+			{Text: fmt.Sprintf("📄 Файлом, если шагов > %d", exportFileThreshold), CallbackData: "admin:export_file_threshold"},
+		},
+		{{Text: "🗒 Заметки авторов в экспорте: " + answerSummaryLabel(exportAuthorNotes), CallbackData: "admin:export_notes_toggle"}},
+		{{Text: "🕒 Часовой пояс: " + timezoneLabel(defaultTimezone), CallbackData: "admin:default_timezone"}},
+		{
+			{Text: "⭐ Очки: " + scoringLabel(settings.ScoringEnabled), CallbackData: "admin:scoring_toggle"},
+			{Text: "✏️ Стоимость", CallbackData: "admin:scoring_values"},
+		},
+		{{Text: "⏰ Автоодобрение: " + autoApproveLabel(autoApproveMinutes), CallbackData: "admin:auto_approve"}},
+		{{Text: "📰 Сводка: " + dailyDigestLabel(dailyDigestTime), CallbackData: "admin:daily_digest"}},
+		{{Text: "✂️ Мин. длина ответа: " + minAnswerLengthLabel(minAnswerLength), CallbackData: "admin:min_answer_length"}},
+		{{Text: "🎯 Шагов для прохождения: " + minCompletionStepsLabel(minCompletionSteps), CallbackData: "admin:min_completion_steps"}},
+		{{Text: "👥 Лимит участников: " + maxActiveUsersLabel(maxActiveUsers), CallbackData: "admin:max_active_users"}},
+		{{Text: "📣 Канал объявлений: " + announceChannelLabel(announceChannelID, announceInterval), CallbackData: "admin:announce_channel"}},
+		{{Text: "🧹 Слова-паразиты: " + fillerWordsLabel(fillerWords), CallbackData: "admin:filler_words"}},
+		{{Text: "« » Без кавычек: " + answerSummaryLabel(stripAnswerQuotes), CallbackData: "admin:strip_quotes_toggle"}},
+		{{Text: "🔤 Синонимы ответов", CallbackData: "admin:synonyms"}},
+		{{Text: "🌐 Языки ответов", CallbackData: "admin:answer_languages"}},
+		{{Text: "🔬 Подробная проверка: " + answerSummaryLabel(verboseMatching), CallbackData: "admin:verbose_matching_toggle"}},
+		{{Text: "🧪 Разбор в проверке ответа: " + answerSummaryLabel(practiceTrace), CallbackData: "admin:practice_trace_toggle"}},
+		{{Text: "🧾 Журнал совпадений: " + answerSummaryLabel(matchRuleLogging), CallbackData: "admin:match_rule_logging_toggle"}},
+		{{Text: "📖 Разбор ответов: " + answerSummaryLabel(settings.AnswerSummaryEnabled), CallbackData: "admin:answer_summary_toggle"}},
+		{{Text: "➡️ Следующий шаг: " + autoAdvanceLabel(autoAdvance), CallbackData: "admin:auto_advance_toggle"}},
+		{{Text: "🖼 Картинка ответа: " + correctImageDelayLabel(correctImageDelay), CallbackData: "admin:correct_image_delay"}},
+		{{Text: "⌨️ «Печатает» перед шагом: " + stepTypingDelayLabel(stepTypingDelay), CallbackData: "admin:step_typing_delay"}},
+		{{Text: "⏳ Пауза подсказок: " + hintCooldownLabel(hintCooldown), CallbackData: "admin:hint_cooldown"}},
+		{{Text: "🗺 Карта квеста: " + answerSummaryLabel(questMapEnabled), CallbackData: "admin:quest_map_toggle"}},
+		{{Text: "🎯 Попытки в карточке участника: " + answerSummaryLabel(userAttemptStats), CallbackData: "admin:attempt_stats_toggle"}},
+		{{Text: "📊 Прогресс после ответа: " + answerSummaryLabel(progressAfterAnswer), CallbackData: "admin:progress_after_answer_toggle"}},
+		{{Text: "🏁 Подтверждение финиша: " + answerSummaryLabel(completionConfirmation), CallbackData: "admin:completion_confirmation_toggle"}},
+		{{Text: "⏭ Пропуск отключённых шагов: " + answerSummaryLabel(skipDeactivatedSteps), CallbackData: "admin:skip_deactivated_toggle"}},
+		{{Text: "🎖️ Уведомлять об уникальных: " + answerSummaryLabel(notifyUniqueAchievements), CallbackData: "admin:unique_claims_toggle"}},
+		{{Text: "🎁 Итоги финиша одним сообщением: " + answerSummaryLabel(groupCompletionAchievements), CallbackData: "admin:group_completion_toggle"}},
+		{{Text: "🏁 Место финиша участнику: " + answerSummaryLabel(revealCompletionPosition), CallbackData: "admin:completion_position_toggle"}},
+		{{Text: "💬 Реакция на верный ответ: " + correctAnswerReactionLabel(correctAnswerReaction), CallbackData: "admin:answer_reaction_cycle"}},
+		{
+			{Text: "📤 Поделиться: " + answerSummaryLabel(settings.ShareEnabled), CallbackData: "admin:share_toggle"},
+			{Text: "✏️ Текст", CallbackData: "admin:edit_setting:share_message"},
+		},
+		{{Text: "⬅️ Назад", CallbackData: "admin:menu"}},
+	}
+
+	h.editOrSend(ctx, chatID, messageID, html.EscapeString(sb.String()), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+func (h *AdminHandler) cycleExportMode(ctx context.Context, chatID int64, messageID int) {
+	mode, err := h.settingsRepo.GetExportMode()
+	if err != nil {
+		mode = exportModeAuto
+	}
+
+	next := exportModeAuto
+	switch mode {
+	case exportModeAuto:
+		next = exportModeInline
+	case exportModeInline:
+		next = exportModeFile
+	}
+
+	if err := h.settingsRepo.SetExportMode(next); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+func exportModeLabel(mode string) string {
+	switch mode {
+	case exportModeInline:
+		return "сообщениями"
+	case exportModeFile:
+		return "файлом"
+	default:
+		return "авто"
+	}
+}
+
+func (h *AdminHandler) startEditExportFileThreshold(ctx context.Context, chatID int64, messageID int) {
+	threshold, err := h.settingsRepo.GetExportFileThreshold()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditExportFileThreshold,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите, сколько шагов можно выгрузить сообщениями. Если шагов больше, автоматический экспорт придёт файлом:\n\nТекущее значение: %d\n\n/cancel - отмена", threshold), nil)
+}
+
+func (h *AdminHandler) handleEditExportFileThreshold(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	threshold, err := parseInt64(strings.TrimSpace(msg.Text))
+	if err != nil || threshold <= 0 {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите положительное число шагов",
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetExportFileThreshold(int(threshold)); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   fmt.Sprintf("✅ Экспорт файлом, если шагов больше %d", threshold),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func scoringLabel(enabled bool) string {
+	if enabled {
+		return "вкл"
+	}
+	return "выкл"
+}
+
+func (h *AdminHandler) toggleScoring(ctx context.Context, chatID int64, messageID int) {
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetScoringEnabled(!settings.ScoringEnabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+func answerSummaryLabel(enabled bool) string {
+	if enabled {
+		return "вкл"
+	}
+	return "выкл"
+}
+
+func (h *AdminHandler) toggleAnswerSummary(ctx context.Context, chatID int64, messageID int) {
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetAnswerSummaryEnabled(!settings.AnswerSummaryEnabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+func autoAdvanceLabel(enabled bool) string {
+	if enabled {
+		return "сразу"
+	}
+	return "по кнопке"
+}
+
+// toggleAutoAdvance переключает выдачу следующего шага: сразу после правильного ответа или по кнопке
+func (h *AdminHandler) toggleAutoAdvance(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetAutoAdvance()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetAutoAdvance(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleShare переключает отправку сообщения «поделиться» со ссылкой-приглашением после прохождения
+func (h *AdminHandler) toggleShare(ctx context.Context, chatID int64, messageID int) {
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetShareEnabled(!settings.ShareEnabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleStripAnswerQuotes переключает снятие обрамляющих кавычек и скобок с ответов
+func (h *AdminHandler) toggleStripAnswerQuotes(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetStripAnswerQuotes()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetStripAnswerQuotes(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleQuestMap включает или выключает команду /map для участников
+func (h *AdminHandler) toggleQuestMap(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetQuestMapEnabled()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetQuestMapEnabled(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleUserAttemptStats включает или выключает сводку попыток в карточке участника
+func (h *AdminHandler) toggleUserAttemptStats(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetUserAttemptStats()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetUserAttemptStats(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleProgressAfterAnswer включает или выключает прогресс-бар в сообщении о правильном ответе
+func (h *AdminHandler) toggleProgressAfterAnswer(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetProgressAfterAnswer()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetProgressAfterAnswer(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleMatchRuleLogging включает или выключает сохранение правила, по которому
+// засчитан ответ участника
+func (h *AdminHandler) toggleMatchRuleLogging(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetMatchRuleLogging()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetMatchRuleLogging(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// correctAnswerReactions — реакции на верный ответ, между которыми переключается настройка.
+// Пустая строка выключает реакцию; Telegram принимает только эмодзи из своего списка
+var correctAnswerReactions = []string{"", "👍", "🔥", "🎉", "👏", "❤"}
+
+func correctAnswerReactionLabel(emoji string) string {
+	if emoji == "" {
+		return "выкл"
+	}
+	return emoji
+}
+
+// cycleCorrectAnswerReaction переключает реакцию на верный ответ на следующую из списка
+func (h *AdminHandler) cycleCorrectAnswerReaction(ctx context.Context, chatID int64, messageID int) {
+	current, err := h.settingsRepo.GetCorrectAnswerReaction()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	next := correctAnswerReactions[(slices.Index(correctAnswerReactions, current)+1)%len(correctAnswerReactions)]
+	if err := h.settingsRepo.SetCorrectAnswerReaction(next); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleExportAuthorNotes включает или выключает заметки авторов шагов в экспорте заданий
+// и конфигурации квеста
+func (h *AdminHandler) toggleExportAuthorNotes(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetExportAuthorNotes()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetExportAuthorNotes(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleRevealCompletionPosition включает или выключает сообщение участнику о его месте
+// в порядке прохождения квеста
+func (h *AdminHandler) toggleRevealCompletionPosition(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetRevealCompletionPosition()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetRevealCompletionPosition(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleGroupCompletionAchievements включает или выключает сбор достижений за финиш
+// в одно финальное сообщение
+func (h *AdminHandler) toggleGroupCompletionAchievements(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetGroupCompletionAchievements()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetGroupCompletionAchievements(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleSkipDeactivatedSteps включает или выключает перевод участников дальше, когда
+// шаг, на котором они остановились, отключён
+func (h *AdminHandler) toggleSkipDeactivatedSteps(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetSkipDeactivatedSteps()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetSkipDeactivatedSteps(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleNotifyUniqueAchievements включает или выключает уведомления администратору
+// о получении уникальных достижений
+func (h *AdminHandler) toggleNotifyUniqueAchievements(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetNotifyUniqueAchievements()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetNotifyUniqueAchievements(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleCompletionConfirmation включает или выключает подтверждение завершения квеста
+// после последнего шага
+func (h *AdminHandler) toggleCompletionConfirmation(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetCompletionConfirmation()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetCompletionConfirmation(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// toggleVerboseMatching переключает разбор проверки ответов для администратора
+func (h *AdminHandler) toggleVerboseMatching(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetVerboseMatching()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetVerboseMatching(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+// togglePracticeTrace переключает разбор сравнения в инструменте проверки ответа
+func (h *AdminHandler) togglePracticeTrace(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetPracticeTrace()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetPracticeTrace(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showSettingsMenu(ctx, chatID, messageID)
+}
+
+func (h *AdminHandler) startEditScoring(ctx context.Context, chatID int64, messageID int) {
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditScoring,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите через пробел очки за шаг и штраф за подсказку (например: 10 5):\n\nТекущее значение: %d %d\n\n/cancel - отмена", settings.ScorePointsPerStep, settings.ScoreHintPenalty), nil)
+}
+
+func (h *AdminHandler) handleEditScoring(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	var pointsPerStep, hintPenalty int
+	if _, err := fmt.Sscanf(msg.Text, "%d %d", &pointsPerStep, &hintPenalty); err != nil || pointsPerStep < 0 || hintPenalty < 0 {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите два неотрицательных числа через пробел, например: 10 5",
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetScoringValues(pointsPerStep, hintPenalty); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Стоимость шагов обновлена",
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func autoApproveLabel(minutes int) string {
+	if minutes <= 0 {
+		return "выкл"
+	}
+	return fmt.Sprintf("%d мин", minutes)
+}
+
+func (h *AdminHandler) startEditAutoApprove(ctx context.Context, chatID int64, messageID int) {
+	minutes, err := h.settingsRepo.GetAutoApproveMinutes()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditAutoApprove,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите, через сколько минут ответ на ручной проверке будет одобрен автоматически (0 — выключить):\n\nТекущее значение: %s\n\n/cancel - отмена", autoApproveLabel(minutes)), nil)
+}
+
+func (h *AdminHandler) handleEditAutoApprove(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	minutes, err := parseInt64(strings.TrimSpace(msg.Text))
+	if err != nil || minutes < 0 {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите неотрицательное число минут",
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetAutoApproveMinutes(int(minutes)); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Автоодобрение: " + autoApproveLabel(int(minutes)),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func minAnswerLengthLabel(length int) string {
+	if length <= 0 {
+		return "выкл"
+	}
+	return fmt.Sprintf("%d", length)
+}
+
+// maxCorrectImageDelay ограничивает задержку картинки правильного ответа, чтобы участник
+// не ждал следующего шага слишком долго
+const maxCorrectImageDelay = 60
+
+// maxStepTypingDelay ограничивает паузу перед шагом: Telegram показывает «печатает…»
+// не дольше пяти секунд после одного запроса
+const maxStepTypingDelay = 5
+
+func rulesLabel(rules string, version int) string {
+	if rules == "" {
+		return "выкл"
+	}
+	return fmt.Sprintf("версия %d", max(version, 1))
+}
+
+func correctImageDelayLabel(seconds int) string {
+	if seconds <= 0 {
+		return "вместе с текстом"
+	}
+	return fmt.Sprintf("через %d с", seconds)
+}
+
+func (h *AdminHandler) startEditCorrectImageDelay(ctx context.Context, chatID int64, messageID int) {
+	seconds, err := h.settingsRepo.GetCorrectImageDelay()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditCorrectImageDelay,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите задержку в секундах, через которую картинка правильного ответа придёт отдельным сообщением после текста «Правильно», чтобы не раскрыть ответ раньше времени (0 — отправлять вместе, не больше %d):\n\nТекущее значение: %s\n\n/cancel - отмена", maxCorrectImageDelay, correctImageDelayLabel(seconds)), nil)
+}
+
+func (h *AdminHandler) handleEditCorrectImageDelay(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	seconds, err := parseInt64(strings.TrimSpace(msg.Text))
+	if err != nil || seconds < 0 || seconds > maxCorrectImageDelay {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   fmt.Sprintf("⚠️ Введите число секунд от 0 до %d", maxCorrectImageDelay),
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetCorrectImageDelay(int(seconds)); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Картинка ответа: " + correctImageDelayLabel(int(seconds)),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func stepTypingDelayLabel(seconds int) string {
+	if seconds <= 0 {
+		return "выкл"
+	}
+	return fmt.Sprintf("%d с", seconds)
+}
+
+func (h *AdminHandler) startEditStepTypingDelay(ctx context.Context, chatID int64, messageID int) {
+	seconds, err := h.settingsRepo.GetStepTypingDelay()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditStepTypingDelay,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите, сколько секунд участник будет видеть «печатает…» перед каждым шагом (0 — отправлять шаг сразу, не больше %d):\n\nТекущее значение: %s\n\n/cancel - отмена", maxStepTypingDelay, stepTypingDelayLabel(seconds)), nil)
+}
+
+func (h *AdminHandler) handleEditStepTypingDelay(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	seconds, err := parseInt64(strings.TrimSpace(msg.Text))
+	if err != nil || seconds < 0 || seconds > maxStepTypingDelay {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   fmt.Sprintf("⚠️ Введите число секунд от 0 до %d", maxStepTypingDelay),
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetStepTypingDelay(int(seconds)); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ «Печатает» перед шагом: " + stepTypingDelayLabel(int(seconds)),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func hintCooldownLabel(seconds int) string {
+	if seconds <= 0 {
+		return "выкл"
+	}
+	return fmt.Sprintf("%d с", seconds)
+}
+
+func (h *AdminHandler) startEditHintCooldown(ctx context.Context, chatID int64, messageID int) {
+	seconds, err := h.settingsRepo.GetHintCooldown()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditHintCooldown,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите паузу в секундах между повторными запросами подсказки на одном шаге (0 — без паузы):\n\nТекущее значение: %s\n\n/cancel - отмена", hintCooldownLabel(seconds)), nil)
+}
+
+func (h *AdminHandler) handleEditHintCooldown(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	seconds, err := parseInt64(strings.TrimSpace(msg.Text))
+	if err != nil || seconds < 0 {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите неотрицательное число секунд",
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetHintCooldown(int(seconds)); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Пауза подсказок: " + hintCooldownLabel(int(seconds)),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func (h *AdminHandler) startEditMinAnswerLength(ctx context.Context, chatID int64, messageID int) {
+	length, err := h.settingsRepo.GetMinAnswerLength()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditMinAnswerLength,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите минимальную длину текстового ответа в символах. Более короткие ответы не засчитываются как попытка (0 — выключить):\n\nТекущее значение: %s\n\n/cancel - отмена", minAnswerLengthLabel(length)), nil)
+}
+
+func (h *AdminHandler) handleEditMinAnswerLength(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	length, err := parseInt64(strings.TrimSpace(msg.Text))
+	if err != nil || length < 0 {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите неотрицательное число символов",
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetMinAnswerLength(int(length)); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Мин. длина ответа: " + minAnswerLengthLabel(int(length)),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+// startEditMinCompletionSteps запрашивает, сколько одобренных шагов достаточно,
+// чтобы квест считался пройденным и выдавались достижения за прохождение
+func (h *AdminHandler) startEditMinCompletionSteps(ctx context.Context, chatID int64, messageID int) {
+	steps, err := h.settingsRepo.GetMinCompletionSteps()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditMinCompletionSteps,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("🎯 Введите, сколько шагов достаточно пройти, чтобы квест считался пройденным и участник получил достижения за прохождение. Остальные шаги остаются необязательными (0 — нужно пройти все шаги):\n\nТекущее значение: %s\n\n/cancel - отмена", minCompletionStepsLabel(steps)), nil)
+}
+
+func (h *AdminHandler) handleEditMinCompletionSteps(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	steps, err := parseInt64(strings.TrimSpace(msg.Text))
+	if err != nil || steps < 0 {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите неотрицательное число шагов",
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetMinCompletionSteps(int(steps)); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Шагов для прохождения: " + minCompletionStepsLabel(int(steps)),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func minCompletionStepsLabel(steps int) string {
+	if steps <= 0 {
+		return "все"
+	}
+	return fmt.Sprintf("%d", steps)
+}
+
+func maxActiveUsersLabel(limit int) string {
+	if limit <= 0 {
+		return "нет"
+	}
+	return fmt.Sprintf("%d", limit)
+}
+
+func (h *AdminHandler) startEditMaxActiveUsers(ctx context.Context, chatID int64, messageID int) {
+	limit, err := h.settingsRepo.GetMaxActiveUsers()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditMaxActiveUsers,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите, сколько участников могут проходить квест одновременно. Новые участники сверх лимита попадут в лист ожидания (0 — без ограничения):\n\nТекущее значение: %s\n\n/cancel - отмена", maxActiveUsersLabel(limit)), nil)
+}
+
+func (h *AdminHandler) handleEditMaxActiveUsers(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	limit, err := parseInt64(strings.TrimSpace(msg.Text))
+	if err != nil || limit < 0 {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите неотрицательное число участников",
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetMaxActiveUsers(int(limit)); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Лимит участников: " + maxActiveUsersLabel(int(limit)),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func announceChannelLabel(channelID int64, interval int) string {
+	if channelID == 0 {
+		return "выкл"
+	}
+	if interval <= 0 {
+		return "финиш"
+	}
+	return fmt.Sprintf("каждые %d шаг.", interval)
+}
+
+func (h *AdminHandler) startEditAnnounceChannel(ctx context.Context, chatID int64, messageID int) {
+	channelID, err := h.settingsRepo.GetAnnounceChannelID()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+	interval, _ := h.settingsRepo.GetAnnounceStepInterval()
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditAnnounceChannel,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите ID публичного канала и, через пробел, через сколько шагов объявлять о прогрессе участника, например <code>-1001234567890 5</code>. Без числа шагов объявляется только прохождение квеста. Бот должен быть администратором канала (0 — выключить):\n\nТекущее значение: %d, %s\n\n/cancel - отмена", channelID, announceChannelLabel(channelID, interval)), nil)
+}
+
+func (h *AdminHandler) handleEditAnnounceChannel(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	channelID, interval, err := ParseAnnounceChannelInput(msg.Text)
+	if err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Введите ID канала и неотрицательное число шагов, например: -1001234567890 5",
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetAnnounceChannelID(channelID); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+	if err := h.settingsRepo.SetAnnounceStepInterval(interval); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Канал объявлений: " + announceChannelLabel(channelID, interval),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+// ParseAnnounceChannelInput разбирает ввод «<ID канала> [число шагов]». «0» выключает объявления
+func ParseAnnounceChannelInput(text string) (int64, int, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, 0, fmt.Errorf("expected channel id and optional step interval")
+	}
+
+	channelID, err := parseInt64(fields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if channelID == 0 {
+		return 0, 0, nil
+	}
+
+	interval := 0
+	if len(fields) == 2 {
+		value, err := parseInt64(fields[1])
+		if err != nil || value < 0 {
+			return 0, 0, fmt.Errorf("invalid step interval %q", fields[1])
+		}
+		interval = int(value)
+	}
+	return channelID, interval, nil
+}
+
+func fillerWordsLabel(words []string) string {
+	if len(words) == 0 {
+		return "выкл"
+	}
+	return fmt.Sprintf("%d", len(words))
+}
+
+func (h *AdminHandler) startEditFillerWords(ctx context.Context, chatID int64, messageID int) {
+	words, err := h.settingsRepo.GetAnswerFillerWords()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	current := "выкл"
+	if len(words) > 0 {
+		current = strings.Join(words, ", ")
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditFillerWords,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите через запятую слова, которые отбрасываются в начале текстового ответа перед проверкой, например: это, вот, ну (0 — выключить):\n\nТекущее значение: %s\n\n/cancel - отмена", current), nil)
+}
+
+func (h *AdminHandler) handleEditFillerWords(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	var words []string
+	if text := strings.TrimSpace(msg.Text); text != "0" {
+		for _, word := range strings.Split(text, ",") {
+			if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+				words = append(words, word)
+			}
+		}
+	}
+
+	if err := h.settingsRepo.SetAnswerFillerWords(words); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Слова-паразиты: " + fillerWordsLabel(words),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func dailyDigestLabel(sendAt string) string {
+	if sendAt == "" {
+		return "выкл"
+	}
+	return sendAt
+}
+
+func (h *AdminHandler) startEditDailyDigest(ctx context.Context, chatID int64, messageID int) {
+	sendAt, err := h.settingsRepo.GetDailyDigestTime()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditDailyDigest,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите время ежедневной сводки в формате ЧЧ:ММ (по часовому поясу по умолчанию) или 0, чтобы выключить:\n\nТекущее значение: %s\n\n/cancel - отмена", dailyDigestLabel(sendAt)), nil)
+}
+
+func (h *AdminHandler) handleEditDailyDigest(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
+	}
+
+	sendAt := strings.TrimSpace(msg.Text)
+	if sendAt == "0" {
+		sendAt = ""
+	} else {
+		hour, minute, err := services.ParseDigestTime(sendAt)
+		if err != nil {
+			h.bot.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: msg.Chat.ID,
+				Text:   "⚠️ Введите время в формате ЧЧ:ММ, например 21:00",
+			})
+			return true
+		}
+		sendAt = fmt.Sprintf("%02d:%02d", hour, minute)
+	}
+
+	if err := h.settingsRepo.SetDailyDigestTime(sendAt); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении настройки",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Ежедневная сводка: " + dailyDigestLabel(sendAt),
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
+}
+
+func timezoneLabel(timezone string) string {
+	if timezone == "" {
+		return "серверный"
+	}
+	return timezone
 }
 
-func (h *AdminHandler) showSettingsMenu(ctx context.Context, chatID int64, messageID int) {
-	settings, err := h.settingsRepo.GetAll()
+func (h *AdminHandler) startEditDefaultTimezone(ctx context.Context, chatID int64, messageID int) {
+	defaultTimezone, err := h.settingsRepo.GetDefaultTimezone()
 	if err != nil {
 		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
 		return
 	}
 
-	var sb strings.Builder
-	sb.WriteString("⚙️ Настройки бота\n\n")
-	sb.WriteString(fmt.Sprintf("👋 Приветствие: %s\n\n", truncateText(settings.WelcomeMessage, 50)))
-	sb.WriteString(fmt.Sprintf("🏁 Финальное сообщение: %s\n\n", truncateText(settings.FinalMessage, 50)))
-	sb.WriteString(fmt.Sprintf("✅ Правильный ответ: %s\n\n", truncateText(settings.CorrectAnswerMessage, 50)))
-	sb.WriteString(fmt.Sprintf("❌ Неправильный ответ: %s", truncateText(settings.WrongAnswerMessage, 50)))
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminEditDefaultTimezone,
+	}
+	h.adminStateRepo.Save(state)
 
-	buttons := [][]tgmodels.InlineKeyboardButton{
-		{{Text: "🎮 Состояние квеста", CallbackData: "admin:quest_state"}},
-		{{Text: "🔐 Ограничение участия", CallbackData: "admin:group_restriction"}},
-		{{Text: "👋 Приветствие", CallbackData: "admin:edit_setting:welcome_message"}},
-		{{Text: "🏁 Финальное", CallbackData: "admin:edit_setting:final_message"}},
-		{{Text: "✅ Правильный ответ", CallbackData: "admin:edit_setting:correct_answer_message"}},
-		{{Text: "❌ Неправильный ответ", CallbackData: "admin:edit_setting:wrong_answer_message"}},
-		{{Text: "⬅️ Назад", CallbackData: "admin:menu"}},
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("📝 Введите часовой пояс по умолчанию (например: Europe/Moscow или +3). Он используется для участников, которые не указали свой через /timezone.\n\nТекущее значение: %s\n\n/cancel - отмена", html.EscapeString(timezoneLabel(defaultTimezone))), nil)
+}
+
+func (h *AdminHandler) handleEditDefaultTimezone(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Text == "" {
+		return false
 	}
 
-	h.editOrSend(ctx, chatID, messageID, html.EscapeString(sb.String()), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+	loc, err := services.ParseTimezone(msg.Text)
+	if err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Не удалось распознать часовой пояс. Примеры: Europe/Moscow, +3",
+		})
+		return true
+	}
+
+	if err := h.settingsRepo.SetDefaultTimezone(loc.String()); err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Ошибка при сохранении часового пояса",
+		})
+		return true
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Часовой пояс по умолчанию обновлён",
+	})
+	h.showSettingsMenu(ctx, msg.Chat.ID, 0)
+	return true
 }
 
 func (h *AdminHandler) showGroupRestrictionMenu(ctx context.Context, chatID int64, messageID int) {
@@ -693,6 +3937,10 @@ func (h *AdminHandler) showGroupRestrictionMenu(ctx context.Context, chatID int6
 		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
 			{Text: "✅ Включить ограничение", CallbackData: "admin:enable_group_restriction"},
 		})
+		autoInviteLink, _ := h.settingsRepo.GetAutoInviteLink()
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🤖 Создать ссылку автоматически: " + answerSummaryLabel(autoInviteLink), CallbackData: "admin:auto_invite_link_toggle"},
+		})
 	} else {
 		sb.WriteString("✅ Ограничение участия включено\n\n")
 		sb.WriteString(fmt.Sprintf("🔐 ID группы: %d\n", groupChatID))
@@ -704,6 +3952,14 @@ func (h *AdminHandler) showGroupRestrictionMenu(ctx context.Context, chatID int6
 		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
 			{Text: "✏️ Изменить ссылку", CallbackData: "admin:edit_group_link"},
 		})
+		hallOfFame, _ := h.settingsRepo.GetHallOfFameEnabled()
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🏛 Зал славы: " + answerSummaryLabel(hallOfFame), CallbackData: "admin:hall_of_fame_toggle"},
+		})
+		failOpen, _ := h.settingsRepo.GetGroupCheckFailOpen()
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🚪 Пускать, если бот без прав: " + answerSummaryLabel(failOpen), CallbackData: "admin:group_fail_open_toggle"},
+		})
 		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
 			{Text: "❌ Выключить ограничение", CallbackData: "admin:disable_group_restriction"},
 		})
@@ -716,6 +3972,57 @@ func (h *AdminHandler) showGroupRestrictionMenu(ctx context.Context, chatID int6
 	h.editOrSend(ctx, chatID, messageID, html.EscapeString(sb.String()), &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
 }
 
+// toggleGroupCheckFailOpen включает или выключает допуск участников без проверки членства,
+// пока бот не может её выполнить
+func (h *AdminHandler) toggleGroupCheckFailOpen(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetGroupCheckFailOpen()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetGroupCheckFailOpen(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showGroupRestrictionMenu(ctx, chatID, messageID)
+}
+
+// toggleAutoInviteLink включает или выключает создание ссылки-приглашения ботом
+// при включении ограничения участия
+func (h *AdminHandler) toggleAutoInviteLink(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetAutoInviteLink()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetAutoInviteLink(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showGroupRestrictionMenu(ctx, chatID, messageID)
+}
+
+// toggleHallOfFame включает или выключает закреплённый «Зал славы» в группе. Сообщение
+// отправляется и обновляется периодической задачей, при выключении она же его открепляет
+func (h *AdminHandler) toggleHallOfFame(ctx context.Context, chatID int64, messageID int) {
+	enabled, err := h.settingsRepo.GetHallOfFameEnabled()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении настроек", nil)
+		return
+	}
+
+	if err := h.settingsRepo.SetHallOfFameEnabled(!enabled); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.showGroupRestrictionMenu(ctx, chatID, messageID)
+}
+
 func (h *AdminHandler) startEnableGroupRestriction(ctx context.Context, chatID int64, messageID int) {
 	state := &models.AdminState{
 		UserID:       h.adminID,
@@ -797,6 +4104,17 @@ func (h *AdminHandler) handleEnableGroupRestrictionID(ctx context.Context, msg *
 		return true
 	}
 
+	linkPrompt := "📝 Введите ссылку на группу (например: https://t.me/+AbCdEfGhIjKlMnOp):\n\n/cancel - отмена"
+	if autoInviteLink, _ := h.settingsRepo.GetAutoInviteLink(); autoInviteLink {
+		inviteLink, err := h.createGroupInviteLink(ctx, groupChatID)
+		if err == nil {
+			h.enableGroupRestriction(ctx, msg.Chat.ID, groupChatID, inviteLink)
+			return true
+		}
+		log.Printf("[ADMIN] Failed to create invite link for group %d: %v", groupChatID, err)
+		linkPrompt = "⚠️ Не удалось создать ссылку автоматически: бот должен быть администратором группы с правом приглашать участников.\n\n" + linkPrompt
+	}
+
 	log.Printf("[ADMIN] Setting NewGroupChatID to: %d", groupChatID)
 	state.NewGroupChatID = groupChatID
 	state.CurrentState = fsm.StateAdminEnableGroupRestrictionLink
@@ -804,11 +4122,27 @@ func (h *AdminHandler) handleEnableGroupRestrictionID(ctx context.Context, msg *
 
 	h.bot.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: msg.Chat.ID,
-		Text:   "📝 Введите ссылку на группу (например: https://t.me/+AbCdEfGhIjKlMnOp):\n\n/cancel - отмена",
+		Text:   linkPrompt,
 	})
 	return true
 }
 
+// createGroupInviteLink создаёт ссылку-приглашение в группу от имени бота. Создаётся
+// отдельная ссылка, а не основная: экспорт основной отозвал бы уже разосланные ссылки
+func (h *AdminHandler) createGroupInviteLink(ctx context.Context, groupChatID int64) (string, error) {
+	link, err := h.bot.CreateChatInviteLink(ctx, &bot.CreateChatInviteLinkParams{
+		ChatID: groupChatID,
+		Name:   "Квест",
+	})
+	if err != nil {
+		return "", err
+	}
+	if link == nil || link.InviteLink == "" {
+		return "", fmt.Errorf("empty invite link")
+	}
+	return link.InviteLink, nil
+}
+
 func (h *AdminHandler) handleEnableGroupRestrictionLink(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
 	if msg.Text == "" {
 		return false
@@ -831,35 +4165,40 @@ func (h *AdminHandler) handleEnableGroupRestrictionLink(ctx context.Context, msg
 		return true
 	}
 
-	log.Printf("[ADMIN] Saving group chat ID: %d", state.NewGroupChatID)
-	if err := h.settingsRepo.SetRequiredGroupChatID(state.NewGroupChatID); err != nil {
+	h.enableGroupRestriction(ctx, msg.Chat.ID, state.NewGroupChatID, inviteLink)
+	return true
+}
+
+// enableGroupRestriction сохраняет группу и ссылку-приглашение и завершает включение ограничения
+func (h *AdminHandler) enableGroupRestriction(ctx context.Context, chatID int64, groupChatID int64, inviteLink string) {
+	log.Printf("[ADMIN] Saving group chat ID: %d", groupChatID)
+	if err := h.settingsRepo.SetRequiredGroupChatID(groupChatID); err != nil {
 		log.Printf("[ADMIN] Failed to save group chat ID: %v", err)
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: msg.Chat.ID,
+			ChatID: chatID,
 			Text:   "⚠️ Ошибка при сохранении ID группы",
 		})
-		return true
+		return
 	}
 
 	log.Printf("[ADMIN] Saving invite link: %s", inviteLink)
 	if err := h.settingsRepo.SetGroupChatInviteLink(inviteLink); err != nil {
 		log.Printf("[ADMIN] Failed to save invite link: %v", err)
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: msg.Chat.ID,
+			ChatID: chatID,
 			Text:   "⚠️ Ошибка при сохранении ссылки",
 		})
-		return true
+		return
 	}
 
 	h.adminStateRepo.Clear(h.adminID)
 
 	log.Printf("[ADMIN] Group restriction enabled successfully")
 	h.bot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: msg.Chat.ID,
-		Text:   "✅ Ограничение участия включено",
+		ChatID: chatID,
+		Text:   "✅ Ограничение участия включено\n🔗 Ссылка: " + inviteLink,
 	})
-	h.showGroupRestrictionMenu(ctx, msg.Chat.ID, 0)
-	return true
+	h.showGroupRestrictionMenu(ctx, chatID, 0)
 }
 
 func (h *AdminHandler) handleEditGroupID(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
@@ -954,13 +4293,28 @@ func (h *AdminHandler) startEditSetting(ctx context.Context, chatID int64, messa
 
 	settingName := map[string]string{
 		"welcome_message":        "приветствие",
+		"resume_message":         "приветствие вернувшегося участника («-» — только повторить шаг)",
 		"final_message":          "финальное сообщение",
 		"correct_answer_message": "сообщение о правильном ответе",
 		"wrong_answer_message":   "сообщение о неправильном ответе",
+		"share_message":          "сообщение «поделиться» ({link} — ссылка-приглашение)",
+		"rules_message":          "правила квеста (участники примут их заново; «-» — отключить правила)",
 	}[settingKey]
+	if language, ok := strings.CutPrefix(settingKey, models.CorrectAnswerMessagePrefix); ok {
+		settingName = fmt.Sprintf("сообщение о правильном ответе [%s] («-» — использовать общее)", language)
+	}
 
 	currentValue, _ := h.settingsRepo.Get(settingKey)
 
+	settings, _ := h.settingsRepo.GetAll()
+	parseMode := settings.ParseModeFor(settingKey)
+
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "🔤 Разметка: " + parseModeLabel(parseMode), CallbackData: "admin:setting_mode:" + settingKey}},
+		},
+	}
+
 	h.editOrSend(
 		ctx,
 		chatID,
@@ -970,24 +4324,145 @@ func (h *AdminHandler) startEditSetting(ctx context.Context, chatID int64, messa
 			settingName,
 			html.EscapeString(currentValue),
 		),
-		nil,
+		keyboard,
 	)
 }
 
+func (h *AdminHandler) cycleSettingParseMode(ctx context.Context, chatID int64, messageID int, data string) {
+	settingKey := strings.TrimPrefix(data, "admin:setting_mode:")
+
+	settings, _ := h.settingsRepo.GetAll()
+
+	next := models.MessageParseModeMarkdown
+	switch settings.ParseModeFor(settingKey) {
+	case models.MessageParseModeMarkdown:
+		next = models.MessageParseModePlain
+	case models.MessageParseModePlain:
+		next = models.MessageParseModeHTML
+	}
+
+	if err := h.settingsRepo.SetMessageParseMode(settingKey, next); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сохранении настройки", nil)
+		return
+	}
+
+	h.startEditSetting(ctx, chatID, messageID, "admin:edit_setting:"+settingKey)
+}
+
+func parseModeLabel(mode models.MessageParseMode) string {
+	switch mode {
+	case models.MessageParseModeMarkdown:
+		return "Markdown"
+	case models.MessageParseModePlain:
+		return "обычный текст"
+	default:
+		return "HTML"
+	}
+}
+
+// textInputStates — состояния, в которых админ отвечает текстом. Стикер, фото или другое
+// сообщение без текста в них не пропадает молча: админ получает подсказку
+var textInputStates = map[string]bool{
+	fsm.StateAdminAddStepText:                true,
+	fsm.StateAdminAddStepAnswers:             true,
+	fsm.StateAdminEditStepText:               true,
+	fsm.StateAdminEditLinkDomain:             true,
+	fsm.StateAdminEditImageConstraints:       true,
+	fsm.StateAdminEditAuthorNote:             true,
+	fsm.StateAdminEditUnlockCode:             true,
+	fsm.StateAdminAddStepTag:                 true,
+	fsm.StateAdminEditRequiredAnswers:        true,
+	fsm.StateAdminAddAnswer:                  true,
+	fsm.StateAdminAddChoice:                  true,
+	fsm.StateAdminAddChapter:                 true,
+	fsm.StateAdminRejectReason:               true,
+	fsm.StateAdminDeleteAnswer:               true,
+	fsm.StateAdminDeleteImage:                true,
+	fsm.StateAdminEditSettingValue:           true,
+	fsm.StateAdminAddHintText:                true,
+	fsm.StateAdminEditHintText:               true,
+	fsm.StateAdminSendMessage:                true,
+	fsm.StateAdminEnableGroupRestrictionID:   true,
+	fsm.StateAdminEnableGroupRestrictionLink: true,
+	fsm.StateAdminEditGroupID:                true,
+	fsm.StateAdminEditGroupLink:              true,
+	fsm.StateAdminEditDefaultTimezone:        true,
+	fsm.StateAdminEditScoring:                true,
+	fsm.StateAdminEditAutoApprove:            true,
+	fsm.StateAdminEditDailyDigest:            true,
+	fsm.StateAdminEditMinAnswerLength:        true,
+	fsm.StateAdminEditMinCompletionSteps:     true,
+	fsm.StateAdminEditMaxActiveUsers:         true,
+	fsm.StateAdminEditExportFileThreshold:    true,
+	fsm.StateAdminEditFillerWords:            true,
+	fsm.StateAdminEditAnnounceChannel:        true,
+	fsm.StateAdminEditCorrectImageDelay:      true,
+	fsm.StateAdminEditStepTypingDelay:        true,
+	fsm.StateAdminEditHintCooldown:           true,
+	fsm.StateAdminAddSynonymGroup:            true,
+	fsm.StateAdminTestAnswer:                 true,
+}
+
+const textInputExpectedPrompt = "⚠️ Здесь нужен текст. Отправьте текстовое сообщение или /cancel для отмены"
+
+// unexpectedInputPrompt возвращает подсказку для сообщения, которое состояние админа
+// не может принять, или пустую строку, если сообщение нужно обработать
+func unexpectedInputPrompt(state string, msg *tgmodels.Message) string {
+	switch {
+	case textInputStates[state] && msg.Text == "":
+		return textInputExpectedPrompt
+	case state == fsm.StateAdminAddStepType:
+		return "⚠️ Выберите тип ответа кнопкой выше или /cancel для отмены"
+	case state == fsm.StateAdminAddStepImages && !isStepMedia(msg):
+		return "⚠️ Отправьте фото или GIF для шага или нажмите «Пропустить»"
+	case state == fsm.StateAdminEditLocationTarget && msg.Location == nil && msg.Text == "":
+		return "⚠️ Отправьте геопозицию или введите широту, долготу и радиус в метрах. /cancel — отмена"
+	}
+	return ""
+}
+
 func (h *AdminHandler) handleStateInput(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if prompt := unexpectedInputPrompt(state.CurrentState, msg); prompt != "" {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   prompt,
+		})
+		return true
+	}
+
 	switch state.CurrentState {
 	case fsm.StateAdminAddStepText:
 		return h.handleAddStepText(ctx, msg, state)
-	case fsm.StateAdminAddStepType:
-		return false
 	case fsm.StateAdminAddStepImages:
 		return h.handleAddStepImages(ctx, msg, state)
 	case fsm.StateAdminAddStepAnswers:
 		return h.handleAddStepAnswers(ctx, msg, state)
 	case fsm.StateAdminEditStepText:
 		return h.handleEditStepText(ctx, msg, state)
+	case fsm.StateAdminEditLinkDomain:
+		return h.handleEditLinkDomain(ctx, msg, state)
+	case fsm.StateAdminEditImageConstraints:
+		return h.handleEditImageConstraints(ctx, msg, state)
+	case fsm.StateAdminEditAuthorNote:
+		return h.handleEditAuthorNote(ctx, msg, state)
+	case fsm.StateAdminEditUnlockCode:
+		return h.handleEditUnlockCode(ctx, msg, state)
+	case fsm.StateAdminAddStepTag:
+		return h.handleAddStepTag(ctx, msg, state)
+	case fsm.StateAdminEditLocationTarget:
+		return h.handleEditLocationTarget(ctx, msg, state)
+	case fsm.StateAdminEditRequiredAnswers:
+		return h.handleEditRequiredAnswers(ctx, msg, state)
 	case fsm.StateAdminAddAnswer:
 		return h.handleAddAnswer(ctx, msg, state)
+	case fsm.StateAdminAddChoice:
+		return h.handleAddChoice(ctx, msg, state)
+	case fsm.StateAdminAddDocument:
+		return h.handleAddDocument(ctx, msg, state)
+	case fsm.StateAdminAddChapter:
+		return h.handleAddChapter(ctx, msg, state)
+	case fsm.StateAdminRejectReason:
+		return h.handleRejectReason(ctx, msg, state)
 	case fsm.StateAdminDeleteAnswer:
 		return h.handleDeleteAnswer(ctx, msg, state)
 	case fsm.StateAdminAddImage:
@@ -1024,6 +4499,38 @@ func (h *AdminHandler) handleStateInput(ctx context.Context, msg *tgmodels.Messa
 		return h.handleEditGroupID(ctx, msg, state)
 	case fsm.StateAdminEditGroupLink:
 		return h.handleEditGroupLink(ctx, msg, state)
+	case fsm.StateAdminEditDefaultTimezone:
+		return h.handleEditDefaultTimezone(ctx, msg, state)
+	case fsm.StateAdminEditScoring:
+		return h.handleEditScoring(ctx, msg, state)
+	case fsm.StateAdminEditAutoApprove:
+		return h.handleEditAutoApprove(ctx, msg, state)
+	case fsm.StateAdminEditDailyDigest:
+		return h.handleEditDailyDigest(ctx, msg, state)
+	case fsm.StateAdminEditMinAnswerLength:
+		return h.handleEditMinAnswerLength(ctx, msg, state)
+	case fsm.StateAdminEditMinCompletionSteps:
+		return h.handleEditMinCompletionSteps(ctx, msg, state)
+	case fsm.StateAdminEditMaxActiveUsers:
+		return h.handleEditMaxActiveUsers(ctx, msg, state)
+	case fsm.StateAdminEditExportFileThreshold:
+		return h.handleEditExportFileThreshold(ctx, msg, state)
+	case fsm.StateAdminEditFillerWords:
+		return h.handleEditFillerWords(ctx, msg, state)
+	case fsm.StateAdminEditAnnounceChannel:
+		return h.handleEditAnnounceChannel(ctx, msg, state)
+	case fsm.StateAdminEditCorrectImageDelay:
+		return h.handleEditCorrectImageDelay(ctx, msg, state)
+	case fsm.StateAdminEditStepTypingDelay:
+		return h.handleEditStepTypingDelay(ctx, msg, state)
+	case fsm.StateAdminEditHintCooldown:
+		return h.handleEditHintCooldown(ctx, msg, state)
+	case fsm.StateAdminImportQuestConfig:
+		return h.handleImportQuestConfig(ctx, msg, state)
+	case fsm.StateAdminAddSynonymGroup:
+		return h.handleAddSynonymGroup(ctx, msg, state)
+	case fsm.StateAdminTestAnswer:
+		return h.handleTestAnswer(ctx, msg, state)
 	}
 	return false
 }
@@ -1060,6 +4567,13 @@ func (h *AdminHandler) handleAddStepText(ctx context.Context, msg *tgmodels.Mess
 				{Text: "📝 Текст", CallbackData: "admin:step_type:text"},
 				{Text: "📷 Изображение", CallbackData: "admin:step_type:image"},
 			},
+			{
+				{Text: "📍 Геопозиция", CallbackData: "admin:step_type:location"},
+				{Text: "🔘 Выбор", CallbackData: "admin:step_type:choice"},
+			},
+			{
+				{Text: "🔗 Ссылка", CallbackData: "admin:step_type:link"},
+			},
 		},
 	}
 
@@ -1091,12 +4605,45 @@ func (h *AdminHandler) setStepType(ctx context.Context, chatID int64, messageID
 }
 
 func (h *AdminHandler) handleAddStepImages(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
-	if len(msg.Photo) == 0 {
+	fileID, mediaType, ok := stepMediaFromMessage(msg)
+	if !ok {
 		return false
 	}
 
-	fileID := msg.Photo[len(msg.Photo)-1].FileID
-	state.NewStepImages = append(state.NewStepImages, fileID)
+	// Альбомы в Telegram состоят только из фото, GIF всегда приходит отдельным сообщением
+	if msg.MediaGroupID != "" {
+		chatID := msg.Chat.ID
+		h.mediaGroups.add(msg.MediaGroupID, mediaGroupPhoto{messageID: msg.ID, fileID: fileID}, func(photos []mediaGroupPhoto) {
+			defer h.errorManager.Recover(ctx, "media group flush")
+			fileIDs := make([]string, len(photos))
+			for i, photo := range photos {
+				fileIDs[i] = photo.fileID
+			}
+			h.appendStepImages(ctx, chatID, fileIDs, mediaType)
+		})
+		return true
+	}
+
+	h.appendStepImages(ctx, msg.Chat.ID, []string{fileID}, mediaType)
+	return true
+}
+
+// appendStepImages добавляет изображения к создаваемому шагу. Состояние перечитывается, потому что
+// альбом сохраняется уже после обработки сообщений, когда администратор мог завершить шаг
+func (h *AdminHandler) appendStepImages(ctx context.Context, chatID int64, fileIDs []string, mediaType string) {
+	state, _ := h.adminStateRepo.Get(h.adminID)
+	if state == nil || state.CurrentState != fsm.StateAdminAddStepImages {
+		return
+	}
+
+	// Типы выравниваются по уже добавленным файлам: у состояний, сохранённых до появления типов, их нет
+	for len(state.NewStepMediaTypes) < len(state.NewStepImages) {
+		state.NewStepMediaTypes = append(state.NewStepMediaTypes, models.MediaTypePhoto)
+	}
+	state.NewStepImages = append(state.NewStepImages, fileIDs...)
+	for range fileIDs {
+		state.NewStepMediaTypes = append(state.NewStepMediaTypes, mediaType)
+	}
 	h.adminStateRepo.Save(state)
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
@@ -1106,11 +4653,10 @@ func (h *AdminHandler) handleAddStepImages(ctx context.Context, msg *tgmodels.Me
 	}
 
 	h.bot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:      msg.Chat.ID,
+		ChatID:      chatID,
 		Text:        fmt.Sprintf("📷 Добавлено изображений: %d\n\nОтправьте ещё или нажмите «Готово»", len(state.NewStepImages)),
 		ReplyMarkup: keyboard,
 	})
-	return true
 }
 
 func (h *AdminHandler) skipImages(ctx context.Context, chatID int64, messageID int) {
@@ -1132,7 +4678,7 @@ func (h *AdminHandler) doneImages(ctx context.Context, chatID int64, messageID i
 }
 
 func (h *AdminHandler) proceedToAnswers(ctx context.Context, chatID int64, messageID int, state *models.AdminState) {
-	if state.NewStepType == models.AnswerTypeImage {
+	if state.NewStepType == models.AnswerTypeImage || state.NewStepType == models.AnswerTypeLocation || state.NewStepType == models.AnswerTypeChoice || state.NewStepType == models.AnswerTypeLink {
 		h.createStep(ctx, chatID, messageID, state)
 		return
 	}
@@ -1208,11 +4754,16 @@ func (h *AdminHandler) createStep(ctx context.Context, chatID int64, messageID i
 	}
 
 	for i, fileID := range state.NewStepImages {
-		h.stepRepo.AddImage(stepID, fileID, i)
+		mediaType := models.MediaTypePhoto
+		if i < len(state.NewStepMediaTypes) && state.NewStepMediaTypes[i] != "" {
+			mediaType = state.NewStepMediaTypes[i]
+		}
+		h.stepRepo.AddMedia(stepID, fileID, mediaType, i)
 	}
 
 	for _, answer := range state.NewStepAnswers {
-		h.stepRepo.AddAnswer(stepID, answer)
+		answer, language := services.ParseAnswerLanguage(answer)
+		h.answerRepo.AddStepAnswerWithLanguage(stepID, answer, language)
 	}
 
 	h.adminStateRepo.Clear(h.adminID)
@@ -1252,7 +4803,8 @@ func (h *AdminHandler) handleAddAnswer(ctx context.Context, msg *tgmodels.Messag
 		return false
 	}
 
-	if err := h.answerRepo.AddStepAnswer(state.EditingStepID, msg.Text); err != nil {
+	answer, language := services.ParseAnswerLanguage(msg.Text)
+	if err := h.answerRepo.AddStepAnswerWithLanguage(state.EditingStepID, answer, language); err != nil {
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: msg.Chat.ID,
 			Text:   "⚠️ Ошибка при добавлении варианта",
@@ -1324,7 +4876,19 @@ func (h *AdminHandler) handleEditSettingValue(ctx context.Context, msg *tgmodels
 		return false
 	}
 
-	if err := h.settingsRepo.Set(state.EditingSetting, msg.Text); err != nil {
+	var err error
+	if state.EditingSetting == "rules_message" {
+		rules := msg.Text
+		if strings.TrimSpace(rules) == "-" {
+			rules = ""
+		}
+		err = h.settingsRepo.SetRulesMessage(rules)
+	} else if (state.EditingSetting == "resume_message" || strings.HasPrefix(state.EditingSetting, models.CorrectAnswerMessagePrefix)) && strings.TrimSpace(msg.Text) == "-" {
+		err = h.settingsRepo.Set(state.EditingSetting, "")
+	} else {
+		err = h.settingsRepo.Set(state.EditingSetting, msg.Text)
+	}
+	if err != nil {
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: msg.Chat.ID,
 			Text:   "⚠️ Ошибка при сохранении настройки",
@@ -1612,6 +5176,12 @@ func (h *AdminHandler) showUserDetails(ctx context.Context, chatID int64, messag
 	}
 
 	text := FormatUserDetails(h, details)
+	if h.adminMessagesRepo != nil {
+		thread, err := h.adminMessagesRepo.GetSupportThread(userID, SupportThreadPreviewSize)
+		if err == nil && len(thread) > 0 {
+			text += "\n\n" + FormatSupportThread(thread)
+		}
+	}
 
 	keyboard := BuildUserDetailsKeyboard(details.User, true)
 	h.editOrSend(ctx, chatID, messageID, text, keyboard)
@@ -1636,13 +5206,7 @@ func FormatUserDetails(h *AdminHandler, details *services.UserDetails) string {
 		sb.WriteString("📊 Прогресс: ✅ Квест завершён\n")
 	} else if details.CurrentStep != nil {
 		fmt.Fprintf(&sb, "📊 Прогресс: Шаг %d\n❓ Вопрос: %s\n", details.CurrentStep.StepOrder, html.EscapeString(details.CurrentStep.Text))
-		statusText := map[models.ProgressStatus]string{
-			models.StatusPending:       "⏳ Ожидает ответа",
-			models.StatusWaitingReview: "🔍 На проверке",
-			models.StatusApproved:      "✅ Одобрен",
-			models.StatusRejected:      "❌ Отклонён",
-		}[details.Status]
-		if statusText != "" {
+		if statusText := progressStatusLabel(details.Status); statusText != "" {
 			fmt.Fprintf(&sb, "📋 Статус: %s\n", statusText)
 		}
 	} else {
@@ -1666,6 +5230,14 @@ func FormatUserDetails(h *AdminHandler, details *services.UserDetails) string {
 		if err == nil && totalAsterisk > 0 {
 			sb.WriteString(fmt.Sprintf("\n⭐ Вопросы со звёздочкой: %d из %d\n", answeredAsterisk, totalAsterisk))
 		}
+
+		if h.showUserAttemptStats() {
+			attempts, err := h.statsService.GetUserAttemptStats(details.User.ID)
+			if err == nil && attempts.TotalAttempts > 0 {
+				sb.WriteString("\n")
+				sb.WriteString(FormatUserAttemptStats(attempts))
+			}
+		}
 	}
 
 	sb.WriteString("\n")
@@ -1678,6 +5250,27 @@ func FormatUserDetails(h *AdminHandler, details *services.UserDetails) string {
 	return sb.String()
 }
 
+// showUserAttemptStats сообщает, включена ли сводка попыток в карточке участника
+func (h *AdminHandler) showUserAttemptStats() bool {
+	if h.settingsRepo == nil {
+		return true
+	}
+	enabled, err := h.settingsRepo.GetUserAttemptStats()
+	return err != nil || enabled
+}
+
+// FormatUserAttemptStats выводит сводку попыток участника: сколько всего ответов, сколько
+// из них неверных, сколько подсказок и попыток в среднем на шаг
+func FormatUserAttemptStats(stats *services.UserAttemptStats) string {
+	var sb strings.Builder
+	sb.WriteString("🎯 <b>Попытки</b>\n")
+	fmt.Fprintf(&sb, "  • Всего: %d\n", stats.TotalAttempts)
+	fmt.Fprintf(&sb, "  • Неверных: %d\n", stats.WrongAttempts)
+	fmt.Fprintf(&sb, "  • Подсказок: %d\n", stats.HintsUsed)
+	fmt.Fprintf(&sb, "  • В среднем на шаг: %.1f\n", stats.AverageAttemptsPerStep())
+	return sb.String()
+}
+
 func BuildUserDetailsKeyboard(user *models.User, isAdmin bool) *tgmodels.InlineKeyboardMarkup {
 	var buttons [][]tgmodels.InlineKeyboardButton
 
@@ -1710,15 +5303,164 @@ func BuildUserDetailsKeyboard(user *models.User, isAdmin bool) *tgmodels.InlineK
 		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
 			{Text: "🏅 Сбросить достижения", CallbackData: fmt.Sprintf("reset_achievements:%d", user.ID)},
 		})
+
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "📄 Экспорт профиля", CallbackData: fmt.Sprintf("export_profile:%d", user.ID)},
+		})
+
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🖼 Фото ответов", CallbackData: fmt.Sprintf("answer_photos:%d", user.ID)},
+		})
+
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "👁 Глазами участника", CallbackData: fmt.Sprintf("admin:view_as:%d", user.ID)},
+		})
+	}
+
+	// Back button - always shown
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад", CallbackData: "admin:userlist"},
+	})
+
+	return &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: buttons,
+	}
+}
+
+// progressStatusLabel возвращает подпись статуса текущего шага участника
+func progressStatusLabel(status models.ProgressStatus) string {
+	return map[models.ProgressStatus]string{
+		models.StatusPending:       "⏳ Ожидает ответа",
+		models.StatusWaitingReview: "🔍 На проверке",
+		models.StatusApproved:      "✅ Одобрен",
+		models.StatusRejected:      "❌ Отклонён",
+	}[status]
+}
+
+func (h *AdminHandler) exportUserProfile(ctx context.Context, chatID int64, data string) {
+	userID, _ := parseInt64(strings.TrimPrefix(data, "export_profile:"))
+	if userID == 0 {
+		return
+	}
+
+	content, filename, err := h.userManager.ExportUserProfile(userID)
+	if err != nil {
+		log.Printf("[ADMIN] Error exporting profile for user %d: %v", userID, err)
+		h.editOrSend(ctx, chatID, 0, "⚠️ Ошибка при экспорте профиля", nil)
+		return
+	}
+
+	_, err = h.bot.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID: chatID,
+		Document: &tgmodels.InputFileUpload{
+			Filename: filename,
+			Data:     bytes.NewReader(content),
+		},
+		Caption:   fmt.Sprintf("📄 <b>Профиль участника</b> [%d]", userID),
+		ParseMode: tgmodels.ParseModeHTML,
+	})
+	if err != nil {
+		log.Printf("[ADMIN] Failed to send profile export: %v", err)
+		h.editOrSend(ctx, chatID, 0, fmt.Sprintf("⚠️ Ошибка при отправке файла: %v", err), nil)
+	}
+}
+
+// handleAnswerPhotos показывает шаги, на которые участник отправлял фото
+// (answer_photos:<user>), или пересылает администратору фото ответов на шаг альбомом
+// (answer_photos:<user>:<step>)
+func (h *AdminHandler) handleAnswerPhotos(ctx context.Context, chatID int64, messageID int, data string) {
+	rawUserID, rawStepID, hasStep := strings.Cut(strings.TrimPrefix(data, "answer_photos:"), ":")
+	userID, _ := parseInt64(rawUserID)
+	if userID == 0 {
+		return
+	}
+
+	answers, err := h.answerRepo.GetUserAnswerHistory(userID)
+	if err != nil {
+		log.Printf("[ADMIN] Error getting answer history for user %d: %v", userID, err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении ответов", nil)
+		return
+	}
+
+	if hasStep {
+		stepID, _ := parseInt64(rawStepID)
+		h.sendAnswerAlbum(ctx, chatID, userID, stepID, answers)
+		return
+	}
+
+	var stepIDs []int64
+	counts := make(map[int64]int)
+	for _, answer := range answers {
+		if len(answer.Images) == 0 {
+			continue
+		}
+		if counts[answer.StepID] == 0 {
+			stepIDs = append(stepIDs, answer.StepID)
+		}
+		counts[answer.StepID] += len(answer.Images)
+	}
+
+	backRow := []tgmodels.InlineKeyboardButton{{Text: "⬅️ Назад", CallbackData: fmt.Sprintf("user:%d", userID)}}
+	if len(stepIDs) == 0 {
+		h.editOrSend(ctx, chatID, messageID, "🖼 Участник не отправлял фото в ответах", &tgmodels.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgmodels.InlineKeyboardButton{backRow},
+		})
+		return
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for _, stepID := range stepIDs {
+		label := fmt.Sprintf("Шаг #%d", stepID)
+		if step, err := h.stepRepo.GetByID(stepID); err == nil && step != nil {
+			label = fmt.Sprintf("Шаг %d", step.StepOrder)
+		}
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: fmt.Sprintf("%s — фото: %d", label, counts[stepID]), CallbackData: fmt.Sprintf("answer_photos:%d:%d", userID, stepID)},
+		})
+	}
+	buttons = append(buttons, backRow)
+
+	h.editOrSend(ctx, chatID, messageID, "🖼 <b>Фото ответов</b>\n\nВыберите шаг, чтобы получить фото альбомом", &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+// sendAnswerAlbum пересылает администратору фото ответов участника на шаг. Фото, которые
+// Telegram больше не отдаёт, пропускаются с предупреждением
+func (h *AdminHandler) sendAnswerAlbum(ctx context.Context, chatID, userID, stepID int64, answers []*models.UserAnswer) {
+	images, missing := h.media.ResolveAnswerImages(ctx, services.AnswerImagesForStep(answers, stepID))
+	if len(images) == 0 {
+		h.editOrSend(ctx, chatID, 0, "⚠️ Фото ответов на этот шаг недоступны", nil)
+		return
 	}
 
-	// Back button - always shown
-	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
-		{Text: "⬅️ Назад", CallbackData: "admin:userlist"},
-	})
+	caption := fmt.Sprintf("🖼 Фото ответов участника [%d]", userID)
+	if step, err := h.stepRepo.GetByID(stepID); err == nil && step != nil {
+		caption = fmt.Sprintf("🖼 Фото ответов участника [%d] на шаг %d", userID, step.StepOrder)
+	}
 
-	return &tgmodels.InlineKeyboardMarkup{
-		InlineKeyboard: buttons,
+	for _, album := range services.BuildAnswerAlbums(images, caption) {
+		var err error
+		if photo, ok := album[0].(*tgmodels.InputMediaPhoto); ok && len(album) == 1 {
+			// Одно фото отправляется обычным сообщением: медиагруппа должна содержать минимум два
+			_, err = h.msgManager.SendPhotoWithRetry(ctx, &bot.SendPhotoParams{
+				ChatID:  chatID,
+				Photo:   &tgmodels.InputFileString{Data: photo.Media},
+				Caption: photo.Caption,
+			})
+		} else {
+			_, err = h.msgManager.SendMediaGroupWithRetry(ctx, &bot.SendMediaGroupParams{
+				ChatID: chatID,
+				Media:  album,
+			})
+		}
+		if err != nil {
+			log.Printf("[ADMIN] Failed to send answer photos of user %d for step %d: %v", userID, stepID, err)
+			h.editOrSend(ctx, chatID, 0, "⚠️ Ошибка при отправке фото", nil)
+			return
+		}
+	}
+
+	if missing > 0 {
+		h.editOrSend(ctx, chatID, 0, fmt.Sprintf("⚠️ Недоступно фото: %d", missing), nil)
 	}
 }
 
@@ -1795,6 +5537,71 @@ func (h *AdminHandler) handleResetAchievementsFromDetails(ctx context.Context, c
 	h.showUserDetails(ctx, chatID, 0, fmt.Sprintf("user:%d", userID))
 }
 
+// resetCategoryOrder — категории, которые можно сбросить пользователю по отдельности
+var resetCategoryOrder = []struct {
+	category models.AchievementCategory
+	name     string
+}{
+	{models.CategoryProgress, "📈 Прогресс"},
+	{models.CategoryCompletion, "🏁 Завершение"},
+	{models.CategorySpecial, "⭐ Особые"},
+	{models.CategoryHints, "💡 Подсказки"},
+	{models.CategoryComposite, "🎖️ Составные"},
+	{models.CategoryUnique, "👑 Уникальные"},
+}
+
+// handleResetCategoryFromDetails показывает выбор категории (reset_category:<user>) и сбрасывает
+// достижения выбранной категории (reset_category:<user>:<category>)
+func (h *AdminHandler) handleResetCategoryFromDetails(ctx context.Context, chatID int64, messageID int, data string) {
+	userIDPart, category, hasCategory := strings.Cut(strings.TrimPrefix(data, "reset_category:"), ":")
+	userID, _ := parseInt64(userIDPart)
+	if userID == 0 {
+		return
+	}
+
+	if h.achievementEngine == nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Система достижений недоступна", nil)
+		return
+	}
+
+	if !hasCategory {
+		var buttons [][]tgmodels.InlineKeyboardButton
+		for _, option := range resetCategoryOrder {
+			buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+				{Text: option.name, CallbackData: fmt.Sprintf("reset_category:%d:%s", userID, option.category)},
+			})
+		}
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "⬅️ Назад", CallbackData: fmt.Sprintf("user_achievements:%d", userID)},
+		})
+		h.editOrSend(ctx, chatID, messageID, "🗂 Достижения какой категории сбросить? Составные достижения, зависящие от них, будут пересчитаны", &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+		return
+	}
+
+	categoryName := ""
+	for _, option := range resetCategoryOrder {
+		if string(option.category) == category {
+			categoryName = option.name
+		}
+	}
+	if categoryName == "" {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Неизвестная категория", nil)
+		return
+	}
+
+	if err := h.achievementEngine.ResetCategory(userID, models.AchievementCategory(category)); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при сбросе достижений", nil)
+		return
+	}
+
+	if _, err := h.achievementEngine.RecalculatePositionAchievements(); err != nil {
+		log.Printf("[ADMIN] Error recalculating position achievements: %v", err)
+	}
+
+	h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("✅ Достижения категории «%s» сброшены", categoryName), nil)
+	h.showUserAchievements(ctx, chatID, 0, fmt.Sprintf("user_achievements:%d", userID))
+}
+
 func (h *AdminHandler) handleManualAchievementAward(ctx context.Context, chatID int64, messageID int, data string) {
 	// Verify caller has admin privileges - additional security check
 	// Note: This is already checked in HandleCallback, but we add it here for defense in depth
@@ -1942,8 +5749,12 @@ func (h *AdminHandler) showImagesMenu(ctx context.Context, chatID int64, message
 	if len(step.Images) == 0 {
 		sb.WriteString("Изображений пока нет")
 	} else {
-		for i, img := range step.Images {
-			sb.WriteString(fmt.Sprintf("%d. Изображение (ID: %s)\n", i+1, img.FileID[:10]+"..."))
+		for i, img := range h.withMediaSizes(ctx, step.Images) {
+			sb.WriteString(fmt.Sprintf("%d. %s (ID: %s)", i+1, stepMediaLabel(img), img.FileID[:10]+"..."))
+			if info := services.FormatMediaInfo(services.MediaInfo{Width: img.Width, Height: img.Height, FileSize: img.FileSize}); info != "" {
+				sb.WriteString(" — " + info)
+			}
+			sb.WriteString("\n")
 		}
 	}
 
@@ -1980,7 +5791,7 @@ func (h *AdminHandler) startAddImage(ctx context.Context, chatID int64, messageI
 	}
 	h.adminStateRepo.Save(state)
 
-	h.editOrSend(ctx, chatID, messageID, "📷 Отправьте изображение для добавления:\n\n/cancel - отмена", nil)
+	h.editOrSend(ctx, chatID, messageID, "📷 Отправьте изображение или GIF для добавления:\n\n/cancel - отмена", nil)
 }
 
 func (h *AdminHandler) startReplaceImage(ctx context.Context, chatID int64, messageID int, data string) {
@@ -2005,7 +5816,7 @@ func (h *AdminHandler) startReplaceImage(ctx context.Context, chatID int64, mess
 	var sb strings.Builder
 	sb.WriteString("🔄 Введите номер изображения для замены:\n\n")
 	for i, img := range step.Images {
-		sb.WriteString(fmt.Sprintf("%d. Изображение (ID: %s)\n", i+1, img.FileID[:10]+"..."))
+		sb.WriteString(fmt.Sprintf("%d. %s (ID: %s)\n", i+1, stepMediaLabel(img), img.FileID[:10]+"..."))
 	}
 	sb.WriteString("\n/cancel - отмена")
 
@@ -2033,32 +5844,91 @@ func (h *AdminHandler) startDeleteImage(ctx context.Context, chatID int64, messa
 	var sb strings.Builder
 	sb.WriteString("🗑️ Введите номер изображения для удаления:\n\n")
 	for i, img := range step.Images {
-		sb.WriteString(fmt.Sprintf("%d. Изображение (ID: %s)\n", i+1, img.FileID[:10]+"..."))
+		sb.WriteString(fmt.Sprintf("%d. %s (ID: %s)\n", i+1, stepMediaLabel(img), img.FileID[:10]+"..."))
 	}
 	sb.WriteString("\n/cancel - отмена")
 
 	h.editOrSend(ctx, chatID, messageID, sb.String(), nil)
 }
 
+// withMediaSizes дозапрашивает размер файлов, загруженных до сохранения сведений о медиа,
+// и запоминает его, чтобы не обращаться к Telegram при каждом открытии меню
+func (h *AdminHandler) withMediaSizes(ctx context.Context, images []models.StepImage) []models.StepImage {
+	if h.media == nil {
+		return images
+	}
+
+	for i, img := range images {
+		if img.FileSize > 0 {
+			continue
+		}
+		size, err := h.media.FileSize(ctx, img.FileID)
+		if err != nil || size == 0 {
+			continue
+		}
+		images[i].FileSize = size
+		h.stepRepo.SetMediaInfo(img.StepID, img.Position, img.Width, img.Height, size)
+	}
+	return images
+}
+
+// saveMediaInfo сохраняет разрешение и размер загруженного медиа шага
+func (h *AdminHandler) saveMediaInfo(ctx context.Context, stepID int64, position int, msg *tgmodels.Message) {
+	if h.media == nil {
+		return
+	}
+
+	info := h.media.InfoFromMessage(ctx, msg)
+	if err := h.stepRepo.SetMediaInfo(stepID, position, info.Width, info.Height, info.FileSize); err != nil {
+		log.Printf("[ADMIN] Error saving media info for step %d: %v", stepID, err)
+	}
+}
+
+func stepMediaLabel(img models.StepImage) string {
+	if img.IsAnimation() {
+		return "GIF"
+	}
+	return "Изображение"
+}
+
+// isStepMedia сообщает, что в сообщении есть фото или GIF для шага
+func isStepMedia(msg *tgmodels.Message) bool {
+	_, _, ok := stepMediaFromMessage(msg)
+	return ok
+}
+
+// stepMediaFromMessage возвращает file_id и тип медиа шага из сообщения администратора:
+// фотографию или анимацию (GIF)
+func stepMediaFromMessage(msg *tgmodels.Message) (fileID, mediaType string, ok bool) {
+	if msg.Animation != nil {
+		return msg.Animation.FileID, models.MediaTypeAnimation, true
+	}
+	if len(msg.Photo) > 0 {
+		return msg.Photo[len(msg.Photo)-1].FileID, models.MediaTypePhoto, true
+	}
+	return "", "", false
+}
+
 func (h *AdminHandler) handleAddImage(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
-	if len(msg.Photo) == 0 {
+	fileID, mediaType, ok := stepMediaFromMessage(msg)
+	if !ok {
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: msg.Chat.ID,
-			Text:   "⚠️ Отправьте изображение",
+			Text:   "⚠️ Отправьте изображение или GIF",
 		})
 		return true
 	}
 
-	fileID := msg.Photo[len(msg.Photo)-1].FileID
 	imageCount, _ := h.stepRepo.GetImageCount(state.EditingStepID)
 
-	if err := h.stepRepo.AddImage(state.EditingStepID, fileID, imageCount); err != nil {
+	if err := h.stepRepo.AddMedia(state.EditingStepID, fileID, mediaType, imageCount); err != nil {
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: msg.Chat.ID,
 			Text:   "⚠️ Ошибка при добавлении изображения",
 		})
 		return true
 	}
+	h.saveMediaInfo(ctx, state.EditingStepID, imageCount, msg)
 
 	h.adminStateRepo.Clear(h.adminID)
 
@@ -2095,28 +5965,28 @@ func (h *AdminHandler) handleReplaceImage(ctx context.Context, msg *tgmodels.Mes
 
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: msg.Chat.ID,
-			Text:   "📷 Теперь отправьте новое изображение:",
+			Text:   "📷 Теперь отправьте новое изображение или GIF:",
 		})
 		return true
 	}
 
-	if len(msg.Photo) == 0 {
+	fileID, mediaType, ok := stepMediaFromMessage(msg)
+	if !ok {
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: msg.Chat.ID,
-			Text:   "⚠️ Отправьте изображение",
+			Text:   "⚠️ Отправьте изображение или GIF",
 		})
 		return true
 	}
 
-	fileID := msg.Photo[len(msg.Photo)-1].FileID
-
-	if err := h.stepRepo.ReplaceImage(state.EditingStepID, state.ImagePosition, fileID); err != nil {
+	if err := h.stepRepo.ReplaceMedia(state.EditingStepID, state.ImagePosition, fileID, mediaType); err != nil {
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: msg.Chat.ID,
 			Text:   "⚠️ Ошибка при замене изображения",
 		})
 		return true
 	}
+	h.saveMediaInfo(ctx, state.EditingStepID, state.ImagePosition, msg)
 
 	h.adminStateRepo.Clear(h.adminID)
 
@@ -2428,70 +6298,296 @@ func (h *AdminHandler) exportSteps(ctx context.Context, chatID int64, messageID
 		return
 	}
 
-	activeCount := 0
-	for _, step := range steps {
-		if step.IsActive {
-			activeCount++
+	mode, err := h.settingsRepo.GetExportMode()
+	if err != nil {
+		mode = exportModeAuto
+	}
+	threshold, err := h.settingsRepo.GetExportFileThreshold()
+	if err != nil {
+		threshold = defaultExportFileThreshold
+	}
+
+	var notes map[int64]string
+	if includeNotes, _ := h.settingsRepo.GetExportAuthorNotes(); includeNotes {
+		if notes, err = h.stepRepo.GetAuthorNotes(); err != nil {
+			log.Printf("[ADMIN] Failed to load author notes for export: %v", err)
 		}
 	}
 
-	const maxMessageLength = 6000
-	var currentMessage strings.Builder
+	plan := h.planStepsExport(steps, notes, mode, threshold)
 
-	for i, step := range steps {
-		stepText := h.formatStepForExport(step)
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "⬅️ Назад", CallbackData: "admin:menu"}},
+		},
+	}
 
-		if currentMessage.Len()+len(stepText) > maxMessageLength && currentMessage.Len() > 0 {
-			h.bot.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID:    chatID,
-				Text:      currentMessage.String(),
-				ParseMode: tgmodels.ParseModeHTML,
-			})
-			currentMessage.Reset()
+	if plan.AsDocument {
+		filename := fmt.Sprintf("quest_steps_%s.html", time.Now().Format("2006-01-02_15-04-05"))
+		_, err := h.bot.SendDocument(ctx, &bot.SendDocumentParams{
+			ChatID: chatID,
+			Document: &tgmodels.InputFileUpload{
+				Filename: filename,
+				Data:     strings.NewReader(plan.Document),
+			},
+			Caption:     fmt.Sprintf("📤 <b>Экспорт заданий</b>\n\n📋 Всего заданий: %d", len(steps)),
+			ParseMode:   tgmodels.ParseModeHTML,
+			ReplyMarkup: keyboard,
+		})
+		if err != nil {
+			log.Printf("[ADMIN] Failed to send export document: %v", err)
+			h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("⚠️ Ошибка при отправке файла: %v", err), keyboard)
 		}
+		return
+	}
 
-		currentMessage.WriteString(stepText)
-
-		if i < len(steps)-1 {
-			currentMessage.WriteString("\n")
+	for i, text := range plan.Messages {
+		var messageKeyboard *tgmodels.InlineKeyboardMarkup
+		if i == len(plan.Messages)-1 {
+			messageKeyboard = keyboard
 		}
+		h.editOrSendLong(ctx, chatID, 0, text, messageKeyboard)
 	}
+}
+
+// ─── Конфигурация квеста ──────────────────────────────────────────────────────
 
+// showQuestConfigMenu показывает выгрузку и загрузку полной конфигурации квеста: шагов,
+// глав, настроек, достижений и синонимов одним файлом
+func (h *AdminHandler) showQuestConfigMenu(ctx context.Context, chatID int64, messageID int) {
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "📤 Выгрузить", CallbackData: "admin:quest_config_export"}},
+			{{Text: "📥 Загрузить", CallbackData: "admin:quest_config_import"}},
 			{{Text: "⬅️ Назад", CallbackData: "admin:menu"}},
 		},
 	}
 
-	if currentMessage.Len() > 0 {
+	h.editOrSend(ctx, chatID, messageID, "📦 <b>Конфигурация квеста</b>\n\nШаги, главы, настройки, достижения и синонимы одним JSON-файлом — для переноса квеста на другой экземпляр бота или отката после неудачной правки.\n\nПрогресс участников и состояние квеста в файл не попадают. Изображения и документы передаются по file_id, поэтому работают только в том же боте.", keyboard)
+}
+
+func (h *AdminHandler) exportQuestConfig(ctx context.Context, chatID int64, messageID int) {
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "⬅️ Назад", CallbackData: "admin:quest_config"}},
+		},
+	}
+
+	data, err := h.questConfig.Export()
+	if err != nil {
+		log.Printf("[ADMIN] Failed to export quest config: %v", err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при выгрузке конфигурации", keyboard)
+		return
+	}
+
+	filename := fmt.Sprintf("quest_config_%s.json", time.Now().Format("2006-01-02_15-04-05"))
+	_, err = h.bot.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID: chatID,
+		Document: &tgmodels.InputFileUpload{
+			Filename: filename,
+			Data:     bytes.NewReader(data),
+		},
+		Caption:     "📦 <b>Конфигурация квеста</b>",
+		ParseMode:   tgmodels.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		log.Printf("[ADMIN] Failed to send quest config: %v", err)
+		h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("⚠️ Ошибка при отправке файла: %v", err), keyboard)
+	}
+}
+
+func (h *AdminHandler) startImportQuestConfig(ctx context.Context, chatID int64, messageID int) {
+	state := &models.AdminState{
+		UserID:       h.adminID,
+		CurrentState: fsm.StateAdminImportQuestConfig,
+	}
+	h.adminStateRepo.Save(state)
+
+	h.editOrSend(ctx, chatID, messageID, "📥 Отправьте JSON-файл конфигурации квеста.\n\nПеред применением будет показан список изменений.\n\n/cancel - отмена", nil)
+}
+
+func (h *AdminHandler) handleImportQuestConfig(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
+	if msg.Document == nil {
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:      chatID,
-			Text:        currentMessage.String(),
-			ReplyMarkup: keyboard,
-			ParseMode:   tgmodels.ParseModeHTML,
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Пожалуйста, отправьте JSON-файл или /cancel для отмены.",
 		})
-	} /* else {
+		return true
+	}
+
+	data, err := h.media.Download(ctx, msg.Document.FileID)
+	if err != nil {
+		log.Printf("[ADMIN] Failed to download quest config: %v", err)
 		h.bot.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:      chatID,
-			Text:        "✅ <b>Экспорт завершен</b>",
-			ReplyMarkup: keyboard,
-			ParseMode:   tgmodels.ParseModeHTML,
+			ChatID: msg.Chat.ID,
+			Text:   "⚠️ Не удалось скачать файл, попробуйте ещё раз",
+		})
+		return true
+	}
+
+	merge, err := h.questConfig.Diff(data, services.QuestConfigMerge)
+	if err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   fmt.Sprintf("⚠️ %v", err),
 		})
-	}*/
+		return true
+	}
+	replace, err := h.questConfig.Diff(data, services.QuestConfigReplace)
+	if err != nil {
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   fmt.Sprintf("⚠️ %v", err),
+		})
+		return true
+	}
+
+	state.EditingSetting = msg.Document.FileID
+	h.adminStateRepo.Save(state)
+
+	text := fmt.Sprintf("📥 <b>Загрузка конфигурации</b>\n\n<b>Объединить</b> — добавить и обновить, ничего не удаляя:\n%s\n\n<b>Заменить</b> — привести квест в точное соответствие с файлом:\n%s",
+		html.EscapeString(services.FormatQuestConfigDiff(merge)),
+		html.EscapeString(services.FormatQuestConfigDiff(replace)),
+	)
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "🔀 Объединить", CallbackData: "admin:quest_config_apply:" + string(services.QuestConfigMerge)}},
+			{{Text: "♻️ Заменить", CallbackData: "admin:quest_config_apply:" + string(services.QuestConfigReplace)}},
+			{{Text: "❌ Отмена", CallbackData: "admin:quest_config"}},
+		},
+	}
+
+	h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      msg.Chat.ID,
+		Text:        text,
+		ParseMode:   tgmodels.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	return true
+}
+
+func (h *AdminHandler) applyQuestConfig(ctx context.Context, chatID int64, messageID int, data string) {
+	mode := services.QuestConfigImportMode(strings.TrimPrefix(data, "admin:quest_config_apply:"))
+	if mode != services.QuestConfigMerge && mode != services.QuestConfigReplace {
+		return
+	}
+
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "⬅️ Назад", CallbackData: "admin:quest_config"}},
+		},
+	}
+
+	state, err := h.adminStateRepo.Get(h.adminID)
+	if err != nil || state == nil || state.CurrentState != fsm.StateAdminImportQuestConfig || state.EditingSetting == "" {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Файл конфигурации не найден, загрузите его заново", keyboard)
+		return
+	}
+
+	content, err := h.media.Download(ctx, state.EditingSetting)
+	if err != nil {
+		log.Printf("[ADMIN] Failed to download quest config: %v", err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Не удалось скачать файл, попробуйте ещё раз", keyboard)
+		return
+	}
+
+	diff, err := h.questConfig.Import(content, mode)
+	if err != nil {
+		log.Printf("[ADMIN] Failed to import quest config: %v", err)
+		h.editOrSend(ctx, chatID, messageID, fmt.Sprintf("⚠️ Ошибка при загрузке конфигурации: %s", html.EscapeString(err.Error())), keyboard)
+		return
+	}
+
+	h.adminStateRepo.Clear(h.adminID)
+	h.statsService.InvalidateCache()
+
+	h.editOrSend(ctx, chatID, messageID, "✅ <b>Конфигурация загружена</b>\n\n"+html.EscapeString(services.FormatQuestConfigDiff(diff)), keyboard)
+}
+
+const (
+	exportModeAuto   = "auto"
+	exportModeInline = "inline"
+	exportModeFile   = "file"
+
+	defaultExportFileThreshold = 50
+	exportMaxMessageLength     = services.MaxMessageLength
+	exportMaxInlineMessages    = 5
+)
+
+type stepsExportPlan struct {
+	AsDocument bool
+	Messages   []string
+	Document   string
+}
+
+// planStepsExport раскладывает экспорт шагов по сообщениям или в файл. notes — заметки авторов
+// по ID шага, попадают в экспорт, только если переданы
+func (h *AdminHandler) planStepsExport(steps []*models.Step, notes map[int64]string, mode string, threshold int) stepsExportPlan {
+	var messages []string
+	var currentMessage strings.Builder
+	totalLength := 0
+
+	for i, step := range steps {
+		stepText := h.formatStepForExport(step, notes[step.ID])
+		totalLength += len(stepText)
+
+		if currentMessage.Len()+len(stepText) > exportMaxMessageLength && currentMessage.Len() > 0 {
+			messages = append(messages, currentMessage.String())
+			currentMessage.Reset()
+		}
+
+		currentMessage.WriteString(stepText)
+
+		if i < len(steps)-1 {
+			currentMessage.WriteString("\n")
+		}
+	}
+
+	if currentMessage.Len() > 0 {
+		messages = append(messages, currentMessage.String())
+	}
+
+	asDocument := false
+	switch mode {
+	case exportModeFile:
+		asDocument = true
+	case exportModeInline:
+		asDocument = false
+	default:
+		if threshold <= 0 {
+			threshold = defaultExportFileThreshold
+		}
+		asDocument = len(steps) > threshold || totalLength > exportMaxMessageLength*exportMaxInlineMessages
+	}
+
+	if !asDocument {
+		return stepsExportPlan{Messages: messages}
+	}
+
+	var doc strings.Builder
+	doc.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Экспорт заданий</title>\n</head>\n")
+	doc.WriteString("<body style=\"white-space: pre-wrap; font-family: sans-serif;\">\n")
+	for _, step := range steps {
+		doc.WriteString(h.formatStepForExport(step, notes[step.ID]))
+	}
+	doc.WriteString("</body>\n</html>\n")
+
+	return stepsExportPlan{AsDocument: true, Document: doc.String()}
 }
 
-func (h *AdminHandler) formatStepForExport(step *models.Step) string {
+func (h *AdminHandler) formatStepForExport(step *models.Step, authorNote string) string {
 	var stepData strings.Builder
 	stepText := step.Text
 	if step.IsAsterisk {
 		stepText = "⭐ " + stepText
 	}
-	stepData.WriteString("<b>" + stepText + "</b>\n")
+	stepData.WriteString("<b>" + escapeExportText(stepText) + "</b>\n")
 
 	if step.HasHint() {
 		stepData.WriteString("<b>Подсказка:</b> ")
 		if step.HintText != "" {
-			stepData.WriteString("<i>" + strings.ReplaceAll(step.HintText, "\n", " ") + "</i>\n")
+			stepData.WriteString("<i>" + escapeExportText(strings.ReplaceAll(step.HintText, "\n", " ")) + "</i>\n")
 		} else {
 			stepData.WriteString("🖼️ <i>изображение</i>\n")
 		}
@@ -2499,7 +6595,34 @@ func (h *AdminHandler) formatStepForExport(step *models.Step) string {
 
 	if len(step.Answers) > 0 {
 		stepData.WriteString("<b>Ответы:</b> ")
-		stepData.WriteString(strings.Join(step.Answers, ", ") + "\n")
+		answers := make([]string, len(step.Answers))
+		for i, answer := range step.Answers {
+			answers[i] = escapeExportText(answer)
+		}
+		stepData.WriteString(strings.Join(answers, ", ") + "\n")
+	}
+
+	if len(step.Choices) > 0 {
+		choices := make([]string, len(step.Choices))
+		for i, choice := range step.Choices {
+			choices[i] = escapeExportText(choice.Text)
+			if choice.IsCorrect {
+				choices[i] = "✅ " + choices[i]
+			}
+		}
+		stepData.WriteString("<b>Варианты выбора:</b> " + strings.Join(choices, ", ") + "\n")
+	}
+
+	if len(step.Documents) > 0 {
+		documents := make([]string, len(step.Documents))
+		for i, document := range step.Documents {
+			documents[i] = escapeExportText(documentLabel(document))
+		}
+		stepData.WriteString("<b>Файлы:</b> " + strings.Join(documents, ", ") + "\n")
+	}
+
+	if authorNote != "" {
+		stepData.WriteString("<b>Заметка автора:</b> <i>" + escapeExportText(strings.ReplaceAll(authorNote, "\n", " ")) + "</i>\n")
 	}
 
 	stepData.WriteString("\n")
@@ -2507,6 +6630,12 @@ func (h *AdminHandler) formatStepForExport(step *models.Step) string {
 	return stepData.String()
 }
 
+// escapeExportText экранирует текст для HTML-экспорта. Уже экранированные сущности
+// (например, &amp;) сначала раскрываются, чтобы не получить двойное экранирование
+func escapeExportText(text string) string {
+	return html.EscapeString(html.UnescapeString(text))
+}
+
 func (h *AdminHandler) showUserAchievements(ctx context.Context, chatID int64, messageID int, data string) {
 	// Verify caller has admin privileges - additional security check
 	if chatID != h.adminID {
@@ -2566,6 +6695,12 @@ func (h *AdminHandler) showUserAchievements(ctx context.Context, chatID int64, m
 		}
 	}
 
+	if summary.TotalCount > 0 {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: "🗂 Сбросить категорию", CallbackData: fmt.Sprintf("reset_category:%d", userID)},
+		})
+	}
+
 	// Добавляем кнопку "Назад"
 	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
 		{Text: "⬅️ Назад к пользователю", CallbackData: fmt.Sprintf("user:%d", userID)},
@@ -2575,7 +6710,7 @@ func (h *AdminHandler) showUserAchievements(ctx context.Context, chatID int64, m
 		InlineKeyboard: buttons,
 	}
 
-	h.editOrSend(ctx, chatID, messageID, text, keyboard)
+	h.editOrSendLong(ctx, chatID, messageID, text, keyboard)
 }
 
 func (h *AdminHandler) FormatUserAchievements(user *models.User, summary *services.UserAchievementSummary, userID int64) string {
@@ -2700,11 +6835,75 @@ func (h *AdminHandler) showAchievementStatistics(ctx context.Context, chatID int
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
 			{{Text: "🏅 Лидеры по достижениям", CallbackData: "admin:achievement_leaders"}},
+			{{Text: "🩺 Проверка целостности", CallbackData: "admin:achievement_audit"}},
+			{{Text: "♻️ Пересчитать достижения", CallbackData: "admin:achievement_recompute"}},
+			{{Text: "🔔 Уведомления о достижениях", CallbackData: "admin:achievement_notify"}},
 			{{Text: "⬅️ Назад", CallbackData: "admin:menu"}},
 		},
 	}
 
-	h.editOrSend(ctx, chatID, messageID, text, keyboard)
+	h.editOrSendLong(ctx, chatID, messageID, text, keyboard)
+}
+
+// showAchievementNotifySettings показывает достижения с переключателями уведомлений:
+// для отключённых достижение выдаётся молча, без сообщения и стикера
+func (h *AdminHandler) showAchievementNotifySettings(ctx context.Context, chatID int64, messageID int) {
+	if h.achievementService == nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Система достижений недоступна", nil)
+		return
+	}
+
+	achievements, err := h.achievementService.GetAllAchievements()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении достижений", nil)
+		return
+	}
+
+	var buttons [][]tgmodels.InlineKeyboardButton
+	for _, achievement := range achievements {
+		buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+			{Text: achievementNotifyLabel(achievement), CallbackData: fmt.Sprintf("admin:achievement_notify_toggle:%d", achievement.ID)},
+		})
+	}
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "⬅️ Назад к статистике", CallbackData: "admin:achievement_stats"},
+	})
+
+	text := "🔔 <b>Уведомления о достижениях</b>\n\nНажмите на достижение, чтобы включить или отключить уведомление и стикер. Достижения с 🔕 выдаются без сообщения участнику"
+	h.editOrSend(ctx, chatID, messageID, text, &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+func achievementNotifyLabel(achievement *models.Achievement) string {
+	if achievement.NotifyOnAward {
+		return "🔔 " + achievement.Name
+	}
+	return "🔕 " + achievement.Name
+}
+
+func (h *AdminHandler) toggleAchievementNotify(ctx context.Context, chatID int64, messageID int, data string) {
+	achievementID, _ := parseInt64(strings.TrimPrefix(data, "admin:achievement_notify_toggle:"))
+	if achievementID == 0 || h.achievementService == nil {
+		return
+	}
+
+	achievements, err := h.achievementService.GetAllAchievements()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при получении достижений", nil)
+		return
+	}
+
+	for _, achievement := range achievements {
+		if achievement.ID != achievementID {
+			continue
+		}
+		if err := h.achievementService.SetNotifyOnAward(achievementID, !achievement.NotifyOnAward); err != nil {
+			h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при изменении уведомления", nil)
+			return
+		}
+		break
+	}
+
+	h.showAchievementNotifySettings(ctx, chatID, messageID)
 }
 
 func (h *AdminHandler) FormatAchievementStatistics(stats *services.AchievementStatistics) string {
@@ -2780,7 +6979,7 @@ func (h *AdminHandler) showAchievementLeaders(ctx context.Context, chatID int64,
 		},
 	}
 
-	h.editOrSend(ctx, chatID, messageID, text, keyboard)
+	h.editOrSendLong(ctx, chatID, messageID, text, keyboard)
 }
 
 func FormatAchievementLeaders(rankings []services.UserAchievementRanking) string {
@@ -2817,6 +7016,187 @@ func FormatAchievementLeaders(rankings []services.UserAchievementRanking) string
 
 	return sb.String()
 }
+
+func (h *AdminHandler) showAchievementAudit(ctx context.Context, chatID int64, messageID int) {
+	if h.achievementEngine == nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Система достижений недоступна", nil)
+		return
+	}
+
+	inconsistencies, err := h.achievementEngine.AuditConsistency()
+	if err != nil {
+		log.Printf("[ADMIN] Error auditing achievements: %v", err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при проверке достижений", nil)
+		return
+	}
+
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "🔄 Обновить", CallbackData: "admin:achievement_audit"}},
+			{{Text: "⬅️ Назад", CallbackData: "admin:achievement_stats"}},
+		},
+	}
+
+	h.editOrSendLong(ctx, chatID, messageID, FormatAchievementAudit(inconsistencies), keyboard)
+}
+
+func (h *AdminHandler) showAchievementRecompute(ctx context.Context, chatID int64, messageID int) {
+	text := "♻️ <b>Пересчёт достижений</b>\n\n" +
+		"Достижения за прогресс, подсказки, завершение и составные будут заново проверены " +
+		"для всех участников по текущим порогам и условиям.\n\n" +
+		"• <b>Только выдать</b> — недостающие достижения будут выданы, уже выданные останутся\n" +
+		"• <b>Выдать и снять</b> — дополнительно будут сняты достижения за прогресс и подсказки, " +
+		"порог которых больше не достигнут, и составные без обязательных достижений"
+
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "➕ Только выдать", CallbackData: "admin:achievement_recompute:award"}},
+			{{Text: "🔁 Выдать и снять", CallbackData: "admin:achievement_recompute:revoke"}},
+			{{Text: "⬅️ Назад", CallbackData: "admin:achievement_stats"}},
+		},
+	}
+
+	h.editOrSend(ctx, chatID, messageID, text, keyboard)
+}
+
+func (h *AdminHandler) handleAchievementRecompute(ctx context.Context, chatID int64, messageID int, data string) {
+	if h.achievementEngine == nil {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Система достижений недоступна", nil)
+		return
+	}
+
+	revoke := strings.TrimPrefix(data, "admin:achievement_recompute:") == "revoke"
+	summary, err := h.achievementEngine.RecomputeAllAchievements(revoke)
+	if err != nil {
+		log.Printf("[ADMIN] Error recomputing achievements: %v", err)
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Ошибка при пересчёте достижений", nil)
+		return
+	}
+
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "⬅️ Назад", CallbackData: "admin:achievement_stats"}},
+		},
+	}
+
+	h.editOrSendLong(ctx, chatID, messageID, FormatAchievementRecompute(summary), keyboard)
+}
+
+// FormatAchievementRecompute описывает итог массового пересчёта: сколько участников затронуто
+// и сколько раз каждое достижение выдано или снято
+func FormatAchievementRecompute(summary *services.RecomputeSummary) string {
+	var sb strings.Builder
+	sb.WriteString("♻️ <b>Пересчёт достижений завершён</b>\n\n")
+	sb.WriteString(fmt.Sprintf("Проверено участников: %d\n", summary.UsersChecked))
+	sb.WriteString(fmt.Sprintf("Изменения у участников: %d\n", summary.UsersChanged))
+
+	if summary.UsersChanged == 0 {
+		sb.WriteString("\n✅ Все достижения соответствуют текущим условиям")
+		return sb.String()
+	}
+
+	writeCounts := func(title string, counts map[string]int) {
+		if len(counts) == 0 {
+			return
+		}
+		keys := make([]string, 0, len(counts))
+		for key := range counts {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		sb.WriteString("\n" + title + "\n")
+		for _, key := range keys {
+			sb.WriteString(fmt.Sprintf("• <code>%s</code>: %d\n", html.EscapeString(key), counts[key]))
+		}
+	}
+	writeCounts("➕ <b>Выдано:</b>", summary.Awarded)
+	writeCounts("➖ <b>Снято:</b>", summary.Revoked)
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func FormatAchievementAudit(inconsistencies []services.Inconsistency) string {
+	var sb strings.Builder
+	sb.WriteString("🩺 <b>Проверка целостности достижений</b>\n\n")
+
+	if len(inconsistencies) == 0 {
+		sb.WriteString("✅ Несоответствий не найдено")
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("⚠️ Найдено несоответствий: %d\n\n", len(inconsistencies)))
+
+	for _, inconsistency := range inconsistencies {
+		userIDs := make([]string, 0, len(inconsistency.UserIDs))
+		for _, userID := range inconsistency.UserIDs {
+			userIDs = append(userIDs, fmt.Sprintf("%d", userID))
+		}
+		sb.WriteString(
+			fmt.Sprintf(
+				"• <code>%s</code>: %s (пользователи: %s)\n",
+				html.EscapeString(inconsistency.AchievementKey),
+				html.EscapeString(inconsistency.Description),
+				strings.Join(userIDs, ", "),
+			),
+		)
+	}
+
+	return sb.String()
+}
+
+// FormatOrphanCleanup формирует отчёт администратору об удалённых «осиротевших» строках
+func FormatOrphanCleanup(result *db.OrphanCleanupResult) string {
+	var sb strings.Builder
+	sb.WriteString("🧹 <b>Очистка базы данных</b>\n\n")
+	sb.WriteString(fmt.Sprintf("🖼 Изображения ответов: %d\n", result.AnswerImages))
+	sb.WriteString(fmt.Sprintf("💬 Ответы: %d\n", result.UserAnswers))
+	sb.WriteString(fmt.Sprintf("📊 Прогресс: %d\n", result.UserProgress))
+	sb.WriteString(fmt.Sprintf("📋 Данные шагов: %d", result.StepRelations))
+	return sb.String()
+}
+
+func FormatDailyDigest(digest *services.DailyDigest, userName func(int64) string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📰 <b>Сводка за %s</b>\n\n", digest.Day.Format("02.01.2006")))
+	sb.WriteString(fmt.Sprintf("✅ Пройдено шагов: %d\n", digest.CompletedSteps))
+	sb.WriteString(fmt.Sprintf("👥 Активных участников: %d\n", digest.ActiveUsers))
+
+	if len(digest.QuestCompletions) > 0 {
+		sb.WriteString(fmt.Sprintf("\n🏁 <b>Прошли квест (%d):</b>\n", len(digest.QuestCompletions)))
+		for _, userID := range digest.QuestCompletions {
+			sb.WriteString(fmt.Sprintf("• %s\n", html.EscapeString(userName(userID))))
+		}
+	}
+
+	if len(digest.NotableAchievements) > 0 {
+		sb.WriteString("\n🏆 <b>Заметные достижения:</b>\n")
+		for _, achievement := range digest.NotableAchievements {
+			sb.WriteString(fmt.Sprintf("• %s — %s\n", html.EscapeString(userName(achievement.UserID)), html.EscapeString(achievement.Name)))
+		}
+	}
+
+	if len(digest.StuckUsers) > 0 {
+		sb.WriteString("\n🧱 <b>Застряли:</b>\n")
+		for _, stuck := range digest.StuckUsers {
+			sb.WriteString(fmt.Sprintf("• %s — шаг %d, попыток: %d\n", html.EscapeString(userName(stuck.UserID)), stuck.StepOrder, stuck.Attempts))
+		}
+	}
+
+	return sb.String()
+}
+
+func (h *AdminHandler) sendDiagnostics(ctx context.Context, chatID int64) {
+	if h.diagnostics == nil {
+		return
+	}
+
+	report := h.diagnostics.Collect(ctx)
+	if err := h.msgManager.SendLong(ctx, chatID, services.FormatDiagnosticsReport(report), nil); err != nil {
+		log.Printf("[ADMIN] Failed to send diagnostics: %v", err)
+	}
+}
+
 func (h *AdminHandler) createBackup(ctx context.Context, chatID int64, messageID int) {
 	h.editOrSend(ctx, chatID, messageID, "💾 <i>Создаю бэкап базы данных...</i>", nil)
 
@@ -2850,6 +7230,10 @@ func (h *AdminHandler) createBackup(ctx context.Context, chatID int64, messageID
 		return
 	}
 
+	if err := h.settingsRepo.SetLastBackupAt(time.Now()); err != nil {
+		log.Printf("[BACKUP] Failed to save backup time: %v", err)
+	}
+
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
 			{{Text: "⬅️ Назад", CallbackData: "admin:menu"}},
@@ -2977,6 +7361,14 @@ func (h *AdminHandler) dumpTableGo(db *sql.DB, dump *strings.Builder, tableName
 	return nil
 }
 
+// statsRefreshPrefix — префикс кнопки «Обновить» на экранах статистики: перед показом экрана
+// закэшированная статистика сбрасывается и пересчитывается
+const statsRefreshPrefix = "admin:stats_refresh:"
+
+func statsRefreshButton(target string) tgmodels.InlineKeyboardButton {
+	return tgmodels.InlineKeyboardButton{Text: "🔄 Обновить", CallbackData: statsRefreshPrefix + target}
+}
+
 func (h *AdminHandler) showStatistics(ctx context.Context, chatID int64, messageID int) {
 	stats, err := h.statsService.CalculateStats()
 	if err != nil {
@@ -3013,17 +7405,31 @@ func (h *AdminHandler) showStatistics(ctx context.Context, chatID int64, message
 	}
 
 	if len(stats.Leaders) > 0 {
+		var scores map[int64]int
+		settings, _ := h.settingsRepo.GetAll()
+		if settings != nil && settings.ScoringEnabled {
+			scores, err = h.statsService.GetScores(settings.ScorePointsPerStep, settings.ScoreHintPenalty)
+			if err != nil {
+				log.Printf("[ADMIN] Error GetScores: %v", err)
+			}
+		}
+
 		sb.WriteString("\n🏆 <b>Лидеры</b>\n")
 		maxLeaders := 10
 		if len(stats.Leaders) < maxLeaders {
 			maxLeaders = len(stats.Leaders)
 		}
 		for i := 0; i < maxLeaders; i++ {
+			scoreText := ""
+			if scores != nil {
+				scoreText = fmt.Sprintf(" — ⭐ %d", scores[stats.Leaders[i].ID])
+			}
 			sb.WriteString(
 				fmt.Sprintf(
-					"  %d. %s\n",
+					"  %d. %s%s\n",
 					i+1,
 					html.EscapeString(stats.Leaders[i].DisplayName()),
+					scoreText,
 				),
 			)
 		}
@@ -3031,12 +7437,12 @@ func (h *AdminHandler) showStatistics(ctx context.Context, chatID int64, message
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:statistics"}},
+			{statsRefreshButton("admin:statistics")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:menu"}},
 		},
 	}
 
-	h.editOrSend(ctx, chatID, messageID, sb.String(), keyboard)
+	h.editOrSendLong(ctx, chatID, messageID, sb.String(), keyboard)
 }
 
 func (h *AdminHandler) notifyAchievements(ctx context.Context, userID int64, achievementKeys []string) {
@@ -3066,19 +7472,72 @@ func (h *AdminHandler) startSendMessage(ctx context.Context, chatID int64, messa
 
 	// Show message type selection menu
 	prompt := fmt.Sprintf("💬 Отправка сообщения пользователю %s\n\nВыберите тип сообщения:", html.EscapeString(user.DisplayName()))
-	keyboard := &tgmodels.InlineKeyboardMarkup{
-		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{
-				{Text: "📝 Текст", CallbackData: fmt.Sprintf("admin:send_msg_type:text:%d", userID)},
-				{Text: "🖼 Фото", CallbackData: fmt.Sprintf("admin:send_msg_type:photo:%d", userID)},
-				{Text: "📎 Документ", CallbackData: fmt.Sprintf("admin:send_msg_type:document:%d", userID)},
-			},
-			{
-				{Text: "❌ Отмена", CallbackData: fmt.Sprintf("admin:send_msg_cancel:%d", userID)},
-			},
+	buttons := [][]tgmodels.InlineKeyboardButton{
+		{
+			{Text: "📝 Текст", CallbackData: fmt.Sprintf("admin:send_msg_type:text:%d", userID)},
+			{Text: "🖼 Фото", CallbackData: fmt.Sprintf("admin:send_msg_type:photo:%d", userID)},
+			{Text: "📎 Документ", CallbackData: fmt.Sprintf("admin:send_msg_type:document:%d", userID)},
 		},
 	}
-	h.editOrSend(ctx, chatID, messageID, prompt, keyboard)
+
+	if h.adminMessagesRepo != nil {
+		if thread, err := h.adminMessagesRepo.GetSupportThread(userID, SupportThreadPreviewSize); err == nil && len(thread) > 0 {
+			prompt = fmt.Sprintf("💬 Отправка сообщения пользователю %s\n\n%s\n\nВыберите тип сообщения:", html.EscapeString(user.DisplayName()), FormatSupportThread(thread))
+		}
+		if open, err := h.adminMessagesRepo.IsSupportThreadOpen(userID); err == nil && open {
+			buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+				{Text: "✖️ Закрыть переписку", CallbackData: fmt.Sprintf("admin:close_thread:%d", userID)},
+			})
+		}
+	}
+
+	buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+		{Text: "❌ Отмена", CallbackData: fmt.Sprintf("admin:send_msg_cancel:%d", userID)},
+	})
+	h.editOrSend(ctx, chatID, messageID, prompt, &tgmodels.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+// SupportThreadPreviewSize — сколько последних сообщений переписки показывать администратору
+const SupportThreadPreviewSize = 5
+
+// FormatSupportThread форматирует последние сообщения переписки с участником
+func FormatSupportThread(thread []db.SupportMessage) string {
+	var sb strings.Builder
+	sb.WriteString("💬 <b>Переписка</b>")
+	for _, msg := range thread {
+		author := "👤"
+		if msg.FromAdmin {
+			author = "🛡"
+		}
+		fmt.Fprintf(&sb, "\n%s %s: %s", author, msg.CreatedAt.Format("02.01 15:04"), html.EscapeString(msg.Text))
+	}
+	return sb.String()
+}
+
+// FormatSupportReply форматирует пересылаемый администратору ответ участника
+// вместе с контекстом: кто ответил и на каком он шаге
+func FormatSupportReply(displayName string, userID int64, step *models.Step, text string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "💬 <b>Ответ в переписке</b> от %s (ID: <code>%d</code>)\n", html.EscapeString(displayName), userID)
+	if step != nil {
+		fmt.Fprintf(&sb, "📋 Шаг %d: <i>%s</i>\n", step.StepOrder, html.EscapeString(truncateText(step.Text, 100)))
+	}
+	sb.WriteString("\n" + html.EscapeString(text))
+	return sb.String()
+}
+
+func (h *AdminHandler) handleCloseSupportThread(ctx context.Context, chatID int64, messageID int, data string) {
+	userID, err := parseInt64(strings.TrimPrefix(data, "admin:close_thread:"))
+	if err != nil || userID == 0 {
+		h.editOrSend(ctx, chatID, messageID, "⚠️ Неверный ID пользователя", nil)
+		return
+	}
+
+	if err := h.adminMessagesRepo.CloseSupportThread(userID); err != nil {
+		h.editOrSend(ctx, chatID, messageID, "❌ Ошибка закрытия переписки", nil)
+		return
+	}
+	h.showUserDetails(ctx, chatID, messageID, fmt.Sprintf("user:%d", userID))
 }
 
 func (h *AdminHandler) handleSendMessageTypeSelect(ctx context.Context, chatID int64, messageID int, data string) {
@@ -3180,10 +7639,13 @@ func (h *AdminHandler) sendMessageToUser(ctx context.Context, adminChatID int64,
 	}
 
 	// Send message to target user
-	_, err = h.bot.SendMessage(ctx, &bot.SendMessageParams{
+	sent, err := h.bot.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: targetUserID,
 		Text:   message,
 	})
+	if err == nil {
+		h.recordSupportMessage(targetUserID, message, sent)
+	}
 
 	// Award achievement for receiving message from admin
 	if err == nil && h.achievementEngine != nil {
@@ -3218,6 +7680,17 @@ func (h *AdminHandler) sendMessageToUser(ctx context.Context, adminChatID int64,
 	h.showUserDetails(ctx, adminChatID, 0, fmt.Sprintf("user:%d", targetUserID))
 }
 
+// recordSupportMessage сохраняет отправленное участнику сообщение в переписку,
+// чтобы его ответ на это сообщение дошёл до администратора
+func (h *AdminHandler) recordSupportMessage(targetUserID int64, text string, sent *tgmodels.Message) {
+	if h.adminMessagesRepo == nil || sent == nil {
+		return
+	}
+	if err := h.adminMessagesRepo.AddSupportMessage(targetUserID, true, text, sent.ID); err != nil {
+		log.Printf("[ADMIN] Error saving support message for user %d: %v", targetUserID, err)
+	}
+}
+
 func (h *AdminHandler) handleSendMessagePhoto(ctx context.Context, msg *tgmodels.Message, state *models.AdminState) bool {
 	if msg.Text == "/cancel" {
 		h.adminStateRepo.Clear(h.adminID)
@@ -3279,11 +7752,14 @@ func (h *AdminHandler) sendPhotoToUser(ctx context.Context, adminChatID int64, t
 		return
 	}
 
-	_, err = h.bot.SendPhoto(ctx, &bot.SendPhotoParams{
+	sent, err := h.bot.SendPhoto(ctx, &bot.SendPhotoParams{
 		ChatID:  targetUserID,
 		Photo:   &tgmodels.InputFileString{Data: fileID},
 		Caption: caption,
 	})
+	if err == nil {
+		h.recordSupportMessage(targetUserID, strings.TrimSpace("[фото] "+caption), sent)
+	}
 
 	if err == nil && h.achievementEngine != nil {
 		awarded, achievementErr := h.achievementEngine.OnMessageFromAdmin(targetUserID)
@@ -3316,6 +7792,89 @@ func (h *AdminHandler) sendPhotoToUser(ctx context.Context, adminChatID int64, t
 
 // ─── Аналитика ответов ────────────────────────────────────────────────────────
 
+func (h *AdminHandler) showStepReports(ctx context.Context, chatID int64, messageID int) {
+	reports, err := h.stepReportRepo.GetRecent(20)
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "❌ Ошибка получения данных", nil)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🚩 <b>Жалобы на шаги</b>\n")
+	sb.WriteString("<i>Последние сообщения о проблемах, отправленные через /report</i>\n\n")
+
+	if len(reports) == 0 {
+		sb.WriteString("Жалоб пока нет")
+	}
+	for _, report := range reports {
+		userName := fmt.Sprintf("[%d]", report.UserID)
+		if user, err := h.userRepo.GetByID(report.UserID); err == nil && user != nil {
+			userName = user.DisplayName()
+		}
+		stepLabel := "без шага"
+		if report.StepOrder > 0 {
+			stepLabel = fmt.Sprintf("шаг %d", report.StepOrder)
+		}
+		usefulMark := ""
+		if report.IsUseful {
+			usefulMark = " 👍"
+		}
+		sb.WriteString(fmt.Sprintf(
+			"• %s, %s, %s%s\n   <i>%s</i>\n",
+			report.CreatedAt.Format("02.01 15:04"), html.EscapeString(userName), stepLabel, usefulMark,
+			html.EscapeString(truncateText(report.Text, 120)),
+		))
+	}
+
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "🔄 Обновить", CallbackData: "admin:reports"}},
+			{{Text: "⬅️ Назад", CallbackData: "admin:menu"}},
+		},
+	}
+	h.editOrSend(ctx, chatID, messageID, sb.String(), keyboard)
+}
+
+// markReportUseful отмечает жалобу полезной из уведомления о ней, убирает кнопку отметки
+// и проверяет у автора жалобы достижение «Помощник»
+func (h *AdminHandler) markReportUseful(ctx context.Context, chatID int64, messageID int, data string) {
+	reportID, _ := parseInt64(strings.TrimPrefix(data, "admin:report_useful:"))
+	if reportID == 0 {
+		return
+	}
+
+	userID, changed, err := h.stepReportRepo.MarkUseful(reportID)
+	if err != nil {
+		log.Printf("[ADMIN] Error marking report %d as useful: %v", reportID, err)
+		h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "⚠️ Не удалось отметить жалобу",
+		})
+		return
+	}
+
+	h.bot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:    chatID,
+		MessageID: messageID,
+		ReplyMarkup: &tgmodels.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+				{{Text: "💬 Написать сообщение", CallbackData: fmt.Sprintf("admin:send_message:%d", userID)}},
+				{{Text: "✅ Отмечена полезной", CallbackData: "admin:reports"}},
+			},
+		},
+	})
+
+	if !changed || h.achievementEngine == nil {
+		return
+	}
+	awarded, err := h.achievementEngine.CheckHelperAchievement(userID)
+	if err != nil {
+		log.Printf("[ADMIN] Error evaluating helper achievement for user %d: %v", userID, err)
+		return
+	}
+	h.notifyAchievements(ctx, userID, awarded)
+}
+
 func (h *AdminHandler) showAnalyticsMenu(ctx context.Context, chatID int64, messageID int) {
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
@@ -3330,6 +7889,7 @@ func (h *AdminHandler) showAnalyticsMenu(ctx context.Context, chatID int64, mess
 			{{Text: "⏰ Хронология квеста", CallbackData: "admin:analytics:hourly"}},
 			{{Text: "🎲 Неоднозначные вопросы", CallbackData: "admin:analytics:diversity"}},
 			{{Text: "📚 Вопросы для домашки", CallbackData: "admin:analytics:homework"}},
+			{{Text: "🔁 Повторы ответов", CallbackData: "admin:analytics:duplicates"}},
 			{{Text: "⬅️ Назад", CallbackData: "admin:menu"}},
 		},
 	}
@@ -3370,7 +7930,7 @@ func (h *AdminHandler) showFunnelAnalytics(ctx context.Context, chatID int64, me
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:funnel"}},
+			{statsRefreshButton("admin:analytics:funnel")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
@@ -3402,7 +7962,7 @@ func (h *AdminHandler) showHardestSteps(ctx context.Context, chatID int64, messa
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:hardest"}},
+			{statsRefreshButton("admin:analytics:hardest")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
@@ -3433,13 +7993,50 @@ func (h *AdminHandler) showHintAnalytics(ctx context.Context, chatID int64, mess
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:hints"}},
+			{statsRefreshButton("admin:analytics:hints")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
 	h.editOrSend(ctx, chatID, messageID, sb.String(), keyboard)
 }
 
+func (h *AdminHandler) showDuplicateAnswers(ctx context.Context, chatID int64, messageID int) {
+	duplicates, err := h.stepRepo.FindDuplicateAnswersAcrossSteps()
+	if err != nil {
+		h.editOrSend(ctx, chatID, messageID, "❌ Ошибка получения данных", nil)
+		return
+	}
+
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{statsRefreshButton("admin:analytics:duplicates")},
+			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
+		},
+	}
+	h.editOrSend(ctx, chatID, messageID, FormatDuplicateAnswers(duplicates), keyboard)
+}
+
+// FormatDuplicateAnswers формирует список вариантов ответов, заданных у нескольких шагов
+func FormatDuplicateAnswers(duplicates []models.DuplicateAnswer) string {
+	var sb strings.Builder
+	sb.WriteString("🔁 <b>Повторы ответов</b>\n")
+	sb.WriteString("<i>Варианты, которые засчитываются сразу на нескольких шагах</i>\n\n")
+
+	if len(duplicates) == 0 {
+		sb.WriteString("✅ Повторяющихся вариантов нет")
+		return sb.String()
+	}
+
+	for _, d := range duplicates {
+		orders := make([]string, len(d.StepOrders))
+		for i, order := range d.StepOrders {
+			orders[i] = fmt.Sprintf("%d", order)
+		}
+		sb.WriteString(fmt.Sprintf("• <code>%s</code> — шаги %s\n", html.EscapeString(d.Answer), strings.Join(orders, ", ")))
+	}
+	return sb.String()
+}
+
 func (h *AdminHandler) showStepAnswers(ctx context.Context, chatID int64, messageID int, data string) {
 	orderStr := strings.TrimPrefix(data, "admin:analytics:answers:")
 	targetOrder := 0
@@ -3547,7 +8144,7 @@ func (h *AdminHandler) showAudienceSegments(ctx context.Context, chatID int64, m
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:segments"}},
+			{statsRefreshButton("admin:analytics:segments")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
@@ -3584,7 +8181,7 @@ func (h *AdminHandler) showDropoffPoints(ctx context.Context, chatID int64, mess
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:dropoff"}},
+			{statsRefreshButton("admin:analytics:dropoff")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
@@ -3618,7 +8215,7 @@ func (h *AdminHandler) showHourlyActivity(ctx context.Context, chatID int64, mes
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:hourly"}},
+			{statsRefreshButton("admin:analytics:hourly")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
@@ -3668,7 +8265,7 @@ func (h *AdminHandler) showSpeedruns(ctx context.Context, chatID int64, messageI
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:speedrun"}},
+			{statsRefreshButton("admin:analytics:speedrun")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
@@ -3717,7 +8314,7 @@ func (h *AdminHandler) showStubbornRecords(ctx context.Context, chatID int64, me
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:stubborn"}},
+			{statsRefreshButton("admin:analytics:stubborn")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
@@ -3761,7 +8358,7 @@ func (h *AdminHandler) showAnswerDiversity(ctx context.Context, chatID int64, me
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:diversity"}},
+			{statsRefreshButton("admin:analytics:diversity")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
@@ -3812,7 +8409,7 @@ func (h *AdminHandler) showHomeworkSteps(ctx context.Context, chatID int64, mess
 
 	keyboard := &tgmodels.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "🔄 Обновить", CallbackData: "admin:analytics:homework"}},
+			{statsRefreshButton("admin:analytics:homework")},
 			{{Text: "⬅️ Назад", CallbackData: "admin:analytics"}},
 		},
 	}
@@ -3830,11 +8427,14 @@ func (h *AdminHandler) sendDocumentToUser(ctx context.Context, adminChatID int64
 		return
 	}
 
-	_, err = h.bot.SendDocument(ctx, &bot.SendDocumentParams{
+	sent, err := h.bot.SendDocument(ctx, &bot.SendDocumentParams{
 		ChatID:   targetUserID,
 		Document: &tgmodels.InputFileString{Data: fileID},
 		Caption:  caption,
 	})
+	if err == nil {
+		h.recordSupportMessage(targetUserID, strings.TrimSpace("[документ] "+caption), sent)
+	}
 
 	if err == nil && h.achievementEngine != nil {
 		awarded, achievementErr := h.achievementEngine.OnMessageFromAdmin(targetUserID)
@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/fsm"
 	"github.com/ad/go-telegram-quest/internal/models"
 	tgmodels "github.com/go-telegram/bot/models"
@@ -95,3 +101,179 @@ func TestMessagePhotoValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestStepMediaFromMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       *tgmodels.Message
+		fileID    string
+		mediaType string
+		ok        bool
+	}{
+		{
+			name:      "Photo uses largest size",
+			msg:       &tgmodels.Message{Photo: []tgmodels.PhotoSize{{FileID: "small"}, {FileID: "large"}}},
+			fileID:    "large",
+			mediaType: models.MediaTypePhoto,
+			ok:        true,
+		},
+		{
+			name:      "Animation",
+			msg:       &tgmodels.Message{Animation: &tgmodels.Animation{FileID: "gif"}},
+			fileID:    "gif",
+			mediaType: models.MediaTypeAnimation,
+			ok:        true,
+		},
+		{
+			name: "Text only",
+			msg:  &tgmodels.Message{Text: "hello"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileID, mediaType, ok := stepMediaFromMessage(tt.msg)
+			if fileID != tt.fileID || mediaType != tt.mediaType || ok != tt.ok {
+				t.Errorf("stepMediaFromMessage() = (%q, %q, %v), want (%q, %q, %v)", fileID, mediaType, ok, tt.fileID, tt.mediaType, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAddStepImages_MediaGroup(t *testing.T) {
+	queue, cleanup := setupTestDBMessaging(t)
+	defer cleanup()
+
+	previousDelay := mediaGroupFlushDelay
+	mediaGroupFlushDelay = 20 * time.Millisecond
+	defer func() { mediaGroupFlushDelay = previousDelay }()
+
+	const adminID = 1
+	b, recorded := newRecordingBot(t)
+	adminStateRepo := db.NewAdminStateRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	h := &AdminHandler{
+		bot:            b,
+		adminID:        adminID,
+		stepRepo:       stepRepo,
+		adminStateRepo: adminStateRepo,
+	}
+
+	if err := adminStateRepo.Save(&models.AdminState{
+		UserID:       adminID,
+		CurrentState: fsm.StateAdminAddStepImages,
+		NewStepText:  "Найдите отличия",
+		NewStepType:  models.AnswerTypeImage,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for _, messageID := range []int{12, 10, 11} {
+		wg.Add(1)
+		go func(messageID int) {
+			defer wg.Done()
+			state, err := adminStateRepo.Get(adminID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			h.handleAddStepImages(ctx, &tgmodels.Message{
+				ID:           messageID,
+				Chat:         tgmodels.Chat{ID: adminID},
+				MediaGroupID: "album",
+				Photo:        []tgmodels.PhotoSize{{FileID: fmt.Sprintf("photo-%d", messageID)}},
+			}, state)
+		}(messageID)
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(recorded()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if calls := recorded(); len(calls) != 1 {
+		t.Fatalf("Expected a single confirmation for the album, got %+v", calls)
+	}
+
+	h.doneImages(ctx, adminID, 0)
+
+	steps, err := stepRepo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 {
+		t.Fatalf("Expected one step, got %d", len(steps))
+	}
+	step, err := stepRepo.GetByID(steps[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"photo-10", "photo-11", "photo-12"}
+	if len(step.Images) != len(want) {
+		t.Fatalf("Expected %d images, got %+v", len(want), step.Images)
+	}
+	for i, image := range step.Images {
+		if image.FileID != want[i] {
+			t.Errorf("Image %d: expected %s, got %s", i, want[i], image.FileID)
+		}
+	}
+}
+
+func TestAddStepImages_Animation(t *testing.T) {
+	queue, cleanup := setupTestDBMessaging(t)
+	defer cleanup()
+
+	const adminID = 1
+	b, _ := newRecordingBot(t)
+	adminStateRepo := db.NewAdminStateRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	h := &AdminHandler{
+		bot:            b,
+		adminID:        adminID,
+		stepRepo:       stepRepo,
+		adminStateRepo: adminStateRepo,
+	}
+
+	if err := adminStateRepo.Save(&models.AdminState{
+		UserID:       adminID,
+		CurrentState: fsm.StateAdminAddStepImages,
+		NewStepText:  "Найдите отличия",
+		NewStepType:  models.AnswerTypeText,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, msg := range []*tgmodels.Message{
+		{Chat: tgmodels.Chat{ID: adminID}, From: &tgmodels.User{ID: adminID}, Animation: &tgmodels.Animation{FileID: "gif-1"}},
+		{Chat: tgmodels.Chat{ID: adminID}, From: &tgmodels.User{ID: adminID}, Photo: []tgmodels.PhotoSize{{FileID: "photo-1"}}},
+	} {
+		if !h.HandleCommand(ctx, msg) {
+			t.Fatal("Expected the step media to be handled")
+		}
+	}
+
+	state, err := adminStateRepo.Get(adminID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(state.NewStepImages, []string{"gif-1", "photo-1"}) {
+		t.Fatalf("Expected the GIF and the photo to be collected, got %v", state.NewStepImages)
+	}
+
+	h.createStep(ctx, adminID, 0, state)
+
+	steps, err := stepRepo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 || len(steps[0].Images) != 2 {
+		t.Fatalf("Expected one step with two media, got %+v", steps)
+	}
+	if !steps[0].Images[0].IsAnimation() || steps[0].Images[1].IsAnimation() {
+		t.Errorf("Expected the GIF to stay an animation and the photo a photo, got %+v", steps[0].Images)
+	}
+}
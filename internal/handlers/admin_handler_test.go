@@ -1,13 +1,21 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/fsm"
 	"github.com/ad/go-telegram-quest/internal/models"
 	"github.com/ad/go-telegram-quest/internal/services"
+	"github.com/go-telegram/bot"
 	tgmodels "github.com/go-telegram/bot/models"
 	"pgregory.net/rapid"
 )
@@ -1222,6 +1230,34 @@ func TestUserDisplayNameEscaping(t *testing.T) {
 	}
 }
 
+func TestFormatUserAttemptStats(t *testing.T) {
+	formatted := FormatUserAttemptStats(&services.UserAttemptStats{TotalAttempts: 7, WrongAttempts: 3, HintsUsed: 2, StepsAttempted: 4})
+	for _, expected := range []string{"Всего: 7", "Неверных: 3", "Подсказок: 2", "В среднем на шаг: 1.8"} {
+		if !strings.Contains(formatted, expected) {
+			t.Errorf("Expected formatted output to contain %q, but got: %s", expected, formatted)
+		}
+	}
+}
+
+func TestFormatAchievementRecompute(t *testing.T) {
+	formatted := FormatAchievementRecompute(&services.RecomputeSummary{
+		UsersChecked: 5,
+		UsersChanged: 2,
+		Awarded:      map[string]int{"beginner_5": 2},
+		Revoked:      map[string]int{"hint_10": 1},
+	})
+	for _, expected := range []string{"Проверено участников: 5", "Изменения у участников: 2", "<code>beginner_5</code>: 2", "<code>hint_10</code>: 1"} {
+		if !strings.Contains(formatted, expected) {
+			t.Errorf("Expected formatted output to contain %q, but got: %s", expected, formatted)
+		}
+	}
+
+	unchanged := FormatAchievementRecompute(&services.RecomputeSummary{UsersChecked: 5})
+	if !strings.Contains(unchanged, "соответствуют текущим условиям") || strings.Contains(unchanged, "Выдано") {
+		t.Errorf("Expected no-change summary, got: %s", unchanged)
+	}
+}
+
 func TestAchievementNameFormatting(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -1447,3 +1483,699 @@ func TestFormatAchievementLeadersHTMLFormatting(t *testing.T) {
 		t.Error("Should contain silver medal for second place")
 	}
 }
+
+func TestPlanStepsExport_SmallQuestInline(t *testing.T) {
+	h := &AdminHandler{}
+	steps := []*models.Step{
+		{ID: 1, StepOrder: 1, Text: "Первый шаг", Answers: []string{"один"}},
+		{ID: 2, StepOrder: 2, Text: "Второй шаг", Answers: []string{"два"}},
+	}
+
+	plan := h.planStepsExport(steps, nil, exportModeAuto, 50)
+
+	if plan.AsDocument {
+		t.Fatal("Small quest should be exported inline")
+	}
+	if len(plan.Messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(plan.Messages))
+	}
+	if !strings.Contains(plan.Messages[0], "Первый шаг") || !strings.Contains(plan.Messages[0], "Второй шаг") {
+		t.Error("Inline export should contain all steps")
+	}
+}
+
+func TestPlanStepsExport_LargeQuestDocument(t *testing.T) {
+	h := &AdminHandler{}
+	var steps []*models.Step
+	for i := 1; i <= 60; i++ {
+		steps = append(steps, &models.Step{
+			ID:        int64(i),
+			StepOrder: i,
+			Text:      fmt.Sprintf("Шаг номер %d", i),
+			Answers:   []string{fmt.Sprintf("ответ%d", i)},
+		})
+	}
+
+	plan := h.planStepsExport(steps, nil, exportModeAuto, 50)
+
+	if !plan.AsDocument {
+		t.Fatal("Large quest should be exported as a document")
+	}
+	if len(plan.Messages) != 0 {
+		t.Errorf("Document export should not produce inline messages, got %d", len(plan.Messages))
+	}
+	if !strings.Contains(plan.Document, "Шаг номер 1") || !strings.Contains(plan.Document, "Шаг номер 60") {
+		t.Error("Document should contain all steps")
+	}
+	if !strings.HasPrefix(plan.Document, "<!DOCTYPE html>") {
+		t.Error("Document should be an HTML file")
+	}
+}
+
+func TestPlanStepsExport_ModeOverride(t *testing.T) {
+	h := &AdminHandler{}
+	var steps []*models.Step
+	for i := 1; i <= 60; i++ {
+		steps = append(steps, &models.Step{ID: int64(i), StepOrder: i, Text: fmt.Sprintf("Шаг %d", i)})
+	}
+
+	if plan := h.planStepsExport(steps, nil, exportModeInline, 50); plan.AsDocument {
+		t.Error("Inline mode should force inline export")
+	}
+	if plan := h.planStepsExport(steps[:2], nil, exportModeFile, 50); !plan.AsDocument {
+		t.Error("File mode should force document export")
+	}
+}
+
+func TestFormatStepForExport_EscapesText(t *testing.T) {
+	h := &AdminHandler{}
+	step := &models.Step{
+		ID:         1,
+		StepOrder:  1,
+		Text:       "Если x < 5 & y > 2 — что дальше? 🤔",
+		HintText:   "Tom &amp; Jerry",
+		Answers:    []string{"a<b", "c&d"},
+		IsAsterisk: true,
+	}
+
+	formatted := h.formatStepForExport(step, "")
+
+	expected := []string{
+		"<b>⭐ Если x &lt; 5 &amp; y &gt; 2 — что дальше? 🤔</b>",
+		"<b>Подсказка:</b> <i>Tom &amp; Jerry</i>",
+		"<b>Ответы:</b> a&lt;b, c&amp;d",
+	}
+	for _, want := range expected {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected export to contain %q, got:\n%s", want, formatted)
+		}
+	}
+	if strings.Contains(formatted, "&amp;amp;") {
+		t.Errorf("Export should not double-escape entities, got:\n%s", formatted)
+	}
+}
+
+func TestPlanStepsExport_AuthorNotes(t *testing.T) {
+	h := &AdminHandler{}
+	steps := []*models.Step{
+		{ID: 1, StepOrder: 1, Text: "Первый шаг"},
+		{ID: 2, StepOrder: 2, Text: "Второй шаг"},
+	}
+	notes := map[int64]string{1: "Источник: <старый> журнал"}
+
+	if plan := h.planStepsExport(steps, nil, exportModeInline, 50); strings.Contains(plan.Messages[0], "Заметка автора") {
+		t.Errorf("Expected notes to be excluded without the flag, got:\n%s", plan.Messages[0])
+	}
+
+	plan := h.planStepsExport(steps, notes, exportModeInline, 50)
+	if !strings.Contains(plan.Messages[0], "<b>Заметка автора:</b> <i>Источник: &lt;старый&gt; журнал</i>") {
+		t.Errorf("Expected escaped author note in export, got:\n%s", plan.Messages[0])
+	}
+	if strings.Count(plan.Messages[0], "Заметка автора") != 1 {
+		t.Errorf("Expected only the noted step to have a note, got:\n%s", plan.Messages[0])
+	}
+}
+
+func TestAnswerMoveButtons(t *testing.T) {
+	if rows := answerMoveButtons(1, 1); rows != nil {
+		t.Errorf("Expected no move buttons for a single answer, got %v", rows)
+	}
+
+	rows := answerMoveButtons(7, 3)
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+	if len(rows[0]) != 1 || rows[0][0].CallbackData != "admin:move_answer:7:0:1" {
+		t.Errorf("First answer should only move down, got %+v", rows[0])
+	}
+	if len(rows[1]) != 2 || rows[1][0].CallbackData != "admin:move_answer:7:1:0" {
+		t.Errorf("Middle answer should move both ways, got %+v", rows[1])
+	}
+	if len(rows[2]) != 1 || rows[2][0].CallbackData != "admin:move_answer:7:2:1" {
+		t.Errorf("Last answer should only move up, got %+v", rows[2])
+	}
+}
+
+func TestParseAnnounceChannelInput(t *testing.T) {
+	tests := []struct {
+		input     string
+		channelID int64
+		interval  int
+		wantErr   bool
+	}{
+		{"-1001234567890 5", -1001234567890, 5, false},
+		{"-1001234567890", -1001234567890, 0, false},
+		{"0", 0, 0, false},
+		{"0 5", 0, 0, false},
+		{"-100123 -1", 0, 0, true},
+		{"", 0, 0, true},
+		{"-100123 5 7", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		channelID, interval, err := ParseAnnounceChannelInput(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if !tt.wantErr && (channelID != tt.channelID || interval != tt.interval) {
+			t.Errorf("%q: expected %d/%d, got %d/%d", tt.input, tt.channelID, tt.interval, channelID, interval)
+		}
+	}
+}
+
+func TestAnswerTypeChangeWarning(t *testing.T) {
+	if warning := answerTypeChangeWarning(models.AnswerTypeText, models.AnswerTypeImage, false, 0); warning != "" {
+		t.Errorf("Expected no warning for an untouched step, got %q", warning)
+	}
+
+	warning := answerTypeChangeWarning(models.AnswerTypeText, models.AnswerTypeImage, true, 0)
+	if !strings.Contains(warning, "уже пройден") || !strings.Contains(warning, "📝 Текст") {
+		t.Errorf("Expected a warning about completed text answers, got %q", warning)
+	}
+
+	warning = answerTypeChangeWarning(models.AnswerTypeText, models.AnswerTypeImage, true, 3)
+	if !strings.Contains(warning, "Сейчас на шаге: 3") || !strings.Contains(warning, "📷 Изображение") {
+		t.Errorf("Expected a warning about users on the step, got %q", warning)
+	}
+}
+
+func TestAnswerTypeSetupNote(t *testing.T) {
+	step := &models.Step{AnswerType: models.AnswerTypeText}
+	if note := answerTypeSetupNote(step, models.AnswerTypeImage); note != "" {
+		t.Errorf("Expected no setup note for image answers, got %q", note)
+	}
+	if note := answerTypeSetupNote(step, models.AnswerTypeChoice); note == "" {
+		t.Error("Expected a reminder to add choices")
+	}
+	step.LocationRadius = 50
+	if note := answerTypeSetupNote(step, models.AnswerTypeLocation); note != "" {
+		t.Errorf("Expected no note when the location target is set, got %q", note)
+	}
+}
+
+func TestHandleStateInput_UnexpectedMessageType(t *testing.T) {
+	queue, cleanup := setupTestDBMessaging(t)
+	defer cleanup()
+
+	const adminID = 1
+	b, recorded := newRecordingBot(t)
+	adminStateRepo := db.NewAdminStateRepository(queue)
+	h := &AdminHandler{
+		bot:            b,
+		adminID:        adminID,
+		stepRepo:       db.NewStepRepository(queue),
+		adminStateRepo: adminStateRepo,
+	}
+
+	if err := adminStateRepo.Save(&models.AdminState{UserID: adminID, CurrentState: fsm.StateAdminAddStepText}); err != nil {
+		t.Fatal(err)
+	}
+
+	sticker := &tgmodels.Message{
+		From:    &tgmodels.User{ID: adminID},
+		Chat:    tgmodels.Chat{ID: adminID},
+		Sticker: &tgmodels.Sticker{FileID: "sticker"},
+	}
+	if !h.HandleCommand(context.Background(), sticker) {
+		t.Fatal("Expected the sticker to be handled instead of dropped")
+	}
+
+	calls := recorded()
+	if len(calls) != 1 || calls[0].text != textInputExpectedPrompt {
+		t.Errorf("Expected a prompt that text is expected, got %+v", calls)
+	}
+
+	state, err := adminStateRepo.Get(adminID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.CurrentState != fsm.StateAdminAddStepText || state.NewStepText != "" {
+		t.Errorf("Expected the state to stay unchanged, got %+v", state)
+	}
+}
+
+func TestEditExportFileThreshold(t *testing.T) {
+	queue, cleanup := setupTestDBMessaging(t)
+	defer cleanup()
+
+	const adminID = 1
+	b, recorded := newRecordingBot(t)
+	adminStateRepo := db.NewAdminStateRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	h := &AdminHandler{
+		bot:            b,
+		adminID:        adminID,
+		stepRepo:       db.NewStepRepository(queue),
+		settingsRepo:   settingsRepo,
+		adminStateRepo: adminStateRepo,
+	}
+	ctx := context.Background()
+
+	h.startEditExportFileThreshold(ctx, adminID, 0)
+	if state, _ := adminStateRepo.Get(adminID); state == nil || state.CurrentState != fsm.StateAdminEditExportFileThreshold {
+		t.Fatalf("Expected the threshold input state, got %+v", state)
+	}
+
+	send := func(text string) {
+		h.HandleCommand(ctx, &tgmodels.Message{
+			Text: text,
+			From: &tgmodels.User{ID: adminID},
+			Chat: tgmodels.Chat{ID: adminID},
+		})
+	}
+
+	before := len(recorded())
+	send("0")
+	if calls := recorded()[before:]; len(calls) != 1 || !strings.Contains(calls[0].text, "положительное число") {
+		t.Errorf("Expected a zero threshold to be rejected, got %+v", calls)
+	}
+
+	send("20")
+	if threshold, _ := settingsRepo.GetExportFileThreshold(); threshold != 20 {
+		t.Errorf("Expected threshold 20, got %d", threshold)
+	}
+	if state, _ := adminStateRepo.Get(adminID); state != nil && state.CurrentState != "" {
+		t.Errorf("Expected the input state to be cleared, got %+v", state)
+	}
+	calls := recorded()
+	if last := calls[len(calls)-1]; !strings.Contains(last.text, "Настройки бота") {
+		t.Errorf("Expected the settings menu after saving, got %+v", last)
+	}
+}
+
+func TestUnexpectedInputPrompt(t *testing.T) {
+	photo := &tgmodels.Message{Photo: []tgmodels.PhotoSize{{FileID: "photo"}}}
+	text := &tgmodels.Message{Text: "ответ"}
+	location := &tgmodels.Message{Location: &tgmodels.Location{Latitude: 55.75, Longitude: 37.62}}
+	animation := &tgmodels.Message{Animation: &tgmodels.Animation{FileID: "gif"}}
+
+	tests := []struct {
+		name       string
+		state      string
+		msg        *tgmodels.Message
+		wantPrompt bool
+	}{
+		{"photo in text state", fsm.StateAdminAddAnswer, photo, true},
+		{"text in text state", fsm.StateAdminAddAnswer, text, false},
+		{"text while choosing step type", fsm.StateAdminAddStepType, text, true},
+		{"text instead of step images", fsm.StateAdminAddStepImages, text, true},
+		{"step image", fsm.StateAdminAddStepImages, photo, false},
+		{"step GIF", fsm.StateAdminAddStepImages, animation, false},
+		{"photo for location target", fsm.StateAdminEditLocationTarget, photo, true},
+		{"location for location target", fsm.StateAdminEditLocationTarget, location, false},
+		{"photo in media state", fsm.StateAdminAddImage, photo, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unexpectedInputPrompt(tt.state, tt.msg); (got != "") != tt.wantPrompt {
+				t.Errorf("unexpectedInputPrompt(%q) = %q, want prompt: %v", tt.state, got, tt.wantPrompt)
+			}
+		})
+	}
+}
+
+// newInviteLinkBot поднимает фейковый Telegram API, который на createChatInviteLink
+// возвращает ссылку или, если createFails, ошибку прав. Остальные вызовы записываются
+func newInviteLinkBot(t *testing.T, createFails bool) (*bot.Bot, func() []telegramCall) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var calls []telegramCall
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		method := path.Base(r.URL.Path)
+		mu.Lock()
+		calls = append(calls, telegramCall{method: method, chatID: r.FormValue("chat_id"), text: r.FormValue("text")})
+		id := len(calls)
+		mu.Unlock()
+		switch {
+		case method == "createChatInviteLink" && createFails:
+			fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: not enough rights to manage chat invite link"}`)
+		case method == "createChatInviteLink":
+			fmt.Fprint(w, `{"ok":true,"result":{"invite_link":"https://t.me/+generated","creator":{"id":123,"is_bot":true,"first_name":"bot"},"creates_join_request":false,"is_primary":false,"is_revoked":false}}`)
+		default:
+			fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":1,"type":"private"}}}`, id)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := bot.New("123:test", bot.WithSkipGetMe(), bot.WithServerURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b, func() []telegramCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]telegramCall(nil), calls...)
+	}
+}
+
+func TestEnableGroupRestriction_AutoInviteLink(t *testing.T) {
+	const adminID = 1
+	const groupChatID = -1001234567890
+
+	setup := func(t *testing.T, createFails bool) (*AdminHandler, *db.SettingsRepository, *db.AdminStateRepository, func() []telegramCall) {
+		queue, cleanup := setupTestDBMessaging(t)
+		t.Cleanup(cleanup)
+
+		b, recorded := newInviteLinkBot(t, createFails)
+		settingsRepo := db.NewSettingsRepository(queue)
+		adminStateRepo := db.NewAdminStateRepository(queue)
+		h := &AdminHandler{
+			bot:            b,
+			adminID:        adminID,
+			settingsRepo:   settingsRepo,
+			adminStateRepo: adminStateRepo,
+		}
+		if err := adminStateRepo.Save(&models.AdminState{UserID: adminID, CurrentState: fsm.StateAdminEnableGroupRestrictionID}); err != nil {
+			t.Fatal(err)
+		}
+		return h, settingsRepo, adminStateRepo, recorded
+	}
+
+	sendGroupID := func(t *testing.T, h *AdminHandler, adminStateRepo *db.AdminStateRepository) {
+		t.Helper()
+		state, err := adminStateRepo.Get(adminID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.handleEnableGroupRestrictionID(context.Background(), &tgmodels.Message{
+			From: &tgmodels.User{ID: adminID},
+			Chat: tgmodels.Chat{ID: adminID},
+			Text: fmt.Sprintf("%d", groupChatID),
+		}, state)
+	}
+
+	t.Run("bot creates the link", func(t *testing.T) {
+		h, settingsRepo, adminStateRepo, recorded := setup(t, false)
+		sendGroupID(t, h, adminStateRepo)
+
+		if calls := recorded(); len(calls) == 0 || calls[0].method != "createChatInviteLink" || calls[0].chatID != fmt.Sprintf("%d", groupChatID) {
+			t.Errorf("Expected the bot to create an invite link for the group, got %+v", calls)
+		}
+		if id, _ := settingsRepo.GetRequiredGroupChatID(); id != groupChatID {
+			t.Errorf("Expected group %d to be saved, got %d", groupChatID, id)
+		}
+		if link, _ := settingsRepo.GetGroupChatInviteLink(); link != "https://t.me/+generated" {
+			t.Errorf("Expected the generated link to be saved, got %q", link)
+		}
+		if state, _ := adminStateRepo.Get(adminID); state != nil && state.CurrentState != "" {
+			t.Errorf("Expected the admin state to be cleared, got %q", state.CurrentState)
+		}
+	})
+
+	t.Run("falls back to manual entry", func(t *testing.T) {
+		h, settingsRepo, adminStateRepo, recorded := setup(t, true)
+		sendGroupID(t, h, adminStateRepo)
+
+		calls := recorded()
+		if len(calls) != 2 || !strings.Contains(calls[1].text, "Не удалось создать ссылку") || !strings.Contains(calls[1].text, "Введите ссылку") {
+			t.Errorf("Expected a prompt to enter the link manually, got %+v", calls)
+		}
+		if id, _ := settingsRepo.GetRequiredGroupChatID(); id != 0 {
+			t.Errorf("Expected the restriction to stay disabled until the link is entered, got group %d", id)
+		}
+		state, err := adminStateRepo.Get(adminID)
+		if err != nil || state == nil || state.CurrentState != fsm.StateAdminEnableGroupRestrictionLink || state.NewGroupChatID != groupChatID {
+			t.Errorf("Expected to wait for the link of group %d, got %+v", groupChatID, state)
+		}
+	})
+
+	t.Run("disabled setting asks for the link", func(t *testing.T) {
+		h, settingsRepo, adminStateRepo, recorded := setup(t, false)
+		if err := settingsRepo.SetAutoInviteLink(false); err != nil {
+			t.Fatal(err)
+		}
+		sendGroupID(t, h, adminStateRepo)
+
+		calls := recorded()
+		if len(calls) != 1 || calls[0].method != "sendMessage" || strings.Contains(calls[0].text, "Не удалось") {
+			t.Errorf("Expected only the manual link prompt, got %+v", calls)
+		}
+	})
+}
+
+func TestHandleTestAnswer_PracticeTrace(t *testing.T) {
+	queue, cleanup := setupTestDBWithAchievements(t)
+	defer cleanup()
+
+	const adminID = 1
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &AdminHandler{
+		bot:          b,
+		adminID:      adminID,
+		stepRepo:     stepRepo,
+		answerRepo:   answerRepo,
+		progressRepo: db.NewProgressRepository(queue),
+		userRepo:     db.NewUserRepository(queue),
+		settingsRepo: settingsRepo,
+	}
+
+	stepID, err := stepRepo.Create(&models.Step{StepOrder: 1, Text: "Столица?", AnswerType: models.AnswerTypeText, HasAutoCheck: true, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := answerRepo.AddStepAnswer(stepID, "Москва"); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &models.AdminState{UserID: adminID, CurrentState: fsm.StateAdminTestAnswer, EditingStepID: stepID}
+	practice := func(answer string) string {
+		t.Helper()
+		before := len(recorded())
+		h.handleTestAnswer(context.Background(), &tgmodels.Message{
+			Text: answer,
+			From: &tgmodels.User{ID: adminID},
+			Chat: tgmodels.Chat{ID: adminID},
+		}, state)
+		calls := recorded()[before:]
+		if len(calls) != 1 {
+			t.Fatalf("Expected one reply, got %+v", calls)
+		}
+		return calls[0].text
+	}
+
+	if text := practice("  МОСКВА "); strings.Contains(text, "Разбор проверки") {
+		t.Errorf("Expected a short verdict without the toggle, got %q", text)
+	}
+
+	if err := settingsRepo.SetPracticeTrace(true); err != nil {
+		t.Fatal(err)
+	}
+	text := practice("  МОСКВА ")
+	for _, want := range []string{"Разбор проверки: засчитан", "Ответ: <code>  МОСКВА </code>", "<code>москва</code> = <code>москва</code>", "Правило совпадения: точное совпадение"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in practice trace, got:\n%s", want, text)
+		}
+	}
+	if text := practice("Питер"); !strings.Contains(text, "Разбор проверки: не засчитан") || strings.Contains(text, "Правило совпадения") {
+		t.Errorf("Expected a trace for the wrong answer, got:\n%s", text)
+	}
+
+	numericID, err := stepRepo.Create(&models.Step{StepOrder: 2, Text: "Сколько?", AnswerType: models.AnswerTypeText, HasAutoCheck: true, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.SetNumericFeedback(numericID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := answerRepo.AddStepAnswer(numericID, "42"); err != nil {
+		t.Fatal(err)
+	}
+	state.EditingStepID = numericID
+	if text := practice("42,0"); !strings.Contains(text, "Разбор проверки: засчитан") || !strings.Contains(text, "<code>42</code> = <code>42</code>") {
+		t.Errorf("Expected a trace for the numeric step, got:\n%s", text)
+	}
+
+	for _, table := range []string{"user_answers", "user_progress"} {
+		var count int
+		if _, err := queue.Execute(func(sqlDB *sql.DB) (interface{}, error) {
+			return nil, sqlDB.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("Expected practice answers not to be persisted, found %d rows in %s", count, table)
+		}
+	}
+}
+
+func TestSendAnswerAlbum_ChunkSizes(t *testing.T) {
+	queue, cleanup := setupTestDBMessaging(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 100
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	if err := db.NewUserRepository(queue).CreateOrUpdate(&models.User{ID: userID, FirstName: "U"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, recorded := newRecordingBot(t)
+	h := &AdminHandler{
+		bot:        b,
+		adminID:    adminID,
+		stepRepo:   stepRepo,
+		answerRepo: answerRepo,
+		msgManager: services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+		media:      services.NewMediaService(b),
+	}
+
+	tests := []struct {
+		photos     int
+		photoCalls int
+		groupCalls int
+	}{
+		{1, 1, 0},
+		{11, 0, 2},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d photos", tt.photos), func(t *testing.T) {
+			stepID, err := stepRepo.Create(&models.Step{StepOrder: i + 1, Text: "Фото", AnswerType: models.AnswerTypeImage, IsActive: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fileIDs []string
+			for j := 0; j < tt.photos; j++ {
+				fileIDs = append(fileIDs, fmt.Sprintf("photo-%d-%d", i, j))
+			}
+			if _, err := answerRepo.CreateImageAnswer(userID, stepID, fileIDs, false); err != nil {
+				t.Fatal(err)
+			}
+
+			before := len(recorded())
+			h.handleAnswerPhotos(context.Background(), adminID, 0, fmt.Sprintf("answer_photos:%d:%d", userID, stepID))
+
+			var photoCalls, groupCalls int
+			for _, call := range recorded()[before:] {
+				switch call.method {
+				case "sendPhoto":
+					photoCalls++
+					if !strings.Contains(call.caption, "Фото ответов участника") {
+						t.Errorf("Expected the single photo to keep the caption, got %q", call.caption)
+					}
+				case "sendMediaGroup":
+					groupCalls++
+				case "sendMessage":
+					t.Errorf("Unexpected message %q", call.text)
+				}
+			}
+			if photoCalls != tt.photoCalls || groupCalls != tt.groupCalls {
+				t.Errorf("Expected %d sendPhoto and %d sendMediaGroup calls, got %d and %d", tt.photoCalls, tt.groupCalls, photoCalls, groupCalls)
+			}
+		})
+	}
+}
+
+func TestHandleRejectReason(t *testing.T) {
+	queue, cleanup := setupTestDBMessaging(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 100
+	const reviewMessageID = 55
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+	adminStateRepo := db.NewAdminStateRepository(queue)
+	if err := db.NewUserRepository(queue).CreateOrUpdate(&models.User{ID: userID, FirstName: "U"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, recorded := newRecordingBot(t)
+	h := &AdminHandler{
+		bot:            b,
+		adminID:        adminID,
+		stepRepo:       stepRepo,
+		progressRepo:   progressRepo,
+		adminStateRepo: adminStateRepo,
+		msgManager:     services.NewMessageManager(b, chatStateRepo, nil),
+	}
+	ctx := context.Background()
+
+	reject := func(stepID int64) []telegramCall {
+		t.Helper()
+		h.startRejectWithReason(ctx, adminID, reviewMessageID, fmt.Sprintf("admin:reject_reason:%d:%d", userID, stepID))
+		before := len(recorded())
+		h.HandleCommand(ctx, &tgmodels.Message{
+			Text: "На фото не видно таблички",
+			From: &tgmodels.User{ID: adminID},
+			Chat: tgmodels.Chat{ID: adminID},
+		})
+		if state, _ := adminStateRepo.Get(adminID); state != nil {
+			t.Errorf("Expected the admin state to be cleared, got %+v", state)
+		}
+		return recorded()[before:]
+	}
+
+	t.Run("waiting answer", func(t *testing.T) {
+		stepID, err := stepRepo.Create(&models.Step{StepOrder: 1, Text: "Фото", AnswerType: models.AnswerTypeImage, IsActive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusWaitingReview}); err != nil {
+			t.Fatal(err)
+		}
+		if err := chatStateRepo.Save(&models.ChatState{UserID: userID, LastUserAnswerMessageID: 7, LastReactionMessageID: 8}); err != nil {
+			t.Fatal(err)
+		}
+
+		var strippedReview, notifiedUser bool
+		var deleted []string
+		for _, call := range reject(stepID) {
+			switch {
+			case call.method == "editMessageReplyMarkup" && call.messageID == fmt.Sprint(reviewMessageID):
+				strippedReview = true
+			case call.method == "deleteMessage" && call.chatID == fmt.Sprint(userID):
+				deleted = append(deleted, call.messageID)
+			case call.method == "sendMessage" && call.chatID == fmt.Sprint(userID):
+				notifiedUser = strings.Contains(call.text, "На фото не видно таблички")
+			}
+		}
+		if !strippedReview {
+			t.Error("Expected the review buttons to be removed")
+		}
+		if len(deleted) != 2 {
+			t.Errorf("Expected the user's answer and reaction to be deleted, got %v", deleted)
+		}
+		if !notifiedUser {
+			t.Error("Expected the user to receive the rejection reason")
+		}
+		if progress, _ := progressRepo.GetByUserAndStep(userID, stepID); progress.Status != models.StatusRejected {
+			t.Errorf("Expected status %s, got %s", models.StatusRejected, progress.Status)
+		}
+	})
+
+	t.Run("already reviewed answer", func(t *testing.T) {
+		stepID, err := stepRepo.Create(&models.Step{StepOrder: 2, Text: "Фото", AnswerType: models.AnswerTypeImage, IsActive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusApproved}); err != nil {
+			t.Fatal(err)
+		}
+
+		var adminNotice string
+		for _, call := range reject(stepID) {
+			if call.chatID == fmt.Sprint(userID) {
+				t.Errorf("Expected no %s call to the user, got %q", call.method, call.text)
+			}
+			if call.method == "sendMessage" && call.chatID == fmt.Sprint(adminID) {
+				adminNotice = call.text
+			}
+		}
+		if !strings.Contains(adminNotice, "уже проверен") {
+			t.Errorf("Expected the admin to be told the answer was already reviewed, got %q", adminNotice)
+		}
+		if progress, _ := progressRepo.GetByUserAndStep(userID, stepID); progress.Status != models.StatusApproved {
+			t.Errorf("Expected status to stay %s, got %s", models.StatusApproved, progress.Status)
+		}
+	})
+}
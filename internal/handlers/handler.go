@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"html"
 	"log"
 	"math/rand"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/metrics"
 	"github.com/ad/go-telegram-quest/internal/models"
 	"github.com/ad/go-telegram-quest/internal/services"
 	"github.com/go-telegram/bot"
@@ -30,11 +36,18 @@ type BotHandler struct {
 	settingsRepo         *db.SettingsRepository
 	chatStateRepo        *db.ChatStateRepository
 	adminMessagesRepo    *db.AdminMessagesRepository
+	stepReportRepo       *db.StepReportRepository
+	userManager          *services.UserManager
 	adminHandler         *AdminHandler
 	questStateMiddleware *services.QuestStateMiddleware
 	achievementEngine    *services.AchievementEngine
 	achievementNotifier  *services.AchievementNotifier
 	groupChatVerifier    *services.GroupChatVerifier
+	referralService      *services.ReferralService
+	channelAnnouncer     *services.ChannelAnnouncer
+	unlockService        *services.UnlockService
+	media                *services.MediaService
+	notificationQueue    *services.NotificationQueue
 }
 
 func NewBotHandler(
@@ -53,18 +66,24 @@ func NewBotHandler(
 	chatStateRepo *db.ChatStateRepository,
 	adminMessagesRepo *db.AdminMessagesRepository,
 	adminStateRepo *db.AdminStateRepository,
+	stepReportRepo *db.StepReportRepository,
 	userManager *services.UserManager,
 	questStateManager *services.QuestStateManager,
 	achievementEngine *services.AchievementEngine,
 	achievementNotifier *services.AchievementNotifier,
 	achievementService *services.AchievementService,
 	groupChatVerifier *services.GroupChatVerifier,
+	referralService *services.ReferralService,
+	channelAnnouncer *services.ChannelAnnouncer,
+	diagnostics *services.DiagnosticsService,
+	questConfig *services.QuestConfigService,
+	unlockService *services.UnlockService,
 	dbPath string,
 ) *BotHandler {
-	adminHandler := NewAdminHandler(b, adminID, stepRepo, answerRepo, settingsRepo, adminStateRepo, userManager, userRepo, questStateManager, achievementService, achievementEngine, achievementNotifier, statsService, errorManager, dbPath)
+	adminHandler := NewAdminHandler(b, adminID, stepRepo, answerRepo, progressRepo, settingsRepo, adminStateRepo, stepReportRepo, adminMessagesRepo, userManager, userRepo, questStateManager, achievementService, achievementEngine, achievementNotifier, statsService, errorManager, msgManager, diagnostics, questConfig, unlockService, dbPath)
 	questStateMiddleware := services.NewQuestStateMiddleware(questStateManager, adminID)
 
-	return &BotHandler{
+	h := &BotHandler{
 		bot:                  b,
 		adminID:              adminID,
 		errorManager:         errorManager,
@@ -79,22 +98,96 @@ func NewBotHandler(
 		settingsRepo:         settingsRepo,
 		chatStateRepo:        chatStateRepo,
 		adminMessagesRepo:    adminMessagesRepo,
+		stepReportRepo:       stepReportRepo,
+		userManager:          userManager,
 		adminHandler:         adminHandler,
 		questStateMiddleware: questStateMiddleware,
 		achievementEngine:    achievementEngine,
 		achievementNotifier:  achievementNotifier,
 		groupChatVerifier:    groupChatVerifier,
+		referralService:      referralService,
+		channelAnnouncer:     channelAnnouncer,
+		unlockService:        unlockService,
+		media:                services.NewMediaService(b),
 	}
+	if achievementEngine != nil {
+		achievementEngine.SetWinnerPlaceListener(h.notifyWinnerPlaceChanges)
+	}
+	return h
+}
+
+// SetNotificationQueue включает повторную отправку заданий, которые не удалось доставить
+// участнику из-за временной ошибки
+func (h *BotHandler) SetNotificationQueue(queue *services.NotificationQueue) {
+	h.notificationQueue = queue
+	queue.RegisterSender(services.NotificationStep, h.resendStep)
 }
 
 func (h *BotHandler) HandleUpdate(ctx context.Context, b *bot.Bot, update *tgmodels.Update) {
 	defer h.recoverPanic(ctx, update)
+	metrics.Default.UpdatesProcessed.Inc()
 
 	if update.Message != nil {
 		h.handleMessage(ctx, update.Message)
+	} else if update.EditedMessage != nil {
+		h.handleEditedMessage(ctx, update.EditedMessage)
 	} else if update.CallbackQuery != nil {
 		h.handleCallback(ctx, update.CallbackQuery)
+	} else if update.MyChatMember != nil {
+		h.handleMyChatMember(ctx, update.MyChatMember)
+	}
+}
+
+// handleMyChatMember отслеживает права бота в группе ограничения участия: если бота удалили
+// из группы или он лишился прав администратора (или вернул их), администратор получает
+// сообщение, а проверка членства переходит в режим, заданный настройкой group_check_fail_open
+func (h *BotHandler) handleMyChatMember(ctx context.Context, update *tgmodels.ChatMemberUpdated) {
+	if h.groupChatVerifier == nil {
+		return
+	}
+
+	change, err := h.groupChatVerifier.HandleBotStatusUpdate(update)
+	if err != nil {
+		log.Printf("[HANDLER] Error handling bot status update in chat %d: %v", update.Chat.ID, err)
+		return
+	}
+	if change == nil {
+		return
+	}
+
+	failOpen, _ := h.settingsRepo.GetGroupCheckFailOpen()
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: h.adminID,
+		Text:   botStatusChangeText(change, failOpen),
+	})
+}
+
+// botStatusChangeText описывает администратору, как изменение прав бота в группе
+// повлияет на проверку участия
+func botStatusChangeText(change *services.BotStatusChange, failOpen bool) string {
+	group := fmt.Sprintf("%d", change.ChatID)
+	if change.ChatTitle != "" {
+		group = fmt.Sprintf("«%s» (%d)", change.ChatTitle, change.ChatID)
+	}
+
+	var sb strings.Builder
+	switch change.New {
+	case services.BotGroupAdmin:
+		sb.WriteString(fmt.Sprintf("✅ Бот снова администратор группы %s — проверка участия работает", group))
+		return sb.String()
+	case services.BotGroupMember:
+		sb.WriteString(fmt.Sprintf("⚠️ Бот больше не администратор группы %s — проверка участия может не работать. Верните боту права администратора.", group))
+	default:
+		sb.WriteString(fmt.Sprintf("⚠️ Бота удалили из группы %s — проверить участие невозможно. Добавьте бота в группу администратором.", group))
+	}
+
+	sb.WriteString("\n\n")
+	if failOpen {
+		sb.WriteString("🚪 Пока проверка не работает, участники допускаются без неё.")
+	} else {
+		sb.WriteString("🔒 Пока проверка не работает, участники получают сообщение об ошибке проверки. Пускать их без проверки можно в меню «🔐 Ограничение участия».")
 	}
+	return sb.String()
 }
 
 func (h *BotHandler) recoverPanic(ctx context.Context, update *tgmodels.Update) {
@@ -114,8 +207,7 @@ func (h *BotHandler) handleMessage(ctx context.Context, msg *tgmodels.Message) {
 
 	userID := msg.From.ID
 
-	if msg.Text == "/start" {
-		h.handleStart(ctx, msg)
+	if h.dispatchUserCommand(ctx, msg, commandStageFirst) {
 		return
 	}
 
@@ -125,8 +217,25 @@ func (h *BotHandler) handleMessage(ctx context.Context, msg *tgmodels.Message) {
 		}
 	}
 
+	if h.dispatchUserCommand(ctx, msg, commandStageAnyState) {
+		return
+	}
+
+	if !h.isUserBlocked(userID) && h.dispatchUserCommand(ctx, msg, commandStageUnblocked) {
+		return
+	}
+
+	if h.questStateMiddleware.AllowsResults(userID) && !h.isUserBlocked(userID) {
+		if h.dispatchUserCommand(ctx, msg, commandStageResults) {
+			return
+		}
+	}
+
 	shouldProcess, notification := h.questStateMiddleware.ShouldProcessMessage(userID)
 	if !shouldProcess {
+		if h.questStateMiddleware.IsReadOnly() {
+			notification = strings.TrimSpace(notification + "\n\n" + questOverResultsHint)
+		}
 		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
 			ChatID: msg.Chat.ID,
 			Text:   notification,
@@ -139,6 +248,19 @@ func (h *BotHandler) handleMessage(ctx context.Context, msg *tgmodels.Message) {
 		return
 	}
 
+	// Ответ организатору доходит и до принятия правил, а команды квеста — только после
+	if msg.ReplyToMessage != nil && msg.Text != "" && h.handleSupportReply(ctx, msg) {
+		return
+	}
+
+	if !h.requireRulesAccepted(ctx, msg.Chat.ID, userID) {
+		return
+	}
+
+	if h.dispatchUserCommand(ctx, msg, commandStageQuest) {
+		return
+	}
+
 	if strings.EqualFold(msg.Text, "Подсказка") {
 		if h.handleHintByText(ctx, userID) {
 			return
@@ -156,6 +278,11 @@ func (h *BotHandler) handleMessage(ctx context.Context, msg *tgmodels.Message) {
 		return
 	}
 
+	if msg.Location != nil {
+		h.handleLocationAnswer(ctx, msg)
+		return
+	}
+
 	if msg.MediaGroupID != "" {
 		return
 	}
@@ -165,254 +292,1428 @@ func (h *BotHandler) handleMessage(ctx context.Context, msg *tgmodels.Message) {
 	}
 }
 
-func (h *BotHandler) isUserBlocked(userID int64) bool {
-	blocked, err := h.userRepo.IsBlocked(userID)
-	if err != nil {
-		return false
-	}
-	return blocked
+// commandStage определяет, на каком этапе обработки сообщения срабатывает команда участника
+type commandStage int
+
+const (
+	// commandStageFirst — до команд администратора и любых проверок
+	commandStageFirst commandStage = iota
+	// commandStageAnyState — при любом состоянии квеста
+	commandStageAnyState
+	// commandStageUnblocked — при любом состоянии квеста, если участник не заблокирован:
+	// заблокированный участник получает обычный ответ для заблокированных
+	commandStageUnblocked
+	// commandStageResults — когда квест идёт или уже завершён и участник не заблокирован:
+	// после завершения квеста результаты остаются доступны только для чтения
+	commandStageResults
+	// commandStageQuest — только когда квест идёт и участник не заблокирован
+	commandStageQuest
+)
+
+// questOverResultsHint дополняет сообщение о завершённом квесте на попытку ответить
+const questOverResultsHint = "Ответы больше не принимаются, но результаты доступны: /progress, /leaderboard, /stickers"
+
+// leaderboardSize — сколько лидеров показывает /leaderboard
+const leaderboardSize = 10
+
+// userCommand — команда участника. По таблице команд строятся и маршрутизация, и справка /help,
+// поэтому новая команда сразу попадает в справку
+type userCommand struct {
+	name        string
+	args        string
+	description string
+	stage       commandStage
+	handle      func(ctx context.Context, msg *tgmodels.Message)
 }
 
-func (h *BotHandler) sendShadowBanResponse(ctx context.Context, chatID int64) {
-	settings, _ := h.settingsRepo.GetAll()
-	wrongMsg := "❌ Неверно, попробуйте ещё раз"
-	if settings != nil && settings.WrongAnswerMessage != "" {
-		wrongMsg = settings.WrongAnswerMessage
-	}
-	h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
-		ChatID: chatID,
-		Text:   wrongMsg,
-	}, "5046589136895476101") // 💩
+func (c userCommand) matches(text string) bool {
+	return text == "/"+c.name || strings.HasPrefix(text, "/"+c.name+" ")
 }
 
-func (h *BotHandler) handleCallback(ctx context.Context, callback *tgmodels.CallbackQuery) {
-	if callback.Message.Message == nil || callback.Message.Message.Chat.Type != tgmodels.ChatTypePrivate {
-		return
+// userCommands возвращает таблицу команд участника в порядке, в котором они показываются в /help
+func (h *BotHandler) userCommands() []userCommand {
+	return []userCommand{
+		{name: "start", description: "начать квест или продолжить с текущего шага", stage: commandStageFirst, handle: h.handleStart},
+		{name: "help", description: "список команд", stage: commandStageAnyState, handle: h.handleHelp},
+		{name: "progress", description: "ваш прогресс и место в рейтинге", stage: commandStageResults, handle: h.handleProgress},
+		{name: "leaderboard", description: "рейтинг участников", stage: commandStageResults, handle: h.handleLeaderboard},
+		{name: "stickers", description: "стикер-пак с вашими достижениями", stage: commandStageResults, handle: h.handleStickers},
+		{name: "remaining", description: "сколько шагов осталось (без раскрытия заданий)", stage: commandStageQuest, handle: h.handleRemaining},
+		{name: "hint", description: "подсказка к текущему шагу", stage: commandStageQuest, handle: h.handleHintCommand},
+		{name: "map", description: "карта квеста: главы, ваше место и необязательные шаги", stage: commandStageQuest, handle: h.handleQuestMap},
+		{name: "code", args: "<промокод>", description: "открыть шаг или главу по промокоду", stage: commandStageQuest, handle: h.handleUnlockCode},
+		{name: "report", args: "<текст>", description: "сообщить организаторам о проблеме с текущим шагом", stage: commandStageQuest, handle: h.handleReport},
+		{name: "timezone", args: "[пояс]", description: "показать или изменить часовой пояс", stage: commandStageUnblocked, handle: h.handleTimezone},
+		{name: "dnd", description: "режим «не беспокоить»: не упоминать вас в канале объявлений", stage: commandStageUnblocked, handle: h.handleDoNotDisturb},
 	}
+}
 
-	if strings.HasPrefix(callback.Data, "next_step:") {
-		h.handleNextStepCallback(ctx, callback)
-		return
+// dispatchUserCommand выполняет команду участника, если она относится к этапу stage
+func (h *BotHandler) dispatchUserCommand(ctx context.Context, msg *tgmodels.Message, stage commandStage) bool {
+	for _, command := range h.userCommands() {
+		if command.stage == stage && command.matches(msg.Text) {
+			command.handle(ctx, msg)
+			return true
+		}
 	}
+	return false
+}
 
-	if strings.HasPrefix(callback.Data, "hint:") {
-		h.handleHintCallback(ctx, callback)
-		return
-	}
+func (h *BotHandler) handleHelp(ctx context.Context, msg *tgmodels.Message) {
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   formatHelp(h.userCommands()),
+	})
+}
 
-	if strings.HasPrefix(callback.Data, "skip_step:") {
-		h.handleSkipStepCallback(ctx, callback)
-		return
+// formatHelp формирует ответ на /help из таблицы команд
+func formatHelp(commands []userCommand) string {
+	var sb strings.Builder
+	sb.WriteString("ℹ️ <b>Команды</b>\n")
+	for _, command := range commands {
+		sb.WriteString("\n/" + command.name)
+		if command.args != "" {
+			sb.WriteString(" " + html.EscapeString(command.args))
+		}
+		sb.WriteString(" — " + html.EscapeString(command.description))
 	}
+	sb.WriteString("\n\nЧтобы ответить на задание, просто отправьте ответ сообщением")
+	return sb.String()
+}
 
-	if strings.HasPrefix(callback.Data, "verify_membership:") {
-		h.handleVerifyMembershipCallback(ctx, callback)
-		return
-	}
+const requireReplyReaction = "↩️ Ответ на это задание нужно отправить реплаем: нажмите на сообщение с заданием, выберите «Ответить» и отправьте ответ"
 
-	if callback.From.ID != h.adminID {
-		log.Printf("[HANDLER] callback from non-admin user: %d", callback.From.ID)
-		return
-	}
+// lockedStepReaction — ответ на попытку ответить на шаг, закрытый промокодом
+const lockedStepReaction = "🔒 Этот шаг открывается промокодом. Введите его командой /code <промокод>"
 
-	adminHandled := h.adminHandler.HandleCallback(ctx, callback)
-	// log.Printf("[HANDLER] adminHandler.HandleCallback returned: %t", adminHandled)
-	if adminHandled {
+// handleUnlockCode открывает шаг или главу по промокоду. Если был закрыт текущий шаг
+// участника, он сразу отправляется заново
+func (h *BotHandler) handleUnlockCode(ctx context.Context, msg *tgmodels.Message) {
+	if h.unlockService == nil {
 		return
 	}
+	userID := msg.From.ID
+	code := strings.TrimSpace(strings.TrimPrefix(msg.Text, "/code"))
 
-	log.Printf("[HANDLER] processing callback in main handler: %s", callback.Data)
-	if strings.HasPrefix(callback.Data, "approve:") || strings.HasPrefix(callback.Data, "reject:") {
-		// log.Printf("[HANDLER] calling handleAdminDecision")
-		h.handleAdminDecision(ctx, callback)
-	} else if strings.HasPrefix(callback.Data, "block:") {
-		h.handleBlockUser(ctx, callback)
-	}
-}
-
-func (h *BotHandler) handleStart(ctx context.Context, msg *tgmodels.Message) {
-	user := &models.User{
-		ID:        msg.From.ID,
-		FirstName: msg.From.FirstName,
-		LastName:  msg.From.LastName,
-		Username:  msg.From.Username,
-	}
-
-	if err := h.userRepo.CreateOrUpdate(user); err != nil {
-		h.sendError(ctx, msg.Chat.ID, "Ошибка при регистрации")
+	if code == "" {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "🔑 Введите промокод после команды, например:\n/code лесная-тропа",
+		})
 		return
 	}
 
-	if h.groupChatVerifier != nil {
-		enabled, err := h.groupChatVerifier.IsVerificationEnabled()
-		if err != nil {
-			log.Printf("[HANDLER] Error checking if verification is enabled: %v", err)
-		} else if enabled {
-			isMember, inviteLink, err := h.groupChatVerifier.VerifyMembership(ctx, user.ID)
-			if err != nil {
-				log.Printf("[HANDLER] Error verifying membership for user %d: %v", user.ID, err)
-				h.sendVerificationUI(ctx, msg.Chat.ID, user.ID, inviteLink)
-				return
-			}
-			if !isMember {
-				h.sendVerificationUI(ctx, msg.Chat.ID, user.ID, inviteLink)
-				return
-			}
-		}
+	var wasLocked bool
+	state, err := h.stateResolver.ResolveState(userID)
+	if err == nil && state.CurrentStep != nil {
+		wasLocked, _ = h.unlockService.IsLocked(userID, state.CurrentStep)
 	}
 
-	shouldProcess, notification := h.questStateMiddleware.ShouldProcessMessage(user.ID)
-	if !shouldProcess {
+	target, err := h.unlockService.Redeem(userID, code)
+	if errors.Is(err, services.ErrInvalidUnlockCode) {
 		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
 			ChatID: msg.Chat.ID,
-			Text:   notification,
+			Text:   "❌ Такого промокода нет",
 		})
 		return
 	}
-
-	state, err := h.stateResolver.ResolveState(user.ID)
 	if err != nil {
-		h.sendError(ctx, msg.Chat.ID, fmt.Sprintf("Ошибка при определении состояния: %v", err))
+		log.Printf("[HANDLER] Error redeeming unlock code for user %d: %v", userID, err)
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при проверке промокода")
 		return
 	}
 
-	if state.IsCompleted {
-		settings, _ := h.settingsRepo.GetAll()
-		finalMsg := "Поздравляем! Вы прошли квест!"
-		if settings != nil && settings.FinalMessage != "" {
-			finalMsg = settings.FinalMessage
-		}
-
-		completionStats := h.statsService.FormatCompletionStats(msg.From.ID)
-		if completionStats != "" {
-			finalMsg = finalMsg + "\n\n" + completionStats
-		}
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "🔓 Промокод принят: " + h.unlockTargetLabel(target),
+	})
 
-		stickerPackMsg := h.achievementNotifier.FormatStickerPackMessage(msg.From.ID)
-		if stickerPackMsg != "" {
-			finalMsg = finalMsg + "\n\n" + stickerPackMsg
+	if wasLocked {
+		if locked, err := h.unlockService.IsLocked(userID, state.CurrentStep); err == nil && !locked {
+			h.sendStep(ctx, userID, state.CurrentStep)
 		}
-
-		h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
-			ChatID: msg.Chat.ID,
-			Text:   finalMsg,
-		}, "5046509860389126442") // 🎉
-		return
 	}
+}
 
-	if state.Status == models.StatusPending || state.Status == "" {
-		settings, _ := h.settingsRepo.GetAll()
-		welcomeMsg := "Добро пожаловать в квест!"
-		if settings != nil && settings.WelcomeMessage != "" {
-			welcomeMsg = settings.WelcomeMessage
+// unlockTargetLabel описывает, что открыл промокод, не раскрывая текста шага
+func (h *BotHandler) unlockTargetLabel(target *models.UnlockCode) string {
+	if target.ChapterID != 0 {
+		if chapters, err := h.stepRepo.GetChapters(); err == nil {
+			for _, chapter := range chapters {
+				if chapter.ID == target.ChapterID {
+					return fmt.Sprintf("открыта глава «%s»", chapter.Title)
+				}
+			}
 		}
-		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
-			ChatID: msg.Chat.ID,
-			Text:   welcomeMsg,
-		})
+		return "открыта глава"
 	}
-
-	h.sendStep(ctx, user.ID, state.CurrentStep)
+	if step, err := h.stepRepo.GetByID(target.StepID); err == nil && step != nil {
+		return fmt.Sprintf("открыт шаг %d", step.StepOrder)
+	}
+	return "открыт шаг"
 }
 
-func (h *BotHandler) sendStep(ctx context.Context, userID int64, step *models.Step) {
-	if step == nil {
-		return
+// isStepLocked сообщает, что шаг закрыт для участника промокодом
+func (h *BotHandler) isStepLocked(userID int64, step *models.Step) bool {
+	if h.unlockService == nil {
+		return false
 	}
-
-	progress, _ := h.progressRepo.GetByUserAndStep(userID, step.ID)
-	if progress == nil {
-		h.progressRepo.Create(&models.UserProgress{
-			UserID: userID,
-			StepID: step.ID,
-			Status: models.StatusPending,
-		})
+	locked, err := h.unlockService.IsLocked(userID, step)
+	if err != nil {
+		log.Printf("[HANDLER] Error checking unlock code for user %d, step %d: %v", userID, step.ID, err)
+		return false
 	}
+	return locked
+}
 
-	answerHint := ""
-	switch step.AnswerType {
-	case models.AnswerTypeText:
-		// answerHint = "\n\n📝 Ответьте текстом или числом"
-	case models.AnswerTypeImage:
-		answerHint = "\n\n📷 Отправьте фото"
+// rejectLockedStep отвечает на ответ к шагу, закрытому промокодом. Возвращает true,
+// если ответ не нужно проверять
+func (h *BotHandler) rejectLockedStep(ctx context.Context, userID int64, messageID int, step *models.Step) bool {
+	if !h.isStepLocked(userID, step) {
+		return false
 	}
+	h.msgManager.SaveUserAnswerMessageID(userID, messageID)
+	h.msgManager.SendReaction(ctx, userID, lockedStepReaction)
+	return true
+}
 
-	// Добавляем прогресс-бар
-	progressText := h.getProgressText(userID)
-
-	stepWithHint := &models.Step{
-		ID:           step.ID,
-		StepOrder:    step.StepOrder,
-		Text:         progressText + "\n\n" + step.Text + answerHint,
-		AnswerType:   step.AnswerType,
-		HasAutoCheck: step.HasAutoCheck,
-		IsActive:     step.IsActive,
-		IsDeleted:    step.IsDeleted,
-		IsAsterisk:   step.IsAsterisk,
-		Images:       step.Images,
-		Answers:      step.Answers,
-		HintText:     step.HintText,
-		HintImage:    step.HintImage,
+// rejectNonReply отвечает на ответ к шагу, который принимается только реплаем на
+// сообщение с заданием. Возвращает true, если ответ не нужно проверять. Если id
+// сообщения с заданием неизвестен, засчитывается любой реплай
+func (h *BotHandler) rejectNonReply(ctx context.Context, msg *tgmodels.Message, step *models.Step) bool {
+	if !step.RequireReply {
+		return false
 	}
-
-	// Check if hint button should be shown
-	showHintButton := false
-	if step.HasHint() {
+	userID := msg.From.ID
+	if msg.ReplyToMessage != nil {
 		chatState, err := h.chatStateRepo.Get(userID)
-		if err == nil && chatState != nil {
-			showHintButton = !chatState.CurrentStepHintUsed
-		} else {
-			showHintButton = true
+		if err != nil || chatState == nil || chatState.LastTaskMessageID == 0 || chatState.LastTaskMessageID == msg.ReplyToMessage.ID {
+			return false
 		}
 	}
-
-	h.msgManager.SendTaskWithButtons(ctx, userID, stepWithHint, showHintButton, step.IsAsterisk)
+	h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+	h.msgManager.SendReaction(ctx, userID, requireReplyReaction)
+	return true
 }
 
-func (h *BotHandler) getProgressText(userID int64) string {
-	_, total, percentage, err := h.statsService.GetUserProgress(userID)
-	if err != nil || total == 0 {
-		return ""
+// rejectImageConstraints отвечает на фото, которое не подходит под ограничения шага на
+// разрешение, и подсказывает, какое фото нужно. Возвращает true, если ответ не нужно проверять
+func (h *BotHandler) rejectImageConstraints(ctx context.Context, msg *tgmodels.Message, step *models.Step) bool {
+	if step.ImageConstraints == "" {
+		return false
+	}
+	constraints, err := services.ParseImageConstraints(step.ImageConstraints)
+	if err != nil || constraints.IsEmpty() {
+		return false
 	}
 
-	barLength := int(percentage * 20 / 100)
-	if barLength > 20 {
-		barLength = 20
+	info := h.media.InfoFromMessage(ctx, msg)
+	if constraints.Check(info) {
+		return false
 	}
 
-	return strings.Repeat("▰", barLength) + strings.Repeat("▱", 20-barLength)
+	userID := msg.From.ID
+	h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+	h.msgManager.SendReaction(ctx, userID, fmt.Sprintf("📐 Фото %d×%d не подходит. Нужно фото: %s", info.Width, info.Height, services.FormatImageConstraints(constraints)))
+	return true
 }
 
-func (h *BotHandler) handleTextAnswer(ctx context.Context, msg *tgmodels.Message) {
+// handleDoNotDisturb переключает режим «не беспокоить»: участник не упоминается в публичном канале
+func (h *BotHandler) handleDoNotDisturb(ctx context.Context, msg *tgmodels.Message) {
 	userID := msg.From.ID
 
-	state, err := h.stateResolver.ResolveState(userID)
+	enabled, err := h.userRepo.IsDoNotDisturb(userID)
 	if err != nil {
-		log.Printf("[HANDLER] Error resolving state for user %d: %v", userID, err)
+		h.sendError(ctx, msg.Chat.ID, "Сначала отправьте /start")
 		return
 	}
 
-	h.evaluateSecretAnswer(ctx, userID, msg.Text)
-
-	if state.IsCompleted {
-		log.Printf("[HANDLER] User %d completed quest, forwarding message to admin", userID)
-		h.evaluateAchievementsOnPostCompletion(ctx, userID)
-		h.forwardMessageToAdmin(ctx, msg, nil, "после завершения квеста")
+	if err := h.userRepo.SetDoNotDisturb(userID, !enabled); err != nil {
+		log.Printf("[HANDLER] Error saving do-not-disturb for user %d: %v", userID, err)
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при сохранении настройки")
 		return
 	}
 
-	if state.CurrentStep == nil {
-		log.Printf("[HANDLER] User %d has no current step", userID)
-		return
+	text := "🔕 Режим «не беспокоить» включён: ваши успехи не будут объявляться в канале квеста.\n\nЧтобы выключить, снова отправьте /dnd"
+	if enabled {
+		text = "🔔 Режим «не беспокоить» выключен: ваши успехи могут объявляться в канале квеста.\n\nЧтобы включить, снова отправьте /dnd"
 	}
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   text,
+	})
+}
 
-	step := state.CurrentStep
-	log.Printf("[HANDLER] User %d on step %d (order %d)", userID, step.ID, step.StepOrder)
-
-	chatState, _ := h.chatStateRepo.Get(userID)
-	progress, _ := h.progressRepo.GetByUserAndStep(userID, step.ID)
+func (h *BotHandler) handleTimezone(ctx context.Context, msg *tgmodels.Message) {
+	userID := msg.From.ID
+	arg := strings.TrimSpace(strings.TrimPrefix(msg.Text, "/timezone"))
 
-	if chatState != nil && chatState.AwaitingNextStep && (progress == nil || progress.Status == models.StatusPending) {
+	if arg == "" {
+		current, _ := h.userRepo.GetEffectiveTimezone(userID)
+		loc := services.ResolveLocation(current)
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text: fmt.Sprintf("🕒 Ваш часовой пояс: <b>%s</b> (сейчас %s)\n\nЧтобы изменить, отправьте, например:\n/timezone Europe/Moscow\n/timezone +3",
+				html.EscapeString(loc.String()), time.Now().In(loc).Format("15:04")),
+		})
+		return
+	}
+
+	loc, err := services.ParseTimezone(arg)
+	if err != nil {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "❌ Не удалось распознать часовой пояс. Примеры: /timezone Europe/Moscow, /timezone +3",
+		})
+		return
+	}
+
+	if err := h.userRepo.SetTimezone(userID, loc.String()); err != nil {
+		log.Printf("[HANDLER] Error saving timezone for user %d: %v", userID, err)
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при сохранении часового пояса")
+		return
+	}
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   fmt.Sprintf("✅ Часовой пояс сохранён: <b>%s</b> (сейчас %s)", html.EscapeString(loc.String()), time.Now().In(loc).Format("15:04")),
+	})
+}
+
+// handleProgress показывает участнику пройденные шаги, место в рейтинге и число достижений
+func (h *BotHandler) handleProgress(ctx context.Context, msg *tgmodels.Message) {
+	userID := msg.From.ID
+	answered, total, percentage, err := h.statsService.GetUserProgress(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error getting progress for user %d: %v", userID, err)
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при получении прогресса")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📊 <b>Ваш прогресс</b>\n\n")
+	sb.WriteString(fmt.Sprintf("✅ Пройдено шагов: %d из %d (%.0f%%)\n", answered, total, percentage))
+	if total > 0 {
+		sb.WriteString(services.ProgressBar(answered, total) + "\n")
+	}
+	if position, totalUsers, err := h.statsService.GetUserLeaderboardPosition(userID); err == nil {
+		sb.WriteString(fmt.Sprintf("🏅 Место в рейтинге: %d из %d\n", position, totalUsers))
+	}
+	if count, err := h.statsService.GetUserAchievementCount(userID); err == nil {
+		sb.WriteString(fmt.Sprintf("🏆 Достижений: %d\n", count))
+	}
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   sb.String(),
+	})
+}
+
+// handleLeaderboard показывает лидеров квеста. Участники в режиме «не беспокоить»
+// в списке не показываются, но место в рейтинге занимают
+func (h *BotHandler) handleLeaderboard(ctx context.Context, msg *tgmodels.Message) {
+	leaders, err := h.statsService.GetLeaders()
+	if err != nil {
+		log.Printf("[HANDLER] Error getting leaders: %v", err)
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при получении рейтинга")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🏆 <b>Рейтинг участников</b>\n")
+	for i, leader := range leaders {
+		if i == leaderboardSize {
+			break
+		}
+		if quiet, err := h.userRepo.IsDoNotDisturb(leader.ID); err == nil && quiet {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%d. %s", i+1, html.EscapeString(services.PublicName(leader))))
+	}
+	if len(leaders) == 0 {
+		sb.WriteString("\nПока никто не прошёл ни одного шага")
+	}
+
+	if position, total, err := h.statsService.GetUserLeaderboardPosition(msg.From.ID); err == nil {
+		sb.WriteString(fmt.Sprintf("\n\n📍 Ваше место: %d из %d", position, total))
+	}
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   sb.String(),
+	})
+}
+
+// handleStickers присылает ссылку на стикер-пак с достижениями участника
+func (h *BotHandler) handleStickers(ctx context.Context, msg *tgmodels.Message) {
+	text := "🎨 Стикер-пак появится, когда вы получите первое достижение"
+	if h.achievementNotifier != nil {
+		if packMsg := h.achievementNotifier.FormatStickerPackMessage(msg.From.ID); packMsg != "" {
+			text = packMsg
+		}
+	}
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   text,
+	})
+}
+
+// handleRemaining сообщает пользователю, сколько шагов осталось, не раскрывая их содержимого
+func (h *BotHandler) handleRemaining(ctx context.Context, msg *tgmodels.Message) {
+	remaining, err := h.stateResolver.GetRemainingSteps(msg.From.ID)
+	if err != nil {
+		log.Printf("[HANDLER] Error counting remaining steps for user %d: %v", msg.From.ID, err)
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при подсчёте шагов")
+		return
+	}
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   FormatRemainingSteps(remaining),
+	})
+}
+
+// FormatRemainingSteps формирует ответ на /remaining
+func FormatRemainingSteps(remaining *services.RemainingSteps) string {
+	switch {
+	case remaining.Total == 0 || remaining.IsCompleted():
+		return "🏁 Квест пройден — шагов не осталось!"
+	case !remaining.Started:
+		return fmt.Sprintf("🧭 Квест ещё не начат. Всего шагов: %d\n\nОтправьте /start, чтобы начать", remaining.Total)
+	default:
+		return fmt.Sprintf("🧭 Осталось шагов: %d из %d", remaining.Remaining, remaining.Total)
+	}
+}
+
+func (h *BotHandler) handleQuestMap(ctx context.Context, msg *tgmodels.Message) {
+	if enabled, _ := h.settingsRepo.GetQuestMapEnabled(); !enabled {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "🗺 Карта квеста отключена организаторами",
+		})
+		return
+	}
+
+	questMap, err := h.stateResolver.GetQuestMap(msg.From.ID)
+	if err != nil {
+		log.Printf("[HANDLER] Error building quest map for user %d: %v", msg.From.ID, err)
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при построении карты квеста")
+		return
+	}
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID:    msg.Chat.ID,
+		Text:      FormatQuestMap(questMap),
+		ParseMode: tgmodels.ParseModeHTML,
+	})
+}
+
+// FormatQuestMap формирует ответ на /map в виде чек-листа. Названия нераскрытых глав и
+// содержимое шагов не показываются
+func FormatQuestMap(questMap *services.QuestMap) string {
+	var sb strings.Builder
+	sb.WriteString("🗺 <b>Карта квеста</b>\n")
+
+	switch {
+	case questMap.Total == 0:
+		sb.WriteString("\nВ квесте пока нет шагов")
+		return sb.String()
+	case questMap.Finished:
+		sb.WriteString(fmt.Sprintf("🏁 Квест пройден: %d из %d шагов\n", questMap.Completed, questMap.Total))
+	case !questMap.Started:
+		sb.WriteString(fmt.Sprintf("Квест ещё не начат. Всего шагов: %d\n", questMap.Total))
+	default:
+		sb.WriteString(fmt.Sprintf("📍 Вы на шаге %d из %d, пройдено: %d\n", questMap.Position, questMap.Total, questMap.Completed))
+	}
+
+	if len(questMap.Chapters) > 0 {
+		sb.WriteString("\n<b>Главы</b>\n")
+		for _, chapter := range questMap.Chapters {
+			mark := "🔒"
+			switch {
+			case chapter.Total > 0 && chapter.Completed == chapter.Total:
+				mark = "✅"
+			case chapter.Current:
+				mark = "📍"
+			case chapter.Revealed:
+				mark = "⬜"
+			}
+			if chapter.Revealed {
+				sb.WriteString(fmt.Sprintf("%s Глава %d: %s (%d/%d)\n", mark, chapter.Number, html.EscapeString(chapter.Title), chapter.Completed, chapter.Total))
+			} else {
+				sb.WriteString(fmt.Sprintf("%s Глава %d\n", mark, chapter.Number))
+			}
+		}
+	}
+
+	if len(questMap.Optional) > 0 || questMap.OptionalAhead > 0 {
+		sb.WriteString("\n<b>Необязательные шаги</b>\n")
+		for _, step := range questMap.Optional {
+			switch step.Status {
+			case models.StatusApproved:
+				sb.WriteString(fmt.Sprintf("✅ Шаг %d\n", step.Number))
+			case models.StatusSkipped:
+				sb.WriteString(fmt.Sprintf("⏭ Шаг %d — пропущен\n", step.Number))
+			default:
+				sb.WriteString(fmt.Sprintf("⬜ Шаг %d\n", step.Number))
+			}
+		}
+		if questMap.OptionalAhead > 0 {
+			sb.WriteString(fmt.Sprintf("Впереди ещё необязательных шагов: %d\n", questMap.OptionalAhead))
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// handleReport сохраняет жалобу пользователя на текущий шаг и пересылает её админу
+func (h *BotHandler) handleReport(ctx context.Context, msg *tgmodels.Message) {
+	userID := msg.From.ID
+	text := strings.TrimSpace(strings.TrimPrefix(msg.Text, "/report"))
+
+	if text == "" {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   "🚩 Опишите проблему после команды, например:\n/report в задании не открывается картинка",
+		})
+		return
+	}
+
+	var step *models.Step
+	if state, err := h.stateResolver.ResolveState(userID); err == nil {
+		step = state.CurrentStep
+	}
+
+	var stepID int64
+	if step != nil {
+		stepID = step.ID
+	}
+
+	reportID, err := h.stepReportRepo.Create(userID, stepID, text)
+	if err != nil {
+		log.Printf("[HANDLER] Error saving report from user %d: %v", userID, err)
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при отправке сообщения о проблеме")
+		return
+	}
+
+	displayName := fmt.Sprintf("[%d]", userID)
+	if user, _ := h.userRepo.GetByID(userID); user != nil {
+		displayName = user.DisplayName()
+	}
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: h.adminID,
+		Text:   FormatStepReportNotification(displayName, userID, step, text),
+		ReplyMarkup: &tgmodels.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+				{{Text: "💬 Написать сообщение", CallbackData: fmt.Sprintf("admin:send_message:%d", userID)}},
+				{{Text: "👍 Полезная жалоба", CallbackData: fmt.Sprintf("admin:report_useful:%d", reportID)}},
+			},
+		},
+	})
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Спасибо! Сообщение о проблеме передано организаторам",
+	})
+}
+
+// handleSupportReply пересылает админу ответ пользователя на сообщение из открытой переписки.
+// Возвращает false, если сообщение не относится к переписке и должно обрабатываться как ответ на шаг
+func (h *BotHandler) handleSupportReply(ctx context.Context, msg *tgmodels.Message) bool {
+	if h.adminMessagesRepo == nil {
+		return false
+	}
+
+	userID := msg.From.ID
+	isReply, err := h.adminMessagesRepo.IsSupportReply(userID, msg.ReplyToMessage.ID)
+	if err != nil {
+		log.Printf("[HANDLER] Error checking support thread for user %d: %v", userID, err)
+		return false
+	}
+	if !isReply {
+		return false
+	}
+
+	if err := h.adminMessagesRepo.AddSupportMessage(userID, false, msg.Text, msg.ID); err != nil {
+		log.Printf("[HANDLER] Error saving support message from user %d: %v", userID, err)
+	}
+
+	if h.achievementEngine != nil {
+		awarded, err := h.achievementEngine.OnMessageToAdmin(userID)
+		if err != nil {
+			log.Printf("[HANDLER] Error awarding message to admin achievement: %v", err)
+		} else if len(awarded) > 0 {
+			h.notifyAchievements(ctx, userID, awarded)
+		}
+	}
+
+	var step *models.Step
+	if state, err := h.stateResolver.ResolveState(userID); err == nil {
+		step = state.CurrentStep
+	}
+
+	displayName := fmt.Sprintf("[%d]", userID)
+	if user, _ := h.userRepo.GetByID(userID); user != nil {
+		displayName = user.DisplayName()
+	}
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: h.adminID,
+		Text:   FormatSupportReply(displayName, userID, step, msg.Text),
+		ReplyMarkup: &tgmodels.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+				{
+					{Text: "↩️ Ответить", CallbackData: fmt.Sprintf("admin:send_msg_type:text:%d", userID)},
+					{Text: "👤 Профиль", CallbackData: fmt.Sprintf("user:%d", userID)},
+				},
+			},
+		},
+	})
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "✅ Сообщение передано администратору",
+	})
+	return true
+}
+
+// FormatStepReportNotification формирует уведомление админу о жалобе пользователя
+func FormatStepReportNotification(displayName string, userID int64, step *models.Step, text string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🚩 <b>Проблема с шагом</b> от %s (ID: <code>%d</code>)\n", html.EscapeString(displayName), userID))
+	if step != nil {
+		sb.WriteString(fmt.Sprintf("📋 Шаг %d: <i>%s</i>\n", step.StepOrder, html.EscapeString(truncateText(step.Text, 100))))
+	} else {
+		sb.WriteString("📋 Текущего шага нет\n")
+	}
+	sb.WriteString("\n💬 " + html.EscapeString(text))
+	return sb.String()
+}
+
+// handleEditedMessage обрабатывает редактирование сообщений пользователем.
+// Правка последнего ответа на текущий, ещё не пройденный шаг считается новой попыткой,
+// правки более старых сообщений игнорируются
+func (h *BotHandler) handleEditedMessage(ctx context.Context, msg *tgmodels.Message) {
+	if msg.From == nil || msg.Chat.Type != tgmodels.ChatTypePrivate || msg.Text == "" {
+		return
+	}
+
+	userID := msg.From.ID
+	if userID == h.adminID || strings.HasPrefix(msg.Text, "/") {
+		return
+	}
+
+	if shouldProcess, _ := h.questStateMiddleware.ShouldProcessMessage(userID); !shouldProcess {
+		return
+	}
+
+	if h.isUserBlocked(userID) {
+		return
+	}
+
+	// Исправленный ответ проверяется только после принятия правил, как и новый
+	if !h.requireRulesAccepted(ctx, msg.Chat.ID, userID) {
+		return
+	}
+
+	state, err := h.stateResolver.ResolveState(userID)
+	if err != nil || state.CurrentStep == nil || state.IsCompleted {
+		return
+	}
+
+	chatState, _ := h.chatStateRepo.Get(userID)
+	progress, _ := h.progressRepo.GetByUserAndStep(userID, state.CurrentStep.ID)
+
+	if !isEditOfCurrentAnswer(chatState, progress, msg.ID) {
+		log.Printf("[HANDLER] Ignoring edit of message %d from user %d", msg.ID, userID)
+		return
+	}
+
+	log.Printf("[HANDLER] User %d edited answer message %d, treating as new submission", userID, msg.ID)
+	h.handleTextAnswer(ctx, msg)
+}
+
+// isEditOfCurrentAnswer проверяет, что отредактировано последнее сообщение-ответ
+// и шаг ещё ожидает ответа
+func isEditOfCurrentAnswer(chatState *models.ChatState, progress *models.UserProgress, messageID int) bool {
+	if chatState == nil || chatState.LastUserAnswerMessageID == 0 || chatState.LastUserAnswerMessageID != messageID {
+		return false
+	}
+	if chatState.AwaitingNextStep {
+		return false
+	}
+	if progress != nil && (progress.Status == models.StatusApproved || progress.Status == models.StatusSkipped) {
+		return false
+	}
+	return true
+}
+
+func renderSettingMessage(settings *models.Settings, key, fallback string) string {
+	value := settings.Message(key)
+	if value == "" {
+		return fallback
+	}
+	return services.RenderSettingMessage(value, settings.ParseModeFor(key))
+}
+
+func (h *BotHandler) isUserBlocked(userID int64) bool {
+	blocked, err := h.userRepo.IsBlocked(userID)
+	if err != nil {
+		return false
+	}
+	return blocked
+}
+
+func (h *BotHandler) sendShadowBanResponse(ctx context.Context, chatID int64) {
+	settings, _ := h.settingsRepo.GetAll()
+	wrongMsg := renderSettingMessage(settings, "wrong_answer_message", "❌ Неверно, попробуйте ещё раз")
+	h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   wrongMsg,
+	}, "5046589136895476101") // 💩
+}
+
+func (h *BotHandler) handleCallback(ctx context.Context, callback *tgmodels.CallbackQuery) {
+	if callback.Message.Message == nil || callback.Message.Message.Chat.Type != tgmodels.ChatTypePrivate {
+		return
+	}
+
+	if strings.HasPrefix(callback.Data, "rules_accept:") {
+		h.handleRulesAcceptCallback(ctx, callback)
+		return
+	}
+
+	if isQuestCallback(callback.Data) && !h.requireRulesAccepted(ctx, callback.Message.Message.Chat.ID, callback.From.ID) {
+		h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: callback.ID,
+			Text:            "📜 Сначала примите правила квеста",
+		})
+		return
+	}
+
+	if strings.HasPrefix(callback.Data, "next_step:") {
+		h.handleNextStepCallback(ctx, callback)
+		return
+	}
+
+	if strings.HasPrefix(callback.Data, "hint:") {
+		h.handleHintCallback(ctx, callback)
+		return
+	}
+
+	if strings.HasPrefix(callback.Data, "skip_step:") {
+		h.handleSkipStepCallback(ctx, callback)
+		return
+	}
+
+	if strings.HasPrefix(callback.Data, "choice:") {
+		h.handleChoiceCallback(ctx, callback)
+		return
+	}
+
+	if callback.Data == "confirm_completion" {
+		h.handleConfirmCompletionCallback(ctx, callback)
+		return
+	}
+
+	if strings.HasPrefix(callback.Data, "verify_membership:") {
+		h.handleVerifyMembershipCallback(ctx, callback)
+		return
+	}
+
+	if callback.From.ID != h.adminID {
+		log.Printf("[HANDLER] callback from non-admin user: %d", callback.From.ID)
+		return
+	}
+
+	adminHandled := h.adminHandler.HandleCallback(ctx, callback)
+	// log.Printf("[HANDLER] adminHandler.HandleCallback returned: %t", adminHandled)
+	if adminHandled {
+		return
+	}
+
+	log.Printf("[HANDLER] processing callback in main handler: %s", callback.Data)
+	if strings.HasPrefix(callback.Data, "approve:") || strings.HasPrefix(callback.Data, "reject:") {
+		// log.Printf("[HANDLER] calling handleAdminDecision")
+		h.handleAdminDecision(ctx, callback)
+	} else if strings.HasPrefix(callback.Data, "block:") {
+		h.handleBlockUser(ctx, callback)
+	} else if strings.HasPrefix(callback.Data, "admin:view_as:") {
+		h.handleViewAs(ctx, callback)
+	}
+}
+
+// isQuestCallback сообщает, относится ли кнопка к прохождению квеста (следующий шаг, подсказка,
+// пропуск, вариант ответа) — такие нажатия недоступны, пока не приняты правила
+func isQuestCallback(data string) bool {
+	for _, prefix := range []string{"next_step:", "hint:", "skip_step:", "choice:"} {
+		if strings.HasPrefix(data, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *BotHandler) handleStart(ctx context.Context, msg *tgmodels.Message) {
+	user := &models.User{
+		ID:        msg.From.ID,
+		FirstName: msg.From.FirstName,
+		LastName:  msg.From.LastName,
+		Username:  msg.From.Username,
+	}
+
+	_, lookupErr := h.userRepo.GetByID(user.ID)
+	if lookupErr != nil && lookupErr != sql.ErrNoRows {
+		log.Printf("[HANDLER] Error getting user %d: %v", user.ID, lookupErr)
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при регистрации")
+		return
+	}
+	isNewUser := lookupErr == sql.ErrNoRows
+
+	if err := h.userRepo.CreateOrUpdate(user); err != nil {
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при регистрации")
+		return
+	}
+
+	if payload, ok := strings.CutPrefix(msg.Text, "/start "); ok {
+		if h.startPayloadRouter().Dispatch(ctx, msg, strings.TrimSpace(payload), isNewUser) {
+			return
+		}
+	}
+
+	if h.groupChatVerifier != nil {
+		enabled, err := h.groupChatVerifier.IsVerificationEnabled()
+		if err != nil {
+			log.Printf("[HANDLER] Error checking if verification is enabled: %v", err)
+		} else if enabled {
+			isMember, inviteLink, err := h.groupChatVerifier.VerifyMembership(ctx, user.ID)
+			if err != nil {
+				log.Printf("[HANDLER] Error verifying membership for user %d: %v", user.ID, err)
+				h.sendVerificationUI(ctx, msg.Chat.ID, user.ID, inviteLink)
+				return
+			}
+			if !isMember {
+				h.sendVerificationUI(ctx, msg.Chat.ID, user.ID, inviteLink)
+				return
+			}
+		}
+	}
+
+	h.continueStart(ctx, msg.Chat.ID, user.ID)
+}
+
+// continueStart продолжает запуск квеста после регистрации и проверки членства в группе:
+// проверяет состояние квеста, принятие правил и лимит участников, затем отправляет
+// текущий шаг или финальное сообщение
+func (h *BotHandler) continueStart(ctx context.Context, chatID int64, userID int64) {
+	shouldProcess, notification := h.questStateMiddleware.ShouldProcessMessage(userID)
+	if !shouldProcess {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   notification,
+		})
+		return
+	}
+
+	if !h.requireRulesAccepted(ctx, chatID, userID) {
+		return
+	}
+
+	if !h.checkStartCapacity(ctx, userID) {
+		return
+	}
+
+	state, err := h.stateResolver.ResolveState(userID)
+	if err != nil {
+		h.sendError(ctx, chatID, fmt.Sprintf("Ошибка при определении состояния: %v", err))
+		return
+	}
+
+	if h.skipDeactivatedSteps(ctx, userID, state) {
+		return
+	}
+
+	if state.IsCompleted && h.isCompletionPending(userID) {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID:      chatID,
+			Text:        completionConfirmationText,
+			ReplyMarkup: completionConfirmationKeyboard(),
+		})
+		return
+	}
+
+	if state.IsCompleted {
+		settings, _ := h.settingsRepo.GetAll()
+		finalMsg := renderSettingMessage(settings, "final_message", "Поздравляем! Вы прошли квест!")
+
+		completionStats := h.statsService.FormatCompletionStats(userID)
+		if completionStats != "" {
+			finalMsg = finalMsg + "\n\n" + completionStats
+		}
+
+		stickerPackMsg := h.achievementNotifier.FormatStickerPackMessage(userID)
+		if stickerPackMsg != "" {
+			finalMsg = finalMsg + "\n\n" + stickerPackMsg
+		}
+
+		h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   finalMsg,
+		}, "5046509860389126442") // 🎉
+		return
+	}
+
+	settings, _ := h.settingsRepo.GetAll()
+	if h.isReturningUser(userID) {
+		if resumeMsg := renderSettingMessage(settings, "resume_message", ""); resumeMsg != "" {
+			h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+				ChatID: chatID,
+				Text:   resumeMsg,
+			})
+		}
+	} else {
+		welcomeMsg := renderSettingMessage(settings, "welcome_message", "Добро пожаловать в квест!")
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   welcomeMsg,
+		})
+	}
+
+	h.sendStep(ctx, userID, state.CurrentStep)
+}
+
+// isReturningUser сообщает, что участнику уже отправлялся шаг квеста: повторный /start
+// не начинает квест заново, а возвращает его к текущему шагу
+func (h *BotHandler) isReturningUser(userID int64) bool {
+	progress, err := h.progressRepo.GetUserProgress(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error loading progress for user %d: %v", userID, err)
+		return false
+	}
+	return len(progress) > 0
+}
+
+// rulesVersion возвращает действующую версию правил. Правила, заданные без увеличения
+// версии, считаются первой редакцией, чтобы их всё равно пришлось принять
+func rulesVersion(settings *models.Settings) int {
+	return max(settings.RulesVersion, 1)
+}
+
+// requireRulesAccepted показывает правила квеста с кнопкой согласия, если они заданы, а участник
+// ещё не принял текущую редакцию. Возвращает true, когда квест можно продолжать
+func (h *BotHandler) requireRulesAccepted(ctx context.Context, chatID int64, userID int64) bool {
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		log.Printf("[HANDLER] Error loading settings for rules check: %v", err)
+		return true
+	}
+	if settings.RulesMessage == "" {
+		return true
+	}
+
+	accepted, err := h.userRepo.GetAcceptedRulesVersion(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error loading accepted rules version for user %d: %v", userID, err)
+	}
+	if accepted == rulesVersion(settings) {
+		return true
+	}
+
+	h.sendRules(ctx, chatID, settings)
+	return false
+}
+
+func (h *BotHandler) sendRules(ctx context.Context, chatID int64, settings *models.Settings) {
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   renderSettingMessage(settings, "rules_message", ""),
+		ReplyMarkup: &tgmodels.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+				{{Text: "✅ Принимаю", CallbackData: fmt.Sprintf("rules_accept:%d", rulesVersion(settings))}},
+			},
+		},
+	})
+}
+
+// handleRulesAcceptCallback сохраняет согласие с правилами и продолжает запуск квеста.
+// Если правила успели измениться, участнику показывается новая редакция
+func (h *BotHandler) handleRulesAcceptCallback(ctx context.Context, callback *tgmodels.CallbackQuery) {
+	msg := callback.Message.Message
+	userID := callback.From.ID
+
+	version, _ := strconv.Atoi(strings.TrimPrefix(callback.Data, "rules_accept:"))
+
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: callback.ID,
+			Text:            "⚠️ Ошибка. Попробуйте позже.",
+		})
+		return
+	}
+
+	if settings.RulesMessage != "" && version != rulesVersion(settings) {
+		h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: callback.ID,
+			Text:            "📜 Правила обновились, ознакомьтесь с новой редакцией",
+		})
+		h.sendRules(ctx, msg.Chat.ID, settings)
+		return
+	}
+
+	if settings.RulesMessage != "" {
+		if err := h.userRepo.AcceptRules(userID, version); err != nil {
+			log.Printf("[HANDLER] Error saving rules acceptance for user %d: %v", userID, err)
+			h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+				CallbackQueryID: callback.ID,
+				Text:            "⚠️ Ошибка. Попробуйте позже.",
+			})
+			return
+		}
+	}
+
+	h.bot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+	})
+	h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "✅ Правила приняты",
+	})
+
+	h.continueStart(ctx, msg.Chat.ID, userID)
+}
+
+// startPayloadHandler обрабатывает значение параметра /start после префикса. Возвращает true,
+// если обработчик сам ответил пользователю и обычный запуск квеста продолжать не нужно
+type startPayloadHandler func(ctx context.Context, msg *tgmodels.Message, value string, isNewUser bool) bool
+
+type startPayloadRoute struct {
+	prefix string
+	handle startPayloadHandler
+}
+
+// StartPayloadRouter направляет параметр /start (deep link t.me/bot?start=...) обработчику по префиксу.
+// Неизвестные параметры игнорируются, и запуск квеста продолжается как обычно
+type StartPayloadRouter struct {
+	routes []startPayloadRoute
+}
+
+func (r *StartPayloadRouter) Handle(prefix string, handle startPayloadHandler) *StartPayloadRouter {
+	r.routes = append(r.routes, startPayloadRoute{prefix: prefix, handle: handle})
+	return r
+}
+
+// Dispatch вызывает обработчик первого подходящего префикса
+func (r *StartPayloadRouter) Dispatch(ctx context.Context, msg *tgmodels.Message, payload string, isNewUser bool) bool {
+	for _, route := range r.routes {
+		if value, ok := strings.CutPrefix(payload, route.prefix); ok {
+			return route.handle(ctx, msg, value, isNewUser)
+		}
+	}
+	return false
+}
+
+// startPayloadRouter собирает обработчики параметров /start бота:
+// resume_ — продолжить квест, invite_<id> — переход по приглашению,
+// step_<n> — просмотр шага администратором
+func (h *BotHandler) startPayloadRouter() *StartPayloadRouter {
+	return (&StartPayloadRouter{}).
+		Handle("resume_", func(context.Context, *tgmodels.Message, string, bool) bool {
+			return false
+		}).
+		Handle(services.ReferralPayloadPrefix, h.handleInvitePayload).
+		Handle("step_", h.handleStepPayload)
+}
+
+func (h *BotHandler) handleInvitePayload(ctx context.Context, msg *tgmodels.Message, value string, isNewUser bool) bool {
+	if h.referralService == nil {
+		return false
+	}
+	if _, err := h.referralService.RegisterReferral(services.ReferralPayloadPrefix+value, msg.From.ID, isNewUser); err != nil {
+		log.Printf("[HANDLER] Error registering referral for user %d: %v", msg.From.ID, err)
+	}
+	return false
+}
+
+// handleStepPayload показывает администратору шаг с указанным номером так, как его увидит
+// участник, не меняя прогресс. Для остальных пользователей параметр игнорируется
+func (h *BotHandler) handleStepPayload(ctx context.Context, msg *tgmodels.Message, value string, _ bool) bool {
+	if msg.From.ID != h.adminID {
+		return false
+	}
+
+	order, err := strconv.Atoi(value)
+	if err != nil {
+		return false
+	}
+
+	steps, err := h.stepRepo.GetAll()
+	if err != nil {
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при получении шагов")
+		return true
+	}
+
+	for _, step := range steps {
+		if step.StepOrder != order {
+			continue
+		}
+		preview := *step
+		preview.Text = fmt.Sprintf("🧪 <b>Просмотр шага %d</b>\n\n%s", step.StepOrder, step.Text)
+		h.msgManager.SendTaskWithButtons(ctx, msg.Chat.ID, &preview, false, false)
+		return true
+	}
+
+	h.sendError(ctx, msg.Chat.ID, fmt.Sprintf("Шаг %d не найден", order))
+	return true
+}
+
+func (h *BotHandler) sendStep(ctx context.Context, userID int64, step *models.Step) {
+	if step == nil {
+		return
+	}
+
+	progress, _ := h.progressRepo.GetByUserAndStep(userID, step.ID)
+	if progress == nil {
+		h.progressRepo.Create(&models.UserProgress{
+			UserID: userID,
+			StepID: step.ID,
+			Status: models.StatusPending,
+		})
+	}
+
+	if h.isStepLocked(userID, step) {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: userID,
+			Text:   fmt.Sprintf("🔒 Шаг %d закрыт промокодом.\n\nВведите его командой /code <промокод>", step.StepOrder),
+		})
+		return
+	}
+
+	stepWithHint, showHintButton := h.renderStep(userID, step)
+
+	h.showTypingBeforeStep(ctx, userID)
+	if err := h.msgManager.SendTaskWithButtons(ctx, userID, stepWithHint, showHintButton, step.IsAsterisk); err != nil {
+		h.enqueueStep(userID, step.ID, err)
+	}
+}
+
+// enqueueStep ставит задание в очередь повторной отправки: целиком, если не доставлено
+// само задание, или только вложения начиная с первого недоставленного
+func (h *BotHandler) enqueueStep(userID, stepID int64, err error) bool {
+	payload := strconv.FormatInt(stepID, 10)
+	var partial *services.StepAttachmentsError
+	if errors.As(err, &partial) {
+		payload += ":" + strconv.Itoa(partial.From)
+	}
+	return h.notificationQueue.Enqueue(userID, services.NotificationStep, payload, err)
+}
+
+// resendStep повторно отправляет задание из очереди. Если участник уже ушёл с этого шага,
+// отправлять нечего. Payload «шаг:номер» означает, что задание доставлено и досылать
+// нужно только вложения начиная с этого номера
+func (h *BotHandler) resendStep(ctx context.Context, userID int64, payload string) error {
+	stepPart, fromPart, attachmentsOnly := strings.Cut(payload, ":")
+	stepID, err := strconv.ParseInt(stepPart, 10, 64)
+	from := 0
+	if err == nil && attachmentsOnly {
+		from, err = strconv.Atoi(fromPart)
+	}
+	if err != nil {
+		log.Printf("[HANDLER] Invalid queued step %q for user %d", payload, userID)
+		return nil
+	}
+
+	state, err := h.stateResolver.ResolveState(userID)
+	if err != nil {
+		return err
+	}
+	if state.IsCompleted || state.CurrentStep == nil || state.CurrentStep.ID != stepID || h.isStepLocked(userID, state.CurrentStep) {
+		return nil
+	}
+
+	stepWithHint, showHintButton := h.renderStep(userID, state.CurrentStep)
+	if attachmentsOnly {
+		err = h.msgManager.SendStepAttachments(ctx, userID, stepWithHint, from)
+	} else {
+		err = h.msgManager.SendTaskWithButtons(ctx, userID, stepWithHint, showHintButton, state.CurrentStep.IsAsterisk)
+	}
+
+	// Если доставлено больше, чем было, в очередь встаёт только оставшееся
+	var partial *services.StepAttachmentsError
+	if errors.As(err, &partial) && (!attachmentsOnly || partial.From > from) {
+		h.enqueueStep(userID, stepID, err)
+		return nil
+	}
+	return err
+}
+
+// renderStep собирает задание так, как его видит участник: с прогресс-баром, заголовком
+// главы и подсказкой о типе ответа. Второе значение сообщает, показывать ли кнопку подсказки.
+// Состояние участника не меняется, поэтому задание можно показать и админу
+func (h *BotHandler) renderStep(userID int64, step *models.Step) (*models.Step, bool) {
+	answerHint := ""
+	switch step.AnswerType {
+	case models.AnswerTypeText:
+		// answerHint = "\n\n📝 Ответьте текстом или числом"
+	case models.AnswerTypeImage:
+		answerHint = "\n\n📷 Отправьте фото"
+	case models.AnswerTypeLocation:
+		answerHint = "\n\n📍 Отправьте геопозицию"
+	case models.AnswerTypeChoice:
+		answerHint = "\n\n🔘 Выберите вариант ответа"
+	case models.AnswerTypeLink:
+		answerHint = "\n\n🔗 Отправьте ссылку"
+	}
+
+	// Добавляем прогресс-бар
+	progressText := h.getProgressText(userID)
+	if chapterHeader := h.getChapterHeader(userID, step); chapterHeader != "" {
+		progressText = chapterHeader + "\n" + progressText
+	}
+
+	stepWithHint := &models.Step{
+		ID:           step.ID,
+		StepOrder:    step.StepOrder,
+		Text:         progressText + "\n\n" + step.Text + answerHint,
+		AnswerType:   step.AnswerType,
+		HasAutoCheck: step.HasAutoCheck,
+		IsActive:     step.IsActive,
+		IsDeleted:    step.IsDeleted,
+		IsAsterisk:   step.IsAsterisk,
+		Images:       step.Images,
+		Answers:      step.Answers,
+		Choices:      step.Choices,
+		Documents:    step.Documents,
+		HintText:     step.HintText,
+		HintImage:    step.HintImage,
+		ChapterID:    step.ChapterID,
+	}
+
+	// Check if hint button should be shown
+	showHintButton := false
+	if step.HasHint() {
+		chatState, err := h.chatStateRepo.Get(userID)
+		if err == nil && chatState != nil {
+			showHintButton = !chatState.CurrentStepHintUsed
+		} else {
+			showHintButton = true
+		}
+	}
+
+	return stepWithHint, showHintButton
+}
+
+// handleViewAs показывает админу текущий шаг участника так, как его видит участник
+// (admin:view_as:<id>). Прогресс и состояние чата участника не меняются
+func (h *BotHandler) handleViewAs(ctx context.Context, callback *tgmodels.CallbackQuery) {
+	userID, _ := parseInt64(strings.TrimPrefix(callback.Data, "admin:view_as:"))
+	if userID == 0 {
+		return
+	}
+	chatID := callback.From.ID
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		h.sendError(ctx, chatID, "Пользователь не найден")
+		return
+	}
+	header := fmt.Sprintf("👁 <b>Глазами участника %s</b>", html.EscapeString(user.DisplayName()))
+
+	state, err := h.stateResolver.ResolveState(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error resolving state for user %d: %v", userID, err)
+		h.sendError(ctx, chatID, "Ошибка при получении состояния участника")
+		return
+	}
+
+	var status string
+	switch {
+	case state.IsCompleted:
+		status = "🏁 Участник прошёл квест"
+	case state.CurrentStep == nil:
+		status = "Текущий шаг не найден"
+	case h.isStepLocked(userID, state.CurrentStep):
+		status = fmt.Sprintf("🔒 Шаг %d закрыт промокодом — участник видит просьбу ввести /code", state.CurrentStep.StepOrder)
+	}
+	if status != "" {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      header + "\n\n" + status,
+			ParseMode: tgmodels.ParseModeHTML,
+		})
+		return
+	}
+
+	preview, showHintButton := h.renderStep(userID, state.CurrentStep)
+	header = fmt.Sprintf("%s\n📊 Шаг %d", header, state.CurrentStep.StepOrder)
+	if statusText := progressStatusLabel(state.Status); statusText != "" {
+		header += " — " + statusText
+	}
+	// Кнопки превью сработали бы от имени админа, поэтому вместо клавиатуры
+	// варианты и доступные участнику действия перечисляются текстом
+	var buttons []string
+	for _, choice := range preview.Choices {
+		buttons = append(buttons, "🔘 "+choice.Text)
+	}
+	if showHintButton {
+		buttons = append(buttons, "💡 Подсказка")
+	}
+	if state.CurrentStep.IsAsterisk {
+		buttons = append(buttons, "⏭ Пропустить")
+	}
+	preview.Text = header + "\n\n" + preview.Text
+	if len(buttons) > 0 {
+		preview.Text += "\n\nКнопки участника:\n" + strings.Join(buttons, "\n")
+	}
+	preview.Choices = nil
+	h.msgManager.SendTaskWithButtons(ctx, chatID, preview, false, false)
+}
+
+// stepTypingDelayUnit — единица задержки «печатает…» перед шагом из настроек
+var stepTypingDelayUnit = time.Second
+
+// showTypingBeforeStep показывает участнику «печатает…» и выжидает заданную в настройках паузу
+// перед отправкой шага. Ответ к этому моменту уже проверен и сохранён, поэтому пауза
+// задерживает только появление следующего задания
+func (h *BotHandler) showTypingBeforeStep(ctx context.Context, userID int64) {
+	delay, _ := h.settingsRepo.GetStepTypingDelay()
+	if delay <= 0 {
+		return
+	}
+
+	if _, err := h.bot.SendChatAction(ctx, &bot.SendChatActionParams{
+		ChatID: userID,
+		Action: tgmodels.ChatActionTyping,
+	}); err != nil {
+		log.Printf("[HANDLER] Failed to send typing action to user %d: %v", userID, err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Duration(delay) * stepTypingDelayUnit):
+	}
+}
+
+// getChapterHeader возвращает заголовок главы шага с прохождением главы участником.
+// Для шагов без главы возвращается пустая строка
+func (h *BotHandler) getChapterHeader(userID int64, step *models.Step) string {
+	if step.ChapterID == 0 {
+		return ""
+	}
+
+	chapters, err := h.stepRepo.GetChapterProgress(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error getting chapter progress for user %d: %v", userID, err)
+		return ""
+	}
+
+	for _, chapter := range chapters {
+		if chapter.Chapter.ID == step.ChapterID {
+			return FormatChapterHeader(chapter)
+		}
+	}
+	return ""
+}
+
+// FormatChapterHeader форматирует заголовок главы для задания, например «📖 Глава 2: Лес (1/3)»
+func FormatChapterHeader(chapter models.ChapterProgress) string {
+	return fmt.Sprintf("📖 <b>Глава %d: %s</b> (%d/%d)", chapter.Number, html.EscapeString(chapter.Chapter.Title), chapter.Completed, chapter.Total)
+}
+
+func (h *BotHandler) getProgressText(userID int64) string {
+	_, total, percentage, err := h.statsService.GetUserProgress(userID)
+	if err != nil || total == 0 {
+		return ""
+	}
+
+	barLength := int(percentage * 20 / 100)
+	if barLength > 20 {
+		barLength = 20
+	}
+
+	progressBar := strings.Repeat("▰", barLength) + strings.Repeat("▱", 20-barLength)
+
+	settings, _ := h.settingsRepo.GetAll()
+	if settings != nil && settings.ScoringEnabled {
+		score, err := h.statsService.GetUserScore(userID, settings.ScorePointsPerStep, settings.ScoreHintPenalty)
+		if err == nil {
+			progressBar += fmt.Sprintf("\n⭐ Очки: %d", score)
+		}
+	}
+
+	return progressBar
+}
+
+// isVerboseMatching сообщает, нужно ли показывать разбор проверки ответа: только администратору,
+// проходящему квест сам, и только при включённой подробной проверке
+func (h *BotHandler) isVerboseMatching(userID int64) bool {
+	if userID != h.adminID {
+		return false
+	}
+	verbose, _ := h.settingsRepo.GetVerboseMatching()
+	return verbose
+}
+
+func (h *BotHandler) sendMatchTrace(ctx context.Context, step *models.Step, result *services.CheckResult) {
+	log.Printf("[MATCH_TRACE] step=%d input=%q correct=%t rules=%+v comparisons=%+v", step.StepOrder, result.Trace.Input, result.IsCorrect, result.Trace.Rules, result.Trace.Comparisons)
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID:    h.adminID,
+		Text:      services.FormatMatchTrace(result.Trace, result.IsCorrect),
+		ParseMode: tgmodels.ParseModeHTML,
+	})
+}
+
+// recordMatchRule сохраняет в ответе участника правило, по которому он был засчитан,
+// если это включено в настройке «🧾 Журнал совпадений»
+func (h *BotHandler) recordMatchRule(answerID int64, result *services.CheckResult) {
+	if answerID == 0 || result.MatchKind == "" {
+		return
+	}
+	if enabled, err := h.settingsRepo.GetMatchRuleLogging(); err != nil || !enabled {
+		return
+	}
+	if err := h.answerRepo.SetMatchRule(answerID, string(result.MatchKind)); err != nil {
+		log.Printf("[HANDLER] Error saving match rule for answer %d: %v", answerID, err)
+	}
+}
+
+func (h *BotHandler) handleTextAnswer(ctx context.Context, msg *tgmodels.Message) {
+	userID := msg.From.ID
+
+	state, err := h.stateResolver.ResolveState(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error resolving state for user %d: %v", userID, err)
+		return
+	}
+
+	h.evaluateSecretAnswer(ctx, userID, msg.Text)
+
+	if h.skipDeactivatedSteps(ctx, userID, state) {
+		return
+	}
+
+	if state.IsCompleted {
+		log.Printf("[HANDLER] User %d completed quest, forwarding message to admin", userID)
+		h.evaluateAchievementsOnPostCompletion(ctx, userID)
+		h.forwardMessageToAdmin(ctx, msg, nil, "после завершения квеста")
+		return
+	}
+
+	if state.CurrentStep == nil {
+		log.Printf("[HANDLER] User %d has no current step", userID)
+		return
+	}
+
+	step := state.CurrentStep
+	log.Printf("[HANDLER] User %d on step %d (order %d)", userID, step.ID, step.StepOrder)
+
+	chatState, _ := h.chatStateRepo.Get(userID)
+	progress, _ := h.progressRepo.GetByUserAndStep(userID, step.ID)
+
+	if chatState != nil && chatState.AwaitingNextStep && (progress == nil || progress.Status == models.StatusPending) {
 		log.Printf("[HANDLER] User %d sent text message while awaiting next step, moving to next step", userID)
 
 		if h.achievementEngine != nil {
@@ -441,6 +1742,10 @@ func (h *BotHandler) handleTextAnswer(ctx context.Context, msg *tgmodels.Message
 		log.Printf("[HANDLER] User %d progress on step %d: status=%s", userID, step.ID, progress.Status)
 	}
 
+	if h.rejectLockedStep(ctx, userID, msg.ID, step) {
+		return
+	}
+
 	if step.AnswerType == models.AnswerTypeImage {
 		h.forwardMessageToAdmin(ctx, msg, step, "при отправке текста на вопрос-изображение")
 
@@ -456,7 +1761,34 @@ func (h *BotHandler) handleTextAnswer(ctx context.Context, msg *tgmodels.Message
 		return
 	}
 
+	if step.AnswerType == models.AnswerTypeLocation {
+		h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+		h.msgManager.SendReaction(ctx, userID, "📍 Для этого задания нужно отправить геопозицию")
+		return
+	}
+
+	if step.AnswerType == models.AnswerTypeChoice {
+		h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+		h.msgManager.SendReaction(ctx, userID, choiceAnswerReaction)
+		return
+	}
+
+	if h.rejectNonReply(ctx, msg, step) {
+		return
+	}
+
+	if step.AnswerType == models.AnswerTypeLink {
+		h.handleLinkAnswer(ctx, msg, step)
+		return
+	}
+
 	h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+
+	if h.isAnswerTooShort(step, msg.Text) {
+		h.msgManager.SendReaction(ctx, userID, "✏️ Слишком короткий ответ, напишите подробнее")
+		return
+	}
+
 	h.msgManager.CleanupHintMessage(ctx, userID)
 
 	chatState, err = h.chatStateRepo.Get(userID)
@@ -465,27 +1797,60 @@ func (h *BotHandler) handleTextAnswer(ctx context.Context, msg *tgmodels.Message
 		hintUsed = chatState.CurrentStepHintUsed
 	}
 
-	h.answerRepo.CreateTextAnswer(userID, step.ID, msg.Text, hintUsed)
+	answerID, _ := h.answerRepo.CreateTextAnswer(userID, step.ID, msg.Text, hintUsed)
 
 	if hintUsed {
 		h.chatStateRepo.ResetHintUsed(userID)
 	}
 
 	if step.HasAutoCheck && len(step.Answers) > 0 {
-		result, err := h.answerChecker.CheckTextAnswer(step.ID, msg.Text)
+		var result *services.CheckResult
+		verbose := h.isVerboseMatching(userID)
+		switch {
+		case step.OrderedAnswers && verbose:
+			result, err = h.answerChecker.CheckSequenceAnswerWithTrace(userID, step, msg.Text)
+		case step.OrderedAnswers:
+			result, err = h.answerChecker.CheckSequenceAnswer(userID, step, msg.Text)
+		case step.RequiredAnswers > 0 && verbose:
+			result, err = h.answerChecker.CheckSetAnswerWithTrace(step, msg.Text)
+		case step.RequiredAnswers > 0:
+			result, err = h.answerChecker.CheckSetAnswer(step, msg.Text)
+		case step.NumericFeedback && verbose:
+			result, err = h.answerChecker.CheckNumericAnswerWithTrace(step, msg.Text)
+		case step.NumericFeedback:
+			result, err = h.answerChecker.CheckNumericAnswer(step, msg.Text)
+		case verbose:
+			result, err = h.answerChecker.CheckTextAnswerWithTrace(step.ID, msg.Text)
+		default:
+			result, err = h.answerChecker.CheckTextAnswer(step.ID, msg.Text)
+		}
 		if err != nil {
 			h.sendError(ctx, msg.Chat.ID, "Ошибка при проверке ответа")
 			return
 		}
+		if result.Trace != nil {
+			h.sendMatchTrace(ctx, step, result)
+		}
+		h.recordMatchRule(answerID, result)
+		metrics.Default.AnswersChecked.Inc()
 
 		if result.IsCorrect {
-			h.handleCorrectAnswer(ctx, userID, step, result.Percentage, msg.Text)
+			h.handleCorrectAnswer(ctx, userID, step, result.Percentage, result.Language)
+		} else if result.SequencePosition > 0 && !result.SequenceReset {
+			h.msgManager.DeleteUserAnswerAndReaction(ctx, userID)
+			h.msgManager.SendReaction(ctx, userID, FormatSequenceProgress(result.SequencePosition, result.SequenceLength))
 		} else {
 			h.msgManager.DeleteUserAnswerAndReaction(ctx, userID)
 			settings, _ := h.settingsRepo.GetAll()
-			wrongMsg := "❌ Неверно, попробуйте ещё раз"
-			if settings != nil && settings.WrongAnswerMessage != "" {
-				wrongMsg = settings.WrongAnswerMessage
+			wrongMsg := renderSettingMessage(settings, "wrong_answer_message", "❌ Неверно, попробуйте ещё раз")
+			if len(result.Matched) > 0 {
+				wrongMsg = FormatPartialSetAnswer(result.Matched, result.Missing)
+			}
+			if hint := FormatNumericCloseness(result.Closeness); hint != "" {
+				wrongMsg = hint
+			}
+			if result.SequenceReset {
+				wrongMsg = FormatSequenceReset(result.SequencePosition, result.SequenceLength)
 			}
 
 			wrongEffects := []string{
@@ -534,9 +1899,46 @@ func (h *BotHandler) handleTextAnswer(ctx context.Context, msg *tgmodels.Message
 	}
 }
 
-func (h *BotHandler) handleCorrectAnswer(ctx context.Context, userID int64, step *models.Step, percentage int, _ string) {
+// FormatNumericCloseness возвращает подсказку «больше/меньше» для неверного числового
+// ответа или пустую строку, если подсказки нет
+func FormatNumericCloseness(closeness services.NumericCloseness) string {
+	switch closeness {
+	case services.ClosenessTooHigh:
+		return "📉 Слишком много, попробуйте число поменьше"
+	case services.ClosenessTooLow:
+		return "📈 Слишком мало, попробуйте число побольше"
+	default:
+		return ""
+	}
+}
+
+// isAnswerTooShort сообщает, что ответ короче минимальной длины из настроек.
+// Такой ответ не сохраняется и не считается попыткой. Числовые шаги, последовательности
+// и наборы не проверяются — короткий ответ вроде «7» или «1,2» там нормален, — как и шаги,
+// у которых сам правильный ответ короче минимума
+func (h *BotHandler) isAnswerTooShort(step *models.Step, answer string) bool {
+	if step.NumericFeedback || step.OrderedAnswers || step.RequiredAnswers > 0 {
+		return false
+	}
+
+	minLength, err := h.settingsRepo.GetMinAnswerLength()
+	if err != nil || minLength <= 0 {
+		return false
+	}
+	for _, accepted := range step.Answers {
+		if utf8.RuneCountInString(strings.TrimSpace(accepted)) < minLength {
+			return false
+		}
+	}
+	return utf8.RuneCountInString(strings.TrimSpace(answer)) < minLength
+}
+
+// handleCorrectAnswer засчитывает ответ. language — язык совпавшего варианта ответа,
+// на нём отправляется сообщение о правильном ответе, если оно настроено
+func (h *BotHandler) handleCorrectAnswer(ctx context.Context, userID int64, step *models.Step, percentage int, language string) {
 	// log.Printf("[HANDLER] handleCorrectAnswer started for user %d, step %d", userID, step.ID)
 
+	h.reactToCorrectAnswer(ctx, userID)
 	h.msgManager.DeleteUserAnswerAndReaction(ctx, userID)
 
 	h.progressRepo.Update(&models.UserProgress{
@@ -546,168 +1948,589 @@ func (h *BotHandler) handleCorrectAnswer(ctx context.Context, userID int64, step
 	})
 
 	nextStep, _ := h.stepRepo.GetNextActive(step.StepOrder, userID)
-	isLastStep := nextStep == nil
+	isLastStep := nextStep == nil || h.minCompletionReached(userID)
 
 	// log.Printf("[HANDLER] Evaluating achievements for user %d, isLastStep=%v", userID, isLastStep)
 
-	// Сначала обрабатываем достижения
-	if isLastStep {
-		h.evaluateAchievementsOnQuestCompleted(ctx, userID)
+	// Сначала обрабатываем достижения. Если финиш нужно подтвердить, достижения
+	// за прохождение выдаются только после подтверждения
+	var completionAwarded []string
+	if isLastStep && !h.deferCompletion(userID) {
+		completionAwarded = h.evaluateAchievementsOnQuestCompleted(ctx, userID)
 	} else {
 		h.evaluateAchievementsOnCorrectAnswer(ctx, userID, step.ID)
 	}
 
 	// log.Printf("[HANDLER] Achievements evaluated, sending correct message to user %d", userID)
 
+	h.sendCorrectAnswerResponse(ctx, userID, step, percentage, isLastStep, language, "", completionAwarded)
+}
+
+// minCompletionReached сообщает, что участник одобрил минимальное для прохождения число
+// шагов из настроек: тогда квест завершается, как после последнего шага
+func (h *BotHandler) minCompletionReached(userID int64) bool {
+	if h.stateResolver == nil {
+		return false
+	}
+	reached, err := h.stateResolver.MinCompletionReached(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error checking minimum completion for user %d: %v", userID, err)
+		return false
+	}
+	return reached
+}
+
+// reactToCorrectAnswer ставит на верный ответ участника реакцию из настроек. Если Telegram
+// не принял реакцию, ответ удаляется как обычно
+func (h *BotHandler) reactToCorrectAnswer(ctx context.Context, userID int64) {
+	emoji, err := h.settingsRepo.GetCorrectAnswerReaction()
+	if err != nil || emoji == "" {
+		return
+	}
+	if err := h.msgManager.ReactToUserAnswer(ctx, userID, emoji); err != nil {
+		log.Printf("[HANDLER] Failed to react to correct answer of user %d: %v", userID, err)
+	}
+}
+
+// sendCorrectAnswerResponse отправляет сообщение о правильном ответе с картинкой и кнопкой следующего шага,
+// а для последнего шага — финальное сообщение с достижениями completionAwarded. note добавляется
+// перед текстом, если не пустой
+func (h *BotHandler) sendCorrectAnswerResponse(ctx context.Context, userID int64, step *models.Step, percentage int, isLastStep bool, language, note string, completionAwarded []string) {
+	// Картинку правильного ответа отправляем только если одобрение действительно сохранилось
+	progress, _ := h.progressRepo.GetByUserAndStep(userID, step.ID)
+	correctImage := correctAnswerImageFor(step, progress)
+
 	settings, _ := h.settingsRepo.GetAll()
-	correctMsg := "✅ Правильно!"
-	if settings != nil && settings.CorrectAnswerMessage != "" {
-		correctMsg = settings.CorrectAnswerMessage
+	correctMsg := renderSettingMessage(settings, settings.CorrectAnswerMessageKey(language), "✅ Правильно!")
+	if note != "" {
+		correctMsg = note + "\n\n" + correctMsg
 	}
 
 	if percentage > 0 {
 		correctMsg = fmt.Sprintf("%s\n\n📊 <i>До этого шага дошли %d%% участников</i>", correctMsg, percentage)
 	}
 
+	if !isLastStep {
+		if progressBar := h.answerProgressBar(userID); progressBar != "" {
+			correctMsg = correctMsg + "\n\n" + progressBar
+		}
+	}
+
 	correctEffects := []string{
 		"5107584321108051014", // 👍
 		"5104841245755180586", // 🔥
 	}
 	effectID := correctEffects[rand.Intn(len(correctEffects))]
 
-	if isLastStep {
-		finalMsg := "🎉 Поздравляем! Вы прошли квест!"
-		if settings != nil && settings.FinalMessage != "" {
-			finalMsg = settings.FinalMessage
-		}
-
-		completionStats := h.statsService.FormatCompletionStats(userID)
-		if completionStats != "" {
-			finalMsg = finalMsg + "\n\n" + completionStats
-		}
-
-		stickerPackMsg := h.achievementNotifier.FormatStickerPackMessage(userID)
-		if stickerPackMsg != "" {
-			finalMsg = finalMsg + "\n\n" + stickerPackMsg
-		}
+	if isLastStep && h.isCompletionPending(userID) {
+		h.sendCorrectMessage(ctx, userID, step, correctMsg+"\n\n"+completionConfirmationText, correctImage, effectID, completionConfirmationKeyboard())
+		return
+	}
 
-		correctMsg = correctMsg + "\n\n" + finalMsg
+	if isLastStep {
+		correctMsg = correctMsg + "\n\n" + h.questFinalMessage(ctx, userID, settings, completionAwarded)
 
-		if step.CorrectAnswerImage != "" {
-			// log.Printf("[HANDLER] Sending final photo to user %d", userID)
-			_, err := h.bot.SendPhoto(ctx, &bot.SendPhotoParams{
-				ChatID:          userID,
-				Photo:           &tgmodels.InputFileString{Data: step.CorrectAnswerImage},
-				Caption:         correctMsg,
-				ParseMode:       tgmodels.ParseModeHTML,
-				MessageEffectID: "5046509860389126442", // 🎉
-			})
-			if err != nil {
-				log.Printf("[HANDLER] Failed to send final photo to user %d: %v, sending text message instead", userID, err)
-				// Если не удалось отправить фото, отправляем текстовое сообщение
-				h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
-					ChatID: userID,
-					Text:   correctMsg,
-				}, "5046509860389126442") // 🎉
-			}
-		} else {
-			h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
-				ChatID: userID,
-				Text:   correctMsg,
-			}, "5046509860389126442") // 🎉
-		}
+		h.sendCorrectMessage(ctx, userID, step, correctMsg, correctImage, "5046509860389126442", nil) // 🎉
 
 		h.notifyAdminQuestCompleted(ctx, userID)
+		h.sendAnswerSummary(ctx, userID)
+		h.sendShareMessage(ctx, userID)
+		h.releaseWaitlist(ctx)
 		return
 	}
 
-	nextStepBtn := tgmodels.InlineKeyboardMarkup{
-		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
-			{{Text: "Следующий вопрос ➡️", CallbackData: fmt.Sprintf("next_step:%d", step.StepOrder)}},
-		},
+	autoAdvance, _ := h.settingsRepo.GetAutoAdvance()
+	if autoAdvance {
+		h.sendCorrectAndAdvance(ctx, userID, step, correctMsg, correctImage, effectID)
+		return
 	}
 
+	nextStepBtn := nextStepKeyboard(step.StepOrder)
+
 	h.chatStateRepo.SetAwaitingNextStep(userID)
 
-	if step.CorrectAnswerImage != "" {
-		msg, err := h.bot.SendPhoto(ctx, &bot.SendPhotoParams{
+	if msg := h.sendCorrectMessage(ctx, userID, step, correctMsg, correctImage, effectID, nextStepBtn); msg != nil {
+		h.chatStateRepo.UpdateReactionMessageID(userID, msg.ID)
+	}
+}
+
+// answerProgressBar возвращает прогресс-бар для сообщения о правильном ответе,
+// если он включён в настройках
+func (h *BotHandler) answerProgressBar(userID int64) string {
+	if enabled, err := h.settingsRepo.GetProgressAfterAnswer(); err != nil || !enabled {
+		return ""
+	}
+	answered, total, _, err := h.statsService.GetUserProgress(userID)
+	if err != nil || total == 0 {
+		return ""
+	}
+	return services.ProgressBar(answered, total)
+}
+
+// correctImageDelayUnit — единица задержки картинки правильного ответа из настроек
+var correctImageDelayUnit = time.Second
+
+// sendCorrectMessage отправляет сообщение о правильном ответе. Картинка ответа по умолчанию
+// идёт подписью к нему, а если в настройках задана задержка — отдельным сообщением после
+// текста, чтобы участник сначала увидел, что ответ верный. Если картинка не отправилась
+// (например, её file_id устарел), участник всё равно получает текст, а админ — сообщение
+// об ошибке. Возвращает текстовое сообщение или фото с подписью, к которому прикреплена клавиатура
+func (h *BotHandler) sendCorrectMessage(ctx context.Context, userID int64, step *models.Step, text, image, effectID string, keyboard tgmodels.ReplyMarkup) *tgmodels.Message {
+	delay, _ := h.settingsRepo.GetCorrectImageDelay()
+
+	if image != "" && delay <= 0 {
+		msg, err := h.msgManager.TrySendPhoto(ctx, &bot.SendPhotoParams{
 			ChatID:          userID,
-			Photo:           &tgmodels.InputFileString{Data: step.CorrectAnswerImage},
-			Caption:         correctMsg,
+			Photo:           &tgmodels.InputFileString{Data: image},
+			Caption:         text,
 			ParseMode:       tgmodels.ParseModeHTML,
-			ReplyMarkup:     nextStepBtn,
+			ReplyMarkup:     keyboard,
 			MessageEffectID: effectID,
 		})
-		if err != nil {
-			log.Printf("[HANDLER] Failed to send photo to user %d: %v, sending text message instead", userID, err)
-			msg, _ = h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
-				ChatID:      userID,
-				Text:        correctMsg,
-				ReplyMarkup: nextStepBtn,
-			}, effectID)
-		}
-		if msg != nil {
-			h.chatStateRepo.UpdateReactionMessageID(userID, msg.ID)
-		}
-	} else {
-		msg, _ := h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
-			ChatID:      userID,
-			Text:        correctMsg,
-			ReplyMarkup: nextStepBtn,
-		}, effectID)
-		if msg != nil {
-			h.chatStateRepo.UpdateReactionMessageID(userID, msg.ID)
+		if err == nil {
+			return msg
 		}
+		log.Printf("[HANDLER] Failed to send photo to user %d: %v, sending text message instead", userID, err)
+		h.reportCorrectImageFailure(ctx, step, err)
+	}
+
+	// С задержкой картинка приходит последней, поэтому кнопки прикрепляются к ней,
+	// чтобы оставаться под последним сообщением
+	delayedImage := image != "" && delay > 0
+	textKeyboard := keyboard
+	if delayedImage {
+		textKeyboard = nil
+	}
+
+	msg, _ := h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        text,
+		ReplyMarkup: textKeyboard,
+	}, effectID)
+
+	if !delayedImage {
+		return msg
+	}
+
+	select {
+	case <-ctx.Done():
+		h.attachKeyboard(context.WithoutCancel(ctx), userID, msg, keyboard)
+		return msg
+	case <-time.After(time.Duration(delay) * correctImageDelayUnit):
+	}
+	photo, err := h.msgManager.TrySendPhoto(ctx, &bot.SendPhotoParams{
+		ChatID:      userID,
+		Photo:       &tgmodels.InputFileString{Data: image},
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		log.Printf("[HANDLER] Failed to send correct answer photo to user %d: %v", userID, err)
+		h.reportCorrectImageFailure(ctx, step, err)
+		h.attachKeyboard(ctx, userID, msg, keyboard)
+		return msg
+	}
+	return photo
+}
+
+// attachKeyboard добавляет кнопки к уже отправленному сообщению, если картинку с ними отправить не удалось
+func (h *BotHandler) attachKeyboard(ctx context.Context, userID int64, msg *tgmodels.Message, keyboard tgmodels.ReplyMarkup) {
+	if msg == nil || keyboard == nil {
+		return
+	}
+	if _, err := h.bot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:      userID,
+		MessageID:   msg.ID,
+		ReplyMarkup: keyboard,
+	}); err != nil {
+		log.Printf("[HANDLER] Failed to attach keyboard for user %d: %v", userID, err)
+	}
+}
+
+// reportCorrectImageFailure сообщает админу, что картинка правильного ответа шага не
+// отправляется и её нужно загрузить заново. Повторы схлопывает ErrorManager
+func (h *BotHandler) reportCorrectImageFailure(ctx context.Context, step *models.Step, err error) {
+	if h.errorManager == nil {
+		return
+	}
+	stepOrder := 0
+	if step != nil {
+		stepOrder = step.StepOrder
+	}
+	h.errorManager.Report(ctx, services.ErrorCategoryTelegram, fmt.Errorf("correct answer image of step %d failed to send, re-upload it in the step settings: %w", stepOrder, err))
+}
+
+func nextStepKeyboard(stepOrder int) tgmodels.InlineKeyboardMarkup {
+	return tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "Следующий вопрос ➡️", CallbackData: fmt.Sprintf("next_step:%d", stepOrder)}},
+		},
+	}
+}
+
+// sendCorrectAndAdvance отправляет сообщение о правильном ответе без кнопки и сразу выдаёт
+// следующий шаг. Сообщение не запоминается как реакция, поэтому остаётся в чате над новым заданием
+func (h *BotHandler) sendCorrectAndAdvance(ctx context.Context, userID int64, step *models.Step, correctMsg, correctImage, effectID string) {
+	h.sendCorrectMessage(ctx, userID, step, correctMsg, correctImage, effectID, nil)
+	h.advanceToNextStep(ctx, userID, step.StepOrder)
+}
+
+// advanceToNextStep снимает ожидание кнопки «Следующий вопрос» и выдаёт следующий шаг
+func (h *BotHandler) advanceToNextStep(ctx context.Context, userID int64, currentOrder int) {
+	h.chatStateRepo.ClearAwaitingNextStep(userID)
+	h.moveToNextStep(ctx, userID, currentOrder)
+}
+
+// correctAnswerImageFor возвращает картинку правильного ответа только для одобренного шага,
+// чтобы она не попала к участникам, которые ещё не ответили верно
+func correctAnswerImageFor(step *models.Step, progress *models.UserProgress) string {
+	if step == nil || progress == nil || progress.Status != models.StatusApproved {
+		return ""
 	}
+	return step.CorrectAnswerImage
 }
 
 func (h *BotHandler) moveToNextStep(ctx context.Context, userID int64, currentOrder int) {
 	nextStep, err := h.stepRepo.GetNextActive(currentOrder, userID)
 	if err != nil || nextStep == nil {
-		h.evaluateAchievementsOnQuestCompleted(ctx, userID)
-
-		settings, _ := h.settingsRepo.GetAll()
-		finalMsg := "🎉 Поздравляем! Вы прошли квест!"
-		if settings != nil && settings.FinalMessage != "" {
-			finalMsg = settings.FinalMessage
+		if h.deferCompletion(userID) {
+			h.msgManager.DeletePreviousMessages(ctx, userID)
+			h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+				ChatID:      userID,
+				Text:        completionConfirmationText,
+				ReplyMarkup: completionConfirmationKeyboard(),
+			})
+			return
 		}
 
-		completionStats := h.statsService.FormatCompletionStats(userID)
-		if completionStats != "" {
-			finalMsg = finalMsg + "\n\n" + completionStats
-		}
+		h.sendQuestFinal(ctx, userID, h.evaluateAchievementsOnQuestCompleted(ctx, userID))
+		return
+	}
 
-		stickerPackMsg := h.achievementNotifier.FormatStickerPackMessage(userID)
-		if stickerPackMsg != "" {
-			finalMsg = finalMsg + "\n\n" + stickerPackMsg
+	h.sendStep(ctx, userID, nextStep)
+}
+
+// skipDeactivatedSteps переводит участника дальше, если админ отключил или удалил шаг,
+// на котором он остановился: незавершённый прогресс по такому шагу удаляется, а участник
+// получает следующий активный шаг или, если шагов не осталось, завершает квест с выдачей
+// достижений за прохождение. Возвращает true, если участника перевели и текущее сообщение
+// обрабатывать не нужно
+func (h *BotHandler) skipDeactivatedSteps(ctx context.Context, userID int64, state *services.UserState) bool {
+	if len(state.DeactivatedStepIDs) == 0 {
+		return false
+	}
+	if enabled, err := h.settingsRepo.GetSkipDeactivatedSteps(); err != nil || !enabled {
+		return false
+	}
+
+	for _, stepID := range state.DeactivatedStepIDs {
+		if err := h.progressRepo.DeleteStepProgress(userID, stepID); err != nil {
+			log.Printf("[HANDLER] Error clearing progress of deactivated step %d for user %d: %v", stepID, userID, err)
+			return false
 		}
+	}
+	h.chatStateRepo.ClearAwaitingNextStep(userID)
+	log.Printf("[HANDLER] User %d moved past deactivated steps %v", userID, state.DeactivatedStepIDs)
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: userID,
+		Text:   "⏭ Шаг, на котором вы остановились, больше недоступен — переходим дальше",
+	})
+
+	if !state.IsCompleted {
+		h.sendStep(ctx, userID, state.CurrentStep)
+		return true
+	}
+
+	if h.deferCompletion(userID) {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID:      userID,
+			Text:        completionConfirmationText,
+			ReplyMarkup: completionConfirmationKeyboard(),
+		})
+		return true
+	}
+
+	h.sendQuestFinal(ctx, userID, h.evaluateAchievementsOnQuestCompleted(ctx, userID))
+	return true
+}
+
+// sendQuestFinal отправляет финальное сообщение квеста с достижениями completionAwarded
+// и уведомляет администратора о прохождении
+func (h *BotHandler) sendQuestFinal(ctx context.Context, userID int64, completionAwarded []string) {
+	settings, _ := h.settingsRepo.GetAll()
+	finalMsg := h.questFinalMessage(ctx, userID, settings, completionAwarded)
+
+	h.msgManager.DeletePreviousMessages(ctx, userID)
+	h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
+		ChatID: userID,
+		Text:   finalMsg,
+	}, "5046509860389126442") // 🎉
+
+	h.notifyAdminQuestCompleted(ctx, userID)
+	h.sendAnswerSummary(ctx, userID)
+	h.sendShareMessage(ctx, userID)
+	h.releaseWaitlist(ctx)
+}
+
+// questFinalMessage собирает финальное сообщение квеста: текст из настроек, статистику
+// прохождения, достижения completionAwarded, если они не были отправлены отдельно,
+// и ссылку на стикер-пак
+func (h *BotHandler) questFinalMessage(ctx context.Context, userID int64, settings *models.Settings, completionAwarded []string) string {
+	finalMsg := renderSettingMessage(settings, "final_message", "🎉 Поздравляем! Вы прошли квест!")
+
+	completionStats := h.statsService.FormatCompletionStats(userID)
+	if completionStats != "" {
+		finalMsg = finalMsg + "\n\n" + completionStats
+	}
+
+	if position := h.completionPositionText(userID); position != "" {
+		finalMsg = finalMsg + "\n" + position
+	}
+
+	if h.achievementNotifier == nil {
+		return finalMsg
+	}
+	return h.achievementNotifier.FormatCompletionSummary(ctx, userID, finalMsg, completionAwarded)
+}
+
+// completionPositionText сообщает участнику его место в порядке прохождения квеста, если это
+// включено в настройках. Место видно только самому участнику
+func (h *BotHandler) completionPositionText(userID int64) string {
+	if enabled, err := h.settingsRepo.GetRevealCompletionPosition(); err != nil || !enabled {
+		return ""
+	}
+
+	position, err := h.statsService.GetUserCompletionPosition(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error getting completion position for user %d: %v", userID, err)
+		return ""
+	}
+	if position == 0 {
+		return ""
+	}
+	return fmt.Sprintf("🏁 Ваше место в порядке прохождения квеста: %d", position)
+}
+
+// holdCompletionAchievements сообщает о достижениях за финиш. Если включена настройка
+// «🎁 Итоги финиша одним сообщением», участник получит их в финальном сообщении, и они
+// возвращаются для него; иначе каждое достижение отправляется сразу
+func (h *BotHandler) holdCompletionAchievements(ctx context.Context, userID int64, achievementKeys []string) []string {
+	if len(achievementKeys) == 0 {
+		return nil
+	}
+	if enabled, err := h.settingsRepo.GetGroupCompletionAchievements(); err != nil || !enabled || h.achievementNotifier == nil {
+		h.notifyAchievements(ctx, userID, achievementKeys)
+		return nil
+	}
+	h.notifyAdminUniqueClaims(ctx, userID, achievementKeys)
+	return achievementKeys
+}
+
+// completionConfirmationText — вопрос перед завершением квеста, если включено подтверждение финиша
+const completionConfirmationText = "🏁 Это был последний шаг! Оглянитесь на пройденный путь и подтвердите завершение квеста — после этого результат будет засчитан."
+
+func completionConfirmationKeyboard() tgmodels.InlineKeyboardMarkup {
+	return tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+			{{Text: "🏁 Завершить квест", CallbackData: "confirm_completion"}},
+		},
+	}
+}
+
+// deferCompletion откладывает завершение квеста до подтверждения, если это включено
+// в настройках. Возвращает true, если участник должен подтвердить финиш
+func (h *BotHandler) deferCompletion(userID int64) bool {
+	confirm, err := h.settingsRepo.GetCompletionConfirmation()
+	if err != nil || !confirm {
+		return false
+	}
+	if err := h.userRepo.SetPendingCompletion(userID); err != nil {
+		log.Printf("[HANDLER] Error deferring completion for user %d: %v", userID, err)
+		return false
+	}
+	return true
+}
+
+func (h *BotHandler) isCompletionPending(userID int64) bool {
+	pending, err := h.userRepo.IsPendingCompletion(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error checking pending completion for user %d: %v", userID, err)
+		return false
+	}
+	return pending
+}
+
+// handleConfirmCompletionCallback завершает квест по кнопке подтверждения: только здесь
+// выдаются достижения за прохождение и места и отправляется финальное сообщение
+func (h *BotHandler) handleConfirmCompletionCallback(ctx context.Context, callback *tgmodels.CallbackQuery) {
+	userID := callback.From.ID
+	h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+	})
+
+	var confirmed bool
+	var awarded []string
+	var err error
+	if h.achievementEngine != nil {
+		confirmed, awarded, err = h.achievementEngine.ConfirmCompletion(userID)
+	} else {
+		confirmed, err = h.userRepo.ClearPendingCompletion(userID)
+	}
+	if err != nil {
+		log.Printf("[HANDLER] Error confirming completion for user %d: %v", userID, err)
+	}
+	if !confirmed {
+		return
+	}
+
+	h.bot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:    userID,
+		MessageID: callback.Message.Message.ID,
+	})
+
+	completionAwarded := h.holdCompletionAchievements(ctx, userID, awarded)
+	h.qualifyReferral(ctx, userID)
+	h.announceProgress(ctx, userID, true)
+	h.sendQuestFinal(ctx, userID, completionAwarded)
+}
+
+// checkStartCapacity не даёт начать квест, если достигнут лимит одновременно проходящих
+// участников, и ставит пользователя в лист ожидания
+func (h *BotHandler) checkStartCapacity(ctx context.Context, userID int64) bool {
+	limit, err := h.settingsRepo.GetMaxActiveUsers()
+	if err != nil || limit <= 0 {
+		return true
+	}
+
+	canStart, err := h.userManager.CanStart(userID, limit)
+	if err != nil {
+		log.Printf("[HANDLER] Error checking start capacity for user %d: %v", userID, err)
+		return true
+	}
+	if canStart {
+		h.userRepo.RemoveFromWaitlist(userID)
+		return true
+	}
+
+	if err := h.userManager.JoinWaitlist(userID); err != nil {
+		log.Printf("[HANDLER] Error adding user %d to waitlist: %v", userID, err)
+	}
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: userID,
+		Text:   "⏳ Сейчас квест проходит максимальное число участников. Вы в листе ожидания — мы напишем, как только освободится место",
+	})
+	return false
+}
+
+// releaseWaitlist приглашает ожидающих пользователей, когда кто-то завершил квест и освободил место
+func (h *BotHandler) releaseWaitlist(ctx context.Context) {
+	limit, err := h.settingsRepo.GetMaxActiveUsers()
+	if err != nil || limit <= 0 {
+		return
+	}
+
+	userIDs, err := h.userManager.ReleaseWaitlist(limit)
+	if err != nil {
+		log.Printf("[HANDLER] Error releasing waitlist: %v", err)
+		return
+	}
+	for _, userID := range userIDs {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: userID,
+			Text:   "🎉 Освободилось место! Отправьте /start, чтобы начать квест",
+		})
+	}
+}
+
+func (h *BotHandler) notifyAdminQuestCompleted(ctx context.Context, userID int64) {
+	user, _ := h.userRepo.GetByID(userID)
+	displayName := fmt.Sprintf("[%d]", userID)
+	if user != nil {
+		displayName = user.DisplayName()
+	}
+
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: h.adminID,
+		Text:   fmt.Sprintf("🏆 Пользователь %s завершил квест!", html.EscapeString(displayName)),
+	})
+}
+
+// sendAnswerSummary отправляет участнику разбор ответов, если он включён в настройках.
+// BuildAnswerSummary возвращает пустой разбор для не завершивших квест
+func (h *BotHandler) sendAnswerSummary(ctx context.Context, userID int64) {
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil || !settings.AnswerSummaryEnabled {
+		return
+	}
+
+	items, err := h.statsService.BuildAnswerSummary(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error building answer summary for user %d: %v", userID, err)
+		return
+	}
 
-		h.msgManager.DeletePreviousMessages(ctx, userID)
-		h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
+	if summary := services.FormatAnswerSummary(items); summary != "" {
+		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
 			ChatID: userID,
-			Text:   finalMsg,
-		}, "5046509860389126442") // 🎉
+			Text:   summary,
+		})
+	}
+}
 
-		h.notifyAdminQuestCompleted(ctx, userID)
+// sendShareMessage отправляет участнику, прошедшему квест, текст «поделиться» с его
+// ссылкой-приглашением и кнопкой пересылки в другой чат
+func (h *BotHandler) sendShareMessage(ctx context.Context, userID int64) {
+	if h.referralService == nil {
 		return
 	}
 
-	h.sendStep(ctx, userID, nextStep)
-}
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil || !settings.ShareEnabled {
+		return
+	}
 
-func (h *BotHandler) notifyAdminQuestCompleted(ctx context.Context, userID int64) {
-	user, _ := h.userRepo.GetByID(userID)
-	displayName := fmt.Sprintf("[%d]", userID)
-	if user != nil {
-		displayName = user.DisplayName()
+	template := settings.Message("share_message")
+	if template == "" {
+		template = services.DefaultShareMessage
 	}
+	text := renderSettingMessage(settings, "share_message", services.DefaultShareMessage)
+	link := h.referralService.InviteLink(userID)
 
 	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
-		ChatID: h.adminID,
-		Text:   fmt.Sprintf("🏆 Пользователь %s завершил квест!", html.EscapeString(displayName)),
+		ChatID: userID,
+		Text:   services.FormatShareMessage(text, link),
+		ReplyMarkup: &tgmodels.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgmodels.InlineKeyboardButton{
+				{{Text: "📤 Поделиться", URL: h.referralService.ShareURL(userID, template)}},
+			},
+		},
 	})
 }
 
+// qualifyReferral засчитывает приглашение, когда приглашённый участник прошёл шаг,
+// и проверяет достижение «Вербовщик» у пригласившего
+func (h *BotHandler) qualifyReferral(ctx context.Context, userID int64) {
+	if h.referralService == nil || h.achievementEngine == nil {
+		return
+	}
+
+	referrerID, err := h.referralService.QualifyReferral(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error qualifying referral for user %d: %v", userID, err)
+		return
+	}
+	if referrerID == 0 {
+		return
+	}
+
+	awarded, err := h.achievementEngine.CheckRecruiterAchievement(referrerID)
+	if err != nil {
+		log.Printf("[HANDLER] Error evaluating recruiter achievement for user %d: %v", referrerID, err)
+		return
+	}
+	h.notifyAchievements(ctx, referrerID, awarded)
+}
+
 func (h *BotHandler) sendError(ctx context.Context, chatID int64, text string) {
 	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
 		ChatID: chatID,
@@ -818,166 +2641,379 @@ func (h *BotHandler) handleVerifyMembershipCallback(ctx context.Context, callbac
 		})
 	}
 
-	h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
-		CallbackQueryID: callback.ID,
-		Text:            "✅ Проверка пройдена!",
-	})
+	h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "✅ Проверка пройдена!",
+	})
+
+	h.continueStart(ctx, callback.Message.Message.Chat.ID, userID)
+}
+
+func (h *BotHandler) handleImageAnswer(ctx context.Context, msg *tgmodels.Message) {
+	userID := msg.From.ID
+
+	state, err := h.stateResolver.ResolveState(userID)
+	if err != nil {
+		return
+	}
+
+	if h.skipDeactivatedSteps(ctx, userID, state) {
+		return
+	}
+
+	if state.IsCompleted {
+		h.evaluateAchievementsOnPostCompletion(ctx, userID)
+		h.forwardMessageToAdmin(ctx, msg, nil, "после завершения квеста")
+		return
+	}
+
+	if state.CurrentStep == nil {
+		return
+	}
+
+	step := state.CurrentStep
+
+	chatState, _ := h.chatStateRepo.Get(userID)
+	progress, _ := h.progressRepo.GetByUserAndStep(userID, step.ID)
+
+	if chatState != nil && chatState.AwaitingNextStep && (progress == nil || progress.Status == models.StatusPending) {
+		log.Printf("[HANDLER] User %d sent image while awaiting next step, moving to next step", userID)
+
+		if h.achievementEngine != nil {
+			awarded, err := h.achievementEngine.OnMessageToAdmin(userID)
+			if err != nil {
+				log.Printf("[HANDLER] Error awarding message to admin achievement: %v", err)
+			} else if len(awarded) > 0 {
+				h.notifyAchievements(ctx, userID, awarded)
+			}
+		}
+
+		prevStep, err := h.stepRepo.GetPreviousActive(step.StepOrder)
+		if err == nil && prevStep != nil {
+			h.forwardMessageToAdmin(ctx, msg, prevStep, "после правильного ответа")
+		} else {
+			h.forwardMessageToAdmin(ctx, msg, step, "после правильного ответа")
+		}
+
+		h.msgManager.DeletePreviousMessages(ctx, userID)
+		h.chatStateRepo.ClearAwaitingNextStep(userID)
+		h.sendStep(ctx, userID, step)
+		return
+	}
+
+	if h.rejectLockedStep(ctx, userID, msg.ID, step) {
+		return
+	}
+
+	if step.AnswerType == models.AnswerTypeLocation {
+		h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+		h.msgManager.SendReaction(ctx, userID, "📍 Для этого задания нужно отправить геопозицию")
+		return
+	}
+
+	if step.AnswerType == models.AnswerTypeChoice {
+		h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+		h.msgManager.SendReaction(ctx, userID, choiceAnswerReaction)
+		return
+	}
+
+	if step.AnswerType == models.AnswerTypeLink {
+		if msg.Caption != "" {
+			if !h.rejectNonReply(ctx, msg, step) {
+				h.handleLinkAnswer(ctx, msg, step)
+			}
+			return
+		}
+		h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+		h.msgManager.SendReaction(ctx, userID, linkAnswerReaction)
+		return
+	}
+
+	isTextTask := step.AnswerType == models.AnswerTypeText
+	if isTextTask {
+		h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+		h.msgManager.SendReaction(ctx, userID, "📝 Для этого задания нужно отправить текст")
+		h.evaluateAchievementsOnPhotoSubmitted(ctx, userID, true, msg, step)
+		return
+	}
+
+	if h.rejectNonReply(ctx, msg, step) {
+		return
+	}
+
+	if h.rejectImageConstraints(ctx, msg, step) {
+		return
+	}
+
+	h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+	h.msgManager.CleanupHintMessage(ctx, userID)
+
+	var fileID string
+	if len(msg.Photo) > 0 {
+		fileID = msg.Photo[len(msg.Photo)-1].FileID
+	}
+
+	chatState, err = h.chatStateRepo.Get(userID)
+	hintUsed := false
+	if err == nil && chatState != nil {
+		hintUsed = chatState.CurrentStepHintUsed
+	}
+
+	h.answerRepo.CreateImageAnswer(userID, step.ID, []string{fileID}, hintUsed)
+
+	if hintUsed {
+		h.chatStateRepo.ResetHintUsed(userID)
+	}
+
+	progress, _ = h.progressRepo.GetByUserAndStep(userID, step.ID)
+	if progress == nil {
+		h.progressRepo.Create(&models.UserProgress{
+			UserID: userID,
+			StepID: step.ID,
+			Status: models.StatusWaitingReview,
+		})
+	} else {
+		h.progressRepo.Update(&models.UserProgress{
+			UserID: userID,
+			StepID: step.ID,
+			Status: models.StatusWaitingReview,
+		})
+	}
+
+	h.sendToAdminForReview(ctx, userID, step, "", []string{fileID})
+
+	h.evaluateAchievementsOnPhotoSubmitted(ctx, userID, false, msg, step)
+
+	h.msgManager.SendReaction(ctx, userID, "⏳ <b>Ваше фото отправлено на проверку, подождите пока его одобрят...</b>")
+}
+
+func (h *BotHandler) handleLocationAnswer(ctx context.Context, msg *tgmodels.Message) {
+	userID := msg.From.ID
+
+	state, err := h.stateResolver.ResolveState(userID)
+	if err != nil {
+		return
+	}
+
+	if h.skipDeactivatedSteps(ctx, userID, state) {
+		return
+	}
+
+	if state.IsCompleted {
+		h.forwardMessageToAdmin(ctx, msg, nil, "после завершения квеста")
+		return
+	}
+
+	if state.CurrentStep == nil {
+		return
+	}
+
+	step := state.CurrentStep
+
+	chatState, _ := h.chatStateRepo.Get(userID)
+	progress, _ := h.progressRepo.GetByUserAndStep(userID, step.ID)
+
+	if chatState != nil && chatState.AwaitingNextStep && (progress == nil || progress.Status == models.StatusPending) {
+		h.forwardMessageToAdmin(ctx, msg, step, "после правильного ответа")
+		h.msgManager.DeletePreviousMessages(ctx, userID)
+		h.chatStateRepo.ClearAwaitingNextStep(userID)
+		h.sendStep(ctx, userID, step)
+		return
+	}
+
+	if h.rejectLockedStep(ctx, userID, msg.ID, step) {
+		return
+	}
+
+	if step.AnswerType != models.AnswerTypeLocation {
+		h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+		if step.AnswerType == models.AnswerTypeImage {
+			h.msgManager.SendReaction(ctx, userID, "📷 Для этого задания нужно отправить фото")
+		} else if step.AnswerType == models.AnswerTypeChoice {
+			h.msgManager.SendReaction(ctx, userID, choiceAnswerReaction)
+		} else if step.AnswerType == models.AnswerTypeLink {
+			h.msgManager.SendReaction(ctx, userID, linkAnswerReaction)
+		} else {
+			h.msgManager.SendReaction(ctx, userID, "📝 Для этого задания нужно отправить текст")
+		}
+		return
+	}
+
+	if h.rejectNonReply(ctx, msg, step) {
+		return
+	}
+
+	h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+	h.msgManager.CleanupHintMessage(ctx, userID)
+
+	hintUsed := chatState != nil && chatState.CurrentStepHintUsed
+	answerText := fmt.Sprintf("%.6f, %.6f", msg.Location.Latitude, msg.Location.Longitude)
+	answerID, _ := h.answerRepo.CreateTextAnswer(userID, step.ID, answerText, hintUsed)
+
+	if hintUsed {
+		h.chatStateRepo.ResetHintUsed(userID)
+	}
+
+	if step.HasAutoCheck && step.LocationRadius > 0 {
+		result, err := h.answerChecker.CheckLocationAnswer(step, msg.Location.Latitude, msg.Location.Longitude)
+		if err != nil {
+			h.sendError(ctx, msg.Chat.ID, "Ошибка при проверке ответа")
+			return
+		}
+		h.recordMatchRule(answerID, result)
+		metrics.Default.AnswersChecked.Inc()
+
+		if result.IsCorrect {
+			h.handleCorrectAnswer(ctx, userID, step, result.Percentage, "")
+			return
+		}
+
+		h.msgManager.DeleteUserAnswerAndReaction(ctx, userID)
+		settings, _ := h.settingsRepo.GetAll()
+		wrongMsg := renderSettingMessage(settings, "wrong_answer_message", "❌ Неверно, попробуйте ещё раз")
+		h.msgManager.SendReactionWithEffect(ctx, userID, wrongMsg, "5104858069142078462") // 👎
+		return
+	}
+
+	if progress == nil {
+		h.progressRepo.Create(&models.UserProgress{
+			UserID: userID,
+			StepID: step.ID,
+			Status: models.StatusWaitingReview,
+		})
+	} else {
+		h.progressRepo.Update(&models.UserProgress{
+			UserID: userID,
+			StepID: step.ID,
+			Status: models.StatusWaitingReview,
+		})
+	}
+
+	h.sendToAdminForReview(ctx, userID, step, "📍 "+answerText, nil)
+	h.msgManager.SendReaction(ctx, userID, "⏳ <b>Ваш ответ отправлен на проверку, подождите пока его одобрят...</b>")
+}
+
+const choiceAnswerReaction = "🔘 Для этого задания нужно выбрать вариант кнопкой под заданием"
+
+const linkAnswerReaction = "🔗 Для этого задания нужно отправить ссылку"
+
+// handleLinkAnswer проверяет ответ на шаге со ссылкой. Ссылки берутся из сущностей
+// текста или подписи к фото, поэтому простой текст без распознанного адреса не
+// засчитывается. Подходящая ссылка одобряется сразу, без ручной проверки
+func (h *BotHandler) handleLinkAnswer(ctx context.Context, msg *tgmodels.Message, step *models.Step) {
+	userID := msg.From.ID
+	h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
+
+	links := append(services.MessageLinks(msg.Text, msg.Entities), services.MessageLinks(msg.Caption, msg.CaptionEntities)...)
+	if len(links) == 0 {
+		h.msgManager.SendReaction(ctx, userID, linkAnswerReaction)
+		return
+	}
+
+	h.msgManager.CleanupHintMessage(ctx, userID)
 
-	user, _ := h.userRepo.GetByID(userID)
-	if user == nil {
-		user = &models.User{ID: userID}
+	chatState, _ := h.chatStateRepo.Get(userID)
+	hintUsed := chatState != nil && chatState.CurrentStepHintUsed
+	answerText := msg.Text
+	if answerText == "" {
+		answerText = msg.Caption
 	}
+	answerID, _ := h.answerRepo.CreateTextAnswer(userID, step.ID, answerText, hintUsed)
 
-	shouldProcess, notification := h.questStateMiddleware.ShouldProcessMessage(user.ID)
-	if !shouldProcess {
-		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
-			ChatID: callback.Message.Message.Chat.ID,
-			Text:   notification,
-		})
-		return
+	if hintUsed {
+		h.chatStateRepo.ResetHintUsed(userID)
 	}
 
-	state, err := h.stateResolver.ResolveState(user.ID)
+	result, err := h.answerChecker.CheckLinkAnswer(step, links)
 	if err != nil {
-		h.sendError(ctx, callback.Message.Message.Chat.ID, fmt.Sprintf("Ошибка при определении состояния: %v", err))
+		h.sendError(ctx, msg.Chat.ID, "Ошибка при проверке ответа")
 		return
 	}
+	h.recordMatchRule(answerID, result)
+	metrics.Default.AnswersChecked.Inc()
 
-	if state.IsCompleted {
-		settings, _ := h.settingsRepo.GetAll()
-		finalMsg := "Поздравляем! Вы прошли квест!"
-		if settings != nil && settings.FinalMessage != "" {
-			finalMsg = settings.FinalMessage
-		}
+	if result.IsCorrect {
+		h.handleCorrectAnswer(ctx, userID, step, result.Percentage, "")
+		return
+	}
 
-		completionStats := h.statsService.FormatCompletionStats(user.ID)
-		if completionStats != "" {
-			finalMsg = finalMsg + "\n\n" + completionStats
-		}
+	h.msgManager.DeleteUserAnswerAndReaction(ctx, userID)
+	h.msgManager.SendReactionWithEffect(ctx, userID, fmt.Sprintf("🔗 Нужна ссылка на %s", step.LinkDomain), "5104858069142078462") // 👎
+}
 
-		stickerPackMsg := h.achievementNotifier.FormatStickerPackMessage(user.ID)
-		if stickerPackMsg != "" {
-			finalMsg = finalMsg + "\n\n" + stickerPackMsg
-		}
+// handleChoiceCallback проверяет вариант, выбранный кнопкой на шаге с выбором ответа
+func (h *BotHandler) handleChoiceCallback(ctx context.Context, callback *tgmodels.CallbackQuery) {
+	h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+	})
 
-		h.msgManager.SendWithRetryAndEffect(ctx, &bot.SendMessageParams{
-			ChatID: callback.Message.Message.Chat.ID,
-			Text:   finalMsg,
-		}, "5046509860389126442")
+	parts := strings.Split(callback.Data, ":")
+	if len(parts) != 3 {
 		return
 	}
 
-	if state.Status == models.StatusPending || state.Status == "" {
-		settings, _ := h.settingsRepo.GetAll()
-		welcomeMsg := "Добро пожаловать в квест!"
-		if settings != nil && settings.WelcomeMessage != "" {
-			welcomeMsg = settings.WelcomeMessage
-		}
-		h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
-			ChatID: callback.Message.Message.Chat.ID,
-			Text:   welcomeMsg,
-		})
+	stepID, _ := parseInt64(parts[1])
+	choiceID, _ := parseInt64(parts[2])
+	if stepID == 0 || choiceID == 0 {
+		return
 	}
 
-	h.sendStep(ctx, user.ID, state.CurrentStep)
-}
-
-func (h *BotHandler) handleImageAnswer(ctx context.Context, msg *tgmodels.Message) {
-	userID := msg.From.ID
-
-	state, err := h.stateResolver.ResolveState(userID)
-	if err != nil {
+	userID := callback.From.ID
+	if shouldProcess, _ := h.questStateMiddleware.ShouldProcessMessage(userID); !shouldProcess {
 		return
 	}
-
-	if state.IsCompleted {
-		h.evaluateAchievementsOnPostCompletion(ctx, userID)
-		h.forwardMessageToAdmin(ctx, msg, nil, "после завершения квеста")
+	if h.isUserBlocked(userID) {
 		return
 	}
 
-	if state.CurrentStep == nil {
+	state, err := h.stateResolver.ResolveState(userID)
+	if err != nil || h.skipDeactivatedSteps(ctx, userID, state) {
+		return
+	}
+	if state.IsCompleted || state.CurrentStep == nil || state.CurrentStep.ID != stepID {
 		return
 	}
 
 	step := state.CurrentStep
+	choice := step.Choice(choiceID)
+	if choice == nil {
+		return
+	}
 
 	chatState, _ := h.chatStateRepo.Get(userID)
-	progress, _ := h.progressRepo.GetByUserAndStep(userID, step.ID)
-
-	if chatState != nil && chatState.AwaitingNextStep && (progress == nil || progress.Status == models.StatusPending) {
-		log.Printf("[HANDLER] User %d sent image while awaiting next step, moving to next step", userID)
-
-		if h.achievementEngine != nil {
-			awarded, err := h.achievementEngine.OnMessageToAdmin(userID)
-			if err != nil {
-				log.Printf("[HANDLER] Error awarding message to admin achievement: %v", err)
-			} else if len(awarded) > 0 {
-				h.notifyAchievements(ctx, userID, awarded)
-			}
-		}
-
-		prevStep, err := h.stepRepo.GetPreviousActive(step.StepOrder)
-		if err == nil && prevStep != nil {
-			h.forwardMessageToAdmin(ctx, msg, prevStep, "после правильного ответа")
-		} else {
-			h.forwardMessageToAdmin(ctx, msg, step, "после правильного ответа")
-		}
-
-		h.msgManager.DeletePreviousMessages(ctx, userID)
-		h.chatStateRepo.ClearAwaitingNextStep(userID)
-		h.sendStep(ctx, userID, step)
+	if chatState != nil && chatState.AwaitingNextStep {
 		return
 	}
-
-	isTextTask := step.AnswerType == models.AnswerTypeText
-	if isTextTask {
-		h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
-		h.msgManager.SendReaction(ctx, userID, "📝 Для этого задания нужно отправить текст")
-		h.evaluateAchievementsOnPhotoSubmitted(ctx, userID, true, msg, step)
+	if h.isStepLocked(userID, step) {
 		return
 	}
 
-	h.msgManager.SaveUserAnswerMessageID(userID, msg.ID)
 	h.msgManager.CleanupHintMessage(ctx, userID)
 
-	var fileID string
-	if len(msg.Photo) > 0 {
-		fileID = msg.Photo[len(msg.Photo)-1].FileID
-	}
-
-	chatState, err = h.chatStateRepo.Get(userID)
-	hintUsed := false
-	if err == nil && chatState != nil {
-		hintUsed = chatState.CurrentStepHintUsed
-	}
-
-	h.answerRepo.CreateImageAnswer(userID, step.ID, []string{fileID}, hintUsed)
+	hintUsed := chatState != nil && chatState.CurrentStepHintUsed
+	answerID, _ := h.answerRepo.CreateTextAnswer(userID, step.ID, choice.Text, hintUsed)
 
 	if hintUsed {
 		h.chatStateRepo.ResetHintUsed(userID)
 	}
 
-	progress, _ = h.progressRepo.GetByUserAndStep(userID, step.ID)
-	if progress == nil {
-		h.progressRepo.Create(&models.UserProgress{
-			UserID: userID,
-			StepID: step.ID,
-			Status: models.StatusWaitingReview,
-		})
-	} else {
-		h.progressRepo.Update(&models.UserProgress{
-			UserID: userID,
-			StepID: step.ID,
-			Status: models.StatusWaitingReview,
-		})
+	result, err := h.answerChecker.CheckChoiceAnswer(step, choiceID)
+	if err != nil {
+		h.sendError(ctx, userID, "Ошибка при проверке ответа")
+		return
 	}
+	h.recordMatchRule(answerID, result)
+	metrics.Default.AnswersChecked.Inc()
 
-	h.sendToAdminForReview(ctx, userID, step, "", []string{fileID})
-
-	h.evaluateAchievementsOnPhotoSubmitted(ctx, userID, false, msg, step)
+	if result.IsCorrect {
+		h.handleCorrectAnswer(ctx, userID, step, result.Percentage, "")
+		return
+	}
 
-	h.msgManager.SendReaction(ctx, userID, "⏳ <b>Ваше фото отправлено на проверку, подождите пока его одобрят...</b>")
+	h.msgManager.DeleteUserAnswerAndReaction(ctx, userID)
+	settings, _ := h.settingsRepo.GetAll()
+	wrongMsg := renderSettingMessage(settings, "wrong_answer_message", "❌ Неверно, попробуйте ещё раз")
+	h.msgManager.SendReactionWithEffect(ctx, userID, wrongMsg, "5104858069142078462") // 👎
 }
 
 func (h *BotHandler) handleAdminDecision(ctx context.Context, callback *tgmodels.CallbackQuery) {
@@ -1029,6 +3065,14 @@ func (h *BotHandler) handleAdminDecision(ctx context.Context, callback *tgmodels
 
 	switch action {
 	case "approve":
+		if progress.Status == models.StatusApproved {
+			// Повторное одобрение не должно заново отправлять картинку правильного ответа
+			h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+				CallbackQueryID: callback.ID,
+				Text:            "Ответ уже одобрен",
+			})
+			return
+		}
 		progress.Status = models.StatusApproved
 		if err := h.progressRepo.Update(progress); err != nil {
 			return
@@ -1058,7 +3102,7 @@ func (h *BotHandler) handleAdminDecision(ctx context.Context, callback *tgmodels
 		percentage, _ := h.answerChecker.CheckTextAnswer(stepID, userAnswer)
 		log.Printf("[CALLBACK] percentage=%d", percentage.Percentage)
 
-		h.handleCorrectAnswer(ctx, userID, step, percentage.Percentage, userAnswer)
+		h.handleCorrectAnswer(ctx, userID, step, percentage.Percentage, "")
 	case "reject":
 		progress.Status = models.StatusRejected
 		if err := h.progressRepo.Update(progress); err != nil {
@@ -1074,10 +3118,7 @@ func (h *BotHandler) handleAdminDecision(ctx context.Context, callback *tgmodels
 
 		h.msgManager.DeleteUserAnswerAndReaction(ctx, userID)
 		settings, _ := h.settingsRepo.GetAll()
-		wrongMsg := "❌ Неверно, попробуйте ещё раз"
-		if settings != nil && settings.WrongAnswerMessage != "" {
-			wrongMsg = settings.WrongAnswerMessage
-		}
+		wrongMsg := renderSettingMessage(settings, "wrong_answer_message", "❌ Неверно, попробуйте ещё раз")
 
 		wrongEffects := []string{
 			"5104858069142078462", // 👎
@@ -1195,7 +3236,7 @@ func (h *BotHandler) appendToCallbackMessage(ctx context.Context, callback *tgmo
 			ParseMode: tgmodels.ParseModeHTML,
 		})
 		if isMessageNotFoundError(err) {
-			h.bot.SendPhoto(ctx, &bot.SendPhotoParams{
+			h.msgManager.SendPhotoWithRetry(ctx, &bot.SendPhotoParams{
 				ChatID:    msg.Chat.ID,
 				Photo:     &tgmodels.InputFileString{Data: msg.Photo[len(msg.Photo)-1].FileID},
 				Caption:   newCaption,
@@ -1231,6 +3272,29 @@ func isMessageNotFoundError(err error) bool {
 		strings.Contains(errStr, "MESSAGE_ID_INVALID")
 }
 
+// FormatPartialSetAnswer сообщает, какие элементы перечисления уже приняты и сколько ещё не хватает
+func FormatPartialSetAnswer(matched []string, missing int) string {
+	escaped := make([]string, len(matched))
+	for i, item := range matched {
+		escaped[i] = html.EscapeString(item)
+	}
+	return fmt.Sprintf("🧩 Засчитано: %s\n\nНе хватает ещё: %d. Отправьте полный ответ через запятую", strings.Join(escaped, ", "), missing)
+}
+
+// FormatSequenceProgress сообщает участнику, сколько элементов последовательности уже засчитано
+func FormatSequenceProgress(position, length int) string {
+	return fmt.Sprintf("✅ Верно! Засчитано %d из %d, отправьте следующий элемент", position, length)
+}
+
+// FormatSequenceReset сообщает, что неверный элемент сбросил последовательность. Если
+// неверный элемент оказался первым элементом последовательности, он уже засчитан заново
+func FormatSequenceReset(position, length int) string {
+	if position > 0 {
+		return fmt.Sprintf("🔁 Порядок нарушен, последовательность начата заново. Засчитано %d из %d", position, length)
+	}
+	return "🔁 Порядок нарушен, последовательность начата заново. Отправьте первый элемент"
+}
+
 func parseInt64(s string) (int64, error) {
 	var result int64
 	_, err := fmt.Sscanf(s, "%d", &result)
@@ -1263,6 +3327,9 @@ func (h *BotHandler) sendToAdminForReview(ctx context.Context, userID int64, ste
 				{Text: "✅ Правильно", CallbackData: fmt.Sprintf("approve:%d:%d", userID, step.ID)},
 				{Text: "❌ Ошибка", CallbackData: fmt.Sprintf("reject:%d:%d", userID, step.ID)},
 			},
+			{
+				{Text: "✏️ Ошибка с причиной", CallbackData: fmt.Sprintf("admin:reject_reason:%d:%d", userID, step.ID)},
+			},
 			{
 				{Text: "🚫 Заблокировать", CallbackData: fmt.Sprintf("block:%d", userID)},
 			},
@@ -1276,7 +3343,7 @@ func (h *BotHandler) sendToAdminForReview(ctx context.Context, userID int64, ste
 			ReplyMarkup: keyboard,
 		})
 	} else if len(imageFileIDs) > 0 {
-		h.bot.SendPhoto(ctx, &bot.SendPhotoParams{
+		h.msgManager.SendPhotoWithRetry(ctx, &bot.SendPhotoParams{
 			ChatID:      h.adminID,
 			Photo:       &tgmodels.InputFileString{Data: imageFileIDs[0]},
 			Caption:     caption,
@@ -1299,10 +3366,8 @@ func (h *BotHandler) handleNextStepCallback(ctx context.Context, callback *tgmod
 		})
 	}
 
-	h.chatStateRepo.ClearAwaitingNextStep(callback.From.ID)
-
 	currentOrder, _ := parseInt64(parts[1])
-	h.moveToNextStep(ctx, callback.From.ID, int(currentOrder))
+	h.advanceToNextStep(ctx, callback.From.ID, int(currentOrder))
 
 	h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: callback.ID,
@@ -1327,6 +3392,14 @@ func (h *BotHandler) handleHintCallback(ctx context.Context, callback *tgmodels.
 		return
 	}
 
+	if remaining := h.hintCooldownLeft(userID, stepID); remaining > 0 {
+		h.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: callback.ID,
+			Text:            formatHintCooldown(remaining),
+		})
+		return
+	}
+
 	hintMsgID, err := h.sendHintMessage(ctx, userID, step)
 	if err != nil {
 		return
@@ -1334,6 +3407,7 @@ func (h *BotHandler) handleHintCallback(ctx context.Context, callback *tgmodels.
 
 	h.chatStateRepo.UpdateHintMessageID(userID, hintMsgID)
 	h.chatStateRepo.SetHintUsed(userID, true)
+	h.progressRepo.SetLastHintAt(userID, stepID, time.Now())
 
 	h.removeHintButton(ctx, userID, callback.Message.Message.ID)
 
@@ -1394,6 +3468,18 @@ func (h *BotHandler) handleSkipStepCallback(ctx context.Context, callback *tgmod
 	})
 }
 
+// handleHintCommand показывает подсказку к текущему шагу по команде /hint — так же,
+// как кнопка «💡 Подсказка» и сообщение «Подсказка»
+func (h *BotHandler) handleHintCommand(ctx context.Context, msg *tgmodels.Message) {
+	if h.handleHintByText(ctx, msg.From.ID) {
+		return
+	}
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text:   "💡 К этому шагу нет подсказки или она уже показана",
+	})
+}
+
 func (h *BotHandler) handleHintByText(ctx context.Context, userID int64) bool {
 	state, err := h.stateResolver.ResolveState(userID)
 	if err != nil || state.IsCompleted || state.CurrentStep == nil {
@@ -1405,6 +3491,13 @@ func (h *BotHandler) handleHintByText(ctx context.Context, userID int64) bool {
 		return false
 	}
 
+	// Ожидание проверяется раньше использованной подсказки: повторный запрос
+	// во время ожидания получает оставшееся время, а не общий отказ
+	if remaining := h.hintCooldownLeft(userID, step.ID); remaining > 0 {
+		h.msgManager.SendReaction(ctx, userID, formatHintCooldown(remaining))
+		return true
+	}
+
 	chatState, err := h.chatStateRepo.Get(userID)
 	if err == nil && chatState != nil && chatState.CurrentStepHintUsed {
 		return false
@@ -1417,6 +3510,7 @@ func (h *BotHandler) handleHintByText(ctx context.Context, userID int64) bool {
 
 	h.chatStateRepo.UpdateHintMessageID(userID, hintMsgID)
 	h.chatStateRepo.SetHintUsed(userID, true)
+	h.progressRepo.SetLastHintAt(userID, step.ID, time.Now())
 
 	if chatState != nil && chatState.LastTaskMessageID != 0 {
 		h.removeHintButton(ctx, userID, chatState.LastTaskMessageID)
@@ -1426,6 +3520,36 @@ func (h *BotHandler) handleHintByText(ctx context.Context, userID int64) bool {
 	return true
 }
 
+// hintCooldownLeft возвращает, сколько участнику ещё ждать до следующей подсказки на шаге
+func (h *BotHandler) hintCooldownLeft(userID, stepID int64) time.Duration {
+	seconds, _ := h.settingsRepo.GetHintCooldown()
+	if seconds <= 0 {
+		return 0
+	}
+	lastHintAt, err := h.progressRepo.GetLastHintAt(userID, stepID)
+	if err != nil {
+		return 0
+	}
+	return hintCooldownRemaining(lastHintAt, time.Duration(seconds)*time.Second, time.Now())
+}
+
+// hintCooldownRemaining считает остаток паузы между подсказками. Нулевой lastHintAt означает,
+// что подсказку на шаге ещё не запрашивали
+func hintCooldownRemaining(lastHintAt time.Time, cooldown time.Duration, now time.Time) time.Duration {
+	if lastHintAt.IsZero() || cooldown <= 0 {
+		return 0
+	}
+	remaining := lastHintAt.Add(cooldown).Sub(now)
+	if remaining <= 0 {
+		return 0
+	}
+	return remaining.Round(time.Second)
+}
+
+func formatHintCooldown(remaining time.Duration) string {
+	return "⏳ Следующая подсказка будет доступна через " + services.FormatDurationRussian(max(remaining, time.Second))
+}
+
 func (h *BotHandler) handleSkipByText(ctx context.Context, userID int64) bool {
 	state, err := h.stateResolver.ResolveState(userID)
 	if err != nil || state.IsCompleted || state.CurrentStep == nil {
@@ -1453,7 +3577,7 @@ func (h *BotHandler) sendHintMessage(ctx context.Context, userID int64, step *mo
 	}
 
 	if step.HintImage != "" {
-		msg, err := h.bot.SendPhoto(ctx, &bot.SendPhotoParams{
+		msg, err := h.msgManager.TrySendPhoto(ctx, &bot.SendPhotoParams{
 			ChatID:    userID,
 			Photo:     &tgmodels.InputFileString{Data: step.HintImage},
 			Caption:   hintText,
@@ -1491,77 +3615,132 @@ func (h *BotHandler) removeHintButton(ctx context.Context, userID int64, message
 	})
 }
 
-func (h *BotHandler) evaluateAchievementsOnCorrectAnswer(ctx context.Context, userID int64, stepID int64) {
-	if h.achievementEngine == nil {
+// AutoApproveStaleReviews одобряет ответы, которые ждут ручной проверки дольше настроенного времени,
+// и уведомляет пользователей так же, как при одобрении администратором
+func (h *BotHandler) AutoApproveStaleReviews(ctx context.Context) {
+	approver := services.NewReviewAutoApprover(h.progressRepo, h.stepRepo, h.settingsRepo, h.achievementEngine)
+	approver.SetStateResolver(h.stateResolver)
+	approvals, err := approver.ApproveStale(time.Now())
+	if err != nil {
+		log.Printf("[AUTO_APPROVE] Error approving stale reviews: %v", err)
+		return
+	}
+	if len(approvals) == 0 {
 		return
 	}
 
-	var allAwarded []string
+	for _, approval := range approvals {
+		userID := approval.Review.UserID
 
-	progressAwarded, err := h.achievementEngine.EvaluateProgressAchievements(userID)
-	if err != nil {
-		log.Printf("[HANDLER] Error evaluating progress achievements: %v", err)
-	} else {
-		allAwarded = append(allAwarded, progressAwarded...)
+		state, _ := h.chatStateRepo.Get(userID)
+		if state != nil && state.LastTaskMessageID != 0 {
+			h.msgManager.DeleteMessage(ctx, userID, state.LastTaskMessageID)
+			h.chatStateRepo.Save(&models.ChatState{
+				UserID:                  userID,
+				LastTaskMessageID:       0,
+				LastUserAnswerMessageID: state.LastUserAnswerMessageID,
+				LastReactionMessageID:   state.LastReactionMessageID,
+			})
+		}
+		h.reactToCorrectAnswer(ctx, userID)
+		h.msgManager.DeleteUserAnswerAndReaction(ctx, userID)
+
+		var completionAwarded []string
+		if approval.IsLastStep {
+			completionAwarded = h.holdCompletionAchievements(ctx, userID, approval.Awarded)
+		} else {
+			h.notifyAchievements(ctx, userID, approval.Awarded)
+		}
+
+		// Ответ уже одобрен, поэтому повторно его не проверяем, а долю дошедших берём из прогресса
+		percentage, _ := h.answerChecker.StepPercentage(approval.Step.ID)
+
+		h.sendCorrectAnswerResponse(ctx, userID, approval.Step, percentage, approval.IsLastStep, "", "⏰ <i>Ответ принят автоматически</i>", completionAwarded)
 	}
 
-	positionAwarded, err := h.achievementEngine.EvaluatePositionBasedAchievements(userID)
-	if err != nil {
-		log.Printf("[HANDLER] Error evaluating position achievements: %v", err)
-	} else {
-		allAwarded = append(allAwarded, positionAwarded...)
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: h.adminID,
+		Text:   fmt.Sprintf("⏰ Автоматически одобрено ответов: %d", len(approvals)),
+	})
+}
+
+// SendDailyDigestIfDue отправляет администратору сводку за день, если наступило настроенное время.
+// Время отсчитывается в часовом поясе по умолчанию, при отсутствии активности сводка не отправляется
+func (h *BotHandler) SendDailyDigestIfDue(ctx context.Context, now time.Time) {
+	sendAt, err := h.settingsRepo.GetDailyDigestTime()
+	if err != nil || sendAt == "" {
+		return
 	}
 
-	hintAwarded, err := h.achievementEngine.EvaluateHintAchievements(userID)
-	if err != nil {
-		log.Printf("[HANDLER] Error evaluating hint achievements: %v", err)
-	} else {
-		allAwarded = append(allAwarded, hintAwarded...)
+	defaultTimezone, _ := h.settingsRepo.GetDefaultTimezone()
+	now = now.In(services.ResolveLocation(defaultTimezone))
+
+	lastSent, _ := h.settingsRepo.GetDailyDigestLastSent()
+	if !services.IsDailyDigestDue(now, sendAt, lastSent) {
+		return
 	}
 
-	asteriskAwarded, err := h.achievementEngine.CheckAsteriskAchievement(userID, stepID)
+	digest, err := h.statsService.GetDailyDigest(now)
 	if err != nil {
-		log.Printf("[HANDLER] Error checking asterisk achievement: %v", err)
-	} else {
-		allAwarded = append(allAwarded, asteriskAwarded...)
+		log.Printf("[DIGEST] Error building daily digest: %v", err)
+		return
 	}
 
-	if len(allAwarded) > 0 {
-		compositeAwarded, err := h.achievementEngine.EvaluateCompositeAchievements(userID)
-		if err != nil {
-			log.Printf("[HANDLER] Error evaluating composite achievements: %v", err)
-		} else {
-			allAwarded = append(allAwarded, compositeAwarded...)
-		}
+	if err := h.settingsRepo.SetDailyDigestLastSent(now.Format("2006-01-02")); err != nil {
+		log.Printf("[DIGEST] Error saving daily digest date: %v", err)
+		return
+	}
+
+	if !digest.HasActivity() {
+		return
 	}
 
-	h.notifyAchievements(ctx, userID, allAwarded)
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: h.adminID,
+		Text: FormatDailyDigest(digest, func(userID int64) string {
+			if user, err := h.userRepo.GetByID(userID); err == nil && user != nil {
+				return user.DisplayName()
+			}
+			return fmt.Sprintf("[%d]", userID)
+		}),
+	})
 }
 
-func (h *BotHandler) evaluateAchievementsOnQuestCompleted(ctx context.Context, userID int64) {
+func (h *BotHandler) evaluateAchievementsOnCorrectAnswer(ctx context.Context, userID int64, stepID int64) {
 	if h.achievementEngine == nil {
 		return
 	}
 
-	var allAwarded []string
+	h.notifyAchievements(ctx, userID, h.achievementEngine.EvaluateOnApproval(userID, stepID, false, time.Now()))
+	h.qualifyReferral(ctx, userID)
+	h.announceProgress(ctx, userID, false)
+}
 
-	completionAwarded, err := h.achievementEngine.EvaluateCompletionAchievements(userID)
-	if err != nil {
-		log.Printf("[HANDLER] Error evaluating completion achievements: %v", err)
-	} else {
-		allAwarded = append(allAwarded, completionAwarded...)
+// evaluateAchievementsOnQuestCompleted выдаёт достижения за финиш и возвращает те из них,
+// о которых нужно сообщить в финальном сообщении (см. holdCompletionAchievements)
+func (h *BotHandler) evaluateAchievementsOnQuestCompleted(ctx context.Context, userID int64) []string {
+	if h.achievementEngine == nil {
+		return nil
 	}
 
-	if len(allAwarded) > 0 {
-		compositeAwarded, err := h.achievementEngine.EvaluateCompositeAchievements(userID)
-		if err != nil {
-			log.Printf("[HANDLER] Error evaluating composite achievements: %v", err)
-		} else {
-			allAwarded = append(allAwarded, compositeAwarded...)
-		}
+	completionAwarded := h.holdCompletionAchievements(ctx, userID, h.achievementEngine.EvaluateOnApproval(userID, 0, true, time.Now()))
+	h.qualifyReferral(ctx, userID)
+	h.announceProgress(ctx, userID, true)
+	return completionAwarded
+}
+
+// announceProgress публикует веху участника в публичном канале, если это включено в настройках
+func (h *BotHandler) announceProgress(ctx context.Context, userID int64, questCompleted bool) {
+	if h.channelAnnouncer == nil {
+		return
 	}
 
-	h.notifyAchievements(ctx, userID, allAwarded)
+	completed, _, _, err := h.statsService.GetUserProgress(userID)
+	if err != nil {
+		log.Printf("[HANDLER] Error getting progress for announcement of user %d: %v", userID, err)
+		return
+	}
+	h.channelAnnouncer.AnnounceProgress(ctx, userID, completed, questCompleted)
 }
 
 func (h *BotHandler) evaluateAchievementsOnPhotoSubmitted(ctx context.Context, userID int64, isTextTask bool, msg *tgmodels.Message, step *models.Step) {
@@ -1616,6 +3795,58 @@ func (h *BotHandler) notifyAchievements(ctx context.Context, userID int64, achie
 	if err := h.achievementNotifier.NotifyAchievements(ctx, userID, achievementKeys); err != nil {
 		log.Printf("[HANDLER] Error notifying achievements: %v", err)
 	}
+
+	h.notifyAdminUniqueClaims(ctx, userID, achievementKeys)
+}
+
+// notifyWinnerPlaceChanges сообщает участникам о пересмотре мест по времени завершения:
+// у кого место отозвано — что оно перешло другому, кому передано — о новом достижении.
+// Администратор получает сводку
+func (h *BotHandler) notifyWinnerPlaceChanges(changes []services.WinnerPlaceChange) {
+	if h.achievementNotifier == nil {
+		return
+	}
+
+	ctx := context.Background()
+	for _, change := range changes {
+		for _, userID := range change.Revoked {
+			if err := h.achievementNotifier.NotifyWinnerPlaceRevoked(ctx, userID, change.AchievementKey); err != nil {
+				log.Printf("[HANDLER] Error notifying user %d about revoked %s: %v", userID, change.AchievementKey, err)
+			}
+		}
+		if change.Awarded != 0 {
+			h.notifyAchievements(ctx, change.Awarded, []string{change.AchievementKey})
+		}
+	}
+
+	userName := func(userID int64) string {
+		if user, err := h.userRepo.GetByID(userID); err == nil && user != nil {
+			return user.DisplayName()
+		}
+		return fmt.Sprintf("[%d]", userID)
+	}
+	h.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: h.adminID,
+		Text:   h.achievementNotifier.FormatWinnerPlaceChanges(changes, userName),
+	})
+}
+
+// notifyAdminUniqueClaims сообщает администратору об уникальных достижениях участника,
+// если это включено в настройках
+func (h *BotHandler) notifyAdminUniqueClaims(ctx context.Context, userID int64, achievementKeys []string) {
+	if enabled, err := h.settingsRepo.GetNotifyUniqueAchievements(); err != nil || !enabled {
+		return
+	}
+
+	userName := fmt.Sprintf("[%d]", userID)
+	if user, err := h.userRepo.GetByID(userID); err == nil && user != nil {
+		userName = user.DisplayName()
+	}
+	defaultTimezone, _ := h.settingsRepo.GetDefaultTimezone()
+
+	if err := h.achievementNotifier.NotifyAdminUniqueClaims(ctx, h.adminID, userID, userName, achievementKeys, services.ResolveLocation(defaultTimezone)); err != nil {
+		log.Printf("[HANDLER] Error notifying admin about unique achievements: %v", err)
+	}
 }
 
 func (h *BotHandler) evaluateSecretAnswer(ctx context.Context, userID int64, answer string) {
@@ -1661,7 +3892,7 @@ func (h *BotHandler) forwardMessageToAdmin(ctx context.Context, msg *tgmodels.Me
 		if msg.Caption != "" {
 			caption = caption + "\n\n📝 " + html.EscapeString(msg.Caption)
 		}
-		h.bot.SendPhoto(ctx, &bot.SendPhotoParams{
+		h.msgManager.SendPhotoWithRetry(ctx, &bot.SendPhotoParams{
 			ChatID:      h.adminID,
 			Photo:       &tgmodels.InputFileString{Data: fileID},
 			Caption:     caption,
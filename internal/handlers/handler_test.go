@@ -1,15 +1,24 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"html"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/models"
 	"github.com/ad/go-telegram-quest/internal/services"
+	"github.com/go-telegram/bot"
+	tgmodels "github.com/go-telegram/bot/models"
 	_ "modernc.org/sqlite"
 	"pgregory.net/rapid"
 )
@@ -27,7 +36,10 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			last_name TEXT,
 			username TEXT,
 			is_blocked BOOLEAN DEFAULT FALSE,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			accepted_at DATETIME,
+			accepted_rules_version INTEGER DEFAULT 0,
+			pending_completion BOOLEAN DEFAULT FALSE
 		)
 	`)
 	if err != nil {
@@ -46,6 +58,18 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			hint_text TEXT DEFAULT '',
 			hint_image TEXT DEFAULT '',
 			is_asterisk BOOLEAN DEFAULT FALSE,
+			location_lat REAL DEFAULT 0,
+			location_lng REAL DEFAULT 0,
+			location_radius INTEGER DEFAULT 0,
+			required_answers INTEGER DEFAULT 0,
+			chapter_id INTEGER DEFAULT 0,
+			numeric_feedback BOOLEAN DEFAULT FALSE,
+			ordered_answers BOOLEAN DEFAULT FALSE,
+			use_synonyms BOOLEAN DEFAULT FALSE,
+			link_domain TEXT DEFAULT '',
+			require_reply BOOLEAN DEFAULT FALSE,
+			image_constraints TEXT DEFAULT '',
+			author_note TEXT DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -57,7 +81,11 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			step_id INTEGER NOT NULL REFERENCES steps(id),
 			file_id TEXT NOT NULL,
-			position INTEGER NOT NULL DEFAULT 0
+			media_type TEXT NOT NULL DEFAULT 'photo',
+			position INTEGER NOT NULL DEFAULT 0,
+			width INTEGER DEFAULT 0,
+			height INTEGER DEFAULT 0,
+			file_size INTEGER DEFAULT 0
 		)
 	`)
 	if err != nil {
@@ -67,7 +95,23 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 		CREATE TABLE IF NOT EXISTS step_answers (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			step_id INTEGER NOT NULL REFERENCES steps(id),
-			answer TEXT NOT NULL
+			answer TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			language TEXT DEFAULT ''
+		);
+
+		CREATE TABLE IF NOT EXISTS answer_synonyms (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			synonym TEXT NOT NULL UNIQUE,
+			canonical TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS step_documents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			step_id INTEGER NOT NULL REFERENCES steps(id),
+			file_id TEXT NOT NULL,
+			file_name TEXT NOT NULL DEFAULT '',
+			position INTEGER NOT NULL DEFAULT 0
 		)
 	`)
 	if err != nil {
@@ -79,6 +123,7 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			step_id INTEGER NOT NULL REFERENCES steps(id),
 			status TEXT NOT NULL DEFAULT 'pending',
 			completed_at DATETIME,
+			review_reason TEXT DEFAULT '',
 			PRIMARY KEY (user_id, step_id)
 		)
 	`)
@@ -92,6 +137,7 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			step_id INTEGER NOT NULL REFERENCES steps(id),
 			text_answer TEXT,
 			hint_used BOOLEAN DEFAULT FALSE,
+			match_rule TEXT DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -136,7 +182,9 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			last_reaction_message_id INTEGER,
 			hint_message_id INTEGER DEFAULT 0,
 			current_step_hint_used BOOLEAN DEFAULT FALSE,
-			awaiting_next_step BOOLEAN DEFAULT FALSE
+			awaiting_next_step BOOLEAN DEFAULT FALSE,
+			last_bot_message_id INTEGER DEFAULT 0,
+			last_bot_message_text TEXT DEFAULT ''
 		)
 	`)
 	if err != nil {
@@ -533,8 +581,8 @@ func TestProperty21_BlockButtonConditionalDisplay(t *testing.T) {
 			rt.Fatal("Keyboard should not be nil")
 		}
 
-		if len(keyboard.InlineKeyboard) < 6 {
-			rt.Fatal("Keyboard should have at least 6 rows")
+		if len(keyboard.InlineKeyboard) < 9 {
+			rt.Fatal("Keyboard should have at least 9 rows")
 		}
 
 		// Row 0: Achievements button
@@ -609,8 +657,35 @@ func TestProperty21_BlockButtonConditionalDisplay(t *testing.T) {
 			rt.Errorf("Expected reset achievements callback 'reset_achievements:%d', got '%s'", userID, resetAchievementsRow[0].CallbackData)
 		}
 
-		// Row 5: Back button
-		backRow := keyboard.InlineKeyboard[5]
+		// Row 5: Export profile button
+		exportRow := keyboard.InlineKeyboard[5]
+		if len(exportRow) != 1 {
+			rt.Fatalf("Export row should have exactly 1 button, got %d", len(exportRow))
+		}
+		if !containsUserID(exportRow[0].CallbackData, "export_profile:", userID) {
+			rt.Errorf("Expected export callback 'export_profile:%d', got '%s'", userID, exportRow[0].CallbackData)
+		}
+
+		// Row 6: Answer photos button
+		photosRow := keyboard.InlineKeyboard[6]
+		if len(photosRow) != 1 {
+			rt.Fatalf("Answer photos row should have exactly 1 button, got %d", len(photosRow))
+		}
+		if !containsUserID(photosRow[0].CallbackData, "answer_photos:", userID) {
+			rt.Errorf("Expected answer photos callback 'answer_photos:%d', got '%s'", userID, photosRow[0].CallbackData)
+		}
+
+		// Row 7: View as user button
+		viewAsRow := keyboard.InlineKeyboard[7]
+		if len(viewAsRow) != 1 {
+			rt.Fatalf("View as row should have exactly 1 button, got %d", len(viewAsRow))
+		}
+		if !containsUserID(viewAsRow[0].CallbackData, "admin:view_as:", userID) {
+			rt.Errorf("Expected view as callback 'admin:view_as:%d', got '%s'", userID, viewAsRow[0].CallbackData)
+		}
+
+		// Row 8: Back button
+		backRow := keyboard.InlineKeyboard[8]
 		if len(backRow) != 1 {
 			rt.Fatalf("Back row should have exactly 1 button, got %d", len(backRow))
 		}
@@ -853,7 +928,10 @@ func setupTestDBWithAchievements(t *testing.T) (*db.DBQueue, func()) {
 			last_name TEXT,
 			username TEXT,
 			is_blocked BOOLEAN DEFAULT FALSE,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			accepted_at DATETIME,
+			accepted_rules_version INTEGER DEFAULT 0,
+			pending_completion BOOLEAN DEFAULT FALSE
 		)
 	`)
 	if err != nil {
@@ -872,6 +950,18 @@ func setupTestDBWithAchievements(t *testing.T) (*db.DBQueue, func()) {
 			hint_text TEXT DEFAULT '',
 			hint_image TEXT DEFAULT '',
 			is_asterisk BOOLEAN DEFAULT FALSE,
+			location_lat REAL DEFAULT 0,
+			location_lng REAL DEFAULT 0,
+			location_radius INTEGER DEFAULT 0,
+			required_answers INTEGER DEFAULT 0,
+			chapter_id INTEGER DEFAULT 0,
+			numeric_feedback BOOLEAN DEFAULT FALSE,
+			ordered_answers BOOLEAN DEFAULT FALSE,
+			use_synonyms BOOLEAN DEFAULT FALSE,
+			link_domain TEXT DEFAULT '',
+			require_reply BOOLEAN DEFAULT FALSE,
+			image_constraints TEXT DEFAULT '',
+			author_note TEXT DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -883,7 +973,11 @@ func setupTestDBWithAchievements(t *testing.T) (*db.DBQueue, func()) {
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			step_id INTEGER NOT NULL REFERENCES steps(id),
 			file_id TEXT NOT NULL,
-			position INTEGER NOT NULL DEFAULT 0
+			media_type TEXT NOT NULL DEFAULT 'photo',
+			position INTEGER NOT NULL DEFAULT 0,
+			width INTEGER DEFAULT 0,
+			height INTEGER DEFAULT 0,
+			file_size INTEGER DEFAULT 0
 		)
 	`)
 	if err != nil {
@@ -893,7 +987,23 @@ func setupTestDBWithAchievements(t *testing.T) (*db.DBQueue, func()) {
 		CREATE TABLE IF NOT EXISTS step_answers (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			step_id INTEGER NOT NULL REFERENCES steps(id),
-			answer TEXT NOT NULL
+			answer TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			language TEXT DEFAULT ''
+		);
+
+		CREATE TABLE IF NOT EXISTS answer_synonyms (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			synonym TEXT NOT NULL UNIQUE,
+			canonical TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS step_documents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			step_id INTEGER NOT NULL REFERENCES steps(id),
+			file_id TEXT NOT NULL,
+			file_name TEXT NOT NULL DEFAULT '',
+			position INTEGER NOT NULL DEFAULT 0
 		)
 	`)
 	if err != nil {
@@ -905,6 +1015,7 @@ func setupTestDBWithAchievements(t *testing.T) (*db.DBQueue, func()) {
 			step_id INTEGER NOT NULL REFERENCES steps(id),
 			status TEXT NOT NULL DEFAULT 'pending',
 			completed_at DATETIME,
+			review_reason TEXT DEFAULT '',
 			PRIMARY KEY (user_id, step_id)
 		)
 	`)
@@ -918,6 +1029,7 @@ func setupTestDBWithAchievements(t *testing.T) (*db.DBQueue, func()) {
 			step_id INTEGER NOT NULL REFERENCES steps(id),
 			text_answer TEXT,
 			hint_used BOOLEAN DEFAULT FALSE,
+			match_rule TEXT DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -962,7 +1074,9 @@ func setupTestDBWithAchievements(t *testing.T) (*db.DBQueue, func()) {
 			last_reaction_message_id INTEGER,
 			hint_message_id INTEGER DEFAULT 0,
 			current_step_hint_used BOOLEAN DEFAULT FALSE,
-			awaiting_next_step BOOLEAN DEFAULT FALSE
+			awaiting_next_step BOOLEAN DEFAULT FALSE,
+			last_bot_message_id INTEGER DEFAULT 0,
+			last_bot_message_text TEXT DEFAULT ''
 		)
 	`)
 	if err != nil {
@@ -979,7 +1093,8 @@ func setupTestDBWithAchievements(t *testing.T) (*db.DBQueue, func()) {
 			is_unique BOOLEAN DEFAULT FALSE,
 			conditions TEXT NOT NULL,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			is_active BOOLEAN DEFAULT TRUE
+			is_active BOOLEAN DEFAULT TRUE,
+			notify_on_award BOOLEAN DEFAULT TRUE
 		)
 	`)
 	if err != nil {
@@ -1325,3 +1440,2202 @@ func TestMainHandlerFormatting_MessageSettings(t *testing.T) {
 		}
 	}
 }
+
+func TestCorrectAnswerImage_OnlyAfterApproval(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:           b,
+		adminID:       adminID,
+		settingsRepo:  settingsRepo,
+		userRepo:      userRepo,
+		stepRepo:      stepRepo,
+		progressRepo:  progressRepo,
+		answerRepo:    answerRepo,
+		chatStateRepo: chatStateRepo,
+		answerChecker: services.NewAnswerChecker(answerRepo, progressRepo, userRepo, settingsRepo),
+		stateResolver: services.NewStateResolver(stepRepo, progressRepo, userRepo),
+		msgManager:    services.NewMessageManager(b, chatStateRepo, nil),
+		statsService:  services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Test"}); err != nil {
+		t.Fatal(err)
+	}
+	stepID, err := stepRepo.Create(&models.Step{StepOrder: 1, Text: "Сфотографируйте табличку", AnswerType: models.AnswerTypeImage, IsActive: true, CorrectAnswerImage: "correct_image_file_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stepRepo.Create(&models.Step{StepOrder: 2, Text: "Question", AnswerType: models.AnswerTypeText, HasAutoCheck: true, IsActive: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusWaitingReview}); err != nil {
+		t.Fatal(err)
+	}
+
+	decide := func(action string) {
+		h.handleAdminDecision(context.Background(), &tgmodels.CallbackQuery{
+			ID:   action,
+			From: tgmodels.User{ID: adminID},
+			Data: fmt.Sprintf("%s:%d:%d", action, userID, stepID),
+			Message: tgmodels.MaybeInaccessibleMessage{
+				Message: &tgmodels.Message{ID: 7, Chat: tgmodels.Chat{ID: adminID}, Photo: []tgmodels.PhotoSize{{FileID: "answer"}}},
+			},
+		})
+	}
+	photosToUser := func() int {
+		count := 0
+		for _, call := range recorded() {
+			if call.method == "sendPhoto" && call.chatID == fmt.Sprint(userID) {
+				count++
+			}
+		}
+		return count
+	}
+
+	// Пока ответ на проверке или отклонён, картинку правильного ответа участник не получает
+	if count := photosToUser(); count != 0 {
+		t.Fatalf("Expected no correct-answer image while waiting for review, got %d", count)
+	}
+	decide("reject")
+	if count := photosToUser(); count != 0 {
+		t.Fatalf("Expected no correct-answer image after rejection, got %d", count)
+	}
+
+	if err := progressRepo.Update(&models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusWaitingReview}); err != nil {
+		t.Fatal(err)
+	}
+	decide("approve")
+	if count := photosToUser(); count != 1 {
+		t.Fatalf("Expected one correct-answer image after approval, got %d", count)
+	}
+
+	// Повторное нажатие «Одобрить» картинку заново не отправляет
+	decide("approve")
+	if count := photosToUser(); count != 1 {
+		t.Errorf("Expected no repeated correct-answer image, got %d", count)
+	}
+}
+
+func TestEditedMessagePolicy(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	chatStateRepo := db.NewChatStateRepository(queue)
+	userID := int64(101)
+
+	if err := chatStateRepo.Save(&models.ChatState{UserID: userID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chatStateRepo.UpdateAnswerMessageID(userID, 50); err != nil {
+		t.Fatal(err)
+	}
+	chatState, err := chatStateRepo.Get(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending := &models.UserProgress{UserID: userID, StepID: 1, Status: models.StatusPending}
+	waitingReview := &models.UserProgress{UserID: userID, StepID: 1, Status: models.StatusWaitingReview}
+	approved := &models.UserProgress{UserID: userID, StepID: 1, Status: models.StatusApproved}
+
+	if isEditOfCurrentAnswer(chatState, pending, 42) {
+		t.Error("Edit of an old message must be ignored")
+	}
+	if !isEditOfCurrentAnswer(chatState, pending, 50) {
+		t.Error("Edit of the latest answer on the current step must be processed")
+	}
+	if !isEditOfCurrentAnswer(chatState, waitingReview, 50) {
+		t.Error("Edit of an answer awaiting review must be processed as a new submission")
+	}
+	if isEditOfCurrentAnswer(chatState, approved, 50) {
+		t.Error("Edit of an answer on an already approved step must be ignored")
+	}
+
+	chatState.AwaitingNextStep = true
+	if isEditOfCurrentAnswer(chatState, pending, 50) {
+		t.Error("Edit while awaiting the next step must be ignored")
+	}
+
+	if isEditOfCurrentAnswer(nil, pending, 50) {
+		t.Error("Edit without chat state must be ignored")
+	}
+}
+
+func TestMinAnswerLength(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	h := &BotHandler{settingsRepo: settingsRepo}
+	step := &models.Step{Answers: []string{"москва"}}
+
+	if h.isAnswerTooShort(step, "a") {
+		t.Error("Minimum answer length should be off by default")
+	}
+
+	if err := settingsRepo.SetMinAnswerLength(3); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, answer := range []string{"", "a", " ab ", "да"} {
+		if !h.isAnswerTooShort(step, answer) {
+			t.Errorf("Expected %q to be rejected as too short", answer)
+		}
+	}
+	for _, answer := range []string{"abc", "кот", "длинный ответ"} {
+		if h.isAnswerTooShort(step, answer) {
+			t.Errorf("Expected %q to proceed to answer checking", answer)
+		}
+	}
+
+	for name, short := range map[string]*models.Step{
+		"numeric":       {Answers: []string{"1000"}, NumericFeedback: true},
+		"sequence":      {Answers: []string{"1", "2", "3"}, OrderedAnswers: true},
+		"set":           {Answers: []string{"1", "2", "3"}, RequiredAnswers: 2},
+		"short answers": {Answers: []string{"7", "семь"}},
+	} {
+		if h.isAnswerTooShort(short, "7") {
+			t.Errorf("%s: expected a short answer to proceed to answer checking", name)
+		}
+	}
+}
+
+func TestFormatStepReportNotification(t *testing.T) {
+	step := &models.Step{ID: 5, StepOrder: 3, Text: "Найдите <табличку> у входа"}
+
+	formatted := FormatStepReportNotification("Иван & Co", 42, step, "ответ \"42\" не принимается <b>")
+
+	expected := []string{
+		"🚩 <b>Проблема с шагом</b> от Иван &amp; Co (ID: <code>42</code>)",
+		"📋 Шаг 3: <i>Найдите &lt;табличку&gt; у входа</i>",
+		"💬 ответ &#34;42&#34; не принимается &lt;b&gt;",
+	}
+	for _, want := range expected {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected notification to contain %q, got:\n%s", want, formatted)
+		}
+	}
+
+	withoutStep := FormatStepReportNotification("Иван", 42, nil, "бот молчит")
+	if !strings.Contains(withoutStep, "Текущего шага нет") {
+		t.Errorf("Expected notification without step context, got:\n%s", withoutStep)
+	}
+}
+
+func TestFormatSupportThread(t *testing.T) {
+	at := time.Date(2025, 3, 10, 15, 4, 0, 0, time.UTC)
+	thread := []db.SupportMessage{
+		{UserID: 42, FromAdmin: true, Text: "Нужна помощь?", CreatedAt: at},
+		{UserID: 42, FromAdmin: false, Text: "Да, <шаг> 3", CreatedAt: at.Add(time.Minute)},
+	}
+
+	formatted := FormatSupportThread(thread)
+
+	expected := []string{
+		"💬 <b>Переписка</b>",
+		"🛡 10.03 15:04: Нужна помощь?",
+		"👤 10.03 15:05: Да, &lt;шаг&gt; 3",
+	}
+	for _, want := range expected {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected thread to contain %q, got:\n%s", want, formatted)
+		}
+	}
+	if strings.Index(formatted, "🛡") > strings.Index(formatted, "👤") {
+		t.Errorf("Expected messages in chronological order, got:\n%s", formatted)
+	}
+}
+
+func TestFormatSupportReply(t *testing.T) {
+	step := &models.Step{ID: 5, StepOrder: 3, Text: "Найдите табличку"}
+
+	formatted := FormatSupportReply("Иван", 42, step, "не вижу <её>")
+
+	expected := []string{
+		"от Иван (ID: <code>42</code>)",
+		"📋 Шаг 3: <i>Найдите табличку</i>",
+		"не вижу &lt;её&gt;",
+	}
+	for _, want := range expected {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected reply to contain %q, got:\n%s", want, formatted)
+		}
+	}
+}
+
+func TestFormatChapterHeader(t *testing.T) {
+	header := FormatChapterHeader(models.ChapterProgress{
+		Chapter:   models.Chapter{ID: 7, Title: "Лес <тёмный>"},
+		Number:    2,
+		Completed: 1,
+		Total:     3,
+	})
+
+	expected := "📖 <b>Глава 2: Лес &lt;тёмный&gt;</b> (1/3)"
+	if header != expected {
+		t.Errorf("Expected %q, got %q", expected, header)
+	}
+}
+
+func TestFormatRemainingSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining *services.RemainingSteps
+		want      string
+	}{
+		{"mid-quest", &services.RemainingSteps{Total: 5, Completed: 2, Remaining: 3, Started: true}, "Осталось шагов: 3 из 5"},
+		{"completed", &services.RemainingSteps{Total: 5, Completed: 5, Remaining: 0, Started: true}, "Квест пройден"},
+		{"not started", &services.RemainingSteps{Total: 5, Remaining: 5}, "Квест ещё не начат. Всего шагов: 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatRemainingSteps(tt.remaining); !strings.Contains(got, tt.want) {
+				t.Errorf("Expected %q in %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatRejectionWithReason(t *testing.T) {
+	formatted := FormatRejectionWithReason(4, "нужно фото <целиком>")
+
+	expected := []string{
+		"Ответ на шаг 4 не принят",
+		"Причина: нужно фото &lt;целиком&gt;",
+	}
+	for _, want := range expected {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected rejection to contain %q, got:\n%s", want, formatted)
+		}
+	}
+}
+
+func TestStartPayloadRouter(t *testing.T) {
+	var routed []string
+	record := func(name string, handled bool) startPayloadHandler {
+		return func(_ context.Context, _ *tgmodels.Message, value string, _ bool) bool {
+			routed = append(routed, name+":"+value)
+			return handled
+		}
+	}
+
+	router := (&StartPayloadRouter{}).
+		Handle("resume_", record("resume", false)).
+		Handle("invite_", record("invite", false)).
+		Handle("step_", record("step", true))
+
+	tests := []struct {
+		payload string
+		routed  string
+		handled bool
+	}{
+		{"resume_1", "resume:1", false},
+		{"invite_42", "invite:42", false},
+		{"step_3", "step:3", true},
+		{"promo_abc", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		routed = nil
+		msg := &tgmodels.Message{From: &tgmodels.User{ID: 1}}
+		handled := router.Dispatch(context.Background(), msg, tt.payload, true)
+		if handled != tt.handled {
+			t.Errorf("%q: expected handled=%t, got %t", tt.payload, tt.handled, handled)
+		}
+		if tt.routed == "" && len(routed) != 0 {
+			t.Errorf("%q: unknown payload must be ignored, got %v", tt.payload, routed)
+		}
+		if tt.routed != "" && (len(routed) != 1 || routed[0] != tt.routed) {
+			t.Errorf("%q: expected route %q, got %v", tt.payload, tt.routed, routed)
+		}
+	}
+}
+
+func TestBotHandlerStartPayloads_NonAdminFallsThrough(t *testing.T) {
+	h := &BotHandler{adminID: 1}
+	msg := &tgmodels.Message{From: &tgmodels.User{ID: 2}, Chat: tgmodels.Chat{ID: 2}}
+
+	for _, payload := range []string{"resume_5", "invite_1", "step_1", "unknown"} {
+		if h.startPayloadRouter().Dispatch(context.Background(), msg, payload, true) {
+			t.Errorf("%q: expected regular start flow for a participant", payload)
+		}
+	}
+}
+
+func TestAutoAdvanceSetting(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+
+	autoAdvance, err := settingsRepo.GetAutoAdvance()
+	if err != nil {
+		t.Fatalf("GetAutoAdvance: %v", err)
+	}
+	if autoAdvance {
+		t.Error("Expected manual mode with the next button by default")
+	}
+
+	if err := settingsRepo.SetAutoAdvance(true); err != nil {
+		t.Fatalf("SetAutoAdvance: %v", err)
+	}
+	if autoAdvance, _ = settingsRepo.GetAutoAdvance(); !autoAdvance {
+		t.Error("Expected auto-advance mode after enabling")
+	}
+
+	if err := settingsRepo.SetAutoAdvance(false); err != nil {
+		t.Fatalf("SetAutoAdvance: %v", err)
+	}
+	if autoAdvance, _ = settingsRepo.GetAutoAdvance(); autoAdvance {
+		t.Error("Expected manual mode after disabling")
+	}
+}
+
+func TestHandleCorrectAnswer_AdvanceModes(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+
+	var steps []*models.Step
+	for order := 1; order <= 2; order++ {
+		step := &models.Step{StepOrder: order, Text: fmt.Sprintf("Задание %d", order), AnswerType: models.AnswerTypeText, HasAutoCheck: true, IsActive: true}
+		id, err := stepRepo.Create(step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step.ID = id
+		steps = append(steps, step)
+	}
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+
+	answerFirstStep := func(t *testing.T, autoAdvance bool) []telegramCall {
+		t.Helper()
+		if err := settingsRepo.SetAutoAdvance(autoAdvance); err != nil {
+			t.Fatal(err)
+		}
+		if err := progressRepo.DeleteUserProgress(userID); err != nil {
+			t.Fatal(err)
+		}
+		if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: steps[0].ID, Status: models.StatusPending}); err != nil {
+			t.Fatal(err)
+		}
+
+		b, recorded := newRecordingBot(t)
+		h := &BotHandler{
+			bot:           b,
+			settingsRepo:  settingsRepo,
+			userRepo:      userRepo,
+			stepRepo:      stepRepo,
+			progressRepo:  progressRepo,
+			chatStateRepo: chatStateRepo,
+			stateResolver: services.NewStateResolver(stepRepo, progressRepo, userRepo),
+			msgManager:    services.NewMessageManager(b, chatStateRepo, nil),
+			statsService:  services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+		}
+		h.handleCorrectAnswer(context.Background(), userID, steps[0], 0, "")
+		return recorded()
+	}
+	summarize := func(calls []telegramCall) (nextStepSent, nextButton bool) {
+		for _, call := range calls {
+			if call.method == "sendMessage" && strings.Contains(call.text, "Задание 2") {
+				nextStepSent = true
+			}
+			if strings.Contains(call.replyMarkup, "next_step:") {
+				nextButton = true
+			}
+		}
+		return nextStepSent, nextButton
+	}
+
+	t.Run("auto", func(t *testing.T) {
+		calls := answerFirstStep(t, true)
+		nextStepSent, nextButton := summarize(calls)
+		if !nextStepSent {
+			t.Errorf("Expected the next step to be sent right away, got %+v", calls)
+		}
+		if nextButton {
+			t.Errorf("Expected no next button in auto mode, got %+v", calls)
+		}
+		if state, _ := chatStateRepo.Get(userID); state != nil && state.AwaitingNextStep {
+			t.Error("Expected the user not to wait for the next button")
+		}
+	})
+
+	t.Run("manual", func(t *testing.T) {
+		calls := answerFirstStep(t, false)
+		nextStepSent, nextButton := summarize(calls)
+		if nextStepSent {
+			t.Errorf("Expected the next step to wait for the button, got %+v", calls)
+		}
+		if !nextButton {
+			t.Errorf("Expected the next button under the correct answer message, got %+v", calls)
+		}
+		if state, _ := chatStateRepo.Get(userID); state == nil || !state.AwaitingNextStep {
+			t.Error("Expected the user to wait for the next button")
+		}
+	})
+}
+
+func TestNextStepKeyboard_ManualMode(t *testing.T) {
+	keyboard := nextStepKeyboard(3)
+
+	if len(keyboard.InlineKeyboard) != 1 || len(keyboard.InlineKeyboard[0]) != 1 {
+		t.Fatalf("Expected a single next button, got %+v", keyboard.InlineKeyboard)
+	}
+	if got := keyboard.InlineKeyboard[0][0].CallbackData; got != "next_step:3" {
+		t.Errorf("Expected callback next_step:3, got %q", got)
+	}
+}
+
+// telegramCall — запрос к фейковому Bot API: метод и подпись, если она была
+type telegramCall struct {
+	method      string
+	chatID      string
+	text        string
+	caption     string
+	messageID   string
+	replyMarkup string
+}
+
+// newRecordingBot возвращает бота, который шлёт запросы на локальный сервер и записывает их по порядку
+func newRecordingBot(t *testing.T) (*bot.Bot, func() []telegramCall) {
+	t.Helper()
+	return newFailingRecordingBot(t)
+}
+
+// newFailingRecordingBot записывает вызовы как newRecordingBot, но отвечает ошибкой
+// Telegram API на вызовы перечисленных методов
+func newFailingRecordingBot(t *testing.T, failingMethods ...string) (*bot.Bot, func() []telegramCall) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var calls []telegramCall
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		mu.Lock()
+		calls = append(calls, telegramCall{method: path.Base(r.URL.Path), chatID: r.FormValue("chat_id"), text: r.FormValue("text"), caption: r.FormValue("caption"), messageID: r.FormValue("message_id"), replyMarkup: r.FormValue("reply_markup")})
+		id := len(calls)
+		mu.Unlock()
+		if slices.Contains(failingMethods, path.Base(r.URL.Path)) {
+			fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: wrong file identifier/HTTP URL specified"}`)
+			return
+		}
+		if path.Base(r.URL.Path) == "setMessageReaction" {
+			fmt.Fprint(w, `{"ok":true,"result":true}`)
+			return
+		}
+		if path.Base(r.URL.Path) == "sendMediaGroup" {
+			fmt.Fprintf(w, `{"ok":true,"result":[{"message_id":%d,"date":0,"chat":{"id":1,"type":"private"}}]}`, id)
+			return
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":1,"type":"private"}}}`, id)
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := bot.New("123:test", bot.WithSkipGetMe(), bot.WithServerURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b, func() []telegramCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]telegramCall(nil), calls...)
+	}
+}
+
+func TestSendCorrectMessage_ImageDelay(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	previousUnit := correctImageDelayUnit
+	correctImageDelayUnit = time.Millisecond
+	defer func() { correctImageDelayUnit = previousUnit }()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:          b,
+		settingsRepo: settingsRepo,
+		msgManager:   services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+	}
+
+	t.Run("together by default", func(t *testing.T) {
+		before := len(recorded())
+		h.sendCorrectMessage(context.Background(), 1, nil, "✅ Правильно!", "photo-id", "", nil)
+
+		calls := recorded()[before:]
+		if len(calls) != 1 || calls[0].method != "sendPhoto" || calls[0].caption != "✅ Правильно!" {
+			t.Errorf("Expected a single photo with the success caption, got %+v", calls)
+		}
+	})
+
+	t.Run("separate follow-up with delay", func(t *testing.T) {
+		if err := settingsRepo.SetCorrectImageDelay(2); err != nil {
+			t.Fatal(err)
+		}
+		defer settingsRepo.SetCorrectImageDelay(0)
+
+		before := len(recorded())
+		msg := h.sendCorrectMessage(context.Background(), 1, nil, "✅ Правильно!", "photo-id", "", nextStepKeyboard(1))
+
+		calls := recorded()[before:]
+		if len(calls) != 2 {
+			t.Fatalf("Expected text and image as two operations, got %+v", calls)
+		}
+		if calls[0].method != "sendMessage" || calls[1].method != "sendPhoto" {
+			t.Errorf("Expected success text before the image, got %+v", calls)
+		}
+		if calls[1].caption != "" {
+			t.Errorf("Expected the follow-up image without caption, got %q", calls[1].caption)
+		}
+		if calls[0].replyMarkup != "" || !strings.Contains(calls[1].replyMarkup, "next_step:1") {
+			t.Errorf("Expected the next button on the delayed image only, got %q and %q", calls[0].replyMarkup, calls[1].replyMarkup)
+		}
+		if msg == nil || msg.ID != before+2 {
+			t.Errorf("Expected the image message carrying the next button to be returned, got %+v", msg)
+		}
+	})
+}
+
+func TestSendCorrectMessage_DelayedBrokenImage(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	previousUnit := correctImageDelayUnit
+	correctImageDelayUnit = time.Millisecond
+	defer func() { correctImageDelayUnit = previousUnit }()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	if err := settingsRepo.SetCorrectImageDelay(2); err != nil {
+		t.Fatal(err)
+	}
+	b, recorded := newFailingRecordingBot(t, "sendPhoto")
+	h := &BotHandler{
+		bot:          b,
+		settingsRepo: settingsRepo,
+		msgManager:   services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+		errorManager: services.NewErrorManager(b, 999),
+	}
+	step := &models.Step{ID: 1, StepOrder: 3, CorrectAnswerImage: "stale-photo-id"}
+
+	msg := h.sendCorrectMessage(context.Background(), 1, step, "✅ Правильно!", step.CorrectAnswerImage, "", nextStepKeyboard(3))
+	if msg == nil {
+		t.Fatal("Expected the success text to be returned")
+	}
+
+	// Картинка не дошла, поэтому кнопки добавляются к тексту, чтобы участник мог продолжить
+	var attached bool
+	for _, call := range recorded() {
+		if call.method == "editMessageReplyMarkup" && call.messageID == fmt.Sprint(msg.ID) && strings.Contains(call.replyMarkup, "next_step:3") {
+			attached = true
+		}
+	}
+	if !attached {
+		t.Errorf("Expected the next button to be attached to the text, got %+v", recorded())
+	}
+}
+
+func TestSendCorrectMessage_BrokenImage(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	b, recorded := newFailingRecordingBot(t, "sendPhoto")
+	h := &BotHandler{
+		bot:          b,
+		settingsRepo: settingsRepo,
+		msgManager:   services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+		errorManager: services.NewErrorManager(b, 999),
+	}
+	step := &models.Step{ID: 1, StepOrder: 3, CorrectAnswerImage: "stale-photo-id"}
+
+	msg := h.sendCorrectMessage(context.Background(), 1, step, "✅ Правильно!", step.CorrectAnswerImage, "", nextStepKeyboard(3))
+
+	var successSent, adminReported bool
+	for _, call := range recorded() {
+		if call.method != "sendMessage" {
+			continue
+		}
+		if call.chatID == "1" && call.text == "✅ Правильно!" {
+			successSent = true
+		}
+		if call.chatID == "999" && strings.Contains(call.text, "step 3") {
+			adminReported = true
+		}
+	}
+	if !successSent || msg == nil {
+		t.Errorf("Expected the success text despite the broken image, got %+v", recorded())
+	}
+	if !adminReported {
+		t.Errorf("Expected the admin to be told which step image is broken, got %+v", recorded())
+	}
+}
+
+func TestRulesGate(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:          b,
+		settingsRepo: settingsRepo,
+		userRepo:     userRepo,
+		msgManager:   services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+	}
+	ctx := context.Background()
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: 1, FirstName: "Test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.requireRulesAccepted(ctx, 1, 1) {
+		t.Fatal("Expected no gate while rules are not configured")
+	}
+
+	if err := settingsRepo.SetRulesMessage("Не подсказывать другим участникам"); err != nil {
+		t.Fatal(err)
+	}
+	defer settingsRepo.Set("rules_message", "")
+
+	before := len(recorded())
+	if h.requireRulesAccepted(ctx, 1, 1) {
+		t.Fatal("Expected progress to be blocked until rules are accepted")
+	}
+	if calls := recorded()[before:]; len(calls) != 1 || calls[0].method != "sendMessage" {
+		t.Errorf("Expected the rules to be shown, got %+v", calls)
+	}
+
+	callback := func(data string) *tgmodels.CallbackQuery {
+		return &tgmodels.CallbackQuery{
+			ID:   "cb",
+			From: tgmodels.User{ID: 1},
+			Data: data,
+			Message: tgmodels.MaybeInaccessibleMessage{
+				Message: &tgmodels.Message{ID: 1, Chat: tgmodels.Chat{ID: 1, Type: tgmodels.ChatTypePrivate}},
+			},
+		}
+	}
+
+	h.handleRulesAcceptCallback(ctx, callback("rules_accept:0"))
+	if h.requireRulesAccepted(ctx, 1, 1) {
+		t.Error("Expected acceptance of an outdated version to be ignored")
+	}
+
+	if err := userRepo.AcceptRules(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !h.requireRulesAccepted(ctx, 1, 1) {
+		t.Error("Expected progress to be allowed after accepting the rules")
+	}
+
+	if err := settingsRepo.SetRulesMessage("Не подсказывать и не спойлерить"); err != nil {
+		t.Fatal(err)
+	}
+	before = len(recorded())
+	if h.requireRulesAccepted(ctx, 1, 1) {
+		t.Error("Expected the user to be re-prompted after the rules version bump")
+	}
+	if calls := recorded()[before:]; len(calls) != 1 || calls[0].method != "sendMessage" {
+		t.Errorf("Expected the new rules to be shown, got %+v", calls)
+	}
+}
+
+func TestRulesGate_BlocksQuestCommands(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:                  b,
+		adminID:              adminID,
+		settingsRepo:         settingsRepo,
+		userRepo:             userRepo,
+		stepRepo:             db.NewStepRepository(queue),
+		progressRepo:         db.NewProgressRepository(queue),
+		msgManager:           services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+		questStateMiddleware: services.NewQuestStateMiddleware(services.NewQuestStateManager(settingsRepo), adminID),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.Set("quest_state", string(services.QuestStateRunning)); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.SetRulesMessage("Не подсказывать другим участникам"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, command := range []string{"/report не открывается картинка", "/code ПРОМО", "/map", "/hint", "/remaining"} {
+		before := len(recorded())
+		h.handleMessage(context.Background(), &tgmodels.Message{
+			Text: command,
+			From: &tgmodels.User{ID: userID},
+			Chat: tgmodels.Chat{ID: userID, Type: tgmodels.ChatTypePrivate},
+		})
+		calls := recorded()[before:]
+		if len(calls) != 1 || !strings.Contains(calls[0].text, "Не подсказывать другим участникам") {
+			t.Errorf("Expected %q to show the rules until they are accepted, got %+v", command, calls)
+		}
+	}
+
+	// Исправленное сообщение не проходит мимо правил как ответ на шаг
+	before := len(recorded())
+	h.handleEditedMessage(context.Background(), &tgmodels.Message{
+		ID:   10,
+		Text: "исправленный ответ",
+		From: &tgmodels.User{ID: userID},
+		Chat: tgmodels.Chat{ID: userID, Type: tgmodels.ChatTypePrivate},
+	})
+	calls := recorded()[before:]
+	if len(calls) != 1 || !strings.Contains(calls[0].text, "Не подсказывать другим участникам") {
+		t.Errorf("Expected an edited message to show the rules until they are accepted, got %+v", calls)
+	}
+}
+
+func TestHelpListsEveryUserCommand(t *testing.T) {
+	h := &BotHandler{}
+	commands := h.userCommands()
+	help := formatHelp(commands)
+
+	seen := make(map[string]bool)
+	for _, command := range commands {
+		if seen[command.name] {
+			t.Errorf("Command /%s is registered twice", command.name)
+		}
+		seen[command.name] = true
+
+		if command.description == "" {
+			t.Errorf("Command /%s has no description", command.name)
+		}
+		if !strings.Contains(help, "/"+command.name+" ") {
+			t.Errorf("Expected /%s in help output:\n%s", command.name, help)
+		}
+	}
+
+	for _, name := range []string{"start", "help", "remaining", "hint", "report", "timezone", "dnd"} {
+		if !seen[name] {
+			t.Errorf("Expected /%s to be registered", name)
+		}
+	}
+}
+
+func TestUserCommandMatches(t *testing.T) {
+	command := userCommand{name: "report"}
+	for text, want := range map[string]bool{
+		"/report":             true,
+		"/report не работает": true,
+		"/reports":            false,
+		"report":              false,
+	} {
+		if got := command.matches(text); got != want {
+			t.Errorf("matches(%q) = %v, expected %v", text, got, want)
+		}
+	}
+}
+
+func TestHintCooldownRemaining(t *testing.T) {
+	lastHintAt := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	cooldown := time.Minute
+
+	if got := hintCooldownRemaining(time.Time{}, cooldown, lastHintAt); got != 0 {
+		t.Errorf("Expected first hint to be available, got %v left", got)
+	}
+	if got := hintCooldownRemaining(lastHintAt, cooldown, lastHintAt.Add(20*time.Second)); got != 40*time.Second {
+		t.Errorf("Expected request within the cooldown to be rejected with 40s left, got %v", got)
+	}
+	if got := hintCooldownRemaining(lastHintAt, cooldown, lastHintAt.Add(time.Minute)); got != 0 {
+		t.Errorf("Expected hint to be available once the cooldown elapsed, got %v left", got)
+	}
+	if got := hintCooldownRemaining(lastHintAt, 0, lastHintAt); got != 0 {
+		t.Errorf("Expected no cooldown when disabled, got %v", got)
+	}
+	if text := formatHintCooldown(40 * time.Second); !strings.Contains(text, "40с") {
+		t.Errorf("Expected remaining time in message, got %q", text)
+	}
+}
+
+func TestHintCooldown_EntryPoints(t *testing.T) {
+	queue, cleanup := setupTestDBMessaging(t)
+	defer cleanup()
+
+	const userID = 42
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+
+	stepID, err := stepRepo.Create(&models.Step{StepOrder: 1, Text: "Задание", AnswerType: models.AnswerTypeText, HasAutoCheck: true, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.UpdateHint(stepID, "Ищи у фонтана", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.SetHintCooldown(60); err != nil {
+		t.Fatal(err)
+	}
+
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:           b,
+		settingsRepo:  settingsRepo,
+		userRepo:      userRepo,
+		stepRepo:      stepRepo,
+		progressRepo:  progressRepo,
+		chatStateRepo: chatStateRepo,
+		stateResolver: services.NewStateResolver(stepRepo, progressRepo, userRepo),
+		msgManager:    services.NewMessageManager(b, chatStateRepo, nil),
+	}
+	ctx := context.Background()
+
+	reset := func(t *testing.T) {
+		t.Helper()
+		if err := progressRepo.DeleteUserProgress(userID); err != nil {
+			t.Fatal(err)
+		}
+		if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusPending}); err != nil {
+			t.Fatal(err)
+		}
+		if err := chatStateRepo.SetHintUsed(userID, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expireCooldown := func(t *testing.T) {
+		t.Helper()
+		if err := progressRepo.SetLastHintAt(userID, stepID, time.Now().Add(-2*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// texts возвращает тексты сообщений и ответов на нажатия, отправленных после before
+	texts := func(before int) string {
+		var result []string
+		for _, call := range recorded()[before:] {
+			if call.method == "sendMessage" || call.method == "answerCallbackQuery" {
+				result = append(result, call.text)
+			}
+		}
+		return strings.Join(result, "\n")
+	}
+
+	t.Run("text and command", func(t *testing.T) {
+		reset(t)
+		command := &tgmodels.Message{Text: "/hint", From: &tgmodels.User{ID: userID}, Chat: tgmodels.Chat{ID: userID}}
+
+		before := len(recorded())
+		if !h.handleHintByText(ctx, userID) {
+			t.Fatal("Expected the first hint request to be handled")
+		}
+		if sent := texts(before); !strings.Contains(sent, "Ищи у фонтана") {
+			t.Fatalf("Expected the hint to be sent, got %q", sent)
+		}
+
+		// Подсказка уже показана, но во время ожидания участник видит оставшееся время
+		before = len(recorded())
+		h.handleHintCommand(ctx, command)
+		if sent := texts(before); !strings.Contains(sent, "Следующая подсказка будет доступна") {
+			t.Errorf("Expected the cooldown message within the cooldown, got %q", sent)
+		}
+
+		expireCooldown(t)
+		before = len(recorded())
+		h.handleHintCommand(ctx, command)
+		if sent := texts(before); !strings.Contains(sent, "уже показана") || strings.Contains(sent, "Следующая подсказка") {
+			t.Errorf("Expected the already shown message after the cooldown, got %q", sent)
+		}
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		reset(t)
+		callback := &tgmodels.CallbackQuery{
+			ID:   "hint",
+			From: tgmodels.User{ID: userID},
+			Data: fmt.Sprintf("hint:%d:%d", userID, stepID),
+			Message: tgmodels.MaybeInaccessibleMessage{
+				Message: &tgmodels.Message{ID: 5, Chat: tgmodels.Chat{ID: userID}},
+			},
+		}
+
+		before := len(recorded())
+		h.handleHintCallback(ctx, callback)
+		if sent := texts(before); !strings.Contains(sent, "Ищи у фонтана") {
+			t.Fatalf("Expected the hint to be sent, got %q", sent)
+		}
+
+		before = len(recorded())
+		h.handleHintCallback(ctx, callback)
+		if sent := texts(before); !strings.Contains(sent, "Следующая подсказка будет доступна") || strings.Contains(sent, "Ищи у фонтана") {
+			t.Errorf("Expected the cooldown answer within the cooldown, got %q", sent)
+		}
+
+		expireCooldown(t)
+		before = len(recorded())
+		h.handleHintCallback(ctx, callback)
+		if sent := texts(before); !strings.Contains(sent, "Ищи у фонтана") {
+			t.Errorf("Expected the hint to be available after the cooldown, got %q", sent)
+		}
+	})
+}
+
+func TestFormatQuestMap(t *testing.T) {
+	questMap := &services.QuestMap{
+		Total:     5,
+		Completed: 3,
+		Position:  4,
+		Started:   true,
+		Chapters: []services.QuestMapChapter{
+			{Number: 1, Title: "Лес", Revealed: true, Completed: 3, Total: 3},
+			{Number: 2, Title: "Река", Revealed: true, Current: true, Total: 1},
+			{Number: 3, Total: 1},
+		},
+		Optional: []services.QuestMapStep{
+			{Number: 2, Status: models.StatusApproved},
+			{Number: 3, Status: models.StatusSkipped},
+		},
+		OptionalAhead: 1,
+	}
+
+	got := FormatQuestMap(questMap)
+	for _, want := range []string{
+		"Вы на шаге 4 из 5, пройдено: 3",
+		"✅ Глава 1: Лес (3/3)",
+		"📍 Глава 2: Река (0/1)",
+		"🔒 Глава 3\n",
+		"✅ Шаг 2",
+		"⏭ Шаг 3 — пропущен",
+		"Впереди ещё необязательных шагов: 1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in quest map, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Шаг 5") {
+		t.Errorf("Expected unreached optional step not to be listed, got:\n%s", got)
+	}
+}
+
+func TestHandleUpdate_RecoversPanic(t *testing.T) {
+	b, recorded := newRecordingBot(t)
+	// Обработчик без зависимостей падает на первом же обращении к репозиторию
+	h := &BotHandler{adminID: 1, errorManager: services.NewErrorManager(b, 1)}
+
+	update := &tgmodels.Update{Message: &tgmodels.Message{
+		Text: "ответ",
+		From: &tgmodels.User{ID: 2},
+		Chat: tgmodels.Chat{ID: 2, Type: tgmodels.ChatTypePrivate},
+	}}
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("Expected panic to be recovered inside HandleUpdate, got %v", r)
+			}
+		}()
+		h.HandleUpdate(context.Background(), b, update)
+	}()
+
+	calls := recorded()
+	if len(calls) != 1 || calls[0].method != "sendMessage" {
+		t.Errorf("Expected the panic to be reported to the admin, got %+v", calls)
+	}
+}
+
+func TestHandleUpdate_MyChatMemberNotifiesAdmin(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const adminID = 1
+	const groupChatID = int64(-1001234567890)
+	settingsRepo := db.NewSettingsRepository(queue)
+	if err := settingsRepo.SetRequiredGroupChatID(groupChatID); err != nil {
+		t.Fatal(err)
+	}
+
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:               b,
+		adminID:           adminID,
+		settingsRepo:      settingsRepo,
+		msgManager:        services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+		groupChatVerifier: services.NewGroupChatVerifier(b, settingsRepo),
+	}
+
+	statusUpdate := func(chatID int64, from, to tgmodels.ChatMemberType) *tgmodels.Update {
+		return &tgmodels.Update{MyChatMember: &tgmodels.ChatMemberUpdated{
+			Chat:          tgmodels.Chat{ID: chatID, Title: "Квестеры", Type: tgmodels.ChatTypeSupergroup},
+			OldChatMember: tgmodels.ChatMember{Type: from},
+			NewChatMember: tgmodels.ChatMember{Type: to},
+		}}
+	}
+
+	h.HandleUpdate(context.Background(), b, statusUpdate(42, tgmodels.ChatMemberTypeMember, tgmodels.ChatMemberTypeBanned))
+	if calls := recorded(); len(calls) != 0 {
+		t.Fatalf("Expected updates outside the restriction group to be ignored, got %+v", calls)
+	}
+
+	h.HandleUpdate(context.Background(), b, statusUpdate(groupChatID, tgmodels.ChatMemberTypeAdministrator, tgmodels.ChatMemberTypeMember))
+	calls := recorded()
+	if len(calls) != 1 || calls[0].chatID != fmt.Sprint(adminID) || !strings.Contains(calls[0].text, "больше не администратор группы «Квестеры»") {
+		t.Fatalf("Expected the admin to be told about lost rights, got %+v", calls)
+	}
+	if !strings.Contains(calls[0].text, "сообщение об ошибке проверки") {
+		t.Errorf("Expected the message to describe the fail-closed mode, got %q", calls[0].text)
+	}
+
+	if err := settingsRepo.SetGroupCheckFailOpen(true); err != nil {
+		t.Fatal(err)
+	}
+	h.HandleUpdate(context.Background(), b, statusUpdate(groupChatID, tgmodels.ChatMemberTypeMember, tgmodels.ChatMemberTypeLeft))
+	calls = recorded()
+	if len(calls) != 2 || !strings.Contains(calls[1].text, "Бота удалили из группы") || !strings.Contains(calls[1].text, "допускаются без неё") {
+		t.Fatalf("Expected the admin to be told about removal in fail-open mode, got %+v", calls)
+	}
+
+	h.HandleUpdate(context.Background(), b, statusUpdate(groupChatID, tgmodels.ChatMemberTypeLeft, tgmodels.ChatMemberTypeAdministrator))
+	calls = recorded()
+	if len(calls) != 3 || !strings.Contains(calls[2].text, "снова администратор") {
+		t.Errorf("Expected the admin to be told about restored rights, got %+v", calls)
+	}
+}
+
+func TestSendStep_TypingIndicator(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	previousUnit := stepTypingDelayUnit
+	stepTypingDelayUnit = time.Millisecond
+	defer func() { stepTypingDelayUnit = previousUnit }()
+
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:           b,
+		stepRepo:      stepRepo,
+		progressRepo:  progressRepo,
+		settingsRepo:  settingsRepo,
+		chatStateRepo: chatStateRepo,
+		statsService:  services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+		msgManager:    services.NewMessageManager(b, chatStateRepo, nil),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: 1, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	step := &models.Step{StepOrder: 1, Text: "Вопрос", AnswerType: models.AnswerTypeText, IsActive: true}
+	stepID, err := stepRepo.Create(step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step.ID = stepID
+
+	h.sendStep(context.Background(), 1, step)
+	for _, call := range recorded() {
+		if call.method == "sendChatAction" {
+			t.Fatalf("Expected no typing indicator by default, got %+v", recorded())
+		}
+	}
+
+	if err := settingsRepo.SetStepTypingDelay(2); err != nil {
+		t.Fatal(err)
+	}
+	before := len(recorded())
+	h.sendStep(context.Background(), 1, step)
+
+	calls := recorded()[before:]
+	if len(calls) < 2 || calls[0].method != "sendChatAction" || calls[len(calls)-1].method != "sendMessage" {
+		t.Errorf("Expected typing indicator before the step, got %+v", calls)
+	}
+}
+
+func TestSendStep_AuthorNoteHidden(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:           b,
+		stepRepo:      stepRepo,
+		progressRepo:  progressRepo,
+		settingsRepo:  settingsRepo,
+		chatStateRepo: chatStateRepo,
+		statsService:  services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+		msgManager:    services.NewMessageManager(b, chatStateRepo, nil),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: 1, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	stepID, err := stepRepo.Create(&models.Step{StepOrder: 1, Text: "Вопрос", AnswerType: models.AnswerTypeText, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const note = "Загадка из сборника 1978 года"
+	if err := stepRepo.SetAuthorNote(stepID, note); err != nil {
+		t.Fatal(err)
+	}
+	step, err := stepRepo.GetByID(stepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.sendStep(context.Background(), 1, step)
+
+	calls := recorded()
+	if len(calls) == 0 {
+		t.Fatal("Expected the step to be sent")
+	}
+	for _, call := range calls {
+		if strings.Contains(call.text, note) || strings.Contains(call.caption, note) {
+			t.Errorf("Author note leaked to the participant: %+v", call)
+		}
+	}
+}
+
+func TestNotifyAchievements_AdminUniqueClaim(t *testing.T) {
+	queue, cleanup := setupTestDBWithAchievements(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	achievementRepo := db.NewAchievementRepository(queue)
+	b, recorded := newRecordingBot(t)
+	msgManager := services.NewMessageManager(b, db.NewChatStateRepository(queue), nil)
+	h := &BotHandler{
+		bot:                 b,
+		adminID:             adminID,
+		settingsRepo:        settingsRepo,
+		userRepo:            userRepo,
+		msgManager:          msgManager,
+		achievementNotifier: services.NewAchievementNotifier(b, achievementRepo, msgManager, nil),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, achievement := range []*models.Achievement{
+		{Key: "pioneer", Name: "Первопроходец", Category: models.CategoryUnique, Type: models.TypeProgressBased, IsUnique: true, IsActive: true},
+		{Key: "beginner_5", Name: "Новичок", Category: models.CategoryProgress, Type: models.TypeProgressBased, IsActive: true},
+	} {
+		if err := achievementRepo.Create(achievement); err != nil {
+			t.Fatal(err)
+		}
+		if err := achievementRepo.AssignToUser(userID, achievement.ID, time.Now(), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	adminMessages := func(before int) []telegramCall {
+		var messages []telegramCall
+		for _, call := range recorded()[before:] {
+			if call.method == "sendMessage" && call.chatID == "1" {
+				messages = append(messages, call)
+			}
+		}
+		return messages
+	}
+
+	before := len(recorded())
+	h.notifyAchievements(context.Background(), userID, []string{"pioneer"})
+	if messages := adminMessages(before); len(messages) != 0 {
+		t.Errorf("Expected no admin notification while the setting is off, got %+v", messages)
+	}
+
+	if err := settingsRepo.SetNotifyUniqueAchievements(true); err != nil {
+		t.Fatal(err)
+	}
+
+	before = len(recorded())
+	h.notifyAchievements(context.Background(), userID, []string{"beginner_5"})
+	if messages := adminMessages(before); len(messages) != 0 {
+		t.Errorf("Expected no admin notification for a non-unique achievement, got %+v", messages)
+	}
+
+	before = len(recorded())
+	h.notifyAchievements(context.Background(), userID, []string{"pioneer"})
+	messages := adminMessages(before)
+	if len(messages) != 1 {
+		t.Fatalf("Expected one admin notification for a unique achievement, got %+v", messages)
+	}
+	if !strings.Contains(messages[0].text, "Первопроходец") || !strings.Contains(messages[0].text, "Анна") {
+		t.Errorf("Expected achievement and user in the notification, got %q", messages[0].text)
+	}
+}
+
+func TestNotifyWinnerPlaceChanges(t *testing.T) {
+	queue, cleanup := setupTestDBWithAchievements(t)
+	defer cleanup()
+
+	const adminID = 1
+	userRepo := db.NewUserRepository(queue)
+	achievementRepo := db.NewAchievementRepository(queue)
+	b, recorded := newRecordingBot(t)
+	msgManager := services.NewMessageManager(b, db.NewChatStateRepository(queue), nil)
+	h := &BotHandler{
+		bot:                 b,
+		adminID:             adminID,
+		settingsRepo:        db.NewSettingsRepository(queue),
+		userRepo:            userRepo,
+		msgManager:          msgManager,
+		achievementNotifier: services.NewAchievementNotifier(b, achievementRepo, msgManager, nil),
+	}
+
+	for _, user := range []*models.User{{ID: 42, FirstName: "Анна"}, {ID: 43, FirstName: "Борис"}} {
+		if err := userRepo.CreateOrUpdate(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, achievement := range []*models.Achievement{
+		{Key: "winner_1", Name: "Победитель", Category: models.CategoryUnique, Type: models.TypeProgressBased, IsActive: true, NotifyOnAward: true},
+		{Key: "winner_2", Name: "Серебро", Category: models.CategoryUnique, Type: models.TypeProgressBased, IsActive: true, NotifyOnAward: true},
+	} {
+		if err := achievementRepo.Create(achievement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.notifyWinnerPlaceChanges([]services.WinnerPlaceChange{
+		{AchievementKey: "winner_1", Revoked: []int64{42}},
+		{AchievementKey: "winner_2", Awarded: 42},
+	})
+
+	calls := recorded()
+	if len(calls) != 3 {
+		t.Fatalf("Expected messages to the user about both places and a summary to the admin, got %+v", calls)
+	}
+	if calls[0].chatID != "42" || !strings.Contains(calls[0].text, "Место пересмотрено") || !strings.Contains(calls[0].text, "Победитель") {
+		t.Errorf("Expected the user to be told the place was revoked, got %+v", calls[0])
+	}
+	if calls[1].chatID != "42" || !strings.Contains(calls[1].text, "Серебро") {
+		t.Errorf("Expected the user to be told about the new place, got %+v", calls[1])
+	}
+	if calls[2].chatID != "1" || !strings.Contains(calls[2].text, "➖ Анна") || !strings.Contains(calls[2].text, "➕ Анна") {
+		t.Errorf("Expected the admin summary to list the changes, got %q", calls[2].text)
+	}
+}
+
+func TestCompletionAchievements_GroupedIntoFinalMessage(t *testing.T) {
+	queue, cleanup := setupTestDBWithAchievements(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	achievementRepo := db.NewAchievementRepository(queue)
+	b, recorded := newRecordingBot(t)
+	msgManager := services.NewMessageManager(b, db.NewChatStateRepository(queue), nil)
+	h := &BotHandler{
+		bot:                 b,
+		adminID:             adminID,
+		settingsRepo:        settingsRepo,
+		userRepo:            userRepo,
+		msgManager:          msgManager,
+		statsService:        services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+		achievementNotifier: services.NewAchievementNotifier(b, achievementRepo, msgManager, nil),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	awarded := []string{"winner", "finisher"}
+	for _, achievement := range []*models.Achievement{
+		{Key: "winner", Name: "Победитель", Category: models.CategoryUnique, Type: models.TypeProgressBased, IsUnique: true, IsActive: true},
+		{Key: "finisher", Name: "Финишёр", Category: models.CategoryCompletion, Type: models.TypeProgressBased, IsActive: true},
+	} {
+		if err := achievementRepo.Create(achievement); err != nil {
+			t.Fatal(err)
+		}
+		if err := achievementRepo.AssignToUser(userID, achievement.ID, time.Now(), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	userMessages := func(before int) []telegramCall {
+		var messages []telegramCall
+		for _, call := range recorded()[before:] {
+			if call.method == "sendMessage" && call.chatID == "42" {
+				messages = append(messages, call)
+			}
+		}
+		return messages
+	}
+
+	before := len(recorded())
+	if held := h.holdCompletionAchievements(context.Background(), userID, awarded); held != nil {
+		t.Errorf("Expected achievements to be sent right away while the setting is off, got %v", held)
+	}
+	if messages := userMessages(before); len(messages) != len(awarded) {
+		t.Errorf("Expected %d separate notifications, got %+v", len(awarded), messages)
+	}
+
+	if err := settingsRepo.SetGroupCompletionAchievements(true); err != nil {
+		t.Fatal(err)
+	}
+
+	before = len(recorded())
+	held := h.holdCompletionAchievements(context.Background(), userID, awarded)
+	if len(held) != len(awarded) {
+		t.Fatalf("Expected achievements to be held for the final message, got %v", held)
+	}
+	if messages := userMessages(before); len(messages) != 0 {
+		t.Errorf("Expected no separate notifications while grouping, got %+v", messages)
+	}
+
+	settings, err := settingsRepo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	finalMsg := h.questFinalMessage(context.Background(), userID, settings, held)
+	for _, name := range []string{"Победитель", "Финишёр"} {
+		if !strings.Contains(finalMsg, name) {
+			t.Errorf("Expected %q in the final message, got %q", name, finalMsg)
+		}
+	}
+}
+
+func TestQuestFinalMessage_CompletionPosition(t *testing.T) {
+	queue, cleanup := setupTestDBWithAchievements(t)
+	defer cleanup()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	h := &BotHandler{
+		settingsRepo: settingsRepo,
+		userRepo:     userRepo,
+		stepRepo:     stepRepo,
+		progressRepo: progressRepo,
+		statsService: services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+	}
+
+	stepID, err := stepRepo.Create(&models.Step{StepOrder: 1, Text: "Финал", AnswerType: models.AnswerTypeText, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	finishedAt := time.Now().Add(-time.Hour)
+	for _, userID := range []int64{10, 20} {
+		if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Участник"}); err != nil {
+			t.Fatal(err)
+		}
+		completedAt := finishedAt
+		progress := &models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusApproved, CompletedAt: &completedAt}
+		if err := progressRepo.Create(progress); err != nil {
+			t.Fatal(err)
+		}
+		if err := progressRepo.Update(progress); err != nil {
+			t.Fatal(err)
+		}
+		finishedAt = finishedAt.Add(time.Minute)
+	}
+
+	finalMessage := func(userID int64) string {
+		settings, err := settingsRepo.GetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h.questFinalMessage(context.Background(), userID, settings, nil)
+	}
+
+	if msg := finalMessage(10); strings.Contains(msg, "порядке прохождения") {
+		t.Errorf("Expected no completion position while the setting is off, got %q", msg)
+	}
+
+	if err := settingsRepo.SetRevealCompletionPosition(true); err != nil {
+		t.Fatal(err)
+	}
+	for userID, expected := range map[int64]string{10: "квеста: 1", 20: "квеста: 2"} {
+		if msg := finalMessage(userID); !strings.Contains(msg, expected) {
+			t.Errorf("User %d: expected %q in the final message, got %q", userID, expected, msg)
+		}
+	}
+}
+
+func TestHandleCorrectAnswer_MinCompletionFinishesQuest(t *testing.T) {
+	queue, cleanup := setupTestDBWithAchievements(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+	resolver := services.NewStateResolver(stepRepo, progressRepo, userRepo)
+	resolver.SetSettingsRepository(settingsRepo)
+
+	var steps []*models.Step
+	for order := 1; order <= 3; order++ {
+		step := &models.Step{StepOrder: order, Text: fmt.Sprintf("Задание %d", order), AnswerType: models.AnswerTypeText, HasAutoCheck: true, IsActive: true}
+		id, err := stepRepo.Create(step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step.ID = id
+		steps = append(steps, step)
+	}
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.SetMinCompletionSteps(2); err != nil {
+		t.Fatal(err)
+	}
+
+	answerSecondStep := func(t *testing.T) []telegramCall {
+		t.Helper()
+		b, recorded := newRecordingBot(t)
+		h := &BotHandler{
+			bot:           b,
+			adminID:       adminID,
+			settingsRepo:  settingsRepo,
+			userRepo:      userRepo,
+			stepRepo:      stepRepo,
+			progressRepo:  progressRepo,
+			chatStateRepo: chatStateRepo,
+			stateResolver: resolver,
+			msgManager:    services.NewMessageManager(b, chatStateRepo, nil),
+			statsService:  services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+		}
+		if err := progressRepo.DeleteUserProgress(userID); err != nil {
+			t.Fatal(err)
+		}
+		if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: steps[0].ID, Status: models.StatusApproved}); err != nil {
+			t.Fatal(err)
+		}
+		if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: steps[1].ID, Status: models.StatusPending}); err != nil {
+			t.Fatal(err)
+		}
+
+		h.handleCorrectAnswer(context.Background(), userID, steps[1], 100, "")
+		return recorded()
+	}
+	sentTo := func(calls []telegramCall, chatID int64, fragment string) bool {
+		for _, call := range calls {
+			if call.chatID == fmt.Sprint(chatID) && strings.Contains(call.text+call.caption, fragment) {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("finishes at the minimum", func(t *testing.T) {
+		calls := answerSecondStep(t)
+		if !sentTo(calls, userID, "Congratulations") {
+			t.Errorf("Expected the final message at the minimum, got %+v", calls)
+		}
+		if sentTo(calls, userID, "Задание 3") {
+			t.Errorf("Expected no further step after the minimum, got %+v", calls)
+		}
+		if !sentTo(calls, adminID, "завершил квест") {
+			t.Errorf("Expected the admin to be notified about the completion, got %+v", calls)
+		}
+		state, err := resolver.ResolveState(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !state.IsCompleted || state.CurrentStep != nil {
+			t.Errorf("Expected a completed read-only state after the minimum, got %+v", state)
+		}
+	})
+
+	t.Run("asks for confirmation at the minimum", func(t *testing.T) {
+		if err := settingsRepo.SetCompletionConfirmation(true); err != nil {
+			t.Fatal(err)
+		}
+		defer settingsRepo.SetCompletionConfirmation(false)
+
+		calls := answerSecondStep(t)
+		if !sentTo(calls, userID, completionConfirmationText) || sentTo(calls, userID, "Congratulations") {
+			t.Errorf("Expected the confirmation instead of the final message, got %+v", calls)
+		}
+		if pending, _ := userRepo.IsPendingCompletion(userID); !pending {
+			t.Error("Expected the completion to wait for confirmation")
+		}
+	})
+}
+
+func TestHandleCorrectAnswer_Reaction(t *testing.T) {
+	queue, cleanup := setupTestDBWithAchievements(t)
+	defer cleanup()
+
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+
+	var steps []*models.Step
+	for order := 1; order <= 2; order++ {
+		step := &models.Step{StepOrder: order, Text: "Шаг", AnswerType: models.AnswerTypeText, HasAutoCheck: true, IsActive: true}
+		id, err := stepRepo.Create(step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step.ID = id
+		steps = append(steps, step)
+	}
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+
+	answerCorrectly := func(t *testing.T, failingMethods ...string) []telegramCall {
+		b, recorded := newFailingRecordingBot(t, failingMethods...)
+		msgManager := services.NewMessageManager(b, chatStateRepo, nil)
+		h := &BotHandler{
+			bot:           b,
+			settingsRepo:  settingsRepo,
+			userRepo:      userRepo,
+			stepRepo:      stepRepo,
+			progressRepo:  progressRepo,
+			chatStateRepo: chatStateRepo,
+			msgManager:    msgManager,
+			statsService:  services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+		}
+		if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: steps[0].ID, Status: models.StatusPending}); err != nil {
+			t.Fatal(err)
+		}
+		if err := msgManager.SaveUserAnswerMessageID(userID, 777); err != nil {
+			t.Fatal(err)
+		}
+
+		h.handleCorrectAnswer(context.Background(), userID, steps[0], 100, "")
+		if err := progressRepo.DeleteUserProgress(userID); err != nil {
+			t.Fatal(err)
+		}
+		return recorded()
+	}
+
+	onAnswer := func(calls []telegramCall, method string) int {
+		n := 0
+		for _, call := range calls {
+			if call.method == method && call.messageID == "777" {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("off by default", func(t *testing.T) {
+		calls := answerCorrectly(t)
+		if n := onAnswer(calls, "setMessageReaction"); n != 0 {
+			t.Errorf("Expected no reaction while the setting is off, got %d", n)
+		}
+		if n := onAnswer(calls, "deleteMessage"); n == 0 {
+			t.Errorf("Expected the answer to be deleted, got %+v", calls)
+		}
+	})
+
+	if err := settingsRepo.SetCorrectAnswerReaction("👍"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("reaction keeps the answer", func(t *testing.T) {
+		calls := answerCorrectly(t)
+		if n := onAnswer(calls, "setMessageReaction"); n != 1 {
+			t.Fatalf("Expected one reaction on the correct answer, got %+v", calls)
+		}
+		if n := onAnswer(calls, "deleteMessage"); n != 0 {
+			t.Errorf("Expected the answer with the reaction to stay in the chat, got %+v", calls)
+		}
+	})
+
+	t.Run("answer deleted when reaction fails", func(t *testing.T) {
+		calls := answerCorrectly(t, "setMessageReaction")
+		if n := onAnswer(calls, "deleteMessage"); n == 0 {
+			t.Errorf("Expected the answer to be deleted after a failed reaction, got %+v", calls)
+		}
+	})
+}
+
+func TestHandleMessage_CompletedQuestIsReadOnly(t *testing.T) {
+	queue, cleanup := setupTestDBWithAchievements(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:                  b,
+		adminID:              adminID,
+		settingsRepo:         settingsRepo,
+		userRepo:             userRepo,
+		stepRepo:             stepRepo,
+		progressRepo:         progressRepo,
+		msgManager:           services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+		statsService:         services.NewStatisticsServiceWithAchievements(queue, stepRepo, progressRepo, userRepo, db.NewAchievementRepository(queue)),
+		questStateMiddleware: services.NewQuestStateMiddleware(services.NewQuestStateManager(settingsRepo), adminID),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(text string) []telegramCall {
+		before := len(recorded())
+		h.handleMessage(context.Background(), &tgmodels.Message{
+			Text: text,
+			From: &tgmodels.User{ID: userID},
+			Chat: tgmodels.Chat{ID: userID, Type: tgmodels.ChatTypePrivate},
+		})
+		return recorded()[before:]
+	}
+	expectText := func(calls []telegramCall, fragment string) {
+		t.Helper()
+		if len(calls) != 1 || calls[0].method != "sendMessage" || !strings.Contains(calls[0].text, fragment) {
+			t.Errorf("Expected one message containing %q, got %+v", fragment, calls)
+		}
+	}
+
+	if err := settingsRepo.Set("quest_state", string(services.QuestStateCompleted)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectText(send("/progress"), "Пройдено шагов: 0 из 0")
+	expectText(send("/leaderboard"), "1. Анна")
+	expectText(send("/stickers"), "Стикер-пак")
+
+	calls := send("ответ")
+	expectText(calls, "Квест завершён")
+	expectText(calls, "Ответы больше не принимаются")
+
+	if err := settingsRepo.Set("quest_state", string(services.QuestStateNotStarted)); err != nil {
+		t.Fatal(err)
+	}
+
+	calls = send("/progress")
+	expectText(calls, "Квест ещё не начался")
+	if len(calls) == 1 && strings.Contains(calls[0].text, "/leaderboard") {
+		t.Errorf("Results hint must not be shown before the quest starts, got %q", calls[0].text)
+	}
+
+	expectText(send("/timezone"), "Ваш часовой пояс")
+
+	if err := userRepo.BlockUser(userID); err != nil {
+		t.Fatal(err)
+	}
+	calls = send("/timezone Europe/Moscow")
+	expectText(calls, "Квест ещё не начался")
+	if timezone, _ := userRepo.GetEffectiveTimezone(userID); timezone == "Europe/Moscow" {
+		t.Error("Expected a blocked user not to be able to change the timezone")
+	}
+}
+
+func TestHandleViewAs(t *testing.T) {
+	queue, cleanup := setupTestDBMessaging(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:           b,
+		adminID:       adminID,
+		settingsRepo:  settingsRepo,
+		userRepo:      userRepo,
+		stepRepo:      stepRepo,
+		progressRepo:  progressRepo,
+		chatStateRepo: db.NewChatStateRepository(queue),
+		stateResolver: services.NewStateResolver(stepRepo, progressRepo, userRepo),
+		msgManager:    services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+		statsService:  services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	var stepIDs []int64
+	for i, step := range []models.Step{
+		{Text: "Первый вопрос", AnswerType: models.AnswerTypeText},
+		{Text: "Второй вопрос", AnswerType: models.AnswerTypeChoice},
+	} {
+		id, err := stepRepo.Create(&models.Step{StepOrder: i + 1, Text: step.Text, AnswerType: step.AnswerType, IsActive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stepIDs = append(stepIDs, id)
+	}
+	for _, choice := range []string{"Красный", "Синий"} {
+		if err := stepRepo.AddChoice(stepIDs[1], choice); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stepRepo.UpdateHint(stepIDs[1], "Подсказка", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepIDs[0], Status: models.StatusApproved}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.handleViewAs(context.Background(), &tgmodels.CallbackQuery{
+		From: tgmodels.User{ID: adminID},
+		Data: fmt.Sprintf("admin:view_as:%d", userID),
+	})
+
+	calls := recorded()
+	if len(calls) != 1 || calls[0].chatID != fmt.Sprint(adminID) {
+		t.Fatalf("Expected a single message to the admin, got %+v", calls)
+	}
+	if !strings.Contains(calls[0].text, "Второй вопрос") || !strings.Contains(calls[0].text, "Шаг 2") || !strings.Contains(calls[0].text, "Анна") {
+		t.Errorf("Expected the user's current step in the preview, got %q", calls[0].text)
+	}
+	if calls[0].replyMarkup != "" {
+		t.Errorf("Expected the admin preview without live buttons, got %q", calls[0].replyMarkup)
+	}
+	for _, button := range []string{"🔘 Красный", "🔘 Синий", "💡 Подсказка"} {
+		if !strings.Contains(calls[0].text, button) {
+			t.Errorf("Expected %q listed in the preview, got %q", button, calls[0].text)
+		}
+	}
+
+	progress, err := progressRepo.GetUserProgress(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 1 {
+		t.Errorf("Expected the preview not to write progress, got %d records", len(progress))
+	}
+}
+
+func TestHandleImageAnswer_LinkInCaption(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:           b,
+		settingsRepo:  settingsRepo,
+		userRepo:      userRepo,
+		stepRepo:      stepRepo,
+		progressRepo:  progressRepo,
+		answerRepo:    answerRepo,
+		chatStateRepo: chatStateRepo,
+		answerChecker: services.NewAnswerChecker(answerRepo, progressRepo, userRepo, settingsRepo),
+		stateResolver: services.NewStateResolver(stepRepo, progressRepo, userRepo),
+		msgManager:    services.NewMessageManager(b, chatStateRepo, nil),
+		statsService:  services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	stepID, err := stepRepo.Create(&models.Step{StepOrder: 1, Text: "Пришлите ссылку на пост", AnswerType: models.AnswerTypeLink, HasAutoCheck: true, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.UpdateLinkDomain(stepID, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepID, Status: models.StatusPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	photo := func(caption string, entities []tgmodels.MessageEntity) {
+		h.handleImageAnswer(context.Background(), &tgmodels.Message{
+			ID:              len(recorded()) + 1,
+			Photo:           []tgmodels.PhotoSize{{FileID: "photo"}},
+			Caption:         caption,
+			CaptionEntities: entities,
+			From:            &tgmodels.User{ID: userID},
+			Chat:            tgmodels.Chat{ID: userID, Type: tgmodels.ChatTypePrivate},
+		})
+	}
+
+	photo("Вот", nil)
+	if progress, _ := progressRepo.GetByUserAndStep(userID, stepID); progress.Status == models.StatusApproved {
+		t.Fatal("Expected a caption without a link not to be accepted")
+	}
+
+	photo("Пост: example.com/post/1", []tgmodels.MessageEntity{{Type: tgmodels.MessageEntityTypeURL, Offset: 6, Length: 18}})
+	if progress, _ := progressRepo.GetByUserAndStep(userID, stepID); progress == nil || progress.Status != models.StatusApproved {
+		t.Errorf("Expected a link in the photo caption to be accepted, got %+v", progress)
+	}
+}
+
+func TestSkipDeactivatedSteps(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	resolver := services.NewStateResolver(stepRepo, progressRepo, userRepo)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:           b,
+		settingsRepo:  settingsRepo,
+		userRepo:      userRepo,
+		stepRepo:      stepRepo,
+		progressRepo:  progressRepo,
+		chatStateRepo: db.NewChatStateRepository(queue),
+		stateResolver: resolver,
+		msgManager:    services.NewMessageManager(b, db.NewChatStateRepository(queue), nil),
+		statsService:  services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	var stepIDs []int64
+	for i, text := range []string{"Первый вопрос", "Второй вопрос"} {
+		id, err := stepRepo.Create(&models.Step{StepOrder: i + 1, Text: text, AnswerType: models.AnswerTypeText, IsActive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stepIDs = append(stepIDs, id)
+	}
+	if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepIDs[0], Status: models.StatusPending}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.SetActive(stepIDs[0], false); err != nil {
+		t.Fatal(err)
+	}
+
+	resolve := func() *services.UserState {
+		t.Helper()
+		state, err := resolver.ResolveState(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state
+	}
+
+	if err := settingsRepo.SetSkipDeactivatedSteps(false); err != nil {
+		t.Fatal(err)
+	}
+	if h.skipDeactivatedSteps(context.Background(), userID, resolve()) {
+		t.Fatal("Expected nothing to happen while the setting is off")
+	}
+	if err := settingsRepo.SetSkipDeactivatedSteps(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.skipDeactivatedSteps(context.Background(), userID, resolve()) {
+		t.Fatal("Expected the user to be moved past the deactivated step")
+	}
+
+	calls := recorded()
+	if len(calls) != 2 || !strings.Contains(calls[0].text, "больше недоступен") || !strings.Contains(calls[1].text, "Второй вопрос") {
+		t.Errorf("Expected a notice followed by the next step, got %+v", calls)
+	}
+
+	if progress, _ := progressRepo.GetByUserAndStep(userID, stepIDs[0]); progress != nil {
+		t.Errorf("Expected progress of the deactivated step to be cleared, got %+v", progress)
+	}
+	if state := resolve(); len(state.DeactivatedStepIDs) != 0 || state.CurrentStep == nil || state.CurrentStep.ID != stepIDs[1] {
+		t.Errorf("Expected the user to stay on step 2 without further skipping, got %+v", state)
+	}
+}
+
+func TestRejectNonReply(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const userID = 42
+	const taskMessageID = 100
+
+	chatStateRepo := db.NewChatStateRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:           b,
+		chatStateRepo: chatStateRepo,
+		msgManager:    services.NewMessageManager(b, chatStateRepo, nil),
+	}
+
+	if err := chatStateRepo.Save(&models.ChatState{UserID: userID, LastTaskMessageID: taskMessageID}); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := func(replyTo *tgmodels.Message) *tgmodels.Message {
+		return &tgmodels.Message{
+			ID:             200,
+			From:           &tgmodels.User{ID: userID},
+			Chat:           tgmodels.Chat{ID: userID},
+			Text:           "ответ",
+			ReplyToMessage: replyTo,
+		}
+	}
+	step := &models.Step{ID: 1, AnswerType: models.AnswerTypeText, RequireReply: true}
+
+	if h.rejectNonReply(context.Background(), answer(&tgmodels.Message{ID: taskMessageID}), step) {
+		t.Error("Expected a reply to the task message to be accepted")
+	}
+	if calls := recorded(); len(calls) != 0 {
+		t.Errorf("Expected no guidance for an accepted reply, got %+v", calls)
+	}
+
+	if !h.rejectNonReply(context.Background(), answer(nil), step) {
+		t.Error("Expected an answer without a reply to be rejected")
+	}
+	calls := recorded()
+	if len(calls) != 1 || !strings.Contains(calls[0].text, "реплаем") {
+		t.Errorf("Expected reply guidance, got %+v", calls)
+	}
+
+	if !h.rejectNonReply(context.Background(), answer(&tgmodels.Message{ID: taskMessageID + 1}), step) {
+		t.Error("Expected a reply to another message to be rejected")
+	}
+
+	step.RequireReply = false
+	if h.rejectNonReply(context.Background(), answer(nil), step) {
+		t.Error("Expected any answer to be accepted when replies are not required")
+	}
+}
+
+func TestRejectImageConstraints(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const userID = 42
+
+	chatStateRepo := db.NewChatStateRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:        b,
+		msgManager: services.NewMessageManager(b, chatStateRepo, nil),
+		media:      services.NewMediaService(b),
+	}
+
+	photo := func(width, height int) *tgmodels.Message {
+		return &tgmodels.Message{
+			ID:   200,
+			From: &tgmodels.User{ID: userID},
+			Chat: tgmodels.Chat{ID: userID},
+			Photo: []tgmodels.PhotoSize{
+				{FileID: "thumb", Width: width / 10, Height: height / 10, FileSize: 1_000},
+				{FileID: "photo", Width: width, Height: height, FileSize: 150_000},
+			},
+		}
+	}
+	step := &models.Step{ID: 1, AnswerType: models.AnswerTypeImage, ImageConstraints: "min=800x600;ratio=4:3"}
+
+	if h.rejectImageConstraints(context.Background(), photo(1600, 1200), step) {
+		t.Error("Expected a conforming photo to be accepted")
+	}
+	if calls := recorded(); len(calls) != 0 {
+		t.Errorf("Expected no guidance for an accepted photo, got %+v", calls)
+	}
+
+	if !h.rejectImageConstraints(context.Background(), photo(640, 480), step) {
+		t.Error("Expected an undersized photo to be rejected")
+	}
+	calls := recorded()
+	if len(calls) != 1 || !strings.Contains(calls[0].text, "640×480") || !strings.Contains(calls[0].text, "не меньше 800×600") {
+		t.Errorf("Expected guidance with the required size, got %+v", calls)
+	}
+
+	step.ImageConstraints = ""
+	if h.rejectImageConstraints(context.Background(), photo(640, 480), step) {
+		t.Error("Expected any photo to be accepted without constraints")
+	}
+}
+
+func TestContinueStart_NewAndReturningUser(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const adminID = 1
+	const userID = 42
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	chatStateRepo := db.NewChatStateRepository(queue)
+	b, recorded := newRecordingBot(t)
+	h := &BotHandler{
+		bot:                  b,
+		adminID:              adminID,
+		settingsRepo:         settingsRepo,
+		userRepo:             userRepo,
+		stepRepo:             stepRepo,
+		progressRepo:         progressRepo,
+		chatStateRepo:        chatStateRepo,
+		stateResolver:        services.NewStateResolver(stepRepo, progressRepo, userRepo),
+		statsService:         services.NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+		msgManager:           services.NewMessageManager(b, chatStateRepo, nil),
+		questStateMiddleware: services.NewQuestStateMiddleware(services.NewQuestStateManager(settingsRepo), adminID),
+	}
+
+	if err := settingsRepo.Set("quest_state", string(services.QuestStateRunning)); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.Set("welcome_message", "Добро пожаловать!"); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.Set("resume_message", "С возвращением!"); err != nil {
+		t.Fatal(err)
+	}
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	var stepIDs []int64
+	for i, text := range []string{"Первый вопрос", "Второй вопрос"} {
+		stepID, err := stepRepo.Create(&models.Step{StepOrder: i + 1, Text: text, AnswerType: models.AnswerTypeText, IsActive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stepIDs = append(stepIDs, stepID)
+	}
+
+	start := func() []string {
+		before := len(recorded())
+		h.continueStart(context.Background(), userID, userID)
+		var texts []string
+		for _, call := range recorded()[before:] {
+			if call.method == "sendMessage" {
+				texts = append(texts, call.text)
+			}
+		}
+		return texts
+	}
+
+	texts := start()
+	if len(texts) != 2 || texts[0] != "Добро пожаловать!" || !strings.Contains(texts[1], "Первый вопрос") {
+		t.Errorf("Expected welcome and the first step for a new user, got %q", texts)
+	}
+
+	if err := progressRepo.Update(&models.UserProgress{UserID: userID, StepID: stepIDs[0], Status: models.StatusApproved}); err != nil {
+		t.Fatal(err)
+	}
+
+	texts = start()
+	if len(texts) != 2 || texts[0] != "С возвращением!" || !strings.Contains(texts[1], "Второй вопрос") {
+		t.Errorf("Expected the resume note and the current step for a returning user, got %q", texts)
+	}
+	if progress, _ := progressRepo.GetByUserAndStep(userID, stepIDs[0]); progress == nil || progress.Status != models.StatusApproved {
+		t.Errorf("Expected progress to be kept on /start, got %+v", progress)
+	}
+
+	if err := settingsRepo.Set("resume_message", ""); err != nil {
+		t.Fatal(err)
+	}
+	texts = start()
+	if len(texts) != 1 || !strings.Contains(texts[0], "Второй вопрос") {
+		t.Errorf("Expected only the current step without a resume note, got %q", texts)
+	}
+}
+
+func TestRecordMatchRule(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	h := &BotHandler{settingsRepo: settingsRepo, answerRepo: answerRepo}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: 42, FirstName: "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	stepID, err := stepRepo.Create(&models.Step{StepOrder: 1, Text: "Вопрос", AnswerType: models.AnswerTypeText, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedRule := func(answerID int64) string {
+		t.Helper()
+		history, err := answerRepo.GetUserAnswerHistory(42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, answer := range history {
+			if answer.ID == answerID {
+				return answer.MatchRule
+			}
+		}
+		t.Fatalf("Answer %d not found", answerID)
+		return ""
+	}
+
+	answerID, err := answerRepo.CreateTextAnswer(42, stepID, "москва", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.recordMatchRule(answerID, &services.CheckResult{IsCorrect: true, MatchKind: services.MatchKindExact})
+	if rule := storedRule(answerID); rule != "" {
+		t.Errorf("Expected no match rule while logging is disabled, got %q", rule)
+	}
+
+	if err := settingsRepo.SetMatchRuleLogging(true); err != nil {
+		t.Fatal(err)
+	}
+	h.recordMatchRule(answerID, &services.CheckResult{IsCorrect: true, MatchKind: services.MatchKindExact})
+	if rule := storedRule(answerID); rule != "exact" {
+		t.Errorf("Expected exact match rule, got %q", rule)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// mediaGroupFlushDelay — сколько ждать остальные фотографии альбома после последней полученной
+var mediaGroupFlushDelay = time.Second
+
+type mediaGroupPhoto struct {
+	messageID int
+	fileID    string
+}
+
+// mediaGroupBuffer собирает фотографии альбома. Telegram присылает каждую фотографию отдельным
+// обновлением с общим media_group_id, и обновления обрабатываются параллельно, поэтому без буфера
+// фотографии сохранялись бы вперемешку и затирали друг друга. Альбом передаётся в flush одним
+// списком в порядке сообщений, когда новые фотографии перестают приходить
+type mediaGroupBuffer struct {
+	mu     sync.Mutex
+	groups map[string]*pendingMediaGroup
+}
+
+type pendingMediaGroup struct {
+	photos []mediaGroupPhoto
+	timer  *time.Timer
+}
+
+func (b *mediaGroupBuffer) add(groupID string, photo mediaGroupPhoto, flush func([]mediaGroupPhoto)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.groups == nil {
+		b.groups = make(map[string]*pendingMediaGroup)
+	}
+
+	if group, ok := b.groups[groupID]; ok {
+		group.photos = append(group.photos, photo)
+		group.timer.Reset(mediaGroupFlushDelay)
+		return
+	}
+
+	group := &pendingMediaGroup{photos: []mediaGroupPhoto{photo}}
+	group.timer = time.AfterFunc(mediaGroupFlushDelay, func() {
+		b.mu.Lock()
+		if b.groups[groupID] != group {
+			// таймер перезапустили в момент срабатывания, альбом уже отправлен
+			b.mu.Unlock()
+			return
+		}
+		photos := group.photos
+		delete(b.groups, groupID)
+		b.mu.Unlock()
+
+		sort.Slice(photos, func(i, j int) bool { return photos[i].messageID < photos[j].messageID })
+		flush(photos)
+	})
+	b.groups[groupID] = group
+}
@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/metrics"
+)
+
+// MetricsHandler отдаёт метрики бота в текстовом формате Prometheus
+type MetricsHandler struct {
+	registry     *metrics.Registry
+	userRepo     *db.UserRepository
+	progressRepo *db.ProgressRepository
+}
+
+func NewMetricsHandler(registry *metrics.Registry, userRepo *db.UserRepository, progressRepo *db.ProgressRepository) *MetricsHandler {
+	return &MetricsHandler{
+		registry:     registry,
+		userRepo:     userRepo,
+		progressRepo: progressRepo,
+	}
+}
+
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	writeMetric(w, "quest_updates_processed_total", "counter", "Telegram updates processed by the bot.", h.registry.UpdatesProcessed.Value())
+	writeMetric(w, "quest_answers_checked_total", "counter", "Answers checked automatically.", h.registry.AnswersChecked.Value())
+	writeMetric(w, "quest_achievements_awarded_total", "counter", "Achievements awarded to users.", h.registry.AchievementsAwarded.Value())
+
+	users, err := h.userRepo.GetAll()
+	if err != nil {
+		log.Printf("[METRICS] failed to count users: %v", err)
+	} else {
+		writeMetric(w, "quest_users_total", "gauge", "Registered users.", int64(len(users)))
+	}
+
+	inProgress, err := h.progressRepo.CountInProgressUsers()
+	if err != nil {
+		log.Printf("[METRICS] failed to count users in progress: %v", err)
+	} else {
+		writeMetric(w, "quest_users_in_progress", "gauge", "Users who have started but not finished the quest.", int64(inProgress))
+	}
+}
+
+func writeMetric(w io.Writer, name, metricType, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, metricType, name, value)
+}
+
+// NewMux возвращает HTTP-маршруты бота
+func NewMux(metricsHandler *MetricsHandler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler)
+	return mux
+}
@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/metrics"
+	"github.com/ad/go-telegram-quest/internal/models"
+	_ "modernc.org/sqlite"
+)
+
+func setupMetricsTestDB(t *testing.T) (*db.DBQueue, func()) {
+	sqlDB, err := sql.Open("sqlite", "file:httpapi_metrics_test?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitSchema(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+	queue := db.NewDBQueue(sqlDB)
+	return queue, func() {
+		queue.Close()
+		sqlDB.Close()
+	}
+}
+
+func scrape(t *testing.T, server *httptest.Server) string {
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	queue, cleanup := setupMetricsTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+
+	stepID, err := stepRepo.Create(&models.Step{StepOrder: 1, Text: "step", AnswerType: models.AnswerTypeText, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []int64{1, 2} {
+		if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "User"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := progressRepo.Create(&models.UserProgress{UserID: 1, StepID: stepID, Status: models.StatusPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := &metrics.Registry{}
+	server := httptest.NewServer(NewMux(NewMetricsHandler(registry, userRepo, progressRepo)))
+	defer server.Close()
+
+	body := scrape(t, server)
+	for _, line := range []string{
+		"# TYPE quest_updates_processed_total counter",
+		"quest_updates_processed_total 0",
+		"quest_answers_checked_total 0",
+		"quest_achievements_awarded_total 0",
+		"# TYPE quest_users_total gauge",
+		"quest_users_total 2",
+		"quest_users_in_progress 1",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in metrics output:\n%s", line, body)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		registry.UpdatesProcessed.Inc()
+	}
+	registry.AnswersChecked.Inc()
+	registry.AchievementsAwarded.Inc()
+
+	body = scrape(t, server)
+	for name, want := range map[string]int{
+		"quest_updates_processed_total":    3,
+		"quest_answers_checked_total":      1,
+		"quest_achievements_awarded_total": 1,
+	} {
+		if line := fmt.Sprintf("%s %d\n", name, want); !strings.Contains(body, line) {
+			t.Errorf("Expected %q after simulated events, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestAchievementAwardIncrementsDefaultRegistry(t *testing.T) {
+	queue, cleanup := setupMetricsTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	achievementRepo := db.NewAchievementRepository(queue)
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: 10, FirstName: "User"}); err != nil {
+		t.Fatal(err)
+	}
+	achievement, err := achievementRepo.GetByKey("pioneer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := metrics.Default.AchievementsAwarded.Value()
+	for i := 0; i < 2; i++ {
+		if err := achievementRepo.AssignToUser(10, achievement.ID, achievement.CreatedAt, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := metrics.Default.AchievementsAwarded.Value() - before; got != 1 {
+		t.Errorf("Expected a repeated award to be counted once, got %d", got)
+	}
+}
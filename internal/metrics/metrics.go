@@ -0,0 +1,26 @@
+package metrics
+
+import "sync/atomic"
+
+// Counter — монотонно растущий счётчик, безопасный для конкурентного использования
+type Counter struct {
+	value atomic.Int64
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+// Registry — набор счётчиков бота, которые отдаются в /metrics
+type Registry struct {
+	UpdatesProcessed    Counter
+	AnswersChecked      Counter
+	AchievementsAwarded Counter
+}
+
+// Default — общий реестр, в который пишут сервисы и обработчики
+var Default = &Registry{}
@@ -50,6 +50,13 @@ type AchievementConditions struct {
 	AsteriskAnswered      *bool    `json:"asterisk_answered,omitempty"`
 	MessageToAdmin        *bool    `json:"message_to_admin,omitempty"`
 	MessageFromAdmin      *bool    `json:"message_from_admin,omitempty"`
+	ComebackDays          *int     `json:"comeback_days,omitempty"`
+	LocalHourFrom         *int     `json:"local_hour_from,omitempty"`
+	LocalHourTo           *int     `json:"local_hour_to,omitempty"`
+	StreakDays            *int     `json:"streak_days,omitempty"`
+	AllAsteriskAnswered   *bool    `json:"all_asterisk_answered,omitempty"`
+	ReferralCount         *int     `json:"referral_count,omitempty"`
+	UsefulReportCount     *int     `json:"useful_report_count,omitempty"`
 }
 
 func (c *AchievementConditions) ToJSON() (string, error) {
@@ -79,6 +86,8 @@ type Achievement struct {
 	Conditions  AchievementConditions
 	CreatedAt   time.Time
 	IsActive    bool
+	// NotifyOnAward — отправлять ли участнику уведомление и стикер при получении
+	NotifyOnAward bool
 }
 
 type UserAchievement struct {
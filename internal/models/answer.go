@@ -9,7 +9,10 @@ type UserAnswer struct {
 	TextAnswer string
 	Images     []AnswerImage
 	HintUsed   bool
-	CreatedAt  time.Time
+	// MatchRule — правило, по которому ответ был засчитан (exact, normalized, synonym…)
+	// или «none»; пусто, если правило не сохранялось
+	MatchRule string
+	CreatedAt time.Time
 }
 
 type AnswerImage struct {
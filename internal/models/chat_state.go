@@ -8,4 +8,8 @@ type ChatState struct {
 	HintMessageID           int
 	CurrentStepHintUsed     bool
 	AwaitingNextStep        bool
+	// LastBotMessageID и LastBotMessageText — последнее текстовое сообщение бота,
+	// по которому MessageManager распознаёт повтор того же текста подряд
+	LastBotMessageID   int
+	LastBotMessageText string
 }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PendingNotification — сообщение участнику, которое не удалось доставить из-за временной
+// ошибки и которое будет отправлено повторно. Payload зависит от Kind: ключ достижения,
+// ID шага и т. п.
+type PendingNotification struct {
+	ID        int64
+	UserID    int64
+	Kind      string
+	Payload   string
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+}
@@ -7,4 +7,13 @@ type UserProgress struct {
 	StepID      int64
 	Status      ProgressStatus
 	CompletedAt *time.Time
+	// ReviewReason — причина, указанная администратором при отклонении ответа
+	ReviewReason string
+}
+
+// PendingReview описывает ответ, ожидающий ручной проверки, и время его отправки
+type PendingReview struct {
+	UserID      int64
+	StepID      int64
+	SubmittedAt time.Time
 }
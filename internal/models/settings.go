@@ -1,10 +1,84 @@
 package models
 
+import "strings"
+
+type MessageParseMode string
+
+const (
+	MessageParseModeHTML     MessageParseMode = "html"
+	MessageParseModeMarkdown MessageParseMode = "markdown"
+	MessageParseModePlain    MessageParseMode = "plain"
+)
+
 type Settings struct {
-	WelcomeMessage       string
+	WelcomeMessage string
+	// ResumeMessage — приветствие участника, который вернулся в квест командой /start;
+	// пустая строка — шаг отправляется заново без приветствия
+	ResumeMessage        string
 	FinalMessage         string
 	CorrectAnswerMessage string
 	WrongAnswerMessage   string
 	RequiredGroupChatID  int64
 	GroupChatInviteLink  string
+	ParseModes           map[string]MessageParseMode
+	ScoringEnabled       bool
+	ScorePointsPerStep   int
+	ScoreHintPenalty     int
+	AnswerSummaryEnabled bool
+	ShareEnabled         bool
+	ShareMessage         string
+	RulesMessage         string
+	RulesVersion         int
+	// CorrectAnswerMessages — сообщения о правильном ответе на других языках: код языка → текст.
+	// Хранятся в настройках с ключами correct_answer_message_<код>
+	CorrectAnswerMessages map[string]string
+}
+
+// CorrectAnswerMessagePrefix — префикс ключей настроек с сообщением о правильном ответе на языке
+const CorrectAnswerMessagePrefix = "correct_answer_message_"
+
+// CorrectAnswerMessageKey возвращает ключ сообщения о правильном ответе для языка совпавшего
+// варианта. Если для языка сообщение не задано, используется общее сообщение
+func (s *Settings) CorrectAnswerMessageKey(language string) string {
+	if language != "" && s != nil && s.CorrectAnswerMessages[language] != "" {
+		return CorrectAnswerMessagePrefix + language
+	}
+	return "correct_answer_message"
+}
+
+func (s *Settings) Message(key string) string {
+	if s == nil {
+		return ""
+	}
+	switch key {
+	case "welcome_message":
+		return s.WelcomeMessage
+	case "resume_message":
+		return s.ResumeMessage
+	case "final_message":
+		return s.FinalMessage
+	case "correct_answer_message":
+		return s.CorrectAnswerMessage
+	case "wrong_answer_message":
+		return s.WrongAnswerMessage
+	case "share_message":
+		return s.ShareMessage
+	case "rules_message":
+		return s.RulesMessage
+	}
+	if language, ok := strings.CutPrefix(key, CorrectAnswerMessagePrefix); ok {
+		return s.CorrectAnswerMessages[language]
+	}
+	return ""
+}
+
+func (s *Settings) ParseModeFor(key string) MessageParseMode {
+	if s == nil || s.ParseModes == nil {
+		return MessageParseModeHTML
+	}
+	switch mode := s.ParseModes[key]; mode {
+	case MessageParseModeMarkdown, MessageParseModePlain:
+		return mode
+	}
+	return MessageParseModeHTML
 }
@@ -14,18 +14,118 @@ type Step struct {
 	CorrectAnswerImage string
 	Images             []StepImage
 	Answers            []string
-	HintText           string
-	HintImage          string
-	CreatedAt          time.Time
+	// AnswerLanguages — язык вариантов ответа двуязычного квеста: вариант → код языка.
+	// Варианты без языка в карту не попадают
+	AnswerLanguages map[string]string
+	Choices         []StepChoice
+	Documents       []StepDocument
+	HintText        string
+	HintImage       string
+	LocationLat     float64
+	LocationLng     float64
+	LocationRadius  int
+	RequiredAnswers int
+	ChapterID       int64
+	NumericFeedback bool
+	OrderedAnswers  bool
+	UseSynonyms     bool
+	// LinkDomain — домен, на который должна вести ссылка в ответе шага типа «ссылка»;
+	// пустая строка принимает ссылку на любой сайт
+	LinkDomain string
+	// RequireReply — ответ принимается, только если участник ответил реплаем
+	// на сообщение с заданием
+	RequireReply bool
+	// ImageConstraints — ограничения на разрешение фото в ответе шага типа «изображение»
+	// в виде «min=800x600;max=4000x4000;ratio=4:3»; пустая строка — без ограничений
+	ImageConstraints string
+	CreatedAt        time.Time
 }
 
 func (s *Step) HasHint() bool {
 	return s.HintText != "" || s.HintImage != ""
 }
 
+// Choice возвращает вариант выбора шага по его ID или nil, если такого нет
+func (s *Step) Choice(choiceID int64) *StepChoice {
+	for i := range s.Choices {
+		if s.Choices[i].ID == choiceID {
+			return &s.Choices[i]
+		}
+	}
+	return nil
+}
+
 type StepImage struct {
+	ID        int64
+	StepID    int64
+	FileID    string
+	MediaType string
+	Position  int
+	// Разрешение и размер файла; 0, если неизвестны (медиа загружено до их сохранения)
+	Width    int
+	Height   int
+	FileSize int64
+}
+
+// Типы медиа задания: фотография или анимация (GIF)
+const (
+	MediaTypePhoto     = "photo"
+	MediaTypeAnimation = "animation"
+)
+
+func (i StepImage) IsAnimation() bool {
+	return i.MediaType == MediaTypeAnimation
+}
+
+// StepChoice — вариант ответа на шаге с выбором, показывается участнику кнопкой
+type StepChoice struct {
+	ID        int64
+	StepID    int64
+	Text      string
+	IsCorrect bool
+	Position  int
+}
+
+// StepDocument — файл, который отправляется участнику вместе с заданием шага
+type StepDocument struct {
 	ID       int64
 	StepID   int64
 	FileID   string
+	FileName string
 	Position int
 }
+
+// Chapter — именованная глава, объединяющая шаги длинного квеста
+type Chapter struct {
+	ID       int64
+	Title    string
+	Position int
+}
+
+// ChapterProgress — прохождение главы участником: сколько активных шагов главы
+// одобрено или пропущено из общего числа
+type ChapterProgress struct {
+	Chapter   Chapter
+	Number    int
+	Completed int
+	Total     int
+}
+
+func (p ChapterProgress) IsCompleted() bool {
+	return p.Total > 0 && p.Completed == p.Total
+}
+
+// SynonymGroup — каноническая форма ответа и её синонимы из общего словаря.
+// ID — идентификатор первой записи группы, по нему группа удаляется
+type SynonymGroup struct {
+	ID        int64
+	Canonical string
+	Synonyms  []string
+}
+
+// DuplicateAnswer — вариант ответа, который задан сразу у нескольких шагов
+type DuplicateAnswer struct {
+	Answer     string
+	StepIDs    []int64
+	StepOrders []int
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// StepReport — сообщение пользователя о проблеме с шагом
+type StepReport struct {
+	ID        int64
+	UserID    int64
+	StepID    int64
+	StepOrder int
+	Text      string
+	CreatedAt time.Time
+	// IsUseful — администратор отметил жалобу полезной; такие жалобы засчитываются в «Помощника»
+	IsUseful bool
+}
@@ -2,9 +2,19 @@ package models
 
 import "time"
 
+type StickerPackStatus string
+
+const (
+	StickerPackStatusOK     StickerPackStatus = "ok"
+	StickerPackStatusFailed StickerPackStatus = "failed"
+)
+
 type UserStickerPack struct {
 	ID        int64
 	UserID    int64
 	PackName  string
+	Status    StickerPackStatus
+	Attempts  int
+	LastError string
 	CreatedAt time.Time
 }
@@ -3,8 +3,11 @@ package models
 type AnswerType string
 
 const (
-	AnswerTypeText  AnswerType = "text"
-	AnswerTypeImage AnswerType = "image"
+	AnswerTypeText     AnswerType = "text"
+	AnswerTypeImage    AnswerType = "image"
+	AnswerTypeLocation AnswerType = "location"
+	AnswerTypeChoice   AnswerType = "choice"
+	AnswerTypeLink     AnswerType = "link"
 )
 
 type ProgressStatus string
@@ -0,0 +1,9 @@
+package models
+
+// UnlockCode — промокод, открывающий доступ к шагу или ко всем шагам главы.
+// Заполнен ровно один из StepID и ChapterID
+type UnlockCode struct {
+	Code      string
+	StepID    int64
+	ChapterID int64
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -12,13 +14,83 @@ import (
 	"github.com/ad/go-telegram-quest/internal/models"
 )
 
+// AchievementEvaluationMode — объём проверки достижений после одобренного ответа
+type AchievementEvaluationMode string
+
+const (
+	// EvaluationModeFull проверяет после каждого ответа все категории, связанные с ответом
+	EvaluationModeFull AchievementEvaluationMode = "full"
+	// EvaluationModeTargeted проверяет только то, что мог изменить именно этот ответ:
+	// достигнутый порог прогресса, место по первому ответу, звёздочку шага.
+	// Достижения за подсказки проверяются при использовании подсказки
+	EvaluationModeTargeted AchievementEvaluationMode = "targeted"
+)
+
+// ParseEvaluationMode разбирает режим проверки достижений; пустое значение — полный режим
+func ParseEvaluationMode(value string) (AchievementEvaluationMode, error) {
+	switch AchievementEvaluationMode(strings.ToLower(strings.TrimSpace(value))) {
+	case "", EvaluationModeFull:
+		return EvaluationModeFull, nil
+	case EvaluationModeTargeted:
+		return EvaluationModeTargeted, nil
+	default:
+		return "", fmt.Errorf("unknown achievement evaluation mode %q", value)
+	}
+}
+
+// ThresholdMode — способ задать пороги достижений за правильные ответы и подсказки
+type ThresholdMode string
+
+const (
+	// ThresholdModeFixed использует пороги ProgressThresholds и HintThresholds как есть
+	ThresholdModeFixed ThresholdMode = "fixed"
+	// ThresholdModePercent считает пороги от числа активных шагов по ProgressThresholdPercents
+	// и HintThresholdPercents, чтобы достижения работали и в коротких квестах
+	ThresholdModePercent ThresholdMode = "percent"
+)
+
+// ParseThresholdMode разбирает способ задать пороги; пустое значение — фиксированные пороги
+func ParseThresholdMode(value string) (ThresholdMode, error) {
+	switch ThresholdMode(strings.ToLower(strings.TrimSpace(value))) {
+	case "", ThresholdModeFixed:
+		return ThresholdModeFixed, nil
+	case ThresholdModePercent:
+		return ThresholdModePercent, nil
+	default:
+		return "", fmt.Errorf("unknown achievement threshold mode %q", value)
+	}
+}
+
 type AchievementEngine struct {
 	achievementRepo *db.AchievementRepository
 	userRepo        *db.UserRepository
 	progressRepo    *db.ProgressRepository
 	stepRepo        *db.StepRepository
+	referralRepo    *db.ReferralRepository
+	stepReportRepo  *db.StepReportRepository
+	statsService    *StatisticsService
 	queue           *db.DBQueue
 	uniqueMutex     sync.Mutex
+
+	evaluationMode     AchievementEvaluationMode
+	evaluationDebounce time.Duration
+	lastFullEvaluation map[int64]time.Time
+	evaluationMutex    sync.Mutex
+
+	flawlessTimeLimit int
+	thresholdMode     ThresholdMode
+	settingsRepo      *db.SettingsRepository
+
+	winnerPlaceListener func([]WinnerPlaceChange)
+}
+
+// WinnerPlaceChange описывает пересмотр места по завершению квеста: достижение отозвано
+// у Revoked (о нём они уже получили уведомление) и выдано Awarded. Awarded равен 0, если
+// место никому не выдано или досталось оцениваемому участнику — о нём сообщает вызывающий
+type WinnerPlaceChange struct {
+	AchievementKey string
+	Revoked        []int64
+	Awarded        int64
 }
 
 func NewAchievementEngine(
@@ -29,12 +101,106 @@ func NewAchievementEngine(
 	queue *db.DBQueue,
 ) *AchievementEngine {
 	return &AchievementEngine{
-		achievementRepo: achievementRepo,
-		userRepo:        userRepo,
-		progressRepo:    progressRepo,
-		stepRepo:        stepRepo,
-		queue:           queue,
+		achievementRepo:   achievementRepo,
+		userRepo:          userRepo,
+		progressRepo:      progressRepo,
+		stepRepo:          stepRepo,
+		referralRepo:      db.NewReferralRepository(queue),
+		stepReportRepo:    db.NewStepReportRepository(queue),
+		statsService:      NewStatisticsService(queue, stepRepo, progressRepo, userRepo),
+		queue:             queue,
+		evaluationMode:    EvaluationModeFull,
+		flawlessTimeLimit: DefaultFlawlessTimeLimitMinutes,
+		thresholdMode:     ThresholdModeFixed,
+	}
+}
+
+// SetWinnerPlaceListener задаёт получателя пересмотров мест по завершению квеста, чтобы
+// уведомить участников, у которых место отозвано или которым оно передано
+func (e *AchievementEngine) SetWinnerPlaceListener(listener func([]WinnerPlaceChange)) {
+	e.winnerPlaceListener = listener
+}
+
+// SetThresholdMode задаёт способ расчёта порогов достижений за правильные ответы и подсказки
+func (e *AchievementEngine) SetThresholdMode(mode ThresholdMode) {
+	e.thresholdMode = mode
+}
+
+// SetSettingsRepository подключает настройки квеста, например минимальное число шагов
+// для прохождения. Без них квест считается пройденным после всех активных шагов
+func (e *AchievementEngine) SetSettingsRepository(settingsRepo *db.SettingsRepository) {
+	e.settingsRepo = settingsRepo
+}
+
+// requiredCompletionSteps возвращает, сколько одобренных шагов нужно для прохождения
+// квеста из totalSteps активных: минимум из настроек, но не больше totalSteps
+func (e *AchievementEngine) requiredCompletionSteps(totalSteps int) int {
+	if minSteps := e.minCompletionSteps(); minSteps > 0 && minSteps < totalSteps {
+		return minSteps
+	}
+	return totalSteps
+}
+
+// minCompletionSteps возвращает минимальное число шагов для прохождения из настроек
+// или 0, если нужно пройти все шаги
+func (e *AchievementEngine) minCompletionSteps() int {
+	if e.settingsRepo == nil {
+		return 0
+	}
+	minSteps, err := e.settingsRepo.GetMinCompletionSteps()
+	if err != nil {
+		log.Printf("[ACHIEVEMENT_ENGINE] Error getting minimum completion steps: %v", err)
+		return 0
+	}
+	return minSteps
+}
+
+// SetFlawlessTimeLimit задаёт лимит времени прохождения в минутах для достижения «Безупречный»
+func (e *AchievementEngine) SetFlawlessTimeLimit(minutes int) {
+	e.flawlessTimeLimit = minutes
+}
+
+// SetEvaluationMode задаёт режим проверки достижений после одобренного ответа
+func (e *AchievementEngine) SetEvaluationMode(mode AchievementEvaluationMode) {
+	e.evaluationMutex.Lock()
+	defer e.evaluationMutex.Unlock()
+	e.evaluationMode = mode
+}
+
+// SetEvaluationDebounce задаёт интервал, в течение которого после полной проверки
+// следующие ответы участника проверяются точечно. 0 отключает задержку
+func (e *AchievementEngine) SetEvaluationDebounce(debounce time.Duration) {
+	e.evaluationMutex.Lock()
+	defer e.evaluationMutex.Unlock()
+	e.evaluationDebounce = debounce
+}
+
+// useTargetedEvaluation решает, проверять ли ответ точечно: в точечном режиме всегда,
+// в полном — если предыдущая полная проверка участника была позже чем debounce назад
+func (e *AchievementEngine) useTargetedEvaluation(userID int64, answeredAt time.Time) bool {
+	e.evaluationMutex.Lock()
+	defer e.evaluationMutex.Unlock()
+
+	if e.evaluationMode == EvaluationModeTargeted {
+		return true
+	}
+	if e.evaluationDebounce > 0 {
+		if last, ok := e.lastFullEvaluation[userID]; ok && answeredAt.Sub(last) < e.evaluationDebounce {
+			return true
+		}
+		if e.lastFullEvaluation == nil {
+			e.lastFullEvaluation = make(map[int64]time.Time)
+		}
+		// Отметки старше debounce уже не влияют на решение, поэтому убираем их при
+		// каждой полной проверке, чтобы карта не росла вместе с числом участников
+		for id, last := range e.lastFullEvaluation {
+			if answeredAt.Sub(last) >= e.evaluationDebounce {
+				delete(e.lastFullEvaluation, id)
+			}
+		}
+		e.lastFullEvaluation[userID] = answeredAt
 	}
+	return false
 }
 
 func (e *AchievementEngine) EvaluateUserAchievements(userID int64) ([]string, error) {
@@ -318,7 +484,13 @@ func (e *AchievementEngine) getUsersOrderedByFirstCorrectAnswer() ([]UserFirstAn
 	return result.([]UserFirstAnswer), nil
 }
 
+// GetQuestCompletions возвращает участников, прошедших квест, в порядке прохождения
+func (e *AchievementEngine) GetQuestCompletions() ([]UserCompletion, error) {
+	return e.getUsersOrderedByQuestCompletion()
+}
+
 func (e *AchievementEngine) getUsersOrderedByQuestCompletion() ([]UserCompletion, error) {
+	minSteps := e.minCompletionSteps()
 	result, err := e.queue.Execute(func(db *sql.DB) (any, error) {
 		// Debug: check all steps in database
 		debugRows, err := db.Query(`
@@ -342,75 +514,152 @@ func (e *AchievementEngine) getUsersOrderedByQuestCompletion() ([]UserCompletion
 			debugRows.Close()
 		}
 
-		// Get the highest step order that is active and not deleted
-		var maxStepOrder sql.NullInt64
-		err = db.QueryRow(`
-			SELECT MAX(s.step_order) 
-			FROM steps s 
-			WHERE s.is_active = 1 
-			AND (s.is_deleted = 0 OR s.is_deleted IS NULL)
-		`).Scan(&maxStepOrder)
-		if err != nil || !maxStepOrder.Valid {
-			return []UserCompletion{}, nil
-		}
-		// log.Printf("[ACHIEVEMENT_ENGINE] Max active non-deleted step order: %d", maxStepOrder.Int64)
+		return queryQuestCompletions(db, minSteps)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]UserCompletion), nil
+}
 
-		// Get users who completed the last step (quest completion)
-		rows, err := db.Query(`
-			SELECT p.user_id, p.completed_at
-			FROM user_progress p
-			JOIN steps s ON p.step_id = s.id
-			WHERE p.status = 'approved' 
-			AND p.completed_at IS NOT NULL
-			AND s.step_order = ?
-			AND s.is_active = 1
-			AND (s.is_deleted = 0 OR s.is_deleted IS NULL)
-			ORDER BY p.completed_at ASC
-		`, maxStepOrder.Int64)
-		if err != nil {
+// queryQuestCompletions возвращает участников, прошедших квест, в порядке прохождения:
+// прошедших последний активный шаг или, если minSteps меньше числа активных шагов,
+// одобривших minSteps активных шагов. Участники, ещё не подтвердившие завершение,
+// не учитываются
+func queryQuestCompletions(db *sql.DB, minSteps int) ([]UserCompletion, error) {
+	// Get the highest step order that is active and not deleted
+	var maxStepOrder sql.NullInt64
+	var totalSteps int
+	err := db.QueryRow(`
+		SELECT MAX(s.step_order), COUNT(*)
+		FROM steps s 
+		WHERE s.is_active = 1 
+		AND (s.is_deleted = 0 OR s.is_deleted IS NULL)
+	`).Scan(&maxStepOrder, &totalSteps)
+	if err != nil || !maxStepOrder.Valid {
+		return []UserCompletion{}, nil
+	}
+	if minSteps > 0 && minSteps < totalSteps {
+		return queryMinStepsCompletions(db, minSteps)
+	}
+	// log.Printf("[ACHIEVEMENT_ENGINE] Max active non-deleted step order: %d", maxStepOrder.Int64)
+
+	// Get users who completed the last step (quest completion)
+	rows, err := db.Query(`
+		SELECT p.user_id, p.completed_at
+		FROM user_progress p
+		JOIN steps s ON p.step_id = s.id
+		WHERE p.status = 'approved' 
+		AND p.completed_at IS NOT NULL
+		AND s.step_order = ?
+		AND s.is_active = 1
+		AND (s.is_deleted = 0 OR s.is_deleted IS NULL)
+		AND p.user_id NOT IN (SELECT id FROM users WHERE pending_completion = TRUE)
+		ORDER BY p.completed_at ASC
+	`, maxStepOrder.Int64)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []UserCompletion
+	for rows.Next() {
+		var u UserCompletion
+		var completedAtStr string
+		if err := rows.Scan(&u.UserID, &completedAtStr); err != nil {
 			return nil, err
 		}
-		defer rows.Close()
-
-		var users []UserCompletion
-		for rows.Next() {
-			var u UserCompletion
-			var completedAtStr string
-			if err := rows.Scan(&u.UserID, &completedAtStr); err != nil {
-				return nil, err
-			}
-			parsedTime, err := time.Parse("2006-01-02 15:04:05.999999999-07:00", completedAtStr)
+		parsedTime, err := time.Parse("2006-01-02 15:04:05.999999999-07:00", completedAtStr)
+		if err != nil {
+			parsedTime, err = time.Parse("2006-01-02T15:04:05Z", completedAtStr)
 			if err != nil {
-				parsedTime, err = time.Parse("2006-01-02T15:04:05Z", completedAtStr)
+				parsedTime, err = time.Parse("2006-01-02 15:04:05", completedAtStr)
 				if err != nil {
-					parsedTime, err = time.Parse("2006-01-02 15:04:05", completedAtStr)
-					if err != nil {
-						parsedTime, _ = time.Parse(time.RFC3339, completedAtStr)
-					}
+					parsedTime, _ = time.Parse(time.RFC3339, completedAtStr)
 				}
 			}
-			u.CompletionTime = parsedTime
-			users = append(users, u)
-			// log.Printf("[ACHIEVEMENT_ENGINE] Found completed user: %d at %v", u.UserID, u.CompletionTime)
 		}
-		// log.Printf("[ACHIEVEMENT_ENGINE] Total users who completed quest: %d", len(users))
-		return users, rows.Err()
-	})
+		u.CompletionTime = parsedTime
+		users = append(users, u)
+		// log.Printf("[ACHIEVEMENT_ENGINE] Found completed user: %d at %v", u.UserID, u.CompletionTime)
+	}
+	// log.Printf("[ACHIEVEMENT_ENGINE] Total users who completed quest: %d", len(users))
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortCompletions(users)
+	return users, nil
+}
+
+// queryMinStepsCompletions возвращает участников, одобривших не меньше minSteps активных шагов.
+// Временем прохождения считается одобрение minSteps-го шага
+func queryMinStepsCompletions(db *sql.DB, minSteps int) ([]UserCompletion, error) {
+	rows, err := db.Query(`
+		SELECT p.user_id, p.completed_at
+		FROM user_progress p
+		JOIN steps s ON p.step_id = s.id
+		WHERE p.status = 'approved'
+		AND p.completed_at IS NOT NULL
+		AND s.is_active = 1
+		AND (s.is_deleted = 0 OR s.is_deleted IS NULL)
+		AND p.user_id NOT IN (SELECT id FROM users WHERE pending_completion = TRUE)
+	`)
 	if err != nil {
 		return nil, err
 	}
-	return result.([]UserCompletion), nil
+	defer rows.Close()
+
+	approvals := make(map[int64][]time.Time)
+	for rows.Next() {
+		var userID int64
+		var completedAtStr string
+		if err := rows.Scan(&userID, &completedAtStr); err != nil {
+			return nil, err
+		}
+		completedAt, _ := parseTimeString(completedAtStr)
+		approvals[userID] = append(approvals[userID], completedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var users []UserCompletion
+	for userID, times := range approvals {
+		if len(times) < minSteps {
+			continue
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		users = append(users, UserCompletion{UserID: userID, CompletionTime: times[minSteps-1]})
+	}
+	sortCompletions(users)
+	return users, nil
+}
+
+// sortCompletions упорядочивает прохождения по времени. completed_at хранится строкой
+// в разных форматах и часовых поясах, поэтому ORDER BY в запросе не гарантирует порядок
+// по времени — сортируем по разобранному времени
+func sortCompletions(users []UserCompletion) {
+	sort.SliceStable(users, func(i, j int) bool {
+		if !users[i].CompletionTime.Equal(users[j].CompletionTime) {
+			return users[i].CompletionTime.Before(users[j].CompletionTime)
+		}
+		return users[i].UserID < users[j].UserID
+	})
 }
 
 func (e *AchievementEngine) evaluateConditions(userID int64, achievement *models.Achievement) (bool, error) {
 	conditions := achievement.Conditions
 
 	if conditions.CorrectAnswers != nil {
+		threshold, err := e.conditionThreshold(achievement.Key, *conditions.CorrectAnswers)
+		if err != nil {
+			return false, err
+		}
 		count, err := e.getCorrectAnswersCount(userID)
 		if err != nil {
 			return false, err
 		}
-		if count < *conditions.CorrectAnswers {
+		if count < threshold {
 			return false, nil
 		}
 	}
@@ -451,11 +700,15 @@ func (e *AchievementEngine) evaluateConditionsWithTimestamp(userID int64, achiev
 	earnedAt := time.Now()
 
 	if conditions.CorrectAnswers != nil {
-		count, timestamp, err := e.getCorrectAnswersCountWithTimestamp(userID, *conditions.CorrectAnswers)
+		threshold, err := e.conditionThreshold(achievement.Key, *conditions.CorrectAnswers)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		count, timestamp, err := e.getCorrectAnswersCountWithTimestamp(userID, threshold)
 		if err != nil {
 			return false, time.Time{}, err
 		}
-		if count < *conditions.CorrectAnswers {
+		if count < threshold {
 			return false, time.Time{}, nil
 		}
 		if !timestamp.IsZero() {
@@ -528,11 +781,15 @@ func (e *AchievementEngine) evaluateConditionsWithTimestamp(userID int64, achiev
 	}
 
 	if conditions.HintCount != nil {
-		count, timestamp, err := e.getHintCountWithTimestamp(userID, *conditions.HintCount)
+		threshold, err := e.conditionThreshold(achievement.Key, *conditions.HintCount)
 		if err != nil {
 			return false, time.Time{}, err
 		}
-		if count < *conditions.HintCount {
+		count, timestamp, err := e.getHintCountWithTimestamp(userID, threshold)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		if count < threshold {
 			return false, time.Time{}, nil
 		}
 		if !timestamp.IsZero() {
@@ -788,97 +1045,149 @@ func (e *AchievementEngine) EvaluatePositionBasedAchievements(userID int64) ([]s
 	return awarded, nil
 }
 
+// EvaluateWinnerAchievements выдаёт участнику достижение за место по завершению квеста.
+// Одновременные завершения обрабатываются под uniqueMutex, а порядок и держатели мест
+// перечитываются внутри блокировки. Места определяются временем завершения, а не порядком
+// оценки: если участник, завершивший раньше, оценивается позже (например, его ответ
+// записался позже), уже выданные места сдвигаются, а о сдвиге сообщается получателю из
+// SetWinnerPlaceListener. Места участников, которых ещё не оценивали, остаются свободными —
+// их выдаст собственная оценка, чтобы участник получил уведомление
 func (e *AchievementEngine) EvaluateWinnerAchievements(userID int64) ([]string, error) {
+	awarded, changes, err := e.assignWinnerPlaces(userID)
+	if len(changes) > 0 && e.winnerPlaceListener != nil {
+		e.winnerPlaceListener(changes)
+	}
+	return awarded, err
+}
+
+// assignWinnerPlaces выдаёт и сдвигает места под uniqueMutex и возвращает выданные
+// участнику userID достижения и пересмотры мест, затронувшие других участников
+func (e *AchievementEngine) assignWinnerPlaces(userID int64) ([]string, []WinnerPlaceChange, error) {
 	e.uniqueMutex.Lock()
 	defer e.uniqueMutex.Unlock()
 
-	// Check if user already has any winner achievement
-	for pos := 1; pos <= 3; pos++ {
-		achievementKey := WinnerAchievementKeys[pos]
-		hasAchievement, err := e.achievementRepo.HasUserAchievement(userID, achievementKey)
-		if err != nil {
-			log.Printf("[ACHIEVEMENT_ENGINE] Error checking if user %d has %s: %v", userID, achievementKey, err)
-			continue
-		}
-		if hasAchievement {
-			// User already has a winner achievement, don't award another
-			return nil, nil
-		}
-	}
-
-	// First check if all winner positions are already taken
-	allPositionsTaken := true
-	for pos := 1; pos <= 3; pos++ {
-		achievementKey := WinnerAchievementKeys[pos]
-		holders, err := e.achievementRepo.GetAchievementHolders(achievementKey)
-		if err != nil {
-			log.Printf("[ACHIEVEMENT_ENGINE] Error getting holders for %s: %v", achievementKey, err)
-			continue
-		}
-		if len(holders) == 0 {
-			allPositionsTaken = false
-			break
-		}
+	winners, err := e.positionalAchievements(completionPlace)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	// If all positions are taken, no new winner achievements can be awarded
-	if allPositionsTaken {
-		return nil, nil
+	if len(winners) == 0 {
+		return nil, nil, nil
 	}
 
 	completedUsers, err := e.getUsersOrderedByQuestCompletion()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	completed := make(map[int64]bool, len(completedUsers))
+	for _, user := range completedUsers {
+		completed[user.UserID] = true
 	}
 
-	var awarded []string
-	for i, user := range completedUsers {
-		if user.UserID != userID {
-			continue
+	places := make([]int, 0, len(winners))
+	holdersByPlace := make(map[int][]int64)
+	evaluated := map[int64]bool{userID: true}
+	for place, achievement := range winners {
+		places = append(places, place)
+		holders, err := e.achievementRepo.GetAchievementHolders(achievement.Key)
+		if err != nil {
+			return nil, nil, err
 		}
-
-		position := i + 1
-		if position > 3 {
-			break
+		holdersByPlace[place] = holders
+		for _, holder := range holders {
+			evaluated[holder] = true
 		}
+	}
+	sort.Ints(places)
 
-		achievementKey, exists := WinnerAchievementKeys[position]
-		if !exists {
-			continue
+	var awarded []string
+	var changes []WinnerPlaceChange
+	for _, place := range places {
+		achievement := winners[place]
+		change := WinnerPlaceChange{AchievementKey: achievement.Key}
+
+		var expected UserCompletion
+		if place <= len(completedUsers) && evaluated[completedUsers[place-1].UserID] {
+			expected = completedUsers[place-1]
 		}
 
-		// Double-check that this position is still available
-		holders, err := e.achievementRepo.GetAchievementHolders(achievementKey)
-		if err != nil {
-			log.Printf("[ACHIEVEMENT_ENGINE] Error getting holders for %s: %v", achievementKey, err)
+		holders := holdersByPlace[place]
+		if len(holders) == 1 && holders[0] == expected.UserID {
 			continue
 		}
-		if len(holders) > 0 {
+		// Держатель, которого нет среди завершивших (например, после добавления нового
+		// последнего шага), сохраняет место: сравнить его время завершения не с чем
+		if slices.ContainsFunc(holders, func(holder int64) bool { return !completed[holder] }) {
 			continue
 		}
 
-		achievement, err := e.achievementRepo.GetByKey(achievementKey)
-		if err != nil {
-			log.Printf("[ACHIEVEMENT_ENGINE] Winner achievement %s not found: %v", achievementKey, err)
-			continue
+		for _, holder := range holders {
+			if holder == expected.UserID {
+				continue
+			}
+			if err := e.achievementRepo.RemoveUserAchievement(holder, achievement.ID); err != nil {
+				return nil, changes, err
+			}
+			change.Revoked = append(change.Revoked, holder)
+			log.Printf("[ACHIEVEMENT_ENGINE] Removed winner achievement %s from user %d: completed later than the place allows", achievement.Key, holder)
 		}
 
-		err = e.achievementRepo.AssignToUser(userID, achievement.ID, user.CompletionTime, false)
-		if err != nil {
-			log.Printf("[ACHIEVEMENT_ENGINE] Error assigning winner achievement %s to user %d: %v", achievementKey, userID, err)
-			continue
+		if expected.UserID != 0 && !slices.Contains(holders, expected.UserID) {
+			if err := e.achievementRepo.AssignToUser(expected.UserID, achievement.ID, expected.CompletionTime, false); err != nil {
+				log.Printf("[ACHIEVEMENT_ENGINE] Error assigning winner achievement %s to user %d: %v", achievement.Key, expected.UserID, err)
+			} else if expected.UserID == userID {
+				awarded = append(awarded, achievement.Key)
+			} else {
+				change.Awarded = expected.UserID
+				log.Printf("[ACHIEVEMENT_ENGINE] Moved winner achievement %s to user %d by completion time", achievement.Key, expected.UserID)
+			}
 		}
 
-		awarded = append(awarded, achievementKey)
-		// log.Printf("[ACHIEVEMENT_ENGINE] Awarded winner achievement %s to user %d (position %d)", achievementKey, userID, position)
-		break
+		if len(change.Revoked) > 0 || change.Awarded != 0 {
+			changes = append(changes, change)
+		}
 	}
 
-	return awarded, nil
+	return awarded, changes, nil
+}
+
+// firstAnswerPlace и completionPlace выбирают из условий достижения место по первому
+// правильному ответу и место по завершению квеста соответственно
+func firstAnswerPlace(conditions models.AchievementConditions) *int {
+	return conditions.Position
+}
+
+func completionPlace(conditions models.AchievementConditions) *int {
+	return conditions.CompletionPosition
+}
+
+// positionalAchievements возвращает активные достижения за место, ключом служит само место.
+// Число призовых мест задаётся определениями достижений: чтобы наградить топ-5,
+// достаточно активных достижений с местами 1–5
+func (e *AchievementEngine) positionalAchievements(place func(models.AchievementConditions) *int) (map[int]*models.Achievement, error) {
+	achievements, err := e.achievementRepo.GetActive()
+	if err != nil {
+		return nil, err
+	}
+
+	byPlace := make(map[int]*models.Achievement)
+	for _, achievement := range achievements {
+		position := place(achievement.Conditions)
+		if position == nil || *position < 1 {
+			continue
+		}
+		if _, exists := byPlace[*position]; !exists {
+			byPlace[*position] = achievement
+		}
+	}
+	return byPlace, nil
 }
 
 var ProgressThresholds = []int{5, 10, 15, 20, 25}
 
+// ProgressThresholdPercents — пороги ProgressThresholds в процентах от числа активных шагов
+// для ThresholdModePercent, по одному на каждый порог
+var ProgressThresholdPercents = []int{20, 40, 60, 80, 100}
+
 var ProgressAchievementKeys = map[int]string{
 	5:  "beginner_5",
 	10: "experienced_10",
@@ -887,26 +1196,105 @@ var ProgressAchievementKeys = map[int]string{
 	25: "master_25",
 }
 
+// WinnerAchievementKeys — достижения за место по завершению квеста из стандартного набора.
+// Оценка победителей опирается на условия CompletionPosition активных достижений
 var WinnerAchievementKeys = map[int]string{
 	1: "winner_1",
 	2: "winner_2",
 	3: "winner_3",
 }
 
+// achievementLevel — порог количества и достижение, которое за него выдаётся
+type achievementLevel struct {
+	threshold int
+	key       string
+}
+
+// ScaleThreshold переводит порог в процентах в число ответов для квеста из activeSteps
+// шагов с округлением вверх. Порог не бывает меньше одного
+func ScaleThreshold(percent, activeSteps int) int {
+	return max((percent*activeSteps+99)/100, 1)
+}
+
+// thresholdLevels возвращает пороги достижений с учётом режима порогов. В режиме процентов
+// пороги пересчитываются от текущего числа активных шагов
+func (e *AchievementEngine) thresholdLevels(thresholds, percents []int, keys map[int]string) ([]achievementLevel, error) {
+	var activeSteps int
+	if e.thresholdMode == ThresholdModePercent {
+		steps, err := e.stepRepo.GetActive()
+		if err != nil {
+			return nil, err
+		}
+		activeSteps = len(steps)
+	}
+
+	levels := make([]achievementLevel, 0, len(thresholds))
+	for i, threshold := range thresholds {
+		level := achievementLevel{threshold: threshold, key: keys[threshold]}
+		if e.thresholdMode == ThresholdModePercent && i < len(percents) {
+			level.threshold = ScaleThreshold(percents[i], activeSteps)
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+func (e *AchievementEngine) progressLevels() ([]achievementLevel, error) {
+	return e.thresholdLevels(ProgressThresholds, ProgressThresholdPercents, ProgressAchievementKeys)
+}
+
+func (e *AchievementEngine) hintLevels() ([]achievementLevel, error) {
+	return e.thresholdLevels(HintThresholds, HintThresholdPercents, HintAchievementKeys)
+}
+
+// conditionThreshold возвращает порог из условий достижения с учётом режима порогов:
+// для достижений за прогресс и подсказки в режиме процентов — пересчитанный порог
+func (e *AchievementEngine) conditionThreshold(achievementKey string, fixed int) (int, error) {
+	if e.thresholdMode != ThresholdModePercent {
+		return fixed, nil
+	}
+	for _, levelsFor := range []func() ([]achievementLevel, error){e.progressLevels, e.hintLevels} {
+		levels, err := levelsFor()
+		if err != nil {
+			return 0, err
+		}
+		for _, level := range levels {
+			if level.key == achievementKey {
+				return level.threshold, nil
+			}
+		}
+	}
+	return fixed, nil
+}
+
 func (e *AchievementEngine) EvaluateProgressAchievements(userID int64) ([]string, error) {
 	correctCount, err := e.getCorrectAnswersCount(userID)
 	if err != nil {
 		return nil, err
 	}
 
+	return e.evaluateProgressForCount(userID, correctCount, false)
+}
+
+// evaluateProgressForCount выдаёт достижения за число правильных ответов. С onlyReached
+// проверяется только порог, которого участник достиг последним ответом
+func (e *AchievementEngine) evaluateProgressForCount(userID int64, correctCount int, onlyReached bool) ([]string, error) {
+	levels, err := e.progressLevels()
+	if err != nil {
+		return nil, err
+	}
+
 	var awarded []string
-	for _, threshold := range ProgressThresholds {
-		if correctCount < threshold {
+	for _, level := range levels {
+		if correctCount < level.threshold {
 			break
 		}
+		if onlyReached && level.threshold != correctCount {
+			continue
+		}
 
-		achievementKey, exists := ProgressAchievementKeys[threshold]
-		if !exists {
+		achievementKey := level.key
+		if achievementKey == "" {
 			continue
 		}
 
@@ -948,9 +1336,14 @@ func (e *AchievementEngine) GetProgressAchievementStatus(userID int64) (map[stri
 		return nil, 0, err
 	}
 
+	levels, err := e.progressLevels()
+	if err != nil {
+		return nil, 0, err
+	}
+
 	status := make(map[string]bool)
-	for _, threshold := range ProgressThresholds {
-		achievementKey := ProgressAchievementKeys[threshold]
+	for _, level := range levels {
+		achievementKey := level.key
 		hasAchievement, err := e.achievementRepo.HasUserAchievement(userID, achievementKey)
 		if err != nil {
 			return nil, 0, err
@@ -967,9 +1360,14 @@ func (e *AchievementEngine) GetNextProgressThreshold(userID int64) (int, string,
 		return 0, "", err
 	}
 
-	for _, threshold := range ProgressThresholds {
-		if correctCount < threshold {
-			return threshold, ProgressAchievementKeys[threshold], nil
+	levels, err := e.progressLevels()
+	if err != nil {
+		return 0, "", err
+	}
+
+	for _, level := range levels {
+		if correctCount < level.threshold {
+			return level.threshold, level.key, nil
 		}
 	}
 
@@ -983,6 +1381,7 @@ var CompletionAchievementKeys = map[string]string{
 	"lightning":       "lightning",
 	"rocket":          "rocket",
 	"cheater":         "cheater",
+	"completionist":   "completionist",
 }
 
 type CompletionStats struct {
@@ -993,8 +1392,39 @@ type CompletionStats struct {
 	CorrectAnswers        int
 	HintsUsed             int
 	CompletionTimeMinutes int
-	FirstAnswerTime       *time.Time
-	LastAnswerTime        *time.Time
+	// CompletionTimeKnown сбрасывается, если время прохождения неправдоподобно
+	// (см. plausibleCompletionTime) — тогда скоростные достижения не выдаются
+	CompletionTimeKnown bool
+	FirstAnswerTime     *time.Time
+	LastAnswerTime      *time.Time
+}
+
+// CompletedWithin сообщает, что квест пройден быстрее limitMinutes минут. Для неизвестного
+// времени прохождения всегда возвращает false
+func (s *CompletionStats) CompletedWithin(limitMinutes int) bool {
+	return s.CompletionTimeKnown && s.CompletionTimeMinutes < limitMinutes
+}
+
+// MaxAnswerClockSkew — насколько время ответа может опережать часы сервера, прежде чем
+// время прохождения будет сочтено неправдоподобным
+const MaxAnswerClockSkew = time.Minute
+
+// plausibleCompletionTime возвращает время прохождения между первым и последним ответом
+// или false, если оно неправдоподобно: время ответа не разобралось, последний ответ раньше
+// первого, ответ из будущего или прохождение длиннее, чем квест вообще идёт (с первого
+// ответа любого участника questStart до now)
+func plausibleCompletionTime(first, last *time.Time, questStart, now time.Time) (time.Duration, bool) {
+	if first == nil || last == nil || first.IsZero() || last.IsZero() {
+		return 0, false
+	}
+	duration := last.Sub(*first)
+	if duration < 0 || last.After(now.Add(MaxAnswerClockSkew)) {
+		return 0, false
+	}
+	if !questStart.IsZero() && duration > now.Add(MaxAnswerClockSkew).Sub(questStart) {
+		return 0, false
+	}
+	return duration, true
 }
 
 func (e *AchievementEngine) GetCompletionStats(userID int64) (*CompletionStats, error) {
@@ -1018,7 +1448,14 @@ func (e *AchievementEngine) GetCompletionStats(userID int64) (*CompletionStats,
 		}
 	}
 
-	stats.IsCompleted = stats.CompletedSteps >= stats.TotalSteps && stats.TotalSteps > 0
+	stats.IsCompleted = stats.TotalSteps > 0 && stats.CompletedSteps >= e.requiredCompletionSteps(stats.TotalSteps)
+	if stats.IsCompleted {
+		pending, err := e.userRepo.IsPendingCompletion(userID)
+		if err != nil {
+			return nil, err
+		}
+		stats.IsCompleted = !pending
+	}
 
 	totalAnswers, hintsUsed, err := e.getUserAnswerStats(userID)
 	if err != nil {
@@ -1035,20 +1472,57 @@ func (e *AchievementEngine) GetCompletionStats(userID int64) (*CompletionStats,
 	stats.LastAnswerTime = lastTime
 
 	if firstTime != nil && lastTime != nil {
-		duration := lastTime.Sub(*firstTime)
-		stats.CompletionTimeMinutes = int(duration.Minutes())
+		questStart, err := e.getQuestStartTime()
+		if err != nil {
+			return nil, err
+		}
+		if duration, ok := plausibleCompletionTime(firstTime, lastTime, questStart, time.Now()); ok {
+			stats.CompletionTimeMinutes = int(duration.Minutes())
+			stats.CompletionTimeKnown = true
+		} else {
+			log.Printf("[ACHIEVEMENT_ENGINE] Ignoring implausible completion time for user %d: first=%v last=%v", userID, firstTime, lastTime)
+		}
 	}
 
 	return stats, nil
 }
 
+// getQuestStartTime возвращает время самого первого ответа в квесте, с учётом ответов,
+// удалённых по сроку хранения. Нулевое время, если ответов ещё нет
+func (e *AchievementEngine) getQuestStartTime() (time.Time, error) {
+	result, err := e.queue.Execute(func(db *sql.DB) (any, error) {
+		var first sql.NullString
+		err := db.QueryRow(`
+			SELECT MIN(first_at) FROM (
+				SELECT MIN(created_at) AS first_at FROM user_answers
+				UNION ALL
+				SELECT MIN(first_answer_at) FROM purged_answer_counts
+			)
+		`).Scan(&first)
+		return first, err
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	first := result.(sql.NullString)
+	if !first.Valid || first.String == "" {
+		return time.Time{}, nil
+	}
+	return parseTimeString(first.String)
+}
+
 func (e *AchievementEngine) getUserAnswerStats(userID int64) (totalAnswers int, hintsUsed int, err error) {
 	result, err := e.queue.Execute(func(db *sql.DB) (any, error) {
 		var total, hints int
+		// Ответы, удалённые по сроку хранения, учитываются по сохранённым счётчикам
 		err := db.QueryRow(`
-			SELECT COUNT(*), COALESCE(SUM(CASE WHEN hint_used = 1 THEN 1 ELSE 0 END), 0)
-			FROM user_answers
-			WHERE user_id = ?
+			SELECT COALESCE(SUM(answers), 0), COALESCE(SUM(hints), 0) FROM (
+				SELECT COUNT(*) AS answers, COALESCE(SUM(CASE WHEN hint_used = 1 THEN 1 ELSE 0 END), 0) AS hints
+				FROM user_answers
+				WHERE user_id = ?1
+				UNION ALL
+				SELECT answers, hints FROM purged_answer_counts WHERE user_id = ?1
+			)
 		`, userID).Scan(&total, &hints)
 		if err != nil {
 			return nil, err
@@ -1066,9 +1540,13 @@ func (e *AchievementEngine) getUserAnswerTimeRange(userID int64) (*time.Time, *t
 	result, err := e.queue.Execute(func(db *sql.DB) (any, error) {
 		var firstTimeStr, lastTimeStr sql.NullString
 		err := db.QueryRow(`
-			SELECT MIN(created_at), MAX(created_at)
-			FROM user_answers
-			WHERE user_id = ?
+			SELECT MIN(first_at), MAX(last_at) FROM (
+				SELECT MIN(created_at) AS first_at, MAX(created_at) AS last_at
+				FROM user_answers
+				WHERE user_id = ?1
+				UNION ALL
+				SELECT first_answer_at, last_answer_at FROM purged_answer_counts WHERE user_id = ?1
+			)
 		`, userID).Scan(&firstTimeStr, &lastTimeStr)
 		if err != nil {
 			return nil, err
@@ -1082,13 +1560,13 @@ func (e *AchievementEngine) getUserAnswerTimeRange(userID int64) (*time.Time, *t
 	var first, last *time.Time
 	if times[0].Valid && times[0].String != "" {
 		parsedTime, err := parseTimeString(times[0].String)
-		if err == nil {
+		if err == nil && !parsedTime.IsZero() {
 			first = &parsedTime
 		}
 	}
 	if times[1].Valid && times[1].String != "" {
 		parsedTime, err := parseTimeString(times[1].String)
-		if err == nil {
+		if err == nil && !parsedTime.IsZero() {
 			last = &parsedTime
 		}
 	}
@@ -1156,12 +1634,22 @@ func (e *AchievementEngine) EvaluateCompletionAchievements(userID int64) ([]stri
 		awarded = append(awarded, CompletionAchievementKeys["self_sufficient"])
 	}
 
+	completionistAwarded, err := e.tryAwardCompletionAchievement(userID, CompletionAchievementKeys["completionist"], func() bool {
+		answered, total, err := e.statsService.GetUserAsteriskStats(userID)
+		return err == nil && total > 0 && answered == total
+	})
+	if err != nil {
+		log.Printf("[ACHIEVEMENT_ENGINE] Error awarding completionist achievement: %v", err)
+	} else if completionistAwarded {
+		awarded = append(awarded, CompletionAchievementKeys["completionist"])
+	}
+
 	hasCheater, _ := e.achievementRepo.HasUserAchievement(userID, CompletionAchievementKeys["cheater"])
 	hasLightning, _ := e.achievementRepo.HasUserAchievement(userID, CompletionAchievementKeys["lightning"])
 	hasRocket, _ := e.achievementRepo.HasUserAchievement(userID, CompletionAchievementKeys["rocket"])
 	speedAchievementExists := hasCheater || hasLightning || hasRocket
 
-	if !speedAchievementExists && stats.CompletionTimeMinutes < 5 {
+	if !speedAchievementExists && stats.CompletedWithin(5) {
 		cheaterAwarded, err := e.tryAwardCompletionAchievement(userID, CompletionAchievementKeys["cheater"], func() bool {
 			return true
 		})
@@ -1173,7 +1661,7 @@ func (e *AchievementEngine) EvaluateCompletionAchievements(userID int64) ([]stri
 		}
 	}
 
-	if !speedAchievementExists && stats.CompletionTimeMinutes < 10 {
+	if !speedAchievementExists && stats.CompletedWithin(10) {
 		lightningAwarded, err := e.tryAwardCompletionAchievement(userID, CompletionAchievementKeys["lightning"], func() bool {
 			return true
 		})
@@ -1185,7 +1673,7 @@ func (e *AchievementEngine) EvaluateCompletionAchievements(userID int64) ([]stri
 		}
 	}
 
-	if !speedAchievementExists && stats.CompletionTimeMinutes < 60 {
+	if !speedAchievementExists && stats.CompletedWithin(60) {
 		rocketAwarded, err := e.tryAwardCompletionAchievement(userID, CompletionAchievementKeys["rocket"], func() bool {
 			return true
 		})
@@ -1242,8 +1730,40 @@ func (e *AchievementEngine) OnQuestCompleted(userID int64) ([]string, error) {
 	return completionAchievements, nil
 }
 
-func (e *AchievementEngine) EvaluateCompletionConditions(userID int64, achievement *models.Achievement) (bool, error) {
-	conditions := achievement.Conditions
+// MarkCompletionPending откладывает завершение квеста до подтверждения участником:
+// пока оно не получено, участник не считается прошедшим квест и не занимает место
+func (e *AchievementEngine) MarkCompletionPending(userID int64) error {
+	return e.userRepo.SetPendingCompletion(userID)
+}
+
+// ConfirmCompletion завершает квест, ожидавший подтверждения, и выдаёт достижения
+// за прохождение и места. Возвращает false, если завершение не ожидало подтверждения
+// (например, кнопку нажали повторно)
+func (e *AchievementEngine) ConfirmCompletion(userID int64) (bool, []string, error) {
+	cleared, err := e.userRepo.ClearPendingCompletion(userID)
+	if err != nil || !cleared {
+		return false, nil, err
+	}
+
+	awarded, err := e.OnQuestCompleted(userID)
+	if err != nil {
+		return true, nil, err
+	}
+
+	if len(awarded) > 0 {
+		composite, err := e.EvaluateCompositeAchievements(userID)
+		if err != nil {
+			log.Printf("[ACHIEVEMENT_ENGINE] Error evaluating composite achievements for user %d: %v", userID, err)
+		} else {
+			awarded = append(awarded, composite...)
+		}
+	}
+
+	return true, awarded, nil
+}
+
+func (e *AchievementEngine) EvaluateCompletionConditions(userID int64, achievement *models.Achievement) (bool, error) {
+	conditions := achievement.Conditions
 
 	stats, err := e.GetCompletionStats(userID)
 	if err != nil {
@@ -1267,7 +1787,7 @@ func (e *AchievementEngine) EvaluateCompletionConditions(userID int64, achieveme
 	}
 
 	if conditions.CompletionTimeMinutes != nil {
-		if stats.CompletionTimeMinutes >= *conditions.CompletionTimeMinutes {
+		if !stats.CompletedWithin(*conditions.CompletionTimeMinutes) {
 			return false, nil
 		}
 	}
@@ -1353,6 +1873,10 @@ var HintAchievementKeys = map[int]string{
 
 var HintThresholds = []int{5, 10, 15, 25}
 
+// HintThresholdPercents — пороги HintThresholds в процентах от числа активных шагов
+// для ThresholdModePercent, по одному на каждый порог
+var HintThresholdPercents = []int{20, 40, 60, 100}
+
 type HintStats struct {
 	TotalHintsUsed      int
 	CorrectAnswers      int
@@ -1414,11 +1938,16 @@ func (e *AchievementEngine) EvaluateHintAchievements(userID int64) ([]string, er
 		return nil, err
 	}
 
+	levels, err := e.hintLevels()
+	if err != nil {
+		return nil, err
+	}
+
 	var awarded []string
 
-	for _, threshold := range HintThresholds {
-		if stats.TotalHintsUsed >= threshold {
-			achievementKey := HintAchievementKeys[threshold]
+	for _, level := range levels {
+		if stats.TotalHintsUsed >= level.threshold {
+			achievementKey := level.key
 			wasAwarded, err := e.tryAwardHintAchievement(userID, achievementKey)
 			if err != nil {
 				log.Printf("[ACHIEVEMENT_ENGINE] Error awarding hint achievement %s: %v", achievementKey, err)
@@ -1907,6 +2436,212 @@ func (e *AchievementEngine) CheckInactivityAchievement(userID int64) ([]string,
 	return nil, nil
 }
 
+const DefaultComebackDays = 7
+
+func (e *AchievementEngine) CheckComebackAchievement(userID int64) ([]string, error) {
+	hasAchievement, err := e.achievementRepo.HasUserAchievement(userID, "comeback")
+	if err != nil {
+		return nil, err
+	}
+	if hasAchievement {
+		return nil, nil
+	}
+
+	achievement, err := e.achievementRepo.GetByKey("comeback")
+	if err != nil {
+		return nil, err
+	}
+	if !achievement.IsActive {
+		return nil, nil
+	}
+
+	days := DefaultComebackDays
+	if achievement.Conditions.ComebackDays != nil && *achievement.Conditions.ComebackDays > 0 {
+		days = *achievement.Conditions.ComebackDays
+	}
+
+	returned, err := e.hasStepCompletedAfterBreak(userID, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if !returned {
+		return nil, nil
+	}
+
+	wasAwarded, err := e.tryAwardSpecialAchievement(userID, "comeback")
+	if err != nil {
+		return nil, err
+	}
+	if wasAwarded {
+		return []string{"comeback"}, nil
+	}
+	return nil, nil
+}
+
+// DefaultRecruiterReferrals — сколько засчитанных приглашений нужно для «Вербовщика»,
+// если в условиях достижения не задано иное
+const DefaultRecruiterReferrals = 3
+
+// CheckRecruiterAchievement выдаёт «Вербовщика» пригласившему участнику, когда число
+// приглашённых им участников, прошедших хотя бы один шаг, достигает порога из условий
+func (e *AchievementEngine) CheckRecruiterAchievement(referrerID int64) ([]string, error) {
+	hasAchievement, err := e.achievementRepo.HasUserAchievement(referrerID, "recruiter")
+	if err != nil {
+		return nil, err
+	}
+	if hasAchievement {
+		return nil, nil
+	}
+
+	achievement, err := e.achievementRepo.GetByKey("recruiter")
+	if err != nil {
+		return nil, err
+	}
+	if !achievement.IsActive {
+		return nil, nil
+	}
+
+	required := DefaultRecruiterReferrals
+	if achievement.Conditions.ReferralCount != nil && *achievement.Conditions.ReferralCount > 0 {
+		required = *achievement.Conditions.ReferralCount
+	}
+
+	count, err := e.referralRepo.CountQualified(referrerID)
+	if err != nil {
+		return nil, err
+	}
+	if count < required {
+		return nil, nil
+	}
+
+	wasAwarded, err := e.tryAwardSpecialAchievement(referrerID, "recruiter")
+	if err != nil {
+		return nil, err
+	}
+	if wasAwarded {
+		return []string{"recruiter"}, nil
+	}
+	return nil, nil
+}
+
+// DefaultHelperReports — сколько полезных жалоб нужно для «Помощника», если в условиях
+// достижения не задано иное
+const DefaultHelperReports = 3
+
+// CheckHelperAchievement выдаёт «Помощника» участнику, когда число его жалоб через /report,
+// отмеченных администратором полезными, достигает порога из условий
+func (e *AchievementEngine) CheckHelperAchievement(userID int64) ([]string, error) {
+	hasAchievement, err := e.achievementRepo.HasUserAchievement(userID, "helper")
+	if err != nil {
+		return nil, err
+	}
+	if hasAchievement {
+		return nil, nil
+	}
+
+	achievement, err := e.achievementRepo.GetByKey("helper")
+	if err != nil {
+		return nil, err
+	}
+	if !achievement.IsActive {
+		return nil, nil
+	}
+
+	required := DefaultHelperReports
+	if achievement.Conditions.UsefulReportCount != nil && *achievement.Conditions.UsefulReportCount > 0 {
+		required = *achievement.Conditions.UsefulReportCount
+	}
+
+	count, err := e.stepReportRepo.CountUseful(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count < required {
+		return nil, nil
+	}
+
+	wasAwarded, err := e.tryAwardSpecialAchievement(userID, "helper")
+	if err != nil {
+		return nil, err
+	}
+	if wasAwarded {
+		return []string{"helper"}, nil
+	}
+	return nil, nil
+}
+
+// hasStepCompletedAfterBreak ищет самый поздний перерыв между ответами длиннее breakDuration
+// и проверяет, что после возвращения пользователь прошёл хотя бы один шаг
+func (e *AchievementEngine) hasStepCompletedAfterBreak(userID int64, breakDuration time.Duration) (bool, error) {
+	result, err := e.queue.Execute(func(db *sql.DB) (any, error) {
+		rows, err := db.Query(`
+			SELECT created_at FROM user_answers
+			WHERE user_id = ?
+			ORDER BY created_at ASC
+		`, userID)
+		if err != nil {
+			return false, err
+		}
+
+		var answerTimes []time.Time
+		for rows.Next() {
+			var createdAtStr string
+			if err := rows.Scan(&createdAtStr); err != nil {
+				rows.Close()
+				return false, err
+			}
+			createdAt, err := parseTimeString(createdAtStr)
+			if err != nil || createdAt.IsZero() {
+				continue
+			}
+			answerTimes = append(answerTimes, createdAt)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return false, err
+		}
+
+		var resumedAt *time.Time
+		for i := 1; i < len(answerTimes); i++ {
+			if answerTimes[i].Sub(answerTimes[i-1]) >= breakDuration {
+				resumed := answerTimes[i]
+				resumedAt = &resumed
+			}
+		}
+		if resumedAt == nil {
+			return false, nil
+		}
+
+		progressRows, err := db.Query(`
+			SELECT completed_at FROM user_progress
+			WHERE user_id = ? AND status = ? AND completed_at IS NOT NULL
+		`, userID, models.StatusApproved)
+		if err != nil {
+			return false, err
+		}
+		defer progressRows.Close()
+
+		for progressRows.Next() {
+			var completedAtStr string
+			if err := progressRows.Scan(&completedAtStr); err != nil {
+				return false, err
+			}
+			completedAt, err := parseTimeString(completedAtStr)
+			if err != nil || completedAt.IsZero() {
+				continue
+			}
+			if !completedAt.Before(*resumedAt) {
+				return true, nil
+			}
+		}
+		return false, progressRows.Err()
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
 func (e *AchievementEngine) GetCurrentConsecutiveCorrect(userID int64) (int, error) {
 	result, err := e.queue.Execute(func(db *sql.DB) (any, error) {
 		rows, err := db.Query(`
@@ -1948,9 +2683,24 @@ func (e *AchievementEngine) GetCurrentConsecutiveCorrect(userID int64) (int, err
 var CompositeAchievementKeys = map[string]string{
 	"super_collector": "super_collector",
 	"super_brain":     "super_brain",
+	"flawless":        "flawless",
 	"legend":          "legend",
 }
 
+// DefaultFlawlessTimeLimitMinutes — лимит времени для «Безупречного» по умолчанию. Он строже,
+// чем у «Супермозга», чтобы достижение оставалось высшей наградой за прохождение
+const DefaultFlawlessTimeLimitMinutes = 15
+
+// QualifiesForFlawless сообщает, заслуживает ли прохождение «Безупречного»: квест завершён
+// без ошибок и подсказок быстрее limitMinutes. Достижение не входит в группу скоростных
+// (читер, молния, ракета), которые взаимоисключают друг друга, и выдаётся вместе с ними
+func QualifiesForFlawless(stats *CompletionStats, limitMinutes int) bool {
+	return stats.IsCompleted &&
+		stats.TotalAnswers == stats.CorrectAnswers &&
+		stats.HintsUsed == 0 &&
+		stats.CompletedWithin(limitMinutes)
+}
+
 var SuperCollectorRequiredAchievements = []string{
 	"beginner_5",
 	"experienced_10",
@@ -2001,6 +2751,7 @@ type CompositeStats struct {
 	NoErrors                     bool
 	NoHints                      bool
 	CompletionTimeMinutes        int
+	CompletionTimeKnown          bool
 	UserAchievementKeys          []string
 }
 
@@ -2059,6 +2810,7 @@ func (e *AchievementEngine) GetCompositeStats(userID int64) (*CompositeStats, er
 	stats.NoErrors = completionStats.TotalAnswers == completionStats.CorrectAnswers
 	stats.NoHints = completionStats.HintsUsed == 0
 	stats.CompletionTimeMinutes = completionStats.CompletionTimeMinutes
+	stats.CompletionTimeKnown = completionStats.CompletionTimeKnown
 
 	return stats, nil
 }
@@ -2080,6 +2832,13 @@ func (e *AchievementEngine) EvaluateCompositeAchievements(userID int64) ([]strin
 		awarded = append(awarded, CompositeAchievementKeys["super_brain"])
 	}
 
+	flawlessAwarded, err := e.evaluateFlawless(userID)
+	if err != nil {
+		log.Printf("[ACHIEVEMENT_ENGINE] Error evaluating flawless: %v", err)
+	} else if flawlessAwarded {
+		awarded = append(awarded, CompositeAchievementKeys["flawless"])
+	}
+
 	legendAwarded, err := e.evaluateLegend(userID)
 	if err != nil {
 		log.Printf("[ACHIEVEMENT_ENGINE] Error evaluating legend: %v", err)
@@ -2153,7 +2912,7 @@ func (e *AchievementEngine) evaluateSuperBrain(userID int64) (bool, error) {
 		return false, nil
 	}
 
-	if stats.CompletionTimeMinutes >= SuperBrainRequiredConditions.CompletionTimeMinutes {
+	if !stats.CompletionTimeKnown || stats.CompletionTimeMinutes >= SuperBrainRequiredConditions.CompletionTimeMinutes {
 		return false, nil
 	}
 
@@ -2171,6 +2930,39 @@ func (e *AchievementEngine) evaluateSuperBrain(userID int64) (bool, error) {
 	return true, nil
 }
 
+func (e *AchievementEngine) evaluateFlawless(userID int64) (bool, error) {
+	achievementKey := CompositeAchievementKeys["flawless"]
+
+	hasAchievement, err := e.achievementRepo.HasUserAchievement(userID, achievementKey)
+	if err != nil {
+		return false, err
+	}
+	if hasAchievement {
+		return false, nil
+	}
+
+	stats, err := e.GetCompletionStats(userID)
+	if err != nil {
+		return false, err
+	}
+
+	if !QualifiesForFlawless(stats, e.flawlessTimeLimit) {
+		return false, nil
+	}
+
+	achievement, err := e.achievementRepo.GetByKey(achievementKey)
+	if err != nil {
+		return false, err
+	}
+
+	err = e.achievementRepo.AssignToUser(userID, achievement.ID, time.Now(), false)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (e *AchievementEngine) evaluateLegend(userID int64) (bool, error) {
 	achievementKey := CompositeAchievementKeys["legend"]
 
@@ -2246,11 +3038,15 @@ func (e *AchievementEngine) EvaluateCompositeConditions(userID int64, achievemen
 	}
 
 	if conditions.CompletionTimeMinutes != nil {
+		limit := *conditions.CompletionTimeMinutes
+		if achievement.Key == CompositeAchievementKeys["flawless"] {
+			limit = e.flawlessTimeLimit
+		}
 		stats, err := e.GetCompletionStats(userID)
 		if err != nil {
 			return false, err
 		}
-		if !stats.IsCompleted || stats.CompletionTimeMinutes >= *conditions.CompletionTimeMinutes {
+		if !stats.IsCompleted || !stats.CompletedWithin(limit) {
 			return false, nil
 		}
 	}
@@ -2429,10 +3225,16 @@ func (e *AchievementEngine) RecalculatePositionAchievements() (map[string]int64,
 	e.uniqueMutex.Lock()
 	defer e.uniqueMutex.Unlock()
 
-	positionAchievements := []string{
-		"pioneer", "second_place", "third_place", "fourth_place", "fifth_place",
-		"sixth_place", "seventh_place", "eighth_place", "ninth_place", "tenth_place",
+	positionAchievements, err := e.positionalAchievements(firstAnswerPlace)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]int, 0, len(positionAchievements))
+	for position := range positionAchievements {
+		positions = append(positions, position)
 	}
+	sort.Ints(positions)
 
 	usersWithFirstAnswer, err := e.getUsersOrderedByFirstCorrectAnswer()
 	if err != nil {
@@ -2441,12 +3243,9 @@ func (e *AchievementEngine) RecalculatePositionAchievements() (map[string]int64,
 
 	awarded := make(map[string]int64)
 
-	for i, key := range positionAchievements {
-		achievement, err := e.achievementRepo.GetByKey(key)
-		if err != nil {
-			log.Printf("[ACHIEVEMENT_ENGINE] Position achievement %s not found: %v", key, err)
-			continue
-		}
+	for _, position := range positions {
+		achievement := positionAchievements[position]
+		key := achievement.Key
 
 		holders, err := e.achievementRepo.GetAchievementHolders(key)
 		if err != nil {
@@ -2454,7 +3253,6 @@ func (e *AchievementEngine) RecalculatePositionAchievements() (map[string]int64,
 			continue
 		}
 
-		position := i + 1
 		if position > len(usersWithFirstAnswer) {
 			continue
 		}
@@ -2485,6 +3283,642 @@ func (e *AchievementEngine) RecalculatePositionAchievements() (map[string]int64,
 
 	return awarded, nil
 }
+
+// RecomputeResult — изменения достижений одного пользователя после пересчёта
+type RecomputeResult struct {
+	Awarded []string
+	Revoked []string
+}
+
+// RecomputeUserAchievements заново проверяет автоматические достижения пользователя по текущим
+// порогам и условиям: выдаёт недостающие за прогресс, подсказки, завершение и составные. С revoke
+// также снимает достижения за прогресс и подсказки, порог которых больше не достигнут, и
+// составные, лишившиеся обязательных достижений
+func (e *AchievementEngine) RecomputeUserAchievements(userID int64, revoke bool) (*RecomputeResult, error) {
+	before, err := e.heldAchievementKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoke {
+		if err := e.revokeUnreachedLevels(userID); err != nil {
+			return nil, err
+		}
+		if err := e.revokeUnsupportedComposites(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	evaluators := []struct {
+		name     string
+		evaluate func(int64) ([]string, error)
+	}{
+		{"progress", e.EvaluateProgressAchievements},
+		{"hint", e.EvaluateHintAchievements},
+		{"completion", e.EvaluateCompletionAchievements},
+		{"composite", e.EvaluateCompositeAchievements},
+	}
+	for _, evaluator := range evaluators {
+		if _, err := evaluator.evaluate(userID); err != nil {
+			log.Printf("[ACHIEVEMENT_ENGINE] Error recomputing %s achievements for user %d: %v", evaluator.name, userID, err)
+		}
+	}
+
+	after, err := e.heldAchievementKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RecomputeResult{}
+	for key := range after {
+		if !before[key] {
+			result.Awarded = append(result.Awarded, key)
+		}
+	}
+	for key := range before {
+		if !after[key] {
+			result.Revoked = append(result.Revoked, key)
+		}
+	}
+	sort.Strings(result.Awarded)
+	sort.Strings(result.Revoked)
+	return result, nil
+}
+
+// revokeUnreachedLevels снимает достижения за прогресс и подсказки, порог которых
+// пользователь не достигает при текущих порогах
+func (e *AchievementEngine) revokeUnreachedLevels(userID int64) error {
+	correctCount, err := e.getCorrectAnswersCount(userID)
+	if err != nil {
+		return err
+	}
+	hintStats, err := e.GetHintStats(userID)
+	if err != nil {
+		return err
+	}
+
+	checks := []struct {
+		levels func() ([]achievementLevel, error)
+		count  int
+	}{
+		{e.progressLevels, correctCount},
+		{e.hintLevels, hintStats.TotalHintsUsed},
+	}
+	for _, check := range checks {
+		levels, err := check.levels()
+		if err != nil {
+			return err
+		}
+		for _, level := range levels {
+			if level.key == "" || check.count >= level.threshold {
+				continue
+			}
+			hasAchievement, err := e.achievementRepo.HasUserAchievement(userID, level.key)
+			if err != nil {
+				return err
+			}
+			if !hasAchievement {
+				continue
+			}
+			achievement, err := e.achievementRepo.GetByKey(level.key)
+			if err != nil {
+				return err
+			}
+			if err := e.achievementRepo.RemoveUserAchievement(userID, achievement.ID); err != nil {
+				return err
+			}
+			log.Printf("[ACHIEVEMENT_ENGINE] Revoked %s from user %d: %d of %d", level.key, userID, check.count, level.threshold)
+		}
+	}
+	return nil
+}
+
+// heldAchievementKeys возвращает ключи достижений, которые есть у пользователя
+func (e *AchievementEngine) heldAchievementKeys(userID int64) (map[string]bool, error) {
+	achievements, err := e.achievementRepo.GetUserAchievements(userID)
+	if err != nil {
+		return nil, err
+	}
+	all, err := e.achievementRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	keysByID := make(map[int64]string, len(all))
+	for _, achievement := range all {
+		keysByID[achievement.ID] = achievement.Key
+	}
+
+	held := make(map[string]bool, len(achievements))
+	for _, ua := range achievements {
+		if key, ok := keysByID[ua.AchievementID]; ok {
+			held[key] = true
+		}
+	}
+	return held, nil
+}
+
+// RecomputeSummary — итог массового пересчёта достижений
+type RecomputeSummary struct {
+	UsersChecked int
+	UsersChanged int
+	Awarded      map[string]int
+	Revoked      map[string]int
+}
+
+// RecomputeAllAchievements пересчитывает автоматические достижения всех пользователей через
+// RecomputeUserAchievements, например после изменения порогов. Ошибка по одному пользователю
+// не останавливает пересчёт остальных
+func (e *AchievementEngine) RecomputeAllAchievements(revoke bool) (*RecomputeSummary, error) {
+	users, err := e.userRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &RecomputeSummary{
+		Awarded: make(map[string]int),
+		Revoked: make(map[string]int),
+	}
+	for _, user := range users {
+		summary.UsersChecked++
+		result, err := e.RecomputeUserAchievements(user.ID, revoke)
+		if err != nil {
+			log.Printf("[ACHIEVEMENT_ENGINE] Error recomputing achievements for user %d: %v", user.ID, err)
+			continue
+		}
+		if len(result.Awarded) == 0 && len(result.Revoked) == 0 {
+			continue
+		}
+		summary.UsersChanged++
+		for _, key := range result.Awarded {
+			summary.Awarded[key]++
+		}
+		for _, key := range result.Revoked {
+			summary.Revoked[key]++
+		}
+	}
+
+	log.Printf("[ACHIEVEMENT_ENGINE] Recomputed achievements for %d users, changed %d", summary.UsersChecked, summary.UsersChanged)
+	return summary, nil
+}
+
 func (e *AchievementEngine) ResetUserAchievements(userID int64) error {
 	return e.achievementRepo.DeleteUserAchievements(userID)
 }
+
+// ResetCategory снимает с пользователя все достижения одной категории, например чтобы заново
+// проверить выдачу достижений за завершение. Составные достижения, обязательные достижения которых
+// пропали, снимаются, остальные составные пересчитываются
+func (e *AchievementEngine) ResetCategory(userID int64, category models.AchievementCategory) error {
+	if err := e.achievementRepo.DeleteUserAchievementsByCategory(userID, category); err != nil {
+		return err
+	}
+
+	if err := e.revokeUnsupportedComposites(userID); err != nil {
+		return err
+	}
+
+	if category != models.CategoryComposite {
+		if _, err := e.EvaluateCompositeAchievements(userID); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("[ACHIEVEMENT_ENGINE] Reset %s achievements for user %d", category, userID)
+	return nil
+}
+
+// revokeUnsupportedComposites снимает достижения, у пользователя которых нет всех
+// required_achievements. Проверка повторяется, пока что-то снимается, чтобы учесть составные
+// достижения, зависящие от других составных
+func (e *AchievementEngine) revokeUnsupportedComposites(userID int64) error {
+	achievements, err := e.achievementRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	achievementsByID := make(map[int64]*models.Achievement)
+	for _, achievement := range achievements {
+		achievementsByID[achievement.ID] = achievement
+	}
+
+	for {
+		userAchievements, err := e.achievementRepo.GetUserAchievements(userID)
+		if err != nil {
+			return err
+		}
+
+		held := make(map[string]bool)
+		for _, ua := range userAchievements {
+			if achievement, ok := achievementsByID[ua.AchievementID]; ok {
+				held[achievement.Key] = true
+			}
+		}
+
+		revoked := false
+		for _, ua := range userAchievements {
+			achievement, ok := achievementsByID[ua.AchievementID]
+			if !ok {
+				continue
+			}
+			for _, required := range achievement.Conditions.RequiredAchievements {
+				if held[required] {
+					continue
+				}
+				if err := e.achievementRepo.RemoveUserAchievement(userID, achievement.ID); err != nil {
+					return err
+				}
+				log.Printf("[ACHIEVEMENT_ENGINE] Revoked %s from user %d: missing %s", achievement.Key, userID, required)
+				revoked = true
+				break
+			}
+		}
+
+		if !revoked {
+			return nil
+		}
+	}
+}
+
+type InconsistencyType string
+
+const (
+	InconsistencyUniqueMultipleHolders        InconsistencyType = "unique_multiple_holders"
+	InconsistencyCompositeMissingPrerequisite InconsistencyType = "composite_missing_prerequisite"
+	InconsistencyPositionOutOfOrder           InconsistencyType = "position_out_of_order"
+)
+
+type Inconsistency struct {
+	Type           InconsistencyType
+	AchievementKey string
+	UserIDs        []int64
+	Description    string
+}
+
+func (e *AchievementEngine) AuditConsistency() ([]Inconsistency, error) {
+	achievements, err := e.achievementRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	userAchievements, err := e.achievementRepo.GetAllUserAchievements()
+	if err != nil {
+		return nil, err
+	}
+
+	achievementsByID := make(map[int64]*models.Achievement)
+	for _, achievement := range achievements {
+		achievementsByID[achievement.ID] = achievement
+	}
+
+	holders := make(map[string][]int64)
+	userKeys := make(map[int64]map[string]bool)
+	for _, ua := range userAchievements {
+		achievement, ok := achievementsByID[ua.AchievementID]
+		if !ok {
+			continue
+		}
+		holders[achievement.Key] = append(holders[achievement.Key], ua.UserID)
+		if userKeys[ua.UserID] == nil {
+			userKeys[ua.UserID] = make(map[string]bool)
+		}
+		userKeys[ua.UserID][achievement.Key] = true
+	}
+
+	var firstAnswerRanks, completionRanks map[int64]int
+
+	var inconsistencies []Inconsistency
+	for _, achievement := range achievements {
+		keyHolders := holders[achievement.Key]
+
+		isPositional := achievement.Conditions.Position != nil || achievement.Conditions.CompletionPosition != nil
+		if (achievement.IsUnique || isPositional) && len(keyHolders) > 1 {
+			inconsistencies = append(inconsistencies, Inconsistency{
+				Type:           InconsistencyUniqueMultipleHolders,
+				AchievementKey: achievement.Key,
+				UserIDs:        keyHolders,
+				Description:    fmt.Sprintf("уникальное достижение у %d пользователей", len(keyHolders)),
+			})
+		}
+
+		if achievement.Category == models.CategoryComposite && len(achievement.Conditions.RequiredAchievements) > 0 {
+			for _, userID := range keyHolders {
+				var missing []string
+				for _, required := range achievement.Conditions.RequiredAchievements {
+					if !userKeys[userID][required] {
+						missing = append(missing, required)
+					}
+				}
+				if len(missing) > 0 {
+					inconsistencies = append(inconsistencies, Inconsistency{
+						Type:           InconsistencyCompositeMissingPrerequisite,
+						AchievementKey: achievement.Key,
+						UserIDs:        []int64{userID},
+						Description:    fmt.Sprintf("нет обязательных достижений: %s", strings.Join(missing, ", ")),
+					})
+				}
+			}
+		}
+
+		if len(keyHolders) != 1 {
+			continue
+		}
+
+		if achievement.Conditions.Position != nil {
+			if firstAnswerRanks == nil {
+				ordered, err := e.getUsersOrderedByFirstCorrectAnswer()
+				if err != nil {
+					return nil, err
+				}
+				firstAnswerRanks = make(map[int64]int)
+				for i, u := range ordered {
+					firstAnswerRanks[u.UserID] = i + 1
+				}
+			}
+			if inconsistency := checkPositionHolder(achievement.Key, *achievement.Conditions.Position, keyHolders[0], firstAnswerRanks); inconsistency != nil {
+				inconsistencies = append(inconsistencies, *inconsistency)
+			}
+		}
+
+		if achievement.Conditions.CompletionPosition != nil {
+			if completionRanks == nil {
+				ordered, err := e.getUsersOrderedByQuestCompletion()
+				if err != nil {
+					return nil, err
+				}
+				completionRanks = make(map[int64]int)
+				for i, u := range ordered {
+					completionRanks[u.UserID] = i + 1
+				}
+			}
+			if inconsistency := checkPositionHolder(achievement.Key, *achievement.Conditions.CompletionPosition, keyHolders[0], completionRanks); inconsistency != nil {
+				inconsistencies = append(inconsistencies, *inconsistency)
+			}
+		}
+	}
+
+	return inconsistencies, nil
+}
+
+func checkPositionHolder(achievementKey string, position int, holderID int64, ranks map[int64]int) *Inconsistency {
+	rank, ok := ranks[holderID]
+	if ok && rank == position {
+		return nil
+	}
+
+	description := fmt.Sprintf("место %d, но пользователь не найден в рейтинге", position)
+	if ok {
+		description = fmt.Sprintf("место %d, но фактическая позиция пользователя %d", position, rank)
+	}
+
+	return &Inconsistency{
+		Type:           InconsistencyPositionOutOfOrder,
+		AchievementKey: achievementKey,
+		UserIDs:        []int64{holderID},
+		Description:    description,
+	}
+}
+
+const (
+	DefaultNightOwlHourFrom = 0
+	DefaultNightOwlHourTo   = 5
+)
+
+// UserLocation возвращает часовой пояс пользователя с учётом default_timezone из настроек
+func (e *AchievementEngine) UserLocation(userID int64) *time.Location {
+	timezone, err := e.userRepo.GetEffectiveTimezone(userID)
+	if err != nil {
+		return time.Local
+	}
+	return ResolveLocation(timezone)
+}
+
+// CheckNightOwlAchievement проверяет, что ответ дан ночью по местному времени пользователя
+func (e *AchievementEngine) CheckNightOwlAchievement(userID int64, answeredAt time.Time) ([]string, error) {
+	hasAchievement, err := e.achievementRepo.HasUserAchievement(userID, "night_owl")
+	if err != nil {
+		return nil, err
+	}
+	if hasAchievement {
+		return nil, nil
+	}
+
+	achievement, err := e.achievementRepo.GetByKey("night_owl")
+	if err != nil {
+		return nil, err
+	}
+	if !achievement.IsActive {
+		return nil, nil
+	}
+
+	hourFrom, hourTo := DefaultNightOwlHourFrom, DefaultNightOwlHourTo
+	if achievement.Conditions.LocalHourFrom != nil {
+		hourFrom = *achievement.Conditions.LocalHourFrom
+	}
+	if achievement.Conditions.LocalHourTo != nil {
+		hourTo = *achievement.Conditions.LocalHourTo
+	}
+
+	localHour := answeredAt.In(e.UserLocation(userID)).Hour()
+	if !isHourInRange(localHour, hourFrom, hourTo) {
+		return nil, nil
+	}
+
+	wasAwarded, err := e.tryAwardSpecialAchievement(userID, "night_owl")
+	if err != nil {
+		return nil, err
+	}
+	if wasAwarded {
+		return []string{"night_owl"}, nil
+	}
+	return nil, nil
+}
+
+const DefaultStreakDays = 3
+
+// CheckStreakAchievement проверяет, что участник проходил шаги несколько дней подряд.
+// Дни считаются по местному времени участника: ответы до и после его полуночи попадают
+// в разные дни, даже если на сервере это одни сутки
+func (e *AchievementEngine) CheckStreakAchievement(userID int64) ([]string, error) {
+	hasAchievement, err := e.achievementRepo.HasUserAchievement(userID, "streak")
+	if err != nil {
+		return nil, err
+	}
+	if hasAchievement {
+		return nil, nil
+	}
+
+	achievement, err := e.achievementRepo.GetByKey("streak")
+	if err != nil {
+		return nil, err
+	}
+	if !achievement.IsActive {
+		return nil, nil
+	}
+
+	days := DefaultStreakDays
+	if achievement.Conditions.StreakDays != nil && *achievement.Conditions.StreakDays > 0 {
+		days = *achievement.Conditions.StreakDays
+	}
+
+	completedAt, err := e.getApprovedCompletionTimes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if longestDayStreak(completedAt, e.UserLocation(userID)) < days {
+		return nil, nil
+	}
+
+	wasAwarded, err := e.tryAwardSpecialAchievement(userID, "streak")
+	if err != nil {
+		return nil, err
+	}
+	if wasAwarded {
+		return []string{"streak"}, nil
+	}
+	return nil, nil
+}
+
+// getApprovedCompletionTimes возвращает время прохождения одобренных шагов участника
+func (e *AchievementEngine) getApprovedCompletionTimes(userID int64) ([]time.Time, error) {
+	result, err := e.queue.Execute(func(db *sql.DB) (any, error) {
+		rows, err := db.Query(`
+			SELECT completed_at FROM user_progress
+			WHERE user_id = ? AND status = ? AND completed_at IS NOT NULL
+		`, userID, models.StatusApproved)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var times []time.Time
+		for rows.Next() {
+			var completedAtStr string
+			if err := rows.Scan(&completedAtStr); err != nil {
+				return nil, err
+			}
+			completedAt, err := parseTimeString(completedAtStr)
+			if err != nil || completedAt.IsZero() {
+				continue
+			}
+			times = append(times, completedAt)
+		}
+		return times, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]time.Time), nil
+}
+
+// longestDayStreak возвращает наибольшее число календарных дней подряд в часовом поясе loc,
+// в каждый из которых есть хотя бы одна отметка времени
+func longestDayStreak(times []time.Time, loc *time.Location) int {
+	days := make(map[time.Time]bool, len(times))
+	for _, t := range times {
+		local := t.In(loc)
+		// Ключ — дата без часового пояса, чтобы переход на летнее время не сдвигал сутки
+		days[time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)] = true
+	}
+
+	longest := 0
+	for day := range days {
+		if days[day.AddDate(0, 0, -1)] {
+			continue
+		}
+		length := 1
+		for days[day.AddDate(0, 0, length)] {
+			length++
+		}
+		longest = max(longest, length)
+	}
+	return longest
+}
+
+// isHourInRange проверяет попадание часа в полуинтервал [from, to), в том числе через полночь
+func isHourInRange(hour, from, to int) bool {
+	if from <= to {
+		return hour >= from && hour < to
+	}
+	return hour >= from || hour < to
+}
+
+// EvaluateOnApproval выполняет полный набор проверок достижений после одобрения шага.
+// Для последнего шага проверяются достижения завершения, для остальных — прогресс, позиция,
+// подсказки, звёздочка и возвращение. answeredAt используется для проверки ночного ответа
+func (e *AchievementEngine) EvaluateOnApproval(userID, stepID int64, isLastStep bool, answeredAt time.Time) []string {
+	var allAwarded []string
+
+	collect := func(name string, awarded []string, err error) {
+		if err != nil {
+			log.Printf("[ACHIEVEMENT_ENGINE] Error evaluating %s achievements for user %d: %v", name, userID, err)
+			return
+		}
+		allAwarded = append(allAwarded, awarded...)
+	}
+
+	if isLastStep {
+		awarded, err := e.EvaluateCompletionAchievements(userID)
+		collect("completion", awarded, err)
+	} else if e.useTargetedEvaluation(userID, answeredAt) {
+		e.evaluateTargeted(userID, stepID, collect)
+	} else {
+		awarded, err := e.EvaluateProgressAchievements(userID)
+		collect("progress", awarded, err)
+
+		awarded, err = e.EvaluatePositionBasedAchievements(userID)
+		collect("position", awarded, err)
+
+		awarded, err = e.EvaluateHintAchievements(userID)
+		collect("hint", awarded, err)
+
+		awarded, err = e.CheckAsteriskAchievement(userID, stepID)
+		collect("asterisk", awarded, err)
+
+		awarded, err = e.CheckComebackAchievement(userID)
+		collect("comeback", awarded, err)
+	}
+
+	// Если для прохождения достаточно части шагов, квест может оказаться пройденным
+	// раньше последнего шага
+	if !isLastStep && e.minCompletionSteps() > 0 {
+		awarded, err := e.EvaluateCompletionAchievements(userID)
+		collect("completion", awarded, err)
+	}
+
+	awarded, err := e.CheckNightOwlAchievement(userID, answeredAt)
+	collect("night owl", awarded, err)
+
+	awarded, err = e.CheckStreakAchievement(userID)
+	collect("streak", awarded, err)
+
+	if len(allAwarded) > 0 {
+		awarded, err := e.EvaluateCompositeAchievements(userID)
+		collect("composite", awarded, err)
+	}
+
+	return allAwarded
+}
+
+// evaluateTargeted проверяет только достижения, которые мог принести этот ответ.
+// Место определяется первым правильным ответом, поэтому достижения за место проверяются
+// лишь на первом одобренном шаге участника
+func (e *AchievementEngine) evaluateTargeted(userID, stepID int64, collect func(string, []string, error)) {
+	correctCount, err := e.getCorrectAnswersCount(userID)
+	if err != nil {
+		collect("progress", nil, err)
+		return
+	}
+
+	awarded, err := e.evaluateProgressForCount(userID, correctCount, true)
+	collect("progress", awarded, err)
+
+	if correctCount <= 1 {
+		awarded, err = e.EvaluatePositionBasedAchievements(userID)
+		collect("position", awarded, err)
+	}
+
+	awarded, err = e.CheckAsteriskAchievement(userID, stepID)
+	collect("asterisk", awarded, err)
+
+	awarded, err = e.CheckComebackAchievement(userID)
+	collect("comeback", awarded, err)
+}
@@ -3,9 +3,12 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -2843,3 +2846,1604 @@ func TestProperty6_ManualAchievementClassification(t *testing.T) {
 		}
 	})
 }
+
+func TestComebackAchievementAfterBreak(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	user := createTestUserForEngine(t, userRepo, 1001)
+	step1 := createTestStep(t, stepRepo, 1)
+	step2 := createTestStep(t, stepRepo, 2)
+
+	beforeBreak := time.Now().Add(-10 * 24 * time.Hour)
+	createUserAnswer(t, queue, user.ID, step1.ID, false, beforeBreak)
+	createUserProgress(t, progressRepo, user.ID, step1.ID, models.StatusApproved, &beforeBreak)
+
+	afterBreak := time.Now()
+	createUserAnswer(t, queue, user.ID, step2.ID, false, afterBreak)
+	createUserProgress(t, progressRepo, user.ID, step2.ID, models.StatusApproved, &afterBreak)
+
+	awarded, err := engine.CheckComebackAchievement(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 1 || awarded[0] != "comeback" {
+		t.Fatalf("Expected comeback achievement, got %v", awarded)
+	}
+
+	awarded, err = engine.CheckComebackAchievement(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 0 {
+		t.Errorf("Comeback achievement should be awarded only once, got %v", awarded)
+	}
+}
+
+func TestComebackAchievementNotAwardedForContinuousActivity(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	user := createTestUserForEngine(t, userRepo, 1002)
+	start := time.Now().Add(-10 * 24 * time.Hour)
+	for i := 0; i < 5; i++ {
+		step := createTestStep(t, stepRepo, i+1)
+		answeredAt := start.Add(time.Duration(i*2) * 24 * time.Hour)
+		createUserAnswer(t, queue, user.ID, step.ID, false, answeredAt)
+		createUserProgress(t, progressRepo, user.ID, step.ID, models.StatusApproved, &answeredAt)
+	}
+
+	awarded, err := engine.CheckComebackAchievement(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 0 {
+		t.Errorf("Comeback achievement should not be awarded without a long break, got %v", awarded)
+	}
+}
+
+func TestComebackAchievementRequiresCompletedStepAfterBreak(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	user := createTestUserForEngine(t, userRepo, 1003)
+	step1 := createTestStep(t, stepRepo, 1)
+	step2 := createTestStep(t, stepRepo, 2)
+
+	beforeBreak := time.Now().Add(-10 * 24 * time.Hour)
+	createUserAnswer(t, queue, user.ID, step1.ID, false, beforeBreak)
+	createUserProgress(t, progressRepo, user.ID, step1.ID, models.StatusApproved, &beforeBreak)
+
+	createUserAnswer(t, queue, user.ID, step2.ID, false, time.Now())
+
+	awarded, err := engine.CheckComebackAchievement(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 0 {
+		t.Errorf("Comeback achievement should require a completed step after the break, got %v", awarded)
+	}
+}
+
+func findInconsistency(inconsistencies []Inconsistency, kind InconsistencyType, key string) *Inconsistency {
+	for i := range inconsistencies {
+		if inconsistencies[i].Type == kind && inconsistencies[i].AchievementKey == key {
+			return &inconsistencies[i]
+		}
+	}
+	return nil
+}
+
+func TestAuditConsistency_CleanData(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	step := createTestStep(t, stepRepo, 1)
+	baseTime := time.Now().Add(-time.Hour)
+	for i := int64(1); i <= 2; i++ {
+		createTestUserForEngine(t, userRepo, i)
+		completedAt := baseTime.Add(time.Duration(i) * time.Minute)
+		createUserProgress(t, progressRepo, i, step.ID, models.StatusApproved, &completedAt)
+	}
+	assignAchievementToUser(t, achievementRepo, 1, "pioneer", baseTime)
+	assignAchievementToUser(t, achievementRepo, 2, "second_place", baseTime)
+
+	inconsistencies, err := engine.AuditConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inconsistencies) != 0 {
+		t.Errorf("Expected no inconsistencies, got %+v", inconsistencies)
+	}
+}
+
+func TestAuditConsistency_TwoHoldersOfPositionAchievement(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	createTestUserForEngine(t, userRepo, 1)
+	createTestUserForEngine(t, userRepo, 2)
+	assignAchievementToUser(t, achievementRepo, 1, "pioneer", time.Now())
+	assignAchievementToUser(t, achievementRepo, 2, "pioneer", time.Now())
+
+	inconsistencies, err := engine.AuditConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := findInconsistency(inconsistencies, InconsistencyUniqueMultipleHolders, "pioneer")
+	if found == nil {
+		t.Fatalf("Expected pioneer with two holders to be flagged, got %+v", inconsistencies)
+	}
+	if len(found.UserIDs) != 2 {
+		t.Errorf("Expected 2 holders in inconsistency, got %v", found.UserIDs)
+	}
+}
+
+func TestAuditConsistency_CompositeWithoutPrerequisites(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	createTestUserForEngine(t, userRepo, 1)
+	assignAchievementToUser(t, achievementRepo, 1, "beginner_5", time.Now())
+	assignAchievementToUser(t, achievementRepo, 1, "super_collector", time.Now())
+
+	inconsistencies, err := engine.AuditConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := findInconsistency(inconsistencies, InconsistencyCompositeMissingPrerequisite, "super_collector")
+	if found == nil {
+		t.Fatalf("Expected super_collector without prerequisites to be flagged, got %+v", inconsistencies)
+	}
+	if !strings.Contains(found.Description, "master_25") {
+		t.Errorf("Expected missing prerequisites in description, got %q", found.Description)
+	}
+	if strings.Contains(found.Description, "beginner_5") {
+		t.Errorf("beginner_5 is held and should not be reported, got %q", found.Description)
+	}
+}
+
+func TestAuditConsistency_PositionOutOfOrder(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	step := createTestStep(t, stepRepo, 1)
+	baseTime := time.Now().Add(-time.Hour)
+	for i := int64(1); i <= 2; i++ {
+		createTestUserForEngine(t, userRepo, i)
+		completedAt := baseTime.Add(time.Duration(i) * time.Minute)
+		createUserProgress(t, progressRepo, i, step.ID, models.StatusApproved, &completedAt)
+	}
+	assignAchievementToUser(t, achievementRepo, 2, "pioneer", baseTime)
+	assignAchievementToUser(t, achievementRepo, 1, "second_place", baseTime)
+
+	inconsistencies, err := engine.AuditConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if findInconsistency(inconsistencies, InconsistencyPositionOutOfOrder, "pioneer") == nil {
+		t.Errorf("Expected swapped pioneer to be flagged, got %+v", inconsistencies)
+	}
+	if findInconsistency(inconsistencies, InconsistencyPositionOutOfOrder, "second_place") == nil {
+		t.Errorf("Expected swapped second_place to be flagged, got %+v", inconsistencies)
+	}
+}
+
+func TestNightOwl_UsesUserLocalHours(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	// 22:30 UTC: ночь в Москве (01:30), но вечер по UTC
+	answeredAt := time.Date(2024, 1, 10, 22, 30, 0, 0, time.UTC)
+
+	createTestUserForEngine(t, userRepo, 1)
+	if err := userRepo.SetTimezone(1, "Europe/Moscow"); err != nil {
+		t.Fatal(err)
+	}
+	createTestUserForEngine(t, userRepo, 2)
+	if err := userRepo.SetTimezone(2, "UTC"); err != nil {
+		t.Fatal(err)
+	}
+
+	awarded, err := engine.CheckNightOwlAchievement(1, answeredAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 1 || awarded[0] != "night_owl" {
+		t.Errorf("Expected night_owl for user answering at 01:30 local time, got %v", awarded)
+	}
+
+	awarded, err = engine.CheckNightOwlAchievement(2, answeredAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 0 {
+		t.Errorf("Expected no night_owl for user answering at 22:30 local time, got %v", awarded)
+	}
+}
+
+func TestNightOwl_FallsBackToDefaultTimezone(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	createTestUserForEngine(t, userRepo, 1)
+	if err := settingsRepo.SetDefaultTimezone("UTC+5"); err != nil {
+		t.Fatal(err)
+	}
+
+	answeredAt := time.Date(2024, 1, 10, 22, 30, 0, 0, time.UTC)
+	awarded, err := engine.CheckNightOwlAchievement(1, answeredAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 1 {
+		t.Errorf("Expected night_owl with default timezone UTC+5 (03:30 local), got %v", awarded)
+	}
+}
+
+func TestStreak_CountsDaysInUserTimezone(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	// По UTC это два дня (10 и 11 января), а в Москве ответ в 22:30 UTC — уже
+	// 01:30 следующего дня, и дней получается три
+	completions := []time.Time{
+		time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 10, 22, 30, 0, 0, time.UTC),
+		time.Date(2024, 1, 11, 22, 30, 0, 0, time.UTC),
+	}
+	steps := make([]*models.Step, len(completions))
+	for i := range completions {
+		steps[i] = createTestStep(t, stepRepo, i+1)
+	}
+
+	for userID, timezone := range map[int64]string{1: "Europe/Moscow", 2: "UTC"} {
+		createTestUserForEngine(t, userRepo, userID)
+		if err := userRepo.SetTimezone(userID, timezone); err != nil {
+			t.Fatal(err)
+		}
+		for i, completedAt := range completions {
+			createUserProgress(t, progressRepo, userID, steps[i].ID, models.StatusApproved, &completedAt)
+		}
+	}
+
+	awarded, err := engine.CheckStreakAchievement(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 1 || awarded[0] != "streak" {
+		t.Errorf("Expected streak for three local days in Moscow, got %v", awarded)
+	}
+
+	awarded, err = engine.CheckStreakAchievement(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 0 {
+		t.Errorf("Expected no streak for two UTC days, got %v", awarded)
+	}
+}
+
+func TestCompletionistAchievement(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	mainStep := createTestStep(t, stepRepo, 1)
+	asterisk1 := createTestStep(t, stepRepo, 2)
+	asterisk2 := createTestStep(t, stepRepo, 3)
+	for _, step := range []*models.Step{asterisk1, asterisk2} {
+		if err := stepRepo.SetAsterisk(step.ID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	completedAt := time.Now().Add(-time.Hour)
+
+	createTestUserForEngine(t, userRepo, 1)
+	for _, step := range []*models.Step{mainStep, asterisk1, asterisk2} {
+		createUserProgress(t, progressRepo, 1, step.ID, models.StatusApproved, &completedAt)
+	}
+
+	createTestUserForEngine(t, userRepo, 2)
+	createUserProgress(t, progressRepo, 2, mainStep.ID, models.StatusApproved, &completedAt)
+	createUserProgress(t, progressRepo, 2, asterisk1.ID, models.StatusApproved, &completedAt)
+	createUserProgress(t, progressRepo, 2, asterisk2.ID, models.StatusSkipped, &completedAt)
+
+	awarded, err := engine.EvaluateCompletionAchievements(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, key := range awarded {
+		if key == "completionist" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected user who answered all asterisk steps to get completionist, got %v", awarded)
+	}
+
+	if _, err := engine.OnQuestCompleted(2); err != nil {
+		t.Fatal(err)
+	}
+	has, err := achievementRepo.HasUserAchievement(2, "completionist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Error("Expected user who skipped an asterisk step not to get completionist")
+	}
+}
+
+func TestWinnerAchievements_TopFiveConfiguration(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	for _, position := range []int{4, 5} {
+		if err := achievementRepo.Create(&models.Achievement{
+			Key:        fmt.Sprintf("winner_%d", position),
+			Name:       fmt.Sprintf("%d-й победитель", position),
+			Category:   models.CategoryUnique,
+			Type:       models.TypeUnique,
+			IsUnique:   true,
+			Conditions: models.AchievementConditions{CompletionPosition: &position},
+			IsActive:   true,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step := createTestStep(t, stepRepo, 1)
+	baseTime := time.Now().Add(-time.Hour)
+	for i := 1; i <= 6; i++ {
+		userID := int64(i)
+		createTestUserForEngine(t, userRepo, userID)
+		completedAt := baseTime.Add(time.Duration(i) * time.Minute)
+		createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, &completedAt)
+
+		if _, err := engine.EvaluateWinnerAchievements(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i <= 5; i++ {
+		holders, err := achievementRepo.GetAchievementHolders(fmt.Sprintf("winner_%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(holders) != 1 || holders[0] != int64(i) {
+			t.Errorf("winner_%d: expected holder %d, got %v", i, i, holders)
+		}
+	}
+
+	userAchievements, err := achievementRepo.GetUserAchievements(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userAchievements) != 0 {
+		t.Errorf("Sixth user should not get a winner achievement, got %d", len(userAchievements))
+	}
+}
+
+func TestRecalculatePositionAchievements_TopFiveConfiguration(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	for _, key := range []string{"sixth_place", "seventh_place", "eighth_place", "ninth_place", "tenth_place"} {
+		achievement, err := achievementRepo.GetByKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		achievement.IsActive = false
+		if err := achievementRepo.Update(achievement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step := createTestStep(t, stepRepo, 1)
+	baseTime := time.Now().Add(-time.Hour)
+	for i := 1; i <= 7; i++ {
+		userID := int64(i)
+		createTestUserForEngine(t, userRepo, userID)
+		completedAt := baseTime.Add(time.Duration(i) * time.Minute)
+		createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, &completedAt)
+	}
+
+	// Место первого занято не тем участником — пересчёт должен его исправить
+	pioneer, err := achievementRepo.GetByKey("pioneer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := achievementRepo.AssignToUser(7, pioneer.ID, baseTime, false); err != nil {
+		t.Fatal(err)
+	}
+
+	awarded, err := engine.RecalculatePositionAchievements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 5 {
+		t.Errorf("Expected 5 reassigned achievements, got %v", awarded)
+	}
+
+	expected := []string{"pioneer", "second_place", "third_place", "fourth_place", "fifth_place"}
+	for i, key := range expected {
+		holders, err := achievementRepo.GetAchievementHolders(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(holders) != 1 || holders[0] != int64(i+1) {
+			t.Errorf("%s: expected holder %d, got %v", key, i+1, holders)
+		}
+	}
+
+	holders, err := achievementRepo.GetAchievementHolders("sixth_place")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holders) != 0 {
+		t.Errorf("Inactive sixth_place should not be assigned, got %v", holders)
+	}
+}
+
+// setupEvaluationModeTest создаёт участника с тремя одобренными шагами из десяти
+func setupEvaluationModeTest(t *testing.T, mode AchievementEvaluationMode) (*AchievementEngine, *db.DBQueue, []*models.Step, func()) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	engine.SetEvaluationMode(mode)
+
+	createTestUserForEngine(t, userRepo, 1)
+	var steps []*models.Step
+	for i := 1; i <= 10; i++ {
+		steps = append(steps, createTestStep(t, stepRepo, i))
+	}
+
+	baseTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		completedAt := baseTime.Add(time.Duration(i) * time.Minute)
+		createUserProgress(t, progressRepo, 1, steps[i].ID, models.StatusApproved, &completedAt)
+	}
+
+	return engine, queue, steps, cleanup
+}
+
+func queriesForApproval(engine *AchievementEngine, queue *db.DBQueue, stepID int64, answeredAt time.Time) int64 {
+	before := queue.ExecutedCount()
+	engine.EvaluateOnApproval(1, stepID, false, answeredAt)
+	return queue.ExecutedCount() - before
+}
+
+func TestEvaluateOnApproval_TargetedModeUsesFewerQueries(t *testing.T) {
+	answeredAt := time.Date(2025, 1, 1, 12, 5, 0, 0, time.UTC)
+
+	fullEngine, fullQueue, fullSteps, cleanupFull := setupEvaluationModeTest(t, EvaluationModeFull)
+	defer cleanupFull()
+	fullQueries := queriesForApproval(fullEngine, fullQueue, fullSteps[2].ID, answeredAt)
+
+	targetedEngine, targetedQueue, targetedSteps, cleanupTargeted := setupEvaluationModeTest(t, EvaluationModeTargeted)
+	defer cleanupTargeted()
+	targetedQueries := queriesForApproval(targetedEngine, targetedQueue, targetedSteps[2].ID, answeredAt)
+
+	t.Logf("queries per answer: full=%d targeted=%d", fullQueries, targetedQueries)
+	if targetedQueries >= fullQueries {
+		t.Errorf("Expected targeted mode to use fewer queries than full (%d), got %d", fullQueries, targetedQueries)
+	}
+}
+
+func TestEvaluateOnApproval_DebounceSkipsRepeatedFullScan(t *testing.T) {
+	engine, queue, steps, cleanup := setupEvaluationModeTest(t, EvaluationModeFull)
+	defer cleanup()
+	engine.SetEvaluationDebounce(time.Minute)
+
+	answeredAt := time.Date(2025, 1, 1, 12, 5, 0, 0, time.UTC)
+	first := queriesForApproval(engine, queue, steps[2].ID, answeredAt)
+	debounced := queriesForApproval(engine, queue, steps[2].ID, answeredAt.Add(10*time.Second))
+	afterWindow := queriesForApproval(engine, queue, steps[2].ID, answeredAt.Add(2*time.Minute))
+
+	if debounced >= first {
+		t.Errorf("Expected answer within debounce window to use fewer queries than %d, got %d", first, debounced)
+	}
+	if afterWindow <= debounced {
+		t.Errorf("Expected full evaluation after debounce window (more than %d queries), got %d", debounced, afterWindow)
+	}
+}
+
+func TestUseTargetedEvaluation_EvictsExpiredTimestamps(t *testing.T) {
+	engine := &AchievementEngine{evaluationMode: EvaluationModeFull}
+	engine.SetEvaluationDebounce(time.Minute)
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for userID := int64(1); userID <= 100; userID++ {
+		engine.useTargetedEvaluation(userID, start)
+	}
+	if !engine.useTargetedEvaluation(1, start.Add(30*time.Second)) {
+		t.Error("Expected an answer inside the debounce window to be checked in targeted mode")
+	}
+
+	if engine.useTargetedEvaluation(101, start.Add(2*time.Minute)) {
+		t.Error("Expected a full evaluation for a user without a recent one")
+	}
+	if len(engine.lastFullEvaluation) != 1 {
+		t.Errorf("Expected expired timestamps to be evicted, %d remain", len(engine.lastFullEvaluation))
+	}
+}
+
+func TestEvaluateOnApproval_TargetedModeAwardsReachedThreshold(t *testing.T) {
+	engine, _, steps, cleanup := setupEvaluationModeTest(t, EvaluationModeTargeted)
+	defer cleanup()
+	progressRepo := db.NewProgressRepository(engine.queue)
+
+	baseTime := time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)
+	for i := 3; i < 5; i++ {
+		completedAt := baseTime.Add(time.Duration(i) * time.Minute)
+		createUserProgress(t, progressRepo, 1, steps[i].ID, models.StatusApproved, &completedAt)
+	}
+
+	awarded := engine.EvaluateOnApproval(1, steps[4].ID, false, baseTime.Add(5*time.Minute))
+	found := false
+	for _, key := range awarded {
+		if key == "beginner_5" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected beginner_5 on the fifth correct answer, got %v", awarded)
+	}
+}
+
+func TestEvaluateOnApproval_TargetedModeAwardsPositionOnFirstAnswer(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(db.NewAchievementRepository(queue), userRepo, progressRepo, stepRepo, queue)
+	engine.SetEvaluationMode(EvaluationModeTargeted)
+
+	createTestUserForEngine(t, userRepo, 1)
+	step := createTestStep(t, stepRepo, 1)
+	createTestStep(t, stepRepo, 2)
+
+	completedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	createUserProgress(t, progressRepo, 1, step.ID, models.StatusApproved, &completedAt)
+
+	awarded := engine.EvaluateOnApproval(1, step.ID, false, completedAt)
+	found := false
+	for _, key := range awarded {
+		if key == "pioneer" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected pioneer on the first correct answer, got %v", awarded)
+	}
+}
+
+func TestParseEvaluationMode(t *testing.T) {
+	cases := map[string]AchievementEvaluationMode{
+		"":          EvaluationModeFull,
+		"full":      EvaluationModeFull,
+		" Targeted": EvaluationModeTargeted,
+	}
+	for input, expected := range cases {
+		mode, err := ParseEvaluationMode(input)
+		if err != nil || mode != expected {
+			t.Errorf("ParseEvaluationMode(%q) = %q, %v; want %q", input, mode, err, expected)
+		}
+	}
+	if _, err := ParseEvaluationMode("lazy"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
+
+func TestPurgeOldAnswers_KeepsCompletionStatsAndAchievements(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	achievementRepo := db.NewAchievementRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	statsService := NewStatisticsService(queue, stepRepo, progressRepo, userRepo)
+
+	userID := int64(1)
+	createTestUserForEngine(t, userRepo, userID)
+	for i := 1; i <= 3; i++ {
+		step := createTestStep(t, stepRepo, i)
+		for attempt := 0; attempt < i; attempt++ {
+			if _, err := answerRepo.CreateTextAnswer(userID, step.ID, "answer", attempt == 0 && i == 2); err != nil {
+				t.Fatal(err)
+			}
+		}
+		createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, nil)
+	}
+
+	// Ответы давали два месяца назад в течение часа
+	_, err := queue.Execute(func(db *sql.DB) (any, error) {
+		return db.Exec(`UPDATE user_answers SET created_at = datetime('now', '-60 days', '+' || id || ' minutes')`)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := engine.OnQuestCompleted(userID); err != nil {
+		t.Fatal(err)
+	}
+	achievementsBefore, err := achievementRepo.CountUserAchievements(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statsBefore, err := engine.GetCompletionStats(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summaryBefore := statsService.FormatCompletionStats(userID)
+
+	result, err := db.PurgeOldAnswers(queue, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UserAnswers != 6 {
+		t.Fatalf("Expected all 6 answers to be purged, got %s", result)
+	}
+
+	statsAfter, err := engine.GetCompletionStats(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statsAfter.TotalAnswers != statsBefore.TotalAnswers || statsAfter.HintsUsed != statsBefore.HintsUsed ||
+		statsAfter.CompletedSteps != statsBefore.CompletedSteps || statsAfter.CompletionTimeMinutes != statsBefore.CompletionTimeMinutes {
+		t.Errorf("Expected completion stats to survive the purge, before %+v, after %+v", statsBefore, statsAfter)
+	}
+	if summary := statsService.FormatCompletionStats(userID); summary != summaryBefore {
+		t.Errorf("Expected the same completion summary, before:\n%s\nafter:\n%s", summaryBefore, summary)
+	}
+
+	if _, err := engine.OnQuestCompleted(userID); err != nil {
+		t.Fatal(err)
+	}
+	achievementsAfter, err := achievementRepo.CountUserAchievements(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if achievementsAfter != achievementsBefore {
+		t.Errorf("Expected %d achievements after purge, got %d", achievementsBefore, achievementsAfter)
+	}
+}
+
+func TestQualifiesForFlawless(t *testing.T) {
+	const limit = 15
+	flawless := func() *CompletionStats {
+		return &CompletionStats{
+			IsCompleted:           true,
+			TotalSteps:            5,
+			CompletedSteps:        5,
+			TotalAnswers:          5,
+			CorrectAnswers:        5,
+			CompletionTimeMinutes: limit - 1,
+			CompletionTimeKnown:   true,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*CompletionStats)
+		want   bool
+	}{
+		{name: "без ошибок и подсказок", modify: func(*CompletionStats) {}, want: true},
+		{name: "одна ошибка", modify: func(s *CompletionStats) { s.TotalAnswers++ }, want: false},
+		{name: "одна подсказка", modify: func(s *CompletionStats) { s.HintsUsed = 1 }, want: false},
+		{name: "пропущенный шаг", modify: func(s *CompletionStats) {
+			s.CompletedSteps--
+			s.CorrectAnswers--
+			s.TotalAnswers--
+			s.IsCompleted = false
+		}, want: false},
+		{name: "время равно лимиту", modify: func(s *CompletionStats) { s.CompletionTimeMinutes = limit }, want: false},
+		{name: "время неизвестно", modify: func(s *CompletionStats) { s.CompletionTimeKnown = false }, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := flawless()
+			tt.modify(stats)
+			if got := QualifiesForFlawless(stats, limit); got != tt.want {
+				t.Errorf("QualifiesForFlawless(%+v, %d) = %v, expected %v", *stats, limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlawlessAchievement_CombinedConditions(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	engine.SetFlawlessTimeLimit(15)
+
+	steps := []*models.Step{createTestStep(t, stepRepo, 1), createTestStep(t, stepRepo, 2)}
+
+	complete := func(userID int64, minutes int, withHint bool) {
+		createTestUserForEngine(t, userRepo, userID)
+		start := time.Now().Add(-2 * time.Hour)
+		for i, step := range steps {
+			completedAt := start.Add(time.Duration(i*minutes) * time.Minute)
+			createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, &completedAt)
+			createUserAnswer(t, queue, userID, step.ID, withHint && i == 0, completedAt)
+		}
+	}
+
+	tests := []struct {
+		userID       int64
+		minutes      int
+		withHint     bool
+		wantFlawless bool
+		wantBrain    bool
+	}{
+		{userID: 1, minutes: 3, wantFlawless: true, wantBrain: true},
+		{userID: 2, minutes: 20, wantFlawless: false, wantBrain: true},
+		{userID: 3, minutes: 3, withHint: true, wantFlawless: false, wantBrain: false},
+	}
+
+	for _, tt := range tests {
+		complete(tt.userID, tt.minutes, tt.withHint)
+
+		speedBefore := completionSpeedAchievements(t, achievementRepo, tt.userID)
+		if _, err := engine.EvaluateCompositeAchievements(tt.userID); err != nil {
+			t.Fatal(err)
+		}
+
+		hasFlawless, _ := achievementRepo.HasUserAchievement(tt.userID, "flawless")
+		if hasFlawless != tt.wantFlawless {
+			t.Errorf("User %d (%d min, hint=%v): expected flawless=%v, got %v", tt.userID, tt.minutes, tt.withHint, tt.wantFlawless, hasFlawless)
+		}
+		hasBrain, _ := achievementRepo.HasUserAchievement(tt.userID, "super_brain")
+		if hasBrain != tt.wantBrain {
+			t.Errorf("User %d: expected super_brain=%v, got %v", tt.userID, tt.wantBrain, hasBrain)
+		}
+		if speedAfter := completionSpeedAchievements(t, achievementRepo, tt.userID); speedAfter != speedBefore {
+			t.Errorf("User %d: flawless evaluation changed speed achievements from %v to %v", tt.userID, speedBefore, speedAfter)
+		}
+	}
+}
+
+func completionSpeedAchievements(t *testing.T, repo *db.AchievementRepository, userID int64) [3]bool {
+	t.Helper()
+	var result [3]bool
+	for i, key := range []string{"cheater", "lightning", "rocket"} {
+		has, err := repo.HasUserAchievement(userID, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result[i] = has
+	}
+	return result
+}
+
+func TestResetCategory_CompletionKeepsProgress(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	createTestUserForEngine(t, userRepo, 1)
+	createTestUserForEngine(t, userRepo, 2)
+	legend, err := achievementRepo.GetByKey("legend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range append(legend.Conditions.RequiredAchievements, "super_collector", "legend") {
+		assignAchievementToUser(t, achievementRepo, 1, key, time.Now())
+	}
+	keptKeys := []string{"beginner_5", "experienced_10", "advanced_15", "expert_20", "master_25", "pioneer"}
+	assignAchievementToUser(t, achievementRepo, 2, "winner", time.Now())
+
+	if err := engine.ResetCategory(1, models.CategoryCompletion); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"winner", "perfect_path"} {
+		if has, _ := achievementRepo.HasUserAchievement(1, key); has {
+			t.Errorf("Expected completion achievement %s to be removed", key)
+		}
+	}
+	for _, key := range append(keptKeys, "super_collector") {
+		if has, _ := achievementRepo.HasUserAchievement(1, key); !has {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+	if has, _ := achievementRepo.HasUserAchievement(1, "legend"); has {
+		t.Error("Expected legend to be revoked after its completion prerequisites were removed")
+	}
+	if has, _ := achievementRepo.HasUserAchievement(2, "winner"); !has {
+		t.Error("Expected other users' achievements to be untouched")
+	}
+}
+
+func TestWinnerAchievements_LateRecordedCompletionTakesItsPlace(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	step := createTestStep(t, stepRepo, 1)
+	baseTime := time.Now().Add(-time.Hour)
+	for _, userID := range []int64{1, 2} {
+		createTestUserForEngine(t, userRepo, userID)
+	}
+
+	var changes []WinnerPlaceChange
+	engine.SetWinnerPlaceListener(func(c []WinnerPlaceChange) {
+		changes = append(changes, c...)
+	})
+
+	// Участник 2 завершил позже, но его ответ записан и оценён первым
+	later := baseTime.Add(time.Minute)
+	createUserProgress(t, progressRepo, 2, step.ID, models.StatusApproved, &later)
+	if _, err := engine.EvaluateWinnerAchievements(2); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("Expected no place changes for the first completion, got %+v", changes)
+	}
+
+	createUserProgress(t, progressRepo, 1, step.ID, models.StatusApproved, &baseTime)
+	awarded, err := engine.EvaluateWinnerAchievements(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 1 || awarded[0] != "winner_1" {
+		t.Errorf("Expected user 1 to be awarded winner_1, got %v", awarded)
+	}
+
+	for place, userID := range map[int]int64{1: 1, 2: 2} {
+		holders, err := achievementRepo.GetAchievementHolders(WinnerAchievementKeys[place])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(holders) != 1 || holders[0] != userID {
+			t.Errorf("%s: expected holder %d, got %v", WinnerAchievementKeys[place], userID, holders)
+		}
+	}
+
+	// Участник 2 уже получил уведомление о первом месте: об отзыве и о втором месте
+	// нужно сообщить отдельно
+	want := []WinnerPlaceChange{
+		{AchievementKey: WinnerAchievementKeys[1], Revoked: []int64{2}},
+		{AchievementKey: WinnerAchievementKeys[2], Awarded: 2},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Expected place changes %+v, got %+v", want, changes)
+	}
+}
+
+func TestWinnerAchievements_ConcurrentCompletions(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	step := createTestStep(t, stepRepo, 1)
+	baseTime := time.Now().Add(-time.Hour)
+
+	// Участник i завершил квест через i минут; горутины стартуют в обратном порядке,
+	// чтобы порядок оценки не совпадал с порядком завершения
+	const numUsers = 8
+	for i := int64(1); i <= numUsers; i++ {
+		createTestUserForEngine(t, userRepo, i)
+	}
+
+	var wg sync.WaitGroup
+	for i := int64(numUsers); i >= 1; i-- {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			completedAt := baseTime.Add(time.Duration(userID) * time.Minute)
+			progress := &models.UserProgress{UserID: userID, StepID: step.ID, Status: models.StatusApproved, CompletedAt: &completedAt}
+			if err := progressRepo.Create(progress); err != nil {
+				t.Errorf("Create progress for user %d: %v", userID, err)
+				return
+			}
+			if err := progressRepo.Update(progress); err != nil {
+				t.Errorf("Update progress for user %d: %v", userID, err)
+				return
+			}
+			if _, err := engine.OnQuestCompleted(userID); err != nil {
+				t.Errorf("OnQuestCompleted for user %d: %v", userID, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for place := 1; place <= 3; place++ {
+		holders, err := achievementRepo.GetAchievementHolders(WinnerAchievementKeys[place])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(holders) != 1 || holders[0] != int64(place) {
+			t.Errorf("%s: expected holder %d by completion time, got %v", WinnerAchievementKeys[place], place, holders)
+		}
+	}
+
+	for userID := int64(4); userID <= numUsers; userID++ {
+		for place := 1; place <= 3; place++ {
+			if has, _ := achievementRepo.HasUserAchievement(userID, WinnerAchievementKeys[place]); has {
+				t.Errorf("User %d completed %d-th and should not hold %s", userID, userID, WinnerAchievementKeys[place])
+			}
+		}
+	}
+}
+
+func TestConfirmCompletion_DefersCompletionAchievements(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	step := createTestStep(t, stepRepo, 1)
+	createTestUserForEngine(t, userRepo, 1)
+	completedAt := time.Now().Add(-time.Minute)
+	createUserProgress(t, progressRepo, 1, step.ID, models.StatusApproved, &completedAt)
+
+	if err := engine.MarkCompletionPending(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if awarded := engine.EvaluateOnApproval(1, step.ID, true, completedAt); len(awarded) != 0 {
+		t.Errorf("Expected no achievements before confirmation, got %v", awarded)
+	}
+	if awarded, err := engine.EvaluateWinnerAchievements(1); err != nil || len(awarded) != 0 {
+		t.Errorf("Expected no winner place before confirmation, got %v (err %v)", awarded, err)
+	}
+	stats, err := engine.GetCompletionStats(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.IsCompleted {
+		t.Error("Expected quest to stay incomplete until confirmation")
+	}
+
+	confirmed, awarded, err := engine.ConfirmCompletion(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !confirmed {
+		t.Fatal("Expected pending completion to be confirmed")
+	}
+	for _, key := range []string{CompletionAchievementKeys["winner"], WinnerAchievementKeys[1]} {
+		if !slices.Contains(awarded, key) {
+			t.Errorf("Expected %s to be awarded on confirmation, got %v", key, awarded)
+		}
+	}
+
+	confirmed, awarded, err = engine.ConfirmCompletion(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed || len(awarded) != 0 {
+		t.Errorf("Expected repeated confirmation to do nothing, got confirmed=%v awarded=%v", confirmed, awarded)
+	}
+}
+
+func TestCheckHelperAchievement_UsefulReportsThreshold(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	reportRepo := db.NewStepReportRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, db.NewProgressRepository(queue), db.NewStepRepository(queue), queue)
+
+	createTestUserForEngine(t, userRepo, 1)
+	var reportIDs []int64
+	for i := 0; i < DefaultHelperReports+1; i++ {
+		reportID, err := reportRepo.Create(1, 0, fmt.Sprintf("проблема %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reportIDs = append(reportIDs, reportID)
+	}
+
+	// Жалобы без отметки администратора не засчитываются
+	awarded, err := engine.CheckHelperAchievement(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 0 {
+		t.Fatalf("Expected no award before reports are marked useful, got %v", awarded)
+	}
+
+	for i, reportID := range reportIDs[:DefaultHelperReports] {
+		userID, changed, err := reportRepo.MarkUseful(reportID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userID != 1 || !changed {
+			t.Fatalf("Expected report %d of user 1 to be marked, got user %d, changed %v", reportID, userID, changed)
+		}
+
+		awarded, err := engine.CheckHelperAchievement(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reached := i+1 == DefaultHelperReports
+		if reached != (len(awarded) == 1 && awarded[0] == "helper") {
+			t.Errorf("After %d useful reports: got %v", i+1, awarded)
+		}
+	}
+
+	// Повторная отметка не меняет жалобу, а достижение не выдаётся дважды
+	if _, changed, err := reportRepo.MarkUseful(reportIDs[0]); err != nil || changed {
+		t.Errorf("Expected repeated mark to be a no-op, got changed %v, err %v", changed, err)
+	}
+	if _, _, err := reportRepo.MarkUseful(reportIDs[DefaultHelperReports]); err != nil {
+		t.Fatal(err)
+	}
+	awarded, err = engine.CheckHelperAchievement(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 0 {
+		t.Errorf("Expected helper to be awarded only once, got %v", awarded)
+	}
+}
+
+func TestPercentThresholds_TenStepQuest(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	engine.SetThresholdMode(ThresholdModePercent)
+
+	var steps []*models.Step
+	for i := 1; i <= 10; i++ {
+		steps = append(steps, createTestStep(t, stepRepo, i))
+	}
+
+	const userID = 1
+	createTestUserForEngine(t, userRepo, userID)
+
+	expected := map[int]string{
+		2:  "beginner_5",
+		4:  "experienced_10",
+		6:  "advanced_15",
+		8:  "expert_20",
+		10: "master_25",
+	}
+
+	baseTime := time.Now().Add(-time.Hour)
+	for i, step := range steps {
+		completedAt := baseTime.Add(time.Duration(i) * time.Minute)
+		createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, &completedAt)
+
+		awarded, err := engine.OnCorrectAnswer(userID)
+		if err != nil {
+			t.Fatalf("OnCorrectAnswer failed: %v", err)
+		}
+		full, err := engine.EvaluateUserAchievements(userID)
+		if err != nil {
+			t.Fatalf("EvaluateUserAchievements failed: %v", err)
+		}
+		awarded = append(awarded, full...)
+
+		var progress []string
+		for _, key := range ProgressAchievementKeys {
+			if slices.Contains(awarded, key) {
+				progress = append(progress, key)
+			}
+		}
+
+		want, ok := expected[i+1]
+		switch {
+		case ok && (len(progress) != 1 || progress[0] != want):
+			t.Errorf("At %d correct answers expected %s, got %v", i+1, want, progress)
+		case !ok && len(progress) > 0:
+			t.Errorf("At %d correct answers expected no progress achievement, got %v", i+1, progress)
+		}
+	}
+
+	threshold, key, err := engine.GetNextProgressThreshold(userID)
+	if err != nil || threshold != 0 || key != "" {
+		t.Errorf("Expected no next threshold after the whole quest, got %d %q (err %v)", threshold, key, err)
+	}
+}
+
+func TestPercentThresholds_Hints(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	engine.SetThresholdMode(ThresholdModePercent)
+
+	var steps []*models.Step
+	for i := 1; i <= 10; i++ {
+		steps = append(steps, createTestStep(t, stepRepo, i))
+	}
+	createTestUserForEngine(t, userRepo, 1)
+
+	baseTime := time.Now().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		createUserAnswer(t, queue, 1, steps[i+1].ID, true, baseTime.Add(time.Duration(i)*time.Minute))
+	}
+
+	awarded, err := engine.EvaluateHintAchievements(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(awarded, "hint_5") || !slices.Contains(awarded, "hint_10") || slices.Contains(awarded, "hint_15") {
+		t.Errorf("Expected hint_5 and hint_10 for 4 hints out of 10 steps, got %v", awarded)
+	}
+}
+
+func TestScaleThreshold(t *testing.T) {
+	cases := []struct {
+		percent, steps, want int
+	}{
+		{20, 10, 2},
+		{100, 10, 10},
+		{20, 3, 1},
+		{60, 7, 5},
+		{20, 0, 1},
+	}
+	for _, c := range cases {
+		if got := ScaleThreshold(c.percent, c.steps); got != c.want {
+			t.Errorf("ScaleThreshold(%d, %d) = %d, want %d", c.percent, c.steps, got, c.want)
+		}
+	}
+
+	if mode, err := ParseThresholdMode(""); err != nil || mode != ThresholdModeFixed {
+		t.Errorf("Expected fixed mode by default, got %q (err %v)", mode, err)
+	}
+	if mode, err := ParseThresholdMode(" Percent "); err != nil || mode != ThresholdModePercent {
+		t.Errorf("Expected percent mode, got %q (err %v)", mode, err)
+	}
+	if _, err := ParseThresholdMode("relative"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
+
+func TestPlausibleCompletionTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	questStart := now.Add(-3 * time.Hour)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+
+	tests := []struct {
+		name     string
+		first    *time.Time
+		last     *time.Time
+		expected time.Duration
+		ok       bool
+	}{
+		{"regular", at(-2 * time.Hour), at(-90 * time.Minute), 30 * time.Minute, true},
+		{"single answer", at(-time.Hour), at(-time.Hour), 0, true},
+		{"small clock skew", at(-10 * time.Minute), at(30 * time.Second), 10*time.Minute + 30*time.Second, true},
+		{"missing answer time", nil, at(-time.Hour), 0, false},
+		{"unparsed answer time", &time.Time{}, at(-time.Hour), 0, false},
+		{"negative", at(-time.Hour), at(-2 * time.Hour), 0, false},
+		{"answer from the future", at(-time.Hour), at(time.Hour), 0, false},
+		{"longer than the quest", at(-5 * time.Hour), at(-time.Hour), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duration, ok := plausibleCompletionTime(tt.first, tt.last, questStart, now)
+			if ok != tt.ok || duration != tt.expected {
+				t.Errorf("plausibleCompletionTime = (%v, %v), expected (%v, %v)", duration, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCompletionStats_SkewedAnswerTimestamps(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	steps := []*models.Step{createTestStep(t, stepRepo, 1), createTestStep(t, stepRepo, 2), createTestStep(t, stepRepo, 3)}
+	start := time.Now().Add(-2 * time.Hour)
+
+	t.Run("out of order and duplicate answers", func(t *testing.T) {
+		const userID = 1001
+		createTestUserForEngine(t, userRepo, userID)
+		for _, step := range steps {
+			createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, nil)
+		}
+		// Ответы записаны не по порядку, а повтор при ретрае сохранил тот же ответ дважды
+		for _, offset := range []time.Duration{40 * time.Minute, 5 * time.Minute, 40 * time.Minute, 20 * time.Minute} {
+			createUserAnswer(t, queue, userID, steps[0].ID, false, start.Add(offset))
+		}
+
+		stats, err := engine.GetCompletionStats(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stats.CompletionTimeKnown || stats.CompletionTimeMinutes != 35 {
+			t.Errorf("Expected completion time of 35 minutes, got %d (known=%v)", stats.CompletionTimeMinutes, stats.CompletionTimeKnown)
+		}
+	})
+
+	t.Run("answer from the future does not award cheater", func(t *testing.T) {
+		const userID = 1002
+		createTestUserForEngine(t, userRepo, userID)
+		for _, step := range steps {
+			createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, nil)
+		}
+		createUserAnswer(t, queue, userID, steps[0].ID, false, time.Now().Add(2*time.Hour))
+		createUserAnswer(t, queue, userID, steps[1].ID, false, time.Now().Add(2*time.Hour+time.Minute))
+
+		stats, err := engine.GetCompletionStats(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.CompletionTimeKnown {
+			t.Errorf("Expected the skewed completion time to be ignored, got %d minutes", stats.CompletionTimeMinutes)
+		}
+
+		awarded, err := engine.EvaluateCompletionAchievements(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range awarded {
+			if key == "cheater" || key == "lightning" || key == "rocket" {
+				t.Errorf("Expected no speed achievement for an implausible time, got %v", awarded)
+			}
+		}
+		if !slices.Contains(awarded, "winner") {
+			t.Errorf("Expected the completion itself to still count, got %v", awarded)
+		}
+	})
+}
+
+func TestCompletionStats_MinCompletionSteps(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	engine.SetSettingsRepository(settingsRepo)
+
+	const userID = 1001
+	createTestUserForEngine(t, userRepo, userID)
+	var steps []*models.Step
+	for order := 1; order <= 4; order++ {
+		steps = append(steps, createTestStep(t, stepRepo, order))
+	}
+
+	isCompleted := func() bool {
+		t.Helper()
+		stats, err := engine.GetCompletionStats(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats.IsCompleted
+	}
+	setMinSteps := func(steps int) {
+		t.Helper()
+		if err := settingsRepo.SetMinCompletionSteps(steps); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setMinSteps(2)
+	createUserProgress(t, progressRepo, userID, steps[0].ID, models.StatusApproved, nil)
+	if isCompleted() {
+		t.Error("Expected the quest to be unfinished below the configured minimum")
+	}
+	if awarded := engine.EvaluateOnApproval(userID, steps[0].ID, false, time.Now()); slices.Contains(awarded, "winner") {
+		t.Errorf("Expected no completion achievements below the minimum, got %v", awarded)
+	}
+
+	createUserProgress(t, progressRepo, userID, steps[1].ID, models.StatusApproved, nil)
+	if !isCompleted() {
+		t.Error("Expected the quest to be completed at the configured minimum")
+	}
+	if awarded := engine.EvaluateOnApproval(userID, steps[1].ID, false, time.Now()); !slices.Contains(awarded, "winner") {
+		t.Errorf("Expected the winner achievement at the configured minimum, got %v", awarded)
+	}
+
+	setMinSteps(0)
+	if isCompleted() {
+		t.Error("Expected all active steps to be required without a minimum")
+	}
+
+	setMinSteps(10)
+	if isCompleted() {
+		t.Error("Expected a minimum above the number of steps to require all steps")
+	}
+	for _, step := range steps[2:] {
+		createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, nil)
+	}
+	if !isCompleted() {
+		t.Error("Expected the quest to be completed after all steps")
+	}
+}
+
+func TestRecomputeAllAchievements_ThresholdChange(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+
+	var steps []*models.Step
+	for i := 1; i <= 10; i++ {
+		steps = append(steps, createTestStep(t, stepRepo, i))
+	}
+
+	const userID, idleUserID = 1, 2
+	createTestUserForEngine(t, userRepo, userID)
+	createTestUserForEngine(t, userRepo, idleUserID)
+
+	baseTime := time.Now().Add(-time.Hour)
+	for i, step := range steps[:4] {
+		completedAt := baseTime.Add(time.Duration(i) * time.Minute)
+		createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, &completedAt)
+	}
+
+	if _, err := engine.EvaluateProgressAchievements(userID); err != nil {
+		t.Fatalf("EvaluateProgressAchievements failed: %v", err)
+	}
+	if has, _ := achievementRepo.HasUserAchievement(userID, "beginner_5"); has {
+		t.Fatal("Expected no progress achievement for 4 answers with fixed thresholds")
+	}
+
+	// В режиме процентов пороги для 10 шагов снижаются до 2, 4, 6...
+	engine.SetThresholdMode(ThresholdModePercent)
+
+	summary, err := engine.RecomputeAllAchievements(false)
+	if err != nil {
+		t.Fatalf("RecomputeAllAchievements failed: %v", err)
+	}
+	if summary.UsersChecked != 2 || summary.UsersChanged != 1 {
+		t.Errorf("Expected 2 users checked and 1 changed, got %d and %d", summary.UsersChecked, summary.UsersChanged)
+	}
+	for _, key := range []string{"beginner_5", "experienced_10"} {
+		if summary.Awarded[key] != 1 {
+			t.Errorf("Expected %s to be awarded once, got %d", key, summary.Awarded[key])
+		}
+		if has, _ := achievementRepo.HasUserAchievement(userID, key); !has {
+			t.Errorf("Expected user to hold %s after recompute", key)
+		}
+	}
+	if summary.Awarded["advanced_15"] != 0 || len(summary.Revoked) != 0 {
+		t.Errorf("Unexpected changes: awarded %v, revoked %v", summary.Awarded, summary.Revoked)
+	}
+
+	summary, err = engine.RecomputeAllAchievements(false)
+	if err != nil {
+		t.Fatalf("RecomputeAllAchievements failed: %v", err)
+	}
+	if summary.UsersChanged != 0 {
+		t.Errorf("Expected repeated recompute to change nothing, got %d users", summary.UsersChanged)
+	}
+
+	engine.SetThresholdMode(ThresholdModeFixed)
+
+	summary, err = engine.RecomputeAllAchievements(false)
+	if err != nil {
+		t.Fatalf("RecomputeAllAchievements failed: %v", err)
+	}
+	if len(summary.Revoked) != 0 {
+		t.Errorf("Expected nothing revoked without the flag, got %v", summary.Revoked)
+	}
+
+	summary, err = engine.RecomputeAllAchievements(true)
+	if err != nil {
+		t.Fatalf("RecomputeAllAchievements failed: %v", err)
+	}
+	for _, key := range []string{"beginner_5", "experienced_10"} {
+		if summary.Revoked[key] != 1 {
+			t.Errorf("Expected %s to be revoked once, got %d", key, summary.Revoked[key])
+		}
+		if has, _ := achievementRepo.HasUserAchievement(userID, key); has {
+			t.Errorf("Expected %s to be revoked from user", key)
+		}
+	}
+}
+
+func TestQuestCompletions_MinCompletionSteps(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	engine.SetSettingsRepository(settingsRepo)
+	resolver := NewStateResolver(stepRepo, progressRepo, userRepo)
+	resolver.SetSettingsRepository(settingsRepo)
+
+	var steps []*models.Step
+	for order := 1; order <= 3; order++ {
+		steps = append(steps, createTestStep(t, stepRepo, order))
+	}
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	approve := func(userID int64, step *models.Step, minutes int) {
+		completedAt := base.Add(time.Duration(minutes) * time.Minute)
+		createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, &completedAt)
+	}
+
+	// Первый участник одобрил два шага из трёх, второй — все три, но второй из них позже
+	createTestUserForEngine(t, userRepo, 1)
+	createTestUserForEngine(t, userRepo, 2)
+	approve(1, steps[0], 0)
+	approve(1, steps[1], 5)
+	approve(2, steps[0], -60)
+	approve(2, steps[1], 10)
+	approve(2, steps[2], 20)
+
+	completionOrder := func() []int64 {
+		t.Helper()
+		completions, err := engine.GetQuestCompletions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, completion := range completions {
+			ids = append(ids, completion.UserID)
+		}
+		return ids
+	}
+
+	if ids := completionOrder(); !slices.Equal(ids, []int64{2}) {
+		t.Errorf("Expected only the user who passed the last step without a minimum, got %v", ids)
+	}
+	if state, _ := resolver.ResolveState(1); state.IsCompleted {
+		t.Error("Expected the quest to continue without a minimum")
+	}
+
+	if err := settingsRepo.SetMinCompletionSteps(2); err != nil {
+		t.Fatal(err)
+	}
+	if ids := completionOrder(); !slices.Equal(ids, []int64{1, 2}) {
+		t.Errorf("Expected users ordered by reaching the minimum, got %v", ids)
+	}
+	if state, _ := resolver.ResolveState(1); !state.IsCompleted || state.CurrentStep != nil {
+		t.Errorf("Expected the quest to be completed at the minimum, got %+v", state)
+	}
+
+	statsService := NewStatisticsService(queue, stepRepo, progressRepo, userRepo)
+	statsService.SetSettingsRepository(settingsRepo)
+	if position, _ := statsService.GetUserCompletionPosition(2); position != 2 {
+		t.Errorf("Expected the second place by the minimum, got %d", position)
+	}
+}
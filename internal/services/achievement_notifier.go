@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"html"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/models"
@@ -16,6 +18,7 @@ type AchievementNotifier struct {
 	achievementRepo *db.AchievementRepository
 	msgManager      *MessageManager
 	stickerService  *StickerService
+	queue           *NotificationQueue
 }
 
 func NewAchievementNotifier(
@@ -32,6 +35,13 @@ func NewAchievementNotifier(
 	}
 }
 
+// SetNotificationQueue включает повторную отправку уведомлений, которые не удалось
+// доставить из-за временной ошибки
+func (n *AchievementNotifier) SetNotificationQueue(queue *NotificationQueue) {
+	n.queue = queue
+	queue.RegisterSender(NotificationAchievement, n.NotifyAchievement)
+}
+
 var categoryEmojis = map[models.AchievementCategory]string{
 	models.CategoryProgress:   "📈",
 	models.CategoryCompletion: "🏆",
@@ -56,6 +66,7 @@ var achievementEmojis = map[string]string{
 	"lightning":       "⚡",
 	"rocket":          "🚀",
 	"cheater":         "🃏",
+	"completionist":   "💯",
 	"photographer":    "📸",
 	"paparazzi":       "📷",
 	"bullseye":        "🎯",
@@ -71,6 +82,7 @@ var achievementEmojis = map[string]string{
 	"skeptic":         "🤨",
 	"super_collector": "🎁",
 	"super_brain":     "🧠",
+	"flawless":        "💎",
 	"legend":          "👑",
 	"winner_1":        "🥇",
 	"winner_2":        "🥈",
@@ -83,6 +95,10 @@ var achievementEmojis = map[string]string{
 	"asterisk":        "⭐",
 	"unseen":          "👁️",
 	"voice":           "📢",
+	"comeback":        "🪃",
+	"helper":          "🛟",
+	"night_owl":       "🦉",
+	"streak":          "📅",
 }
 
 func (n *AchievementNotifier) GetAchievementEmoji(achievement *models.Achievement) string {
@@ -130,6 +146,10 @@ func (n *AchievementNotifier) NotifyAchievement(ctx context.Context, userID int6
 		return fmt.Errorf("failed to get achievement %s: %w", achievementKey, err)
 	}
 
+	if !achievement.NotifyOnAward {
+		return nil
+	}
+
 	var stickerFileID string
 	if n.stickerService != nil {
 		emoji := n.GetAchievementEmoji(achievement)
@@ -166,6 +186,7 @@ func (n *AchievementNotifier) NotifyAchievements(ctx context.Context, userID int
 	for _, key := range achievementKeys {
 		if err := n.NotifyAchievement(ctx, userID, key); err != nil {
 			log.Printf("[ACHIEVEMENT_NOTIFIER] Error notifying user %d about achievement %s: %v", userID, key, err)
+			n.queue.Enqueue(userID, NotificationAchievement, key, err)
 		}
 	}
 
@@ -187,6 +208,88 @@ func (n *AchievementNotifier) sendNotification(ctx context.Context, userID int64
 	return nil
 }
 
+// FormatUniqueClaimNotification формирует уведомление администратору о том, что участник
+// получил уникальное достижение
+func (n *AchievementNotifier) FormatUniqueClaimNotification(achievement *models.Achievement, userName string, earnedAt time.Time) string {
+	return fmt.Sprintf("%s <b>Уникальное достижение получено</b>\n\n%s\n👤 %s\n📅 %s",
+		n.GetAchievementEmoji(achievement),
+		html.EscapeString(achievement.Name),
+		html.EscapeString(userName),
+		earnedAt.Format("02.01.2006 15:04:05"))
+}
+
+// NotifyAdminUniqueClaims сообщает администратору, какие из выданных участнику достижений
+// уникальны. Остальные достижения пропускаются
+func (n *AchievementNotifier) NotifyAdminUniqueClaims(ctx context.Context, adminID, userID int64, userName string, achievementKeys []string, loc *time.Location) error {
+	var earned []*models.UserAchievement
+	for _, key := range achievementKeys {
+		achievement, err := n.achievementRepo.GetByKey(key)
+		if err != nil {
+			log.Printf("[ACHIEVEMENT_NOTIFIER] Failed to get achievement %s: %v", key, err)
+			continue
+		}
+		if !achievement.IsUnique {
+			continue
+		}
+
+		if earned == nil {
+			if earned, err = n.achievementRepo.GetUserAchievements(userID); err != nil {
+				return err
+			}
+		}
+		earnedAt := time.Now()
+		for _, ua := range earned {
+			if ua.AchievementID == achievement.ID {
+				earnedAt = ua.EarnedAt
+			}
+		}
+
+		if _, err := n.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+			ChatID: adminID,
+			Text:   n.FormatUniqueClaimNotification(achievement, userName, earnedAt.In(loc)),
+		}); err != nil {
+			log.Printf("[ACHIEVEMENT_NOTIFIER] Failed to notify admin about unique achievement %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// NotifyWinnerPlaceRevoked сообщает участнику, что место по завершению квеста, о котором
+// он уже получил уведомление, отозвано: другой участник завершил квест раньше
+func (n *AchievementNotifier) NotifyWinnerPlaceRevoked(ctx context.Context, userID int64, achievementKey string) error {
+	achievement, err := n.achievementRepo.GetByKey(achievementKey)
+	if err != nil {
+		return fmt.Errorf("failed to get achievement %s: %w", achievementKey, err)
+	}
+
+	_, err = n.msgManager.SendWithRetry(ctx, &bot.SendMessageParams{
+		ChatID: userID,
+		Text: fmt.Sprintf("🔄 <b>Место пересмотрено</b>\n\nДостижение %s <code>%s</code> перешло к участнику, который завершил квест раньше.",
+			n.GetAchievementEmoji(achievement), html.EscapeString(achievement.Name)),
+	})
+	return err
+}
+
+// FormatWinnerPlaceChanges формирует сводку для администратора о пересмотренных местах
+func (n *AchievementNotifier) FormatWinnerPlaceChanges(changes []WinnerPlaceChange, userName func(int64) string) string {
+	var sb strings.Builder
+	sb.WriteString("🔄 <b>Места пересмотрены по времени завершения</b>\n")
+	for _, change := range changes {
+		name := change.AchievementKey
+		if achievement, err := n.achievementRepo.GetByKey(change.AchievementKey); err == nil {
+			name = achievement.Name
+		}
+		sb.WriteString(fmt.Sprintf("\n<b>%s</b>", html.EscapeString(name)))
+		for _, userID := range change.Revoked {
+			sb.WriteString(fmt.Sprintf("\n➖ %s", html.EscapeString(userName(userID))))
+		}
+		if change.Awarded != 0 {
+			sb.WriteString(fmt.Sprintf("\n➕ %s", html.EscapeString(userName(change.Awarded))))
+		}
+	}
+	return sb.String()
+}
+
 type AchievementNotification struct {
 	UserID         int64
 	AchievementKey string
@@ -203,6 +306,9 @@ func (n *AchievementNotifier) PrepareNotifications(achievementKeys []string) ([]
 			log.Printf("[ACHIEVEMENT_NOTIFIER] Failed to get achievement %s: %v", key, err)
 			continue
 		}
+		if !achievement.NotifyOnAward {
+			continue
+		}
 
 		notification := &AchievementNotification{
 			AchievementKey: key,
@@ -223,3 +329,33 @@ func (n *AchievementNotifier) SendPreparedNotifications(ctx context.Context, use
 	}
 	return nil
 }
+
+// FormatCompletionSummary собирает итоговое сообщение о прохождении квеста: finalMessage,
+// все полученные за финиш достижения одним списком и ссылку на стикер-пак. Стикеры
+// достижений добавляются в пак, но по отдельности не отправляются
+func (n *AchievementNotifier) FormatCompletionSummary(ctx context.Context, userID int64, finalMessage string, achievementKeys []string) string {
+	summary := finalMessage
+
+	notifications, _ := n.PrepareNotifications(achievementKeys)
+	if len(notifications) > 0 {
+		var sb strings.Builder
+		sb.WriteString("🎉 <b>Ваши достижения за квест:</b>")
+		for _, notification := range notifications {
+			emoji := n.GetAchievementEmoji(notification.Achievement)
+			if n.stickerService != nil {
+				if _, err := n.stickerService.EnsureStickerPack(ctx, userID, notification.AchievementKey, emoji); err != nil {
+					log.Printf("[ACHIEVEMENT_NOTIFIER] Failed to ensure sticker pack for user %d: %v", userID, err)
+				}
+			}
+			fmt.Fprintf(&sb, "\n%s <code>%s</code> — <i>%s</i>", emoji,
+				html.EscapeString(notification.Achievement.Name),
+				html.EscapeString(notification.Achievement.Description))
+		}
+		summary = summary + "\n\n" + sb.String()
+	}
+
+	if stickerPackMsg := n.FormatStickerPackMessage(userID); stickerPackMsg != "" {
+		summary = summary + "\n\n" + stickerPackMsg
+	}
+	return summary
+}
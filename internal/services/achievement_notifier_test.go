@@ -1,10 +1,12 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"html"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/models"
@@ -375,3 +377,83 @@ func TestAchievementNotifier_PrepareNotifications_InvalidKey(t *testing.T) {
 		t.Errorf("Expected notification for %s, got %s", ach1.Key, notifications[0].AchievementKey)
 	}
 }
+
+func TestAchievementNotifier_SilentAchievement(t *testing.T) {
+	queue, cleanup := setupNotifierTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	// Без бота и менеджера сообщений: любая попытка отправить уведомление упадёт
+	notifier := &AchievementNotifier{
+		achievementRepo: achievementRepo,
+	}
+
+	silent := createNotifierTestAchievement(t, achievementRepo, "silent_ach", "Silent", "Description", models.CategoryProgress)
+	loud := createNotifierTestAchievement(t, achievementRepo, "loud_ach", "Loud", "Description", models.CategoryProgress)
+	if err := achievementRepo.SetNotifyOnAward(silent.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := userRepo.CreateOrUpdate(&models.User{ID: 1, FirstName: "Test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := achievementRepo.AssignToUser(1, silent.ID, time.Now(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := notifier.NotifyAchievement(context.Background(), 1, silent.Key); err != nil {
+		t.Fatalf("Expected silent achievement to be skipped, got %v", err)
+	}
+
+	has, err := achievementRepo.HasUserAchievement(1, silent.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Error("Expected silent achievement to be recorded")
+	}
+
+	notifications, err := notifier.PrepareNotifications([]string{silent.Key, loud.Key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].AchievementKey != loud.Key {
+		t.Errorf("Expected only the notifying achievement to be prepared, got %+v", notifications)
+	}
+}
+
+func TestAchievementNotifier_FormatCompletionSummary(t *testing.T) {
+	queue, cleanup := setupNotifierTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	notifier := &AchievementNotifier{
+		achievementRepo: achievementRepo,
+	}
+
+	winner := createNotifierTestAchievement(t, achievementRepo, "summary_winner", "Winner", "First to finish", models.CategoryUnique)
+	finisher := createNotifierTestAchievement(t, achievementRepo, "summary_finisher", "Finisher", "Completed the quest", models.CategoryCompletion)
+	silent := createNotifierTestAchievement(t, achievementRepo, "summary_silent", "Silent", "Hidden", models.CategoryCompletion)
+	if err := achievementRepo.SetNotifyOnAward(silent.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := notifier.FormatCompletionSummary(context.Background(), 1, "Final", []string{winner.Key, finisher.Key, silent.Key})
+
+	if !strings.HasPrefix(summary, "Final\n\n") {
+		t.Errorf("Expected summary to start with final message, got %q", summary)
+	}
+	for _, name := range []string{winner.Name, finisher.Name} {
+		if !strings.Contains(summary, "<code>"+name+"</code>") {
+			t.Errorf("Expected summary to contain %q, got %q", name, summary)
+		}
+	}
+	if strings.Contains(summary, silent.Name) {
+		t.Errorf("Expected silent achievement to be omitted, got %q", summary)
+	}
+
+	if got := notifier.FormatCompletionSummary(context.Background(), 1, "Final", nil); got != "Final" {
+		t.Errorf("Expected final message unchanged without achievements, got %q", got)
+	}
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"time"
+
 	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/models"
 )
@@ -57,6 +59,10 @@ func (s *AchievementService) GetActiveAchievements() ([]*models.Achievement, err
 	return s.achievementRepo.GetActive()
 }
 
+func (s *AchievementService) SetNotifyOnAward(achievementID int64, notify bool) error {
+	return s.achievementRepo.SetNotifyOnAward(achievementID, notify)
+}
+
 func (s *AchievementService) GetAchievementByKey(key string) (*models.Achievement, error) {
 	return s.achievementRepo.GetByKey(key)
 }
@@ -84,6 +90,11 @@ func (s *AchievementService) GetUserAchievementSummary(userID int64) (*UserAchie
 		AchievementsByCategory: make(map[models.AchievementCategory][]*UserAchievementDetails),
 	}
 
+	loc := time.Local
+	if timezone, err := s.userRepo.GetEffectiveTimezone(userID); err == nil {
+		loc = ResolveLocation(timezone)
+	}
+
 	for _, ua := range userAchievements {
 		achievement, err := s.achievementRepo.GetByID(ua.AchievementID)
 		if err != nil {
@@ -92,7 +103,7 @@ func (s *AchievementService) GetUserAchievementSummary(userID int64) (*UserAchie
 
 		details := &UserAchievementDetails{
 			Achievement: achievement,
-			EarnedAt:    ua.EarnedAt.Format("02.01.2006 15:04"),
+			EarnedAt:    ua.EarnedAt.In(loc).Format("02.01.2006 15:04"),
 		}
 
 		summary.AchievementsByCategory[achievement.Category] = append(
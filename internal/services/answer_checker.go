@@ -1,6 +1,10 @@
 package services
 
 import (
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/ad/go-telegram-quest/internal/db"
@@ -10,41 +14,370 @@ import (
 type CheckResult struct {
 	IsCorrect  bool
 	Percentage int
+	// Для шагов с набором ответов: распознанные варианты и сколько ещё не хватает
+	Matched []string
+	Missing int
+	// Для числовых шагов с подсказкой: неверный ответ больше или меньше загаданного числа
+	Closeness NumericCloseness
+	// Для шагов с ответами по порядку: сколько элементов отправлено верно, длина
+	// последовательности и был ли сброшен уже набранный прогресс
+	SequencePosition int
+	SequenceLength   int
+	SequenceReset    bool
+	// Разбор проверки, если он был запрошен через один из методов ...WithTrace
+	Trace *MatchTrace
+	// Код языка совпавшего варианта на двуязычных шагах; пусто, если язык варианта не указан
+	Language string
+	// Правило, по которому ответ совпал с вариантом шага; MatchKindNone для неверного ответа
+	MatchKind MatchKind
 }
 
+// MatchKind — правило, по которому ответ засчитан. Сохраняется в ответе участника,
+// чтобы автор квеста видел, как проходят шаги
+type MatchKind string
+
+const (
+	MatchKindNone MatchKind = "none"
+	// MatchKindExact — ответ совпал с вариантом без учёта регистра и пробелов по краям
+	MatchKindExact MatchKind = "exact"
+	// MatchKindNormalized — ответ совпал после снятия кавычек или слов-паразитов
+	MatchKindNormalized MatchKind = "normalized"
+	MatchKindSynonym    MatchKind = "synonym"
+	MatchKindNumeric    MatchKind = "numeric"
+	MatchKindSet        MatchKind = "set"
+	MatchKindSequence   MatchKind = "sequence"
+	MatchKindChoice     MatchKind = "choice"
+	MatchKindLink       MatchKind = "link"
+	MatchKindLocation   MatchKind = "location"
+)
+
+// matchKindLabels — подписи правил совпадения для отчётов администратору
+var matchKindLabels = map[MatchKind]string{
+	MatchKindNone:       "не совпал",
+	MatchKindExact:      "точное совпадение",
+	MatchKindNormalized: "после нормализации",
+	MatchKindSynonym:    "по синониму",
+	MatchKindNumeric:    "как число",
+	MatchKindSet:        "перечисление",
+	MatchKindSequence:   "последовательность",
+	MatchKindChoice:     "выбор варианта",
+	MatchKindLink:       "ссылка",
+	MatchKindLocation:   "геопозиция",
+}
+
+// MatchKindLabel возвращает подпись правила совпадения; неизвестное правило выводится как есть
+func MatchKindLabel(kind MatchKind) string {
+	if label, ok := matchKindLabels[kind]; ok {
+		return label
+	}
+	return string(kind)
+}
+
+// matchKind возвращает kind для верного ответа и MatchKindNone для неверного
+func matchKind(isCorrect bool, kind MatchKind) MatchKind {
+	if !isCorrect {
+		return MatchKindNone
+	}
+	return kind
+}
+
+// ParseAnswerLanguage отделяет от варианта ответа метку языка вида «[en] apple».
+// Без метки вариант возвращается как есть с пустым языком
+func ParseAnswerLanguage(text string) (answer, language string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "[") {
+		return text, ""
+	}
+	tag, rest, ok := strings.Cut(text[1:], "]")
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	rest = strings.TrimSpace(rest)
+	if !ok || rest == "" || !isLanguageCode(tag) {
+		return text, ""
+	}
+	return rest, tag
+}
+
+func isLanguageCode(code string) bool {
+	if len(code) < 2 || len(code) > 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchTrace — подробный разбор проверки текстового ответа для автора квеста: какие правила
+// нормализации применялись, во что превратился ответ и с какими вариантами он сравнивался
+type MatchTrace struct {
+	Input          string
+	Rules          []MatchRule
+	Comparisons    []MatchComparison
+	MatchedVariant string
+}
+
+// MatchRule — одно правило нормализации. Applied означает, что правило изменило ответ,
+// Skipped — почему правило не запускалось
+type MatchRule struct {
+	Name    string
+	Before  string
+	After   string
+	Applied bool
+	Skipped string
+}
+
+// MatchComparison — сравнение одной формы ответа с вариантом шага
+type MatchComparison struct {
+	Form    string
+	Variant string
+	Equal   bool
+}
+
+func (t *MatchTrace) addRule(rule MatchRule) {
+	if t != nil {
+		t.Rules = append(t.Rules, rule)
+	}
+}
+
+// NumericCloseness — в какую сторону неверный числовой ответ отличается от загаданного
+type NumericCloseness int
+
+const (
+	ClosenessNone NumericCloseness = iota
+	ClosenessTooHigh
+	ClosenessTooLow
+)
+
 type AnswerChecker struct {
 	answerRepo   *db.AnswerRepository
 	progressRepo *db.ProgressRepository
 	userRepo     *db.UserRepository
+	settingsRepo *db.SettingsRepository
 }
 
-func NewAnswerChecker(answerRepo *db.AnswerRepository, progressRepo *db.ProgressRepository, userRepo *db.UserRepository) *AnswerChecker {
+func NewAnswerChecker(answerRepo *db.AnswerRepository, progressRepo *db.ProgressRepository, userRepo *db.UserRepository, settingsRepo *db.SettingsRepository) *AnswerChecker {
 	return &AnswerChecker{
 		answerRepo:   answerRepo,
 		progressRepo: progressRepo,
 		userRepo:     userRepo,
+		settingsRepo: settingsRepo,
+	}
+}
+
+// StripFillerWords отбрасывает слова-паразиты в начале нормализованного ответа.
+// Если ответ целиком состоит из таких слов, он возвращается без изменений
+func StripFillerWords(answer string, fillerWords []string) string {
+	if len(fillerWords) == 0 {
+		return answer
+	}
+
+	filler := make(map[string]bool, len(fillerWords))
+	for _, word := range fillerWords {
+		filler[word] = true
+	}
+
+	words := strings.Fields(answer)
+	i := 0
+	for i < len(words) && filler[words[i]] {
+		i++
+	}
+	if i == 0 || i == len(words) {
+		return answer
+	}
+	return strings.Join(words[i:], " ")
+}
+
+// enclosingPairs — открывающие символы и соответствующие им закрывающие
+var enclosingPairs = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'«':  '»',
+	'„':  '“',
+	'“':  '”',
+	'(':  ')',
+	'[':  ']',
+	'{':  '}',
+}
+
+// StripEnclosingPairs снимает парные кавычки и скобки, обрамляющие весь ответ, например
+// «"Москва"» или «(Москва)». Снимаются только сбалансированные пары: в «(а) (б)»
+// первая скобка закрывается не в конце ответа, поэтому ответ не меняется
+func StripEnclosingPairs(answer string) string {
+	for {
+		runes := []rune(answer)
+		if len(runes) < 2 {
+			return answer
+		}
+
+		open, last := runes[0], runes[len(runes)-1]
+		closing, ok := enclosingPairs[open]
+		if !ok || last != closing || !enclosesWhole(runes, open, closing) {
+			return answer
+		}
+
+		answer = strings.TrimSpace(string(runes[1 : len(runes)-1]))
+	}
+}
+
+// enclosesWhole проверяет, что первый символ runes закрывается именно последним
+func enclosesWhole(runes []rune, open, closing rune) bool {
+	inner := runes[1 : len(runes)-1]
+	if open == closing {
+		for _, r := range inner {
+			if r == open {
+				return false
+			}
+		}
+		return true
+	}
+
+	depth := 0
+	for _, r := range inner {
+		switch r {
+		case open:
+			depth++
+		case closing:
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// answerForms возвращает формы ответа для сравнения с вариантами: сам нормализованный
+// ответ и, если это включено в настройках, ответ без обрамляющих кавычек и без слов-паразитов.
+// Если передан trace, в него записывается каждое правило и его результат
+func (c *AnswerChecker) answerForms(normalizedAnswer string, trace *MatchTrace) []string {
+	forms := []string{normalizedAnswer}
+	if c.settingsRepo == nil {
+		return forms
+	}
+
+	stripQuotes, err := c.settingsRepo.GetStripAnswerQuotes()
+	switch {
+	case err != nil || !stripQuotes:
+		trace.addRule(MatchRule{Name: "кавычки и скобки", Before: normalizedAnswer, After: normalizedAnswer, Skipped: "выключено в настройках"})
+	default:
+		stripped := StripEnclosingPairs(normalizedAnswer)
+		trace.addRule(MatchRule{Name: "кавычки и скобки", Before: normalizedAnswer, After: stripped, Applied: stripped != normalizedAnswer})
+		if stripped != normalizedAnswer {
+			forms = append(forms, stripped)
+		}
 	}
+
+	fillerWords, err := c.settingsRepo.GetAnswerFillerWords()
+	if err != nil || len(fillerWords) == 0 {
+		trace.addRule(MatchRule{Name: "слова-паразиты", Before: normalizedAnswer, After: normalizedAnswer, Skipped: "список слов пуст"})
+		return forms
+	}
+	for _, form := range forms {
+		stripped := StripFillerWords(form, fillerWords)
+		trace.addRule(MatchRule{Name: "слова-паразиты", Before: form, After: stripped, Applied: stripped != form})
+		if stripped != form {
+			forms = append(forms, stripped)
+		}
+	}
+	return forms
+}
+
+// applySynonyms добавляет к формам ответа их канонические формы из словаря синонимов.
+// Варианты шага сравниваются и напрямую, и через свою каноническую форму, поэтому автору
+// достаточно указать любой из синонимов
+func applySynonyms(forms []string, synonyms map[string]string, trace *MatchTrace) []string {
+	if len(synonyms) == 0 {
+		if len(forms) > 0 {
+			trace.addRule(MatchRule{Name: "синонимы", Before: forms[0], After: forms[0], Skipped: "выключены для шага или словарь пуст"})
+		}
+		return forms
+	}
+
+	result := forms
+	for _, form := range forms {
+		canonical, ok := synonyms[form]
+		if !ok {
+			trace.addRule(MatchRule{Name: "синонимы", Before: form, After: form})
+			continue
+		}
+		trace.addRule(MatchRule{Name: "синонимы", Before: form, After: canonical, Applied: true})
+		if !slices.Contains(result, canonical) {
+			result = append(result, canonical)
+		}
+	}
+	return result
 }
 
 func (c *AnswerChecker) CheckTextAnswer(stepID int64, answer string) (*CheckResult, error) {
+	return c.checkTextAnswer(stepID, answer, nil)
+}
+
+// CheckTextAnswerWithTrace проверяет ответ так же, как CheckTextAnswer, и дополнительно
+// возвращает в result.Trace разбор всех правил нормализации и сравнений
+func (c *AnswerChecker) CheckTextAnswerWithTrace(stepID int64, answer string) (*CheckResult, error) {
+	return withTrace(answer, func(trace *MatchTrace) (*CheckResult, error) {
+		return c.checkTextAnswer(stepID, answer, trace)
+	})
+}
+
+func (c *AnswerChecker) checkTextAnswer(stepID int64, answer string, trace *MatchTrace) (*CheckResult, error) {
 	variants, err := c.answerRepo.GetStepAnswers(stepID)
 	if err != nil {
 		return nil, err
 	}
 
 	normalizedAnswer := strings.ToLower(strings.TrimSpace(answer))
+	trace.addRule(MatchRule{Name: "пробелы и регистр", Before: answer, After: normalizedAnswer, Applied: normalizedAnswer != answer})
 	// log.Printf("[ANSWER_CHECKER] stepID=%d answer='%s' normalized='%s' variants=%v", stepID, answer, normalizedAnswer, variants)
 
+	synonyms, err := c.answerRepo.GetStepSynonyms(stepID)
+	if err != nil {
+		return nil, err
+	}
+
 	isCorrect := false
-	for _, variant := range variants {
-		if normalizedAnswer == variant {
-			isCorrect = true
-			break
+	matchedVariant := ""
+	kind := MatchKindNone
+	normalizedForms := c.answerForms(normalizedAnswer, trace)
+forms:
+	for _, form := range applySynonyms(normalizedForms, synonyms, trace) {
+		for _, variant := range variants {
+			equal := form == variant || (synonyms[variant] != "" && form == synonyms[variant])
+			if trace != nil {
+				trace.Comparisons = append(trace.Comparisons, MatchComparison{Form: form, Variant: variant, Equal: equal})
+			}
+			if equal {
+				isCorrect = true
+				matchedVariant = variant
+				switch {
+				case form != variant || !slices.Contains(normalizedForms, form):
+					kind = MatchKindSynonym
+				case form == normalizedAnswer:
+					kind = MatchKindExact
+				default:
+					kind = MatchKindNormalized
+				}
+				if trace != nil {
+					trace.MatchedVariant = variant
+				}
+				break forms
+			}
 		}
 	}
 
 	result := &CheckResult{
 		IsCorrect: isCorrect,
+		MatchKind: kind,
+	}
+
+	if isCorrect {
+		languages, err := c.answerRepo.GetStepAnswerLanguages(stepID)
+		if err != nil {
+			return nil, err
+		}
+		result.Language = languages[matchedVariant]
 	}
 
 	if isCorrect {
@@ -59,6 +392,393 @@ func (c *AnswerChecker) CheckTextAnswer(stepID int64, answer string) (*CheckResu
 	return result, nil
 }
 
+// FormatMatchTrace формирует разбор проверки ответа для администратора (HTML)
+func FormatMatchTrace(trace *MatchTrace, isCorrect bool) string {
+	var sb strings.Builder
+	if isCorrect {
+		sb.WriteString("🔬 <b>Разбор проверки: засчитан</b>\n")
+	} else {
+		sb.WriteString("🔬 <b>Разбор проверки: не засчитан</b>\n")
+	}
+	sb.WriteString(fmt.Sprintf("Ответ: <code>%s</code>\n", html.EscapeString(trace.Input)))
+
+	sb.WriteString("\n<b>Нормализация</b>\n")
+	for _, rule := range trace.Rules {
+		switch {
+		case rule.Skipped != "":
+			sb.WriteString(fmt.Sprintf("⏭ %s: пропущено — %s\n", rule.Name, html.EscapeString(rule.Skipped)))
+		case rule.Applied:
+			sb.WriteString(fmt.Sprintf("✏️ %s: <code>%s</code> → <code>%s</code>\n", rule.Name, html.EscapeString(rule.Before), html.EscapeString(rule.After)))
+		default:
+			sb.WriteString(fmt.Sprintf("▫️ %s: без изменений\n", rule.Name))
+		}
+	}
+
+	sb.WriteString("\n<b>Сравнения</b>\n")
+	if len(trace.Comparisons) == 0 {
+		sb.WriteString("У шага нет вариантов ответа\n")
+	}
+	for _, comparison := range trace.Comparisons {
+		mark := "≠"
+		if comparison.Equal {
+			mark = "="
+		}
+		sb.WriteString(fmt.Sprintf("<code>%s</code> %s <code>%s</code>\n", html.EscapeString(comparison.Form), mark, html.EscapeString(comparison.Variant)))
+	}
+
+	if trace.MatchedVariant != "" {
+		sb.WriteString(fmt.Sprintf("\n✅ Совпал вариант «%s»", html.EscapeString(trace.MatchedVariant)))
+	} else {
+		sb.WriteString("\n❌ Ни одна форма ответа не совпала с вариантами")
+	}
+	return sb.String()
+}
+
+// ParseNumericAnswer разбирает ответ как число, допуская десятичную запятую и пробелы
+// между разрядами: «1 000,5» читается как 1000.5
+func ParseNumericAnswer(answer string) (float64, bool) {
+	normalized := strings.ReplaceAll(strings.TrimSpace(answer), " ", "")
+	normalized = strings.ReplaceAll(normalized, ",", ".")
+	if normalized == "" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(normalized, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// CheckNumericAnswer проверяет ответ на шаге с подсказкой «больше/меньше». Ответ сначала
+// сравнивается с вариантами как текст, затем как число, так что «42.0» засчитывается для «42».
+// Для неверного числового ответа в Closeness указывается, больше он загаданного или меньше.
+// На шагах без флага NumericFeedback проверка совпадает с CheckTextAnswer
+func (c *AnswerChecker) CheckNumericAnswer(step *models.Step, answer string) (*CheckResult, error) {
+	return c.checkNumericAnswer(step, answer, nil)
+}
+
+// CheckNumericAnswerWithTrace проверяет ответ так же, как CheckNumericAnswer, и возвращает
+// в result.Trace разбор: текстовые правила и сравнения ответа как числа
+func (c *AnswerChecker) CheckNumericAnswerWithTrace(step *models.Step, answer string) (*CheckResult, error) {
+	return withTrace(answer, func(trace *MatchTrace) (*CheckResult, error) {
+		return c.checkNumericAnswer(step, answer, trace)
+	})
+}
+
+// withTrace запускает проверку с новым разбором и прикладывает его к результату
+func withTrace(answer string, check func(trace *MatchTrace) (*CheckResult, error)) (*CheckResult, error) {
+	trace := &MatchTrace{Input: answer}
+	result, err := check(trace)
+	if err != nil {
+		return nil, err
+	}
+	result.Trace = trace
+	return result, nil
+}
+
+func (c *AnswerChecker) checkNumericAnswer(step *models.Step, answer string, trace *MatchTrace) (*CheckResult, error) {
+	result, err := c.checkTextAnswer(step.ID, answer, trace)
+	if err != nil || result.IsCorrect || !step.NumericFeedback {
+		return result, err
+	}
+
+	value, ok := ParseNumericAnswer(answer)
+	if !ok {
+		trace.addRule(MatchRule{Name: "число", Before: answer, After: answer, Skipped: "ответ не число"})
+		return result, nil
+	}
+	form := strconv.FormatFloat(value, 'f', -1, 64)
+	trace.addRule(MatchRule{Name: "число", Before: answer, After: form, Applied: form != answer})
+
+	variants, err := c.answerRepo.GetStepAnswers(step.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, variant := range variants {
+		target, ok := ParseNumericAnswer(variant)
+		if !ok {
+			continue
+		}
+		if trace != nil {
+			trace.Comparisons = append(trace.Comparisons, MatchComparison{Form: form, Variant: variant, Equal: value == target})
+		}
+		if value == target {
+			if trace != nil {
+				trace.MatchedVariant = variant
+			}
+			result.IsCorrect = true
+			result.Closeness = ClosenessNone
+			result.MatchKind = MatchKindNumeric
+			percentage, err := c.calculatePercentage(step.ID)
+			if err != nil {
+				return nil, err
+			}
+			result.Percentage = percentage
+			return result, nil
+		}
+		// Подсказка даётся относительно первого числового варианта
+		if result.Closeness == ClosenessNone {
+			if value > target {
+				result.Closeness = ClosenessTooHigh
+			} else {
+				result.Closeness = ClosenessTooLow
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// SplitAnswerSet разбивает ответ пользователя на элементы по запятым, точкам с запятой и переводам строк
+func SplitAnswerSet(answer string) []string {
+	parts := strings.FieldsFunc(answer, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n'
+	})
+
+	var items []string
+	for _, part := range parts {
+		if item := strings.ToLower(strings.TrimSpace(part)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// CheckSetAnswer проверяет ответ-перечисление: засчитывается, если в нём есть не меньше
+// step.RequiredAnswers разных вариантов шага (или все варианты, если их меньше)
+func (c *AnswerChecker) CheckSetAnswer(step *models.Step, answer string) (*CheckResult, error) {
+	return c.checkSetAnswer(step, answer, nil)
+}
+
+// CheckSetAnswerWithTrace проверяет ответ так же, как CheckSetAnswer, и возвращает
+// в result.Trace разбор: на какие элементы разбит ответ и с чем сравнивался каждый
+func (c *AnswerChecker) CheckSetAnswerWithTrace(step *models.Step, answer string) (*CheckResult, error) {
+	return withTrace(answer, func(trace *MatchTrace) (*CheckResult, error) {
+		return c.checkSetAnswer(step, answer, trace)
+	})
+}
+
+func (c *AnswerChecker) checkSetAnswer(step *models.Step, answer string, trace *MatchTrace) (*CheckResult, error) {
+	variants, err := c.answerRepo.GetStepAnswers(step.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	required := step.RequiredAnswers
+	if required > len(variants) {
+		required = len(variants)
+	}
+
+	known := make(map[string]bool, len(variants))
+	for _, variant := range variants {
+		known[variant] = true
+	}
+
+	result := &CheckResult{}
+	seen := make(map[string]bool)
+	items := SplitAnswerSet(answer)
+	trace.addRule(MatchRule{Name: "перечисление", Before: answer, After: strings.Join(items, "; "), Applied: len(items) > 1})
+	for _, item := range items {
+		for _, form := range c.answerForms(item, trace) {
+			if trace != nil {
+				for _, variant := range variants {
+					trace.Comparisons = append(trace.Comparisons, MatchComparison{Form: form, Variant: variant, Equal: form == variant})
+				}
+			}
+			if known[form] && !seen[form] {
+				seen[form] = true
+				result.Matched = append(result.Matched, form)
+				break
+			}
+		}
+	}
+	if trace != nil && required > 0 && len(result.Matched) >= required {
+		trace.MatchedVariant = strings.Join(result.Matched, ", ")
+	}
+
+	result.Missing = required - len(result.Matched)
+	if result.Missing < 0 {
+		result.Missing = 0
+	}
+	result.IsCorrect = required > 0 && result.Missing == 0
+	result.MatchKind = matchKind(result.IsCorrect, MatchKindSet)
+
+	if result.IsCorrect {
+		percentage, err := c.calculatePercentage(step.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Percentage = percentage
+	}
+
+	return result, nil
+}
+
+// CheckSequenceAnswer проверяет очередной элемент последовательности на шаге с ответами
+// по порядку. Варианты шага — ожидаемая последовательность, позиция участника хранится
+// в его прогрессе. Верный элемент сдвигает позицию, шаг засчитывается после последнего.
+// Неверный элемент сбрасывает последовательность; если он совпадает с первым элементом,
+// последовательность сразу начинается с него заново
+func (c *AnswerChecker) CheckSequenceAnswer(userID int64, step *models.Step, answer string) (*CheckResult, error) {
+	return c.checkSequenceAnswer(userID, step, answer, nil)
+}
+
+// CheckSequenceAnswerWithTrace проверяет элемент так же, как CheckSequenceAnswer, и возвращает
+// в result.Trace разбор: формы ответа и сравнения с ожидаемым и первым элементами
+func (c *AnswerChecker) CheckSequenceAnswerWithTrace(userID int64, step *models.Step, answer string) (*CheckResult, error) {
+	return withTrace(answer, func(trace *MatchTrace) (*CheckResult, error) {
+		return c.checkSequenceAnswer(userID, step, answer, trace)
+	})
+}
+
+func (c *AnswerChecker) checkSequenceAnswer(userID int64, step *models.Step, answer string, trace *MatchTrace) (*CheckResult, error) {
+	sequence, err := c.answerRepo.GetStepAnswers(step.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := c.progressRepo.GetSequencePosition(userID, step.ID)
+	if err != nil {
+		return nil, err
+	}
+	if position >= len(sequence) {
+		position = 0
+	}
+
+	result := &CheckResult{SequenceLength: len(sequence), MatchKind: MatchKindNone}
+	if len(sequence) == 0 {
+		return result, nil
+	}
+
+	normalizedAnswer := strings.ToLower(strings.TrimSpace(answer))
+	trace.addRule(MatchRule{Name: "пробелы и регистр", Before: answer, After: normalizedAnswer, Applied: normalizedAnswer != answer})
+	forms := c.answerForms(normalizedAnswer, trace)
+	if trace != nil {
+		expected := []string{sequence[position]}
+		if position > 0 {
+			expected = append(expected, sequence[0])
+		}
+		for _, variant := range expected {
+			for _, form := range forms {
+				trace.Comparisons = append(trace.Comparisons, MatchComparison{Form: form, Variant: variant, Equal: form == variant})
+			}
+		}
+	}
+	switch {
+	case matchesAnyForm(forms, sequence[position]):
+		if trace != nil {
+			trace.MatchedVariant = sequence[position]
+		}
+		position++
+	case matchesAnyForm(forms, sequence[0]):
+		if trace != nil {
+			trace.MatchedVariant = sequence[0]
+		}
+		result.SequenceReset = position > 0
+		position = 1
+	default:
+		result.SequenceReset = position > 0
+		position = 0
+	}
+
+	if position == len(sequence) {
+		result.IsCorrect = true
+		result.MatchKind = MatchKindSequence
+		result.SequencePosition = position
+		if err := c.progressRepo.SetSequencePosition(userID, step.ID, 0); err != nil {
+			return nil, err
+		}
+		percentage, err := c.calculatePercentage(step.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Percentage = percentage
+		return result, nil
+	}
+
+	result.SequencePosition = position
+	if err := c.progressRepo.SetSequencePosition(userID, step.ID, position); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func matchesAnyForm(forms []string, variant string) bool {
+	for _, form := range forms {
+		if form == variant {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckChoiceAnswer проверяет вариант, выбранный кнопкой на шаге с выбором ответа
+func (c *AnswerChecker) CheckChoiceAnswer(step *models.Step, choiceID int64) (*CheckResult, error) {
+	choice := step.Choice(choiceID)
+	result := &CheckResult{
+		IsCorrect: choice != nil && choice.IsCorrect,
+	}
+	result.MatchKind = matchKind(result.IsCorrect, MatchKindChoice)
+
+	if result.IsCorrect {
+		percentage, err := c.calculatePercentage(step.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Percentage = percentage
+	}
+
+	return result, nil
+}
+
+// CheckLinkAnswer проверяет, что среди ссылок из сообщения есть ссылка на домен шага.
+// Если домен не задан, подходит любая ссылка
+func (c *AnswerChecker) CheckLinkAnswer(step *models.Step, links []string) (*CheckResult, error) {
+	result := &CheckResult{}
+	for _, link := range links {
+		if LinkMatchesDomain(link, step.LinkDomain) {
+			result.IsCorrect = true
+			break
+		}
+	}
+	result.MatchKind = matchKind(result.IsCorrect, MatchKindLink)
+
+	if result.IsCorrect {
+		percentage, err := c.calculatePercentage(step.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Percentage = percentage
+	}
+
+	return result, nil
+}
+
+// CheckLocationAnswer проверяет, что присланная точка находится в радиусе цели шага
+func (c *AnswerChecker) CheckLocationAnswer(step *models.Step, lat, lng float64) (*CheckResult, error) {
+	distance := HaversineDistance(step.LocationLat, step.LocationLng, lat, lng)
+	result := &CheckResult{
+		IsCorrect: step.LocationRadius > 0 && distance <= float64(step.LocationRadius),
+	}
+	result.MatchKind = matchKind(result.IsCorrect, MatchKindLocation)
+
+	if result.IsCorrect {
+		percentage, err := c.calculatePercentage(step.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Percentage = percentage
+	}
+
+	return result, nil
+}
+
+// StepPercentage возвращает долю участников, у которых шаг одобрен
+func (c *AnswerChecker) StepPercentage(stepID int64) (int, error) {
+	return c.calculatePercentage(stepID)
+}
+
 func (c *AnswerChecker) calculatePercentage(stepID int64) (int, error) {
 	approvedCount, err := c.progressRepo.CountByStep(stepID, models.StatusApproved)
 	if err != nil {
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"slices"
 	"strings"
 	"testing"
 
@@ -19,7 +20,7 @@ func TestProperty6_CaseInsensitiveAnswerMatching(t *testing.T) {
 		answerRepo := db.NewAnswerRepository(queue)
 		progressRepo := db.NewProgressRepository(queue)
 		userRepo := db.NewUserRepository(queue)
-		checker := NewAnswerChecker(answerRepo, progressRepo, userRepo)
+		checker := NewAnswerChecker(answerRepo, progressRepo, userRepo, db.NewSettingsRepository(queue))
 
 		step := &models.Step{
 			StepOrder:    1,
@@ -67,3 +68,851 @@ func mixCase(s string) string {
 	}
 	return string(result)
 }
+
+func TestCheckSetAnswer(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	checker := NewAnswerChecker(answerRepo, db.NewProgressRepository(queue), db.NewUserRepository(queue), db.NewSettingsRepository(queue))
+
+	step := createTestStep(t, stepRepo, 1)
+	for _, answer := range []string{"Красный", "Жёлтый", "Зелёный"} {
+		if err := answerRepo.AddStepAnswer(step.ID, answer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("full set", func(t *testing.T) {
+		step.RequiredAnswers = 3
+		result, err := checker.CheckSetAnswer(step, "зелёный, Красный;\nжёлтый")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect || result.Missing != 0 || len(result.Matched) != 3 {
+			t.Errorf("Expected full set to be accepted, got %+v", result)
+		}
+	})
+
+	t.Run("partial set", func(t *testing.T) {
+		step.RequiredAnswers = 3
+		result, err := checker.CheckSetAnswer(step, "красный, красный, синий")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsCorrect {
+			t.Error("Expected partial set to be rejected")
+		}
+		if len(result.Matched) != 1 || result.Matched[0] != "красный" || result.Missing != 2 {
+			t.Errorf("Expected one matched item and two missing, got %+v", result)
+		}
+	})
+
+	t.Run("k of n", func(t *testing.T) {
+		step.RequiredAnswers = 2
+		result, err := checker.CheckSetAnswer(step, "жёлтый, зелёный")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect {
+			t.Errorf("Expected 2 of 3 to be accepted, got %+v", result)
+		}
+
+		result, err = checker.CheckSetAnswer(step, "жёлтый")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsCorrect || result.Missing != 1 {
+			t.Errorf("Expected 1 of 3 to be rejected with one missing, got %+v", result)
+		}
+	})
+
+	t.Run("required count above variants", func(t *testing.T) {
+		step.RequiredAnswers = 10
+		result, err := checker.CheckSetAnswer(step, "красный, жёлтый, зелёный")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect {
+			t.Errorf("Expected all variants to satisfy an oversized requirement, got %+v", result)
+		}
+	})
+}
+
+func TestCheckChoiceAnswer(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	checker := NewAnswerChecker(db.NewAnswerRepository(queue), db.NewProgressRepository(queue), db.NewUserRepository(queue), db.NewSettingsRepository(queue))
+
+	created := createTestStepWithType(t, stepRepo, 1, models.AnswerTypeChoice)
+	for _, text := range []string{"Москва", "Париж", "Берлин"} {
+		if err := stepRepo.AddChoice(created.ID, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step, err := stepRepo.GetByID(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(step.Choices) != 3 {
+		t.Fatalf("Expected 3 choices, got %d", len(step.Choices))
+	}
+
+	correct := step.Choices[1]
+	if err := stepRepo.SetCorrectChoice(step.ID, correct.ID); err != nil {
+		t.Fatal(err)
+	}
+	step, err = stepRepo.GetByID(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("correct choice", func(t *testing.T) {
+		result, err := checker.CheckChoiceAnswer(step, correct.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect {
+			t.Errorf("Expected %q to be accepted", correct.Text)
+		}
+	})
+
+	t.Run("incorrect choice", func(t *testing.T) {
+		result, err := checker.CheckChoiceAnswer(step, step.Choices[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsCorrect {
+			t.Errorf("Expected %q to be rejected", step.Choices[0].Text)
+		}
+	})
+
+	t.Run("unknown choice", func(t *testing.T) {
+		result, err := checker.CheckChoiceAnswer(step, 999999)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsCorrect {
+			t.Error("Expected a choice from another step to be rejected")
+		}
+	})
+}
+
+func TestCheckTextAnswer_FillerWords(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	checker := NewAnswerChecker(answerRepo, db.NewProgressRepository(queue), db.NewUserRepository(queue), settingsRepo)
+
+	step := createTestStep(t, stepRepo, 1)
+	if err := answerRepo.AddStepAnswer(step.ID, "Москва"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := checker.CheckTextAnswer(step.ID, "это москва")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected answer with a filler word to be rejected when normalization is disabled")
+	}
+
+	if err := settingsRepo.SetAnswerFillerWords([]string{"это", "вот"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, answer := range []string{"это москва", "Вот это Москва", "москва"} {
+		result, err := checker.CheckTextAnswer(step.ID, answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect {
+			t.Errorf("Expected %q to match when normalization is enabled", answer)
+		}
+	}
+
+	result, err = checker.CheckTextAnswer(step.ID, "это")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected an answer consisting only of filler words to be rejected")
+	}
+}
+
+func TestCheckTextAnswer_MatchKind(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	checker := NewAnswerChecker(answerRepo, db.NewProgressRepository(queue), db.NewUserRepository(queue), settingsRepo)
+
+	step := createTestStep(t, stepRepo, 1)
+	if err := answerRepo.AddStepAnswer(step.ID, "Санкт-Петербург"); err != nil {
+		t.Fatal(err)
+	}
+	if err := answerRepo.AddSynonyms("санкт-петербург", []string{"Питер"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.SetUseSynonyms(step.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.SetAnswerFillerWords([]string{"это"}); err != nil {
+		t.Fatal(err)
+	}
+	numeric := createTestStep(t, stepRepo, 2)
+	numeric.NumericFeedback = true
+	if err := answerRepo.AddStepAnswer(numeric.ID, "42"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		step     *models.Step
+		answer   string
+		expected MatchKind
+	}{
+		{step, " САНКТ-ПЕТЕРБУРГ ", MatchKindExact},
+		{step, "это санкт-петербург", MatchKindNormalized},
+		{step, "питер", MatchKindSynonym},
+		{step, "москва", MatchKindNone},
+		{numeric, "42", MatchKindExact},
+		{numeric, "42.0", MatchKindNumeric},
+		{numeric, "43", MatchKindNone},
+	}
+
+	userRepo := db.NewUserRepository(queue)
+	createTestUserForEngine(t, userRepo, 1)
+	for _, tt := range tests {
+		result, err := checker.CheckNumericAnswer(tt.step, tt.answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.MatchKind != tt.expected {
+			t.Errorf("%q: expected match kind %q, got %q", tt.answer, tt.expected, result.MatchKind)
+		}
+
+		answerID, err := answerRepo.CreateTextAnswer(1, tt.step.ID, tt.answer, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := answerRepo.SetMatchRule(answerID, string(result.MatchKind)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := answerRepo.GetUserAnswerHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != len(tests) {
+		t.Fatalf("Expected %d stored answers, got %d", len(tests), len(history))
+	}
+	for i, answer := range history {
+		if answer.MatchRule != string(tests[i].expected) {
+			t.Errorf("%q: expected stored match rule %q, got %q", answer.TextAnswer, tests[i].expected, answer.MatchRule)
+		}
+	}
+}
+
+func TestCheckTextAnswer_Synonyms(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	checker := NewAnswerChecker(answerRepo, db.NewProgressRepository(queue), db.NewUserRepository(queue), db.NewSettingsRepository(queue))
+
+	step := createTestStep(t, stepRepo, 1)
+	if err := answerRepo.AddStepAnswer(step.ID, "Санкт-Петербург"); err != nil {
+		t.Fatal(err)
+	}
+	other := createTestStep(t, stepRepo, 2)
+	if err := answerRepo.AddStepAnswer(other.ID, "Питер"); err != nil {
+		t.Fatal(err)
+	}
+	if err := answerRepo.AddSynonyms("санкт-петербург", []string{"Питер", "СПб"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := checker.CheckTextAnswer(step.ID, "спб")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected synonym to be rejected while synonyms are disabled for the step")
+	}
+
+	for _, id := range []int64{step.ID, other.ID} {
+		if err := stepRepo.SetUseSynonyms(id, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, answer := range []string{"СПб", "питер", "Санкт-Петербург"} {
+		result, err := checker.CheckTextAnswer(step.ID, answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect {
+			t.Errorf("Expected %q to match the canonical answer", answer)
+		}
+	}
+
+	for _, answer := range []string{"спб", "санкт-петербург"} {
+		result, err := checker.CheckTextAnswer(other.ID, answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect {
+			t.Errorf("Expected %q to match a variant that is itself a synonym", answer)
+		}
+	}
+
+	result, err = checker.CheckTextAnswer(step.ID, "москва")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected unrelated answer to be rejected")
+	}
+
+	groups, err := answerRepo.GetSynonymGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Canonical != "санкт-петербург" || len(groups[0].Synonyms) != 2 {
+		t.Fatalf("Unexpected synonym groups: %+v", groups)
+	}
+	if err := answerRepo.DeleteSynonymGroup(groups[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = checker.CheckTextAnswer(step.ID, "спб")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected synonym to be rejected after the group was deleted")
+	}
+}
+
+func TestCheckTextAnswer_Languages(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	checker := NewAnswerChecker(answerRepo, db.NewProgressRepository(queue), db.NewUserRepository(queue), settingsRepo)
+
+	step := createTestStep(t, stepRepo, 1)
+	for _, variant := range []string{"[en] Apple", "[ru] яблоко", "фрукт"} {
+		answer, language := ParseAnswerLanguage(variant)
+		if err := answerRepo.AddStepAnswerWithLanguage(step.ID, answer, language); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := settingsRepo.Set("correct_answer_message", "✅ Правильно!"); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.Set(models.CorrectAnswerMessagePrefix+"en", "✅ Correct!"); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := settingsRepo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		answer       string
+		wantLanguage string
+		wantMessage  string
+	}{
+		{"apple", "en", "✅ Correct!"},
+		{"Яблоко", "ru", "✅ Правильно!"},
+		{"фрукт", "", "✅ Правильно!"},
+	}
+	for _, tt := range tests {
+		result, err := checker.CheckTextAnswer(step.ID, tt.answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect || result.Language != tt.wantLanguage {
+			t.Errorf("CheckTextAnswer(%q) = correct %v, language %q; want language %q", tt.answer, result.IsCorrect, result.Language, tt.wantLanguage)
+		}
+		if message := settings.Message(settings.CorrectAnswerMessageKey(result.Language)); message != tt.wantMessage {
+			t.Errorf("Expected success message %q for %q, got %q", tt.wantMessage, tt.answer, message)
+		}
+	}
+
+	result, err := checker.CheckTextAnswer(step.ID, "[en] apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected the language tag not to be part of the stored variant")
+	}
+}
+
+func TestParseAnswerLanguage(t *testing.T) {
+	tests := []struct {
+		text         string
+		wantAnswer   string
+		wantLanguage string
+	}{
+		{"[en] apple", "apple", "en"},
+		{" [DE]Apfel ", "Apfel", "de"},
+		{"apple", "apple", ""},
+		{"[english] apple", "[english] apple", ""},
+		{"[en]", "[en]", ""},
+		{"[1] первый", "[1] первый", ""},
+	}
+
+	for _, tt := range tests {
+		answer, language := ParseAnswerLanguage(tt.text)
+		if answer != tt.wantAnswer || language != tt.wantLanguage {
+			t.Errorf("ParseAnswerLanguage(%q) = %q, %q; want %q, %q", tt.text, answer, language, tt.wantAnswer, tt.wantLanguage)
+		}
+	}
+}
+
+func TestStripFillerWords(t *testing.T) {
+	words := []string{"это", "вот"}
+
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"это москва", "москва"},
+		{"вот это москва", "москва"},
+		{"москва это", "москва это"},
+		{"это", "это"},
+		{"москва", "москва"},
+	}
+
+	for _, tt := range tests {
+		if got := StripFillerWords(tt.answer, words); got != tt.want {
+			t.Errorf("StripFillerWords(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+	if got := StripFillerWords("это москва", nil); got != "это москва" {
+		t.Errorf("Expected no changes without filler words, got %q", got)
+	}
+}
+
+func TestCheckTextAnswer_EnclosingQuotes(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	checker := NewAnswerChecker(answerRepo, db.NewProgressRepository(queue), db.NewUserRepository(queue), settingsRepo)
+
+	step := createTestStep(t, stepRepo, 1)
+	if err := answerRepo.AddStepAnswer(step.ID, "Москва"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := checker.CheckTextAnswer(step.ID, `"Москва"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected quoted answer to be rejected when stripping is disabled")
+	}
+
+	if err := settingsRepo.SetStripAnswerQuotes(true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, answer := range []string{`"Москва"`, "(Москва)", "«Москва»", `( "Москва" )`} {
+		result, err := checker.CheckTextAnswer(step.ID, answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect {
+			t.Errorf("Expected %q to match when stripping is enabled", answer)
+		}
+	}
+
+	result, err = checker.CheckTextAnswer(step.ID, `"Москва`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected an unbalanced quote not to be stripped")
+	}
+}
+
+func TestStripEnclosingPairs(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{`"москва"`, "москва"},
+		{"(москва)", "москва"},
+		{"«москва»", "москва"},
+		{"[(москва)]", "москва"},
+		{`"москва`, `"москва`},
+		{"(москва", "(москва"},
+		{"(москва]", "(москва]"},
+		{"(а) (б)", "(а) (б)"},
+		{`"а" и "б"`, `"а" и "б"`},
+		{"((а) (б))", "(а) (б)"},
+		{"москва", "москва"},
+		{`""`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := StripEnclosingPairs(tt.answer); got != tt.want {
+			t.Errorf("StripEnclosingPairs(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTextAnswerWithTrace(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	checker := NewAnswerChecker(answerRepo, db.NewProgressRepository(queue), db.NewUserRepository(queue), settingsRepo)
+
+	step := createTestStep(t, stepRepo, 1)
+	if err := answerRepo.AddStepAnswer(step.ID, "москва"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := checker.CheckTextAnswerWithTrace(step.ID, " «Это Москва» ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected answer to be rejected with all rules disabled")
+	}
+	wantSkipped := map[string]bool{"кавычки и скобки": true, "слова-паразиты": true, "синонимы": true}
+	for _, rule := range result.Trace.Rules {
+		if wantSkipped[rule.Name] && rule.Skipped == "" {
+			t.Errorf("Expected rule %q to be recorded as skipped", rule.Name)
+		}
+	}
+	if len(result.Trace.Rules) != 4 {
+		t.Errorf("Expected 4 recorded rules, got %+v", result.Trace.Rules)
+	}
+
+	if err := settingsRepo.SetStripAnswerQuotes(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.SetAnswerFillerWords([]string{"это"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = checker.CheckTextAnswerWithTrace(step.ID, " «Это Москва» ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsCorrect {
+		t.Fatal("Expected answer to match after stripping quotes and filler words")
+	}
+
+	trace := result.Trace
+	expected := []MatchRule{
+		{Name: "пробелы и регистр", Before: " «Это Москва» ", After: "«это москва»", Applied: true},
+		{Name: "кавычки и скобки", Before: "«это москва»", After: "это москва", Applied: true},
+		{Name: "слова-паразиты", Before: "«это москва»", After: "«это москва»"},
+		{Name: "слова-паразиты", Before: "это москва", After: "москва", Applied: true},
+		{Name: "синонимы", Before: "«это москва»", After: "«это москва»", Skipped: "выключены для шага или словарь пуст"},
+	}
+	if len(trace.Rules) != len(expected) {
+		t.Fatalf("Expected %d rules, got %+v", len(expected), trace.Rules)
+	}
+	for i, rule := range expected {
+		if trace.Rules[i] != rule {
+			t.Errorf("Rule %d: expected %+v, got %+v", i, rule, trace.Rules[i])
+		}
+	}
+
+	if len(trace.Comparisons) != 3 {
+		t.Fatalf("Expected every form to be compared until a match, got %+v", trace.Comparisons)
+	}
+	last := trace.Comparisons[len(trace.Comparisons)-1]
+	if !last.Equal || last.Form != "москва" || trace.MatchedVariant != "москва" {
+		t.Errorf("Expected the stripped form to match, got %+v", trace)
+	}
+
+	plain, err := checker.CheckTextAnswer(step.ID, " «Это Москва» ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Trace != nil {
+		t.Error("Expected CheckTextAnswer not to build a trace")
+	}
+}
+
+func TestCheckAnswerWithTrace_StepTypes(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	checker := NewAnswerChecker(answerRepo, progressRepo, db.NewUserRepository(queue), db.NewSettingsRepository(queue))
+	createTestUserForEngine(t, db.NewUserRepository(queue), 1)
+
+	hasComparison := func(trace *MatchTrace, form, variant string) bool {
+		return slices.Contains(trace.Comparisons, MatchComparison{Form: form, Variant: variant, Equal: form == variant})
+	}
+
+	t.Run("numeric", func(t *testing.T) {
+		step := createTestStep(t, stepRepo, 1)
+		step.NumericFeedback = true
+		if err := answerRepo.AddStepAnswer(step.ID, "42"); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := checker.CheckNumericAnswerWithTrace(step, "42,0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect || result.Trace == nil {
+			t.Fatalf("Expected a traced correct answer, got %+v", result)
+		}
+		rule := result.Trace.Rules[len(result.Trace.Rules)-1]
+		if rule.Name != "число" || rule.After != "42" || !hasComparison(result.Trace, "42", "42") || result.Trace.MatchedVariant != "42" {
+			t.Errorf("Expected the numeric comparison in the trace, got %+v", result.Trace)
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		step := createTestStep(t, stepRepo, 2)
+		step.RequiredAnswers = 2
+		for _, answer := range []string{"красный", "синий"} {
+			if err := answerRepo.AddStepAnswer(step.ID, answer); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		result, err := checker.CheckSetAnswerWithTrace(step, "Синий, красный")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsCorrect || result.Trace == nil || result.Trace.Rules[0].After != "синий; красный" {
+			t.Fatalf("Expected a traced set answer split into items, got %+v", result)
+		}
+		if !hasComparison(result.Trace, "синий", "синий") || result.Trace.MatchedVariant != "синий, красный" {
+			t.Errorf("Expected item comparisons in the trace, got %+v", result.Trace)
+		}
+	})
+
+	t.Run("sequence", func(t *testing.T) {
+		step := createTestStep(t, stepRepo, 3)
+		step.OrderedAnswers = true
+		for _, answer := range []string{"один", "два"} {
+			if err := answerRepo.AddStepAnswer(step.ID, answer); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		result, err := checker.CheckSequenceAnswerWithTrace(1, step, "Один")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Trace == nil || !hasComparison(result.Trace, "один", "один") || result.Trace.MatchedVariant != "один" {
+			t.Fatalf("Expected the expected element to be compared, got %+v", result.Trace)
+		}
+
+		result, err = checker.CheckSequenceAnswerWithTrace(1, step, "три")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hasComparison(result.Trace, "три", "два") || !hasComparison(result.Trace, "три", "один") || result.Trace.MatchedVariant != "" {
+			t.Errorf("Expected comparisons with the expected and the first element, got %+v", result.Trace)
+		}
+	})
+}
+
+func TestCheckNumericAnswer(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	checker := NewAnswerChecker(answerRepo, db.NewProgressRepository(queue), db.NewUserRepository(queue), db.NewSettingsRepository(queue))
+
+	step := createTestStep(t, stepRepo, 1)
+	if err := answerRepo.AddStepAnswer(step.ID, "42"); err != nil {
+		t.Fatal(err)
+	}
+	step.NumericFeedback = true
+
+	tests := []struct {
+		answer    string
+		correct   bool
+		closeness NumericCloseness
+	}{
+		{"50", false, ClosenessTooHigh},
+		{"7", false, ClosenessTooLow},
+		{"42", true, ClosenessNone},
+		{"42,0", true, ClosenessNone},
+		{"сорок два", false, ClosenessNone},
+	}
+
+	for _, tt := range tests {
+		result, err := checker.CheckNumericAnswer(step, tt.answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsCorrect != tt.correct || result.Closeness != tt.closeness {
+			t.Errorf("CheckNumericAnswer(%q) = correct %v, closeness %v; want %v, %v",
+				tt.answer, result.IsCorrect, result.Closeness, tt.correct, tt.closeness)
+		}
+	}
+
+	step.NumericFeedback = false
+	result, err := checker.CheckNumericAnswer(step, "50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Closeness != ClosenessNone {
+		t.Error("Expected no closeness feedback on a step without the flag")
+	}
+}
+
+func TestCheckNumericAnswer_TextVariantsGiveNoFeedback(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	checker := NewAnswerChecker(answerRepo, db.NewProgressRepository(queue), db.NewUserRepository(queue), db.NewSettingsRepository(queue))
+
+	step := createTestStep(t, stepRepo, 1)
+	if err := answerRepo.AddStepAnswer(step.ID, "москва"); err != nil {
+		t.Fatal(err)
+	}
+	step.NumericFeedback = true
+
+	result, err := checker.CheckNumericAnswer(step, "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect || result.Closeness != ClosenessNone {
+		t.Errorf("Expected no feedback for an exact-string step, got %+v", result)
+	}
+}
+
+func setupSequenceStep(t *testing.T) (*AnswerChecker, *db.ProgressRepository, *models.Step, func()) {
+	t.Helper()
+	queue, cleanup := setupAchievementEngineTestDB(t)
+
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	checker := NewAnswerChecker(answerRepo, progressRepo, userRepo, db.NewSettingsRepository(queue))
+
+	createTestUserForEngine(t, userRepo, 1)
+	step := createTestStep(t, stepRepo, 1)
+	for _, answer := range []string{"Раз", "Два", "Три"} {
+		if err := answerRepo.AddStepAnswer(step.ID, answer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stepRepo.SetOrderedAnswers(step.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	step.OrderedAnswers = true
+
+	return checker, progressRepo, step, cleanup
+}
+
+func TestCheckSequenceAnswer_FullSequence(t *testing.T) {
+	checker, progressRepo, step, cleanup := setupSequenceStep(t)
+	defer cleanup()
+
+	for i, answer := range []string{"раз", "Два"} {
+		result, err := checker.CheckSequenceAnswer(1, step, answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsCorrect || result.SequenceReset || result.SequencePosition != i+1 || result.SequenceLength != 3 {
+			t.Fatalf("Expected prefix %d of 3 to be acknowledged, got %+v", i+1, result)
+		}
+	}
+
+	result, err := checker.CheckSequenceAnswer(1, step, "три")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsCorrect {
+		t.Errorf("Expected full sequence to be accepted, got %+v", result)
+	}
+
+	position, err := progressRepo.GetSequencePosition(1, step.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position != 0 {
+		t.Errorf("Expected stored position to be cleared after acceptance, got %d", position)
+	}
+}
+
+func TestCheckSequenceAnswer_WrongEntryResets(t *testing.T) {
+	checker, progressRepo, step, cleanup := setupSequenceStep(t)
+	defer cleanup()
+
+	for _, answer := range []string{"раз", "два"} {
+		if _, err := checker.CheckSequenceAnswer(1, step, answer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := checker.CheckSequenceAnswer(1, step, "четыре")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect || !result.SequenceReset || result.SequencePosition != 0 {
+		t.Errorf("Expected wrong entry to reset the sequence, got %+v", result)
+	}
+	if position, _ := progressRepo.GetSequencePosition(1, step.ID); position != 0 {
+		t.Errorf("Expected stored position 0 after reset, got %d", position)
+	}
+
+	result, err = checker.CheckSequenceAnswer(1, step, "три")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect || result.SequencePosition != 0 {
+		t.Errorf("Expected out-of-order entry after reset to be rejected, got %+v", result)
+	}
+}
+
+func TestCheckSequenceAnswer_FirstElementRestarts(t *testing.T) {
+	checker, _, step, cleanup := setupSequenceStep(t)
+	defer cleanup()
+
+	for _, answer := range []string{"раз", "два"} {
+		if _, err := checker.CheckSequenceAnswer(1, step, answer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := checker.CheckSequenceAnswer(1, step, "раз")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.SequenceReset || result.SequencePosition != 1 {
+		t.Errorf("Expected the first element to restart the sequence, got %+v", result)
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+// AnswerSummaryItem — шаг квеста и принятый на нём ответ для разбора после прохождения
+type AnswerSummaryItem struct {
+	StepOrder int
+	StepText  string
+	Answer    string
+}
+
+// BuildAnswerSummary собирает разбор ответов для участника, прошедшего квест: по каждому
+// одобренному шагу — принятый ответ. Для участника, не завершившего квест, разбор пустой,
+// чтобы ответы не попали к тем, кто ещё проходит шаги
+func (s *StatisticsService) BuildAnswerSummary(userID int64) ([]AnswerSummaryItem, error) {
+	steps, err := s.stepRepo.GetActive()
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, nil
+	}
+
+	progress, err := s.progressRepo.GetUserProgress(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	statuses := make(map[int64]models.ProgressStatus, len(progress))
+	for _, p := range progress {
+		statuses[p.StepID] = p.Status
+	}
+
+	for _, step := range steps {
+		if status := statuses[step.ID]; status != models.StatusApproved && status != models.StatusSkipped {
+			return nil, nil
+		}
+	}
+
+	var items []AnswerSummaryItem
+	for _, step := range steps {
+		if statuses[step.ID] != models.StatusApproved {
+			continue
+		}
+
+		answer, err := s.acceptedAnswer(userID, step)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, AnswerSummaryItem{
+			StepOrder: step.StepOrder,
+			StepText:  step.Text,
+			Answer:    answer,
+		})
+	}
+	return items, nil
+}
+
+// acceptedAnswer возвращает ответ, который засчитывается на шаге: правильный вариант
+// выбора, варианты ответа шага или, для шагов без вариантов, последний ответ участника
+func (s *StatisticsService) acceptedAnswer(userID int64, step *models.Step) (string, error) {
+	if step.AnswerType == models.AnswerTypeChoice {
+		for _, choice := range step.Choices {
+			if choice.IsCorrect {
+				return choice.Text, nil
+			}
+		}
+	}
+
+	if len(step.Answers) > 0 {
+		if step.RequiredAnswers > 0 {
+			return strings.Join(step.Answers, ", "), nil
+		}
+		return step.Answers[0], nil
+	}
+
+	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var answer sql.NullString
+		err := db.QueryRow(`
+			SELECT text_answer FROM user_answers
+			WHERE user_id = ? AND step_id = ?
+			ORDER BY id DESC LIMIT 1
+		`, userID, step.ID).Scan(&answer)
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return answer.String, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.(string), nil
+}
+
+// FormatAnswerSummary форматирует разбор ответов для отправки участнику
+func FormatAnswerSummary(items []AnswerSummaryItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📖 <b>Разбор ответов</b>\n")
+	for _, item := range items {
+		answer := item.Answer
+		if answer == "" {
+			answer = "—"
+		}
+		fmt.Fprintf(&sb, "\n<b>%d.</b> %s\n✅ %s\n", item.StepOrder, html.EscapeString(truncateSummaryText(item.StepText, 100)), html.EscapeString(answer))
+	}
+	return sb.String()
+}
+
+func truncateSummaryText(text string, maxLen int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	return string(runes[:maxLen]) + "..."
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+func TestBuildAnswerSummary(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	stats := NewStatisticsService(queue, stepRepo, progressRepo, userRepo)
+
+	textStep := createTestStep(t, stepRepo, 1)
+	if err := stepRepo.AddAnswer(textStep.ID, "маяк"); err != nil {
+		t.Fatal(err)
+	}
+	imageStep := createTestStepWithType(t, stepRepo, 2, models.AnswerTypeImage)
+
+	createTestUserForEngine(t, userRepo, 1)
+	createTestUserForEngine(t, userRepo, 2)
+
+	now := time.Now()
+	createUserProgress(t, progressRepo, 1, textStep.ID, models.StatusApproved, &now)
+	createUserAnswerWithID(t, queue, 1, imageStep.ID, "фото у фонтана", false, now)
+	createUserProgress(t, progressRepo, 1, imageStep.ID, models.StatusApproved, &now)
+
+	createUserProgress(t, progressRepo, 2, textStep.ID, models.StatusApproved, &now)
+
+	items, err := stats.BuildAnswerSummary(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 summary items, got %+v", items)
+	}
+	if items[0].StepOrder != 1 || items[0].Answer != "маяк" {
+		t.Errorf("Expected step variant as accepted answer, got %+v", items[0])
+	}
+	if items[1].StepOrder != 2 || items[1].Answer != "фото у фонтана" {
+		t.Errorf("Expected user's answer for step without variants, got %+v", items[1])
+	}
+
+	formatted := FormatAnswerSummary(items)
+	if !strings.Contains(formatted, "Разбор ответов") || !strings.Contains(formatted, "✅ маяк") {
+		t.Errorf("Unexpected formatted summary:\n%s", formatted)
+	}
+
+	incomplete, err := stats.BuildAnswerSummary(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incomplete) != 0 {
+		t.Errorf("Expected empty summary for incomplete user, got %+v", incomplete)
+	}
+	if FormatAnswerSummary(incomplete) != "" {
+		t.Error("Expected empty formatted summary for incomplete user")
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+	"github.com/go-telegram/bot"
+	tgmodels "github.com/go-telegram/bot/models"
+)
+
+// ChannelAnnouncer публикует в публичном канале вехи участников: каждые N пройденных шагов
+// и прохождение квеста. Участники в режиме «не беспокоить» в объявления не попадают
+type ChannelAnnouncer struct {
+	bot          *bot.Bot
+	settingsRepo *db.SettingsRepository
+	userRepo     *db.UserRepository
+}
+
+func NewChannelAnnouncer(b *bot.Bot, settingsRepo *db.SettingsRepository, userRepo *db.UserRepository) *ChannelAnnouncer {
+	return &ChannelAnnouncer{
+		bot:          b,
+		settingsRepo: settingsRepo,
+		userRepo:     userRepo,
+	}
+}
+
+// PublicName возвращает имя участника для публичного объявления — без фамилии, username и ID
+func PublicName(user *models.User) string {
+	if user == nil || user.FirstName == "" {
+		return "Участник"
+	}
+	return user.FirstName
+}
+
+// FormatStepAnnouncement форматирует объявление о том, что участник прошёл completedSteps шагов
+func FormatStepAnnouncement(user *models.User, completedSteps int) string {
+	return fmt.Sprintf("🚀 <b>%s</b> уже прошёл шагов: %d!", html.EscapeString(PublicName(user)), completedSteps)
+}
+
+// FormatCompletionAnnouncement форматирует объявление о прохождении квеста
+func FormatCompletionAnnouncement(user *models.User) string {
+	return fmt.Sprintf("🏁 <b>%s</b> прошёл квест до конца!", html.EscapeString(PublicName(user)))
+}
+
+// PlanAnnouncement решает, нужно ли объявление о прогрессе участника, и возвращает канал и текст.
+// Объявление не нужно, если канал не настроен, число шагов не кратно интервалу или участник
+// включил режим «не беспокоить»
+func (a *ChannelAnnouncer) PlanAnnouncement(userID int64, completedSteps int, questCompleted bool) (int64, string, error) {
+	channelID, err := a.settingsRepo.GetAnnounceChannelID()
+	if err != nil || channelID == 0 {
+		return 0, "", err
+	}
+
+	if !questCompleted {
+		interval, err := a.settingsRepo.GetAnnounceStepInterval()
+		if err != nil {
+			return 0, "", err
+		}
+		if interval <= 0 || completedSteps <= 0 || completedSteps%interval != 0 {
+			return 0, "", nil
+		}
+	}
+
+	quiet, err := a.userRepo.IsDoNotDisturb(userID)
+	if err != nil || quiet {
+		return 0, "", err
+	}
+
+	user, err := a.userRepo.GetByID(userID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if questCompleted {
+		return channelID, FormatCompletionAnnouncement(user), nil
+	}
+	return channelID, FormatStepAnnouncement(user, completedSteps), nil
+}
+
+// AnnounceProgress публикует объявление о прогрессе участника, если оно предусмотрено настройками
+func (a *ChannelAnnouncer) AnnounceProgress(ctx context.Context, userID int64, completedSteps int, questCompleted bool) {
+	channelID, text, err := a.PlanAnnouncement(userID, completedSteps, questCompleted)
+	if err != nil {
+		log.Printf("[CHANNEL_ANNOUNCER] Error planning announcement for user %d: %v", userID, err)
+		return
+	}
+	if channelID == 0 {
+		return
+	}
+
+	if _, err := a.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    channelID,
+		Text:      text,
+		ParseMode: tgmodels.ParseModeHTML,
+	}); err != nil {
+		log.Printf("[CHANNEL_ANNOUNCER] Failed to post to channel %d: %v", channelID, err)
+	}
+}
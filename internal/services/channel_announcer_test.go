@@ -0,0 +1,83 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+func TestFormatAnnouncements(t *testing.T) {
+	user := &models.User{ID: 42, FirstName: "Аня <3", LastName: "Иванова", Username: "anya"}
+
+	step := FormatStepAnnouncement(user, 10)
+	if !strings.Contains(step, "<b>Аня &lt;3</b>") || !strings.Contains(step, "10") {
+		t.Errorf("Unexpected step announcement: %q", step)
+	}
+	for _, private := range []string{"Иванова", "anya", "42"} {
+		if strings.Contains(step, private) || strings.Contains(FormatCompletionAnnouncement(user), private) {
+			t.Errorf("Announcement must not reveal %q", private)
+		}
+	}
+
+	if got := FormatCompletionAnnouncement(&models.User{ID: 1}); !strings.Contains(got, "Участник") {
+		t.Errorf("Expected fallback name for user without first name, got %q", got)
+	}
+}
+
+func TestChannelAnnouncer_PlanAnnouncement(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	announcer := NewChannelAnnouncer(nil, settingsRepo, userRepo)
+
+	createTestUserForEngine(t, userRepo, 1)
+	createTestUserForEngine(t, userRepo, 2)
+
+	// Канал не настроен — объявлений нет
+	if channelID, _, err := announcer.PlanAnnouncement(1, 5, true); err != nil || channelID != 0 {
+		t.Fatalf("Expected no announcement without a channel, got %d, %v", channelID, err)
+	}
+
+	if err := settingsRepo.SetAnnounceChannelID(-100123); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.SetAnnounceStepInterval(5); err != nil {
+		t.Fatal(err)
+	}
+	if err := userRepo.SetDoNotDisturb(2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		userID    int64
+		steps     int
+		completed bool
+		announced bool
+	}{
+		{"milestone", 1, 10, false, true},
+		{"between milestones", 1, 7, false, false},
+		{"quest completed", 1, 12, true, true},
+		{"do not disturb milestone", 2, 10, false, false},
+		{"do not disturb completion", 2, 12, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channelID, text, err := announcer.PlanAnnouncement(tt.userID, tt.steps, tt.completed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if announced := channelID != 0; announced != tt.announced {
+				t.Fatalf("Expected announced=%t, got channel %d text %q", tt.announced, channelID, text)
+			}
+			if tt.announced && channelID != -100123 {
+				t.Errorf("Expected announcement to the configured channel, got %d", channelID)
+			}
+		})
+	}
+}
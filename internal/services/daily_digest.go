@@ -0,0 +1,189 @@
+package services
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+// DailyDigestStuckAttempts — сколько неудачных попыток за день на одном шаге считается «застреванием»
+const DailyDigestStuckAttempts = 5
+
+type DigestAchievement struct {
+	UserID int64
+	Name   string
+}
+
+type DigestStuckUser struct {
+	UserID    int64
+	StepOrder int
+	Attempts  int
+}
+
+// DailyDigest — сводка активности за один календарный день
+type DailyDigest struct {
+	Day                 time.Time
+	CompletedSteps      int
+	ActiveUsers         int
+	QuestCompletions    []int64
+	NotableAchievements []DigestAchievement
+	StuckUsers          []DigestStuckUser
+}
+
+func (d *DailyDigest) HasActivity() bool {
+	return d.CompletedSteps > 0 || len(d.NotableAchievements) > 0 || len(d.StuckUsers) > 0
+}
+
+// GetDailyDigest собирает сводку за день, в который попадает day (в часовом поясе day)
+func (s *StatisticsService) GetDailyDigest(day time.Time) (*DailyDigest, error) {
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	inDay := func(t time.Time) bool {
+		return !t.Before(dayStart) && t.Before(dayEnd)
+	}
+
+	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		digest := &DailyDigest{Day: dayStart}
+
+		var lastStepOrder int
+		if err := db.QueryRow(`
+			SELECT COALESCE(MAX(step_order), 0) FROM steps WHERE is_active = TRUE AND is_deleted = FALSE
+		`).Scan(&lastStepOrder); err != nil {
+			return nil, err
+		}
+
+		rows, err := db.Query(`
+			SELECT up.user_id, st.step_order, up.completed_at
+			FROM user_progress up
+			JOIN steps st ON up.step_id = st.id AND st.is_active = TRUE AND st.is_deleted = FALSE
+			WHERE up.status = 'approved' AND up.completed_at IS NOT NULL
+			ORDER BY up.completed_at
+		`)
+		if err != nil {
+			return nil, err
+		}
+		activeUsers := make(map[int64]bool)
+		for rows.Next() {
+			var userID int64
+			var stepOrder int
+			var completedAt time.Time
+			if err := rows.Scan(&userID, &stepOrder, &completedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if !inDay(completedAt) {
+				continue
+			}
+			digest.CompletedSteps++
+			activeUsers[userID] = true
+			if stepOrder == lastStepOrder {
+				digest.QuestCompletions = append(digest.QuestCompletions, userID)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		digest.ActiveUsers = len(activeUsers)
+
+		rows, err = db.Query(`
+			SELECT ua.user_id, a.name, ua.earned_at
+			FROM user_achievements ua
+			JOIN achievements a ON ua.achievement_id = a.id
+			WHERE a.category IN (?, ?) AND ua.is_retroactive = FALSE
+			ORDER BY ua.earned_at
+		`, models.CategoryUnique, models.CategoryComposite)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var achievement DigestAchievement
+			var earnedAt time.Time
+			if err := rows.Scan(&achievement.UserID, &achievement.Name, &earnedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if inDay(earnedAt) {
+				digest.NotableAchievements = append(digest.NotableAchievements, achievement)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		rows, err = db.Query(`
+			SELECT ua.user_id, st.step_order, ua.created_at
+			FROM user_answers ua
+			JOIN steps st ON ua.step_id = st.id AND st.is_active = TRUE AND st.is_deleted = FALSE
+			LEFT JOIN user_progress up ON up.user_id = ua.user_id AND up.step_id = ua.step_id
+			WHERE COALESCE(up.status, '') NOT IN ('approved', 'skipped')
+		`)
+		if err != nil {
+			return nil, err
+		}
+		attempts := make(map[[2]int64]int)
+		for rows.Next() {
+			var userID int64
+			var stepOrder int
+			var createdAt time.Time
+			if err := rows.Scan(&userID, &stepOrder, &createdAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if inDay(createdAt) {
+				attempts[[2]int64{userID, int64(stepOrder)}]++
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		for key, count := range attempts {
+			if count >= DailyDigestStuckAttempts {
+				digest.StuckUsers = append(digest.StuckUsers, DigestStuckUser{
+					UserID:    key[0],
+					StepOrder: int(key[1]),
+					Attempts:  count,
+				})
+			}
+		}
+		sort.Slice(digest.StuckUsers, func(i, j int) bool {
+			if digest.StuckUsers[i].Attempts != digest.StuckUsers[j].Attempts {
+				return digest.StuckUsers[i].Attempts > digest.StuckUsers[j].Attempts
+			}
+			return digest.StuckUsers[i].UserID < digest.StuckUsers[j].UserID
+		})
+
+		return digest, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*DailyDigest), nil
+}
+
+// ParseDigestTime разбирает время отправки сводки в формате ЧЧ:ММ
+func ParseDigestTime(value string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// IsDailyDigestDue сообщает, пора ли отправлять сводку: время отправки наступило,
+// а за текущий день (в часовом поясе now) сводка ещё не отправлялась
+func IsDailyDigestDue(now time.Time, sendAt, lastSentDay string) bool {
+	hour, minute, err := ParseDigestTime(sendAt)
+	if err != nil {
+		return false
+	}
+	if now.Format("2006-01-02") == lastSentDay {
+		return false
+	}
+	return now.Hour() > hour || (now.Hour() == hour && now.Minute() >= minute)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+func TestGetDailyDigest(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	stats := NewStatisticsServiceWithAchievements(queue, stepRepo, progressRepo, userRepo, achievementRepo)
+
+	step1 := createTestStep(t, stepRepo, 1)
+	step2 := createTestStep(t, stepRepo, 2)
+
+	day := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	yesterday := day.AddDate(0, 0, -1)
+
+	createTestUserForEngine(t, userRepo, 1)
+	createTestUserForEngine(t, userRepo, 2)
+	createTestUserForEngine(t, userRepo, 3)
+
+	// Пользователь 1 прошёл весь квест сегодня
+	completed1 := day.Add(-2 * time.Hour)
+	completed2 := day.Add(-time.Hour)
+	createUserProgress(t, progressRepo, 1, step1.ID, models.StatusApproved, &completed1)
+	createUserProgress(t, progressRepo, 1, step2.ID, models.StatusApproved, &completed2)
+	assignAchievementToUser(t, achievementRepo, 1, "pioneer", completed1)
+	assignAchievementToUser(t, achievementRepo, 1, "beginner_5", completed1)
+
+	// Пользователь 2 прошёл первый шаг вчера и застрял на втором сегодня
+	createUserProgress(t, progressRepo, 2, step1.ID, models.StatusApproved, &yesterday)
+	for i := 0; i < DailyDigestStuckAttempts; i++ {
+		createUserAnswer(t, queue, 2, step2.ID, false, day.Add(time.Duration(i)*time.Minute))
+	}
+
+	// Пользователь 3 ошибался вчера, что не попадает в сводку за сегодня
+	for i := 0; i < DailyDigestStuckAttempts; i++ {
+		createUserAnswer(t, queue, 3, step1.ID, false, yesterday.Add(time.Duration(i)*time.Minute))
+	}
+
+	digest, err := stats.GetDailyDigest(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !digest.HasActivity() {
+		t.Fatal("Expected digest to have activity")
+	}
+	if digest.CompletedSteps != 2 {
+		t.Errorf("Expected 2 completed steps, got %d", digest.CompletedSteps)
+	}
+	if digest.ActiveUsers != 1 {
+		t.Errorf("Expected 1 active user, got %d", digest.ActiveUsers)
+	}
+	if len(digest.QuestCompletions) != 1 || digest.QuestCompletions[0] != 1 {
+		t.Errorf("Expected user 1 to complete the quest, got %v", digest.QuestCompletions)
+	}
+	if len(digest.NotableAchievements) != 1 || digest.NotableAchievements[0].UserID != 1 {
+		t.Errorf("Expected only the unique achievement to be notable, got %v", digest.NotableAchievements)
+	}
+	if len(digest.StuckUsers) != 1 || digest.StuckUsers[0].UserID != 2 || digest.StuckUsers[0].StepOrder != 2 {
+		t.Errorf("Expected user 2 to be stuck on step 2, got %v", digest.StuckUsers)
+	}
+
+	quietDigest, err := stats.GetDailyDigest(day.AddDate(0, 0, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quietDigest.HasActivity() {
+		t.Errorf("Expected no activity on a quiet day, got %+v", quietDigest)
+	}
+}
+
+func TestIsDailyDigestDue(t *testing.T) {
+	now := time.Date(2025, 3, 10, 21, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		sendAt   string
+		lastSent string
+		want     bool
+	}{
+		{"disabled", "", "", false},
+		{"invalid", "25:00", "", false},
+		{"before time", "21:30", "", false},
+		{"time reached", "21:05", "", true},
+		{"after time", "09:00", "2025-03-09", true},
+		{"already sent today", "09:00", "2025-03-10", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsDailyDigestDue(now, tt.sendAt, tt.lastSent); got != tt.want {
+				t.Errorf("IsDailyDigestDue(%q, %q) = %v, want %v", tt.sendAt, tt.lastSent, got, tt.want)
+			}
+		})
+	}
+}
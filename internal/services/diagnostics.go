@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/go-telegram/bot"
+)
+
+// TableRowCount — число строк в таблице базы данных
+type TableRowCount struct {
+	Table string
+	Rows  int
+}
+
+// DiagnosticsReport — состояние бота и базы данных для команды /diag
+type DiagnosticsReport struct {
+	Uptime       time.Duration
+	DBSize       int64
+	WALSize      int64
+	JournalMode  string
+	Tables       []TableRowCount
+	LastBackup   time.Time
+	Goroutines   int
+	GetMeLatency time.Duration
+	GetMeError   error
+}
+
+// DiagnosticsService собирает сведения о работе бота: время работы, размер и таблицы базы,
+// режим журнала, последний бэкап, число горутин и задержку ответа Telegram
+type DiagnosticsService struct {
+	queue        *db.DBQueue
+	settingsRepo *db.SettingsRepository
+	bot          *bot.Bot
+	dbPath       string
+	startedAt    time.Time
+}
+
+func NewDiagnosticsService(queue *db.DBQueue, settingsRepo *db.SettingsRepository, b *bot.Bot, dbPath string) *DiagnosticsService {
+	return &DiagnosticsService{
+		queue:        queue,
+		settingsRepo: settingsRepo,
+		bot:          b,
+		dbPath:       dbPath,
+		startedAt:    time.Now(),
+	}
+}
+
+// CountTableRows возвращает число строк в каждой пользовательской таблице базы по алфавиту
+func (s *DiagnosticsService) CountTableRows() ([]TableRowCount, error) {
+	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+		if err != nil {
+			return nil, err
+		}
+
+		var tables []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			tables = append(tables, name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		counts := make([]TableRowCount, 0, len(tables))
+		for _, table := range tables {
+			var count int
+			query := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(table, `"`, `""`))
+			if err := db.QueryRow(query).Scan(&count); err != nil {
+				return nil, err
+			}
+			counts = append(counts, TableRowCount{Table: table, Rows: count})
+		}
+		return counts, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]TableRowCount), nil
+}
+
+func (s *DiagnosticsService) journalMode() (string, error) {
+	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var mode string
+		err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode)
+		return mode, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.(string), nil
+}
+
+// Collect собирает отчёт. Ошибки отдельных проверок не прерывают сбор: недоступные
+// сведения остаются пустыми
+func (s *DiagnosticsService) Collect(ctx context.Context) *DiagnosticsReport {
+	report := &DiagnosticsReport{
+		Uptime:     time.Since(s.startedAt),
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	if info, err := os.Stat(s.dbPath); err == nil {
+		report.DBSize = info.Size()
+	}
+	if info, err := os.Stat(s.dbPath + "-wal"); err == nil {
+		report.WALSize = info.Size()
+	}
+
+	report.JournalMode, _ = s.journalMode()
+	report.Tables, _ = s.CountTableRows()
+
+	if s.settingsRepo != nil {
+		report.LastBackup, _ = s.settingsRepo.GetLastBackupAt()
+	}
+
+	if s.bot != nil {
+		getMeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		started := time.Now()
+		_, report.GetMeError = s.bot.GetMe(getMeCtx)
+		report.GetMeLatency = time.Since(started)
+		cancel()
+	}
+
+	return report
+}
+
+// FormatDiagnosticsReport форматирует отчёт для отправки администратору
+func FormatDiagnosticsReport(report *DiagnosticsReport) string {
+	var sb strings.Builder
+	sb.WriteString("🩺 <b>Диагностика</b>\n\n")
+	sb.WriteString(fmt.Sprintf("⏱ Время работы: %s\n", report.Uptime.Round(time.Second)))
+	sb.WriteString(fmt.Sprintf("🧵 Горутин: %d\n", report.Goroutines))
+
+	if report.GetMeError != nil {
+		sb.WriteString(fmt.Sprintf("📡 Telegram getMe: ошибка — %s\n", html.EscapeString(report.GetMeError.Error())))
+	} else {
+		sb.WriteString(fmt.Sprintf("📡 Telegram getMe: %d мс\n", report.GetMeLatency.Milliseconds()))
+	}
+
+	sb.WriteString("\n🗄 <b>База данных</b>\n")
+	sb.WriteString(fmt.Sprintf("Размер: %s\n", formatBytes(report.DBSize)))
+	journal := report.JournalMode
+	if journal == "" {
+		journal = "неизвестно"
+	}
+	sb.WriteString(fmt.Sprintf("Журнал: %s", html.EscapeString(journal)))
+	if strings.EqualFold(report.JournalMode, "wal") {
+		sb.WriteString(fmt.Sprintf(", WAL-файл %s", formatBytes(report.WALSize)))
+	}
+	sb.WriteString("\n")
+
+	if report.LastBackup.IsZero() {
+		sb.WriteString("Последний бэкап: не создавался\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Последний бэкап: %s\n", report.LastBackup.Format("02.01.2006 15:04:05")))
+	}
+
+	if len(report.Tables) > 0 {
+		sb.WriteString("\n📋 <b>Строк в таблицах</b>\n")
+		for _, table := range report.Tables {
+			sb.WriteString(fmt.Sprintf("%s: %d\n", html.EscapeString(table.Table), table.Rows))
+		}
+	}
+
+	return sb.String()
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d Б", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"КБ", "МБ", "ГБ"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f ТБ", value)
+}
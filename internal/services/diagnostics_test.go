@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+)
+
+func TestDiagnosticsCountTableRows(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	for _, id := range []int64{1, 2, 3} {
+		createTestUserForEngine(t, userRepo, id)
+	}
+	createTestStep(t, stepRepo, 1)
+	createTestStep(t, stepRepo, 2)
+
+	diagnostics := NewDiagnosticsService(queue, db.NewSettingsRepository(queue), nil, "")
+	counts, err := diagnostics.CountTableRows()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byTable := make(map[string]int, len(counts))
+	for i, count := range counts {
+		if i > 0 && counts[i-1].Table > count.Table {
+			t.Errorf("Expected tables in alphabetical order, got %s before %s", counts[i-1].Table, count.Table)
+		}
+		if strings.HasPrefix(count.Table, "sqlite_") {
+			t.Errorf("Expected internal table %s to be skipped", count.Table)
+		}
+		byTable[count.Table] = count.Rows
+	}
+
+	if byTable["users"] != 3 {
+		t.Errorf("Expected 3 users, got %d", byTable["users"])
+	}
+	if byTable["steps"] != 2 {
+		t.Errorf("Expected 2 steps, got %d", byTable["steps"])
+	}
+	if _, ok := byTable["user_progress"]; !ok {
+		t.Error("Expected empty tables to be reported too")
+	}
+	if byTable["settings"] == 0 {
+		t.Error("Expected default settings to be counted")
+	}
+}
+
+func TestDiagnosticsCollect_WithoutBot(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	backupAt := time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)
+	if err := settingsRepo.SetLastBackupAt(backupAt); err != nil {
+		t.Fatal(err)
+	}
+
+	report := NewDiagnosticsService(queue, settingsRepo, nil, "").Collect(context.Background())
+
+	if !report.LastBackup.Equal(backupAt) {
+		t.Errorf("Expected last backup %v, got %v", backupAt, report.LastBackup)
+	}
+	if report.Goroutines <= 0 {
+		t.Errorf("Expected goroutine count, got %d", report.Goroutines)
+	}
+	if len(report.Tables) == 0 {
+		t.Error("Expected table row counts in the report")
+	}
+}
+
+func TestFormatDiagnosticsReport(t *testing.T) {
+	report := &DiagnosticsReport{
+		Uptime:       90 * time.Minute,
+		DBSize:       3 * 1024 * 1024,
+		WALSize:      2048,
+		JournalMode:  "wal",
+		Tables:       []TableRowCount{{Table: "users", Rows: 42}},
+		Goroutines:   12,
+		GetMeLatency: 150 * time.Millisecond,
+	}
+
+	formatted := FormatDiagnosticsReport(report)
+	for _, want := range []string{"1h30m0s", "Горутин: 12", "150 мс", "3.0 МБ", "WAL-файл 2.0 КБ", "не создавался", "users: 42"} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, formatted)
+		}
+	}
+
+	report.GetMeError = errors.New("timeout <x>")
+	if formatted := FormatDiagnosticsReport(report); !strings.Contains(formatted, "timeout &lt;x&gt;") {
+		t.Errorf("Expected escaped getMe error, got:\n%s", formatted)
+	}
+}
@@ -4,59 +4,211 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"runtime/debug"
+	"sync"
+	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 )
 
+type ErrorCategory string
+
+const (
+	ErrorCategoryDB       ErrorCategory = "db"
+	ErrorCategoryTelegram ErrorCategory = "telegram"
+	ErrorCategoryLogic    ErrorCategory = "logic"
+	ErrorCategoryPanic    ErrorCategory = "panic"
+)
+
+// ErrorDedupWindow — в течение этого времени одинаковые ошибки не отправляются админу повторно,
+// а только подсчитываются
+const ErrorDedupWindow = time.Minute
+
+type reportedError struct {
+	category   ErrorCategory
+	text       string
+	reportedAt time.Time
+	suppressed int
+}
+
 type ErrorManager struct {
 	bot     *bot.Bot
 	adminID int64
+
+	mu     sync.Mutex
+	recent map[string]*reportedError
+	window time.Duration
+	now    func() time.Time
+	send   func(ctx context.Context, text string)
 }
 
 func NewErrorManager(b *bot.Bot, adminID int64) *ErrorManager {
-	return &ErrorManager{
+	e := &ErrorManager{
 		bot:     b,
 		adminID: adminID,
+		recent:  make(map[string]*reportedError),
+		window:  ErrorDedupWindow,
+		now:     time.Now,
 	}
+	e.send = e.sendToAdmin
+	return e
 }
 
+func (e *ErrorManager) sendToAdmin(ctx context.Context, text string) {
+	if e.bot == nil {
+		return
+	}
+	_, _ = e.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: e.adminID,
+		Text:   text,
+	})
+}
+
+func errorCategoryTitle(category ErrorCategory) string {
+	switch category {
+	case ErrorCategoryDB:
+		return "🗄 DB error"
+	case ErrorCategoryTelegram:
+		return "📡 Telegram API error"
+	case ErrorCategoryPanic:
+		return "🚨 Panic"
+	default:
+		return "⚙️ Logic error"
+	}
+}
+
+// Report отправляет админу ошибку указанной категории. Одинаковые ошибки внутри окна
+// ErrorDedupWindow схлопываются: повторы только подсчитываются, а их число приходит
+// вместе со следующим сообщением или при FlushSuppressed
+func (e *ErrorManager) Report(ctx context.Context, category ErrorCategory, err error) {
+	if err == nil {
+		return
+	}
+	e.report(ctx, category, err.Error(), fmt.Sprintf("%s\nError: %v", errorCategoryTitle(category), err))
+}
+
+func (e *ErrorManager) report(ctx context.Context, category ErrorCategory, key, text string) {
+	key = string(category) + ":" + key
+	now := e.now()
+
+	e.mu.Lock()
+	entry, ok := e.recent[key]
+	if ok && now.Sub(entry.reportedAt) < e.window {
+		entry.suppressed++
+		e.mu.Unlock()
+		return
+	}
+	suppressed := 0
+	if ok {
+		suppressed = entry.suppressed
+	}
+	e.recent[key] = &reportedError{category: category, text: text, reportedAt: now}
+	e.mu.Unlock()
+
+	if suppressed > 0 {
+		text = fmt.Sprintf("%s\n\n🔁 Repeated %d more times since the previous report", text, suppressed)
+	}
+	e.send(ctx, truncateErrorMessage(text))
+}
+
+// FlushSuppressed отправляет сводку по ошибкам, повторы которых были подавлены и окно
+// дедупликации которых уже закончилось, и забывает эти ошибки
+func (e *ErrorManager) FlushSuppressed(ctx context.Context) {
+	now := e.now()
+
+	var messages []string
+	e.mu.Lock()
+	for key, entry := range e.recent {
+		if now.Sub(entry.reportedAt) < e.window {
+			continue
+		}
+		if entry.suppressed > 0 {
+			messages = append(messages, fmt.Sprintf("%s\n\n🔁 Repeated %d more times in %s", entry.text, entry.suppressed, e.window))
+		}
+		delete(e.recent, key)
+	}
+	e.mu.Unlock()
+
+	for _, text := range messages {
+		e.send(ctx, truncateErrorMessage(text))
+	}
+}
+
+func truncateErrorMessage(msg string) string {
+	if len(msg) > 4000 {
+		return msg[:4000] + "\n... (truncated)"
+	}
+	return msg
+}
+
+// NotifyAdmin сообщает админу о панике при обработке обновления: кто его прислал, какого
+// оно типа и стек вызовов. Одинаковые паники схлопываются так же, как ошибки в Report
 func (e *ErrorManager) NotifyAdmin(ctx context.Context, panicValue interface{}, update *models.Update) {
 	userInfo := "unknown"
-	stepInfo := "unknown"
+	var from *models.User
 
 	if update != nil {
-		if update.Message != nil && update.Message.From != nil {
-			userInfo = fmt.Sprintf("[%d]", update.Message.From.ID)
-			if update.Message.From.FirstName != "" {
-				userInfo = update.Message.From.FirstName + " " + userInfo
-			}
-			if update.Message.From.Username != "" {
-				userInfo = userInfo + " @" + update.Message.From.Username
-			}
-		} else if update.CallbackQuery != nil && update.CallbackQuery.From.ID != 0 {
-			userInfo = fmt.Sprintf("[%d]", update.CallbackQuery.From.ID)
-			if update.CallbackQuery.From.FirstName != "" {
-				userInfo = update.CallbackQuery.From.FirstName + " " + userInfo
-			}
-			if update.CallbackQuery.From.Username != "" {
-				userInfo = userInfo + " @" + update.CallbackQuery.From.Username
-			}
+		switch {
+		case update.Message != nil:
+			from = update.Message.From
+		case update.EditedMessage != nil:
+			from = update.EditedMessage.From
+		case update.CallbackQuery != nil:
+			from = &update.CallbackQuery.From
 		}
 	}
+	if from != nil && from.ID != 0 {
+		userInfo = fmt.Sprintf("[%d]", from.ID)
+		if from.FirstName != "" {
+			userInfo = from.FirstName + " " + userInfo
+		}
+		if from.Username != "" {
+			userInfo = userInfo + " @" + from.Username
+		}
+	}
+	kind := updateKind(update)
 
-	msg := fmt.Sprintf("🚨 Panic in handler\nUser: %s\nStep: %s\nError: %v\n\nStack trace:\n%s",
-		userInfo, stepInfo, panicValue, string(debug.Stack()))
+	log.Printf("[PANIC] Recovered panic in %s update from user %s: %v", kind, userInfo, panicValue)
 
-	if len(msg) > 4000 {
-		msg = msg[:4000] + "\n... (truncated)"
+	msg := fmt.Sprintf("🚨 Panic in handler\nUser: %s\nUpdate: %s\nError: %v\n\nStack trace:\n%s",
+		userInfo, kind, panicValue, string(debug.Stack()))
+	e.report(ctx, ErrorCategoryPanic, fmt.Sprintf("%s:%v", kind, panicValue), msg)
+}
+
+// Recover перехватывает панику в фоновой задаче и сообщает о ней админу, чтобы бот продолжил
+// работу. Вызывается через defer в начале задачи
+func (e *ErrorManager) Recover(ctx context.Context, task string) {
+	if r := recover(); r != nil {
+		log.Printf("[PANIC] Recovered panic in %s: %v", task, r)
+		msg := fmt.Sprintf("🚨 Panic in %s\nError: %v\n\nStack trace:\n%s", task, r, string(debug.Stack()))
+		e.report(ctx, ErrorCategoryPanic, fmt.Sprintf("%s:%v", task, r), msg)
 	}
+}
 
-	_, _ = e.bot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: e.adminID,
-		Text:   msg,
-	})
+// Guard выполняет одну итерацию периодической задачи, перехватывая панику, чтобы следующая
+// итерация всё равно запустилась
+func (e *ErrorManager) Guard(ctx context.Context, task string, fn func()) {
+	defer e.Recover(ctx, task)
+	fn()
+}
+
+func updateKind(update *models.Update) string {
+	switch {
+	case update == nil:
+		return "unknown"
+	case update.Message != nil:
+		return "message"
+	case update.EditedMessage != nil:
+		return "edited_message"
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	case update.MyChatMember != nil:
+		return "my_chat_member"
+	default:
+		return "unknown"
+	}
 }
 
 func (e *ErrorManager) NotifyAdminWithCurl(ctx context.Context, chatID int64, request interface{}, err error) {
@@ -65,14 +217,7 @@ func (e *ErrorManager) NotifyAdminWithCurl(ctx context.Context, chatID int64, re
 	msg := fmt.Sprintf("❌ Failed to send message\nUser: [%d]\nError: %v\n\nCurl:\n%s",
 		chatID, err, curl)
 
-	if len(msg) > 4000 {
-		msg = msg[:4000] + "\n... (truncated)"
-	}
-
-	_, _ = e.bot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: e.adminID,
-		Text:   msg,
-	})
+	e.report(ctx, ErrorCategoryTelegram, fmt.Sprintf("%v", err), msg)
 }
 
 func (e *ErrorManager) buildCurlCommand(_ int64, request interface{}) string {
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func newTestErrorManager() (*ErrorManager, *[]string, *time.Time) {
+	e := NewErrorManager(nil, 1)
+	var sent []string
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+	e.send = func(ctx context.Context, text string) {
+		sent = append(sent, text)
+	}
+	return e, &sent, &now
+}
+
+func TestErrorManagerReport_CollapsesRepeatedErrors(t *testing.T) {
+	e, sent, now := newTestErrorManager()
+	ctx := context.Background()
+
+	for i := 0; i < 50; i++ {
+		e.Report(ctx, ErrorCategoryDB, errors.New("database is locked"))
+		*now = now.Add(time.Second)
+	}
+
+	if len(*sent) != 1 {
+		t.Fatalf("Expected repeated error to be sent once within the window, got %d messages", len(*sent))
+	}
+	if !strings.Contains((*sent)[0], "DB error") || !strings.Contains((*sent)[0], "database is locked") {
+		t.Errorf("Unexpected report text: %q", (*sent)[0])
+	}
+
+	e.FlushSuppressed(ctx)
+	if len(*sent) != 1 {
+		t.Fatalf("Expected no summary before the window ends, got %d messages", len(*sent))
+	}
+
+	*now = now.Add(ErrorDedupWindow)
+	e.FlushSuppressed(ctx)
+	if len(*sent) != 2 {
+		t.Fatalf("Expected a summary after the window ends, got %d messages", len(*sent))
+	}
+	if !strings.Contains((*sent)[1], "49") {
+		t.Errorf("Expected summary to contain the repeat count, got %q", (*sent)[1])
+	}
+
+	e.FlushSuppressed(ctx)
+	if len(*sent) != 2 {
+		t.Errorf("Expected summary to be sent only once, got %d messages", len(*sent))
+	}
+}
+
+func TestErrorManagerReport_DistinctErrorsReportedSeparately(t *testing.T) {
+	e, sent, _ := newTestErrorManager()
+	ctx := context.Background()
+
+	e.Report(ctx, ErrorCategoryDB, errors.New("database is locked"))
+	e.Report(ctx, ErrorCategoryTelegram, errors.New("database is locked"))
+	e.Report(ctx, ErrorCategoryDB, errors.New("no such table: steps"))
+	e.Report(ctx, ErrorCategoryLogic, errors.New("unexpected step order"))
+	e.Report(ctx, ErrorCategoryLogic, nil)
+
+	if len(*sent) != 4 {
+		t.Fatalf("Expected 4 distinct reports, got %d: %v", len(*sent), *sent)
+	}
+}
+
+func TestErrorManagerReport_RepeatAfterWindowIncludesCount(t *testing.T) {
+	e, sent, now := newTestErrorManager()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		e.Report(ctx, ErrorCategoryTelegram, errors.New("Forbidden: bot was blocked by the user"))
+	}
+	*now = now.Add(ErrorDedupWindow + time.Second)
+	e.Report(ctx, ErrorCategoryTelegram, errors.New("Forbidden: bot was blocked by the user"))
+
+	if len(*sent) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(*sent))
+	}
+	if !strings.Contains((*sent)[1], "Repeated 2 more times") {
+		t.Errorf("Expected repeat count in the next report, got %q", (*sent)[1])
+	}
+}
+
+func TestErrorManagerNotifyAdmin_IncludesUpdateContext(t *testing.T) {
+	e, sent, _ := newTestErrorManager()
+
+	update := &models.Update{EditedMessage: &models.Message{From: &models.User{ID: 42, FirstName: "Анна", Username: "anna"}}}
+	e.NotifyAdmin(context.Background(), "boom", update)
+	e.NotifyAdmin(context.Background(), "boom", update)
+
+	if len(*sent) != 1 {
+		t.Fatalf("Expected repeated panic to be reported once, got %d messages", len(*sent))
+	}
+	for _, want := range []string{"Panic in handler", "Анна [42] @anna", "Update: edited_message", "Error: boom", "Stack trace"} {
+		if !strings.Contains((*sent)[0], want) {
+			t.Errorf("Expected %q in panic report, got %q", want, (*sent)[0])
+		}
+	}
+}
+
+func TestErrorManagerGuard_RecoversPanic(t *testing.T) {
+	e, sent, _ := newTestErrorManager()
+
+	ran := 0
+	for i := 0; i < 2; i++ {
+		e.Guard(context.Background(), "hall of fame", func() {
+			ran++
+			var steps []int
+			_ = steps[ran]
+		})
+	}
+
+	if ran != 2 {
+		t.Errorf("Expected the task to run again after a panic, ran %d times", ran)
+	}
+	if len(*sent) != 2 || !strings.Contains((*sent)[0], "Panic in hall of fame") || !strings.Contains((*sent)[0], "index out of range") {
+		t.Errorf("Expected both panics to be reported, got %v", *sent)
+	}
+}
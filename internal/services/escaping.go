@@ -3,7 +3,10 @@ package services
 import (
 	"fmt"
 	"html"
+	"regexp"
 	"strings"
+
+	"github.com/ad/go-telegram-quest/internal/models"
 )
 
 func FormatBold(text string) string {
@@ -29,3 +32,162 @@ func SafeConcat(parts ...string) string {
 	}
 	return sb.String()
 }
+
+var allowedTelegramTags = map[string]bool{
+	"b":          true,
+	"strong":     true,
+	"i":          true,
+	"em":         true,
+	"u":          true,
+	"ins":        true,
+	"s":          true,
+	"strike":     true,
+	"del":        true,
+	"a":          true,
+	"code":       true,
+	"pre":        true,
+	"blockquote": true,
+	"span":       true,
+	"tg-spoiler": true,
+	"tg-emoji":   true,
+}
+
+var htmlEntityPattern = regexp.MustCompile(`^&(lt|gt|amp|quot|#[0-9]+|#x[0-9a-fA-F]+);`)
+
+var (
+	markdownCodePattern   = regexp.MustCompile("`([^`\n]+)`")
+	markdownBoldPattern   = regexp.MustCompile(`\*([^*\n]+)\*`)
+	markdownItalicPattern = regexp.MustCompile(`(^|[\s(])_([^_\n]+)_`)
+	markdownLinkPattern   = regexp.MustCompile(`\[([^\]\n]+)\]\(([^)\s]+)\)`)
+)
+
+// RenderSettingMessage приводит текст настройки к HTML с учётом её режима разметки,
+// чтобы его можно было безопасно склеивать с остальными HTML-сообщениями
+func RenderSettingMessage(text string, mode models.MessageParseMode) string {
+	switch mode {
+	case models.MessageParseModePlain:
+		return html.EscapeString(text)
+	case models.MessageParseModeMarkdown:
+		return markdownToHTML(text)
+	default:
+		return SanitizeTelegramHTML(text)
+	}
+}
+
+// SanitizeTelegramHTML оставляет поддерживаемые Telegram теги и экранирует всё остальное.
+// Теги без пары (незакрытый <b> или </i> без открывающего) тоже экранируются: Telegram
+// отклоняет сообщение с несбалансированной разметкой целиком
+func SanitizeTelegramHTML(text string) string {
+	type piece struct {
+		text    string
+		tag     string
+		closing bool
+		keep    bool
+	}
+
+	var pieces []piece
+	var open []int
+	var sb strings.Builder
+	flush := func() {
+		if sb.Len() > 0 {
+			pieces = append(pieces, piece{text: sb.String()})
+			sb.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '&':
+			if htmlEntityPattern.MatchString(text[i:]) {
+				sb.WriteByte('&')
+			} else {
+				sb.WriteString("&amp;")
+			}
+			i++
+		case '<':
+			end := strings.IndexAny(text[i+1:], "<>")
+			if end >= 0 && text[i+1+end] == '>' && isAllowedTelegramTag(text[i+1:i+1+end]) {
+				flush()
+				raw := text[i : i+end+2]
+				name, closing := telegramTagName(text[i+1 : i+1+end])
+				if !closing {
+					open = append(open, len(pieces))
+					pieces = append(pieces, piece{text: raw, tag: name})
+				} else {
+					// Закрывающий тег закрывает ближайший открытый с тем же именем; открытые
+					// внутри него, но не закрытые теги остаются без пары
+					p := piece{text: raw, tag: name, closing: true}
+					for j := len(open) - 1; j >= 0; j-- {
+						if pieces[open[j]].tag == name {
+							pieces[open[j]].keep = true
+							p.keep = true
+							open = open[:j]
+							break
+						}
+					}
+					pieces = append(pieces, p)
+				}
+				i += end + 2
+			} else {
+				sb.WriteString("&lt;")
+				i++
+			}
+		case '>':
+			sb.WriteString("&gt;")
+			i++
+		default:
+			sb.WriteByte(text[i])
+			i++
+		}
+	}
+	flush()
+
+	var result strings.Builder
+	for _, p := range pieces {
+		if p.tag != "" && !p.keep {
+			result.WriteString(html.EscapeString(p.text))
+		} else {
+			result.WriteString(p.text)
+		}
+	}
+	return result.String()
+}
+
+func isAllowedTelegramTag(tag string) bool {
+	name, _ := telegramTagName(tag)
+	return allowedTelegramTags[name]
+}
+
+// telegramTagName возвращает имя тега в нижнем регистре и признак закрывающего тега
+func telegramTagName(tag string) (string, bool) {
+	tag = strings.TrimSpace(tag)
+	closing := strings.HasPrefix(tag, "/")
+	name := strings.TrimPrefix(tag, "/")
+	if idx := strings.IndexAny(name, " \t\n"); idx != -1 {
+		name = name[:idx]
+	}
+	return strings.ToLower(name), closing
+}
+
+// markdownToHTML переводит упрощённый Markdown в HTML. Внутри `кода` разметка не
+// применяется: звёздочки и подчёркивания там остаются как есть
+func markdownToHTML(text string) string {
+	escaped := html.EscapeString(text)
+
+	var sb strings.Builder
+	last := 0
+	for _, match := range markdownCodePattern.FindAllStringSubmatchIndex(escaped, -1) {
+		sb.WriteString(markdownInlineToHTML(escaped[last:match[0]]))
+		sb.WriteString("<code>" + escaped[match[2]:match[3]] + "</code>")
+		last = match[1]
+	}
+	sb.WriteString(markdownInlineToHTML(escaped[last:]))
+	return sb.String()
+}
+
+// markdownInlineToHTML применяет ссылки, жирный и курсив к уже экранированному тексту без кода
+func markdownInlineToHTML(text string) string {
+	text = markdownLinkPattern.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	text = markdownBoldPattern.ReplaceAllString(text, "<b>$1</b>")
+	return markdownItalicPattern.ReplaceAllString(text, "$1<i>$2</i>")
+}
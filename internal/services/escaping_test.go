@@ -5,6 +5,8 @@ import (
 	"strings"
 	"testing"
 	"testing/quick"
+
+	"github.com/ad/go-telegram-quest/internal/models"
 )
 
 func TestProperty3_BoldFormattingConversion(t *testing.T) {
@@ -226,3 +228,70 @@ func TestHTMLCharacterEscaping(t *testing.T) {
 		}
 	}
 }
+
+func TestRenderSettingMessage_HTMLMode(t *testing.T) {
+	result := RenderSettingMessage("<b>Привет</b> & добро пожаловать", models.MessageParseModeHTML)
+	expected := "<b>Привет</b> &amp; добро пожаловать"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestRenderSettingMessage_HTMLModeEscapesUnknownTags(t *testing.T) {
+	result := RenderSettingMessage("2 < 3 и <script>alert(1)</script> &amp; <i>ok</i>", models.MessageParseModeHTML)
+	expected := "2 &lt; 3 и &lt;script&gt;alert(1)&lt;/script&gt; &amp; <i>ok</i>"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestSanitizeTelegramHTML_UnbalancedTags(t *testing.T) {
+	tests := map[string]string{
+		"<b>жирный":                    "&lt;b&gt;жирный",
+		"курсив</i>":                   "курсив&lt;/i&gt;",
+		"<b><i>оба</b>":                "<b>&lt;i&gt;оба</b>",
+		"<b>один</b> <i>два</i>":       "<b>один</b> <i>два</i>",
+		`<a href="https://x.y">с</A>`:  `<a href="https://x.y">с</A>`,
+		`<a href="https://x.y">с`:      "&lt;a href=&#34;https://x.y&#34;&gt;с",
+		"<b>внешний <b>внутренний</b>": "&lt;b&gt;внешний <b>внутренний</b>",
+	}
+	for input, expected := range tests {
+		if result := SanitizeTelegramHTML(input); result != expected {
+			t.Errorf("SanitizeTelegramHTML(%q) = %q, want %q", input, result, expected)
+		}
+	}
+}
+
+func TestRenderSettingMessage_MarkdownSkipsFormattingInCode(t *testing.T) {
+	result := RenderSettingMessage("`a*b*c_d_` и *жирный*", models.MessageParseModeMarkdown)
+	expected := "<code>a*b*c_d_</code> и <b>жирный</b>"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestRenderSettingMessage_MarkdownMode(t *testing.T) {
+	result := RenderSettingMessage("*Привет* <b>мир</b> _друг_ `код` [сайт](https://example.com/a_b)", models.MessageParseModeMarkdown)
+	expected := "<b>Привет</b> &lt;b&gt;мир&lt;/b&gt; <i>друг</i> <code>код</code> <a href=\"https://example.com/a_b\">сайт</a>"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestRenderSettingMessage_PlainModeEscapes(t *testing.T) {
+	result := RenderSettingMessage("<b>Привет</b> & пока", models.MessageParseModePlain)
+	expected := "&lt;b&gt;Привет&lt;/b&gt; &amp; пока"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestProperty_PlainSettingMessageMatchesHTMLEscape(t *testing.T) {
+	property := func(text string) bool {
+		return RenderSettingMessage(text, models.MessageParseModePlain) == html.EscapeString(text)
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 100}); err != nil {
+		t.Errorf("Plain mode should fully escape text: %v", err)
+	}
+}
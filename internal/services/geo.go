@@ -0,0 +1,50 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusMeters = 6371000.0
+
+// DefaultLocationRadius — радиус зоны в метрах, если администратор прислал только точку
+const DefaultLocationRadius = 50
+
+// HaversineDistance возвращает расстояние между двумя точками на поверхности Земли в метрах
+func HaversineDistance(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ParseLocationTarget разбирает строку вида «55.7539, 37.6208 100» в координаты и радиус в метрах.
+// Если радиус не указан, используется DefaultLocationRadius
+func ParseLocationTarget(value string) (lat, lng float64, radius int, err error) {
+	fields := strings.Fields(strings.ReplaceAll(value, ",", " "))
+	if len(fields) != 2 && len(fields) != 3 {
+		return 0, 0, 0, fmt.Errorf("expected latitude, longitude and optional radius")
+	}
+
+	if lat, err = strconv.ParseFloat(fields[0], 64); err != nil || lat < -90 || lat > 90 {
+		return 0, 0, 0, fmt.Errorf("invalid latitude: %s", fields[0])
+	}
+	if lng, err = strconv.ParseFloat(fields[1], 64); err != nil || lng < -180 || lng > 180 {
+		return 0, 0, 0, fmt.Errorf("invalid longitude: %s", fields[1])
+	}
+
+	radius = DefaultLocationRadius
+	if len(fields) == 3 {
+		if radius, err = strconv.Atoi(fields[2]); err != nil || radius <= 0 {
+			return 0, 0, 0, fmt.Errorf("invalid radius: %s", fields[2])
+		}
+	}
+	return lat, lng, radius, nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+func TestHaversineDistance(t *testing.T) {
+	tests := []struct {
+		name       string
+		lat1, lng1 float64
+		lat2, lng2 float64
+		want       float64
+		tolerance  float64
+	}{
+		{"same point", 55.7539, 37.6208, 55.7539, 37.6208, 0, 0.001},
+		{"moscow to saint petersburg", 55.7558, 37.6173, 59.9343, 30.3351, 634000, 2000},
+		{"one degree of latitude", 0, 0, 1, 0, 111195, 10},
+		{"across antimeridian", 0, 179.9, 0, -179.9, 22239, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HaversineDistance(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("HaversineDistance() = %.1f, want %.1f ± %.1f", got, tt.want, tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestParseLocationTarget(t *testing.T) {
+	lat, lng, radius, err := ParseLocationTarget("55.7539, 37.6208 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lat != 55.7539 || lng != 37.6208 || radius != 100 {
+		t.Errorf("Unexpected target: %f %f %d", lat, lng, radius)
+	}
+
+	_, _, radius, err = ParseLocationTarget("55.7539 37.6208")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if radius != DefaultLocationRadius {
+		t.Errorf("Expected default radius %d, got %d", DefaultLocationRadius, radius)
+	}
+
+	for _, value := range []string{"", "55.7", "91 10 5", "10 181", "10 10 0", "a b c"} {
+		if _, _, _, err := ParseLocationTarget(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestCheckLocationAnswer(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	checker := NewAnswerChecker(db.NewAnswerRepository(queue), db.NewProgressRepository(queue), db.NewUserRepository(queue), db.NewSettingsRepository(queue))
+
+	step := createTestStepWithType(t, stepRepo, 1, models.AnswerTypeLocation)
+	if err := stepRepo.UpdateLocationTarget(step.ID, 55.7539, 37.6208, 100); err != nil {
+		t.Fatal(err)
+	}
+	step, err := stepRepo.GetByID(step.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ~55 м к северу от цели
+	result, err := checker.CheckLocationAnswer(step, 55.7544, 37.6208)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsCorrect {
+		t.Error("Expected point within radius to be accepted")
+	}
+
+	// ~220 м к северу от цели
+	result, err = checker.CheckLocationAnswer(step, 55.7559, 37.6208)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected point outside radius to be rejected")
+	}
+
+	step.LocationRadius = 0
+	result, err = checker.CheckLocationAnswer(step, step.LocationLat, step.LocationLng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected step without target to never auto-approve")
+	}
+}
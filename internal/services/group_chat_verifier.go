@@ -2,16 +2,60 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
 
 	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/go-telegram/bot"
 	tgmodels "github.com/go-telegram/bot/models"
 )
 
+// ErrBotLacksGroupRights — бот не может проверить участие: его удалили из группы или он
+// лишился прав администратора
+var ErrBotLacksGroupRights = errors.New("bot lacks rights in the required group")
+
+// BotGroupAccess — уровень доступа бота к группе ограничения участия
+type BotGroupAccess int
+
+const (
+	// BotGroupAbsent — бота нет в группе: проверить участие невозможно
+	BotGroupAbsent BotGroupAccess = iota
+	// BotGroupMember — бот в группе без прав администратора: проверка может не работать
+	BotGroupMember
+	// BotGroupAdmin — бот администратор группы: проверка работает
+	BotGroupAdmin
+)
+
+func (a BotGroupAccess) String() string {
+	switch a {
+	case BotGroupAdmin:
+		return "admin"
+	case BotGroupMember:
+		return "member"
+	default:
+		return "absent"
+	}
+}
+
+// BotStatusChange — изменение прав бота в группе ограничения участия из my_chat_member
+type BotStatusChange struct {
+	ChatID    int64
+	ChatTitle string
+	Old       BotGroupAccess
+	New       BotGroupAccess
+}
+
 type GroupChatVerifier struct {
 	bot          *bot.Bot
 	settingsRepo *db.SettingsRepository
+
+	statusMutex sync.Mutex
+	// statusChatID и status — последний известный статус бота в группе. Статус известен
+	// только после my_chat_member и не переживает перезапуск: до этого проверка идёт как обычно
+	statusChatID int64
+	status       BotGroupAccess
 }
 
 func NewGroupChatVerifier(b *bot.Bot, settingsRepo *db.SettingsRepository) *GroupChatVerifier {
@@ -29,6 +73,58 @@ func (v *GroupChatVerifier) IsVerificationEnabled() (bool, error) {
 	return chatID != 0, nil
 }
 
+// BotGroupAccessOf переводит статус участника чата в уровень доступа бота
+func BotGroupAccessOf(status tgmodels.ChatMemberType) BotGroupAccess {
+	switch status {
+	case tgmodels.ChatMemberTypeOwner, tgmodels.ChatMemberTypeAdministrator:
+		return BotGroupAdmin
+	case tgmodels.ChatMemberTypeMember, tgmodels.ChatMemberTypeRestricted:
+		return BotGroupMember
+	default:
+		return BotGroupAbsent
+	}
+}
+
+// HandleBotStatusUpdate запоминает статус бота из my_chat_member. Изменение возвращается,
+// только если это группа ограничения участия и уровень доступа бота поменялся
+func (v *GroupChatVerifier) HandleBotStatusUpdate(update *tgmodels.ChatMemberUpdated) (*BotStatusChange, error) {
+	chatID, err := v.settingsRepo.GetRequiredGroupChatID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group chat ID: %w", err)
+	}
+	if chatID == 0 || update.Chat.ID != chatID {
+		return nil, nil
+	}
+
+	change := &BotStatusChange{
+		ChatID:    chatID,
+		ChatTitle: update.Chat.Title,
+		Old:       BotGroupAccessOf(update.OldChatMember.Type),
+		New:       BotGroupAccessOf(update.NewChatMember.Type),
+	}
+
+	v.statusMutex.Lock()
+	v.statusChatID = chatID
+	v.status = change.New
+	v.statusMutex.Unlock()
+
+	if change.Old == change.New {
+		return nil, nil
+	}
+	log.Printf("[GROUP_VERIFIER] Bot access in group %d changed: %s -> %s", chatID, change.Old, change.New)
+	return change, nil
+}
+
+// BotAccess возвращает последний известный уровень доступа бота к группе chatID
+func (v *GroupChatVerifier) BotAccess(chatID int64) (BotGroupAccess, bool) {
+	v.statusMutex.Lock()
+	defer v.statusMutex.Unlock()
+	if v.statusChatID != chatID {
+		return BotGroupAdmin, false
+	}
+	return v.status, true
+}
+
 func (v *GroupChatVerifier) VerifyMembership(ctx context.Context, userID int64) (bool, string, error) {
 	chatID, err := v.settingsRepo.GetRequiredGroupChatID()
 	if err != nil {
@@ -40,11 +136,19 @@ func (v *GroupChatVerifier) VerifyMembership(ctx context.Context, userID int64)
 		return false, "", fmt.Errorf("failed to get invite link: %w", err)
 	}
 
+	access, known := v.BotAccess(chatID)
+	if known && access == BotGroupAbsent {
+		return v.degradedVerification(userID, inviteLink)
+	}
+
 	member, err := v.bot.GetChatMember(ctx, &bot.GetChatMemberParams{
 		ChatID: chatID,
 		UserID: userID,
 	})
 	if err != nil {
+		if known && access != BotGroupAdmin {
+			return v.degradedVerification(userID, inviteLink)
+		}
 		return false, inviteLink, fmt.Errorf("failed to get chat member: %w", err)
 	}
 
@@ -52,6 +156,21 @@ func (v *GroupChatVerifier) VerifyMembership(ctx context.Context, userID int64)
 	return isMember, inviteLink, nil
 }
 
+// degradedVerification решает, пускать ли участника, когда бот не может проверить участие:
+// с настройкой group_check_fail_open участник проходит без проверки, иначе возвращается
+// ErrBotLacksGroupRights
+func (v *GroupChatVerifier) degradedVerification(userID int64, inviteLink string) (bool, string, error) {
+	failOpen, err := v.settingsRepo.GetGroupCheckFailOpen()
+	if err != nil {
+		return false, inviteLink, fmt.Errorf("failed to get fail-open setting: %w", err)
+	}
+	if failOpen {
+		log.Printf("[GROUP_VERIFIER] Bot lacks group rights, letting user %d in without a check", userID)
+		return true, inviteLink, nil
+	}
+	return false, inviteLink, ErrBotLacksGroupRights
+}
+
 func (v *GroupChatVerifier) isValidMemberStatus(status tgmodels.ChatMemberType) bool {
 	switch status {
 	case tgmodels.ChatMemberTypeOwner, tgmodels.ChatMemberTypeAdministrator, tgmodels.ChatMemberTypeMember:
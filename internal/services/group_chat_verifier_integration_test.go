@@ -1,7 +1,14 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync/atomic"
 	"testing"
 
 	"github.com/ad/go-telegram-quest/internal/db"
@@ -308,3 +315,87 @@ func TestGroupChatVerifierIntegration_ValidationScenarios(t *testing.T) {
 		}
 	})
 }
+
+func TestGroupChatVerifier_BotStatusChanges(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	settingsRepo := db.NewSettingsRepository(queue)
+	const groupChatID = int64(-1001234567890)
+	if err := settingsRepo.SetRequiredGroupChatID(groupChatID); err != nil {
+		t.Fatal(err)
+	}
+
+	var memberCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) == "getChatMember" {
+			memberCalls.Add(1)
+		}
+		fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: not enough rights"}`)
+	}))
+	defer server.Close()
+
+	b, err := bot.New("123:test", bot.WithSkipGetMe(), bot.WithServerURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewGroupChatVerifier(b, settingsRepo)
+
+	statusUpdate := func(chatID int64, from, to tgmodels.ChatMemberType) *tgmodels.ChatMemberUpdated {
+		return &tgmodels.ChatMemberUpdated{
+			Chat:          tgmodels.Chat{ID: chatID, Title: "Квестеры"},
+			OldChatMember: tgmodels.ChatMember{Type: from},
+			NewChatMember: tgmodels.ChatMember{Type: to},
+		}
+	}
+
+	change, err := verifier.HandleBotStatusUpdate(statusUpdate(-100999, tgmodels.ChatMemberTypeAdministrator, tgmodels.ChatMemberTypeLeft))
+	if err != nil || change != nil {
+		t.Errorf("Expected updates from other chats to be ignored, got %+v (err %v)", change, err)
+	}
+
+	// Пока статус неизвестен, ошибка проверки остаётся обычной ошибкой
+	if _, _, err := verifier.VerifyMembership(context.Background(), 1); err == nil || errors.Is(err, ErrBotLacksGroupRights) {
+		t.Errorf("Expected a plain API error with unknown bot status, got %v", err)
+	}
+
+	change, err = verifier.HandleBotStatusUpdate(statusUpdate(groupChatID, tgmodels.ChatMemberTypeAdministrator, tgmodels.ChatMemberTypeMember))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change == nil || change.Old != BotGroupAdmin || change.New != BotGroupMember || change.ChatTitle != "Квестеры" {
+		t.Fatalf("Expected admin -> member change, got %+v", change)
+	}
+	if change, _ := verifier.HandleBotStatusUpdate(statusUpdate(groupChatID, tgmodels.ChatMemberTypeMember, tgmodels.ChatMemberTypeRestricted)); change != nil {
+		t.Errorf("Expected no change between member statuses, got %+v", change)
+	}
+
+	if _, _, err := verifier.VerifyMembership(context.Background(), 1); !errors.Is(err, ErrBotLacksGroupRights) {
+		t.Errorf("Expected ErrBotLacksGroupRights without admin rights, got %v", err)
+	}
+	if err := settingsRepo.SetGroupCheckFailOpen(true); err != nil {
+		t.Fatal(err)
+	}
+	if isMember, _, err := verifier.VerifyMembership(context.Background(), 1); err != nil || !isMember {
+		t.Errorf("Expected fail-open to let the user in, got %v (err %v)", isMember, err)
+	}
+
+	if _, err := verifier.HandleBotStatusUpdate(statusUpdate(groupChatID, tgmodels.ChatMemberTypeMember, tgmodels.ChatMemberTypeBanned)); err != nil {
+		t.Fatal(err)
+	}
+	before := memberCalls.Load()
+	if isMember, _, err := verifier.VerifyMembership(context.Background(), 1); err != nil || !isMember {
+		t.Errorf("Expected fail-open to let the user in after removal, got %v (err %v)", isMember, err)
+	}
+	if memberCalls.Load() != before {
+		t.Error("Expected no getChatMember call once the bot is removed from the group")
+	}
+
+	change, err = verifier.HandleBotStatusUpdate(statusUpdate(groupChatID, tgmodels.ChatMemberTypeBanned, tgmodels.ChatMemberTypeAdministrator))
+	if err != nil || change == nil || change.New != BotGroupAdmin {
+		t.Fatalf("Expected the bot to regain admin rights, got %+v (err %v)", change, err)
+	}
+	if _, _, err := verifier.VerifyMembership(context.Background(), 1); err == nil || errors.Is(err, ErrBotLacksGroupRights) {
+		t.Errorf("Expected API errors to surface again with admin rights, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/go-telegram/bot"
+	tgmodels "github.com/go-telegram/bot/models"
+)
+
+// HallOfFameSize — сколько последних финишёров и лидеров по достижениям показывается в «Зале славы»
+const HallOfFameSize = 5
+
+// HallOfFameEntry — участник в «Зале славы»: публичное имя и время прохождения квеста
+// или число достижений
+type HallOfFameEntry struct {
+	Name             string
+	CompletedAt      time.Time
+	AchievementCount int
+}
+
+// HallOfFame — содержимое закреплённого сообщения: последние прошедшие квест (новые сверху)
+// и лидеры по числу достижений
+type HallOfFame struct {
+	TotalCompleters  int
+	RecentCompleters []HallOfFameEntry
+	TopAchievers     []HallOfFameEntry
+}
+
+// FormatHallOfFame форматирует «Зал славы» для закреплённого сообщения в группе. В тексте
+// нет времени обновления, чтобы сообщение редактировалось только при изменении содержимого
+func FormatHallOfFame(fame *HallOfFame, loc *time.Location) string {
+	var sb strings.Builder
+	sb.WriteString("🏛 <b>Зал славы</b>\n\n")
+
+	if len(fame.RecentCompleters) == 0 && len(fame.TopAchievers) == 0 {
+		sb.WriteString("Пока никто не прошёл квест — здесь появятся первые финишёры")
+		return sb.String()
+	}
+
+	if len(fame.RecentCompleters) > 0 {
+		sb.WriteString(fmt.Sprintf("🏁 <b>Прошли квест</b> (всего: %d)\n", fame.TotalCompleters))
+		for _, entry := range fame.RecentCompleters {
+			sb.WriteString(fmt.Sprintf("• %s — %s\n", html.EscapeString(entry.Name), entry.CompletedAt.In(loc).Format("02.01 15:04")))
+		}
+	}
+
+	if len(fame.TopAchievers) > 0 {
+		if len(fame.RecentCompleters) > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("🏆 <b>Больше всего достижений</b>\n")
+		for i, entry := range fame.TopAchievers {
+			medal := fmt.Sprintf("%d.", i+1)
+			switch i {
+			case 0:
+				medal = "🥇"
+			case 1:
+				medal = "🥈"
+			case 2:
+				medal = "🥉"
+			}
+			sb.WriteString(fmt.Sprintf("%s %s — %d\n", medal, html.EscapeString(entry.Name), entry.AchievementCount))
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// HallOfFamePublisher ведёт закреплённое сообщение «Зал славы» в группе, участие в которой
+// обязательно. Сообщение отправляется один раз и дальше редактируется, а правка отправляется,
+// только если содержимое изменилось, — так обновления не упираются в лимиты Telegram
+type HallOfFamePublisher struct {
+	bot               *bot.Bot
+	settingsRepo      *db.SettingsRepository
+	userRepo          *db.UserRepository
+	achievementRepo   *db.AchievementRepository
+	achievementEngine *AchievementEngine
+
+	mu       sync.Mutex
+	lastText string
+}
+
+func NewHallOfFamePublisher(b *bot.Bot, settingsRepo *db.SettingsRepository, userRepo *db.UserRepository, achievementRepo *db.AchievementRepository, achievementEngine *AchievementEngine) *HallOfFamePublisher {
+	return &HallOfFamePublisher{
+		bot:               b,
+		settingsRepo:      settingsRepo,
+		userRepo:          userRepo,
+		achievementRepo:   achievementRepo,
+		achievementEngine: achievementEngine,
+	}
+}
+
+// Build собирает «Зал славы». Участники в режиме «не беспокоить» в него не попадают,
+// но учитываются в общем числе прошедших
+func (p *HallOfFamePublisher) Build() (*HallOfFame, error) {
+	completions, err := p.achievementEngine.GetQuestCompletions()
+	if err != nil {
+		return nil, err
+	}
+
+	fame := &HallOfFame{TotalCompleters: len(completions)}
+	for i := len(completions) - 1; i >= 0 && len(fame.RecentCompleters) < HallOfFameSize; i-- {
+		name, ok := p.publicName(completions[i].UserID)
+		if !ok {
+			continue
+		}
+		fame.RecentCompleters = append(fame.RecentCompleters, HallOfFameEntry{Name: name, CompletedAt: completions[i].CompletionTime})
+	}
+
+	counts, err := p.achievementRepo.GetUsersWithAchievementCount()
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]int64, 0, len(counts))
+	for userID := range counts {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		if counts[userIDs[i]] != counts[userIDs[j]] {
+			return counts[userIDs[i]] > counts[userIDs[j]]
+		}
+		return userIDs[i] < userIDs[j]
+	})
+	for _, userID := range userIDs {
+		if len(fame.TopAchievers) == HallOfFameSize {
+			break
+		}
+		name, ok := p.publicName(userID)
+		if !ok {
+			continue
+		}
+		fame.TopAchievers = append(fame.TopAchievers, HallOfFameEntry{Name: name, AchievementCount: counts[userID]})
+	}
+
+	return fame, nil
+}
+
+func (p *HallOfFamePublisher) publicName(userID int64) (string, bool) {
+	if quiet, err := p.userRepo.IsDoNotDisturb(userID); err != nil || quiet {
+		return "", false
+	}
+	user, err := p.userRepo.GetByID(userID)
+	if err != nil {
+		return "", false
+	}
+	return PublicName(user), true
+}
+
+// Update обновляет закреплённое сообщение. Если «Зал славы» выключен или ограничение участия
+// снято, ранее закреплённое сообщение открепляется
+func (p *HallOfFamePublisher) Update(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	enabled, err := p.settingsRepo.GetHallOfFameEnabled()
+	if err != nil {
+		return err
+	}
+	groupChatID, err := p.settingsRepo.GetRequiredGroupChatID()
+	if err != nil {
+		return err
+	}
+	messageChatID, messageID, err := p.settingsRepo.GetHallOfFameMessage()
+	if err != nil {
+		return err
+	}
+
+	if messageID != 0 && (!enabled || messageChatID != groupChatID) {
+		if _, err := p.bot.UnpinChatMessage(ctx, &bot.UnpinChatMessageParams{ChatID: messageChatID, MessageID: messageID}); err != nil {
+			log.Printf("[HALL_OF_FAME] Failed to unpin message %d in chat %d: %v", messageID, messageChatID, err)
+		}
+		if err := p.settingsRepo.SetHallOfFameMessage(0, 0); err != nil {
+			return err
+		}
+		messageID = 0
+		p.lastText = ""
+	}
+	if !enabled || groupChatID == 0 {
+		return nil
+	}
+
+	fame, err := p.Build()
+	if err != nil {
+		return err
+	}
+	defaultTimezone, _ := p.settingsRepo.GetDefaultTimezone()
+	text := FormatHallOfFame(fame, ResolveLocation(defaultTimezone))
+
+	if messageID != 0 {
+		if text == p.lastText {
+			return nil
+		}
+		_, err := p.bot.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    groupChatID,
+			MessageID: messageID,
+			Text:      text,
+			ParseMode: tgmodels.ParseModeHTML,
+		})
+		if err == nil || strings.Contains(err.Error(), "message is not modified") {
+			p.lastText = text
+			return nil
+		}
+		// Сообщение могли удалить из группы — отправляем и закрепляем новое
+		log.Printf("[HALL_OF_FAME] Failed to edit message %d, sending a new one: %v", messageID, err)
+	}
+
+	sent, err := p.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:              groupChatID,
+		Text:                text,
+		ParseMode:           tgmodels.ParseModeHTML,
+		DisableNotification: true,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := p.bot.PinChatMessage(ctx, &bot.PinChatMessageParams{ChatID: groupChatID, MessageID: sent.ID, DisableNotification: true}); err != nil {
+		log.Printf("[HALL_OF_FAME] Failed to pin message %d in chat %d: %v", sent.ID, groupChatID, err)
+	}
+	p.lastText = text
+	return p.settingsRepo.SetHallOfFameMessage(groupChatID, sent.ID)
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+func TestFormatHallOfFame(t *testing.T) {
+	completedAt := time.Date(2026, 10, 15, 14, 30, 0, 0, time.UTC)
+	fame := &HallOfFame{
+		TotalCompleters:  7,
+		RecentCompleters: []HallOfFameEntry{{Name: "Анна", CompletedAt: completedAt}, {Name: "<Борис>", CompletedAt: completedAt.Add(-time.Hour)}},
+		TopAchievers:     []HallOfFameEntry{{Name: "Вика", AchievementCount: 12}, {Name: "Анна", AchievementCount: 9}, {Name: "Гоша", AchievementCount: 5}, {Name: "Дина", AchievementCount: 2}},
+	}
+
+	text := FormatHallOfFame(fame, time.UTC)
+	for _, want := range []string{
+		"🏛 <b>Зал славы</b>",
+		"🏁 <b>Прошли квест</b> (всего: 7)",
+		"• Анна — 15.10 14:30",
+		"• &lt;Борис&gt; — 15.10 13:30",
+		"🥇 Вика — 12",
+		"🥉 Гоша — 5",
+		"4. Дина — 2",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in hall of fame:\n%s", want, text)
+		}
+	}
+
+	empty := FormatHallOfFame(&HallOfFame{}, time.UTC)
+	if !strings.Contains(empty, "Пока никто не прошёл квест") {
+		t.Errorf("Expected placeholder for empty hall of fame, got %q", empty)
+	}
+}
+
+func TestHallOfFameBuild(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	publisher := NewHallOfFamePublisher(nil, db.NewSettingsRepository(queue), userRepo, achievementRepo, engine)
+
+	step := createTestStep(t, stepRepo, 1)
+	names := map[int64]string{1: "Анна", 2: "Борис", 3: "Вика"}
+	for id, name := range names {
+		if err := userRepo.CreateOrUpdate(&models.User{ID: id, FirstName: name, LastName: "Фамилия"}); err != nil {
+			t.Fatal(err)
+		}
+		completedAt := time.Now().Add(-time.Duration(4-id) * time.Hour)
+		createUserProgress(t, progressRepo, id, step.ID, models.StatusApproved, &completedAt)
+	}
+	if err := userRepo.SetDoNotDisturb(2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	achievements, err := achievementRepo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, userID := range []int64{1, 1, 3, 2, 2, 2} {
+		if err := achievementRepo.AssignToUser(userID, achievements[i].ID, time.Now(), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fame, err := publisher.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fame.TotalCompleters != 3 {
+		t.Errorf("Expected 3 completers in total, got %d", fame.TotalCompleters)
+	}
+	if len(fame.RecentCompleters) != 2 || fame.RecentCompleters[0].Name != "Вика" || fame.RecentCompleters[1].Name != "Анна" {
+		t.Errorf("Expected newest completers first without do-not-disturb users, got %+v", fame.RecentCompleters)
+	}
+	if len(fame.TopAchievers) != 2 || fame.TopAchievers[0].Name != "Анна" || fame.TopAchievers[0].AchievementCount != 2 || fame.TopAchievers[1].Name != "Вика" {
+		t.Errorf("Expected achievers ranked by count without do-not-disturb users, got %+v", fame.TopAchievers)
+	}
+	if strings.Contains(FormatHallOfFame(fame, time.UTC), "Фамилия") {
+		t.Error("Expected only public first names in the hall of fame")
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// aspectRatioTolerance — допустимое относительное отклонение пропорций фото от заданных:
+// Telegram пережимает фото, и стороны могут отличаться на несколько пикселей
+const aspectRatioTolerance = 0.05
+
+// ImageConstraints — ограничения на разрешение фото в ответе на шаг. Нулевое значение
+// стороны или пропорций означает, что ограничения нет
+type ImageConstraints struct {
+	MinWidth    int
+	MinHeight   int
+	MaxWidth    int
+	MaxHeight   int
+	RatioWidth  int
+	RatioHeight int
+}
+
+func (c ImageConstraints) IsEmpty() bool {
+	return c == ImageConstraints{}
+}
+
+// String возвращает ограничения в виде, в котором они хранятся в шаге,
+// например «min=800x600;max=4000x4000;ratio=4:3»
+func (c ImageConstraints) String() string {
+	var parts []string
+	if c.MinWidth > 0 || c.MinHeight > 0 {
+		parts = append(parts, fmt.Sprintf("min=%dx%d", c.MinWidth, c.MinHeight))
+	}
+	if c.MaxWidth > 0 || c.MaxHeight > 0 {
+		parts = append(parts, fmt.Sprintf("max=%dx%d", c.MaxWidth, c.MaxHeight))
+	}
+	if c.RatioWidth > 0 && c.RatioHeight > 0 {
+		parts = append(parts, fmt.Sprintf("ratio=%d:%d", c.RatioWidth, c.RatioHeight))
+	}
+	return strings.Join(parts, ";")
+}
+
+// ParseImageConstraints разбирает ограничения, сохранённые в шаге или введённые
+// администратором: части через запятую, точку с запятой или перевод строки, например
+// «мин 800x600, макс 4000x4000, 4:3». Ноль вместо стороны снимает ограничение по ней
+func ParseImageConstraints(value string) (ImageConstraints, error) {
+	var c ImageConstraints
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n'
+	})
+	for _, part := range parts {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+
+		key, arg := "", part
+		if i := strings.IndexAny(part, " ="); i >= 0 {
+			key, arg = part[:i], strings.TrimSpace(part[i+1:])
+		}
+
+		var err error
+		switch key {
+		case "min", "мин":
+			c.MinWidth, c.MinHeight, err = parseImageSize(arg)
+		case "max", "макс":
+			c.MaxWidth, c.MaxHeight, err = parseImageSize(arg)
+		case "ratio", "пропорции", "":
+			c.RatioWidth, c.RatioHeight, err = parseAspectRatio(arg)
+		default:
+			err = fmt.Errorf("unknown constraint: %s", key)
+		}
+		if err != nil {
+			return ImageConstraints{}, err
+		}
+	}
+
+	if (c.MaxWidth > 0 && c.MinWidth > c.MaxWidth) || (c.MaxHeight > 0 && c.MinHeight > c.MaxHeight) {
+		return ImageConstraints{}, fmt.Errorf("min size exceeds max size: %s", value)
+	}
+	return c, nil
+}
+
+// parseImageSize разбирает размер вида «800x600»; допускаются «x», «х» и «×»
+func parseImageSize(value string) (int, int, error) {
+	value = strings.NewReplacer("х", "x", "×", "x", "*", "x").Replace(value)
+	rawWidth, rawHeight, ok := strings.Cut(value, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid image size: %s", value)
+	}
+	width, err := strconv.Atoi(strings.TrimSpace(rawWidth))
+	if err != nil || width < 0 {
+		return 0, 0, fmt.Errorf("invalid image width: %s", value)
+	}
+	height, err := strconv.Atoi(strings.TrimSpace(rawHeight))
+	if err != nil || height < 0 {
+		return 0, 0, fmt.Errorf("invalid image height: %s", value)
+	}
+	return width, height, nil
+}
+
+// parseAspectRatio разбирает пропорции вида «4:3»
+func parseAspectRatio(value string) (int, int, error) {
+	rawWidth, rawHeight, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid aspect ratio: %s", value)
+	}
+	width, err := strconv.Atoi(strings.TrimSpace(rawWidth))
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid aspect ratio: %s", value)
+	}
+	height, err := strconv.Atoi(strings.TrimSpace(rawHeight))
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid aspect ratio: %s", value)
+	}
+	return width, height, nil
+}
+
+// FormatImageConstraints описывает ограничения для администратора и участника,
+// например «не меньше 800×600, пропорции 4:3»
+func FormatImageConstraints(c ImageConstraints) string {
+	var parts []string
+	if c.MinWidth > 0 || c.MinHeight > 0 {
+		parts = append(parts, "не меньше "+formatImageSize(c.MinWidth, c.MinHeight))
+	}
+	if c.MaxWidth > 0 || c.MaxHeight > 0 {
+		parts = append(parts, "не больше "+formatImageSize(c.MaxWidth, c.MaxHeight))
+	}
+	if c.RatioWidth > 0 && c.RatioHeight > 0 {
+		parts = append(parts, fmt.Sprintf("пропорции %d:%d", c.RatioWidth, c.RatioHeight))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatImageSize выводит размер вида «800×600» или одну сторону, если вторая не ограничена
+func formatImageSize(width, height int) string {
+	switch {
+	case width == 0:
+		return fmt.Sprintf("%d px по высоте", height)
+	case height == 0:
+		return fmt.Sprintf("%d px по ширине", width)
+	}
+	return fmt.Sprintf("%d×%d", width, height)
+}
+
+// Check сообщает, подходит ли фото под ограничения. Если разрешение неизвестно,
+// фото принимается: отклонять ответ из-за недоступных метаданных нельзя
+func (c ImageConstraints) Check(info MediaInfo) bool {
+	if info.Width <= 0 || info.Height <= 0 {
+		return true
+	}
+	if (c.MinWidth > 0 && info.Width < c.MinWidth) || (c.MinHeight > 0 && info.Height < c.MinHeight) {
+		return false
+	}
+	if (c.MaxWidth > 0 && info.Width > c.MaxWidth) || (c.MaxHeight > 0 && info.Height > c.MaxHeight) {
+		return false
+	}
+	if c.RatioWidth > 0 && c.RatioHeight > 0 {
+		expected := float64(c.RatioWidth) / float64(c.RatioHeight)
+		actual := float64(info.Width) / float64(info.Height)
+		if math.Abs(actual-expected)/expected > aspectRatioTolerance {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package services
+
+import "testing"
+
+func TestParseImageConstraints(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ImageConstraints
+	}{
+		{"", ImageConstraints{}},
+		{"мин 800x600, макс 4000х4000, 4:3", ImageConstraints{MinWidth: 800, MinHeight: 600, MaxWidth: 4000, MaxHeight: 4000, RatioWidth: 4, RatioHeight: 3}},
+		{"min=800x600;max=4000x4000;ratio=4:3", ImageConstraints{MinWidth: 800, MinHeight: 600, MaxWidth: 4000, MaxHeight: 4000, RatioWidth: 4, RatioHeight: 3}},
+		{"Мин 1080×0", ImageConstraints{MinWidth: 1080}},
+		{"пропорции 16:9", ImageConstraints{RatioWidth: 16, RatioHeight: 9}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseImageConstraints(tt.input)
+		if err != nil || got != tt.expected {
+			t.Errorf("ParseImageConstraints(%q) = %+v, %v; want %+v", tt.input, got, err, tt.expected)
+		}
+		if reparsed, err := ParseImageConstraints(got.String()); err != nil || reparsed != got {
+			t.Errorf("Expected %q to round-trip, got %+v, %v", got.String(), reparsed, err)
+		}
+	}
+
+	for _, input := range []string{"800x600", "мин 800", "макс 100x100, мин 200x200", "пропорции 0:3", "цвет красный"} {
+		if _, err := ParseImageConstraints(input); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
+
+func TestImageConstraintsCheck(t *testing.T) {
+	constraints := ImageConstraints{MinWidth: 800, MinHeight: 600, MaxWidth: 4000, MaxHeight: 4000, RatioWidth: 4, RatioHeight: 3}
+
+	tests := []struct {
+		name     string
+		info     MediaInfo
+		expected bool
+	}{
+		{"conforming", MediaInfo{Width: 1280, Height: 960}, true},
+		{"ratio within tolerance", MediaInfo{Width: 1280, Height: 940}, true},
+		{"undersized", MediaInfo{Width: 640, Height: 480}, false},
+		{"oversized", MediaInfo{Width: 4800, Height: 3600}, false},
+		{"wrong ratio", MediaInfo{Width: 1920, Height: 1080}, false},
+		{"unknown resolution", MediaInfo{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := constraints.Check(tt.info); got != tt.expected {
+				t.Errorf("Check(%+v) = %v, want %v", tt.info, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatImageConstraints(t *testing.T) {
+	tests := []struct {
+		constraints ImageConstraints
+		expected    string
+	}{
+		{ImageConstraints{}, ""},
+		{ImageConstraints{MinWidth: 800, MinHeight: 600, RatioWidth: 4, RatioHeight: 3}, "не меньше 800×600, пропорции 4:3"},
+		{ImageConstraints{MinWidth: 1080, MaxHeight: 2000}, "не меньше 1080 px по ширине, не больше 2000 px по высоте"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatImageConstraints(tt.constraints); got != tt.expected {
+			t.Errorf("FormatImageConstraints(%+v) = %q, want %q", tt.constraints, got, tt.expected)
+		}
+	}
+}
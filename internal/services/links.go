@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf16"
+
+	tgmodels "github.com/go-telegram/bot/models"
+)
+
+// MessageLinks возвращает ссылки из сущностей сообщения: адреса, которые Telegram распознал
+// в тексте, и скрытые ссылки под текстом. Смещения сущностей считаются в UTF-16
+func MessageLinks(text string, entities []tgmodels.MessageEntity) []string {
+	var encoded []uint16
+	var links []string
+	for _, entity := range entities {
+		switch entity.Type {
+		case tgmodels.MessageEntityTypeURL:
+			if encoded == nil {
+				encoded = utf16.Encode([]rune(text))
+			}
+			if entity.Offset < 0 || entity.Length <= 0 || entity.Offset+entity.Length > len(encoded) {
+				continue
+			}
+			links = append(links, string(utf16.Decode(encoded[entity.Offset:entity.Offset+entity.Length])))
+		case tgmodels.MessageEntityTypeTextLink:
+			if entity.URL != "" {
+				links = append(links, entity.URL)
+			}
+		}
+	}
+	return links
+}
+
+// NormalizeLinkDomain приводит введённый администратором домен к виду «example.com»:
+// убирает схему, «www.», путь и порт
+func NormalizeLinkDomain(value string) (string, error) {
+	host := linkHost(strings.TrimSpace(value))
+	if host == "" || !strings.Contains(host, ".") || strings.ContainsAny(host, " /") {
+		return "", fmt.Errorf("invalid domain: %s", value)
+	}
+	return host, nil
+}
+
+// LinkMatchesDomain сообщает, что ссылка ведёт на домен или его поддомен. Пустой домен
+// принимает любую ссылку
+func LinkMatchesDomain(link, domain string) bool {
+	host := linkHost(link)
+	if host == "" {
+		return false
+	}
+	if domain == "" {
+		return true
+	}
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+	tgmodels "github.com/go-telegram/bot/models"
+)
+
+func TestMessageLinks(t *testing.T) {
+	// Смещения в UTF-16: эмодзи занимает две единицы
+	text := "🔗 Вот: github.com/ad/quest и ещё"
+	entities := []tgmodels.MessageEntity{
+		{Type: tgmodels.MessageEntityTypeBold, Offset: 0, Length: 2},
+		{Type: tgmodels.MessageEntityTypeURL, Offset: 8, Length: 19},
+		{Type: tgmodels.MessageEntityTypeTextLink, Offset: 30, Length: 3, URL: "https://example.com/page"},
+	}
+
+	got := MessageLinks(text, entities)
+	want := []string{"github.com/ad/quest", "https://example.com/page"}
+	if !slices.Equal(got, want) {
+		t.Errorf("MessageLinks() = %v, want %v", got, want)
+	}
+
+	if links := MessageLinks("github.com", []tgmodels.MessageEntity{{Type: tgmodels.MessageEntityTypeURL, Offset: 5, Length: 20}}); len(links) != 0 {
+		t.Errorf("Expected out-of-range entity to be ignored, got %v", links)
+	}
+}
+
+func TestNormalizeLinkDomain(t *testing.T) {
+	for input, want := range map[string]string{
+		"github.com":                  "github.com",
+		" https://www.GitHub.com/ad ": "github.com",
+		"docs.google.com:443":         "docs.google.com",
+	} {
+		got, err := NormalizeLinkDomain(input)
+		if err != nil || got != want {
+			t.Errorf("NormalizeLinkDomain(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"", "localhost", "два слова.ru"} {
+		if _, err := NormalizeLinkDomain(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestLinkMatchesDomain(t *testing.T) {
+	tests := []struct {
+		link   string
+		domain string
+		want   bool
+	}{
+		{"https://github.com/ad/quest", "github.com", true},
+		{"gist.github.com/ad", "github.com", true},
+		{"https://www.github.com", "github.com", true},
+		{"https://notgithub.com", "github.com", false},
+		{"https://github.com.evil.ru", "github.com", false},
+		{"https://example.com", "", true},
+	}
+
+	for _, tt := range tests {
+		if got := LinkMatchesDomain(tt.link, tt.domain); got != tt.want {
+			t.Errorf("LinkMatchesDomain(%q, %q) = %v, want %v", tt.link, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestCheckLinkAnswer(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	checker := NewAnswerChecker(db.NewAnswerRepository(queue), db.NewProgressRepository(queue), db.NewUserRepository(queue), db.NewSettingsRepository(queue))
+
+	step := createTestStepWithType(t, stepRepo, 1, models.AnswerTypeLink)
+	if err := stepRepo.UpdateLinkDomain(step.ID, "github.com"); err != nil {
+		t.Fatal(err)
+	}
+	step, err := stepRepo.GetByID(step.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := "Мой репозиторий: https://github.com/ad/quest"
+	links := MessageLinks(text, []tgmodels.MessageEntity{{Type: tgmodels.MessageEntityTypeURL, Offset: 17, Length: 27}})
+	result, err := checker.CheckLinkAnswer(step, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsCorrect {
+		t.Errorf("Expected message with matching URL entity to be accepted, links %v", links)
+	}
+
+	result, err = checker.CheckLinkAnswer(step, MessageLinks("github.com/ad/quest без разметки", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected plain-text message without URL entity to be rejected")
+	}
+
+	result, err = checker.CheckLinkAnswer(step, []string{"https://gitlab.com/ad/quest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsCorrect {
+		t.Error("Expected link to another domain to be rejected")
+	}
+}
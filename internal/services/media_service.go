@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/models"
+	"github.com/go-telegram/bot"
+	tgmodels "github.com/go-telegram/bot/models"
+)
+
+// OversizedMediaBytes — размер файла, начиная с которого медиа шага помечается как слишком
+// большое: такие изображения долго загружаются у участников с медленным интернетом
+const OversizedMediaBytes = 1 << 20
+
+// MediaInfo описывает разрешение и размер файла медиа шага. Нулевые значения означают,
+// что сведения неизвестны
+type MediaInfo struct {
+	Width    int
+	Height   int
+	FileSize int64
+}
+
+// IsOversized сообщает, что файл больше OversizedMediaBytes
+func (i MediaInfo) IsOversized() bool {
+	return i.FileSize >= OversizedMediaBytes
+}
+
+// MediaService получает сведения о медиа, которые администратор загружает в шаги
+type MediaService struct {
+	bot *bot.Bot
+}
+
+func NewMediaService(b *bot.Bot) *MediaService {
+	return &MediaService{bot: b}
+}
+
+// FileSize возвращает размер файла по его file_id через getFile
+func (s *MediaService) FileSize(ctx context.Context, fileID string) (int64, error) {
+	file, err := s.bot.GetFile(ctx, &bot.GetFileParams{FileID: fileID})
+	if err != nil {
+		return 0, err
+	}
+	return file.FileSize, nil
+}
+
+// Download скачивает файл по его file_id, например документ, который прислал администратор
+func (s *MediaService) Download(ctx context.Context, fileID string) ([]byte, error) {
+	file, err := s.bot.GetFile(ctx, &bot.GetFileParams{FileID: fileID})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.bot.FileDownloadLink(file), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// InfoFromMessage возвращает сведения о фотографии или анимации из сообщения. Разрешение
+// берётся из сообщения, а размер, если Telegram его не передал, запрашивается через getFile
+func (s *MediaService) InfoFromMessage(ctx context.Context, msg *tgmodels.Message) MediaInfo {
+	var info MediaInfo
+	var fileID string
+	switch {
+	case msg.Animation != nil:
+		fileID = msg.Animation.FileID
+		info = MediaInfo{Width: msg.Animation.Width, Height: msg.Animation.Height, FileSize: msg.Animation.FileSize}
+	case len(msg.Photo) > 0:
+		photo := msg.Photo[len(msg.Photo)-1]
+		fileID = photo.FileID
+		info = MediaInfo{Width: photo.Width, Height: photo.Height, FileSize: int64(photo.FileSize)}
+	default:
+		return info
+	}
+
+	if info.FileSize == 0 {
+		info.FileSize, _ = s.FileSize(ctx, fileID)
+	}
+	return info
+}
+
+// MaxMediaGroupSize — наибольшее число фото в одной медиагруппе Telegram
+const MaxMediaGroupSize = 10
+
+// AnswerImagesForStep собирает фото из ответов участника на шаг в порядке отправки
+func AnswerImagesForStep(answers []*models.UserAnswer, stepID int64) []models.AnswerImage {
+	var images []models.AnswerImage
+	for _, answer := range answers {
+		if answer.StepID == stepID {
+			images = append(images, answer.Images...)
+		}
+	}
+	return images
+}
+
+// ResolveAnswerImages оставляет фото ответов, которые Telegram ещё отдаёт по file_id,
+// и возвращает число недоступных
+func (s *MediaService) ResolveAnswerImages(ctx context.Context, images []models.AnswerImage) ([]models.AnswerImage, int) {
+	if s.bot == nil {
+		return images, 0
+	}
+
+	var available []models.AnswerImage
+	for _, img := range images {
+		if _, err := s.bot.GetFile(ctx, &bot.GetFileParams{FileID: img.FileID}); err != nil {
+			continue
+		}
+		available = append(available, img)
+	}
+	return available, len(images) - len(available)
+}
+
+// BuildAnswerAlbums раскладывает фото ответов по медиагруппам не больше MaxMediaGroupSize.
+// Медиагруппа в Telegram не бывает из одного фото, поэтому одно фото из хвоста переносится
+// из предыдущей группы; группа из одного фото остаётся, только если фото всего одно.
+// Подпись ставится на первое фото первой группы
+func BuildAnswerAlbums(images []models.AnswerImage, caption string) [][]tgmodels.InputMedia {
+	var albums [][]tgmodels.InputMedia
+	for start := 0; start < len(images); {
+		end := min(start+MaxMediaGroupSize, len(images))
+		if len(images)-end == 1 {
+			end--
+		}
+		album := make([]tgmodels.InputMedia, 0, end-start)
+		for i, img := range images[start:end] {
+			photo := &tgmodels.InputMediaPhoto{Media: img.FileID}
+			if start == 0 && i == 0 {
+				photo.Caption = caption
+			}
+			album = append(album, photo)
+		}
+		albums = append(albums, album)
+		start = end
+	}
+	return albums
+}
+
+// FormatMediaInfo возвращает разрешение и примерный размер файла для меню изображений,
+// например «1280×960, ~245 КБ», с предупреждением для слишком больших файлов
+func FormatMediaInfo(info MediaInfo) string {
+	var result string
+	if info.Width > 0 && info.Height > 0 {
+		result = fmt.Sprintf("%d×%d", info.Width, info.Height)
+	}
+	if info.FileSize > 0 {
+		if result != "" {
+			result += ", "
+		}
+		result += "~" + formatFileSize(info.FileSize)
+	}
+	if info.IsOversized() {
+		result += " ⚠️ большой файл"
+	}
+	return result
+}
+
+// formatFileSize округляет размер до килобайт, а от мегабайта — до десятых мегабайта
+func formatFileSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f МБ", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%d КБ", (size+1<<9)>>10)
+	default:
+		return fmt.Sprintf("%d Б", size)
+	}
+}
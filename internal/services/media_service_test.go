@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+	tgmodels "github.com/go-telegram/bot/models"
+)
+
+func TestFormatMediaInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     MediaInfo
+		expected string
+	}{
+		{"unknown", MediaInfo{}, ""},
+		{"resolution and size", MediaInfo{Width: 1280, Height: 960, FileSize: 250_000}, "1280×960, ~244 КБ"},
+		{"size only", MediaInfo{FileSize: 800}, "~800 Б"},
+		{"resolution only", MediaInfo{Width: 640, Height: 480}, "640×480"},
+		{"oversized", MediaInfo{Width: 4000, Height: 3000, FileSize: 3 << 20}, "4000×3000, ~3.0 МБ ⚠️ большой файл"},
+		{"just below limit", MediaInfo{Width: 1920, Height: 1080, FileSize: OversizedMediaBytes - 1}, "1920×1080, ~1024 КБ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatMediaInfo(tt.info); got != tt.expected {
+				t.Errorf("FormatMediaInfo(%+v) = %q, want %q", tt.info, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMediaInfoFromMessage(t *testing.T) {
+	service := NewMediaService(nil)
+
+	photo := &tgmodels.Message{Photo: []tgmodels.PhotoSize{
+		{FileID: "small", Width: 90, Height: 60, FileSize: 1_000},
+		{FileID: "large", Width: 1280, Height: 853, FileSize: 120_000},
+	}}
+	if got := service.InfoFromMessage(context.Background(), photo); got != (MediaInfo{Width: 1280, Height: 853, FileSize: 120_000}) {
+		t.Errorf("Expected info of the largest photo size, got %+v", got)
+	}
+
+	animation := &tgmodels.Message{Animation: &tgmodels.Animation{FileID: "gif", Width: 480, Height: 270, FileSize: 2 << 20}}
+	got := service.InfoFromMessage(context.Background(), animation)
+	if got != (MediaInfo{Width: 480, Height: 270, FileSize: 2 << 20}) || !got.IsOversized() {
+		t.Errorf("Expected oversized animation info, got %+v", got)
+	}
+
+	if got := service.InfoFromMessage(context.Background(), &tgmodels.Message{Text: "текст"}); got != (MediaInfo{}) {
+		t.Errorf("Expected empty info for a message without media, got %+v", got)
+	}
+}
+
+func TestBuildAnswerAlbums_FromStoredAnswers(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+
+	createTestUserForEngine(t, userRepo, 1)
+	photoStep := createTestStep(t, stepRepo, 1)
+	otherStep := createTestStep(t, stepRepo, 2)
+
+	var retry []string
+	for i := 0; i < 10; i++ {
+		retry = append(retry, fmt.Sprintf("retry-%d", i))
+	}
+	for _, answer := range []struct {
+		stepID  int64
+		fileIDs []string
+	}{
+		{photoStep.ID, []string{"first-0", "first-1"}},
+		{otherStep.ID, []string{"other-0"}},
+		{photoStep.ID, retry},
+	} {
+		if _, err := answerRepo.CreateImageAnswer(1, answer.stepID, answer.fileIDs, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := answerRepo.CreateTextAnswer(1, photoStep.ID, "текст", false); err != nil {
+		t.Fatal(err)
+	}
+
+	answers, err := answerRepo.GetUserAnswerHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := AnswerImagesForStep(answers, photoStep.ID)
+	if len(images) != 12 {
+		t.Fatalf("Expected 12 images for the step, got %d", len(images))
+	}
+
+	albums := BuildAnswerAlbums(images, "Фото ответов")
+	if len(albums) != 2 || len(albums[0]) != MaxMediaGroupSize || len(albums[1]) != 2 {
+		t.Fatalf("Expected albums of 10 and 2 photos, got %d albums", len(albums))
+	}
+
+	var fileIDs []string
+	for i, album := range albums {
+		for j, media := range album {
+			photo, ok := media.(*tgmodels.InputMediaPhoto)
+			if !ok {
+				t.Fatalf("Expected photo in album %d, got %T", i, media)
+			}
+			fileIDs = append(fileIDs, photo.Media)
+			if wantCaption := i == 0 && j == 0; (photo.Caption != "") != wantCaption {
+				t.Errorf("Unexpected caption %q at album %d position %d", photo.Caption, i, j)
+			}
+		}
+	}
+	if fileIDs[0] != "first-0" || fileIDs[1] != "first-1" || fileIDs[2] != "retry-0" || fileIDs[11] != "retry-9" {
+		t.Errorf("Expected photos in submission order, got %v", fileIDs)
+	}
+}
+
+func TestBuildAnswerAlbums_NoSinglePhotoGroups(t *testing.T) {
+	tests := []struct {
+		count int
+		sizes []int
+	}{
+		{1, []int{1}},
+		{2, []int{2}},
+		{10, []int{10}},
+		{11, []int{9, 2}},
+		{12, []int{10, 2}},
+		{21, []int{10, 9, 2}},
+	}
+
+	for _, tt := range tests {
+		var images []models.AnswerImage
+		for i := 0; i < tt.count; i++ {
+			images = append(images, models.AnswerImage{FileID: fmt.Sprintf("photo-%d", i)})
+		}
+
+		albums := BuildAnswerAlbums(images, "Фото ответов")
+		var sizes []int
+		for _, album := range albums {
+			sizes = append(sizes, len(album))
+		}
+		if !slices.Equal(sizes, tt.sizes) {
+			t.Errorf("%d photos: expected albums of %v, got %v", tt.count, tt.sizes, sizes)
+		}
+	}
+}
+
+func TestBuildAnswerAlbums_Empty(t *testing.T) {
+	if albums := BuildAnswerAlbums(nil, "Фото ответов"); len(albums) != 0 {
+		t.Errorf("Expected no albums without photos, got %d", len(albums))
+	}
+
+	service := NewMediaService(nil)
+	if images, missing := service.ResolveAnswerImages(context.Background(), nil); len(images) != 0 || missing != 0 {
+		t.Errorf("Expected nothing to resolve, got %d images and %d missing", len(images), missing)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"unicode/utf16"
 
 	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/models"
@@ -13,20 +14,164 @@ import (
 	tgmodels "github.com/go-telegram/bot/models"
 )
 
+// MaxMessageLength — предел длины текста сообщения в Telegram (в единицах UTF-16)
+const MaxMessageLength = 4096
+
+// DuplicatePolicy определяет, что делать, если бот собирается отправить в личный чат
+// тот же текст, что и его последнее сообщение там
+type DuplicatePolicy string
+
+const (
+	// DuplicatePolicySend отправляет повтор как обычно
+	DuplicatePolicySend DuplicatePolicy = "send"
+	// DuplicatePolicySkip не отправляет повтор: в чате остаётся прежнее сообщение
+	DuplicatePolicySkip DuplicatePolicy = "skip"
+	// DuplicatePolicyEdit не отправляет повтор, а редактирует прежнее сообщение
+	DuplicatePolicyEdit DuplicatePolicy = "edit"
+)
+
+// ParseDuplicatePolicy разбирает политику повторов; пустое значение — отправлять как обычно
+func ParseDuplicatePolicy(value string) (DuplicatePolicy, error) {
+	switch DuplicatePolicy(strings.ToLower(strings.TrimSpace(value))) {
+	case "", DuplicatePolicySend:
+		return DuplicatePolicySend, nil
+	case DuplicatePolicySkip:
+		return DuplicatePolicySkip, nil
+	case DuplicatePolicyEdit:
+		return DuplicatePolicyEdit, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate message policy %q", value)
+	}
+}
+
 type MessageManager struct {
-	bot           *bot.Bot
-	chatStateRepo *db.ChatStateRepository
-	errMgr        *ErrorManager
-	maxRetry      int
+	bot              *bot.Bot
+	chatStateRepo    *db.ChatStateRepository
+	errMgr           *ErrorManager
+	maxRetry         int
+	maxMessageLength int
+	duplicatePolicy  DuplicatePolicy
 }
 
 func NewMessageManager(b *bot.Bot, chatStateRepo *db.ChatStateRepository, errMgr *ErrorManager) *MessageManager {
 	return &MessageManager{
-		bot:           b,
-		chatStateRepo: chatStateRepo,
-		errMgr:        errMgr,
-		maxRetry:      2,
+		bot:              b,
+		chatStateRepo:    chatStateRepo,
+		errMgr:           errMgr,
+		maxRetry:         2,
+		maxMessageLength: MaxMessageLength,
+		duplicatePolicy:  DuplicatePolicySend,
+	}
+}
+
+// SetDuplicatePolicy задаёт, что делать с текстовым сообщением без клавиатуры, которое
+// повторяет последнее сообщение бота в личном чате
+func (m *MessageManager) SetDuplicatePolicy(policy DuplicatePolicy) {
+	m.duplicatePolicy = policy
+}
+
+// SetMaxMessageLength задаёт длину, на части которой SendLong делит текст. Значения вне
+// (0, MaxMessageLength] заменяются пределом Telegram
+func (m *MessageManager) SetMaxMessageLength(length int) {
+	if length <= 0 || length > MaxMessageLength {
+		length = MaxMessageLength
+	}
+	m.maxMessageLength = length
+}
+
+// MaxMessageLength возвращает длину, на части которой SendLong делит текст
+func (m *MessageManager) MaxMessageLength() int {
+	return m.maxMessageLength
+}
+
+// SendLong отправляет текст, который может не поместиться в одно сообщение: он делится
+// на части по границам строк, а клавиатура прикрепляется только к последней части
+func (m *MessageManager) SendLong(ctx context.Context, chatID int64, text string, keyboard *tgmodels.InlineKeyboardMarkup) error {
+	for _, params := range m.longMessageParams(chatID, text, keyboard) {
+		if _, err := m.SendWithRetry(ctx, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MessageManager) longMessageParams(chatID int64, text string, keyboard *tgmodels.InlineKeyboardMarkup) []*bot.SendMessageParams {
+	chunks := SplitMessage(text, m.maxMessageLength)
+	params := make([]*bot.SendMessageParams, 0, len(chunks))
+	for i, chunk := range chunks {
+		p := &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      chunk,
+			ParseMode: tgmodels.ParseModeHTML,
+		}
+		if i == len(chunks)-1 && keyboard != nil {
+			p.ReplyMarkup = keyboard
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// MessageLength возвращает длину текста так, как её считает Telegram, — в единицах UTF-16
+func MessageLength(text string) int {
+	length := 0
+	for _, r := range text {
+		length += utf16.RuneLen(r)
 	}
+	return length
+}
+
+// SplitMessage делит текст на части не длиннее limit, разрывая его по переводам строк.
+// Строка длиннее limit разрезается посимвольно
+func SplitMessage(text string, limit int) []string {
+	if limit <= 0 {
+		limit = MaxMessageLength
+	}
+	if MessageLength(text) <= limit {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	currentLength := 0
+
+	flush := func() {
+		if chunk := strings.TrimRight(current.String(), "\n"); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentLength = 0
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineLength := MessageLength(line)
+		// Завершающий перевод строки части отбрасывается, поэтому в предел не входит
+		if currentLength+MessageLength(strings.TrimSuffix(line, "\n")) > limit {
+			flush()
+		}
+		for MessageLength(strings.TrimSuffix(line, "\n")) > limit {
+			head, rest := splitAtLength(line, limit)
+			chunks = append(chunks, head)
+			line, lineLength = rest, MessageLength(rest)
+		}
+		current.WriteString(line)
+		currentLength += lineLength
+	}
+	flush()
+
+	return chunks
+}
+
+// splitAtLength отделяет от строки начало длиной не больше limit единиц UTF-16
+func splitAtLength(text string, limit int) (string, string) {
+	length := 0
+	for i, r := range text {
+		if length+utf16.RuneLen(r) > limit {
+			return text[:i], text[i:]
+		}
+		length += utf16.RuneLen(r)
+	}
+	return text, ""
 }
 
 func (m *MessageManager) SendWithRetry(ctx context.Context, params *bot.SendMessageParams) (*tgmodels.Message, error) {
@@ -34,11 +179,23 @@ func (m *MessageManager) SendWithRetry(ctx context.Context, params *bot.SendMess
 		params.ParseMode = tgmodels.ParseModeHTML
 	}
 
+	chatID, tracked := m.duplicateTrackedChat(params)
+	if tracked {
+		if msg := m.resolveDuplicate(ctx, chatID, params); msg != nil {
+			return msg, nil
+		}
+	}
+
 	var lastErr error
 
 	for attempt := 0; attempt < m.maxRetry; attempt++ {
 		msg, err := m.bot.SendMessage(ctx, params)
 		if err == nil {
+			if tracked {
+				m.chatStateRepo.UpdateLastBotMessage(chatID, msg.ID, params.Text)
+			} else {
+				m.forgetLastMessage(params.ChatID)
+			}
 			return msg, nil
 		}
 		lastErr = err
@@ -49,6 +206,64 @@ func (m *MessageManager) SendWithRetry(ctx context.Context, params *bot.SendMess
 	return nil, lastErr
 }
 
+// tracksDuplicates сообщает, нужно ли запоминать последнее сообщение бота в чате
+func (m *MessageManager) tracksDuplicates() bool {
+	return m.chatStateRepo != nil && m.duplicatePolicy != "" && m.duplicatePolicy != DuplicatePolicySend
+}
+
+// duplicateTrackedChat сообщает, отслеживаются ли повторы для сообщения: только в личных
+// чатах и только для текста без клавиатуры, чтобы не потерять кнопки нового сообщения
+func (m *MessageManager) duplicateTrackedChat(params *bot.SendMessageParams) (int64, bool) {
+	if !m.tracksDuplicates() || params.ReplyMarkup != nil {
+		return 0, false
+	}
+	chatID, ok := params.ChatID.(int64)
+	return chatID, ok && chatID > 0
+}
+
+// resolveDuplicate применяет политику повторов. Возвращает прежнее сообщение, если новое
+// отправлять не нужно, или nil, если сообщение не повтор или прежнее не удалось отредактировать
+func (m *MessageManager) resolveDuplicate(ctx context.Context, chatID int64, params *bot.SendMessageParams) *tgmodels.Message {
+	state, err := m.chatStateRepo.Get(chatID)
+	if err != nil || state == nil || state.LastBotMessageID == 0 || state.LastBotMessageText != params.Text {
+		return nil
+	}
+
+	previous := &tgmodels.Message{ID: state.LastBotMessageID, Chat: tgmodels.Chat{ID: chatID}, Text: params.Text}
+	if m.duplicatePolicy != DuplicatePolicyEdit {
+		return previous
+	}
+
+	edited, err := m.bot.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:             chatID,
+		MessageID:          state.LastBotMessageID,
+		Text:               params.Text,
+		ParseMode:          params.ParseMode,
+		LinkPreviewOptions: params.LinkPreviewOptions,
+	})
+	switch {
+	case err == nil && edited != nil:
+		return edited
+	case err != nil && strings.Contains(err.Error(), "message is not modified"):
+		return previous
+	default:
+		// Прежнее сообщение удалено или слишком старое — отправляем новое
+		log.Printf("[MESSAGE_MANAGER] Failed to edit duplicate message %d in chat %d: %v", state.LastBotMessageID, chatID, err)
+		return nil
+	}
+}
+
+// forgetLastMessage сбрасывает последнее текстовое сообщение бота после отправки
+// чего-то другого: повтор того же текста после него уже не идёт подряд
+func (m *MessageManager) forgetLastMessage(chatID interface{}) {
+	if !m.tracksDuplicates() {
+		return
+	}
+	if id, ok := chatID.(int64); ok && id > 0 {
+		m.chatStateRepo.UpdateLastBotMessage(id, 0, "")
+	}
+}
+
 func (m *MessageManager) SendWithRetryAndEffect(ctx context.Context, params *bot.SendMessageParams, effectID string) (*tgmodels.Message, error) {
 	if effectID != "" {
 		params.MessageEffectID = effectID
@@ -66,6 +281,7 @@ func (m *MessageManager) SendPhotoWithRetry(ctx context.Context, params *bot.Sen
 	for attempt := 0; attempt < m.maxRetry; attempt++ {
 		msg, err := m.bot.SendPhoto(ctx, params)
 		if err == nil {
+			m.forgetLastMessage(params.ChatID)
 			return msg, nil
 		}
 		lastErr = err
@@ -78,6 +294,21 @@ func (m *MessageManager) SendPhotoWithRetry(ctx context.Context, params *bot.Sen
 	return nil, lastErr
 }
 
+// TrySendPhoto отправляет фото одной попыткой и без уведомления админа — для мест, где
+// у вызывающего есть свой запасной вариант (например, отправить текст без картинки)
+func (m *MessageManager) TrySendPhoto(ctx context.Context, params *bot.SendPhotoParams) (*tgmodels.Message, error) {
+	if params.ParseMode == "" && params.Caption != "" {
+		params.ParseMode = tgmodels.ParseModeHTML
+	}
+
+	msg, err := m.bot.SendPhoto(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	m.forgetLastMessage(params.ChatID)
+	return msg, nil
+}
+
 func (m *MessageManager) SendMediaGroupWithRetry(ctx context.Context, params *bot.SendMediaGroupParams) ([]*tgmodels.Message, error) {
 	for i, media := range params.Media {
 		if photo, ok := media.(*tgmodels.InputMediaPhoto); ok && photo.Caption != "" && photo.ParseMode == "" {
@@ -91,6 +322,7 @@ func (m *MessageManager) SendMediaGroupWithRetry(ctx context.Context, params *bo
 	for attempt := 0; attempt < m.maxRetry; attempt++ {
 		msgs, err := m.bot.SendMediaGroup(ctx, params)
 		if err == nil {
+			m.forgetLastMessage(params.ChatID)
 			return msgs, nil
 		}
 		lastErr = err
@@ -103,6 +335,98 @@ func (m *MessageManager) SendMediaGroupWithRetry(ctx context.Context, params *bo
 	return nil, lastErr
 }
 
+func (m *MessageManager) SendDocumentWithRetry(ctx context.Context, params *bot.SendDocumentParams) (*tgmodels.Message, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < m.maxRetry; attempt++ {
+		msg, err := m.bot.SendDocument(ctx, params)
+		if err == nil {
+			m.forgetLastMessage(params.ChatID)
+			return msg, nil
+		}
+		lastErr = err
+	}
+
+	chatID, _ := params.ChatID.(int64)
+
+	m.errMgr.NotifyAdminWithCurl(ctx, chatID, params, lastErr)
+
+	return nil, lastErr
+}
+
+func (m *MessageManager) SendAnimationWithRetry(ctx context.Context, params *bot.SendAnimationParams) (*tgmodels.Message, error) {
+	if params.ParseMode == "" && params.Caption != "" {
+		params.ParseMode = tgmodels.ParseModeHTML
+	}
+
+	var lastErr error
+
+	for attempt := 0; attempt < m.maxRetry; attempt++ {
+		msg, err := m.bot.SendAnimation(ctx, params)
+		if err == nil {
+			m.forgetLastMessage(params.ChatID)
+			return msg, nil
+		}
+		lastErr = err
+	}
+
+	chatID, _ := params.ChatID.(int64)
+
+	m.errMgr.NotifyAdminWithCurl(ctx, chatID, params, lastErr)
+
+	return nil, lastErr
+}
+
+// StepMediaPlan описывает отправку медиа задания. Telegram не принимает GIF в альбомах,
+// поэтому анимации рядом с фотографиями уходят отдельными сообщениями после задания
+type StepMediaPlan struct {
+	// Captioned — единственное медиа, к которому подписывается текст задания
+	Captioned *models.StepImage
+	// Album — фотографии, отправляемые альбомом, если их больше одной
+	Album []models.StepImage
+	// Extra — анимации, отправляемые после задания без подписи
+	Extra []models.StepImage
+}
+
+// PlanStepMedia выбирает способ отправки медиа шага: текст без медиа, одиночное фото
+// или анимация с подписью, альбом фотографий
+func PlanStepMedia(images []models.StepImage) StepMediaPlan {
+	var photos, animations []models.StepImage
+	for _, img := range images {
+		if img.IsAnimation() {
+			animations = append(animations, img)
+		} else {
+			photos = append(photos, img)
+		}
+	}
+
+	var plan StepMediaPlan
+	switch {
+	case len(photos) > 1:
+		plan.Album = photos
+		plan.Extra = animations
+	case len(photos) == 1:
+		plan.Captioned = &photos[0]
+		plan.Extra = animations
+	case len(animations) > 0:
+		plan.Captioned = &animations[0]
+		plan.Extra = animations[1:]
+	}
+	return plan
+}
+
+// BuildStepDocuments возвращает параметры отправки файлов, прикреплённых к шагу
+func BuildStepDocuments(userID int64, step *models.Step) []*bot.SendDocumentParams {
+	var params []*bot.SendDocumentParams
+	for _, document := range step.Documents {
+		params = append(params, &bot.SendDocumentParams{
+			ChatID:   userID,
+			Document: &tgmodels.InputFileString{Data: document.FileID},
+		})
+	}
+	return params
+}
+
 func (m *MessageManager) SendTask(ctx context.Context, userID int64, step *models.Step) error {
 	return m.SendTaskWithButtons(ctx, userID, step, false, false)
 }
@@ -129,9 +453,18 @@ func (m *MessageManager) SendTaskWithButtons(ctx context.Context, userID int64,
 	}
 
 	var keyboard *tgmodels.InlineKeyboardMarkup
-	if showHintButton && step.HasHint() || showSkipButton {
+	if showHintButton && step.HasHint() || showSkipButton || len(step.Choices) > 0 {
 		var buttons [][]tgmodels.InlineKeyboardButton
 
+		for _, choice := range step.Choices {
+			buttons = append(buttons, []tgmodels.InlineKeyboardButton{
+				{
+					Text:         choice.Text,
+					CallbackData: fmt.Sprintf("choice:%d:%d", step.ID, choice.ID),
+				},
+			})
+		}
+
 		if showHintButton && step.HasHint() {
 			buttons = append(buttons, []tgmodels.InlineKeyboardButton{
 				{
@@ -158,8 +491,9 @@ func (m *MessageManager) SendTaskWithButtons(ctx context.Context, userID int64,
 	}
 
 	var taskMsgID int
+	plan := PlanStepMedia(step.Images)
 
-	if len(step.Images) == 0 {
+	if plan.Captioned == nil && len(plan.Album) == 0 {
 		params := &bot.SendMessageParams{
 			ChatID: userID,
 			Text:   stepText + starQuestion,
@@ -172,10 +506,35 @@ func (m *MessageManager) SendTaskWithButtons(ctx context.Context, userID int64,
 			return err
 		}
 		taskMsgID = msg.ID
-	} else if len(step.Images) == 1 {
+	} else if plan.Captioned != nil && plan.Captioned.IsAnimation() {
+		params := &bot.SendAnimationParams{
+			ChatID:    userID,
+			Animation: &tgmodels.InputFileString{Data: plan.Captioned.FileID},
+			Caption:   stepText + starQuestion,
+		}
+		if keyboard != nil {
+			params.ReplyMarkup = keyboard
+		}
+		msg, err := m.SendAnimationWithRetry(ctx, params)
+		if err != nil {
+			log.Printf("[MESSAGE_MANAGER] Failed to send animation for step %d to user %d: %v, sending text instead", step.ID, userID, err)
+			textParams := &bot.SendMessageParams{
+				ChatID: userID,
+				Text:   stepText + starQuestion,
+			}
+			if keyboard != nil {
+				textParams.ReplyMarkup = keyboard
+			}
+			msg, err = m.SendWithRetry(ctx, textParams)
+			if err != nil {
+				return err
+			}
+		}
+		taskMsgID = msg.ID
+	} else if plan.Captioned != nil {
 		params := &bot.SendPhotoParams{
 			ChatID:  userID,
-			Photo:   &tgmodels.InputFileString{Data: step.Images[0].FileID},
+			Photo:   &tgmodels.InputFileString{Data: plan.Captioned.FileID},
 			Caption: stepText + starQuestion,
 		}
 		if keyboard != nil {
@@ -199,8 +558,8 @@ func (m *MessageManager) SendTaskWithButtons(ctx context.Context, userID int64,
 		}
 		taskMsgID = msg.ID
 	} else {
-		media := make([]tgmodels.InputMedia, len(step.Images))
-		for i, img := range step.Images {
+		media := make([]tgmodels.InputMedia, len(plan.Album))
+		for i, img := range plan.Album {
 			photo := &tgmodels.InputMediaPhoto{
 				Media: img.FileID,
 			}
@@ -244,7 +603,60 @@ func (m *MessageManager) SendTaskWithButtons(ctx context.Context, userID int64,
 		}
 	}
 
-	return m.chatStateRepo.UpdateTaskMessageID(userID, taskMsgID)
+	// Задание уже доставлено: ошибка сохранения не должна приводить к его повторной отправке
+	if err := m.chatStateRepo.UpdateTaskMessageID(userID, taskMsgID); err != nil {
+		log.Printf("[MESSAGE_MANAGER] Failed to save task message for step %d of user %d: %v", step.ID, userID, err)
+	}
+
+	return m.SendStepAttachments(ctx, userID, step, 0)
+}
+
+// StepAttachmentsError сообщает, что задание доставлено, а его вложения начиная с номера
+// From — нет. Повторять нужно только их, иначе задание и первые вложения придут дважды
+type StepAttachmentsError struct {
+	From int
+	Err  error
+}
+
+func (e *StepAttachmentsError) Error() string {
+	return fmt.Sprintf("step attachments from %d not delivered: %v", e.From, e.Err)
+}
+
+func (e *StepAttachmentsError) Unwrap() error {
+	return e.Err
+}
+
+// SendStepAttachments отправляет вложения шага после задания — дополнительные анимации
+// и файлы, — начиная с номера from. Вложение, которое не отправить повтором (например,
+// с устаревшим file_id), пропускается; на временной ошибке отправка останавливается
+// и возвращается *StepAttachmentsError с номером недоставленного вложения
+func (m *MessageManager) SendStepAttachments(ctx context.Context, userID int64, step *models.Step, from int) error {
+	var sends []func() error
+	for _, img := range PlanStepMedia(step.Images).Extra {
+		sends = append(sends, func() error {
+			_, err := m.SendAnimationWithRetry(ctx, &bot.SendAnimationParams{
+				ChatID:    userID,
+				Animation: &tgmodels.InputFileString{Data: img.FileID},
+			})
+			return err
+		})
+	}
+	for _, params := range BuildStepDocuments(userID, step) {
+		sends = append(sends, func() error {
+			_, err := m.SendDocumentWithRetry(ctx, params)
+			return err
+		})
+	}
+
+	for i := from; i < len(sends); i++ {
+		if err := sends[i](); err != nil {
+			if IsTransientSendError(err) {
+				return &StepAttachmentsError{From: i, Err: err}
+			}
+			log.Printf("[MESSAGE_MANAGER] Failed to send attachment %d for step %d to user %d: %v", i, step.ID, userID, err)
+		}
+	}
+	return nil
 }
 
 func (m *MessageManager) SendReaction(ctx context.Context, userID int64, text string) error {
@@ -331,9 +743,37 @@ func (m *MessageManager) DeleteMessage(ctx context.Context, chatID int64, messag
 		ChatID:    chatID,
 		MessageID: messageID,
 	})
+	if m.tracksDuplicates() {
+		m.chatStateRepo.ForgetBotMessage(chatID, messageID)
+	}
 	return err
 }
 
+// ReactToUserAnswer ставит реакцию emoji на последний ответ участника. Ответ с реакцией
+// остаётся в чате: он больше не удаляется вместе с реакцией бота
+func (m *MessageManager) ReactToUserAnswer(ctx context.Context, userID int64, emoji string) error {
+	state, err := m.chatStateRepo.Get(userID)
+	if err != nil {
+		return err
+	}
+	if state.LastUserAnswerMessageID == 0 {
+		return fmt.Errorf("no answer message for user %d", userID)
+	}
+
+	if _, err := m.bot.SetMessageReaction(ctx, &bot.SetMessageReactionParams{
+		ChatID:    userID,
+		MessageID: state.LastUserAnswerMessageID,
+		Reaction: []tgmodels.ReactionType{{
+			Type:              tgmodels.ReactionTypeTypeEmoji,
+			ReactionTypeEmoji: &tgmodels.ReactionTypeEmoji{Emoji: emoji},
+		}},
+	}); err != nil {
+		return err
+	}
+
+	return m.chatStateRepo.UpdateAnswerMessageID(userID, 0)
+}
+
 func (m *MessageManager) SaveUserAnswerMessageID(userID int64, messageID int) error {
 	return m.chatStateRepo.UpdateAnswerMessageID(userID, messageID)
 }
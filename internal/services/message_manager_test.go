@@ -3,9 +3,16 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 
+	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/models"
 	"github.com/go-telegram/bot"
 	tgmodels "github.com/go-telegram/bot/models"
@@ -238,3 +245,387 @@ func TestProperty4_HintMessageCleanup(t *testing.T) {
 		}
 	})
 }
+
+func TestBuildStepDocuments(t *testing.T) {
+	step := &models.Step{ID: 1, Text: "Задание"}
+	if params := BuildStepDocuments(42, step); len(params) != 0 {
+		t.Errorf("Expected no documents for a step without files, got %d", len(params))
+	}
+
+	step.Documents = []models.StepDocument{
+		{ID: 1, StepID: 1, FileID: "doc_file_1", FileName: "map.pdf"},
+		{ID: 2, StepID: 1, FileID: "doc_file_2", FileName: "rules.txt", Position: 1},
+	}
+	params := BuildStepDocuments(42, step)
+	if len(params) != 2 {
+		t.Fatalf("Expected a sendDocument call per document, got %d", len(params))
+	}
+	for i, p := range params {
+		if p.ChatID != int64(42) {
+			t.Errorf("Expected document to be sent to user 42, got %v", p.ChatID)
+		}
+		file, ok := p.Document.(*tgmodels.InputFileString)
+		if !ok || file.Data != step.Documents[i].FileID {
+			t.Errorf("Expected document %d to reference %s, got %+v", i, step.Documents[i].FileID, p.Document)
+		}
+	}
+}
+
+func TestSendTaskWithButtons_SendsDocumentsAfterTask(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		call := path.Base(r.URL.Path)
+		if document := r.FormValue("document"); document != "" {
+			call += " " + document
+		}
+		mu.Lock()
+		calls = append(calls, call)
+		id := len(calls)
+		mu.Unlock()
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":42,"type":"private"}}}`, id)
+	}))
+	defer server.Close()
+
+	b, err := bot.New("123:test", bot.WithSkipGetMe(), bot.WithServerURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMessageManager(b, db.NewChatStateRepository(queue), NewErrorManager(b, 1))
+	step := &models.Step{
+		ID:     7,
+		Text:   "Задание",
+		Images: []models.StepImage{{FileID: "photo_1", MediaType: models.MediaTypePhoto}},
+		Documents: []models.StepDocument{
+			{ID: 1, StepID: 7, FileID: "doc_file_1", FileName: "map.pdf"},
+			{ID: 2, StepID: 7, FileID: "doc_file_2", FileName: "rules.txt", Position: 1},
+		},
+	}
+
+	if err := m.SendTaskWithButtons(context.Background(), 42, step, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"sendPhoto", "sendDocument doc_file_1", "sendDocument doc_file_2"}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected the task followed by its documents in order %v, got %v", want, calls)
+	}
+}
+
+func TestPlanStepMedia(t *testing.T) {
+	photo := func(id string) models.StepImage {
+		return models.StepImage{FileID: id, MediaType: models.MediaTypePhoto}
+	}
+	gif := func(id string) models.StepImage {
+		return models.StepImage{FileID: id, MediaType: models.MediaTypeAnimation}
+	}
+
+	plan := PlanStepMedia(nil)
+	if plan.Captioned != nil || len(plan.Album) != 0 || len(plan.Extra) != 0 {
+		t.Errorf("Expected empty plan for a step without media, got %+v", plan)
+	}
+
+	plan = PlanStepMedia([]models.StepImage{gif("gif_1")})
+	if plan.Captioned == nil || !plan.Captioned.IsAnimation() || plan.Captioned.FileID != "gif_1" {
+		t.Errorf("Expected a single GIF to be sent as a captioned animation, got %+v", plan)
+	}
+
+	plan = PlanStepMedia([]models.StepImage{photo("photo_1")})
+	if plan.Captioned == nil || plan.Captioned.IsAnimation() {
+		t.Errorf("Expected a single photo to be sent as a captioned photo, got %+v", plan)
+	}
+
+	plan = PlanStepMedia([]models.StepImage{gif("gif_1"), photo("photo_1"), photo("photo_2")})
+	if len(plan.Album) != 2 || plan.Captioned != nil {
+		t.Errorf("Expected photos to go to the album, got %+v", plan)
+	}
+	if len(plan.Extra) != 1 || plan.Extra[0].FileID != "gif_1" {
+		t.Errorf("Expected the GIF to be sent after the album, got %+v", plan.Extra)
+	}
+
+	plan = PlanStepMedia([]models.StepImage{gif("gif_1"), gif("gif_2")})
+	if plan.Captioned == nil || plan.Captioned.FileID != "gif_1" || len(plan.Extra) != 1 || plan.Extra[0].FileID != "gif_2" {
+		t.Errorf("Expected the first GIF captioned and the second sent separately, got %+v", plan)
+	}
+}
+
+func TestSendLongSplitsMessage(t *testing.T) {
+	var sb strings.Builder
+	for sb.Len() < 9000 {
+		sb.WriteString("строка статистики с эмодзи 📊\n")
+	}
+	text := sb.String()
+
+	keyboard := &tgmodels.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgmodels.InlineKeyboardButton{{{Text: "⬅️ Назад", CallbackData: "admin:menu"}}},
+	}
+
+	m := NewMessageManager(nil, nil, nil)
+	params := m.longMessageParams(42, text, keyboard)
+	if len(params) < 2 {
+		t.Fatalf("Expected a 9000-char message to be split, got %d chunk(s)", len(params))
+	}
+
+	var joined []string
+	for i, p := range params {
+		if length := MessageLength(p.Text); length > MaxMessageLength {
+			t.Errorf("Chunk %d is %d long, limit %d", i, length, MaxMessageLength)
+		}
+		if strings.HasSuffix(p.Text, "\n") || strings.HasPrefix(p.Text, "\n") {
+			t.Errorf("Chunk %d should be split on a line boundary", i)
+		}
+		last := i == len(params)-1
+		if last != (p.ReplyMarkup != nil) {
+			t.Errorf("Chunk %d: keyboard must be attached only to the last chunk", i)
+		}
+		joined = append(joined, p.Text)
+	}
+	if strings.Join(joined, "\n") != strings.TrimRight(text, "\n") {
+		t.Error("Chunks should add up to the original text")
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	if chunks := SplitMessage("short", 10); len(chunks) != 1 || chunks[0] != "short" {
+		t.Errorf("Short text should stay intact, got %v", chunks)
+	}
+
+	chunks := SplitMessage("aaaa\nbbbb\ncccc", 9)
+	if len(chunks) != 2 || chunks[0] != "aaaa\nbbbb" || chunks[1] != "cccc" {
+		t.Errorf("Unexpected line split: %q", chunks)
+	}
+
+	chunks = SplitMessage(strings.Repeat("я", 25), 10)
+	if len(chunks) != 3 || chunks[2] != strings.Repeat("я", 5) {
+		t.Errorf("Long line should be cut by characters, got %q", chunks)
+	}
+
+	// Эмодзи занимает две единицы UTF-16 и не должен разрезаться пополам
+	chunks = SplitMessage(strings.Repeat("📊", 3), 4)
+	if len(chunks) != 2 || chunks[0] != "📊📊" || chunks[1] != "📊" {
+		t.Errorf("Unexpected emoji split: %q", chunks)
+	}
+}
+
+func TestSetMaxMessageLength(t *testing.T) {
+	m := NewMessageManager(nil, nil, nil)
+	m.SetMaxMessageLength(1000)
+	if m.MaxMessageLength() != 1000 {
+		t.Errorf("Expected 1000, got %d", m.MaxMessageLength())
+	}
+	for _, length := range []int{0, -5, 10000} {
+		m.SetMaxMessageLength(length)
+		if m.MaxMessageLength() != MaxMessageLength {
+			t.Errorf("SetMaxMessageLength(%d): expected Telegram limit, got %d", length, m.MaxMessageLength())
+		}
+	}
+}
+
+// newDuplicateTestManager возвращает MessageManager с фейковым Telegram API и список
+// вызванных методов. editFails заставляет editMessageText вернуть ошибку
+func newDuplicateTestManager(t *testing.T, policy DuplicatePolicy, editFails bool) (*MessageManager, func() []string) {
+	t.Helper()
+
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	t.Cleanup(cleanup)
+	createTestUserForEngine(t, db.NewUserRepository(queue), 42)
+
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := path.Base(r.URL.Path)
+		mu.Lock()
+		methods = append(methods, method)
+		id := len(methods)
+		mu.Unlock()
+		if method == "editMessageText" && editFails {
+			fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: message to edit not found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":42,"type":"private"}}}`, id)
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := bot.New("123:test", bot.WithSkipGetMe(), bot.WithServerURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMessageManager(b, db.NewChatStateRepository(queue), nil)
+	m.SetDuplicatePolicy(policy)
+	return m, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), methods...)
+	}
+}
+
+func TestSendWithRetry_DuplicatePolicy(t *testing.T) {
+	ctx := context.Background()
+	send := func(t *testing.T, m *MessageManager, text string) *tgmodels.Message {
+		t.Helper()
+		msg, err := m.SendWithRetry(ctx, &bot.SendMessageParams{ChatID: int64(42), Text: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	t.Run("send", func(t *testing.T) {
+		m, methods := newDuplicateTestManager(t, DuplicatePolicySend, false)
+		send(t, m, "⏳ Квест ещё не начался")
+		send(t, m, "⏳ Квест ещё не начался")
+		if got := methods(); len(got) != 2 {
+			t.Errorf("Expected both messages to be sent, got %v", got)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		m, methods := newDuplicateTestManager(t, DuplicatePolicySkip, false)
+		first := send(t, m, "⏳ Квест ещё не начался")
+		second := send(t, m, "⏳ Квест ещё не начался")
+		if got := methods(); len(got) != 1 || got[0] != "sendMessage" {
+			t.Errorf("Expected a single message, got %v", got)
+		}
+		if second.ID != first.ID {
+			t.Errorf("Expected the previous message %d to be returned, got %d", first.ID, second.ID)
+		}
+
+		send(t, m, "Другой текст")
+		send(t, m, "⏳ Квест ещё не начался")
+		if got := methods(); len(got) != 3 {
+			t.Errorf("Expected a repeat after another message to be sent, got %v", got)
+		}
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		m, methods := newDuplicateTestManager(t, DuplicatePolicyEdit, false)
+		send(t, m, "⏳ Квест ещё не начался")
+		send(t, m, "⏳ Квест ещё не начался")
+		if got := methods(); len(got) != 2 || got[1] != "editMessageText" {
+			t.Errorf("Expected the repeat to edit the previous message, got %v", got)
+		}
+	})
+
+	t.Run("edit falls back to sending", func(t *testing.T) {
+		m, methods := newDuplicateTestManager(t, DuplicatePolicyEdit, true)
+		send(t, m, "⏳ Квест ещё не начался")
+		send(t, m, "⏳ Квест ещё не начался")
+		if got := methods(); len(got) != 3 || got[2] != "sendMessage" {
+			t.Errorf("Expected a new message after a failed edit, got %v", got)
+		}
+	})
+
+	t.Run("deleted message and other media reset the repeat", func(t *testing.T) {
+		m, methods := newDuplicateTestManager(t, DuplicatePolicySkip, false)
+		first := send(t, m, "📷 Для этого задания нужно отправить фото")
+		m.DeleteMessage(ctx, 42, first.ID)
+		send(t, m, "📷 Для этого задания нужно отправить фото")
+
+		if _, err := m.SendPhotoWithRetry(ctx, &bot.SendPhotoParams{ChatID: int64(42), Photo: &tgmodels.InputFileString{Data: "photo"}}); err != nil {
+			t.Fatal(err)
+		}
+		send(t, m, "📷 Для этого задания нужно отправить фото")
+
+		if got := methods(); len(got) != 5 {
+			t.Errorf("Expected every repeat to be sent, got %v", got)
+		}
+	})
+
+	t.Run("messages with buttons are always sent", func(t *testing.T) {
+		m, methods := newDuplicateTestManager(t, DuplicatePolicySkip, false)
+		keyboard := &tgmodels.InlineKeyboardMarkup{InlineKeyboard: [][]tgmodels.InlineKeyboardButton{{{Text: "➡️", CallbackData: "next"}}}}
+		for i := 0; i < 2; i++ {
+			if _, err := m.SendWithRetry(ctx, &bot.SendMessageParams{ChatID: int64(42), Text: "✅ Правильно!", ReplyMarkup: keyboard}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := methods(); len(got) != 2 {
+			t.Errorf("Expected both messages with buttons to be sent, got %v", got)
+		}
+	})
+
+	t.Run("messages with buttons and single photos reset the repeat", func(t *testing.T) {
+		m, methods := newDuplicateTestManager(t, DuplicatePolicySkip, false)
+		keyboard := &tgmodels.InlineKeyboardMarkup{InlineKeyboard: [][]tgmodels.InlineKeyboardButton{{{Text: "➡️", CallbackData: "next"}}}}
+		send(t, m, "⏳ Подождите")
+		if _, err := m.SendWithRetry(ctx, &bot.SendMessageParams{ChatID: int64(42), Text: "✅ Правильно!", ReplyMarkup: keyboard}); err != nil {
+			t.Fatal(err)
+		}
+		send(t, m, "⏳ Подождите")
+
+		if _, err := m.TrySendPhoto(ctx, &bot.SendPhotoParams{ChatID: int64(42), Photo: &tgmodels.InputFileString{Data: "photo"}}); err != nil {
+			t.Fatal(err)
+		}
+		send(t, m, "⏳ Подождите")
+
+		if got := methods(); len(got) != 5 {
+			t.Errorf("Expected every repeat to be sent, got %v", got)
+		}
+	})
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for value, want := range map[string]DuplicatePolicy{"": DuplicatePolicySend, "send": DuplicatePolicySend, " Skip ": DuplicatePolicySkip, "edit": DuplicatePolicyEdit} {
+		if got, err := ParseDuplicatePolicy(value); err != nil || got != want {
+			t.Errorf("ParseDuplicatePolicy(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("merge"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
+
+func TestSendStepAttachments_ResumesFromUndelivered(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	failing := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) != "sendDocument" {
+			fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}`)
+			return
+		}
+		document := r.FormValue("document")
+		mu.Lock()
+		defer mu.Unlock()
+		if document == "doc_2" && failing {
+			fmt.Fprint(w, `{"ok":false,"error_code":500,"description":"Internal Server Error"}`)
+			return
+		}
+		sent = append(sent, document)
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":2,"date":0,"chat":{"id":42,"type":"private"}}}`)
+	}))
+	defer server.Close()
+
+	b, err := bot.New("123:test", bot.WithSkipGetMe(), bot.WithServerURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMessageManager(b, nil, NewErrorManager(b, 1))
+	step := &models.Step{ID: 7, Documents: []models.StepDocument{{FileID: "doc_1"}, {FileID: "doc_2"}, {FileID: "doc_3"}}}
+
+	err = m.SendStepAttachments(context.Background(), 42, step, 0)
+	var partial *StepAttachmentsError
+	if !errors.As(err, &partial) || partial.From != 1 {
+		t.Fatalf("Expected delivery to stop at the second document, got %v", err)
+	}
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	if err := m.SendStepAttachments(context.Background(), 42, step, partial.From); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(sent, ",") != "doc_1,doc_2,doc_3" {
+		t.Errorf("Expected every document to be delivered exactly once, got %v", sent)
+	}
+}
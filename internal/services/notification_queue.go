@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+	"github.com/go-telegram/bot"
+)
+
+// Виды сообщений, которые можно отправить повторно
+const (
+	NotificationAchievement = "achievement"
+	NotificationStep        = "step"
+)
+
+// DefaultNotificationRetryAttempts — сколько раз всего пытаться доставить сообщение,
+// включая первую неудачную отправку
+const DefaultNotificationRetryAttempts = 5
+
+// NotificationSender отправляет участнику сообщение вида, для которого он зарегистрирован
+type NotificationSender func(ctx context.Context, userID int64, payload string) error
+
+// NotificationQueue повторяет отправку сообщений участникам, которые не удалось доставить
+// из-за временной ошибки Telegram или сети. Сообщение отбрасывается после maxAttempts
+// неудачных попыток или если участник заблокировал бота
+type NotificationQueue struct {
+	repo        *db.PendingNotificationRepository
+	maxAttempts int
+
+	mu      sync.RWMutex
+	senders map[string]NotificationSender
+}
+
+func NewNotificationQueue(repo *db.PendingNotificationRepository) *NotificationQueue {
+	return &NotificationQueue{
+		repo:        repo,
+		maxAttempts: DefaultNotificationRetryAttempts,
+		senders:     make(map[string]NotificationSender),
+	}
+}
+
+// SetMaxAttempts задаёт общее число попыток доставки одного сообщения
+func (q *NotificationQueue) SetMaxAttempts(attempts int) {
+	q.maxAttempts = attempts
+}
+
+// RegisterSender задаёт, как повторно отправлять сообщения вида kind
+func (q *NotificationQueue) RegisterSender(kind string, sender NotificationSender) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.senders[kind] = sender
+}
+
+func (q *NotificationQueue) sender(kind string) NotificationSender {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.senders[kind]
+}
+
+// IsTransientSendError сообщает, стоит ли повторять отправку после ошибки. Участник,
+// заблокировавший бота, и некорректный запрос повтором не исправятся
+func IsTransientSendError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return !errors.Is(err, bot.ErrorForbidden) && !errors.Is(err, bot.ErrorBadRequest)
+}
+
+// Enqueue ставит сообщение в очередь, если sendErr — временная ошибка. Возвращает true,
+// если сообщение будет отправлено повторно
+func (q *NotificationQueue) Enqueue(userID int64, kind, payload string, sendErr error) bool {
+	if q == nil || q.maxAttempts <= 1 || !IsTransientSendError(sendErr) {
+		return false
+	}
+
+	err := q.repo.Add(&models.PendingNotification{
+		UserID:    userID,
+		Kind:      kind,
+		Payload:   payload,
+		Attempts:  1,
+		LastError: truncateErrorMessage(sendErr.Error()),
+	})
+	if err != nil {
+		log.Printf("[NOTIFICATION_QUEUE] Failed to queue %s for user %d: %v", kind, userID, err)
+		return false
+	}
+	return true
+}
+
+// Deliver отправляет сообщение зарегистрированным отправителем и при временной ошибке
+// ставит его в очередь
+func (q *NotificationQueue) Deliver(ctx context.Context, userID int64, kind, payload string) error {
+	send := q.sender(kind)
+	if send == nil {
+		return fmt.Errorf("no sender for notification kind %q", kind)
+	}
+
+	err := send(ctx, userID, payload)
+	if err != nil {
+		q.Enqueue(userID, kind, payload, err)
+	}
+	return err
+}
+
+// RetryResult — итог одного прохода по очереди
+type RetryResult struct {
+	Delivered int
+	Dropped   int
+	Pending   int
+}
+
+// Retry пытается отправить все сообщения из очереди. Доставленные и безнадёжные
+// сообщения удаляются, остальные ждут следующего прохода
+func (q *NotificationQueue) Retry(ctx context.Context) (RetryResult, error) {
+	var result RetryResult
+
+	notifications, err := q.repo.GetAll()
+	if err != nil {
+		return result, err
+	}
+
+	for _, n := range notifications {
+		if ctx.Err() != nil {
+			break
+		}
+
+		send := q.sender(n.Kind)
+		if send == nil {
+			log.Printf("[NOTIFICATION_QUEUE] Dropping %s for user %d: no sender", n.Kind, n.UserID)
+			result.Dropped++
+			if err := q.repo.Delete(n.ID); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		sendErr := send(ctx, n.UserID, n.Payload)
+		switch {
+		case sendErr == nil:
+			result.Delivered++
+			if err := q.repo.Delete(n.ID); err != nil {
+				return result, err
+			}
+		case !IsTransientSendError(sendErr) || n.Attempts+1 >= q.maxAttempts:
+			log.Printf("[NOTIFICATION_QUEUE] Dropping %s for user %d after %d attempts: %v", n.Kind, n.UserID, n.Attempts+1, sendErr)
+			result.Dropped++
+			if err := q.repo.Delete(n.ID); err != nil {
+				return result, err
+			}
+		default:
+			result.Pending++
+			if err := q.repo.RecordFailure(n.ID, truncateErrorMessage(sendErr.Error())); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/go-telegram/bot"
+)
+
+var errTransientSend = errors.New("connection reset by peer")
+
+func newTestNotificationQueue(t *testing.T) (*NotificationQueue, *db.PendingNotificationRepository) {
+	queue, cleanup := setupNotifierTestDB(t)
+	t.Cleanup(cleanup)
+
+	repo := db.NewPendingNotificationRepository(queue)
+	return NewNotificationQueue(repo), repo
+}
+
+func TestNotificationQueue_EnqueueOnFailure(t *testing.T) {
+	q, repo := newTestNotificationQueue(t)
+
+	q.RegisterSender(NotificationAchievement, func(ctx context.Context, userID int64, payload string) error {
+		return errTransientSend
+	})
+	if err := q.Deliver(context.Background(), 1, NotificationAchievement, "winner_1"); err == nil {
+		t.Fatal("Expected delivery error")
+	}
+	// Повторный сбой того же сообщения не создаёт дубликат
+	q.Deliver(context.Background(), 1, NotificationAchievement, "winner_1")
+
+	blocked := fmt.Errorf("%w, Forbidden: bot was blocked by the user", bot.ErrorForbidden)
+	if q.Enqueue(2, NotificationStep, "5", blocked) {
+		t.Error("Expected no retry for a user who blocked the bot")
+	}
+
+	pending, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("Expected 1 queued notification, got %d", len(pending))
+	}
+	if pending[0].UserID != 1 || pending[0].Kind != NotificationAchievement || pending[0].Payload != "winner_1" || pending[0].Attempts != 1 {
+		t.Errorf("Unexpected queued notification: %+v", pending[0])
+	}
+}
+
+func TestNotificationQueue_RetrySucceeds(t *testing.T) {
+	q, repo := newTestNotificationQueue(t)
+
+	var delivered []string
+	q.RegisterSender(NotificationStep, func(ctx context.Context, userID int64, payload string) error {
+		delivered = append(delivered, payload)
+		return nil
+	})
+	q.Enqueue(1, NotificationStep, "3", errTransientSend)
+
+	result, err := q.Retry(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Delivered != 1 || result.Dropped != 0 || result.Pending != 0 {
+		t.Errorf("Expected one delivered notification, got %+v", result)
+	}
+	if len(delivered) != 1 || delivered[0] != "3" {
+		t.Errorf("Expected step 3 to be resent, got %v", delivered)
+	}
+
+	pending, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected empty queue after delivery, got %d", len(pending))
+	}
+}
+
+func TestNotificationQueue_DropAfterRepeatedFailure(t *testing.T) {
+	q, repo := newTestNotificationQueue(t)
+	q.SetMaxAttempts(3)
+
+	attempts := 0
+	q.RegisterSender(NotificationAchievement, func(ctx context.Context, userID int64, payload string) error {
+		attempts++
+		return errTransientSend
+	})
+	q.Enqueue(1, NotificationAchievement, "winner_1", errTransientSend)
+
+	result, err := q.Retry(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Pending != 1 {
+		t.Errorf("Expected notification to stay queued after the second attempt, got %+v", result)
+	}
+
+	result, err = q.Retry(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Dropped != 1 {
+		t.Errorf("Expected notification to be dropped after the third attempt, got %+v", result)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 retries, got %d", attempts)
+	}
+
+	pending, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected empty queue after drop, got %d", len(pending))
+	}
+}
+
+func TestNotificationQueue_DropWhenUserBlockedBot(t *testing.T) {
+	q, repo := newTestNotificationQueue(t)
+
+	q.RegisterSender(NotificationStep, func(ctx context.Context, userID int64, payload string) error {
+		return fmt.Errorf("%w, Forbidden: bot was blocked by the user", bot.ErrorForbidden)
+	})
+	q.Enqueue(1, NotificationStep, "3", errTransientSend)
+
+	result, err := q.Retry(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Dropped != 1 {
+		t.Errorf("Expected notification to be dropped, got %+v", result)
+	}
+
+	pending, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected empty queue after drop, got %d", len(pending))
+	}
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultProgressBarWidth — число делений прогресс-бара в /progress и после ответа
+const DefaultProgressBarWidth = 10
+
+// ProgressBar рисует текстовый прогресс-бар шириной DefaultProgressBarWidth,
+// например «▓▓▓▓▓▓░░░░ 60%»
+func ProgressBar(done, total int) string {
+	return ProgressBarWithWidth(done, total, DefaultProgressBarWidth)
+}
+
+// ProgressBarWithWidth рисует прогресс-бар из width делений. done ограничивается
+// диапазоном от 0 до total, а при total <= 0 бар пустой и показывает 0%
+func ProgressBarWithWidth(done, total, width int) string {
+	if width < 1 {
+		width = 1
+	}
+	if total <= 0 || done < 0 {
+		done = 0
+	}
+	if total > 0 && done > total {
+		done = total
+	}
+
+	filled, percent := 0, 0
+	if total > 0 {
+		filled = done * width / total
+		percent = done * 100 / total
+	}
+
+	return fmt.Sprintf("%s%s %d%%", strings.Repeat("▓", filled), strings.Repeat("░", width-filled), percent)
+}
@@ -0,0 +1,32 @@
+package services
+
+import "testing"
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		name        string
+		done, total int
+		width       int
+		want        string
+	}{
+		{name: "zero", done: 0, total: 5, width: 5, want: "░░░░░ 0%"},
+		{name: "partial", done: 3, total: 5, width: 5, want: "▓▓▓░░ 60%"},
+		{name: "complete", done: 5, total: 5, width: 5, want: "▓▓▓▓▓ 100%"},
+		{name: "rounds down", done: 1, total: 3, width: 10, want: "▓▓▓░░░░░░░ 33%"},
+		{name: "more than total", done: 7, total: 5, width: 5, want: "▓▓▓▓▓ 100%"},
+		{name: "no steps", done: 0, total: 0, width: 5, want: "░░░░░ 0%"},
+		{name: "minimal width", done: 1, total: 2, width: 0, want: "░ 50%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProgressBarWithWidth(tt.done, tt.total, tt.width); got != tt.want {
+				t.Errorf("ProgressBarWithWidth(%d, %d, %d) = %q, want %q", tt.done, tt.total, tt.width, got, tt.want)
+			}
+		})
+	}
+
+	if got := ProgressBar(6, 10); got != "▓▓▓▓▓▓░░░░ 60%" {
+		t.Errorf("ProgressBar(6, 10) = %q", got)
+	}
+}
@@ -0,0 +1,736 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+// QuestConfigVersion — версия формата бандла конфигурации квеста. Бандлы более новой
+// версии не импортируются, чтобы не потерять поля, о которых этот бот не знает
+const QuestConfigVersion = 1
+
+// QuestConfigImportMode определяет, что делать с тем, чего нет в бандле
+type QuestConfigImportMode string
+
+const (
+	// QuestConfigMerge добавляет и обновляет шаги, настройки и достижения из бандла,
+	// остальное не трогает
+	QuestConfigMerge QuestConfigImportMode = "merge"
+	// QuestConfigReplace дополнительно удаляет шаги, главы, синонимы и настройки, которых нет
+	// в бандле, а отсутствующие достижения выключает: выданные участникам достижения сохраняются
+	QuestConfigReplace QuestConfigImportMode = "replace"
+)
+
+// questConfigInstanceSettings — настройки, привязанные к конкретному экземпляру бота
+// (состояние квеста, чаты, служебные отметки времени). Они не экспортируются и не
+// перезаписываются при импорте
+var questConfigInstanceSettings = map[string]bool{
+	"quest_state":            true,
+	"required_group_chat_id": true,
+	"group_chat_invite_link": true,
+	"announce_channel_id":    true,
+	"hall_of_fame_message":   true,
+	"daily_digest_last_sent": true,
+	"last_backup_at":         true,
+}
+
+// QuestConfigBundle — вся конфигурация квеста одним JSON-файлом: шаги с главами и
+// вариантами ответов, настройки, достижения и словарь синонимов. Медиа хранятся как
+// file_id Telegram, поэтому переносятся только между экземплярами с одним и тем же ботом
+type QuestConfigBundle struct {
+	Version      int                      `json:"version"`
+	ExportedAt   time.Time                `json:"exported_at"`
+	Chapters     []string                 `json:"chapters,omitempty"`
+	Steps        []QuestConfigStep        `json:"steps"`
+	Settings     map[string]string        `json:"settings"`
+	Achievements []QuestConfigAchievement `json:"achievements"`
+	Synonyms     []QuestConfigSynonyms    `json:"synonyms,omitempty"`
+}
+
+// QuestConfigStep — шаг квеста в бандле. Шаги сопоставляются по порядковому номеру,
+// глава указывается названием
+type QuestConfigStep struct {
+	Order              int               `json:"order"`
+	Text               string            `json:"text"`
+	AnswerType         models.AnswerType `json:"answer_type"`
+	HasAutoCheck       bool              `json:"has_auto_check,omitempty"`
+	IsActive           bool              `json:"is_active"`
+	IsAsterisk         bool              `json:"is_asterisk,omitempty"`
+	Chapter            string            `json:"chapter,omitempty"`
+	CorrectAnswerImage string            `json:"correct_answer_image,omitempty"`
+	HintText           string            `json:"hint_text,omitempty"`
+	HintImage          string            `json:"hint_image,omitempty"`
+	LocationLat        float64           `json:"location_lat,omitempty"`
+	LocationLng        float64           `json:"location_lng,omitempty"`
+	LocationRadius     int               `json:"location_radius,omitempty"`
+	RequiredAnswers    int               `json:"required_answers,omitempty"`
+	NumericFeedback    bool              `json:"numeric_feedback,omitempty"`
+	OrderedAnswers     bool              `json:"ordered_answers,omitempty"`
+	UseSynonyms        bool              `json:"use_synonyms,omitempty"`
+	LinkDomain         string            `json:"link_domain,omitempty"`
+	RequireReply       bool              `json:"require_reply,omitempty"`
+	ImageConstraints   string            `json:"image_constraints,omitempty"`
+	// AuthorNote — внутренняя заметка автора, выгружается только при включённой настройке
+	// export_author_notes. При импорте пустая заметка не стирает существующую
+	AuthorNote      string                `json:"author_note,omitempty"`
+	Images          []QuestConfigMedia    `json:"images,omitempty"`
+	Answers         []string              `json:"answers,omitempty"`
+	AnswerLanguages map[string]string     `json:"answer_languages,omitempty"`
+	Choices         []QuestConfigChoice   `json:"choices,omitempty"`
+	Documents       []QuestConfigDocument `json:"documents,omitempty"`
+}
+
+type QuestConfigMedia struct {
+	FileID    string `json:"file_id"`
+	MediaType string `json:"media_type"`
+}
+
+type QuestConfigChoice struct {
+	Text      string `json:"text"`
+	IsCorrect bool   `json:"is_correct,omitempty"`
+}
+
+type QuestConfigDocument struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name,omitempty"`
+}
+
+// QuestConfigAchievement — достижение в бандле, сопоставляется по ключу
+type QuestConfigAchievement struct {
+	Key           string                       `json:"key"`
+	Name          string                       `json:"name"`
+	Description   string                       `json:"description"`
+	Category      models.AchievementCategory   `json:"category"`
+	Type          models.AchievementType       `json:"type"`
+	IsUnique      bool                         `json:"is_unique,omitempty"`
+	Conditions    models.AchievementConditions `json:"conditions"`
+	IsActive      bool                         `json:"is_active"`
+	NotifyOnAward bool                         `json:"notify_on_award"`
+}
+
+type QuestConfigSynonyms struct {
+	Canonical string   `json:"canonical"`
+	Synonyms  []string `json:"synonyms"`
+}
+
+// QuestConfigDiff — изменения, которые внесёт импорт бандла в текущий квест
+type QuestConfigDiff struct {
+	Mode                QuestConfigImportMode
+	StepsAdded          []int
+	StepsChanged        []int
+	StepsRemoved        []int
+	ChaptersAdded       []string
+	ChaptersRemoved     []string
+	SettingsChanged     []string
+	SettingsRemoved     []string
+	AchievementsAdded   []string
+	AchievementsChanged []string
+	AchievementsRemoved []string
+	SynonymsChanged     bool
+}
+
+func (d *QuestConfigDiff) IsEmpty() bool {
+	return len(d.StepsAdded)+len(d.StepsChanged)+len(d.StepsRemoved)+
+		len(d.ChaptersAdded)+len(d.ChaptersRemoved)+
+		len(d.SettingsChanged)+len(d.SettingsRemoved)+
+		len(d.AchievementsAdded)+len(d.AchievementsChanged)+len(d.AchievementsRemoved) == 0 &&
+		!d.SynonymsChanged
+}
+
+type QuestConfigService struct {
+	queue           *db.DBQueue
+	stepRepo        *db.StepRepository
+	settingsRepo    *db.SettingsRepository
+	achievementRepo *db.AchievementRepository
+	answerRepo      *db.AnswerRepository
+}
+
+func NewQuestConfigService(
+	queue *db.DBQueue,
+	stepRepo *db.StepRepository,
+	settingsRepo *db.SettingsRepository,
+	achievementRepo *db.AchievementRepository,
+	answerRepo *db.AnswerRepository,
+) *QuestConfigService {
+	return &QuestConfigService{
+		queue:           queue,
+		stepRepo:        stepRepo,
+		settingsRepo:    settingsRepo,
+		achievementRepo: achievementRepo,
+		answerRepo:      answerRepo,
+	}
+}
+
+// Export возвращает текущую конфигурацию квеста в виде JSON-бандла
+func (s *QuestConfigService) Export() ([]byte, error) {
+	bundle, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	bundle.ExportedAt = time.Now().UTC()
+
+	if includeNotes, err := s.settingsRepo.GetExportAuthorNotes(); err != nil || !includeNotes {
+		for i := range bundle.Steps {
+			bundle.Steps[i].AuthorNote = ""
+		}
+	}
+	return json.MarshalIndent(bundle, "", "  ")
+}
+
+// ParseQuestConfig разбирает бандл и проверяет его версию и целостность
+func ParseQuestConfig(data []byte) (*QuestConfigBundle, error) {
+	var bundle QuestConfigBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("файл не является бандлом конфигурации: %w", err)
+	}
+	if bundle.Version == 0 {
+		return nil, errors.New("в файле нет версии бандла конфигурации")
+	}
+	if bundle.Version > QuestConfigVersion {
+		return nil, fmt.Errorf("бандл версии %d новее поддерживаемой (%d)", bundle.Version, QuestConfigVersion)
+	}
+
+	orders := make(map[int]bool, len(bundle.Steps))
+	for _, step := range bundle.Steps {
+		if step.Order <= 0 {
+			return nil, fmt.Errorf("у шага «%s» нет порядкового номера", truncateSummaryText(step.Text, 30))
+		}
+		if orders[step.Order] {
+			return nil, fmt.Errorf("шаг %d указан в бандле дважды", step.Order)
+		}
+		orders[step.Order] = true
+		if step.Chapter != "" && !slices.Contains(bundle.Chapters, step.Chapter) {
+			return nil, fmt.Errorf("глава «%s» шага %d не описана в бандле", step.Chapter, step.Order)
+		}
+		if _, err := ParseImageConstraints(step.ImageConstraints); err != nil {
+			return nil, fmt.Errorf("у шага %d неверные ограничения фото «%s»", step.Order, step.ImageConstraints)
+		}
+	}
+
+	keys := make(map[string]bool, len(bundle.Achievements))
+	for _, achievement := range bundle.Achievements {
+		if achievement.Key == "" {
+			return nil, fmt.Errorf("у достижения «%s» нет ключа", achievement.Name)
+		}
+		if keys[achievement.Key] {
+			return nil, fmt.Errorf("достижение %s указано в бандле дважды", achievement.Key)
+		}
+		keys[achievement.Key] = true
+	}
+
+	return &bundle, nil
+}
+
+// Diff проверяет бандл и возвращает изменения, которые внесёт его импорт, ничего не меняя
+func (s *QuestConfigService) Diff(data []byte, mode QuestConfigImportMode) (*QuestConfigDiff, error) {
+	bundle, err := ParseQuestConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return diffQuestConfig(current, bundle, mode)
+}
+
+// Import применяет бандл в одной транзакции и возвращает внесённые изменения.
+// Изменённые шаги обновляются на месте, поэтому прогресс участников сохраняется
+func (s *QuestConfigService) Import(data []byte, mode QuestConfigImportMode) (*QuestConfigDiff, error) {
+	bundle, err := ParseQuestConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := diffQuestConfig(current, bundle, mode)
+	if err != nil {
+		return nil, err
+	}
+	if diff.IsEmpty() {
+		return diff, nil
+	}
+
+	_, err = s.queue.Execute(func(conn *sql.DB) (interface{}, error) {
+		tx, err := conn.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if err := applyQuestConfig(tx, bundle, diff); err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// current собирает бандл из текущего состояния базы
+func (s *QuestConfigService) current() (*QuestConfigBundle, error) {
+	bundle := &QuestConfigBundle{
+		Version:  QuestConfigVersion,
+		Settings: make(map[string]string),
+	}
+
+	chapters, err := s.stepRepo.GetChapters()
+	if err != nil {
+		return nil, err
+	}
+	chapterTitles := make(map[int64]string, len(chapters))
+	for _, chapter := range chapters {
+		chapterTitles[chapter.ID] = chapter.Title
+		bundle.Chapters = append(bundle.Chapters, chapter.Title)
+	}
+
+	steps, err := s.stepRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	notes, err := s.stepRepo.GetAuthorNotes()
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		configStep := questConfigStep(step, chapterTitles[step.ChapterID])
+		configStep.AuthorNote = notes[step.ID]
+		bundle.Steps = append(bundle.Steps, configStep)
+	}
+
+	settings, err := s.settingsRepo.GetValues()
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range settings {
+		if !questConfigInstanceSettings[key] {
+			bundle.Settings[key] = value
+		}
+	}
+
+	achievements, err := s.achievementRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range achievements {
+		bundle.Achievements = append(bundle.Achievements, QuestConfigAchievement{
+			Key:           a.Key,
+			Name:          a.Name,
+			Description:   a.Description,
+			Category:      a.Category,
+			Type:          a.Type,
+			IsUnique:      a.IsUnique,
+			Conditions:    a.Conditions,
+			IsActive:      a.IsActive,
+			NotifyOnAward: a.NotifyOnAward,
+		})
+	}
+
+	groups, err := s.answerRepo.GetSynonymGroups()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		bundle.Synonyms = append(bundle.Synonyms, QuestConfigSynonyms{Canonical: group.Canonical, Synonyms: group.Synonyms})
+	}
+
+	return bundle, nil
+}
+
+func questConfigStep(step *models.Step, chapter string) QuestConfigStep {
+	result := QuestConfigStep{
+		Order:              step.StepOrder,
+		Text:               step.Text,
+		AnswerType:         step.AnswerType,
+		HasAutoCheck:       step.HasAutoCheck,
+		IsActive:           step.IsActive,
+		IsAsterisk:         step.IsAsterisk,
+		Chapter:            chapter,
+		CorrectAnswerImage: step.CorrectAnswerImage,
+		HintText:           step.HintText,
+		HintImage:          step.HintImage,
+		LocationLat:        step.LocationLat,
+		LocationLng:        step.LocationLng,
+		LocationRadius:     step.LocationRadius,
+		RequiredAnswers:    step.RequiredAnswers,
+		NumericFeedback:    step.NumericFeedback,
+		OrderedAnswers:     step.OrderedAnswers,
+		UseSynonyms:        step.UseSynonyms,
+		LinkDomain:         step.LinkDomain,
+		RequireReply:       step.RequireReply,
+		ImageConstraints:   step.ImageConstraints,
+	}
+	for _, img := range step.Images {
+		result.Images = append(result.Images, QuestConfigMedia{FileID: img.FileID, MediaType: img.MediaType})
+	}
+	if len(step.Answers) > 0 {
+		result.Answers = slices.Clone(step.Answers)
+	}
+	if len(step.AnswerLanguages) > 0 {
+		result.AnswerLanguages = maps.Clone(step.AnswerLanguages)
+	}
+	for _, choice := range step.Choices {
+		result.Choices = append(result.Choices, QuestConfigChoice{Text: choice.Text, IsCorrect: choice.IsCorrect})
+	}
+	for _, document := range step.Documents {
+		result.Documents = append(result.Documents, QuestConfigDocument{FileID: document.FileID, FileName: document.FileName})
+	}
+	return result
+}
+
+func diffQuestConfig(current, bundle *QuestConfigBundle, mode QuestConfigImportMode) (*QuestConfigDiff, error) {
+	if mode != QuestConfigMerge && mode != QuestConfigReplace {
+		return nil, fmt.Errorf("неизвестный режим импорта: %s", mode)
+	}
+	replace := mode == QuestConfigReplace
+	diff := &QuestConfigDiff{Mode: mode}
+
+	currentSteps := make(map[int]QuestConfigStep, len(current.Steps))
+	for _, step := range current.Steps {
+		currentSteps[step.Order] = step
+	}
+	bundleOrders := make(map[int]bool, len(bundle.Steps))
+	for _, step := range bundle.Steps {
+		bundleOrders[step.Order] = true
+		existing, ok := currentSteps[step.Order]
+		if step.AuthorNote == "" {
+			existing.AuthorNote = ""
+		}
+		switch {
+		case !ok:
+			diff.StepsAdded = append(diff.StepsAdded, step.Order)
+		case !reflect.DeepEqual(existing, step):
+			diff.StepsChanged = append(diff.StepsChanged, step.Order)
+		}
+	}
+	if replace {
+		for _, step := range current.Steps {
+			if !bundleOrders[step.Order] {
+				diff.StepsRemoved = append(diff.StepsRemoved, step.Order)
+			}
+		}
+	}
+
+	for _, title := range bundle.Chapters {
+		if !slices.Contains(current.Chapters, title) && !slices.Contains(diff.ChaptersAdded, title) {
+			diff.ChaptersAdded = append(diff.ChaptersAdded, title)
+		}
+	}
+	if replace {
+		for _, title := range current.Chapters {
+			if !slices.Contains(bundle.Chapters, title) {
+				diff.ChaptersRemoved = append(diff.ChaptersRemoved, title)
+			}
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(bundle.Settings)) {
+		if questConfigInstanceSettings[key] {
+			continue
+		}
+		if value, ok := current.Settings[key]; !ok || value != bundle.Settings[key] {
+			diff.SettingsChanged = append(diff.SettingsChanged, key)
+		}
+	}
+	if replace {
+		for _, key := range slices.Sorted(maps.Keys(current.Settings)) {
+			if _, ok := bundle.Settings[key]; !ok {
+				diff.SettingsRemoved = append(diff.SettingsRemoved, key)
+			}
+		}
+	}
+
+	currentAchievements := make(map[string]QuestConfigAchievement, len(current.Achievements))
+	for _, a := range current.Achievements {
+		currentAchievements[a.Key] = a
+	}
+	bundleKeys := make(map[string]bool, len(bundle.Achievements))
+	for _, a := range bundle.Achievements {
+		bundleKeys[a.Key] = true
+		existing, ok := currentAchievements[a.Key]
+		switch {
+		case !ok:
+			diff.AchievementsAdded = append(diff.AchievementsAdded, a.Key)
+		case !reflect.DeepEqual(existing, a):
+			diff.AchievementsChanged = append(diff.AchievementsChanged, a.Key)
+		}
+	}
+	if replace {
+		for _, a := range current.Achievements {
+			if !bundleKeys[a.Key] && a.IsActive {
+				diff.AchievementsRemoved = append(diff.AchievementsRemoved, a.Key)
+			}
+		}
+	}
+
+	diff.SynonymsChanged = synonymsChanged(current.Synonyms, bundle.Synonyms, replace)
+
+	return diff, nil
+}
+
+// synonymsChanged сообщает, изменит ли импорт словарь синонимов. При слиянии учитываются
+// только синонимы из бандла, которых нет в словаре или которые ведут к другой форме
+func synonymsChanged(current, bundle []QuestConfigSynonyms, replace bool) bool {
+	currentMap := synonymMap(current)
+	bundleMap := synonymMap(bundle)
+	if replace {
+		return !maps.Equal(currentMap, bundleMap)
+	}
+	for synonym, canonical := range bundleMap {
+		if currentMap[synonym] != canonical {
+			return true
+		}
+	}
+	return false
+}
+
+func synonymMap(groups []QuestConfigSynonyms) map[string]string {
+	result := make(map[string]string)
+	for _, group := range groups {
+		canonical := strings.ToLower(strings.TrimSpace(group.Canonical))
+		for _, synonym := range group.Synonyms {
+			if synonym = strings.ToLower(strings.TrimSpace(synonym)); synonym != "" && synonym != canonical {
+				result[synonym] = canonical
+			}
+		}
+	}
+	return result
+}
+
+func applyQuestConfig(tx *sql.Tx, bundle *QuestConfigBundle, diff *QuestConfigDiff) error {
+	replace := diff.Mode == QuestConfigReplace
+
+	for _, title := range diff.ChaptersAdded {
+		if _, err := tx.Exec(`
+			INSERT INTO chapters (title, position)
+			VALUES (?, (SELECT COALESCE(MAX(position), -1) + 1 FROM chapters))
+		`, title); err != nil {
+			return err
+		}
+	}
+	for _, title := range diff.ChaptersRemoved {
+		if _, err := tx.Exec(`UPDATE steps SET chapter_id = 0 WHERE chapter_id IN (SELECT id FROM chapters WHERE title = ?)`, title); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM chapters WHERE title = ?`, title); err != nil {
+			return err
+		}
+	}
+
+	for _, order := range diff.StepsRemoved {
+		if _, err := tx.Exec(`UPDATE steps SET is_deleted = TRUE WHERE step_order = ? AND is_deleted = FALSE`, order); err != nil {
+			return err
+		}
+	}
+
+	// Номера до bundleMaxOrder может занять импорт, поэтому удалённые шаги, освобождающие
+	// номер, переносятся за него
+	bundleMaxOrder := 0
+	for _, step := range bundle.Steps {
+		bundleMaxOrder = max(bundleMaxOrder, step.Order)
+	}
+
+	for _, step := range bundle.Steps {
+		added := slices.Contains(diff.StepsAdded, step.Order)
+		if !added && !slices.Contains(diff.StepsChanged, step.Order) {
+			continue
+		}
+		if err := applyQuestConfigStep(tx, step, added, bundleMaxOrder); err != nil {
+			return fmt.Errorf("шаг %d: %w", step.Order, err)
+		}
+	}
+
+	for _, key := range diff.SettingsChanged {
+		if _, err := tx.Exec(`
+			INSERT INTO settings (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value
+		`, key, bundle.Settings[key]); err != nil {
+			return err
+		}
+	}
+	for _, key := range diff.SettingsRemoved {
+		if _, err := tx.Exec(`DELETE FROM settings WHERE key = ?`, key); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range bundle.Achievements {
+		if !slices.Contains(diff.AchievementsAdded, a.Key) && !slices.Contains(diff.AchievementsChanged, a.Key) {
+			continue
+		}
+		conditions, err := a.Conditions.ToJSON()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO achievements (key, name, description, category, type, is_unique, conditions, is_active, notify_on_award)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET
+				name = excluded.name, description = excluded.description, category = excluded.category,
+				type = excluded.type, is_unique = excluded.is_unique, conditions = excluded.conditions,
+				is_active = excluded.is_active, notify_on_award = excluded.notify_on_award
+		`, a.Key, a.Name, a.Description, a.Category, a.Type, a.IsUnique, conditions, a.IsActive, a.NotifyOnAward); err != nil {
+			return err
+		}
+	}
+	for _, key := range diff.AchievementsRemoved {
+		if _, err := tx.Exec(`UPDATE achievements SET is_active = FALSE WHERE key = ?`, key); err != nil {
+			return err
+		}
+	}
+
+	if diff.SynonymsChanged {
+		if replace {
+			if _, err := tx.Exec(`DELETE FROM answer_synonyms`); err != nil {
+				return err
+			}
+		}
+		for synonym, canonical := range synonymMap(bundle.Synonyms) {
+			if _, err := tx.Exec(`
+				INSERT INTO answer_synonyms (synonym, canonical) VALUES (?, ?)
+				ON CONFLICT(synonym) DO UPDATE SET canonical = excluded.canonical
+			`, synonym, canonical); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyQuestConfigStep создаёт шаг или обновляет существующий шаг с тем же номером,
+// полностью заменяя его медиа, варианты ответа и файлы. Удалённый шаг с тем же номером
+// переносится за bundleMaxOrder и за последний номер в таблице
+func applyQuestConfigStep(tx *sql.Tx, step QuestConfigStep, added bool, bundleMaxOrder int) error {
+	var chapterID int64
+	if step.Chapter != "" {
+		if err := tx.QueryRow(`SELECT id FROM chapters WHERE title = ? ORDER BY position, id LIMIT 1`, step.Chapter).Scan(&chapterID); err != nil {
+			return err
+		}
+	}
+
+	var stepID int64
+	if added {
+		// Номер может занимать удалённый шаг: переносим его в конец, чтобы освободить номер
+		if _, err := tx.Exec(`
+			UPDATE steps SET step_order = MAX((SELECT MAX(step_order) FROM steps), ?) + 1
+			WHERE step_order = ? AND is_deleted = TRUE
+		`, bundleMaxOrder, step.Order); err != nil {
+			return err
+		}
+		res, err := tx.Exec(`INSERT INTO steps (step_order, text) VALUES (?, '')`, step.Order)
+		if err != nil {
+			return err
+		}
+		if stepID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	} else if err := tx.QueryRow(`SELECT id FROM steps WHERE step_order = ? AND is_deleted = FALSE`, step.Order).Scan(&stepID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE steps SET
+			text = ?, answer_type = ?, has_auto_check = ?, is_active = ?, is_asterisk = ?,
+			correct_answer_image = ?, hint_text = ?, hint_image = ?,
+			location_lat = ?, location_lng = ?, location_radius = ?, required_answers = ?,
+			chapter_id = ?, numeric_feedback = ?, ordered_answers = ?, use_synonyms = ?, link_domain = ?,
+			require_reply = ?, image_constraints = ?
+		WHERE id = ?
+	`, step.Text, step.AnswerType, step.HasAutoCheck, step.IsActive, step.IsAsterisk,
+		step.CorrectAnswerImage, step.HintText, step.HintImage,
+		step.LocationLat, step.LocationLng, step.LocationRadius, step.RequiredAnswers,
+		chapterID, step.NumericFeedback, step.OrderedAnswers, step.UseSynonyms, step.LinkDomain, step.RequireReply, step.ImageConstraints, stepID); err != nil {
+		return err
+	}
+	if step.AuthorNote != "" {
+		if _, err := tx.Exec(`UPDATE steps SET author_note = ? WHERE id = ?`, step.AuthorNote, stepID); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"step_images", "step_answers", "step_choices", "step_documents"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE step_id = ?`, stepID); err != nil {
+			return err
+		}
+	}
+	for i, img := range step.Images {
+		mediaType := img.MediaType
+		if mediaType == "" {
+			mediaType = models.MediaTypePhoto
+		}
+		if _, err := tx.Exec(`INSERT INTO step_images (step_id, file_id, media_type, position) VALUES (?, ?, ?, ?)`, stepID, img.FileID, mediaType, i); err != nil {
+			return err
+		}
+	}
+	for i, answer := range step.Answers {
+		if _, err := tx.Exec(`INSERT INTO step_answers (step_id, answer, position, language) VALUES (?, ?, ?, ?)`, stepID, answer, i, step.AnswerLanguages[answer]); err != nil {
+			return err
+		}
+	}
+	for i, choice := range step.Choices {
+		if _, err := tx.Exec(`INSERT INTO step_choices (step_id, text, is_correct, position) VALUES (?, ?, ?, ?)`, stepID, choice.Text, choice.IsCorrect, i); err != nil {
+			return err
+		}
+	}
+	for i, document := range step.Documents {
+		if _, err := tx.Exec(`INSERT INTO step_documents (step_id, file_id, file_name, position) VALUES (?, ?, ?, ?)`, stepID, document.FileID, document.FileName, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatQuestConfigDiff описывает изменения импорта для подтверждения администратором
+func FormatQuestConfigDiff(diff *QuestConfigDiff) string {
+	if diff.IsEmpty() {
+		return "Изменений нет — конфигурация совпадает с текущей"
+	}
+
+	var sb strings.Builder
+	writeOrders := func(label string, orders []int) {
+		if len(orders) == 0 {
+			return
+		}
+		parts := make([]string, len(orders))
+		for i, order := range orders {
+			parts[i] = fmt.Sprintf("%d", order)
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", label, strings.Join(parts, ", ")))
+	}
+	writeNames := func(label string, names []string) {
+		if len(names) == 0 {
+			return
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", label, strings.Join(names, ", ")))
+	}
+
+	writeOrders("➕ Новые шаги", diff.StepsAdded)
+	writeOrders("✏️ Изменённые шаги", diff.StepsChanged)
+	writeOrders("🗑 Удаляемые шаги", diff.StepsRemoved)
+	writeNames("➕ Новые главы", diff.ChaptersAdded)
+	writeNames("🗑 Удаляемые главы", diff.ChaptersRemoved)
+	writeNames("⚙️ Изменённые настройки", diff.SettingsChanged)
+	writeNames("↩️ Сбрасываемые настройки", diff.SettingsRemoved)
+	writeNames("🏆 Новые достижения", diff.AchievementsAdded)
+	writeNames("✏️ Изменённые достижения", diff.AchievementsChanged)
+	writeNames("⏸ Выключаемые достижения", diff.AchievementsRemoved)
+	if diff.SynonymsChanged {
+		sb.WriteString("🔤 Словарь синонимов будет обновлён\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
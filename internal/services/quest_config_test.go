@@ -0,0 +1,359 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+func newTestQuestConfigService(queue *db.DBQueue) *QuestConfigService {
+	return NewQuestConfigService(queue, db.NewStepRepository(queue), db.NewSettingsRepository(queue), db.NewAchievementRepository(queue), db.NewAnswerRepository(queue))
+}
+
+func exportedQuestConfig(t *testing.T, service *QuestConfigService) *QuestConfigBundle {
+	t.Helper()
+	data, err := service.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := ParseQuestConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle.ExportedAt = bundle.ExportedAt.UTC().Truncate(0)
+	return bundle
+}
+
+func TestQuestConfig_RoundTripIntoFreshDB(t *testing.T) {
+	source, cleanupSource := setupAchievementEngineTestDB(t)
+	defer cleanupSource()
+
+	stepRepo := db.NewStepRepository(source)
+	settingsRepo := db.NewSettingsRepository(source)
+	achievementRepo := db.NewAchievementRepository(source)
+	answerRepo := db.NewAnswerRepository(source)
+
+	chapterID, err := stepRepo.CreateChapter("Лес")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := createTestStep(t, stepRepo, 1)
+	if err := answerRepo.AddStepAnswer(first.ID, "Москва"); err != nil {
+		t.Fatal(err)
+	}
+	if err := answerRepo.AddStepAnswerWithLanguage(first.ID, "Moscow", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.AddMedia(first.ID, "gif-file", models.MediaTypeAnimation, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.UpdateHint(first.ID, "Столица", "hint-file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.SetChapter(first.ID, chapterID); err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.SetUseSynonyms(first.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	second := createTestStepWithType(t, stepRepo, 2, models.AnswerTypeChoice)
+	for _, choice := range []string{"Красный", "Синий"} {
+		if err := stepRepo.AddChoice(second.ID, choice); err != nil {
+			t.Fatal(err)
+		}
+	}
+	second, err = stepRepo.GetByID(second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.SetCorrectChoice(second.ID, second.Choices[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := stepRepo.AddDocument(second.ID, "doc-file", "карта.pdf"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := settingsRepo.SetWelcomeMessage("Привет, искатель!"); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.SetHintCooldown(30); err != nil {
+		t.Fatal(err)
+	}
+	if err := settingsRepo.Set("quest_state", "running"); err != nil {
+		t.Fatal(err)
+	}
+	if err := answerRepo.AddSynonyms("москва", []string{"мск"}); err != nil {
+		t.Fatal(err)
+	}
+
+	winner, err := achievementRepo.GetByKey("winner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	winner.Name = "Победитель квеста"
+	winner.IsActive = false
+	if err := achievementRepo.Update(winner); err != nil {
+		t.Fatal(err)
+	}
+	correct := 3
+	if err := achievementRepo.Create(&models.Achievement{
+		Key:           "custom_three",
+		Name:          "Три ответа",
+		Description:   "Ответить на три вопроса",
+		Category:      models.CategoryProgress,
+		Type:          models.TypeProgressBased,
+		Conditions:    models.AchievementConditions{CorrectAnswers: &correct},
+		IsActive:      true,
+		NotifyOnAward: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := exportedQuestConfig(t, newTestQuestConfigService(source))
+	if _, ok := exported.Settings["quest_state"]; ok {
+		t.Error("Expected instance-specific quest_state to be left out of the bundle")
+	}
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, cleanupTarget := setupAchievementEngineTestDB(t)
+	defer cleanupTarget()
+	targetService := newTestQuestConfigService(target)
+
+	// Удалённый шаг в новой базе занимает номер 1 и не должен мешать импорту
+	deleted := createTestStep(t, db.NewStepRepository(target), 1)
+	if err := db.NewStepRepository(target).SoftDelete(deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := targetService.Diff(data, QuestConfigReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.Steps[0].AnswerLanguages["moscow"] != "en" {
+		t.Errorf("Expected answer language in the bundle, got %v", exported.Steps[0].AnswerLanguages)
+	}
+	if !slices.Equal(diff.StepsAdded, []int{1, 2}) || !slices.Contains(diff.AchievementsAdded, "custom_three") ||
+		!slices.Contains(diff.AchievementsChanged, "winner") || !slices.Contains(diff.SettingsChanged, "welcome_message") {
+		t.Errorf("Unexpected diff: %+v", diff)
+	}
+
+	if _, err := targetService.Import(data, QuestConfigReplace); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := exportedQuestConfig(t, targetService)
+	imported.ExportedAt = exported.ExportedAt
+	if !reflect.DeepEqual(imported.Steps, exported.Steps) {
+		t.Errorf("Steps differ after round trip:\nexported %+v\nimported %+v", exported.Steps, imported.Steps)
+	}
+	if !reflect.DeepEqual(imported.Settings, exported.Settings) {
+		t.Errorf("Settings differ after round trip:\nexported %v\nimported %v", exported.Settings, imported.Settings)
+	}
+	if !reflect.DeepEqual(imported.Achievements, exported.Achievements) {
+		t.Errorf("Achievements differ after round trip")
+	}
+	if !reflect.DeepEqual(imported, exported) {
+		t.Errorf("Bundles differ after round trip")
+	}
+
+	state, err := db.NewSettingsRepository(target).Get("quest_state")
+	if err != nil || state != "not_started" {
+		t.Errorf("Expected target quest_state to stay not_started, got %q (err %v)", state, err)
+	}
+
+	diff, err = targetService.Diff(data, QuestConfigReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.IsEmpty() {
+		t.Errorf("Expected no changes after importing the same bundle, got %+v", diff)
+	}
+}
+
+func TestQuestConfig_MergeKeepsExistingSteps(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	service := newTestQuestConfigService(queue)
+	stepRepo := db.NewStepRepository(queue)
+	createTestStep(t, stepRepo, 1)
+	createTestStep(t, stepRepo, 2)
+
+	bundle := exportedQuestConfig(t, service)
+	bundle.Steps = []QuestConfigStep{{Order: 2, Text: "Новый текст", AnswerType: models.AnswerTypeText, IsActive: true}}
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merge, err := service.Diff(data, QuestConfigMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(merge.StepsChanged, []int{2}) || len(merge.StepsRemoved) != 0 {
+		t.Errorf("Unexpected merge diff: %+v", merge)
+	}
+	replace, err := service.Diff(data, QuestConfigReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(replace.StepsRemoved, []int{1}) {
+		t.Errorf("Expected replace to remove step 1, got %+v", replace)
+	}
+	if text := FormatQuestConfigDiff(replace); !strings.Contains(text, "🗑 Удаляемые шаги: 1") {
+		t.Errorf("Expected removed steps in diff text, got %q", text)
+	}
+
+	if _, err := service.Import(data, QuestConfigMerge); err != nil {
+		t.Fatal(err)
+	}
+	steps, err := stepRepo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0].Text != "Test step" || steps[1].Text != "Новый текст" {
+		t.Errorf("Expected merge to update step 2 and keep step 1, got %+v", steps)
+	}
+}
+
+func TestQuestConfig_ImportMovesDeletedStepsPastBundle(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	service := newTestQuestConfigService(queue)
+	stepRepo := db.NewStepRepository(queue)
+	for order := 1; order <= 2; order++ {
+		step := createTestStep(t, stepRepo, order)
+		if err := stepRepo.SoftDelete(step.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bundle := exportedQuestConfig(t, service)
+	for order := 1; order <= 3; order++ {
+		bundle.Steps = append(bundle.Steps, QuestConfigStep{Order: order, Text: "Новый шаг", AnswerType: models.AnswerTypeText, IsActive: true})
+	}
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Import(data, QuestConfigMerge); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := queue.Execute(func(sqlDB *sql.DB) (interface{}, error) {
+		rows, err := sqlDB.Query(`SELECT step_order FROM steps WHERE is_deleted = TRUE ORDER BY step_order`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var orders []int
+		for rows.Next() {
+			var order int
+			if err := rows.Scan(&order); err != nil {
+				return nil, err
+			}
+			orders = append(orders, order)
+		}
+		return orders, rows.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orders := result.([]int); !slices.Equal(orders, []int{4, 5}) {
+		t.Errorf("Expected deleted steps to move once past the imported orders, got %v", orders)
+	}
+}
+
+func TestParseQuestConfig_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"not json", "шаги", "не является бандлом"},
+		{"no version", `{"steps": []}`, "нет версии"},
+		{"newer version", `{"version": 99}`, "новее поддерживаемой"},
+		{"duplicate step", `{"version": 1, "steps": [{"order": 1}, {"order": 1}]}`, "указан в бандле дважды"},
+		{"unknown chapter", `{"version": 1, "steps": [{"order": 1, "chapter": "Лес"}]}`, "не описана"},
+		{"achievement without key", `{"version": 1, "achievements": [{"name": "Без ключа"}]}`, "нет ключа"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseQuestConfig([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestQuestConfig_AuthorNotesOnlyWithFlag(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	service := newTestQuestConfigService(queue)
+	stepRepo := db.NewStepRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+	step := createTestStep(t, stepRepo, 1)
+	if err := stepRepo.SetAuthorNote(step.ID, "Сложность: высокая"); err != nil {
+		t.Fatal(err)
+	}
+
+	withoutNotes := exportedQuestConfig(t, service)
+	if withoutNotes.Steps[0].AuthorNote != "" {
+		t.Errorf("Expected author note to be left out by default, got %q", withoutNotes.Steps[0].AuthorNote)
+	}
+	data, err := json.Marshal(withoutNotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := service.Diff(data, QuestConfigReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.IsEmpty() {
+		t.Errorf("Expected a bundle without notes to change nothing, got %+v", diff)
+	}
+	if _, err := service.Import(data, QuestConfigReplace); err != nil {
+		t.Fatal(err)
+	}
+	if note, _ := stepRepo.GetAuthorNote(step.ID); note != "Сложность: высокая" {
+		t.Errorf("Expected import without notes to keep the existing note, got %q", note)
+	}
+
+	if err := settingsRepo.SetExportAuthorNotes(true); err != nil {
+		t.Fatal(err)
+	}
+	withNotes := exportedQuestConfig(t, service)
+	if withNotes.Steps[0].AuthorNote != "Сложность: высокая" {
+		t.Fatalf("Expected author note in the bundle with the flag, got %q", withNotes.Steps[0].AuthorNote)
+	}
+	data, err = json.Marshal(withNotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, cleanupTarget := setupAchievementEngineTestDB(t)
+	defer cleanupTarget()
+	if _, err := newTestQuestConfigService(target).Import(data, QuestConfigReplace); err != nil {
+		t.Fatal(err)
+	}
+	targetSteps, err := db.NewStepRepository(target).GetAll()
+	if err != nil || len(targetSteps) != 1 {
+		t.Fatalf("Expected one imported step, got %v (err %v)", targetSteps, err)
+	}
+	if note, _ := db.NewStepRepository(target).GetAuthorNote(targetSteps[0].ID); note != "Сложность: высокая" {
+		t.Errorf("Expected author note to be imported, got %q", note)
+	}
+}
@@ -71,6 +71,7 @@ func setupTestDBForDataPreservation(t *testing.T) (*db.DBQueue, func()) {
 			step_id INTEGER,
 			status TEXT NOT NULL,
 			completed_at DATETIME,
+			review_reason TEXT DEFAULT '',
 			PRIMARY KEY (user_id, step_id)
 		);
 
@@ -80,6 +81,7 @@ func setupTestDBForDataPreservation(t *testing.T) (*db.DBQueue, func()) {
 			step_id INTEGER NOT NULL,
 			text_answer TEXT,
     		hint_used BOOLEAN DEFAULT FALSE,
+			match_rule TEXT DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		);
 
@@ -97,13 +99,24 @@ func setupTestDBForDataPreservation(t *testing.T) (*db.DBQueue, func()) {
 			last_reaction_message_id INTEGER,
 			hint_message_id INTEGER DEFAULT 0,
 			current_step_hint_used BOOLEAN DEFAULT FALSE,
-			awaiting_next_step BOOLEAN DEFAULT FALSE
+			awaiting_next_step BOOLEAN DEFAULT FALSE,
+			last_bot_message_id INTEGER DEFAULT 0,
+			last_bot_message_text TEXT DEFAULT ''
 		);
 
 		CREATE TABLE IF NOT EXISTS step_answers (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			step_id INTEGER NOT NULL,
-			answer TEXT NOT NULL
+			answer TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0
+		);
+
+		CREATE TABLE IF NOT EXISTS step_documents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			step_id INTEGER NOT NULL,
+			file_id TEXT NOT NULL,
+			file_name TEXT NOT NULL DEFAULT '',
+			position INTEGER NOT NULL DEFAULT 0
 		);
 	`)
 	if err != nil {
@@ -28,6 +28,22 @@ func (m *QuestStateMiddleware) ShouldProcessMessage(userID int64) (bool, string)
 	return false, notification
 }
 
+// IsReadOnly сообщает, что квест завершён: участники могут смотреть результаты,
+// но новые ответы не принимаются
+func (m *QuestStateMiddleware) IsReadOnly() bool {
+	state, err := m.stateManager.GetCurrentState()
+	return err == nil && state == QuestStateCompleted
+}
+
+// AllowsResults сообщает, может ли участник смотреть результаты: пока квест идёт
+// и после его завершения, но не до начала и не на паузе
+func (m *QuestStateMiddleware) AllowsResults(userID int64) bool {
+	if m.stateManager.IsUserAllowed(userID, userID == m.adminID) {
+		return true
+	}
+	return m.IsReadOnly()
+}
+
 func (m *QuestStateMiddleware) GetStateNotification() string {
 	currentState, err := m.stateManager.GetCurrentState()
 	if err != nil {
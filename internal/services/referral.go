@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+)
+
+// ReferralPayloadPrefix — префикс параметра /start в ссылке-приглашении
+const ReferralPayloadPrefix = "invite_"
+
+// ShareLinkPlaceholder заменяется в тексте «поделиться» на ссылку-приглашение
+const ShareLinkPlaceholder = "{link}"
+
+// DefaultShareMessage используется, если текст «поделиться» не задан в настройках
+const DefaultShareMessage = "🏆 Я прошёл квест! Попробуй и ты: " + ShareLinkPlaceholder
+
+type ReferralService struct {
+	referralRepo *db.ReferralRepository
+	userRepo     *db.UserRepository
+	botUsername  string
+}
+
+func NewReferralService(referralRepo *db.ReferralRepository, userRepo *db.UserRepository, botUsername string) *ReferralService {
+	return &ReferralService{
+		referralRepo: referralRepo,
+		userRepo:     userRepo,
+		botUsername:  botUsername,
+	}
+}
+
+// ParseReferralPayload извлекает ID пригласившего из параметра /start вида invite_<id>
+func ParseReferralPayload(payload string) (int64, bool) {
+	ref, ok := strings.CutPrefix(strings.TrimSpace(payload), ReferralPayloadPrefix)
+	if !ok {
+		return 0, false
+	}
+	referrerID, err := strconv.ParseInt(ref, 10, 64)
+	if err != nil || referrerID <= 0 {
+		return 0, false
+	}
+	return referrerID, true
+}
+
+// InviteLink возвращает ссылку на бота, которая отмечает userID пригласившим
+func (s *ReferralService) InviteLink(userID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", s.botUsername, ReferralPayloadPrefix, userID)
+}
+
+// ShareURL возвращает ссылку Telegram, открывающую выбор чата для пересылки приглашения
+func (s *ReferralService) ShareURL(userID int64, template string) string {
+	text := strings.TrimSpace(strings.ReplaceAll(template, ShareLinkPlaceholder, ""))
+	return "https://t.me/share/url?url=" + url.QueryEscape(s.InviteLink(userID)) + "&text=" + url.QueryEscape(text)
+}
+
+// FormatShareMessage подставляет ссылку в уже отрендеренный текст «поделиться». Если в тексте
+// нет {link}, ссылка добавляется отдельной строкой
+func FormatShareMessage(text, link string) string {
+	if strings.Contains(text, ShareLinkPlaceholder) {
+		return strings.ReplaceAll(text, ShareLinkPlaceholder, link)
+	}
+	return text + "\n\n" + link
+}
+
+// RegisterReferral засчитывает переход по ссылке-приглашению. Приглашение записывается только
+// для нового участника, впервые открывшего бота, и только если пригласивший уже участвует
+// в квесте и не приглашает сам себя
+func (s *ReferralService) RegisterReferral(payload string, referredID int64, isNewUser bool) (bool, error) {
+	referrerID, ok := ParseReferralPayload(payload)
+	if !ok || !isNewUser || referrerID == referredID {
+		return false, nil
+	}
+
+	if _, err := s.userRepo.GetByID(referrerID); err != nil {
+		return false, nil
+	}
+
+	return s.referralRepo.Create(referrerID, referredID)
+}
+
+// QualifyReferral отмечает, что приглашённый прошёл шаг, и возвращает пригласившего,
+// если приглашение засчитано этим вызовом, иначе 0
+func (s *ReferralService) QualifyReferral(referredID int64) (int64, error) {
+	return s.referralRepo.MarkQualified(referredID)
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+)
+
+func TestParseReferralPayload(t *testing.T) {
+	cases := []struct {
+		payload string
+		id      int64
+		ok      bool
+	}{
+		{"invite_42", 42, true},
+		{" invite_7 ", 7, true},
+		{"invite_", 0, false},
+		{"invite_-3", 0, false},
+		{"invite_abc", 0, false},
+		{"promo_42", 0, false},
+		{"", 0, false},
+	}
+	for _, c := range cases {
+		id, ok := ParseReferralPayload(c.payload)
+		if id != c.id || ok != c.ok {
+			t.Errorf("ParseReferralPayload(%q) = %d, %t; want %d, %t", c.payload, id, ok, c.id, c.ok)
+		}
+	}
+}
+
+func TestReferralService_RegisterReferral(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	referralRepo := db.NewReferralRepository(queue)
+	service := NewReferralService(referralRepo, userRepo, "quest_bot")
+
+	createTestUserForEngine(t, userRepo, 1)
+	createTestUserForEngine(t, userRepo, 2)
+
+	if link := service.InviteLink(1); link != "https://t.me/quest_bot?start=invite_1" {
+		t.Errorf("Unexpected invite link %q", link)
+	}
+
+	cases := []struct {
+		name      string
+		payload   string
+		referred  int64
+		isNewUser bool
+		expected  bool
+	}{
+		{"existing user", "invite_1", 2, false, false},
+		{"self invite", "invite_3", 3, true, false},
+		{"unknown referrer", "invite_99", 3, true, false},
+		{"new user", "invite_1", 3, true, true},
+		{"second referrer ignored", "invite_2", 3, true, false},
+	}
+	for _, c := range cases {
+		created, err := service.RegisterReferral(c.payload, c.referred, c.isNewUser)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if created != c.expected {
+			t.Errorf("%s: expected created=%t, got %t", c.name, c.expected, created)
+		}
+	}
+
+	referrerID, err := referralRepo.GetReferrer(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if referrerID != 1 {
+		t.Errorf("Expected user 3 to be attributed to user 1, got %d", referrerID)
+	}
+}
+
+func TestCheckRecruiterAchievement_Threshold(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	referralRepo := db.NewReferralRepository(queue)
+	engine := NewAchievementEngine(achievementRepo, userRepo, db.NewProgressRepository(queue), db.NewStepRepository(queue), queue)
+	service := NewReferralService(referralRepo, userRepo, "quest_bot")
+
+	createTestUserForEngine(t, userRepo, 1)
+	for referredID := int64(2); referredID <= 1+DefaultRecruiterReferrals; referredID++ {
+		if _, err := service.RegisterReferral("invite_1", referredID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Приглашённый, не прошедший ни одного шага, не засчитывается
+	awarded, err := engine.CheckRecruiterAchievement(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awarded) != 0 {
+		t.Fatalf("Expected no award before referrals qualify, got %v", awarded)
+	}
+
+	for referredID := int64(2); referredID <= 1+DefaultRecruiterReferrals; referredID++ {
+		referrerID, err := service.QualifyReferral(referredID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if referrerID != 1 {
+			t.Fatalf("Expected referral of %d to qualify for user 1, got %d", referredID, referrerID)
+		}
+
+		awarded, err := engine.CheckRecruiterAchievement(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reached := referredID == 1+DefaultRecruiterReferrals
+		if reached != (len(awarded) == 1 && awarded[0] == "recruiter") {
+			t.Errorf("After %d qualified referrals: got %v", referredID-1, awarded)
+		}
+	}
+
+	// Повторный шаг приглашённого не засчитывает приглашение ещё раз
+	referrerID, err := service.QualifyReferral(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if referrerID != 0 {
+		t.Errorf("Expected repeated qualification to be ignored, got referrer %d", referrerID)
+	}
+}
+
+func TestFormatShareMessage(t *testing.T) {
+	link := "https://t.me/quest_bot?start=invite_1"
+	if got := FormatShareMessage("Попробуй: {link}", link); got != "Попробуй: "+link {
+		t.Errorf("Unexpected share message %q", got)
+	}
+	if got := FormatShareMessage("Попробуй!", link); got != "Попробуй!\n\n"+link {
+		t.Errorf("Expected link appended, got %q", got)
+	}
+}
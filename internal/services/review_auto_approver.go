@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+// AutoApproval описывает ответ, одобренный автоматически по истечении времени ожидания
+type AutoApproval struct {
+	Review     *models.PendingReview
+	Step       *models.Step
+	IsLastStep bool
+	Awarded    []string
+}
+
+type ReviewAutoApprover struct {
+	progressRepo      *db.ProgressRepository
+	stepRepo          *db.StepRepository
+	settingsRepo      *db.SettingsRepository
+	achievementEngine *AchievementEngine
+	stateResolver     *StateResolver
+}
+
+func NewReviewAutoApprover(
+	progressRepo *db.ProgressRepository,
+	stepRepo *db.StepRepository,
+	settingsRepo *db.SettingsRepository,
+	achievementEngine *AchievementEngine,
+) *ReviewAutoApprover {
+	return &ReviewAutoApprover{
+		progressRepo:      progressRepo,
+		stepRepo:          stepRepo,
+		settingsRepo:      settingsRepo,
+		achievementEngine: achievementEngine,
+	}
+}
+
+// SetStateResolver подключает определение состояния участника: с ним ответ, после которого
+// одобрено минимальное для прохождения число шагов, завершает квест, как последний шаг
+func (a *ReviewAutoApprover) SetStateResolver(stateResolver *StateResolver) {
+	a.stateResolver = stateResolver
+}
+
+// FilterStaleReviews оставляет только ответы, ожидающие проверки не меньше timeout
+func FilterStaleReviews(reviews []*models.PendingReview, timeout time.Duration, now time.Time) []*models.PendingReview {
+	var stale []*models.PendingReview
+	for _, review := range reviews {
+		if now.Sub(review.SubmittedAt) >= timeout {
+			stale = append(stale, review)
+		}
+	}
+	return stale
+}
+
+// ApproveStale одобряет ответы, ожидающие проверки дольше настроенного времени,
+// и начисляет достижения так же, как при ручном одобрении
+func (a *ReviewAutoApprover) ApproveStale(now time.Time) ([]*AutoApproval, error) {
+	minutes, err := a.settingsRepo.GetAutoApproveMinutes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auto approve timeout: %w", err)
+	}
+	if minutes <= 0 {
+		return nil, nil
+	}
+
+	reviews, err := a.progressRepo.GetPendingReviews()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending reviews: %w", err)
+	}
+
+	var approvals []*AutoApproval
+	for _, review := range FilterStaleReviews(reviews, time.Duration(minutes)*time.Minute, now) {
+		approval, err := a.approve(review)
+		if err != nil {
+			log.Printf("[AUTO_APPROVE] Failed to approve user %d step %d: %v", review.UserID, review.StepID, err)
+			continue
+		}
+		if approval != nil {
+			approvals = append(approvals, approval)
+		}
+	}
+	return approvals, nil
+}
+
+func (a *ReviewAutoApprover) approve(review *models.PendingReview) (*AutoApproval, error) {
+	step, err := a.stepRepo.GetByID(review.StepID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Админ мог успеть проверить ответ между выборкой и одобрением: статус меняется
+	// одним запросом только у ответа, который всё ещё ждёт проверки
+	approved, err := a.progressRepo.ApproveWaitingReview(review.UserID, review.StepID)
+	if err != nil {
+		return nil, err
+	}
+	if !approved {
+		return nil, nil
+	}
+
+	nextStep, _ := a.stepRepo.GetNextActive(step.StepOrder, review.UserID)
+	approval := &AutoApproval{
+		Review:     review,
+		Step:       step,
+		IsLastStep: nextStep == nil,
+	}
+	if !approval.IsLastStep && a.stateResolver != nil {
+		approval.IsLastStep, _ = a.stateResolver.MinCompletionReached(review.UserID)
+	}
+
+	if a.achievementEngine != nil {
+		if approval.IsLastStep {
+			if confirm, _ := a.settingsRepo.GetCompletionConfirmation(); confirm {
+				if err := a.achievementEngine.MarkCompletionPending(review.UserID); err != nil {
+					log.Printf("[AUTO_APPROVE] Failed to defer completion for user %d: %v", review.UserID, err)
+				}
+			}
+		}
+		approval.Awarded = a.achievementEngine.EvaluateOnApproval(review.UserID, step.ID, approval.IsLastStep, review.SubmittedAt)
+	}
+
+	return approval, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+func TestFilterStaleReviews(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	reviews := []*models.PendingReview{
+		{UserID: 1, StepID: 1, SubmittedAt: now.Add(-2 * time.Hour)},
+		{UserID: 2, StepID: 1, SubmittedAt: now.Add(-30 * time.Minute)},
+		{UserID: 3, StepID: 1, SubmittedAt: now.Add(-time.Hour)},
+	}
+
+	stale := FilterStaleReviews(reviews, time.Hour, now)
+	if len(stale) != 2 {
+		t.Fatalf("Expected 2 stale reviews, got %d", len(stale))
+	}
+	if stale[0].UserID != 1 || stale[1].UserID != 3 {
+		t.Errorf("Unexpected stale reviews: %d, %d", stale[0].UserID, stale[1].UserID)
+	}
+}
+
+func TestReviewAutoApprover_ApproveStale(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	settingsRepo := db.NewSettingsRepository(queue)
+
+	engine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	approver := NewReviewAutoApprover(progressRepo, stepRepo, settingsRepo, engine)
+
+	step1 := createTestStepWithType(t, stepRepo, 1, models.AnswerTypeImage)
+	createTestStep(t, stepRepo, 2)
+
+	now := time.Now()
+	createTestUserForEngine(t, userRepo, 1001)
+	createTestUserForEngine(t, userRepo, 1002)
+
+	createUserProgress(t, progressRepo, 1001, step1.ID, models.StatusWaitingReview, nil)
+	createUserAnswer(t, queue, 1001, step1.ID, false, now.Add(-2*time.Hour))
+	createUserProgress(t, progressRepo, 1002, step1.ID, models.StatusWaitingReview, nil)
+	createUserAnswer(t, queue, 1002, step1.ID, false, now.Add(-3*time.Hour))
+	createUserAnswer(t, queue, 1002, step1.ID, false, now.Add(-10*time.Minute))
+
+	approvals, err := approver.ApproveStale(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(approvals) != 0 {
+		t.Fatalf("Expected no approvals while auto approval is disabled, got %d", len(approvals))
+	}
+
+	if err := settingsRepo.SetAutoApproveMinutes(60); err != nil {
+		t.Fatal(err)
+	}
+
+	approvals, err = approver.ApproveStale(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(approvals) != 1 {
+		t.Fatalf("Expected 1 approval, got %d", len(approvals))
+	}
+
+	approval := approvals[0]
+	if approval.Review.UserID != 1001 {
+		t.Errorf("Expected user 1001 to be approved, got %d", approval.Review.UserID)
+	}
+	if approval.IsLastStep {
+		t.Error("Expected step 1 not to be the last step")
+	}
+
+	hasPioneer := false
+	for _, key := range approval.Awarded {
+		if key == "pioneer" {
+			hasPioneer = true
+		}
+	}
+	if !hasPioneer {
+		t.Errorf("Expected pioneer achievement to be awarded, got %v", approval.Awarded)
+	}
+
+	progress, err := progressRepo.GetByUserAndStep(1001, step1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != models.StatusApproved {
+		t.Errorf("Expected status approved, got %s", progress.Status)
+	}
+
+	// Последний ответ пользователя 1002 отправлен недавно, поэтому он остаётся на проверке
+	progress, err = progressRepo.GetByUserAndStep(1002, step1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != models.StatusWaitingReview {
+		t.Errorf("Expected status waiting_review, got %s", progress.Status)
+	}
+
+	approvals, err = approver.ApproveStale(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(approvals) != 0 {
+		t.Errorf("Expected no repeated approvals, got %d", len(approvals))
+	}
+}
@@ -12,12 +12,16 @@ type UserState struct {
 	CurrentStep *models.Step
 	Status      models.ProgressStatus
 	IsCompleted bool
+	// DeactivatedStepIDs — незавершённые шаги участника, которые админ отключил или удалил.
+	// Такие шаги пропускаются, а участник мог остановиться на одном из них
+	DeactivatedStepIDs []int64
 }
 
 type StateResolver struct {
 	stepRepo     *db.StepRepository
 	progressRepo *db.ProgressRepository
 	userRepo     *db.UserRepository
+	settingsRepo *db.SettingsRepository
 }
 
 func NewStateResolver(stepRepo *db.StepRepository, progressRepo *db.ProgressRepository, userRepo *db.UserRepository) *StateResolver {
@@ -28,17 +32,55 @@ func NewStateResolver(stepRepo *db.StepRepository, progressRepo *db.ProgressRepo
 	}
 }
 
-func (r *StateResolver) ResolveState(userID int64) (*UserState, error) {
+// SetSettingsRepository подключает настройки квеста: с ними квест считается пройденным,
+// как только участник одобрил минимальное число шагов из настроек
+func (r *StateResolver) SetSettingsRepository(settingsRepo *db.SettingsRepository) {
+	r.settingsRepo = settingsRepo
+}
+
+// minCompletionReached сообщает, что участник одобрил столько активных шагов, сколько
+// достаточно для прохождения по настройке min_completion_steps. Если минимум не задан
+// или не меньше числа активных шагов, нужно пройти все шаги и проверка не срабатывает
+func (r *StateResolver) minCompletionReached(activeSteps []*models.Step, userProgress []*models.UserProgress) bool {
+	if r.settingsRepo == nil {
+		return false
+	}
+	minSteps, err := r.settingsRepo.GetMinCompletionSteps()
+	if err != nil || minSteps <= 0 || minSteps >= len(activeSteps) {
+		return false
+	}
+
+	activeStepIDs := make(map[int64]bool, len(activeSteps))
+	for _, step := range activeSteps {
+		activeStepIDs[step.ID] = true
+	}
+	approved := 0
+	for _, p := range userProgress {
+		if p.Status == models.StatusApproved && activeStepIDs[p.StepID] {
+			approved++
+		}
+	}
+	return approved >= minSteps
+}
+
+// MinCompletionReached сообщает, что участник прошёл квест досрочно, одобрив минимальное
+// число шагов из настроек
+func (r *StateResolver) MinCompletionReached(userID int64) (bool, error) {
 	activeSteps, err := r.stepRepo.GetActive()
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	userProgress, err := r.progressRepo.GetUserProgress(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
 	}
+	return r.minCompletionReached(activeSteps, userProgress), nil
+}
 
-	if len(activeSteps) == 0 {
-		return &UserState{
-			UserID:      userID,
-			IsCompleted: true,
-		}, nil
+func (r *StateResolver) ResolveState(userID int64) (*UserState, error) {
+	activeSteps, err := r.stepRepo.GetActive()
+	if err != nil {
+		return nil, err
 	}
 
 	userProgress, err := r.progressRepo.GetUserProgress(userID)
@@ -46,15 +88,31 @@ func (r *StateResolver) ResolveState(userID int64) (*UserState, error) {
 		return nil, err
 	}
 
+	activeStepIDs := make(map[int64]bool, len(activeSteps))
+	for _, step := range activeSteps {
+		activeStepIDs[step.ID] = true
+	}
+
 	completedSteps := make(map[int64]bool)
 	progressByStep := make(map[int64]*models.UserProgress)
+	var deactivated []int64
 	for _, p := range userProgress {
 		progressByStep[p.StepID] = p
 		if p.Status == models.StatusApproved || p.Status == models.StatusSkipped {
 			completedSteps[p.StepID] = true
+		} else if !activeStepIDs[p.StepID] {
+			deactivated = append(deactivated, p.StepID)
 		}
 	}
 
+	if r.minCompletionReached(activeSteps, userProgress) {
+		return &UserState{
+			UserID:             userID,
+			IsCompleted:        true,
+			DeactivatedStepIDs: deactivated,
+		}, nil
+	}
+
 	for _, step := range activeSteps {
 		if completedSteps[step.ID] {
 			continue
@@ -66,15 +124,176 @@ func (r *StateResolver) ResolveState(userID int64) (*UserState, error) {
 		}
 
 		return &UserState{
-			UserID:      userID,
-			CurrentStep: step,
-			Status:      status,
-			IsCompleted: false,
+			UserID:             userID,
+			CurrentStep:        step,
+			Status:             status,
+			IsCompleted:        false,
+			DeactivatedStepIDs: deactivated,
 		}, nil
 	}
 
 	return &UserState{
-		UserID:      userID,
-		IsCompleted: true,
+		UserID:             userID,
+		IsCompleted:        true,
+		DeactivatedStepIDs: deactivated,
 	}, nil
 }
+
+// RemainingSteps — сколько активных шагов участник уже прошёл и сколько ему осталось
+type RemainingSteps struct {
+	Total     int
+	Completed int
+	Remaining int
+	Started   bool
+}
+
+func (r *RemainingSteps) IsCompleted() bool {
+	return r.Remaining == 0
+}
+
+// GetRemainingSteps считает оставшиеся активные шаги участника, не раскрывая их содержимого.
+// Пройденными считаются одобренные и пропущенные шаги
+func (r *StateResolver) GetRemainingSteps(userID int64) (*RemainingSteps, error) {
+	activeSteps, err := r.stepRepo.GetActive()
+	if err != nil {
+		return nil, err
+	}
+
+	userProgress, err := r.progressRepo.GetUserProgress(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	completedSteps := make(map[int64]bool)
+	for _, p := range userProgress {
+		if p.Status == models.StatusApproved || p.Status == models.StatusSkipped {
+			completedSteps[p.StepID] = true
+		}
+	}
+
+	result := &RemainingSteps{
+		Total:   len(activeSteps),
+		Started: len(userProgress) > 0,
+	}
+	for _, step := range activeSteps {
+		if completedSteps[step.ID] {
+			result.Completed++
+		}
+	}
+	result.Remaining = result.Total - result.Completed
+	return result, nil
+}
+
+// QuestMap — обзор квеста для участника без раскрытия заданий: место участника,
+// главы и необязательные шаги, до которых он дошёл
+type QuestMap struct {
+	Total     int
+	Completed int
+	// Position — номер текущего шага среди активных, 0 — квест не начат или пройден
+	Position int
+	Started  bool
+	Finished bool
+	Chapters []QuestMapChapter
+	// Optional — необязательные шаги, до которых участник уже дошёл
+	Optional []QuestMapStep
+	// OptionalAhead — сколько необязательных шагов ещё впереди
+	OptionalAhead int
+}
+
+// QuestMapChapter — глава на карте. Название нераскрытой главы участнику не показывается
+type QuestMapChapter struct {
+	Number    int
+	Title     string
+	Revealed  bool
+	Current   bool
+	Completed int
+	Total     int
+}
+
+// QuestMapStep — необязательный шаг на карте и его статус у участника
+type QuestMapStep struct {
+	Number int
+	Status models.ProgressStatus
+}
+
+// GetQuestMap строит карту квеста участника. Глава считается раскрытой, если участник дошёл
+// хотя бы до одного её шага
+func (r *StateResolver) GetQuestMap(userID int64) (*QuestMap, error) {
+	activeSteps, err := r.stepRepo.GetActive()
+	if err != nil {
+		return nil, err
+	}
+
+	userProgress, err := r.progressRepo.GetUserProgress(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	statusByStep := make(map[int64]models.ProgressStatus)
+	for _, p := range userProgress {
+		statusByStep[p.StepID] = p.Status
+	}
+
+	questMap := &QuestMap{
+		Total:   len(activeSteps),
+		Started: len(userProgress) > 0,
+	}
+
+	state, err := r.ResolveState(userID)
+	if err != nil {
+		return nil, err
+	}
+	questMap.Finished = state.IsCompleted && questMap.Total > 0
+
+	reached := make(map[int64]bool)
+	currentChapterID := int64(-1)
+	for i, step := range activeSteps {
+		status := statusByStep[step.ID]
+		if status == models.StatusApproved || status == models.StatusSkipped {
+			questMap.Completed++
+		}
+
+		isCurrent := questMap.Started && state.CurrentStep != nil && state.CurrentStep.ID == step.ID
+		if isCurrent {
+			questMap.Position = i + 1
+			currentChapterID = step.ChapterID
+		}
+
+		isReached := questMap.Finished || (questMap.Started && (questMap.Position == 0 || isCurrent))
+		if isReached {
+			reached[step.ChapterID] = true
+		}
+
+		if !step.IsAsterisk {
+			continue
+		}
+		if isReached {
+			if status == "" {
+				status = models.StatusPending
+			}
+			questMap.Optional = append(questMap.Optional, QuestMapStep{Number: i + 1, Status: status})
+		} else {
+			questMap.OptionalAhead++
+		}
+	}
+
+	chapters, err := r.stepRepo.GetChapterProgress(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, chapter := range chapters {
+		mapChapter := QuestMapChapter{
+			Number:    chapter.Number,
+			Revealed:  reached[chapter.Chapter.ID],
+			Current:   chapter.Chapter.ID == currentChapterID,
+			Completed: chapter.Completed,
+			Total:     chapter.Total,
+		}
+		if mapChapter.Revealed {
+			mapChapter.Title = chapter.Chapter.Title
+		}
+		questMap.Chapters = append(questMap.Chapters, mapChapter)
+	}
+
+	return questMap, nil
+}
@@ -23,7 +23,8 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			last_name TEXT,
 			username TEXT,
 			is_blocked BOOLEAN DEFAULT FALSE,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			pending_completion BOOLEAN DEFAULT FALSE
 		)
 	`)
 	if err != nil {
@@ -42,6 +43,18 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			hint_text TEXT DEFAULT '',
 			hint_image TEXT DEFAULT '',
 			is_asterisk BOOLEAN DEFAULT FALSE,
+			location_lat REAL DEFAULT 0,
+			location_lng REAL DEFAULT 0,
+			location_radius INTEGER DEFAULT 0,
+			required_answers INTEGER DEFAULT 0,
+			chapter_id INTEGER DEFAULT 0,
+			numeric_feedback BOOLEAN DEFAULT FALSE,
+			ordered_answers BOOLEAN DEFAULT FALSE,
+			use_synonyms BOOLEAN DEFAULT FALSE,
+			link_domain TEXT DEFAULT '',
+			require_reply BOOLEAN DEFAULT FALSE,
+			image_constraints TEXT DEFAULT '',
+			author_note TEXT DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -53,7 +66,11 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			step_id INTEGER NOT NULL REFERENCES steps(id),
 			file_id TEXT NOT NULL,
-			position INTEGER NOT NULL DEFAULT 0
+			media_type TEXT NOT NULL DEFAULT 'photo',
+			position INTEGER NOT NULL DEFAULT 0,
+			width INTEGER DEFAULT 0,
+			height INTEGER DEFAULT 0,
+			file_size INTEGER DEFAULT 0
 		)
 	`)
 	if err != nil {
@@ -63,7 +80,23 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 		CREATE TABLE IF NOT EXISTS step_answers (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			step_id INTEGER NOT NULL REFERENCES steps(id),
-			answer TEXT NOT NULL
+			answer TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			language TEXT DEFAULT ''
+		);
+
+		CREATE TABLE IF NOT EXISTS answer_synonyms (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			synonym TEXT NOT NULL UNIQUE,
+			canonical TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS step_documents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			step_id INTEGER NOT NULL REFERENCES steps(id),
+			file_id TEXT NOT NULL,
+			file_name TEXT NOT NULL DEFAULT '',
+			position INTEGER NOT NULL DEFAULT 0
 		)
 	`)
 	if err != nil {
@@ -75,6 +108,7 @@ func setupTestDB(t *testing.T) (*db.DBQueue, func()) {
 			step_id INTEGER NOT NULL REFERENCES steps(id),
 			status TEXT NOT NULL DEFAULT 'pending',
 			completed_at DATETIME,
+			review_reason TEXT DEFAULT '',
 			PRIMARY KEY (user_id, step_id)
 		)
 	`)
@@ -282,3 +316,246 @@ func TestStateResolver_SkippedStepsNotReturnedAsCurrent(t *testing.T) {
 		t.Errorf("Expected current step order to be 2, got %d", state.CurrentStep.StepOrder)
 	}
 }
+
+func TestStateResolver_DeactivatedSteps(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	resolver := NewStateResolver(stepRepo, progressRepo, userRepo)
+
+	userID := int64(12345)
+
+	var stepIDs []int64
+	for order := 1; order <= 3; order++ {
+		id, err := stepRepo.Create(&models.Step{StepOrder: order, Text: "Step", AnswerType: models.AnswerTypeText, IsActive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stepIDs = append(stepIDs, id)
+	}
+
+	if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepIDs[0], Status: models.StatusApproved}); err != nil {
+		t.Fatal(err)
+	}
+	if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepIDs[1], Status: models.StatusPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("inactive current step is skipped", func(t *testing.T) {
+		if err := stepRepo.SetActive(stepIDs[1], false); err != nil {
+			t.Fatal(err)
+		}
+
+		state, err := resolver.ResolveState(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.IsCompleted || state.CurrentStep == nil || state.CurrentStep.ID != stepIDs[2] {
+			t.Fatalf("Expected step 3 to become current, got %+v", state)
+		}
+		if len(state.DeactivatedStepIDs) != 1 || state.DeactivatedStepIDs[0] != stepIDs[1] {
+			t.Errorf("Expected step 2 to be reported as deactivated, got %v", state.DeactivatedStepIDs)
+		}
+	})
+
+	t.Run("completed when no active steps remain ahead", func(t *testing.T) {
+		if err := stepRepo.SoftDelete(stepIDs[2]); err != nil {
+			t.Fatal(err)
+		}
+
+		state, err := resolver.ResolveState(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !state.IsCompleted {
+			t.Fatalf("Expected the quest to be completed, got current step %+v", state.CurrentStep)
+		}
+		if len(state.DeactivatedStepIDs) != 1 {
+			t.Errorf("Expected the abandoned step to be reported, got %v", state.DeactivatedStepIDs)
+		}
+	})
+
+	t.Run("finished steps are not reported", func(t *testing.T) {
+		if err := stepRepo.SetActive(stepIDs[0], false); err != nil {
+			t.Fatal(err)
+		}
+		if err := progressRepo.DeleteStepProgress(userID, stepIDs[1]); err != nil {
+			t.Fatal(err)
+		}
+
+		state, err := resolver.ResolveState(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !state.IsCompleted || len(state.DeactivatedStepIDs) != 0 {
+			t.Errorf("Expected a clean completed state, got %+v", state)
+		}
+	})
+}
+
+func TestGetRemainingSteps(t *testing.T) {
+	queue, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	resolver := NewStateResolver(stepRepo, progressRepo, userRepo)
+
+	var stepIDs []int64
+	for i := 1; i <= 3; i++ {
+		id, err := stepRepo.Create(&models.Step{
+			StepOrder:  i,
+			Text:       "Step",
+			AnswerType: models.AnswerTypeText,
+			IsActive:   true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stepIDs = append(stepIDs, id)
+	}
+
+	addProgress := func(userID, stepID int64, status models.ProgressStatus) {
+		if err := progressRepo.Create(&models.UserProgress{UserID: userID, StepID: stepID, Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Пользователь 1 в середине квеста: один шаг одобрен, один пропущен
+	addProgress(1, stepIDs[0], models.StatusApproved)
+	addProgress(1, stepIDs[1], models.StatusSkipped)
+	addProgress(1, stepIDs[2], models.StatusPending)
+
+	// Пользователь 2 прошёл все шаги
+	for _, stepID := range stepIDs {
+		addProgress(2, stepID, models.StatusApproved)
+	}
+
+	tests := []struct {
+		name      string
+		userID    int64
+		remaining int
+		started   bool
+	}{
+		{"mid-quest", 1, 1, true},
+		{"completed", 2, 0, true},
+		{"not started", 3, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolver.GetRemainingSteps(tt.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Total != 3 || result.Remaining != tt.remaining || result.Started != tt.started {
+				t.Errorf("Unexpected remaining steps: %+v", result)
+			}
+			if result.IsCompleted() != (tt.remaining == 0) {
+				t.Errorf("IsCompleted() = %v for %+v", result.IsCompleted(), result)
+			}
+		})
+	}
+}
+
+func TestGetQuestMap(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	resolver := NewStateResolver(stepRepo, progressRepo, userRepo)
+
+	var chapterIDs []int64
+	for _, title := range []string{"Лес", "Река", "Пещера"} {
+		id, err := stepRepo.CreateChapter(title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chapterIDs = append(chapterIDs, id)
+	}
+
+	// Шаги 2, 3 и 5 необязательные; главы: Лес — шаги 1-3, Река — 4, Пещера — 5
+	layout := []struct {
+		chapter  int
+		asterisk bool
+	}{{0, false}, {0, true}, {0, true}, {1, false}, {2, true}}
+	var stepIDs []int64
+	for i, l := range layout {
+		id, err := stepRepo.Create(&models.Step{
+			StepOrder:  i + 1,
+			Text:       "Секретное задание",
+			AnswerType: models.AnswerTypeText,
+			IsActive:   true,
+			IsAsterisk: l.asterisk,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stepRepo.SetChapter(id, chapterIDs[l.chapter]); err != nil {
+			t.Fatal(err)
+		}
+		stepIDs = append(stepIDs, id)
+	}
+
+	createTestUserForEngine(t, userRepo, 1)
+	for i, status := range []models.ProgressStatus{models.StatusApproved, models.StatusApproved, models.StatusSkipped} {
+		if err := progressRepo.Create(&models.UserProgress{UserID: 1, StepID: stepIDs[i], Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	questMap, err := resolver.GetQuestMap(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if questMap.Total != 5 || questMap.Completed != 3 || questMap.Position != 4 || !questMap.Started || questMap.Finished {
+		t.Errorf("Unexpected position: %+v", questMap)
+	}
+
+	expectedOptional := []QuestMapStep{{Number: 2, Status: models.StatusApproved}, {Number: 3, Status: models.StatusSkipped}}
+	if len(questMap.Optional) != len(expectedOptional) {
+		t.Fatalf("Expected %d reached optional steps, got %+v", len(expectedOptional), questMap.Optional)
+	}
+	for i, step := range expectedOptional {
+		if questMap.Optional[i] != step {
+			t.Errorf("Optional step %d: expected %+v, got %+v", i, step, questMap.Optional[i])
+		}
+	}
+	if questMap.OptionalAhead != 1 {
+		t.Errorf("Expected 1 optional step ahead, got %d", questMap.OptionalAhead)
+	}
+
+	expectedChapters := []QuestMapChapter{
+		{Number: 1, Title: "Лес", Revealed: true, Completed: 3, Total: 3},
+		{Number: 2, Title: "Река", Revealed: true, Current: true, Completed: 0, Total: 1},
+		{Number: 3, Completed: 0, Total: 1},
+	}
+	if len(questMap.Chapters) != len(expectedChapters) {
+		t.Fatalf("Expected %d chapters, got %+v", len(expectedChapters), questMap.Chapters)
+	}
+	for i, chapter := range expectedChapters {
+		if questMap.Chapters[i] != chapter {
+			t.Errorf("Chapter %d: expected %+v, got %+v", i, chapter, questMap.Chapters[i])
+		}
+	}
+
+	notStarted, err := resolver.GetQuestMap(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notStarted.Started || notStarted.Position != 0 || len(notStarted.Optional) != 0 || notStarted.OptionalAhead != 3 {
+		t.Errorf("Expected nothing revealed before start, got %+v", notStarted)
+	}
+	for _, chapter := range notStarted.Chapters {
+		if chapter.Revealed || chapter.Title != "" {
+			t.Errorf("Expected chapter %d to stay hidden before start, got %+v", chapter.Number, chapter)
+		}
+	}
+}
@@ -50,6 +50,8 @@ type StatisticsService struct {
 	progressRepo    *db.ProgressRepository
 	userRepo        *db.UserRepository
 	achievementRepo *db.AchievementRepository
+	settingsRepo    *db.SettingsRepository
+	cache           *statsCache
 }
 
 func NewStatisticsService(queue *db.DBQueue, stepRepo *db.StepRepository, progressRepo *db.ProgressRepository, userRepo *db.UserRepository) *StatisticsService {
@@ -59,6 +61,7 @@ func NewStatisticsService(queue *db.DBQueue, stepRepo *db.StepRepository, progre
 		progressRepo:    progressRepo,
 		userRepo:        userRepo,
 		achievementRepo: nil,
+		cache:           newStatsCache(),
 	}
 }
 
@@ -69,10 +72,26 @@ func NewStatisticsServiceWithAchievements(queue *db.DBQueue, stepRepo *db.StepRe
 		progressRepo:    progressRepo,
 		userRepo:        userRepo,
 		achievementRepo: achievementRepo,
+		cache:           newStatsCache(),
 	}
 }
 
+// SetCacheTTL включает кэширование тяжёлых запросов статистики на время ttl.
+// Нулевой ttl выключает кэш — так сервис работает по умолчанию
+func (s *StatisticsService) SetCacheTTL(ttl time.Duration) {
+	s.cache.setTTL(ttl)
+}
+
+// InvalidateCache сбрасывает закэшированную статистику, следующий запрос пересчитает её
+func (s *StatisticsService) InvalidateCache() {
+	s.cache.invalidate()
+}
+
 func (s *StatisticsService) CalculateStats() (*Statistics, error) {
+	return cachedStats(s, "step_stats", s.calculateStats)
+}
+
+func (s *StatisticsService) calculateStats() (*Statistics, error) {
 	steps, err := s.stepRepo.GetActive()
 	if err != nil {
 		return nil, err
@@ -233,6 +252,37 @@ func (s *StatisticsService) GetUserLeaderboardPosition(userID int64) (int, int,
 	return results[0], results[1], nil
 }
 
+// SetSettingsRepository подключает настройки квеста: с ними место в порядке прохождения
+// учитывает минимальное число шагов для прохождения
+func (s *StatisticsService) SetSettingsRepository(settingsRepo *db.SettingsRepository) {
+	s.settingsRepo = settingsRepo
+}
+
+// GetUserCompletionPosition возвращает место участника в порядке прохождения квеста —
+// так же, как оно определяется для достижений победителей. 0 — участник квест не прошёл
+func (s *StatisticsService) GetUserCompletionPosition(userID int64) (int, error) {
+	minSteps := 0
+	if s.settingsRepo != nil {
+		var err error
+		if minSteps, err = s.settingsRepo.GetMinCompletionSteps(); err != nil {
+			return 0, err
+		}
+	}
+	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		return queryQuestCompletions(db, minSteps)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i, completion := range result.([]UserCompletion) {
+		if completion.UserID == userID {
+			return i + 1, nil
+		}
+	}
+	return 0, nil
+}
+
 func (s *StatisticsService) GetUserProgress(userID int64) (int, int, float64, error) {
 	activeCount, err := s.stepRepo.GetActiveStepsCount()
 	if err != nil {
@@ -252,6 +302,67 @@ func (s *StatisticsService) GetUserProgress(userID int64) (int, int, float64, er
 	return answeredCount, activeCount, percentage, nil
 }
 
+// CalculateScore считает очки: каждый пройденный шаг даёт pointsPerStep,
+// подсказка на шаге снижает его стоимость на hintPenalty, но не ниже нуля
+func CalculateScore(approvedSteps, hintedSteps, pointsPerStep, hintPenalty int) int {
+	if hintedSteps > approvedSteps {
+		hintedSteps = approvedSteps
+	}
+	hintedStepPoints := pointsPerStep - hintPenalty
+	if hintedStepPoints < 0 {
+		hintedStepPoints = 0
+	}
+	return (approvedSteps-hintedSteps)*pointsPerStep + hintedSteps*hintedStepPoints
+}
+
+func (s *StatisticsService) GetUserScore(userID int64, pointsPerStep, hintPenalty int) (int, error) {
+	scores, err := s.getScores(userID, pointsPerStep, hintPenalty)
+	if err != nil {
+		return 0, err
+	}
+	return scores[userID], nil
+}
+
+func (s *StatisticsService) GetScores(pointsPerStep, hintPenalty int) (map[int64]int, error) {
+	return s.getScores(0, pointsPerStep, hintPenalty)
+}
+
+func (s *StatisticsService) getScores(userID int64, pointsPerStep, hintPenalty int) (map[int64]int, error) {
+	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		rows, err := db.Query(`
+			SELECT p.user_id,
+			       COUNT(*) as approved_steps,
+			       SUM(CASE WHEN EXISTS (
+			           SELECT 1 FROM user_answers a
+			           WHERE a.user_id = p.user_id AND a.step_id = p.step_id AND a.hint_used = TRUE
+			       ) THEN 1 ELSE 0 END) as hinted_steps
+			FROM user_progress p
+			JOIN steps st ON p.step_id = st.id AND st.is_active = TRUE AND st.is_deleted = FALSE
+			WHERE p.status = 'approved' AND (? = 0 OR p.user_id = ?)
+			GROUP BY p.user_id
+		`, userID, userID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		scores := make(map[int64]int)
+		for rows.Next() {
+			var id int64
+			var approved, hinted int
+			if err := rows.Scan(&id, &approved, &hinted); err != nil {
+				return nil, err
+			}
+			scores[id] = CalculateScore(approved, hinted, pointsPerStep, hintPenalty)
+		}
+		return scores, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[int64]int), nil
+}
+
 func (s *StatisticsService) GetUserAchievementCount(userID int64) (int, error) {
 	if s.achievementRepo == nil {
 		return 0, nil
@@ -274,6 +385,10 @@ func (s *StatisticsService) GetUserAsteriskStats(userID int64) (answered int, to
 }
 
 func (s *StatisticsService) GetAsteriskStepsStats() ([]AsteriskStepStats, error) {
+	return cachedStats(s, "asterisk_steps", s.getAsteriskStepsStats)
+}
+
+func (s *StatisticsService) getAsteriskStepsStats() ([]AsteriskStepStats, error) {
 	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
 			SELECT 
@@ -525,18 +640,28 @@ func (s *StatisticsService) getUserDetailedAnswerStats(userID int64) (int, int,
 	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		var totalAnswers, hintsUsed int
 		var firstTimeStr, lastTimeStr sql.NullString
+		// Ответы, удалённые по сроку хранения, учитываются по сохранённым счётчикам
 		err := db.QueryRow(`
-			SELECT 
-				COUNT(*), 
-				COALESCE(SUM(CASE WHEN ua.hint_used = 1 THEN 1 ELSE 0 END), 0),
-				MIN(ua.created_at),
-				MAX(ua.created_at)
-			FROM user_answers ua
-			WHERE ua.user_id = ?
-			AND NOT EXISTS (
+			SELECT
+				COALESCE(SUM(answers), 0),
+				COALESCE(SUM(hints), 0),
+				MIN(first_at),
+				MAX(last_at)
+			FROM (
+				SELECT ua.step_id, 1 AS answers,
+					CASE WHEN ua.hint_used = 1 THEN 1 ELSE 0 END AS hints,
+					ua.created_at AS first_at, ua.created_at AS last_at
+				FROM user_answers ua
+				WHERE ua.user_id = ?1
+				UNION ALL
+				SELECT pc.step_id, pc.answers, pc.hints, pc.first_answer_at, pc.last_answer_at
+				FROM purged_answer_counts pc
+				WHERE pc.user_id = ?1
+			) a
+			WHERE NOT EXISTS (
 				SELECT 1 FROM user_progress up 
-				WHERE up.user_id = ua.user_id 
-				AND up.step_id = ua.step_id 
+				WHERE up.user_id = ?1 
+				AND up.step_id = a.step_id 
 				AND up.status = 'skipped'
 			)
 		`, userID).Scan(&totalAnswers, &hintsUsed, &firstTimeStr, &lastTimeStr)
@@ -569,6 +694,70 @@ func (s *StatisticsService) getUserDetailedAnswerStats(userID int64) (int, int,
 	return totalAnswers, hintsUsed, first, last, nil
 }
 
+// UserAttemptStats — сводка попыток участника для карточки в админке
+type UserAttemptStats struct {
+	TotalAttempts  int
+	WrongAttempts  int
+	HintsUsed      int
+	StepsAttempted int
+}
+
+// AverageAttemptsPerStep возвращает среднее число попыток на шаг, на который участник отвечал
+func (a *UserAttemptStats) AverageAttemptsPerStep() float64 {
+	if a.StepsAttempted == 0 {
+		return 0
+	}
+	return float64(a.TotalAttempts) / float64(a.StepsAttempted)
+}
+
+// GetUserAttemptStats считает попытки участника по шагам. Неверными считаются все попытки
+// на неодобренных шагах и все, кроме последней, на одобренных; подсказки — по числу шагов,
+// где участник ими воспользовался. Пропущенные шаги не учитываются
+func (s *StatisticsService) GetUserAttemptStats(userID int64) (*UserAttemptStats, error) {
+	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
+		var stats UserAttemptStats
+		// Ответы, удалённые по сроку хранения, учитываются по сохранённым счётчикам
+		err := db.QueryRow(`
+			SELECT
+				COALESCE(SUM(attempts), 0),
+				COALESCE(SUM(CASE WHEN approved THEN attempts - 1 ELSE attempts END), 0),
+				COALESCE(SUM(CASE WHEN hints > 0 THEN 1 ELSE 0 END), 0),
+				COUNT(*)
+			FROM (
+				SELECT a.step_id, SUM(a.answers) AS attempts, SUM(a.hints) AS hints,
+					EXISTS (
+						SELECT 1 FROM user_progress up
+						WHERE up.user_id = ?1
+						AND up.step_id = a.step_id
+						AND up.status = 'approved'
+					) AS approved
+				FROM (
+					SELECT ua.step_id, 1 AS answers,
+						CASE WHEN ua.hint_used = 1 THEN 1 ELSE 0 END AS hints
+					FROM user_answers ua
+					WHERE ua.user_id = ?1
+					UNION ALL
+					SELECT pc.step_id, pc.answers, pc.hints
+					FROM purged_answer_counts pc
+					WHERE pc.user_id = ?1
+				) a
+				WHERE NOT EXISTS (
+					SELECT 1 FROM user_progress up
+					WHERE up.user_id = ?1
+					AND up.step_id = a.step_id
+					AND up.status = 'skipped'
+				)
+				GROUP BY a.step_id
+			)
+		`, userID).Scan(&stats.TotalAttempts, &stats.WrongAttempts, &stats.HintsUsed, &stats.StepsAttempted)
+		return &stats, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*UserAttemptStats), nil
+}
+
 // AudienceSegments — сегменты аудитории по прогрессу
 type AudienceSegments struct {
 	TotalUsers  int
@@ -599,6 +788,10 @@ type HourlyActivity struct {
 
 // GetAudienceSegments возвращает сегменты участников по % прохождения
 func (s *StatisticsService) GetAudienceSegments() (*AudienceSegments, error) {
+	return cachedStats(s, "audience_segments", s.getAudienceSegments)
+}
+
+func (s *StatisticsService) getAudienceSegments() (*AudienceSegments, error) {
 	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		var maxStep int
 		err := db.QueryRow(`
@@ -658,6 +851,12 @@ func (s *StatisticsService) GetAudienceSegments() (*AudienceSegments, error) {
 
 // GetDropoffPoints возвращает шаги с наибольшим числом ушедших участников
 func (s *StatisticsService) GetDropoffPoints(limit int) ([]DropoffPoint, error) {
+	return cachedStats(s, fmt.Sprintf("dropoff:%d", limit), func() ([]DropoffPoint, error) {
+		return s.getDropoffPoints(limit)
+	})
+}
+
+func (s *StatisticsService) getDropoffPoints(limit int) ([]DropoffPoint, error) {
 	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
 			WITH last_steps AS (
@@ -712,6 +911,10 @@ func (s *StatisticsService) GetDropoffPoints(limit int) ([]DropoffPoint, error)
 
 // GetHourlyActivity возвращает активность по часам суток
 func (s *StatisticsService) GetHourlyActivity() ([]HourlyActivity, error) {
+	return cachedStats(s, "hourly_activity", s.getHourlyActivity)
+}
+
+func (s *StatisticsService) getHourlyActivity() ([]HourlyActivity, error) {
 	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
 			SELECT CAST(strftime('%H', created_at) AS INTEGER) as hour,
@@ -1018,6 +1221,10 @@ type StepAnswerStats struct {
 
 // GetFunnelStats возвращает воронку прохождения: уники по шагам
 func (s *StatisticsService) GetFunnelStats() ([]AnswerFunnelData, error) {
+	return cachedStats(s, "funnel", s.getFunnelStats)
+}
+
+func (s *StatisticsService) getFunnelStats() ([]AnswerFunnelData, error) {
 	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
 			SELECT s.step_order, s.text, COUNT(DISTINCT ua.user_id) as unique_users
@@ -1049,6 +1256,12 @@ func (s *StatisticsService) GetFunnelStats() ([]AnswerFunnelData, error) {
 
 // GetHardestSteps возвращает топ шагов с авто-проверкой по среднему числу попыток
 func (s *StatisticsService) GetHardestSteps(limit int) ([]HardestStep, error) {
+	return cachedStats(s, fmt.Sprintf("hardest:%d", limit), func() ([]HardestStep, error) {
+		return s.getHardestSteps(limit)
+	})
+}
+
+func (s *StatisticsService) getHardestSteps(limit int) ([]HardestStep, error) {
 	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
 			SELECT
@@ -1087,6 +1300,12 @@ func (s *StatisticsService) GetHardestSteps(limit int) ([]HardestStep, error) {
 
 // GetHintStats возвращает топ шагов по числу использованных подсказок
 func (s *StatisticsService) GetHintStats(limit int) ([]HintStepData, error) {
+	return cachedStats(s, fmt.Sprintf("hints:%d", limit), func() ([]HintStepData, error) {
+		return s.getHintStats(limit)
+	})
+}
+
+func (s *StatisticsService) getHintStats(limit int) ([]HintStepData, error) {
 	result, err := s.queue.Execute(func(db *sql.DB) (interface{}, error) {
 		rows, err := db.Query(`
 			SELECT s.step_order, s.text, COUNT(ua.id) as hint_count
@@ -2,6 +2,7 @@ package services
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ad/go-telegram-quest/internal/db"
 	"github.com/ad/go-telegram-quest/internal/models"
@@ -193,3 +194,256 @@ func TestProperty9_LeaderboardPositionCalculation(t *testing.T) {
 		}
 	})
 }
+
+func TestCalculateScore(t *testing.T) {
+	if score := CalculateScore(3, 1, 10, 4); score != 26 {
+		t.Errorf("Expected 26 for 3 steps with one hint, got %d", score)
+	}
+	if score := CalculateScore(2, 2, 10, 15); score != 0 {
+		t.Errorf("Expected penalty not to push a step below zero, got %d", score)
+	}
+	if score := CalculateScore(0, 0, 10, 5); score != 0 {
+		t.Errorf("Expected 0 for no steps, got %d", score)
+	}
+}
+
+func TestGetUserScore_WithHints(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	statsService := NewStatisticsService(queue, stepRepo, progressRepo, userRepo)
+
+	userID := int64(1)
+	otherUserID := int64(2)
+	createTestUserForEngine(t, userRepo, userID)
+	createTestUserForEngine(t, userRepo, otherUserID)
+
+	var steps []*models.Step
+	for i := 1; i <= 4; i++ {
+		steps = append(steps, createTestStep(t, stepRepo, i))
+	}
+
+	// Три шага пройдены, на втором использована подсказка, четвёртый ещё не решён
+	for i, step := range steps[:3] {
+		if _, err := answerRepo.CreateTextAnswer(userID, step.ID, "answer", i == 1); err != nil {
+			t.Fatal(err)
+		}
+		createUserProgress(t, progressRepo, userID, step.ID, models.StatusApproved, nil)
+	}
+	if _, err := answerRepo.CreateTextAnswer(userID, steps[3].ID, "wrong", true); err != nil {
+		t.Fatal(err)
+	}
+	createUserProgress(t, progressRepo, userID, steps[3].ID, models.StatusPending, nil)
+
+	createUserProgress(t, progressRepo, otherUserID, steps[0].ID, models.StatusApproved, nil)
+
+	score, err := statsService.GetUserScore(userID, 10, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != 27 {
+		t.Errorf("Expected score 27 (10 + 7 + 10), got %d", score)
+	}
+
+	scores, err := statsService.GetScores(10, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores[userID] != 27 || scores[otherUserID] != 10 {
+		t.Errorf("Expected scores {1: 27, 2: 10}, got %v", scores)
+	}
+}
+
+func TestGetUserAttemptStats(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	statsService := NewStatisticsService(queue, stepRepo, progressRepo, userRepo)
+
+	userID := int64(1)
+	createTestUserForEngine(t, userRepo, userID)
+
+	var steps []*models.Step
+	for i := 1; i <= 4; i++ {
+		steps = append(steps, createTestStep(t, stepRepo, i))
+	}
+
+	answer := func(step *models.Step, count int, hintUsed bool) {
+		for i := 0; i < count; i++ {
+			if _, err := answerRepo.CreateTextAnswer(userID, step.ID, "answer", hintUsed); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Первый шаг с первой попытки, второй с третьей и с подсказкой,
+	// на третьем две неверные попытки, четвёртый пропущен и не учитывается
+	answer(steps[0], 1, false)
+	createUserProgress(t, progressRepo, userID, steps[0].ID, models.StatusApproved, nil)
+	answer(steps[1], 3, true)
+	createUserProgress(t, progressRepo, userID, steps[1].ID, models.StatusApproved, nil)
+	answer(steps[2], 2, false)
+	createUserProgress(t, progressRepo, userID, steps[2].ID, models.StatusPending, nil)
+	answer(steps[3], 5, true)
+	createUserProgress(t, progressRepo, userID, steps[3].ID, models.StatusSkipped, nil)
+
+	stats, err := statsService.GetUserAttemptStats(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := UserAttemptStats{TotalAttempts: 6, WrongAttempts: 4, HintsUsed: 1, StepsAttempted: 3}
+	if *stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *stats)
+	}
+	if avg := stats.AverageAttemptsPerStep(); avg != 2 {
+		t.Errorf("Expected 2 attempts per step on average, got %v", avg)
+	}
+
+	empty, err := statsService.GetUserAttemptStats(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.TotalAttempts != 0 || empty.AverageAttemptsPerStep() != 0 {
+		t.Errorf("Expected no attempts for a user without answers, got %+v", *empty)
+	}
+}
+
+func TestGetUserCompletionPosition(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	statsService := NewStatisticsService(queue, stepRepo, progressRepo, userRepo)
+
+	steps := []*models.Step{createTestStep(t, stepRepo, 1), createTestStep(t, stepRepo, 2)}
+	for id := int64(1); id <= 3; id++ {
+		createTestUserForEngine(t, userRepo, id)
+	}
+
+	// Третий участник финишировал раньше первого, второй не дошёл до последнего шага
+	base := time.Now().Add(-time.Hour)
+	finish := map[int64]time.Time{3: base, 1: base.Add(10 * time.Minute)}
+	for userID, finishedAt := range finish {
+		started := finishedAt.Add(-time.Minute)
+		createUserProgress(t, progressRepo, userID, steps[0].ID, models.StatusApproved, &started)
+		createUserProgress(t, progressRepo, userID, steps[1].ID, models.StatusApproved, &finishedAt)
+	}
+	started := base.Add(-time.Minute)
+	createUserProgress(t, progressRepo, 2, steps[0].ID, models.StatusApproved, &started)
+
+	for userID, expected := range map[int64]int{3: 1, 1: 2, 2: 0} {
+		position, err := statsService.GetUserCompletionPosition(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if position != expected {
+			t.Errorf("User %d: expected completion position %d, got %d", userID, expected, position)
+		}
+	}
+}
+
+func TestStatisticsCache(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	statsService := NewStatisticsService(queue, stepRepo, progressRepo, userRepo)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	statsService.cache.now = func() time.Time { return now }
+	statsService.SetCacheTTL(time.Minute)
+
+	step := createTestStep(t, stepRepo, 1)
+	createTestUserForEngine(t, userRepo, 1)
+	createTestUserForEngine(t, userRepo, 2)
+	createTestUserForEngine(t, userRepo, 3)
+	createUserProgress(t, progressRepo, 1, step.ID, models.StatusApproved, nil)
+
+	approvedOnFirstStep := func() int {
+		t.Helper()
+		stats, err := statsService.CalculateStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats.StepStats[0].Count
+	}
+
+	if got := approvedOnFirstStep(); got != 1 {
+		t.Fatalf("Expected 1 participant on the step, got %d", got)
+	}
+
+	createUserProgress(t, progressRepo, 2, step.ID, models.StatusApproved, nil)
+
+	now = now.Add(30 * time.Second)
+	if got := approvedOnFirstStep(); got != 1 {
+		t.Errorf("Expected cached result within TTL, got %d", got)
+	}
+
+	statsService.InvalidateCache()
+	if got := approvedOnFirstStep(); got != 2 {
+		t.Errorf("Expected recomputed result after invalidation, got %d", got)
+	}
+
+	createUserProgress(t, progressRepo, 3, step.ID, models.StatusApproved, nil)
+	now = now.Add(2 * time.Minute)
+	if got := approvedOnFirstStep(); got != 3 {
+		t.Errorf("Expected recomputed result after TTL, got %d", got)
+	}
+}
+
+func TestStatisticsCache_KeyedByParameters(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	statsService := NewStatisticsService(queue, db.NewStepRepository(queue), db.NewProgressRepository(queue), db.NewUserRepository(queue))
+	statsService.SetCacheTTL(time.Minute)
+
+	calls := 0
+	compute := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	first, _ := cachedStats(statsService, "hardest:5", compute)
+	second, _ := cachedStats(statsService, "hardest:5", compute)
+	other, _ := cachedStats(statsService, "hardest:10", compute)
+
+	if first != 1 || second != 1 {
+		t.Errorf("Expected the same query to be served from cache, got %d and %d", first, second)
+	}
+	if other != 2 {
+		t.Errorf("Expected a different limit to be computed separately, got %d", other)
+	}
+}
+
+func TestStatisticsCache_DisabledByDefault(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	statsService := NewStatisticsService(queue, db.NewStepRepository(queue), db.NewProgressRepository(queue), db.NewUserRepository(queue))
+
+	calls := 0
+	compute := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	cachedStats(statsService, "funnel", compute)
+	cachedStats(statsService, "funnel", compute)
+
+	if calls != 2 {
+		t.Errorf("Expected every call to be computed without TTL, got %d computations", calls)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// statsCache хранит результаты тяжёлых запросов статистики, чтобы повторное открытие экрана
+// не пересчитывало их заново. Ключ — имя запроса вместе с параметрами. При нулевом TTL
+// кэш выключен и каждый запрос выполняется заново
+type statsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]statsCacheEntry
+}
+
+type statsCacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+func newStatsCache() *statsCache {
+	return &statsCache{
+		now:     time.Now,
+		entries: make(map[string]statsCacheEntry),
+	}
+}
+
+func (c *statsCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *statsCache) put(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return
+	}
+	c.entries[key] = statsCacheEntry{value: value, expires: c.now().Add(c.ttl)}
+}
+
+func (c *statsCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ttl = ttl
+	c.entries = make(map[string]statsCacheEntry)
+}
+
+func (c *statsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]statsCacheEntry)
+}
+
+// cachedStats возвращает результат запроса key из кэша или вычисляет его через compute.
+// Ошибки не кэшируются
+func cachedStats[T any](s *StatisticsService, key string, compute func() (T, error)) (T, error) {
+	if value, ok := s.cache.get(key); ok {
+		return value.(T), nil
+	}
+
+	value, err := compute()
+	if err != nil {
+		return value, err
+	}
+	s.cache.put(key, value)
+	return value, nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// MaxStepTagLength — наибольшая длина тега шага в символах
+const MaxStepTagLength = 30
+
+// ParseStepTags разбирает теги шага, введённые через пробел или запятую. Теги приводятся
+// к нижнему регистру, «#» в начале убирается, слишком длинные теги обрезаются, повторы
+// отбрасываются
+func ParseStepTags(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	var tags []string
+	for _, field := range fields {
+		tag := strings.ToLower(strings.TrimLeft(field, "#"))
+		if runes := []rune(tag); len(runes) > MaxStepTagLength {
+			tag = string(runes[:MaxStepTagLength])
+		}
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// FormatStepTags показывает теги в виде «#лес #финал»
+func FormatStepTags(tags []string) string {
+	formatted := make([]string, len(tags))
+	for i, tag := range tags {
+		formatted[i] = "#" + tag
+	}
+	return strings.Join(formatted, " ")
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseStepTags(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"лес финал", []string{"лес", "финал"}},
+		{"#Лес, #ФИНАЛ,лес", []string{"лес", "финал"}},
+		{"  ,  # ", nil},
+		{strings.Repeat("я", MaxStepTagLength+5), []string{strings.Repeat("я", MaxStepTagLength)}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseStepTags(tt.input); !slices.Equal(got, tt.expected) {
+			t.Errorf("ParseStepTags(%q) = %v, expected %v", tt.input, got, tt.expected)
+		}
+	}
+
+	if got := FormatStepTags([]string{"лес", "финал"}); got != "#лес #финал" {
+		t.Errorf("FormatStepTags = %q", got)
+	}
+}
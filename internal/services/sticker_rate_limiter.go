@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStickerOperationsPerMinute — сколько операций с наборами стикеров выполняется в минуту по умолчанию
+	DefaultStickerOperationsPerMinute = 20
+	// DefaultStickerBurst — сколько операций можно выполнить подряд без ожидания
+	DefaultStickerBurst = 3
+)
+
+// StickerRateLimiter — token bucket для создания наборов и добавления стикеров. Операции сверх
+// лимита не отбрасываются, а ждут своей очереди: каждая резервирует следующий свободный
+// токен, поэтому ожидающие выполняются в порядке вызова Wait
+type StickerRateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+
+	// Часы вынесены в поля, чтобы ограничение можно было проверить без реального ожидания
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewStickerRateLimiter создаёт ограничитель на perMinute операций в минуту с запасом burst.
+// При perMinute <= 0 ограничение выключено
+func NewStickerRateLimiter(perMinute, burst int) *StickerRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &StickerRateLimiter{
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+	if perMinute > 0 {
+		l.interval = time.Minute / time.Duration(perMinute)
+	}
+	return l
+}
+
+// Wait блокирует вызывающего, пока для операции не найдётся токен, или до отмены ctx
+func (l *StickerRateLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.interval <= 0 {
+		return nil
+	}
+
+	delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	return l.sleep(ctx, delay)
+}
+
+func (l *StickerRateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.interval))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeStickerClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *fakeStickerClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep сразу переводит часы вперёд, как если бы вызывающий действительно подождал
+func (c *fakeStickerClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func newTestStickerLimiter(perMinute, burst int) (*StickerRateLimiter, *fakeStickerClock) {
+	clock := &fakeStickerClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewStickerRateLimiter(perMinute, burst)
+	limiter.now = clock.Now
+	limiter.sleep = clock.Sleep
+	return limiter, clock
+}
+
+func TestStickerRateLimiter_BurstIsThrottled(t *testing.T) {
+	limiter, clock := newTestStickerLimiter(60, 2)
+	start := clock.Now()
+
+	for i := 0; i < 6; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Два токена из запаса, затем по одной операции в секунду
+	if elapsed := clock.Now().Sub(start); elapsed != 4*time.Second {
+		t.Errorf("Expected 6 operations to take 4s at 60/min with burst 2, took %v", elapsed)
+	}
+	if len(clock.delays) != 4 {
+		t.Errorf("Expected 4 operations to wait, got %d", len(clock.delays))
+	}
+}
+
+func TestStickerRateLimiter_ConcurrentCallersAreQueued(t *testing.T) {
+	clock := &fakeStickerClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewStickerRateLimiter(30, 1)
+	limiter.now = clock.Now
+	limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		clock.delays = append(clock.delays, d)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Wait(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(clock.delays)
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 6 * time.Second}
+	if !slices.Equal(clock.delays, expected) {
+		t.Errorf("Expected queued delays %v, got %v", expected, clock.delays)
+	}
+}
+
+func TestStickerRateLimiter_RefillIsCappedByBurst(t *testing.T) {
+	limiter, clock := newTestStickerLimiter(60, 2)
+
+	for i := 0; i < 2; i++ {
+		limiter.Wait(context.Background())
+	}
+	clock.now = clock.now.Add(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		limiter.Wait(context.Background())
+	}
+	if len(clock.delays) != 1 || clock.delays[0] != time.Second {
+		t.Errorf("Expected only the third operation after idle to wait 1s, got %v", clock.delays)
+	}
+}
+
+func TestStickerRateLimiter_DisabledAndCanceled(t *testing.T) {
+	limiter, clock := newTestStickerLimiter(0, 1)
+	for i := 0; i < 10; i++ {
+		limiter.Wait(context.Background())
+	}
+	if len(clock.delays) != 0 {
+		t.Errorf("Expected no waiting without a rate, got %v", clock.delays)
+	}
+
+	var nilLimiter *StickerRateLimiter
+	if err := nilLimiter.Wait(context.Background()); err != nil {
+		t.Errorf("Expected nil limiter to pass, got %v", err)
+	}
+
+	limiter, _ = newTestStickerLimiter(60, 1)
+	limiter.Wait(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err == nil {
+		t.Error("Expected canceled context to abort waiting")
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+func TestStickerService_RepairStickerPacks(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	achievementRepo := db.NewAchievementRepository(queue)
+	userRepo := db.NewUserRepository(queue)
+	stickerPackRepo := db.NewStickerPackRepository(queue)
+
+	service := NewStickerService(nil, stickerPackRepo, "questbot", "")
+
+	now := time.Now()
+	// 1 — набор не создавался, 2 — создание упало, 3 — набор в порядке,
+	// 4 — попытки исчерпаны, 5 — без достижений
+	for _, userID := range []int64{1, 2, 3, 4, 5} {
+		createTestUserForEngine(t, userRepo, userID)
+	}
+	for _, userID := range []int64{1, 2, 3, 4} {
+		assignAchievementToUser(t, achievementRepo, userID, "pioneer", now)
+	}
+	assignAchievementToUser(t, achievementRepo, 1, "beginner_5", now.Add(time.Minute))
+
+	if err := stickerPackRepo.MarkFailed(2, service.GetPackName(2), "timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stickerPackRepo.Create(3, service.GetPackName(3)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := stickerPackRepo.MarkFailed(4, service.GetPackName(4), "timeout"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	needRepair, err := stickerPackRepo.GetUsersNeedingRepair(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(needRepair) != 2 || needRepair[0] != 1 || needRepair[1] != 2 {
+		t.Fatalf("Expected users 1 and 2 to need repair, got %v", needRepair)
+	}
+
+	var created []int64
+	addedStickers := make(map[int64][]string)
+	service.createPack = func(ctx context.Context, userID int64, achievementKey, emoji string) (string, error) {
+		if userID == 2 {
+			return "", errors.New("telegram API error")
+		}
+		created = append(created, userID)
+		return "", stickerPackRepo.Create(userID, service.GetPackName(userID))
+	}
+	service.addSticker = func(ctx context.Context, userID int64, achievementKey, emoji string) (string, error) {
+		addedStickers[userID] = append(addedStickers[userID], achievementKey)
+		return "", nil
+	}
+
+	repaired, err := service.RepairStickerPacks(context.Background(), 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repaired != 1 || len(created) != 1 || created[0] != 1 {
+		t.Fatalf("Expected only user 1 to be repaired, got repaired=%d created=%v", repaired, created)
+	}
+	if len(addedStickers[1]) != 1 || addedStickers[1][0] != "beginner_5" {
+		t.Errorf("Expected remaining achievements to be added to the pack, got %v", addedStickers[1])
+	}
+
+	pack, err := stickerPackRepo.GetByUserID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Status != models.StickerPackStatusOK {
+		t.Errorf("Expected repaired pack status ok, got %s", pack.Status)
+	}
+
+	needRepair, err = stickerPackRepo.GetUsersNeedingRepair(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(needRepair) != 1 || needRepair[0] != 2 {
+		t.Errorf("Expected only user 2 to still need repair, got %v", needRepair)
+	}
+}
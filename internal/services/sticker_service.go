@@ -22,15 +22,29 @@ type StickerService struct {
 	stickerPackRepo *db.StickerPackRepository
 	botUsername     string
 	botToken        string
+	limiter         *StickerRateLimiter
+
+	// Операции с Telegram API вынесены в поля, чтобы восстановление наборов можно было проверить без сети
+	createPack func(ctx context.Context, userID int64, achievementKey, emoji string) (string, error)
+	addSticker func(ctx context.Context, userID int64, achievementKey, emoji string) (string, error)
 }
 
 func NewStickerService(b *bot.Bot, repo *db.StickerPackRepository, botUsername, botToken string) *StickerService {
-	return &StickerService{
+	s := &StickerService{
 		bot:             b,
 		stickerPackRepo: repo,
 		botUsername:     botUsername,
 		botToken:        botToken,
+		limiter:         NewStickerRateLimiter(DefaultStickerOperationsPerMinute, DefaultStickerBurst),
 	}
+	s.createPack = s.createStickerPack
+	s.addSticker = s.addStickerToSet
+	return s
+}
+
+// SetRateLimiter задаёт ограничение частоты создания наборов и добавления стикеров
+func (s *StickerService) SetRateLimiter(limiter *StickerRateLimiter) {
+	s.limiter = limiter
 }
 
 func (s *StickerService) GetPackName(userID int64) string {
@@ -92,6 +106,10 @@ func (s *StickerService) createStickerPack(ctx context.Context, userID int64, ac
 		Stickers: []tgmodels.InputSticker{inputSticker},
 	}
 
+	if err := s.limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
 	_, err = s.bot.CreateNewStickerSet(ctx, params)
 	if err != nil {
 		if strings.Contains(err.Error(), "STICKER_SET_NAME_OCCUPIED") {
@@ -102,6 +120,9 @@ func (s *StickerService) createStickerPack(ctx context.Context, userID int64, ac
 			return s.getLastStickerFileID(ctx, packName), nil
 		}
 		log.Printf("[STICKER_SERVICE] Failed to create sticker pack: %v", err)
+		if dbErr := s.stickerPackRepo.MarkFailed(userID, packName, err.Error()); dbErr != nil {
+			log.Printf("[STICKER_SERVICE] Failed to mark sticker pack as failed: %v", dbErr)
+		}
 		return "", fmt.Errorf("failed to create sticker pack: %w", err)
 	}
 
@@ -128,6 +149,10 @@ func (s *StickerService) addStickerToSet(ctx context.Context, userID int64, achi
 		return "", fmt.Errorf("failed to read sticker file: %w", err)
 	}
 
+	if err := s.limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
 	err = s.addStickerToSetRaw(ctx, userID, packName, fileContent, emoji)
 	if err != nil {
 		if strings.Contains(err.Error(), "STICKER_EMOJI_INVALID") ||
@@ -275,6 +300,7 @@ func (s *StickerService) getAchievementEmoji(achievementKey string) string {
 		"lightning":       "⚡",
 		"rocket":          "🚀",
 		"cheater":         "🃏",
+		"completionist":   "💯",
 		"photographer":    "📸",
 		"paparazzi":       "📷",
 		"bullseye":        "🎯",
@@ -290,6 +316,7 @@ func (s *StickerService) getAchievementEmoji(achievementKey string) string {
 		"skeptic":         "🤨",
 		"super_collector": "🎁",
 		"super_brain":     "🧠",
+		"flawless":        "💎",
 		"legend":          "👑",
 		"winner_1":        "🥇",
 		"winner_2":        "🥈",
@@ -302,6 +329,10 @@ func (s *StickerService) getAchievementEmoji(achievementKey string) string {
 		"asterisk":        "⭐",
 		"unseen":          "👁️",
 		"voice":           "📢",
+		"comeback":        "🪃",
+		"helper":          "🛟",
+		"night_owl":       "🦉",
+		"streak":          "📅",
 	}
 
 	if emoji, ok := achievementEmojis[achievementKey]; ok {
@@ -331,3 +362,59 @@ func (s *StickerService) SendSticker(ctx context.Context, chatID int64, stickerF
 
 	return err
 }
+
+// RepairStickerPacks повторяет создание наборов стикеров для пользователей с достижениями,
+// у которых набор отсутствует или ранее не создался. Между пользователями выдерживается пауза delay,
+// чтобы не упираться в ограничения Telegram API. Возвращает число восстановленных наборов
+func (s *StickerService) RepairStickerPacks(ctx context.Context, maxAttempts int, delay time.Duration) (int, error) {
+	if maxAttempts <= 0 {
+		return 0, nil
+	}
+
+	userIDs, err := s.stickerPackRepo.GetUsersNeedingRepair(maxAttempts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get users needing sticker pack repair: %w", err)
+	}
+
+	repaired := 0
+	for i, userID := range userIDs {
+		if i > 0 && delay > 0 {
+			select {
+			case <-ctx.Done():
+				return repaired, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		keys, err := s.stickerPackRepo.GetUserAchievementKeys(userID)
+		if err != nil {
+			log.Printf("[STICKER_SERVICE] Failed to get achievements for user %d: %v", userID, err)
+			continue
+		}
+
+		var stickerKeys []string
+		for _, key := range keys {
+			if s.stickerExists(key) {
+				stickerKeys = append(stickerKeys, key)
+			}
+		}
+		if len(stickerKeys) == 0 {
+			continue
+		}
+
+		if _, err := s.createPack(ctx, userID, stickerKeys[0], s.getAchievementEmoji(stickerKeys[0])); err != nil {
+			log.Printf("[STICKER_SERVICE] Sticker pack repair failed for user %d: %v", userID, err)
+			continue
+		}
+
+		for _, key := range stickerKeys[1:] {
+			if _, err := s.addSticker(ctx, userID, key, s.getAchievementEmoji(key)); err != nil {
+				log.Printf("[STICKER_SERVICE] Failed to restore sticker %s for user %d: %v", key, userID, err)
+			}
+		}
+
+		repaired++
+	}
+
+	return repaired, nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+var utcOffsetPattern = regexp.MustCompile(`^(?i:utc|gmt)?\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// ParseTimezone разбирает часовой пояс в формате IANA ("Europe/Moscow") или смещение от UTC ("+3", "UTC-5", "+05:30")
+func ParseTimezone(value string) (*time.Location, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("empty timezone")
+	}
+
+	if m := utcOffsetPattern.FindStringSubmatch(value); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes := 0
+		if m[3] != "" {
+			minutes, _ = strconv.Atoi(m[3])
+		}
+		if hours > 14 || minutes >= 60 {
+			return nil, fmt.Errorf("invalid UTC offset: %s", value)
+		}
+		offset := hours*3600 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(formatUTCOffset(offset), offset), nil
+	}
+
+	if strings.EqualFold(value, "utc") || strings.EqualFold(value, "gmt") {
+		return time.UTC, nil
+	}
+
+	return time.LoadLocation(value)
+}
+
+// ResolveLocation возвращает часовой пояс по имени, при пустом или некорректном значении — серверное время
+func ResolveLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := ParseTimezone(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+func formatUTCOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	hours := offset / 3600
+	minutes := (offset % 3600) / 60
+	if minutes == 0 {
+		return fmt.Sprintf("UTC%s%d", sign, hours)
+	}
+	return fmt.Sprintf("UTC%s%d:%02d", sign, hours, minutes)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimezone(t *testing.T) {
+	tests := []struct {
+		input      string
+		wantOffset int
+		wantName   string
+	}{
+		{"+3", 3 * 3600, "UTC+3"},
+		{"UTC-5", -5 * 3600, "UTC-5"},
+		{"gmt+05:30", 5*3600 + 30*60, "UTC+5:30"},
+		{"UTC", 0, "UTC"},
+		{"Europe/Moscow", 3 * 3600, "Europe/Moscow"},
+	}
+
+	reference := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		loc, err := ParseTimezone(tt.input)
+		if err != nil {
+			t.Errorf("ParseTimezone(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if _, offset := reference.In(loc).Zone(); offset != tt.wantOffset {
+			t.Errorf("ParseTimezone(%q) offset = %d, want %d", tt.input, offset, tt.wantOffset)
+		}
+		if loc.String() != tt.wantName {
+			t.Errorf("ParseTimezone(%q) name = %q, want %q", tt.input, loc.String(), tt.wantName)
+		}
+		if _, err := ParseTimezone(loc.String()); err != nil {
+			t.Errorf("Stored name %q must parse back: %v", loc.String(), err)
+		}
+	}
+
+	for _, invalid := range []string{"", "Mars/Olympus", "+25"} {
+		if _, err := ParseTimezone(invalid); err == nil {
+			t.Errorf("ParseTimezone(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestIsHourInRange(t *testing.T) {
+	if !isHourInRange(3, 0, 5) || isHourInRange(5, 0, 5) {
+		t.Error("Expected [0, 5) range to include 3 and exclude 5")
+	}
+	if !isHourInRange(23, 22, 4) || !isHourInRange(1, 22, 4) || isHourInRange(12, 22, 4) {
+		t.Error("Expected range across midnight to wrap")
+	}
+}
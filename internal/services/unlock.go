@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+	"github.com/ad/go-telegram-quest/internal/models"
+)
+
+// ErrInvalidUnlockCode возвращается, если введённый промокод не открывает ни шаг, ни главу
+var ErrInvalidUnlockCode = errors.New("invalid unlock code")
+
+// ErrUnlockCodeTaken возвращается, если промокод уже открывает другой шаг или главу
+var ErrUnlockCodeTaken = errors.New("unlock code is already used")
+
+// UnlockService открывает шаги и главы, закрытые промокодом. Промокод вводится отдельной
+// командой и не проверяется как ответ на шаг
+type UnlockService struct {
+	unlockRepo *db.UnlockCodeRepository
+}
+
+func NewUnlockService(unlockRepo *db.UnlockCodeRepository) *UnlockService {
+	return &UnlockService{unlockRepo: unlockRepo}
+}
+
+// NormalizeUnlockCode приводит промокод к виду, в котором он хранится: без пробелов по краям
+// и в нижнем регистре
+func NormalizeUnlockCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// SetStepCode задаёт промокод шага; пустой код снимает ограничение
+func (s *UnlockService) SetStepCode(stepID int64, code string) error {
+	code = NormalizeUnlockCode(code)
+	if err := s.checkCodeFree(code, &models.UnlockCode{StepID: stepID}); err != nil {
+		return err
+	}
+	return s.unlockRepo.SetStepCode(stepID, code)
+}
+
+// SetChapterCode задаёт промокод главы; пустой код снимает ограничение
+func (s *UnlockService) SetChapterCode(chapterID int64, code string) error {
+	code = NormalizeUnlockCode(code)
+	if err := s.checkCodeFree(code, &models.UnlockCode{ChapterID: chapterID}); err != nil {
+		return err
+	}
+	return s.unlockRepo.SetChapterCode(chapterID, code)
+}
+
+func (s *UnlockService) checkCodeFree(code string, target *models.UnlockCode) error {
+	if code == "" {
+		return nil
+	}
+	existing, err := s.unlockRepo.Find(code)
+	if err != nil {
+		return err
+	}
+	if existing != nil && (existing.StepID != target.StepID || existing.ChapterID != target.ChapterID) {
+		return ErrUnlockCodeTaken
+	}
+	return nil
+}
+
+func (s *UnlockService) GetStepCode(stepID int64) (string, error) {
+	return s.unlockRepo.GetStepCode(stepID)
+}
+
+func (s *UnlockService) GetChapterCode(chapterID int64) (string, error) {
+	return s.unlockRepo.GetChapterCode(chapterID)
+}
+
+// Redeem открывает участнику шаг или главу по промокоду и возвращает, что именно открыто
+func (s *UnlockService) Redeem(userID int64, code string) (*models.UnlockCode, error) {
+	code = NormalizeUnlockCode(code)
+	if code == "" {
+		return nil, ErrInvalidUnlockCode
+	}
+	target, err := s.unlockRepo.Find(code)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, ErrInvalidUnlockCode
+	}
+	if err := s.unlockRepo.Unlock(userID, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// IsLocked сообщает, что участнику нужно ввести промокод, чтобы отвечать на шаг
+func (s *UnlockService) IsLocked(userID int64, step *models.Step) (bool, error) {
+	return s.unlockRepo.IsLocked(userID, step)
+}
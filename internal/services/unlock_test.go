@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ad/go-telegram-quest/internal/db"
+)
+
+func TestUnlockService_StepCode(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	service := NewUnlockService(db.NewUnlockCodeRepository(queue))
+
+	locked := createTestStep(t, stepRepo, 1)
+	open := createTestStep(t, stepRepo, 2)
+
+	if err := service.SetStepCode(locked.ID, "  Forest "); err != nil {
+		t.Fatalf("SetStepCode: %v", err)
+	}
+	if code, _ := service.GetStepCode(locked.ID); code != "forest" {
+		t.Errorf("Expected normalized code %q, got %q", "forest", code)
+	}
+
+	if isLocked, err := service.IsLocked(1, locked); err != nil || !isLocked {
+		t.Fatalf("Expected step to be locked, got %t (err %v)", isLocked, err)
+	}
+	if isLocked, _ := service.IsLocked(1, open); isLocked {
+		t.Error("Step without code should not be locked")
+	}
+
+	if _, err := service.Redeem(1, "river"); !errors.Is(err, ErrInvalidUnlockCode) {
+		t.Errorf("Expected ErrInvalidUnlockCode, got %v", err)
+	}
+	if isLocked, _ := service.IsLocked(1, locked); !isLocked {
+		t.Error("Invalid code must not unlock the step")
+	}
+
+	target, err := service.Redeem(1, "FOREST")
+	if err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	if target.StepID != locked.ID {
+		t.Errorf("Expected step %d to be unlocked, got %+v", locked.ID, target)
+	}
+	if isLocked, _ := service.IsLocked(1, locked); isLocked {
+		t.Error("Step should be unlocked after redeeming the code")
+	}
+	if isLocked, _ := service.IsLocked(2, locked); !isLocked {
+		t.Error("Code redeemed by one user must not unlock the step for others")
+	}
+
+	if err := service.SetStepCode(open.ID, "forest"); !errors.Is(err, ErrUnlockCodeTaken) {
+		t.Errorf("Expected ErrUnlockCodeTaken, got %v", err)
+	}
+
+	if err := service.SetStepCode(locked.ID, ""); err != nil {
+		t.Fatalf("SetStepCode clear: %v", err)
+	}
+	if isLocked, _ := service.IsLocked(2, locked); isLocked {
+		t.Error("Step should not be locked after the code is removed")
+	}
+}
+
+func TestUnlockService_ChapterCode(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	stepRepo := db.NewStepRepository(queue)
+	service := NewUnlockService(db.NewUnlockCodeRepository(queue))
+
+	chapterID, err := stepRepo.CreateChapter("Лес")
+	if err != nil {
+		t.Fatalf("CreateChapter: %v", err)
+	}
+	step := createTestStep(t, stepRepo, 1)
+	if err := stepRepo.SetChapter(step.ID, chapterID); err != nil {
+		t.Fatalf("SetChapter: %v", err)
+	}
+	step.ChapterID = chapterID
+
+	if err := service.SetChapterCode(chapterID, "owl"); err != nil {
+		t.Fatalf("SetChapterCode: %v", err)
+	}
+	if isLocked, _ := service.IsLocked(1, step); !isLocked {
+		t.Fatal("Step of a locked chapter should be locked")
+	}
+
+	target, err := service.Redeem(1, "owl")
+	if err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	if target.ChapterID != chapterID {
+		t.Errorf("Expected chapter %d to be unlocked, got %+v", chapterID, target)
+	}
+	if isLocked, _ := service.IsLocked(1, step); isLocked {
+		t.Error("Step should be unlocked after redeeming the chapter code")
+	}
+}
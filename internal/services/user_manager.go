@@ -2,7 +2,10 @@ package services
 
 import (
 	"database/sql"
+	"fmt"
+	"html"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/ad/go-telegram-quest/internal/db"
@@ -433,3 +436,155 @@ func (m *UserManager) GetQuestStatistics() (*QuestStatistics, error) {
 
 	return stats, nil
 }
+
+// CanStart проверяет, может ли пользователь начать квест при ограничении limit на число
+// одновременно проходящих участников. Уже начавшие квест пользователи проходят всегда,
+// limit <= 0 означает, что ограничения нет
+func (m *UserManager) CanStart(userID int64, limit int) (bool, error) {
+	if limit <= 0 {
+		return true, nil
+	}
+
+	progress, err := m.progressRepo.GetUserProgress(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if len(progress) > 0 {
+		return true, nil
+	}
+
+	inProgress, err := m.progressRepo.CountInProgressUsers()
+	if err != nil {
+		return false, err
+	}
+	return inProgress < limit, nil
+}
+
+// JoinWaitlist ставит пользователя, которому не хватило места, в лист ожидания
+func (m *UserManager) JoinWaitlist(userID int64) error {
+	return m.userRepo.AddToWaitlist(userID)
+}
+
+// ReleaseWaitlist снимает с листа ожидания столько пользователей, сколько освободилось мест,
+// и возвращает их, чтобы пригласить начать квест
+func (m *UserManager) ReleaseWaitlist(limit int) ([]int64, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	inProgress, err := m.progressRepo.CountInProgressUsers()
+	if err != nil {
+		return nil, err
+	}
+	free := limit - inProgress
+	if free <= 0 {
+		return nil, nil
+	}
+
+	userIDs, err := m.userRepo.GetWaitlist(free)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range userIDs {
+		if err := m.userRepo.RemoveFromWaitlist(userID); err != nil {
+			return nil, err
+		}
+	}
+	return userIDs, nil
+}
+
+// ExportUserProfile собирает полный профиль участника в HTML-документ:
+// данные пользователя, прогресс, статистику, историю ответов и достижения
+func (m *UserManager) ExportUserProfile(userID int64) ([]byte, string, error) {
+	details, err := m.GetUserDetails(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	user := details.User
+
+	var doc strings.Builder
+	doc.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&doc, "<title>Профиль участника %d</title>\n</head>\n", user.ID)
+	doc.WriteString("<body style=\"white-space: pre-wrap; font-family: sans-serif;\">\n")
+
+	doc.WriteString("<h2>👤 Информация о пользователе</h2>\n")
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		fmt.Fprintf(&doc, "📛 Имя: %s\n", html.EscapeString(name))
+	}
+	if user.Username != "" {
+		fmt.Fprintf(&doc, "🔗 Username: @%s\n", html.EscapeString(user.Username))
+	}
+	fmt.Fprintf(&doc, "🆔 ID: %d\n", user.ID)
+	if user.IsBlocked {
+		doc.WriteString("🚫 Статус: Заблокирован\n")
+	} else {
+		doc.WriteString("✅ Статус: Активен\n")
+	}
+
+	doc.WriteString("<h2>📊 Прогресс</h2>\n")
+	switch {
+	case details.IsCompleted:
+		doc.WriteString("✅ Квест завершён\n")
+	case details.CurrentStep != nil:
+		fmt.Fprintf(&doc, "Шаг %d: %s\nСтатус: %s\n", details.CurrentStep.StepOrder, html.EscapeString(details.CurrentStep.Text), html.EscapeString(string(details.Status)))
+	default:
+		doc.WriteString("Не начат\n")
+	}
+
+	if details.Statistics != nil {
+		doc.WriteString(FormatUserStatistics(details.Statistics, details.IsCompleted))
+	}
+
+	doc.WriteString("<h2>📝 История ответов</h2>\n")
+	answers, err := m.answerRepo.GetUserAnswerHistory(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(answers) == 0 {
+		doc.WriteString("Ответов нет\n")
+	}
+	stepOrders := make(map[int64]int)
+	for _, answer := range answers {
+		order, ok := stepOrders[answer.StepID]
+		if !ok {
+			if step, err := m.stepRepo.GetByID(answer.StepID); err == nil && step != nil {
+				order = step.StepOrder
+			}
+			stepOrders[answer.StepID] = order
+		}
+
+		content := html.EscapeString(answer.TextAnswer)
+		if len(answer.Images) > 0 {
+			content = strings.TrimSpace(fmt.Sprintf("%s 🖼️ фото: %d", content, len(answer.Images)))
+		}
+		hint := ""
+		if answer.HintUsed {
+			hint = " 💡"
+		}
+		if answer.MatchRule != "" {
+			hint += " <i>(" + html.EscapeString(MatchKindLabel(MatchKind(answer.MatchRule))) + ")</i>"
+		}
+		fmt.Fprintf(&doc, "%s — шаг %d: %s%s\n", FormatDateTime(answer.CreatedAt), order, content, hint)
+	}
+
+	doc.WriteString("<h2>🏆 Достижения</h2>\n")
+	userAchievements, err := m.achievementRepo.GetUserAchievements(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(userAchievements) == 0 {
+		doc.WriteString("Достижений нет\n")
+	}
+	for _, ua := range userAchievements {
+		achievement, err := m.achievementRepo.GetByID(ua.AchievementID)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&doc, "%s — %s\n", FormatDateTime(ua.EarnedAt), html.EscapeString(achievement.Name))
+	}
+
+	doc.WriteString("</body>\n</html>\n")
+
+	filename := fmt.Sprintf("user_%d_profile_%s.html", userID, time.Now().Format("20060102_150405"))
+	return []byte(doc.String()), filename, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"database/sql"
+	"strings"
 	"testing"
 	"time"
 
@@ -428,3 +429,146 @@ func TestProperty4_RestartAchievementIdempotence(t *testing.T) {
 		}
 	})
 }
+
+func TestExportUserProfile_ContainsSections(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	achievementRepo := db.NewAchievementRepository(queue)
+	statsService := NewStatisticsService(queue, stepRepo, progressRepo, userRepo)
+	chatStateRepo := db.NewChatStateRepository(queue)
+	achievementEngine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	manager := NewUserManager(userRepo, stepRepo, progressRepo, answerRepo, chatStateRepo, achievementRepo, statsService, achievementEngine)
+
+	userID := int64(777)
+	if err := userRepo.CreateOrUpdate(&models.User{ID: userID, FirstName: "Иван", Username: "ivan<script>"}); err != nil {
+		t.Fatal(err)
+	}
+
+	step1 := createTestStep(t, stepRepo, 1)
+	createTestStep(t, stepRepo, 2)
+
+	if _, err := answerRepo.CreateTextAnswer(userID, step1.ID, "неверно", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := answerRepo.CreateTextAnswer(userID, step1.ID, "ответ <b>", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := answerRepo.CreateImageAnswer(userID, step1.ID, []string{"photo_1", "photo_2"}, false); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	createUserProgress(t, progressRepo, userID, step1.ID, models.StatusApproved, &now)
+	assignAchievementToUser(t, achievementRepo, userID, "pioneer", now)
+
+	content, filename, err := manager.ExportUserProfile(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(filename, "user_777_profile_") || !strings.HasSuffix(filename, ".html") {
+		t.Errorf("Unexpected filename %q", filename)
+	}
+
+	doc := string(content)
+	for _, expected := range []string{
+		"Информация о пользователе",
+		"Иван",
+		"@ivan&lt;script&gt;",
+		"Прогресс",
+		"Статистика прохождения",
+		"История ответов",
+		"шаг 1: неверно",
+		"шаг 1: ответ &lt;b&gt; 💡",
+		"🖼️ фото: 2",
+		"Достижения",
+		"Пионер",
+	} {
+		if !strings.Contains(doc, expected) {
+			t.Errorf("Expected profile export to contain %q", expected)
+		}
+	}
+	if strings.Contains(doc, "<script>") {
+		t.Error("User-provided text must be escaped in the export")
+	}
+}
+
+func TestCanStart_ActiveUsersCap(t *testing.T) {
+	queue, cleanup := setupAchievementEngineTestDB(t)
+	defer cleanup()
+
+	userRepo := db.NewUserRepository(queue)
+	stepRepo := db.NewStepRepository(queue)
+	progressRepo := db.NewProgressRepository(queue)
+	answerRepo := db.NewAnswerRepository(queue)
+	achievementRepo := db.NewAchievementRepository(queue)
+	statsService := NewStatisticsService(queue, stepRepo, progressRepo, userRepo)
+	achievementEngine := NewAchievementEngine(achievementRepo, userRepo, progressRepo, stepRepo, queue)
+	manager := NewUserManager(userRepo, stepRepo, progressRepo, answerRepo, db.NewChatStateRepository(queue), achievementRepo, statsService, achievementEngine)
+
+	step1 := createTestStep(t, stepRepo, 1)
+	step2 := createTestStep(t, stepRepo, 2)
+	for i := int64(1); i <= 4; i++ {
+		createTestUserForEngine(t, userRepo, i)
+	}
+
+	const limit = 2
+
+	if ok, err := manager.CanStart(3, 0); err != nil || !ok {
+		t.Fatalf("Expected no cap when limit is 0, got %v, %v", ok, err)
+	}
+
+	createUserProgress(t, progressRepo, 1, step1.ID, models.StatusPending, nil)
+	createUserProgress(t, progressRepo, 2, step1.ID, models.StatusPending, nil)
+
+	if ok, err := manager.CanStart(3, limit); err != nil || ok {
+		t.Fatalf("Expected new user to be gated when cap is reached, got %v, %v", ok, err)
+	}
+	if ok, err := manager.CanStart(1, limit); err != nil || !ok {
+		t.Fatalf("Expected already started user to continue, got %v, %v", ok, err)
+	}
+
+	for _, userID := range []int64{3, 4} {
+		if err := manager.JoinWaitlist(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	released, err := manager.ReleaseWaitlist(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 0 {
+		t.Fatalf("Expected nobody to be released while cap is full, got %v", released)
+	}
+
+	now := time.Now()
+	if err := progressRepo.Update(&models.UserProgress{UserID: 1, StepID: step1.ID, Status: models.StatusApproved, CompletedAt: &now}); err != nil {
+		t.Fatal(err)
+	}
+	createUserProgress(t, progressRepo, 1, step2.ID, models.StatusApproved, &now)
+
+	if ok, err := manager.CanStart(3, limit); err != nil || !ok {
+		t.Fatalf("Expected a slot to free up after completion, got %v, %v", ok, err)
+	}
+
+	released, err = manager.ReleaseWaitlist(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 || released[0] != 3 {
+		t.Fatalf("Expected first waitlisted user to be released, got %v", released)
+	}
+
+	remaining, err := userRepo.GetWaitlist(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0] != 4 {
+		t.Errorf("Expected user 4 to stay on the waitlist, got %v", remaining)
+	}
+}